@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// zstdMagic starts every zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+func newTablesCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "tables [TABLE...]",
+		Short: "List tables with their row and compaction statistics",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			admin, closeDB, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			var tables []db.TableInfo
+			if len(args) == 0 {
+				if tables, err = admin.Tables(cmd.Context()); err != nil {
+					return err
+				}
+			}
+			for _, name := range args {
+				info, err := admin.Table(cmd.Context(), name)
+				if err != nil {
+					return err
+				}
+				tables = append(tables, info)
+			}
+
+			if flags.output == "json" {
+				return printJSON(cmd.OutOrStdout(), tables)
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSCHEMA\tRECORDS\tOBJECTS\tREVISION\tCOMPACTED")
+			for _, t := range tables {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", t.Name, t.SchemaVersion, t.Records, t.Objects, t.Revision, t.CompactionID)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newHistoryCommand(flags *globalFlags) *cobra.Command {
+	var (
+		namespace string
+		opts      db.HistoryOptions
+	)
+	cmd := &cobra.Command{
+		Use:   "history TABLE NAME",
+		Short: "Show the retained revisions of an object, newest first",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			admin, closeDB, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			revisions, err := admin.History(cmd.Context(), args[0], namespace, args[1], opts)
+			if err != nil {
+				return err
+			}
+
+			if flags.output == "json" {
+				return printJSON(cmd.OutOrStdout(), revisions)
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "REVISION\tUID\tEVENT\tTIME\tSIZE")
+			for _, r := range revisions {
+				event := "updated"
+				if r.Deleted {
+					event = "deleted"
+				} else if r.Created {
+					event = "created"
+				}
+				when := "<unknown>"
+				if !r.Time.IsZero() {
+					when = r.Time.UTC().Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", r.ResourceVersion, r.UID, event, when, len(r.Value))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace of the object, empty for cluster scoped objects")
+	cmd.Flags().Int64Var(&opts.Limit, "limit", 0, "Maximum number of revisions to show")
+	cmd.Flags().StringVar(&opts.Before, "before", "", "Only show revisions older than this resourceVersion")
+	return cmd
+}
+
+func newGetCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get TABLE RESOURCEVERSION",
+		Short: "Print the object stored at a revision",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			admin, closeDB, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			revision, err := admin.GetRevision(cmd.Context(), args[0], args[1])
+			if err != nil {
+				return err
+			}
+			if flags.output == "json" {
+				return printJSON(cmd.OutOrStdout(), revision)
+			}
+			return printJSON(cmd.OutOrStdout(), revision.Value)
+		},
+	}
+}
+
+func newRollbackCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback TABLE RESOURCEVERSION",
+		Short: "Write an earlier revision of an object as its latest revision",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			admin, closeDB, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			revision, err := admin.Rollback(cmd.Context(), args[0], args[1])
+			if err != nil {
+				return err
+			}
+			if flags.output == "json" {
+				return printJSON(cmd.OutOrStdout(), revision)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "rolled back %s to revision %s as revision %s\n", revision.Name, args[1], revision.ResourceVersion)
+			return nil
+		},
+	}
+}
+
+func newCompactCommand(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "compact TABLE",
+		Short: "Compact the history of a table",
+		Long: "Compact deletes the history older than the previous compaction point and moves the compaction point to " +
+			"the latest revision, like the periodic compaction of a running server. Run it twice to delete all history.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			admin, closeDB, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			count, err := admin.Compact(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "compacted %s: %d records deleted\n", args[0], count)
+			return nil
+		},
+	}
+}
+
+func newMigrateStorageCommand(flags *globalFlags) *cobra.Command {
+	var (
+		version string
+		opts    db.StorageMigrationOptions
+		quiet   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "migrate-storage TABLE --version VERSION",
+		Short: "Record the stored API version of the rows of a table and re-encode their values",
+		Long: "Migrate-storage records --version as the API version of the rows of the table written before versions " +
+			"were recorded and, with --reencode, rewrites every row with the current value encoding. Rows are " +
+			"rewritten in place in batches, so the table can be used during the migration. Converting rows stored in " +
+			"another API version needs the types of the table and is done by the server that serves it, with " +
+			"WithStorageMigration.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if version == "" {
+				return fmt.Errorf("--version is required")
+			}
+
+			admin, closeDB, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			if !quiet {
+				opts.Progress = func(p db.StorageMigrationProgress) {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d/%d rows\n", args[0], p.Migrated, p.Total)
+				}
+			}
+			progress, err := admin.MigrateStorage(cmd.Context(), args[0], version, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "migrated %s: %d rows rewritten\n", args[0], progress.Migrated)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&version, "version", "", "API version the rows are stored in")
+	cmd.Flags().BoolVar(&opts.Reencode, "reencode", false, "Rewrite every row with the current value encoding")
+	cmd.Flags().IntVar(&opts.BatchSize, "batch-size", 500, "Number of rows rewritten per transaction")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Don't report progress")
+	return cmd
+}
+
+func newVerifyCommand(flags *globalFlags) *cobra.Command {
+	var (
+		opts  db.VerifyOptions
+		scrub bool
+	)
+	cmd := &cobra.Command{
+		Use:   "verify [TABLE...]",
+		Short: "Check the named tables, or all tables, for inconsistent records",
+		Long: "Verify detects broken chains of revisions, stale revisions left by crashed writes, objects that can't be " +
+			"created again because a deleted revision is still marked as created, orphaned tombstones and compaction " +
+			"points ahead of the latest revision. With --repair, the problems that can be repaired are. Stop writers " +
+			"before repairing. With --scrub, every value is also read back and checked against its checksum, which " +
+			"reports corrupt values that can't be repaired. Verify fails if problems remain.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			admin, closeDB, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			if len(args) == 0 {
+				tables, err := admin.Tables(cmd.Context())
+				if err != nil {
+					return err
+				}
+				for _, t := range tables {
+					args = append(args, t.Name)
+				}
+			}
+
+			problems := []db.Problem{}
+			for _, table := range args {
+				found, err := admin.Verify(cmd.Context(), table, opts)
+				if err != nil {
+					return err
+				}
+				problems = append(problems, found...)
+				if scrub {
+					if found, err = admin.Scrub(cmd.Context(), table); err != nil {
+						return err
+					}
+					problems = append(problems, found...)
+				}
+			}
+
+			if flags.output == "json" {
+				if err := printJSON(cmd.OutOrStdout(), problems); err != nil {
+					return err
+				}
+			} else if len(problems) > 0 {
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "TABLE\tPROBLEM\tREVISION\tNAMESPACE\tNAME\tREPAIRED")
+				for _, p := range problems {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\n", p.Table, p.Kind, p.ResourceVersion, p.Namespace, p.Name, p.Repaired)
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+
+			var remaining int
+			for _, p := range problems {
+				if !p.Repaired {
+					remaining++
+				}
+			}
+			if remaining > 0 {
+				return fmt.Errorf("%d problems found", remaining)
+			}
+			if flags.output != "json" {
+				fmt.Fprintf(cmd.OutOrStdout(), "verified %d tables\n", len(args))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.Repair, "repair", false, "Repair the problems that can be repaired")
+	cmd.Flags().BoolVar(&scrub, "scrub", false, "Also check every value against its checksum")
+	return cmd
+}
+
+func newDumpCommand(flags *globalFlags) *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:   "dump [TABLE...]",
+		Short: "Write a backup of the named tables, or all tables, as newline-delimited JSON",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			admin, closeDB, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			var w io.Writer = cmd.OutOrStdout()
+			if file != "" && file != "-" {
+				f, err := os.Create(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			return admin.Dump(cmd.Context(), w, args...)
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "File to write the backup to")
+	return cmd
+}
+
+func newRestoreCommand(flags *globalFlags) *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore a backup written by dump into empty tables",
+		Long: "Restore loads a backup written by dump, or a compressed backup uploaded by the backup schedule of a " +
+			"factory, into empty tables.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			admin, closeDB, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			var r io.Reader = cmd.InOrStdin()
+			if file != "" && file != "-" {
+				f, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+
+			// Backups uploaded on a schedule are zstd compressed
+			buf := bufio.NewReader(r)
+			if magic, _ := buf.Peek(len(zstdMagic)); bytes.Equal(magic, zstdMagic) {
+				dec, err := db.NewBackupReader(buf)
+				if err != nil {
+					return err
+				}
+				defer dec.Close()
+				return admin.Restore(cmd.Context(), dec)
+			}
+			return admin.Restore(cmd.Context(), buf)
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "File to read the backup from")
+	return cmd
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/db"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+const (
+	loadNamespace = "kinm-load"
+	loadGroup     = "group"
+	// loadWritten is the annotation holding the time an object was written, to measure watch latency
+	loadWritten = "load.kinm.io/written"
+)
+
+var loadGVK = schema.GroupVersionKind{Group: "load.kinm.io", Version: "v1", Kind: "LoadObject"}
+
+// loadObject is the kind written by the load generator, stored in the table loadobject.
+type loadObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Data              string `json:"data,omitempty"`
+}
+
+func (l *loadObject) DeepCopyObject() runtime.Object {
+	return &loadObject{
+		TypeMeta:   l.TypeMeta,
+		ObjectMeta: *l.ObjectMeta.DeepCopy(),
+		Data:       l.Data,
+	}
+}
+
+type loadObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []loadObject `json:"items"`
+}
+
+func (l *loadObjectList) DeepCopyObject() runtime.Object {
+	result := &loadObjectList{
+		TypeMeta: l.TypeMeta,
+		ListMeta: *l.ListMeta.DeepCopy(),
+	}
+	for _, item := range l.Items {
+		result.Items = append(result.Items, *item.DeepCopyObject().(*loadObject))
+	}
+	return result
+}
+
+type loadOptions struct {
+	objects  int
+	size     int
+	groups   int
+	duration time.Duration
+	updates  float64
+	gets     float64
+	lists    float64
+	watchers int
+	seed     int64
+}
+
+// loadResult is the report of a load run.
+type loadResult struct {
+	Operations []opStats    `json:"operations"`
+	Before     db.TableInfo `json:"before"`
+	After      db.TableInfo `json:"after"`
+	// DatabaseBytes is the size of the database file, only reported for sqlite.
+	DatabaseBytes int64 `json:"databaseBytes,omitempty"`
+}
+
+type opStats struct {
+	Operation string        `json:"operation"`
+	Count     int           `json:"count"`
+	Errors    int           `json:"errors"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+}
+
+func newLoadCommand(flags *globalFlags) *cobra.Command {
+	var opts loadOptions
+	cmd := &cobra.Command{
+		Use:   "load",
+		Short: "Generate load against a database and report latencies and table growth",
+		Long: "Load creates objects of the kind LoadObject in the table loadobject of the database given by --dsn, " +
+			"then updates, gets and lists them at the given rates while watchers watch them, and reports the latency " +
+			"of each operation and how much the table grew. Watch latency is the time from a write to its event. Lists " +
+			"and watches select objects by label, the objects being spread over --groups label values. Use a " +
+			"dedicated database, the table is left in place.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.dsn == "" {
+				return fmt.Errorf("--dsn or $KINM_DSN is required")
+			}
+			if flags.output != "table" && flags.output != "json" {
+				return fmt.Errorf("invalid output format %q, must be table or json", flags.output)
+			}
+			if opts.objects < 1 || opts.groups < 1 {
+				return fmt.Errorf("--objects and --groups must be at least 1")
+			}
+
+			result, err := runLoad(cmd.Context(), flags, opts)
+			if err != nil {
+				return err
+			}
+
+			if flags.output == "json" {
+				return printJSON(cmd.OutOrStdout(), result)
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "OPERATION\tCOUNT\tERRORS\tP50\tP90\tP99\tMAX")
+			for _, op := range result.Operations {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", op.Operation, op.Count, op.Errors, op.P50, op.P90, op.P99, op.Max)
+			}
+			fmt.Fprintf(w, "\nrecords\t%d -> %d\n", result.Before.Records, result.After.Records)
+			fmt.Fprintf(w, "objects\t%d -> %d\n", result.Before.Objects, result.After.Objects)
+			if result.DatabaseBytes != 0 {
+				fmt.Fprintf(w, "database size\t%d bytes\n", result.DatabaseBytes)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().IntVar(&opts.objects, "objects", 1000, "Number of objects to create")
+	cmd.Flags().IntVar(&opts.size, "size", 1024, "Size in bytes of the data of each object")
+	cmd.Flags().IntVar(&opts.groups, "groups", 10, "Number of label values the objects are spread over")
+	cmd.Flags().DurationVar(&opts.duration, "duration", 30*time.Second, "How long to update, get and list after creating")
+	cmd.Flags().Float64Var(&opts.updates, "update-rate", 100, "Updates per second")
+	cmd.Flags().Float64Var(&opts.gets, "get-rate", 100, "Gets per second")
+	cmd.Flags().Float64Var(&opts.lists, "list-rate", 10, "Lists of a label value per second")
+	cmd.Flags().IntVar(&opts.watchers, "watchers", 10, "Number of watches, each of a label value")
+	cmd.Flags().Int64Var(&opts.seed, "seed", 1, "Seed for choosing objects and generating data")
+	return cmd
+}
+
+// latencies records the latencies of an operation.
+type latencies struct {
+	lock      sync.Mutex
+	durations []time.Duration
+	errors    int
+}
+
+func (l *latencies) observe(start time.Time, err error) {
+	l.record(time.Since(start), err)
+}
+
+func (l *latencies) record(d time.Duration, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err != nil {
+		l.errors++
+		return
+	}
+	l.durations = append(l.durations, d)
+}
+
+func (l *latencies) stats(name string) opStats {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	stats := opStats{
+		Operation: name,
+		Count:     len(l.durations) + l.errors,
+		Errors:    l.errors,
+	}
+	if len(l.durations) == 0 {
+		return stats
+	}
+	slices.Sort(l.durations)
+	percentile := func(p int) time.Duration {
+		return l.durations[(len(l.durations)-1)*p/100]
+	}
+	stats.P50, stats.P90, stats.P99, stats.Max = percentile(50), percentile(90), percentile(99), percentile(100)
+	return stats
+}
+
+func runLoad(ctx context.Context, flags *globalFlags, opts loadOptions) (*loadResult, error) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(loadGVK, &loadObject{})
+	scheme.AddKnownTypeWithName(loadGVK.GroupVersion().WithKind(loadGVK.Kind+"List"), &loadObjectList{})
+
+	f, err := newFactory(scheme, flags.dsn, flags.tablePrefix, flags.schema)
+	if err != nil {
+		return nil, err
+	}
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&loadObject{})
+	if err != nil {
+		return nil, err
+	}
+	defer s.Destroy()
+
+	result := &loadResult{}
+	if result.Before, err = f.Admin().Table(ctx, "loadobject"); err != nil {
+		return nil, err
+	}
+
+	var (
+		random   = rand.New(rand.NewSource(opts.seed))
+		prefix   = strconv.FormatInt(time.Now().UnixNano(), 36)
+		ops      = map[string]*latencies{}
+		opsOrder = []string{"create", "update", "get", "list", "watch"}
+		data     = func() string {
+			var b strings.Builder
+			for range opts.size {
+				b.WriteByte(byte('a' + random.Intn(26)))
+			}
+			return b.String()
+		}
+	)
+	for _, name := range opsOrder {
+		ops[name] = &latencies{}
+	}
+
+	// Watch from the current revision so objects of previous runs don't skew the watch latency
+	current, err := s.List(ctx, loadNamespace, storage.ListOptions{
+		Predicate: storage.SelectionPredicate{Limit: 1},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	watchCtx, cancelWatches := context.WithCancel(ctx)
+	defer cancelWatches()
+	var watchers sync.WaitGroup
+	for i := range opts.watchers {
+		w, err := s.Watch(watchCtx, loadNamespace, storage.ListOptions{
+			ResourceVersion: current.GetResourceVersion(),
+			Predicate: storage.SelectionPredicate{
+				Label: labels.SelectorFromSet(labels.Set{loadGroup: strconv.Itoa(i % opts.groups)}),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		watchers.Add(1)
+		go func() {
+			defer watchers.Done()
+			watchLatency(w, ops["watch"])
+		}()
+	}
+
+	objects := make([]*loadObject, 0, opts.objects)
+	for i := range opts.objects {
+		obj := &loadObject{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("load-%s-%d", prefix, i),
+				Namespace: loadNamespace,
+				UID:       ktypes.UID(uuid.NewUUID()),
+				Labels:    map[string]string{loadGroup: strconv.Itoa(i % opts.groups)},
+			},
+			Data: data(),
+		}
+		start := time.Now()
+		obj.Annotations = map[string]string{loadWritten: strconv.FormatInt(start.UnixNano(), 10)}
+		created, err := s.Create(ctx, obj)
+		ops["create"].observe(start, err)
+		if err != nil {
+			continue
+		}
+		objects = append(objects, created.(*loadObject))
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects could be created")
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+	var (
+		runners sync.WaitGroup
+		lock    sync.Mutex
+	)
+	runAt := func(rate float64, op func()) {
+		if rate <= 0 {
+			return
+		}
+		runners.Add(1)
+		go func() {
+			defer runners.Done()
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+			defer ticker.Stop()
+			for {
+				select {
+				case <-runCtx.Done():
+					return
+				case <-ticker.C:
+					op()
+				}
+			}
+		}()
+	}
+
+	runAt(opts.updates, func() {
+		lock.Lock()
+		i := random.Intn(len(objects))
+		obj := objects[i].DeepCopyObject().(*loadObject)
+		obj.Data = data()
+		lock.Unlock()
+
+		start := time.Now()
+		obj.Annotations[loadWritten] = strconv.FormatInt(start.UnixNano(), 10)
+		updated, err := s.Update(runCtx, obj)
+		if runCtx.Err() != nil {
+			return
+		}
+		ops["update"].observe(start, err)
+		if err == nil {
+			lock.Lock()
+			objects[i] = updated.(*loadObject)
+			lock.Unlock()
+		}
+	})
+	runAt(opts.gets, func() {
+		lock.Lock()
+		name := objects[random.Intn(len(objects))].Name
+		lock.Unlock()
+
+		start := time.Now()
+		_, err := s.Get(runCtx, loadNamespace, name)
+		if runCtx.Err() == nil {
+			ops["get"].observe(start, err)
+		}
+	})
+	runAt(opts.lists, func() {
+		lock.Lock()
+		group := strconv.Itoa(random.Intn(opts.groups))
+		lock.Unlock()
+
+		start := time.Now()
+		_, err := s.List(runCtx, loadNamespace, storage.ListOptions{
+			Predicate: storage.SelectionPredicate{
+				Label: labels.SelectorFromSet(labels.Set{loadGroup: group}),
+			},
+		})
+		if runCtx.Err() == nil {
+			ops["list"].observe(start, err)
+		}
+	})
+	runners.Wait()
+	cancelWatches()
+	watchers.Wait()
+
+	for _, name := range opsOrder {
+		result.Operations = append(result.Operations, ops[name].stats(name))
+	}
+	if result.After, err = f.Admin().Table(ctx, "loadobject"); err != nil {
+		return nil, err
+	}
+	if path, ok := strings.CutPrefix(flags.dsn, "sqlite://"); ok {
+		if info, err := os.Stat(path); err == nil {
+			result.DatabaseBytes = info.Size()
+		}
+	}
+	return result, nil
+}
+
+// watchLatency records the time from each write to its watch event until w is closed.
+func watchLatency(w <-chan watch.Event, l *latencies) {
+	for event := range w {
+		switch event.Type {
+		case watch.Added, watch.Modified:
+		case watch.Error:
+			l.record(0, fmt.Errorf("watch error"))
+			continue
+		default:
+			continue
+		}
+		obj, ok := event.Object.(*loadObject)
+		if !ok {
+			continue
+		}
+		written, err := strconv.ParseInt(obj.Annotations[loadWritten], 10, 64)
+		if err != nil {
+			continue
+		}
+		l.record(time.Since(time.Unix(0, written)), nil)
+	}
+}
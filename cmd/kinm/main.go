@@ -0,0 +1,91 @@
+// Command kinm performs operational tasks on a kinm database, such as inspecting tables and object history, rolling
+// back objects, compacting tables and taking backups.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/obot-platform/kinm/pkg/db"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type globalFlags struct {
+	dsn         string
+	tablePrefix string
+	schema      string
+	output      string
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	flags := &globalFlags{}
+	root := &cobra.Command{
+		Use:           "kinm",
+		Short:         "Operate on the tables of a kinm database",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.PersistentFlags().StringVar(&flags.dsn, "dsn", os.Getenv("KINM_DSN"),
+		"Database to connect to, sqlite://<path> or postgres://... (default $KINM_DSN)")
+	root.PersistentFlags().StringVar(&flags.tablePrefix, "table-prefix", "", "Prefix of the table names")
+	root.PersistentFlags().StringVar(&flags.schema, "schema", "", "Postgres schema of the tables")
+	root.PersistentFlags().StringVarP(&flags.output, "output", "o", "table", "Output format, table or json")
+
+	root.AddCommand(
+		newTablesCommand(flags),
+		newHistoryCommand(flags),
+		newGetCommand(flags),
+		newRollbackCommand(flags),
+		newCompactCommand(flags),
+		newMigrateStorageCommand(flags),
+		newRotateKeyCommand(flags),
+		newVerifyCommand(flags),
+		newDumpCommand(flags),
+		newRestoreCommand(flags),
+		newMigrateCommand(flags),
+		newLoadCommand(flags),
+	)
+	return root
+}
+
+// admin connects to the database selected by the flags.
+func (g *globalFlags) admin(opts ...db.FactoryOption) (*db.Admin, func(), error) {
+	if g.dsn == "" {
+		return nil, nil, fmt.Errorf("--dsn or $KINM_DSN is required")
+	}
+	if g.output != "table" && g.output != "json" {
+		return nil, nil, fmt.Errorf("invalid output format %q, must be table or json", g.output)
+	}
+	return connect(g.dsn, g.tablePrefix, g.schema, opts...)
+}
+
+func connect(dsn, tablePrefix, schema string, opts ...db.FactoryOption) (*db.Admin, func(), error) {
+	f, err := newFactory(runtime.NewScheme(), dsn, tablePrefix, schema, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f.Admin(), func() { _ = f.SQLDB.Close() }, nil
+}
+
+func newFactory(scheme *runtime.Scheme, dsn, tablePrefix, schema string, opts ...db.FactoryOption) (*db.Factory, error) {
+	if tablePrefix != "" {
+		opts = append(opts, db.WithTablePrefix(tablePrefix))
+	}
+	if schema != "" {
+		opts = append(opts, db.WithSchema(schema))
+	}
+	return db.NewFactory(scheme, dsn, opts...)
+}
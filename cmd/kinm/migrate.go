@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/obot-platform/kinm/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCommand(flags *globalFlags) *cobra.Command {
+	var (
+		to          string
+		tablePrefix string
+		schema      string
+		quiet       bool
+	)
+	cmd := &cobra.Command{
+		Use:   "migrate --to DSN [TABLE...]",
+		Short: "Copy the named tables, or all tables, to another database",
+		Long: "Migrate copies tables from the database given by --dsn to the database given by --to, which may use a " +
+			"different driver. Resource versions, history and compaction points are preserved and the copy is " +
+			"verified. The destination tables must be empty. Stop writers to the source before migrating, writes made " +
+			"during the copy are not included.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if to == "" {
+				return fmt.Errorf("--to is required")
+			}
+
+			src, closeSrc, err := flags.admin()
+			if err != nil {
+				return err
+			}
+			defer closeSrc()
+
+			dst, closeDst, err := connect(to, tablePrefix, schema)
+			if err != nil {
+				return err
+			}
+			defer closeDst()
+
+			opts := db.CopyOptions{Tables: args}
+			if !quiet {
+				opts.Progress = func(p db.CopyProgress) {
+					if p.Copied%1000 == 0 || p.Copied == p.Total {
+						fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d/%d records\n", p.Table, p.Copied, p.Total)
+					}
+				}
+			}
+			if err := src.CopyTo(cmd.Context(), dst, opts); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "migration complete and verified")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&to, "to", "", "Database to copy to, sqlite://<path> or postgres://...")
+	cmd.Flags().StringVar(&tablePrefix, "to-table-prefix", "", "Prefix of the table names in the destination")
+	cmd.Flags().StringVar(&schema, "to-schema", "", "Postgres schema of the tables in the destination")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Don't report progress")
+	return cmd
+}
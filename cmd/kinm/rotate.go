@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/db"
+	"github.com/obot-platform/kinm/pkg/db/envelope"
+	"github.com/spf13/cobra"
+)
+
+func newRotateKeyCommand(flags *globalFlags) *cobra.Command {
+	var (
+		kmsURL  string
+		version string
+		opts    db.StorageMigrationOptions
+		quiet   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "rotate-key TABLE --kms URL --version VERSION",
+		Short: "Re-encrypt the rows of a table with the current key of a KMS",
+		Long: "Rotate-key rewrites every row of the table encrypted with envelope encryption, so that it is encrypted " +
+			"with a new data encryption key wrapped by the current key of the KMS. Rows are rewritten in place in " +
+			"batches, so the table can be used during the rotation; the old key must stay available until it is done. " +
+			"--kms is local:///path/to/keyfile, with a key per line of the form ID=BASE64KEY and the current key " +
+			"first, gcpkms://projects/P/locations/L/keyRings/R/cryptoKeys/K, authorized with " +
+			"$GOOGLE_OAUTH_ACCESS_TOKEN or the metadata server, or awskms://KEY?region=REGION, signed with " +
+			"$AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN. --version is the API version rows " +
+			"without a recorded version are stored in, as with migrate-storage.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if kmsURL == "" {
+				return fmt.Errorf("--kms is required")
+			}
+			if version == "" {
+				return fmt.Errorf("--version is required")
+			}
+			kms, err := parseKMS(kmsURL)
+			if err != nil {
+				return err
+			}
+
+			transformer := envelope.NewTransformer(kms, envelope.Options{})
+			admin, closeDB, err := flags.admin(db.WithStrategyOptions(db.WithValueTransformer(transformer)))
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			opts.Reencode = true
+			if !quiet {
+				opts.Progress = func(p db.StorageMigrationProgress) {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d/%d rows\n", args[0], p.Migrated, p.Total)
+				}
+			}
+			progress, err := admin.MigrateStorage(cmd.Context(), args[0], version, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "rotated %s: %d rows re-encrypted\n", args[0], progress.Migrated)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&kmsURL, "kms", "", "KMS the data encryption keys are encrypted with")
+	cmd.Flags().StringVar(&version, "version", "", "API version the rows are stored in")
+	cmd.Flags().IntVar(&opts.BatchSize, "batch-size", 500, "Number of rows rewritten per transaction")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Don't report progress")
+	return cmd
+}
+
+// parseKMS returns the KMS of a --kms URL. The key is taken verbatim, since AWS key ARNs aren't valid URL hosts.
+func parseKMS(kmsURL string) (envelope.KMS, error) {
+	scheme, rest, _ := strings.Cut(kmsURL, "://")
+	key, rawQuery, _ := strings.Cut(rest, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --kms: %w", err)
+	}
+
+	switch scheme {
+	case "local":
+		return envelope.LoadLocalKMS(key)
+	case "gcpkms":
+		kms := &envelope.GCPKMS{Key: key}
+		if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+			kms.Token = func(context.Context) (string, error) { return token, nil }
+		}
+		return kms, nil
+	case "awskms":
+		kms := &envelope.AWSKMS{Key: key, Region: query.Get("region")}
+		if kms.Region == "" {
+			kms.Region = os.Getenv("AWS_REGION")
+		}
+		if kms.Region == "" {
+			return nil, fmt.Errorf("the region of the AWS KMS key is required, set ?region= or $AWS_REGION")
+		}
+		return kms, nil
+	default:
+		return nil, fmt.Errorf("unsupported --kms %q, must be local://, gcpkms:// or awskms://", kmsURL)
+	}
+}
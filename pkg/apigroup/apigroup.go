@@ -33,3 +33,28 @@ func ForStores(scheme AddToScheme, stores map[string]rest.Storage, groupVersion
 	}
 	return &apiGroupInfo, nil
 }
+
+// ForVersionedStores is ForStores for a group served in several versions. The stores hold the objects of
+// storageVersion, which is also the preferred version, and serve them in every version of versions as well. The
+// scheme must register the types of those versions and the conversion functions between them and the types of the
+// storage version, which the apiserver uses to convert requests and responses.
+func ForVersionedStores(scheme AddToScheme, stores map[string]rest.Storage, storageVersion schema.GroupVersion, versions ...string) (*genericapiserver.APIGroupInfo, error) {
+	apiGroupInfo, err := ForStores(scheme, stores, storageVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	priority := []schema.GroupVersion{storageVersion}
+	for _, version := range versions {
+		if version == storageVersion.Version {
+			continue
+		}
+		apiGroupInfo.VersionedResourcesStorageMap[version] = stores
+		priority = append(priority, schema.GroupVersion{Group: storageVersion.Group, Version: version})
+	}
+	if err := apiGroupInfo.Scheme.SetVersionPriority(priority...); err != nil {
+		return nil, err
+	}
+	apiGroupInfo.PrioritizedVersions = priority
+	return apiGroupInfo, nil
+}
@@ -0,0 +1,154 @@
+// Package cdc publishes the changes made to kinm tables to an external message bus.
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+// Change is a single change to an object.
+type Change struct {
+	GroupVersionKind schema.GroupVersionKind
+	// Type is Added, Modified or Deleted. A change of type Error carries a *metav1.Status as Object and ends the feed.
+	Type watch.EventType
+	// ResourceVersion is the resource version of the change, which can be used to resume the feed.
+	ResourceVersion string
+	// Object is the object after the change. For deletes it is the last state of the object.
+	Object runtime.Object
+	// OldObject is the object before the change. It is nil for creates and when the previous version has already
+	// been compacted.
+	OldObject runtime.Object
+}
+
+// Source provides the change feed of one or more kinds. Changes are delivered in resource version order per kind.
+// start maps kinds to the resource version to resume after. Kinds without a start revision begin with changes made
+// after the call.
+type Source interface {
+	Changes(ctx context.Context, start map[schema.GroupVersionKind]string, gvks ...schema.GroupVersionKind) (<-chan Change, error)
+}
+
+// Sink publishes changes to a message bus such as NATS or Kafka.
+type Sink interface {
+	Publish(ctx context.Context, change Change) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, change Change) error
+
+func (f SinkFunc) Publish(ctx context.Context, change Change) error {
+	return f(ctx, change)
+}
+
+// Checkpointer stores the last published resource version of each kind so that publishing resumes where it left off
+// after a restart.
+type Checkpointer interface {
+	Load(ctx context.Context) (map[schema.GroupVersionKind]string, error)
+	Save(ctx context.Context, gvk schema.GroupVersionKind, resourceVersion string) error
+}
+
+// Publisher tails the change feed of a Source and publishes every change to a Sink. Changes are published at least
+// once: a change is retried until the sink accepts it, and after a restart changes since the last checkpoint may be
+// published again.
+type Publisher struct {
+	source       Source
+	sink         Sink
+	gvks         []schema.GroupVersionKind
+	checkpointer Checkpointer
+	backoff      wait.Backoff
+}
+
+type Option func(*Publisher)
+
+// WithKinds limits the published kinds. By default all kinds of the source are published.
+func WithKinds(gvks ...schema.GroupVersionKind) Option {
+	return func(p *Publisher) {
+		p.gvks = append(p.gvks, gvks...)
+	}
+}
+
+// WithCheckpointer resumes publishing from, and records progress in, checkpointer.
+func WithCheckpointer(checkpointer Checkpointer) Option {
+	return func(p *Publisher) {
+		p.checkpointer = checkpointer
+	}
+}
+
+// WithRetryBackoff sets the backoff between attempts to publish a change. The default starts at 100ms and is capped
+// at 30s.
+func WithRetryBackoff(backoff wait.Backoff) Option {
+	return func(p *Publisher) {
+		p.backoff = backoff
+	}
+}
+
+func NewPublisher(source Source, sink Sink, opts ...Option) *Publisher {
+	p := &Publisher{
+		source: source,
+		sink:   sink,
+		backoff: wait.Backoff{
+			Duration: 100 * time.Millisecond,
+			Factor:   2,
+			Jitter:   0.1,
+			Steps:    1 << 30,
+			Cap:      30 * time.Second,
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Run publishes changes until ctx is done or the change feed fails.
+func (p *Publisher) Run(ctx context.Context) error {
+	var start map[schema.GroupVersionKind]string
+	if p.checkpointer != nil {
+		var err error
+		if start, err = p.checkpointer.Load(ctx); err != nil {
+			return fmt.Errorf("failed to load checkpoint: %w", err)
+		}
+	}
+
+	changes, err := p.source.Changes(ctx, start, p.gvks...)
+	if err != nil {
+		return err
+	}
+
+	for change := range changes {
+		if change.Type == watch.Error {
+			if status, ok := change.Object.(*metav1.Status); ok {
+				return &apierrors.StatusError{ErrStatus: *status}
+			}
+			return fmt.Errorf("change feed failed: %v", change.Object)
+		}
+		if err := p.publish(ctx, change); err != nil {
+			return err
+		}
+		if p.checkpointer != nil {
+			if err := p.checkpointer.Save(ctx, change.GroupVersionKind, change.ResourceVersion); err != nil {
+				return fmt.Errorf("failed to save checkpoint: %w", err)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+func (p *Publisher) publish(ctx context.Context, change Change) error {
+	return wait.ExponentialBackoffWithContext(ctx, p.backoff, func(ctx context.Context) (bool, error) {
+		if err := p.sink.Publish(ctx, change); err != nil {
+			klog.Warningf("failed to publish %s change of %s at %s, retrying: %v",
+				change.Type, change.GroupVersionKind.Kind, change.ResourceVersion, err)
+			return false, nil
+		}
+		return true, nil
+	})
+}
@@ -0,0 +1,337 @@
+// Package client implements the controller-runtime client directly against kinm strategies, so that controllers
+// running in the same process as the API server don't go through HTTP and serialization.
+//
+// The client calls the strategies, not the REST adapters, so request validation and admission that run in the
+// apiserver handler chain are skipped. Only typed objects that are registered in the scheme are supported.
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/names"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+var _ kclient.WithWatch = (*Client)(nil)
+
+// StrategyResolver returns the strategy that stores a kind. db.Factory implements it for the strategies it created.
+type StrategyResolver interface {
+	StrategyFor(gvk schema.GroupVersionKind) (strategy.CompleteStrategy, error)
+}
+
+// Strategies is a StrategyResolver for a fixed set of strategies.
+type Strategies map[schema.GroupVersionKind]strategy.CompleteStrategy
+
+func (s Strategies) StrategyFor(gvk schema.GroupVersionKind) (strategy.CompleteStrategy, error) {
+	if result, ok := s[gvk]; ok {
+		return result, nil
+	}
+	return nil, fmt.Errorf("no strategy for %s", gvk)
+}
+
+type Option func(*Client)
+
+// WithRESTMapper sets the mapper returned by RESTMapper. By default the mapper is built from the kinds in the scheme.
+func WithRESTMapper(mapper meta.RESTMapper) Option {
+	return func(c *Client) {
+		c.mapper = mapper
+	}
+}
+
+type Client struct {
+	scheme     *runtime.Scheme
+	strategies StrategyResolver
+	mapper     meta.RESTMapper
+}
+
+func New(scheme *runtime.Scheme, strategies StrategyResolver, opts ...Option) *Client {
+	c := &Client{
+		scheme:     scheme,
+		strategies: strategies,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.mapper == nil {
+		c.mapper = defaultRESTMapper(scheme)
+	}
+	return c
+}
+
+func defaultRESTMapper(scheme *runtime.Scheme) meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(scheme.PrioritizedVersionsAllGroups())
+	for gvk := range scheme.AllKnownTypes() {
+		if strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		scope := meta.RESTScopeNamespace
+		if obj, err := scheme.New(gvk); err == nil {
+			if scoper, ok := obj.(strategy.NamespaceScoper); ok && !scoper.NamespaceScoped() {
+				scope = meta.RESTScopeRoot
+			}
+		}
+		mapper.Add(gvk, scope)
+	}
+	return mapper
+}
+
+func (c *Client) Scheme() *runtime.Scheme {
+	return c.scheme
+}
+
+func (c *Client) RESTMapper() meta.RESTMapper {
+	return c.mapper
+}
+
+func (c *Client) GroupVersionKindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	return apiutil.GVKForObject(obj, c.scheme)
+}
+
+func (c *Client) IsObjectNamespaced(obj runtime.Object) (bool, error) {
+	s, err := c.strategyFor(obj)
+	if err != nil {
+		return false, err
+	}
+	return strategy.NewScoper(s).NamespaceScoped(), nil
+}
+
+func (c *Client) strategyFor(obj runtime.Object) (strategy.CompleteStrategy, error) {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return nil, err
+	}
+	return c.strategies.StrategyFor(gvk)
+}
+
+func (c *Client) strategyForList(list kclient.ObjectList) (strategy.CompleteStrategy, error) {
+	gvk, err := c.GroupVersionKindFor(list)
+	if err != nil {
+		return nil, err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	return c.strategies.StrategyFor(gvk)
+}
+
+// setObject copies src into dst, which must be a pointer to the same type.
+func setObject(dst, src runtime.Object) error {
+	dstValue, srcValue := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if dstValue.Type() != srcValue.Type() {
+		return fmt.Errorf("can't set %T to %T", dst, src)
+	}
+	dstValue.Elem().Set(srcValue.Elem())
+	return nil
+}
+
+func dryRun(opts []string) error {
+	if len(opts) > 0 {
+		return apierrors.NewBadRequest("dry run is not supported")
+	}
+	return nil
+}
+
+func (c *Client) Get(ctx context.Context, key kclient.ObjectKey, obj kclient.Object, opts ...kclient.GetOption) error {
+	s, err := c.strategyFor(obj)
+	if err != nil {
+		return err
+	}
+	var result types.Object
+	getOpts := (&kclient.GetOptions{}).ApplyOptions(opts)
+	if getOpts.Raw != nil && getOpts.Raw.ResourceVersion != "" {
+		result, err = strategy.GetWithOptions(ctx, s, key.Namespace, key.Name, *getOpts.Raw)
+	} else {
+		result, err = s.Get(ctx, key.Namespace, key.Name)
+	}
+	if err != nil {
+		return err
+	}
+	return setObject(obj, result)
+}
+
+func toStorageListOptions(opts *kclient.ListOptions) storage.ListOptions {
+	result := storage.ListOptions{
+		Predicate: storage.SelectionPredicate{
+			Label:    opts.LabelSelector,
+			Field:    opts.FieldSelector,
+			Limit:    opts.Limit,
+			Continue: opts.Continue,
+		},
+		Recursive: true,
+	}
+	if result.Predicate.Label == nil {
+		result.Predicate.Label = labels.Everything()
+	}
+	if result.Predicate.Field == nil {
+		result.Predicate.Field = fields.Everything()
+	}
+	if opts.Raw != nil {
+		result.ResourceVersion = opts.Raw.ResourceVersion
+		result.Predicate.AllowWatchBookmarks = opts.Raw.AllowWatchBookmarks
+	}
+	return result
+}
+
+func (c *Client) List(ctx context.Context, list kclient.ObjectList, opts ...kclient.ListOption) error {
+	s, err := c.strategyForList(list)
+	if err != nil {
+		return err
+	}
+	listOpts := (&kclient.ListOptions{}).ApplyOptions(opts)
+	result, err := s.List(ctx, listOpts.Namespace, toStorageListOptions(listOpts))
+	if err != nil {
+		return err
+	}
+	return setObject(list, result)
+}
+
+func (c *Client) Watch(ctx context.Context, list kclient.ObjectList, opts ...kclient.ListOption) (watch.Interface, error) {
+	s, err := c.strategyForList(list)
+	if err != nil {
+		return nil, err
+	}
+	listOpts := (&kclient.ListOptions{}).ApplyOptions(opts)
+	storageOpts := toStorageListOptions(listOpts)
+	// Watches don't page
+	storageOpts.Predicate.Limit = 0
+	storageOpts.Predicate.Continue = ""
+
+	ctx, cancel := context.WithCancel(ctx)
+	events, err := s.Watch(ctx, listOpts.Namespace, storageOpts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &watcher{
+		cancel: cancel,
+		events: events,
+	}, nil
+}
+
+type watcher struct {
+	cancel context.CancelFunc
+	events <-chan watch.Event
+}
+
+func (w *watcher) Stop() {
+	w.cancel()
+	go func() {
+		for range w.events {
+		}
+	}()
+}
+
+func (w *watcher) ResultChan() <-chan watch.Event {
+	return w.events
+}
+
+func (c *Client) Create(ctx context.Context, obj kclient.Object, opts ...kclient.CreateOption) error {
+	s, err := c.strategyFor(obj)
+	if err != nil {
+		return err
+	}
+	createOpts := (&kclient.CreateOptions{}).ApplyOptions(opts)
+	if err := dryRun(createOpts.DryRun); err != nil {
+		return err
+	}
+	if obj.GetResourceVersion() != "" {
+		return apierrors.NewBadRequest("resourceVersion should not be set on objects to be created")
+	}
+
+	toCreate := obj.DeepCopyObject().(types.Object)
+	if toCreate.GetName() == "" && toCreate.GetGenerateName() != "" {
+		toCreate.SetName(names.SimpleNameGenerator.GenerateName(toCreate.GetGenerateName()))
+	}
+	// The REST handler normally fills these in
+	rest.FillObjectMetaSystemFields(toCreate)
+
+	result, err := s.Create(ctx, toCreate)
+	if err != nil {
+		return err
+	}
+	return setObject(obj, result)
+}
+
+func (c *Client) Update(ctx context.Context, obj kclient.Object, opts ...kclient.UpdateOption) error {
+	s, err := c.strategyFor(obj)
+	if err != nil {
+		return err
+	}
+	if err := dryRun((&kclient.UpdateOptions{}).ApplyOptions(opts).DryRun); err != nil {
+		return err
+	}
+	result, err := s.Update(ctx, obj.DeepCopyObject().(types.Object))
+	if err != nil {
+		return err
+	}
+	return setObject(obj, result)
+}
+
+func (c *Client) Delete(ctx context.Context, obj kclient.Object, opts ...kclient.DeleteOption) error {
+	s, err := c.strategyFor(obj)
+	if err != nil {
+		return err
+	}
+	deleteOpts := (&kclient.DeleteOptions{}).ApplyOptions(opts)
+	if err := dryRun(deleteOpts.DryRun); err != nil {
+		return err
+	}
+	return c.delete(ctx, s, obj.GetNamespace(), obj.GetName(), deleteOpts.Preconditions)
+}
+
+func (c *Client) delete(ctx context.Context, s strategy.CompleteStrategy, namespace, name string, preconditions *metav1.Preconditions) error {
+	current, err := s.Get(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+	if preconditions != nil {
+		if preconditions.UID != nil && *preconditions.UID != current.GetUID() {
+			return apierrors.NewConflict(schema.GroupResource{}, name,
+				fmt.Errorf("the UID in the precondition (%s) does not match the UID in record (%s)", *preconditions.UID, current.GetUID()))
+		}
+		if preconditions.ResourceVersion != nil && *preconditions.ResourceVersion != current.GetResourceVersion() {
+			return apierrors.NewConflict(schema.GroupResource{}, name,
+				fmt.Errorf("the ResourceVersion in the precondition (%s) does not match the ResourceVersion in record (%s)",
+					*preconditions.ResourceVersion, current.GetResourceVersion()))
+		}
+	}
+	_, err = s.Delete(ctx, current)
+	return err
+}
+
+func (c *Client) DeleteAllOf(ctx context.Context, obj kclient.Object, opts ...kclient.DeleteAllOfOption) error {
+	s, err := c.strategyFor(obj)
+	if err != nil {
+		return err
+	}
+	deleteOpts := (&kclient.DeleteAllOfOptions{}).ApplyOptions(opts)
+	if err := dryRun(deleteOpts.DryRun); err != nil {
+		return err
+	}
+	list, err := s.List(ctx, deleteOpts.Namespace, toStorageListOptions(&deleteOpts.ListOptions))
+	if err != nil {
+		return err
+	}
+	return meta.EachListItem(list, func(item runtime.Object) error {
+		obj := item.(types.Object)
+		err := c.delete(ctx, s, obj.GetNamespace(), obj.GetName(), deleteOpts.Preconditions)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
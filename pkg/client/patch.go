@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (c *Client) Patch(ctx context.Context, obj kclient.Object, patch kclient.Patch, opts ...kclient.PatchOption) error {
+	if err := dryRun((&kclient.PatchOptions{}).ApplyOptions(opts).DryRun); err != nil {
+		return err
+	}
+	return c.patch(ctx, obj, patch, false)
+}
+
+// patch applies patch to the stored version of obj. A patch that doesn't set the resource version is retried on
+// conflict, so it applies to the latest version like it does in the apiserver.
+func (c *Client) patch(ctx context.Context, obj kclient.Object, patch kclient.Patch, status bool) error {
+	s, err := c.strategyFor(obj)
+	if err != nil {
+		return err
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+
+	for {
+		current, err := s.Get(ctx, obj.GetNamespace(), obj.GetName())
+		if err != nil {
+			return err
+		}
+		patched, err := applyPatch(s, patch.Type(), current, data)
+		if err != nil {
+			return err
+		}
+
+		var result types.Object
+		if status {
+			result, err = s.UpdateStatus(ctx, patched)
+		} else {
+			result, err = s.Update(ctx, patched)
+		}
+		if apierrors.IsConflict(err) && patched.GetResourceVersion() == current.GetResourceVersion() {
+			continue
+		} else if err != nil {
+			return err
+		}
+		return setObject(obj, result)
+	}
+}
+
+func applyPatch(s strategy.CompleteStrategy, patchType ktypes.PatchType, current types.Object, data []byte) (types.Object, error) {
+	original, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	switch patchType {
+	case ktypes.JSONPatchType:
+		ops, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		patched, err = ops.Apply(original)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	case ktypes.MergePatchType:
+		patched, err = jsonpatch.MergePatch(original, data)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	case ktypes.StrategicMergePatchType:
+		patched, err = strategicpatch.StrategicMergePatch(original, data, s.New())
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("patch type %s is not supported", patchType))
+	}
+
+	result := s.New()
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (c *Client) Status() kclient.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *Client) SubResource(subResource string) kclient.SubResourceClient {
+	return &subResourceClient{
+		client:      c,
+		subResource: subResource,
+	}
+}
+
+// subResourceClient supports the status subresource, which is the only subresource kinm strategies store.
+type subResourceClient struct {
+	client      *Client
+	subResource string
+}
+
+func (s *subResourceClient) supported() error {
+	if s.subResource != "status" {
+		return apierrors.NewBadRequest(fmt.Sprintf("subresource %s is not supported", s.subResource))
+	}
+	return nil
+}
+
+func (s *subResourceClient) Get(ctx context.Context, obj kclient.Object, subResource kclient.Object, _ ...kclient.SubResourceGetOption) error {
+	if err := s.supported(); err != nil {
+		return err
+	}
+	return s.client.Get(ctx, kclient.ObjectKeyFromObject(obj), subResource)
+}
+
+func (s *subResourceClient) Create(context.Context, kclient.Object, kclient.Object, ...kclient.SubResourceCreateOption) error {
+	return apierrors.NewBadRequest(fmt.Sprintf("creating subresource %s is not supported", s.subResource))
+}
+
+func (s *subResourceClient) Update(ctx context.Context, obj kclient.Object, opts ...kclient.SubResourceUpdateOption) error {
+	if err := s.supported(); err != nil {
+		return err
+	}
+	updateOpts := (&kclient.SubResourceUpdateOptions{}).ApplyOptions(opts)
+	if err := dryRun(updateOpts.DryRun); err != nil {
+		return err
+	}
+	body := obj
+	if updateOpts.SubResourceBody != nil {
+		body = updateOpts.SubResourceBody
+	}
+
+	strategy, err := s.client.strategyFor(obj)
+	if err != nil {
+		return err
+	}
+	result, err := strategy.UpdateStatus(ctx, body.DeepCopyObject().(kclient.Object))
+	if err != nil {
+		return err
+	}
+	return setObject(obj, result)
+}
+
+func (s *subResourceClient) Patch(ctx context.Context, obj kclient.Object, patch kclient.Patch, opts ...kclient.SubResourcePatchOption) error {
+	if err := s.supported(); err != nil {
+		return err
+	}
+	if err := dryRun((&kclient.SubResourcePatchOptions{}).ApplyOptions(opts).DryRun); err != nil {
+		return err
+	}
+	return s.client.patch(ctx, obj, patch, true)
+}
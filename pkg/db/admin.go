@@ -0,0 +1,371 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/db/statements"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+)
+
+// Admin performs operational tasks on the tables of a database by name, without needing the Go types stored in them.
+// Tables are opened with the statement and value options of the factory, so values transformed with a per-kind
+// WithTransformer can't be read. Admin sees the rows of all partitions, bypassing WithRowLevelSecurity.
+type Admin struct {
+	f *Factory
+}
+
+// Admin returns an Admin for the database of the factory.
+func (f *Factory) Admin() *Admin {
+	return &Admin{f: f}
+}
+
+// TableInfo describes a table. Names don't include the table prefix.
+type TableInfo struct {
+	Name          string `json:"name"`
+	SchemaVersion int    `json:"schemaVersion"`
+	// Records is the number of rows, including retained history.
+	Records int64 `json:"records"`
+	// Objects is the number of objects that currently exist.
+	Objects int64 `json:"objects"`
+	// Revision is the latest resourceVersion written to the table.
+	Revision int64 `json:"revision"`
+	// CompactionID is the resourceVersion up to which history has been compacted.
+	CompactionID int64 `json:"compactionID"`
+}
+
+// RawRevision is a revision of an object with its value as stored JSON.
+type RawRevision struct {
+	ResourceVersion string          `json:"resourceVersion"`
+	Namespace       string          `json:"namespace,omitempty"`
+	Name            string          `json:"name"`
+	UID             string          `json:"uid"`
+	Created         bool            `json:"created,omitempty"`
+	Deleted         bool            `json:"deleted,omitempty"`
+	Time            metav1.Time     `json:"time,omitempty"`
+	Value           json.RawMessage `json:"value"`
+}
+
+func toRawRevision(rec record) RawRevision {
+	revision := RawRevision{
+		ResourceVersion: strconv.FormatInt(rec.id, 10),
+		Namespace:       rec.namespace,
+		Name:            rec.name,
+		UID:             rec.uid,
+		Created:         rec.created == 1 || rec.previousID == nil,
+		Deleted:         rec.deleted == 1,
+		Value:           json.RawMessage(rec.value),
+	}
+	if rec.modified != 0 {
+		revision.Time = metav1.NewTime(time.UnixMilli(rec.modified))
+	}
+	return revision
+}
+
+// open returns the table with the given name without accessing the database.
+func (a *Admin) open(name string) *db {
+	s := &Strategy{
+		db: db{
+			sqlDB: a.f.SQLDB,
+			gvk:   schema.GroupVersionKind{Kind: name},
+		},
+	}
+	for _, opt := range append([]Option{WithQueryLogger(a.f.logger, a.f.slowQueryThreshold)}, a.f.strategyOptions...) {
+		opt(s)
+	}
+	s.db.bypassRowLevelSecurity = true
+	s.db.stmt = statements.New(name, a.f.dialect, s.statementOptions...)
+	return &s.db
+}
+
+// table returns the table with the given name. If create is false, the table must already exist.
+func (a *Admin) table(ctx context.Context, name string, create bool) (*db, error) {
+	d := a.open(name)
+	if err := d.stmt.Err(); err != nil {
+		return nil, err
+	}
+	if create {
+		return d, d.migrate(ctx)
+	}
+
+	if _, err := d.execContext(ctx, d.stmt.SchemaVersionSQL()); err != nil {
+		return nil, err
+	}
+	var version int
+	if err := d.queryRowContext(ctx, d.stmt.GetSchemaVersionSQL()).Scan(&version); err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "tables"}, name)
+	}
+	return d, nil
+}
+
+// Tables returns the tables in the database that were created by kinm with the same table prefix.
+func (a *Admin) Tables(ctx context.Context) ([]TableInfo, error) {
+	// The statements of any table can list the tables that share its prefix
+	d := a.open("")
+	if _, err := d.execContext(ctx, d.stmt.SchemaVersionSQL()); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.queryContext(ctx, d.stmt.ListTablesSQL())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var (
+			name    string
+			version int
+		)
+		if err := rows.Scan(&name, &version); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if name, ok := strings.CutPrefix(name, d.stmt.Prefix()); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]TableInfo, 0, len(names))
+	for _, name := range names {
+		info, err := a.Table(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// Table returns the statistics of the named table.
+func (a *Admin) Table(ctx context.Context, name string) (TableInfo, error) {
+	d, err := a.table(ctx, name, false)
+	if err != nil {
+		return TableInfo{}, err
+	}
+
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return TableInfo{}, err
+	}
+	defer tx.Rollback()
+
+	info := TableInfo{Name: name}
+	if err := d.queryRowContext(ctx, d.stmt.GetSchemaVersionSQL()).Scan(&info.SchemaVersion); err != nil {
+		return TableInfo{}, err
+	}
+	if err := d.queryRowContext(ctx, d.stmt.TableStatsSQL()).Scan(&info.Records, &info.Objects); err != nil {
+		return TableInfo{}, err
+	}
+	meta, err := d.getTableMeta(ctx)
+	if err != nil {
+		return TableInfo{}, err
+	}
+	info.Revision, info.CompactionID = meta.ListID, meta.CompactionID
+	return info, nil
+}
+
+// History returns the retained revisions of the named object in table, newest first. See Strategy.History.
+func (a *Admin) History(ctx context.Context, table, namespace, name string, opts HistoryOptions) ([]RawRevision, error) {
+	d, err := a.table(ctx, table, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var before int64
+	if opts.Before != "" {
+		if before, err = parseResourceVersion(opts.Before); err != nil {
+			return nil, err
+		}
+	}
+
+	records, err := d.history(ctx, namespace, name, before, opts.Limit, opts.IncludeArchived && d.archive)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]RawRevision, 0, len(records))
+	for _, rec := range records {
+		result = append(result, toRawRevision(rec))
+	}
+	return result, nil
+}
+
+// GetRevision returns the revision of table with the given resourceVersion.
+func (a *Admin) GetRevision(ctx context.Context, table, resourceVersion string) (RawRevision, error) {
+	d, err := a.table(ctx, table, false)
+	if err != nil {
+		return RawRevision{}, err
+	}
+	rec, err := a.getRevision(ctx, d, resourceVersion)
+	if err != nil {
+		return RawRevision{}, err
+	}
+	return toRawRevision(*rec), nil
+}
+
+func (a *Admin) getRevision(ctx context.Context, d *db, resourceVersion string) (*record, error) {
+	id, err := parseResourceVersion(resourceVersion)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := d.getByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: d.stmt.TableName()}, resourceVersion)
+	}
+	return rec, nil
+}
+
+// Rollback writes the value of the object at resourceVersion as a new revision of the object, as if it had been
+// updated back to that value. The object must still exist and have the same UID as the revision. Its generation is
+// incremented so that controllers see the change. The new revision is returned.
+func (a *Admin) Rollback(ctx context.Context, table, resourceVersion string) (RawRevision, error) {
+	d, err := a.table(ctx, table, false)
+	if err != nil {
+		return RawRevision{}, err
+	}
+	target, err := a.getRevision(ctx, d, resourceVersion)
+	if err != nil {
+		return RawRevision{}, err
+	}
+	if target.deleted == 1 {
+		return RawRevision{}, apierrors.NewBadRequest(fmt.Sprintf("revision %s deleted %s, roll back to an earlier revision", resourceVersion, target.name))
+	}
+
+	current, err := d.get(ctx, target.namespace, target.name)
+	if err != nil {
+		return RawRevision{}, err
+	}
+	if current.uid != target.uid {
+		return RawRevision{}, apierrors.NewConflict(schema.GroupResource{Resource: d.stmt.TableName()}, target.name,
+			fmt.Errorf("revision %s belongs to an earlier object with UID %s", resourceVersion, target.uid))
+	}
+
+	var currentObj, targetObj unstructured.Unstructured
+	if err := utiljson.Unmarshal([]byte(current.value), &currentObj.Object); err != nil {
+		return RawRevision{}, err
+	}
+	if err := utiljson.Unmarshal([]byte(target.value), &targetObj.Object); err != nil {
+		return RawRevision{}, err
+	}
+	targetObj.SetGeneration(currentObj.GetGeneration() + 1)
+	targetObj.SetResourceVersion("0")
+
+	value, err := json.Marshal(targetObj.Object)
+	if err != nil {
+		return RawRevision{}, err
+	}
+
+	rec := record{
+		name:       current.name,
+		namespace:  current.namespace,
+		previousID: &current.id,
+		uid:        current.uid,
+		value:      string(value),
+	}
+	rec.id, err = d.insert(ctx, rec)
+	if err != nil {
+		return RawRevision{}, err
+	}
+	rec.modified = time.Now().UnixMilli()
+	return toRawRevision(rec), nil
+}
+
+// Compact deletes the history of table that is older than the compaction point set by the previous compaction and
+// moves the compaction point to the latest revision, exactly like the periodic compaction of a strategy. It returns
+// the number of deleted records.
+func (a *Admin) Compact(ctx context.Context, table string) (int64, error) {
+	d, err := a.table(ctx, table, false)
+	if err != nil {
+		return 0, err
+	}
+	return d.compact(ctx)
+}
+
+// Dump writes the named tables, or all tables if none are named, to w in the format of Factory.Snapshot. The kinds
+// of the tables are not recorded.
+func (a *Admin) Dump(ctx context.Context, w io.Writer, tables ...string) error {
+	if len(tables) == 0 {
+		infos, err := a.Tables(ctx)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			tables = append(tables, info.Name)
+		}
+	}
+
+	var sources []snapshotSource
+	for _, table := range tables {
+		d, err := a.table(ctx, table, false)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, snapshotSource{db: d})
+	}
+	return writeSnapshot(ctx, w, sources)
+}
+
+// Restore loads a snapshot written by Dump or Factory.Snapshot, creating its tables if they don't exist. The tables
+// must be empty.
+func (a *Admin) Restore(ctx context.Context, r io.Reader) error {
+	// Tables must be created before the restore transaction starts, since sqlite only has a single connection
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	tables := map[string]*db{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var line snapshotLine
+		if err := dec.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("invalid snapshot: %w", err)
+		}
+		if line.Table != nil && tables[line.Table.Name] == nil {
+			if tables[line.Table.Name], err = a.table(ctx, line.Table.Name, true); err != nil {
+				return err
+			}
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	var txDB *db
+	for _, d := range tables {
+		txDB = d
+		break
+	}
+	_, err = readSnapshot(ctx, bytes.NewReader(data), txDB, nil, func(_ context.Context, table *snapshotTable) (*db, error) {
+		return tables[table.Name], nil
+	})
+	return err
+}
+
+func parseResourceVersion(resourceVersion string) (int64, error) {
+	id, err := strconv.ParseInt(resourceVersion, 10, 64)
+	if err != nil {
+		return 0, apierrors.NewBadRequest(fmt.Sprintf("invalid resource version %q: %v", resourceVersion, err))
+	}
+	return id, nil
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+)
+
+// AggregateOptions configures Strategy.Aggregate.
+type AggregateOptions struct {
+	// GroupBy are the dot separated paths of the fields in the JSON of the objects to group the objects by, such as
+	// "metadata.namespace" and "status.phase". Without fields the objects are counted as one group.
+	GroupBy []string
+}
+
+// AggregateGroup is the count of the objects whose fields have the same values.
+type AggregateGroup struct {
+	// Values are the values of the fields of AggregateOptions.GroupBy, in the same order. Strings are returned as is,
+	// other values as JSON, and missing or null fields as empty strings.
+	Values []string
+	Count  int64
+}
+
+// Aggregate counts the objects in namespace, or in all namespaces if namespace is empty, grouped by the values of
+// fields. The groups are sorted by their values. Only the latest revision of each object is counted; deleted objects
+// are not. If ctx has a partition ID, only the objects of that partition are counted.
+//
+// The objects are grouped by the database, which extracts the fields from the JSON of the objects, so the objects
+// aren't read. Values that are compressed, transformed or stored in blobs can't be read by the database and are
+// decoded and counted by the strategy instead.
+func (s *Strategy) Aggregate(ctx context.Context, namespace string, opts AggregateOptions) ([]AggregateGroup, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+	return s.db.aggregate(ctx, getNamespace(namespace), opts.GroupBy)
+}
+
+func (d *db) aggregate(ctx context.Context, namespace *string, groupBy []string) ([]AggregateGroup, error) {
+	ctx, tx, err := d.beginTx(ctx, &sql.TxOptions{
+		// Repeatable read is needed so that both queries count the same objects
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	counts := map[string]*AggregateGroup{}
+	add := func(values []string, count int64) {
+		// The values can't contain a NUL, so joining them gives a unique key
+		key := strings.Join(values, "\x00")
+		if group, ok := counts[key]; ok {
+			group.Count += count
+		} else {
+			counts[key] = &AggregateGroup{Values: values, Count: count}
+		}
+	}
+
+	args := []any{namespace, getPartitionID(ctx)}
+	for _, path := range groupBy {
+		args = append(args, d.stmt.AggregatePath(path))
+	}
+	rows, err := d.queryContext(ctx, d.stmt.AggregateSQL(len(groupBy)), args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			values = make([]string, len(groupBy))
+			count  int64
+			dest   []any
+		)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(append(dest, &count)...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if count > 0 {
+			add(values, count)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids, err := d.queryIDs(ctx, d.stmt.AggregateEncodedSQL(), namespace, getPartitionID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		rec, err := d.getByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(rec.value), &obj); err != nil {
+			return nil, err
+		}
+		values := make([]string, len(groupBy))
+		for i, path := range groupBy {
+			values[i] = indexValue(obj, IndexSpec{Field: path})
+		}
+		add(values, 1)
+	}
+
+	result := make([]AggregateGroup, 0, len(counts))
+	for _, group := range counts {
+		result = append(result, *group)
+	}
+	slices.SortFunc(result, func(a, b AggregateGroup) int {
+		return slices.Compare(a.Values, b.Values)
+	})
+	return result, tx.Commit()
+}
+
+// queryIDs returns the ids returned by query.
+func (d *db) queryIDs(ctx context.Context, query string, args ...any) ([]int64, error) {
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := d.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AggregateCollector returns a Prometheus collector that serves the counts of Aggregate over all namespaces as a gauge
+// with the given name and help, labeled by the fields of opts, so that the objects can be graphed and alerted on like
+// metrics. The objects are counted when the metrics are collected. The labels are the paths of the fields with the
+// characters that are not valid in label names replaced by underscores, such as "status_phase".
+func (s *Strategy) AggregateCollector(name, help string, opts AggregateOptions) prometheus.Collector {
+	labels := make([]string, len(opts.GroupBy))
+	for i, path := range opts.GroupBy {
+		labels[i] = labelName(path)
+	}
+	return &aggregateCollector{
+		s:    s,
+		opts: opts,
+		desc: prometheus.NewDesc(name, help, labels, prometheus.Labels{"table": s.db.stmt.TableName()}),
+	}
+}
+
+type aggregateCollector struct {
+	s    *Strategy
+	opts AggregateOptions
+	desc *prometheus.Desc
+}
+
+func (c *aggregateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *aggregateCollector) Collect(ch chan<- prometheus.Metric) {
+	groups, err := c.s.Aggregate(c.s.ctx, "", c.opts)
+	if err != nil {
+		klog.Errorf("failed to count %s objects for metrics: %v", c.s.db.gvk.Kind, err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for _, group := range groups {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(group.Count), group.Values...)
+	}
+}
+
+// labelName replaces the characters of path that are not valid in Prometheus label names by underscores.
+func labelName(path string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, path)
+}
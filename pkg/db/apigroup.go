@@ -0,0 +1,84 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/obot-platform/kinm/pkg/apigroup"
+	"github.com/obot-platform/kinm/pkg/stores"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/rest"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Stores creates a strategy for each object and returns the storage for them, keyed by the plural lowercase resource
+// name that the apiserver serves them under. Objects with a Status field also get a status subresource, and all
+// objects get an undelete subresource if the factory was created with WithUndeleteSubresource.
+func (f *Factory) Stores(objs ...types.Object) (map[string]rest.Storage, error) {
+	result := make(map[string]rest.Storage, len(objs))
+	for _, obj := range objs {
+		gvk, err := apiutil.GVKForObject(obj, f.schema)
+		if err != nil {
+			return nil, err
+		}
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		resource := plural.Resource
+		if _, ok := result[resource]; ok {
+			return nil, fmt.Errorf("resource %s is defined more than once", resource)
+		}
+
+		s, err := f.NewDBStrategy(obj)
+		if err != nil {
+			return nil, err
+		}
+		result[resource] = stores.NewComplete(f.schema, s)
+		if strategy.HasStatus(obj) {
+			result[resource+"/status"] = stores.NewStatus(f.schema, s)
+		}
+		if undeleter, ok := s.(strategy.Undeleter); ok && f.undelete {
+			result[resource+"/undelete"] = stores.NewUndelete(undeleter)
+		}
+	}
+	return result, nil
+}
+
+// APIGroup creates the storage for objs, which must all belong to groupVersion, and returns the APIGroupInfo to
+// install into a generic apiserver.
+func (f *Factory) APIGroup(addToScheme apigroup.AddToScheme, groupVersion schema.GroupVersion, objs ...types.Object) (*genericapiserver.APIGroupInfo, error) {
+	storage, err := f.groupStores(groupVersion, objs)
+	if err != nil {
+		return nil, err
+	}
+	return apigroup.ForStores(addToScheme, storage, groupVersion)
+}
+
+// VersionedAPIGroup is APIGroup for a group served in several versions. objs are the objects of storageVersion, which
+// are the objects stored in the database, and are served in every version of versions as well. addToScheme must
+// register the objects of those versions and the conversion functions between them and the objects of storageVersion.
+//
+// The database holds the version each object was written in, so that the objects can be migrated when the storage
+// version changes.
+func (f *Factory) VersionedAPIGroup(addToScheme apigroup.AddToScheme, storageVersion schema.GroupVersion, versions []string, objs ...types.Object) (*genericapiserver.APIGroupInfo, error) {
+	storage, err := f.groupStores(storageVersion, objs)
+	if err != nil {
+		return nil, err
+	}
+	return apigroup.ForVersionedStores(addToScheme, storage, storageVersion, versions...)
+}
+
+// groupStores returns the storage for objs, which must all belong to groupVersion.
+func (f *Factory) groupStores(groupVersion schema.GroupVersion, objs []types.Object) (map[string]rest.Storage, error) {
+	for _, obj := range objs {
+		gvk, err := apiutil.GVKForObject(obj, f.schema)
+		if err != nil {
+			return nil, err
+		}
+		if gvk.GroupVersion() != groupVersion {
+			return nil, fmt.Errorf("%s does not belong to %s", gvk, groupVersion)
+		}
+	}
+	return f.Stores(objs...)
+}
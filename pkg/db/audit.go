@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/obot-platform/kinm/pkg/db/statements"
+	"github.com/obot-platform/kinm/pkg/strategy/audit"
+)
+
+var _ audit.Sink = (*AuditSink)(nil)
+
+// AuditSink records audit events in a database table.
+type AuditSink struct {
+	d *db
+}
+
+// NewAuditSink returns a sink that records audit events in tableName, creating the table if needed. Use
+// Factory.NewAuditSink to name the table with the table prefix of a factory.
+func NewAuditSink(ctx context.Context, sqlDB *sql.DB, tableName string, opts ...statements.Option) (*AuditSink, error) {
+	return newAuditSink(ctx, &db{
+		sqlDB: sqlDB,
+		stmt:  statements.New(tableName, driverDialect(sqlDB), opts...),
+	})
+}
+
+// NewAuditSink returns a sink that records audit events in tableName, creating the table if needed. The table is named
+// and its statements are overridden like the tables of the factory.
+func (f *Factory) NewAuditSink(ctx context.Context, tableName string) (*AuditSink, error) {
+	return newAuditSink(ctx, f.Admin().open(tableName))
+}
+
+func newAuditSink(ctx context.Context, d *db) (*AuditSink, error) {
+	if err := d.stmt.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := d.execContext(ctx, d.stmt.AuditCreateSQL()); err != nil {
+		return nil, fmt.Errorf("failed to create audit table %s: %w", d.stmt.TableName(), err)
+	}
+	return &AuditSink{
+		d: d,
+	}, nil
+}
+
+func (a *AuditSink) Record(ctx context.Context, event audit.Event) error {
+	_, err := a.d.execContext(ctx, a.d.stmt.AuditInsertSQL(),
+		event.Time.UnixMicro(),
+		event.User,
+		string(event.Verb),
+		event.GroupVersionKind.Group,
+		event.GroupVersionKind.Version,
+		event.GroupVersionKind.Kind,
+		event.Namespace,
+		event.Name,
+		event.OldResourceVersion,
+		event.NewResourceVersion,
+		string(event.Outcome),
+		event.Error)
+	return err
+}
@@ -0,0 +1,234 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"k8s.io/klog/v2"
+)
+
+const (
+	backupPrefix = "kinm-"
+	backupSuffix = ".ndjson.zst"
+	// backupTimeFormat sorts lexically in time order, so the names of backups sort in the order they were taken.
+	backupTimeFormat = "20060102T150405.000Z"
+)
+
+// BackupStore stores the archives written by Factory.Backup, typically in an object store such as S3 or GCS. Names
+// are flat, without directories.
+type BackupStore interface {
+	Put(ctx context.Context, name string, r io.Reader) error
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// BackupOptions configures the backups taken by WithBackups.
+type BackupOptions struct {
+	// Interval is the time between backups. The first backup is taken one interval after the factory is created.
+	Interval time.Duration
+	// Retain is the number of most recent backups kept. All backups are kept if zero.
+	Retain int
+	// MaxAge deletes backups older than this, always keeping the most recent one. Backups don't expire if zero.
+	MaxAge time.Duration
+}
+
+// WithBackups backs up every table of the database to store on a schedule, see Factory.Backup. Failed backups are
+// logged and retried at the next interval. The backups stop when the factory is closed.
+func WithBackups(store BackupStore, opts BackupOptions) FactoryOption {
+	return func(f *Factory) {
+		f.backups = store
+		f.backupOptions = opts
+	}
+}
+
+// BackupInfo describes a backup taken by Factory.Backup.
+type BackupInfo struct {
+	Name string `json:"name"`
+	// Size is the size of the compressed archive in bytes.
+	Size int64 `json:"size"`
+	// Deleted lists the older backups deleted by the retention rules.
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// Backup takes a consistent snapshot of every table of the database, as Admin.Dump does, compresses it with zstd and
+// uploads it to the store configured with WithBackups. Backups older than the retention rules allow are then deleted.
+// The snapshot is written to a temporary file before it is uploaded, so a slow upload doesn't hold the snapshot
+// transaction open, which would block writers on sqlite.
+func (f *Factory) Backup(ctx context.Context) (*BackupInfo, error) {
+	if f.backups == nil {
+		return nil, fmt.Errorf("no backup store configured")
+	}
+
+	tmp, err := os.CreateTemp("", "kinm-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	enc, err := zstd.NewWriter(tmp)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Admin().Dump(ctx, enc); err != nil {
+		_ = enc.Close()
+		return nil, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	info := &BackupInfo{
+		Name: backupPrefix + time.Now().UTC().Format(backupTimeFormat) + backupSuffix,
+		Size: size,
+	}
+	if err := f.backups.Put(ctx, info.Name, tmp); err != nil {
+		return nil, fmt.Errorf("failed to upload backup %s: %w", info.Name, err)
+	}
+
+	info.Deleted, err = f.pruneBackups(ctx, time.Now())
+	return info, err
+}
+
+// pruneBackups deletes the backups that the retention rules don't keep and returns their names.
+func (f *Factory) pruneBackups(ctx context.Context, now time.Time) ([]string, error) {
+	names, err := f.backups.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, name := range names {
+		if strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	// Newest first
+	slices.Sort(backups)
+	slices.Reverse(backups)
+
+	var deleted []string
+	for i, name := range backups {
+		if i == 0 {
+			continue
+		}
+		expired := f.backupOptions.Retain > 0 && i >= f.backupOptions.Retain
+		if !expired && f.backupOptions.MaxAge > 0 {
+			taken, err := time.Parse(backupTimeFormat,
+				strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix))
+			expired = err == nil && now.Sub(taken) > f.backupOptions.MaxAge
+		}
+		if !expired {
+			continue
+		}
+		if err := f.backups.Delete(ctx, name); err != nil {
+			return deleted, fmt.Errorf("failed to delete backup %s: %w", name, err)
+		}
+		deleted = append(deleted, name)
+	}
+	return deleted, nil
+}
+
+// runBackups takes a backup every interval until ctx is done.
+func (f *Factory) runBackups(ctx context.Context) {
+	ticker := time.NewTicker(f.backupOptions.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := f.Backup(ctx)
+			if err != nil {
+				klog.Errorf("failed to back up database: %v", err)
+				continue
+			}
+			klog.Infof("backed up database to %s: %d bytes", info.Name, info.Size)
+		}
+	}
+}
+
+// NewBackupReader returns a reader of the snapshot in a backup taken by Factory.Backup, for Admin.Restore or
+// Factory.Restore.
+func NewBackupReader(r io.Reader) (io.ReadCloser, error) {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return dec.IOReadCloser(), nil
+}
+
+var _ ReplicaTarget = (*FileBackupStore)(nil)
+
+// FileBackupStore is a BackupStore and ReplicaTarget that writes each file to a directory, for example a mounted
+// network file system.
+type FileBackupStore struct {
+	dir string
+}
+
+func NewFileBackupStore(dir string) (*FileBackupStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileBackupStore{
+		dir: dir,
+	}, nil
+}
+
+func (f *FileBackupStore) Put(_ context.Context, name string, r io.Reader) error {
+	tmp, err := os.CreateTemp(f.dir, ".backup-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(f.dir, name))
+}
+
+func (f *FileBackupStore) Get(_ context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(f.dir, name))
+}
+
+func (f *FileBackupStore) List(_ context.Context) ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (f *FileBackupStore) Delete(_ context.Context, name string) error {
+	err := os.Remove(filepath.Join(f.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// benchValue is the value of benchmark objects, about 1KiB like a typical small object.
+var benchValue = strings.Repeat("x", 1024)
+
+// newBenchStrategy returns a strategy with count additional objects spread over ten label values.
+func newBenchStrategy(b *testing.B, count int) *Strategy {
+	b.Helper()
+	s := newStrategy(b)
+	for i := range count {
+		if _, err := s.Create(ctx, benchObject(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return s
+}
+
+func benchObject(i int) *TestKind {
+	return &TestKind{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bench" + strconv.Itoa(i),
+			Namespace: "bench",
+			UID:       types.UID("benchuid" + strconv.Itoa(i)),
+			Labels:    map[string]string{"group": strconv.Itoa(i % 10)},
+		},
+		Value: benchValue,
+	}
+}
+
+func BenchmarkCreate(b *testing.B) {
+	s := newBenchStrategy(b, 0)
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := s.Create(ctx, benchObject(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	s := newBenchStrategy(b, 1)
+	obj, err := s.Get(ctx, "bench", "bench0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		if obj, err = s.Update(ctx, obj); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	s := newBenchStrategy(b, 100)
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := s.Get(ctx, "bench", "bench"+strconv.Itoa(i%100)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkList(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts storage.ListOptions
+	}{
+		{"All", storage.ListOptions{}},
+		{"Label", storage.ListOptions{Predicate: storage.SelectionPredicate{
+			Label: labels.SelectorFromSet(labels.Set{"group": "1"}),
+		}}},
+		{"Page", storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 10}}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			s := newBenchStrategy(b, 1000)
+			b.ResetTimer()
+			for range b.N {
+				if _, err := s.List(ctx, "bench", bench.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkWatch measures the time from an update to its event reaching a watcher.
+func BenchmarkWatch(b *testing.B) {
+	s := newBenchStrategy(b, 1)
+	obj, err := s.Get(ctx, "bench", "bench0")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w, err := s.Watch(ctx, "bench", storage.ListOptions{ResourceVersion: obj.GetResourceVersion()})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		if obj, err = s.Update(ctx, obj); err != nil {
+			b.Fatal(err)
+		}
+		for event := range w {
+			if event.Type == watch.Modified && event.Object.(*TestKind).ResourceVersion == obj.GetResourceVersion() {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// BlobStore stores values that are too large to keep in the database row. Keys are derived from the content, so
+// implementations never see the same key with different data. Since many rows can point to the same blob, kinm never
+// deletes blobs; cleaning them up is left to the store.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// WithBlobStore offloads values larger than threshold bytes to store, keeping only a pointer and checksum in the row.
+func WithBlobStore(store BlobStore, threshold int) Option {
+	return func(s *Strategy) {
+		s.db.blobs = store
+		s.db.blobThreshold = threshold
+	}
+}
+
+var _ BlobStore = (*FileBlobStore)(nil)
+
+// FileBlobStore is a BlobStore that writes each blob to a file in a directory.
+type FileBlobStore struct {
+	dir string
+}
+
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileBlobStore{
+		dir: dir,
+	}, nil
+}
+
+func (f *FileBlobStore) path(key string) string {
+	// Spread the files over sub directories so that a single directory doesn't get too large
+	if len(key) > 2 {
+		return filepath.Join(f.dir, key[:2], key)
+	}
+	return filepath.Join(f.dir, key)
+}
+
+func (f *FileBlobStore) Put(_ context.Context, key string, data []byte) error {
+	path := f.path(key)
+	if _, err := os.Stat(path); err == nil {
+		// Content addressed, so the existing file already has this data
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *FileBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("blob %s not found: %w", key, err)
+	}
+	return data, err
+}
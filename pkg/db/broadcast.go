@@ -0,0 +1,29 @@
+package db
+
+import "sync"
+
+// broadcaster wakes up every goroutine waiting for a change. The zero value is ready to use.
+type broadcaster struct {
+	lock sync.Mutex
+	ch   chan struct{}
+}
+
+// notify closes the channel returned by previous calls to wait.
+func (b *broadcaster) notify() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.ch != nil {
+		close(b.ch)
+		b.ch = nil
+	}
+}
+
+// wait returns a channel that is closed on the next call to notify.
+func (b *broadcaster) wait() <-chan struct{} {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.ch == nil {
+		b.ch = make(chan struct{})
+	}
+	return b.ch
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"hash/crc32"
+	"strconv"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/db/errors"
+	"k8s.io/klog/v2"
+)
+
+// ProblemCorruptValue is a record whose stored value doesn't match its checksum or can't be decoded. It is reported
+// by Scrub and can't be repaired automatically; roll the object back to an earlier revision or restore it from a
+// backup.
+const ProblemCorruptValue ProblemKind = "CorruptValue"
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// WithChecksumVerification verifies the checksum of every value read from the table, so that a value corrupted in the
+// database fails with an error for which errors.IsCorruptValue is true instead of a decoding error. Checksums are
+// always written, values written before they were tracked are not verified.
+func WithChecksumVerification() Option {
+	return func(s *Strategy) {
+		s.db.verifyChecksums = true
+	}
+}
+
+// WithScrubInterval scrubs the table in the background every interval, logging every corrupt record found. See
+// Strategy.Scrub.
+func WithScrubInterval(interval time.Duration) Option {
+	return func(s *Strategy) {
+		s.scrubInterval = interval
+	}
+}
+
+// valueChecksum returns the CRC-32C of a value as written to the value column.
+func valueChecksum(value string) int64 {
+	return int64(crc32.Checksum([]byte(value), crc32c))
+}
+
+// readValue verifies the stored value of r against checksum if verification is enabled, and decodes it.
+func (d *db) readValue(ctx context.Context, r *record, checksum sql.NullInt64) (err error) {
+	if d.verifyChecksums && checksum.Valid && valueChecksum(r.value) != checksum.Int64 {
+		return errors.NewCorruptValue(d.gvk, r.namespace, r.name, r.id)
+	}
+	r.value, err = d.decodeValue(ctx, r.namespace, r.name, r.value)
+	return err
+}
+
+// Scrub reads every record of the table, including retained history, and returns those whose value doesn't match its
+// checksum, can't be decoded or isn't JSON as ProblemCorruptValue problems. Scrub runs whether or not
+// WithChecksumVerification is set. Records are read in batches, so writes are not blocked for the duration of the
+// scrub.
+func (s *Strategy) Scrub(ctx context.Context) ([]Problem, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+	return s.db.scrub(WithRowLevelSecurityBypass(ctx))
+}
+
+// Scrub checks the values of the named table, see Strategy.Scrub.
+func (a *Admin) Scrub(ctx context.Context, table string) ([]Problem, error) {
+	d, err := a.table(ctx, table, false)
+	if err != nil {
+		return nil, err
+	}
+	return d.scrub(ctx)
+}
+
+func (d *db) scrub(ctx context.Context) ([]Problem, error) {
+	var (
+		problems []Problem
+		after    int64
+	)
+	for {
+		batch, err := d.scrubBatch(ctx, after)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return problems, nil
+		}
+		for _, r := range batch {
+			after = r.id
+			if d.isCorrupt(ctx, r.record, r.checksum) {
+				problems = append(problems, Problem{
+					Kind:            ProblemCorruptValue,
+					Table:           d.baseTableName(),
+					ResourceVersion: strconv.FormatInt(r.id, 10),
+					Namespace:       r.namespace,
+					Name:            r.name,
+				})
+			}
+		}
+	}
+}
+
+type scrubRecord struct {
+	record
+	checksum sql.NullInt64
+}
+
+// scrubBatch reads the next batch of records after the given id. The rows are closed before values are decoded, since
+// decoding may need the connection.
+func (d *db) scrubBatch(ctx context.Context, after int64) ([]scrubRecord, error) {
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := d.queryContext(ctx, d.stmt.ScrubSQL(), after)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []scrubRecord
+	for rows.Next() {
+		var r scrubRecord
+		if err := rows.Scan(&r.id, &r.namespace, &r.name, &r.value, &r.checksum); err != nil {
+			return nil, err
+		}
+		batch = append(batch, r)
+	}
+	return batch, rows.Err()
+}
+
+func (d *db) isCorrupt(ctx context.Context, r record, checksum sql.NullInt64) bool {
+	if checksum.Valid && valueChecksum(r.value) != checksum.Int64 {
+		return true
+	}
+	value, err := d.decodeValue(ctx, r.namespace, r.name, r.value)
+	return err != nil || !json.Valid([]byte(value))
+}
+
+// scrubPeriodically scrubs the table every interval until ctx is done.
+func (s *Strategy) scrubPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			problems, err := s.db.scrub(ctx)
+			if err != nil {
+				klog.Errorf("failed to scrub %q: %v", s.db.stmt.TableName(), err)
+				continue
+			}
+			for _, problem := range problems {
+				klog.Errorf("corrupt record in %q: %s", s.db.stmt.TableName(), problem)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/klog/v2"
+)
+
+const compactionInterval = 15 * time.Minute
+
+// CompactionMetrics records the periodic compactions of tables. One CompactionMetrics may be shared by many
+// strategies, whose tables are told apart by the table label.
+type CompactionMetrics struct {
+	runs         *prometheus.CounterVec
+	rows         *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	compactionID *prometheus.GaugeVec
+	lastSuccess  *prometheus.GaugeVec
+}
+
+// NewCompactionMetrics creates the kinm_compaction_* collectors and registers them with registerer. An alert on
+// kinm_compaction_last_success_timestamp_seconds notices a table whose compaction stalls.
+func NewCompactionMetrics(registerer prometheus.Registerer) (*CompactionMetrics, error) {
+	m := &CompactionMetrics{
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kinm",
+			Subsystem: "compaction",
+			Name:      "runs_total",
+			Help:      "Number of compactions by table and result.",
+		}, []string{"table", "result"}),
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kinm",
+			Subsystem: "compaction",
+			Name:      "rows_removed_total",
+			Help:      "Number of rows removed by compactions by table.",
+		}, []string{"table"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "kinm",
+			Subsystem: "compaction",
+			Name:      "duration_seconds",
+			Help:      "Duration of compactions by table.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"table"}),
+		compactionID: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "kinm",
+			Subsystem: "compaction",
+			Name:      "id",
+			Help:      "Resource version up to which the history of a table is compacted by the next compaction.",
+		}, []string{"table"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "kinm",
+			Subsystem: "compaction",
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Time of the last successful compaction of a table in seconds since the epoch.",
+		}, []string{"table"}),
+	}
+	for _, c := range []prometheus.Collector{m.runs, m.rows, m.duration, m.compactionID, m.lastSuccess} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// WithCompactionMetrics records the periodic compactions of the table in metrics.
+func WithCompactionMetrics(metrics *CompactionMetrics) Option {
+	return func(s *Strategy) {
+		s.compactionMetrics = metrics
+	}
+}
+
+// WithCompactionEvents creates a core/v1 Event in namespace with events for every periodic compaction of the table that
+// fails or removes rows, such as with a strategy for Events created by the same factory. The events refer to the kind
+// of the table and have the name of the table.
+func WithCompactionEvents(events strategy.Creater, namespace string) Option {
+	return func(s *Strategy) {
+		s.compactionEvents = events
+		s.compactionEventsNamespace = namespace
+	}
+}
+
+// compactPeriodically compacts the table until ctx is done.
+func (s *Strategy) compactPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(compactionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runCompaction(ctx)
+		}
+	}
+}
+
+// runCompaction compacts the table and reports the result.
+func (s *Strategy) runCompaction(ctx context.Context) {
+	table := s.db.stmt.TableName()
+	start := time.Now()
+	count, err := s.db.compact(ctx)
+	duration := time.Since(start)
+
+	var meta tableMeta
+	if err == nil {
+		meta, err = s.db.getTableMeta(ctx)
+	}
+
+	if m := s.compactionMetrics; m != nil {
+		m.duration.WithLabelValues(table).Observe(duration.Seconds())
+		m.rows.WithLabelValues(table).Add(float64(count))
+		if err != nil {
+			m.runs.WithLabelValues(table, "error").Inc()
+		} else {
+			m.runs.WithLabelValues(table, "success").Inc()
+			m.compactionID.WithLabelValues(table).Set(float64(meta.CompactionID))
+			m.lastSuccess.WithLabelValues(table).Set(float64(time.Now().Unix()))
+		}
+	}
+
+	switch {
+	case err != nil:
+		klog.Errorf("failed to compact %q: %v", table, err)
+		s.compactionEvent(ctx, corev1.EventTypeWarning, "CompactionFailed", fmt.Sprintf("Failed to compact %s after removing %d rows: %v", table, count, err))
+	case count > 0:
+		klog.Infof("compacted %q: %d records", table, count)
+		s.compactionEvent(ctx, corev1.EventTypeNormal, "Compacted", fmt.Sprintf("Removed %d rows of %s in %v, the next compaction is up to resource version %d",
+			count, table, duration.Round(time.Millisecond), meta.CompactionID))
+	}
+}
+
+// compactionEvent creates an event about the compaction of the table, if configured with WithCompactionEvents.
+func (s *Strategy) compactionEvent(ctx context.Context, eventType, reason, message string) {
+	if s.compactionEvents == nil {
+		return
+	}
+
+	now := metav1.Now()
+	table := s.db.stmt.TableName()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      table + "." + strconv.FormatInt(now.UnixNano(), 16),
+			Namespace: s.compactionEventsNamespace,
+			UID:       uuid.NewUUID(),
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: s.db.gvk.GroupVersion().String(),
+			Kind:       s.db.gvk.Kind,
+			Name:       table,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: "kinm"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	// The strategy may store Events as another type, such as unstructured objects
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(event)
+	if err == nil {
+		obj := s.compactionEvents.New()
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(content, obj); err == nil {
+			_, err = s.compactionEvents.Create(ctx, obj)
+		}
+	}
+	if err != nil {
+		klog.Errorf("failed to create %s event for the compaction of %q: %v", reason, table, err)
+	}
+}
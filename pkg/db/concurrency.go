@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const defaultQueueTimeout = 10 * time.Second
+
+// ConcurrencyLimit limits the concurrent operations of a class of requests.
+type ConcurrencyLimit struct {
+	// Concurrency is the number of operations that run at the same time. Zero is unlimited.
+	Concurrency int
+	// QueueLength is the number of operations that wait for one of the running operations to finish. Operations
+	// arriving when the queue is full fail with a TooManyRequests error. Zero doesn't queue.
+	QueueLength int
+	// QueueTimeout is how long an operation waits in the queue before it fails with a TooManyRequests error. The
+	// default is 10 seconds.
+	QueueTimeout time.Duration
+}
+
+// ConcurrencyLimits are the limits of each class of requests, which are limited independently so that a burst of one
+// class can't starve the others.
+type ConcurrencyLimits struct {
+	// Mutating limits creates, updates, deletes and storage migrations.
+	Mutating ConcurrencyLimit
+	// ReadOnly limits gets, lists and the other reads of the objects.
+	ReadOnly ConcurrencyLimit
+	// Watch limits the queries of watches: the initial list of a watch and each poll for changes. An open watch
+	// doesn't hold a slot while it waits for changes. A watch whose poll fails to get a slot ends with an error event,
+	// and the client watches again from the last resourceVersion it received.
+	Watch ConcurrencyLimit
+}
+
+// ConcurrencyLimiter limits the concurrent requests of the strategies it is given to with WithConcurrencyLimiter.
+// Strategies using the same connection pool should share a limiter, such as by passing the option to
+// WithStrategyOptions.
+type ConcurrencyLimiter struct {
+	levels [requestClasses]*priorityLevel
+}
+
+// NewConcurrencyLimiter returns a limiter with the given limits.
+func NewConcurrencyLimiter(limits ConcurrencyLimits) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		levels: [requestClasses]*priorityLevel{
+			mutatingRequest: newPriorityLevel("mutating", limits.Mutating),
+			readOnlyRequest: newPriorityLevel("read-only", limits.ReadOnly),
+			watchRequest:    newPriorityLevel("watch", limits.Watch),
+		},
+	}
+}
+
+// WithConcurrencyLimiter limits the concurrent requests of the strategy with limiter.
+func WithConcurrencyLimiter(limiter *ConcurrencyLimiter) Option {
+	return func(s *Strategy) {
+		s.limits.concurrency = limiter
+	}
+}
+
+// requestClass is the class of a request, each class is limited by its own priority level.
+type requestClass int
+
+const (
+	mutatingRequest requestClass = iota
+	readOnlyRequest
+	watchRequest
+	requestClasses
+)
+
+// acquire waits for a slot of the priority level of class. Every successful call must be paired with a call to release.
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, class requestClass, kind string) error {
+	if l == nil {
+		return nil
+	}
+	return l.levels[class].acquire(ctx, kind)
+}
+
+func (l *ConcurrencyLimiter) release(class requestClass) {
+	if l == nil {
+		return
+	}
+	l.levels[class].release()
+}
+
+// priorityLevel is a semaphore with a bounded queue.
+type priorityLevel struct {
+	name    string
+	limit   ConcurrencyLimit
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+func newPriorityLevel(name string, limit ConcurrencyLimit) *priorityLevel {
+	if limit.QueueTimeout <= 0 {
+		limit.QueueTimeout = defaultQueueTimeout
+	}
+	p := &priorityLevel{
+		name:  name,
+		limit: limit,
+	}
+	if limit.Concurrency > 0 {
+		p.slots = make(chan struct{}, limit.Concurrency)
+	}
+	return p
+}
+
+func (p *priorityLevel) acquire(ctx context.Context, kind string) error {
+	if p.slots == nil {
+		return nil
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if p.limit.QueueLength <= 0 || !acquire(&p.waiting, int64(p.limit.QueueLength)) {
+		return apierrors.NewTooManyRequests(fmt.Sprintf("too many concurrent %s requests for %s", p.name, kind), 1)
+	}
+	defer p.waiting.Add(-1)
+
+	timer := time.NewTimer(p.limit.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return apierrors.NewTooManyRequests(fmt.Sprintf("timed out waiting for a slot for a %s request for %s", p.name, kind), 1)
+	case <-ctx.Done():
+		return apierrors.NewTimeoutError(fmt.Sprintf("canceled while waiting for a slot for a %s request for %s", p.name, kind), 1)
+	}
+}
+
+func (p *priorityLevel) release() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// CopyProgress reports the progress of copying a table.
+type CopyProgress struct {
+	Table string
+	// Copied is the number of records copied so far.
+	Copied int64
+	// Total is the number of records in the table when the copy started.
+	Total int64
+}
+
+// CopyOptions configures Admin.CopyTo.
+type CopyOptions struct {
+	// Tables are the names of the tables to copy. All tables are copied if it is empty.
+	Tables []string
+	// Progress, if set, is called after each copied record.
+	Progress func(CopyProgress)
+}
+
+// CopyTo copies tables from the database of a to the database of dst, which may use a different driver, for example
+// to move from sqlite to postgres. Records keep their IDs and the compaction point of each table is preserved, so
+// resourceVersions, history and watches from existing resourceVersions continue to work. The tables are read at a
+// consistent revision and written in a single transaction, and the destination tables must be empty. After copying,
+// the record and object counts and the revision of every destination table are verified against the source.
+func (a *Admin) CopyTo(ctx context.Context, dst *Admin, opts CopyOptions) error {
+	names := opts.Tables
+	if len(names) == 0 {
+		tables, err := a.Tables(ctx)
+		if err != nil {
+			return err
+		}
+		for _, table := range tables {
+			names = append(names, table.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	var sources []snapshotSource
+	for _, name := range names {
+		d, err := a.table(ctx, name, false)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, snapshotSource{db: d})
+	}
+
+	// Destination tables must be created before the copy transaction starts, since sqlite only has a single
+	// connection
+	targets := map[string]*db{}
+	for _, name := range names {
+		d, err := dst.table(ctx, name, true)
+		if err != nil {
+			return fmt.Errorf("failed to create %s in destination: %w", name, err)
+		}
+		targets[name] = d
+	}
+
+	var progress func(*snapshotTable, int64)
+	if opts.Progress != nil {
+		progress = func(table *snapshotTable, records int64) {
+			opts.Progress(CopyProgress{
+				Table:  table.Name,
+				Copied: records,
+				Total:  table.Records,
+			})
+		}
+	}
+
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		err := writeSnapshot(ctx, pw, sources)
+		_ = pw.CloseWithError(err)
+		written <- err
+	}()
+
+	tables, err := readSnapshot(ctx, pr, targets[names[0]], progress, func(_ context.Context, table *snapshotTable) (*db, error) {
+		return targets[table.Name], nil
+	})
+	// Unblock the writer if reading failed
+	_ = pr.CloseWithError(err)
+	if writeErr := <-written; writeErr != nil && err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		info, err := dst.Table(ctx, table.Name)
+		if err != nil {
+			return err
+		}
+		if info.Records != table.Records || info.Objects != table.Objects || info.Revision != table.Revision {
+			return fmt.Errorf("verification of %s failed: copied %d records, %d objects at revision %d but the source "+
+				"had %d records, %d objects at revision %d", table.Name, info.Records, info.Objects, info.Revision,
+				table.Records, table.Objects, table.Revision)
+		}
+	}
+	return nil
+}
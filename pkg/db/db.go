@@ -4,34 +4,134 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/obot-platform/kinm/pkg/db/errors"
 	"github.com/obot-platform/kinm/pkg/db/statements"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/storage/value"
 )
 
 type db struct {
 	sqlDB *sql.DB
 	stmt  *statements.Statements
 	gvk   schema.GroupVersionKind
+
+	compressThreshold int
+	blobs             BlobStore
+	blobThreshold     int
+	historyLimit      int64
+	transformer       value.Transformer
+	fieldTransformer  value.Transformer
+	encryptedFields   []string
+	partitionRequired bool
+	rowLevelSecurity  bool
+	tablePartitions   *tablePartitions
+	verifyChecksums   bool
+	orderByName       bool
+	uniqueRules       []UniqueRule
+	indexes           []IndexSpec
+	searchPaths       []string
+	quotas            quotas
+	maintenance       maintenance
+	archive           bool
+	replicas          *replicaPool
+	// written is the latest revision written through the factory, reads from replicas behind it go to the primary
+	written       *atomic.Int64
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	// bypassRowLevelSecurity lets every transaction see all partitions, for the tables of Admin
+	bypassRowLevelSecurity bool
 }
 
 func (d *db) Close() {
 	_ = d.sqlDB.Close()
 }
 
+// migrate applies the migrations of the table that haven't been applied yet, followed by the extension statements. The
+// applied version of each table is recorded in the schema_version table.
 func (d *db) migrate(ctx context.Context) error {
-	_, err := d.execContext(ctx, d.stmt.CreateSQL())
-	return err
+	if _, err := d.execContext(ctx, d.stmt.SchemaVersionSQL()); err != nil {
+		return err
+	}
+
+	ctx, tx, err := d.beginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Ensure concurrent processes don't run the same migrations at the same time
+	if _, err := d.execContext(ctx, d.stmt.SchemaVersionLockSQL()); err != nil {
+		return err
+	}
+
+	var version int
+	if err := d.queryRowContext(ctx, d.stmt.GetSchemaVersionSQL()).Scan(&version); err != nil {
+		return err
+	}
+
+	for _, migration := range d.stmt.Migrations() {
+		if migration.Version <= version {
+			continue
+		}
+
+		skip := false
+		if migration.SkipIfColumn != "" {
+			if skip, err = d.hasColumn(ctx, migration.SkipIfColumn); err != nil {
+				return err
+			}
+		}
+		if !skip {
+			if _, err := d.execContext(ctx, migration.SQL); err != nil {
+				return fmt.Errorf("failed to apply migration %d %s to %s: %w", migration.Version, migration.Name, d.gvk.Kind, err)
+			}
+		}
+		if _, err := d.execContext(ctx, d.stmt.SetSchemaVersionSQL(), migration.Version); err != nil {
+			return err
+		}
+	}
+
+	if err := d.createPartitions(ctx); err != nil {
+		return err
+	}
+
+	for i, extension := range d.stmt.Extensions() {
+		if _, err := d.execContext(ctx, extension); err != nil {
+			return fmt.Errorf("failed to apply statement extension %d to %s: %w", i+1, d.gvk.Kind, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (d *db) hasColumn(ctx context.Context, column string) (bool, error) {
+	var count int
+	err := d.queryRowContext(ctx, d.stmt.HasColumnSQL(), column).Scan(&count)
+	return count > 0, err
 }
 
 type txKey struct{}
 
-func (d *db) execContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+func (d *db) execContext(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
 	if query == "" {
 		return nil, nil
 	}
+	defer func(start time.Time) {
+		rows := int64(-1)
+		if result != nil {
+			if n, err := result.RowsAffected(); err == nil {
+				rows = n
+			}
+		}
+		d.logQuery(ctx, start, query, args, rows, err)
+	}(time.Now())
 	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
 	if ok {
 		return tx.ExecContext(ctx, query, args...)
@@ -39,7 +139,10 @@ func (d *db) execContext(ctx context.Context, query string, args ...interface{})
 	return d.sqlDB.ExecContext(ctx, query, args...)
 }
 
-func (d *db) queryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+func (d *db) queryContext(ctx context.Context, query string, args ...interface{}) (_ *sql.Rows, err error) {
+	defer func(start time.Time) {
+		d.logQuery(ctx, start, query, args, -1, err)
+	}(time.Now())
 	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
 	if ok {
 		return tx.QueryContext(ctx, query, args...)
@@ -47,7 +150,10 @@ func (d *db) queryContext(ctx context.Context, query string, args ...interface{}
 	return d.sqlDB.QueryContext(ctx, query, args...)
 }
 
-func (d *db) queryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+func (d *db) queryRowContext(ctx context.Context, query string, args ...interface{}) (row *sql.Row) {
+	defer func(start time.Time) {
+		d.logQuery(ctx, start, query, args, -1, row.Err())
+	}(time.Now())
 	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
 	if ok {
 		return tx.QueryRowContext(ctx, query, args...)
@@ -70,6 +176,19 @@ func (n noopTx) Commit() error {
 	return nil
 }
 
+// driverDialect returns the dialect of the driver of sqlDB, for strategies created without a factory. The Postgres
+// drivers are pgx and lib/pq; anything else is taken to be sqlite.
+func driverDialect(sqlDB *sql.DB) statements.Dialect {
+	t := reflect.TypeOf(sqlDB.Driver())
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if strings.HasPrefix(t.PkgPath(), "github.com/jackc/pgx/") || t.PkgPath() == "github.com/lib/pq" {
+		return statements.Postgres
+	}
+	return statements.SQLite
+}
+
 func (d *db) beginTx(ctx context.Context, options *sql.TxOptions) (context.Context, tx, error) {
 	_, ok := ctx.Value(txKey{}).(*sql.Tx)
 	if ok {
@@ -80,11 +199,15 @@ func (d *db) beginTx(ctx context.Context, options *sql.TxOptions) (context.Conte
 	if err != nil {
 		return ctx, nil, err
 	}
+	if err := d.setTenant(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return ctx, nil, err
+	}
 	return context.WithValue(ctx, txKey{}, tx), tx, nil
 }
 
 func (d *db) get(ctx context.Context, namespace, name string) (*record, error) {
-	_, records, err := d.list(ctx, getNamespace(namespace), &name, 0, false, 0, 1)
+	_, records, err := d.list(ctx, getNamespace(namespace), &name, 0, false, cursor{}, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -99,16 +222,57 @@ type tableMeta struct {
 	CompactionID int64
 }
 
+// prunedIDs returns the newest revision that WithHistoryLimit deleted of the objects in namespace and name, which are
+// all objects if nil, and the newest revision it kept of those with a deleted revision at or before rev. A watch from a
+// resourceVersion older than prunedID would miss the deleted revisions, and a list at one older than keptID would
+// return an older revision of an object. Objects that were never pruned don't affect either.
+func (d *db) prunedIDs(ctx context.Context, namespace, name *string, rev int64) (prunedID, keptID int64, _ error) {
+	err := d.queryRowContext(ctx, d.stmt.PrunedIDsSQL(), namespace, name, rev).Scan(&prunedID, &keptID)
+	return prunedID, keptID, err
+}
+
+// getByID returns the record with the given id, or nil if it doesn't exist, for example because it was compacted.
+func (d *db) getByID(ctx context.Context, id int64) (*record, error) {
+	var (
+		r        record
+		created  sql.NullInt16
+		checksum sql.NullInt64
+	)
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	err = d.queryRowContext(ctx, d.stmt.GetByIDSQL(), id).Scan(
+		&r.id, &r.name, &r.namespace, &r.previousID, &r.uid, &created, &r.deleted, &r.value, &r.partitionID, &checksum)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if created.Valid {
+		r.created = created.Int16
+	}
+	if err := d.readValue(ctx, &r, checksum); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
 // list after=true will return all records after rev, whereas after=false it will return just the latest resourceVersion
 // for each name,namespace pair for all records <= rev
-func (d *db) list(ctx context.Context, namespace, name *string, rev int64, after bool, cont, limit int64) (tableMeta, []record, error) {
-	if cont > 0 && rev <= 0 {
+func (d *db) list(ctx context.Context, namespace, name *string, rev int64, after bool, cont cursor, limit int64) (tableMeta, []record, error) {
+	if !cont.isZero() && rev <= 0 {
 		panic("rev must be set when cont is set")
 	}
-	if after && cont != 0 {
+	if after && !cont.isZero() {
 		panic("cont must be zero when after is true")
 	}
 
+	if meta, records, ok := d.listReplica(ctx, namespace, name, rev, after, cont, limit); ok {
+		return meta, records, nil
+	}
+
 	ctx, tx, err := d.beginTx(ctx, &sql.TxOptions{
 		// Repeatable read is needed to ensure that the ListID is consistent across multiple queries
 		Isolation: sql.LevelRepeatableRead,
@@ -119,6 +283,11 @@ func (d *db) list(ctx context.Context, namespace, name *string, rev int64, after
 	}
 	defer tx.Rollback()
 
+	return d.listTx(ctx, tx, namespace, name, rev, after, cont, limit)
+}
+
+// listTx runs a list in the transaction tx, which must be in ctx, and commits it.
+func (d *db) listTx(ctx context.Context, tx tx, namespace, name *string, rev int64, after bool, cont cursor, limit int64) (tableMeta, []record, error) {
 	meta, records, err := d.doList(ctx, namespace, name, rev, after, cont, limit)
 	if err != nil {
 		return tableMeta{}, nil, err
@@ -138,6 +307,26 @@ func (d *db) list(ctx context.Context, namespace, name *string, rev int64, after
 		}
 	}
 
+	if after {
+		// A watch after rev needs every change after rev, which compaction may have removed if rev is before the
+		// compaction ID. The ListID doesn't tell, it is the latest revision of the table. If compaction removed the
+		// latest rows, the ListID can be before rev, but nothing changed after rev.
+		if rev != 0 && rev < meta.CompactionID {
+			return meta, nil, errors.NewCompactionError(uint(rev), uint(meta.CompactionID))
+		}
+		if rev != 0 {
+			prunedID, _, err := d.prunedIDs(ctx, namespace, name, rev)
+			if err != nil {
+				return tableMeta{}, nil, err
+			}
+			if rev < prunedID {
+				return meta, nil, errors.NewCompactionError(uint(rev), uint(prunedID))
+			}
+		}
+		meta.ListID = max(meta.ListID, rev)
+		return meta, records, tx.Commit()
+	}
+
 	// ListID can be zero if no records exist in the table. Also don't check if rev is zero that means
 	// a specific revision was not requested and there we don't need to consider compaction. This condition
 	// is important for when the compaction ID is greater than any existing ID in the table. That can happen
@@ -145,24 +334,52 @@ func (d *db) list(ctx context.Context, namespace, name *string, rev int64, after
 	if rev != 0 && meta.ListID != 0 && meta.ListID < meta.CompactionID {
 		return meta, nil, errors.NewCompactionError(uint(meta.ListID), uint(meta.CompactionID))
 	}
+	// Only the objects in the scope of the list whose revision at rev was pruned make it expire
+	if rev != 0 {
+		_, keptID, err := d.prunedIDs(ctx, namespace, name, rev)
+		if err != nil {
+			return tableMeta{}, nil, err
+		}
+		if rev < keptID {
+			return meta, nil, errors.NewCompactionError(uint(rev), uint(keptID))
+		}
+	}
 
 	return meta, records, tx.Commit()
 }
 
 func (d *db) getTableMeta(ctx context.Context) (meta tableMeta, _ error) {
-	err := d.queryRowContext(ctx, d.stmt.TableMetaSQL()).Scan(&meta.ListID, &meta.CompactionID)
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return meta, err
+	}
+	defer tx.Rollback()
+	err = d.queryRowContext(ctx, d.stmt.TableMetaSQL()).Scan(&meta.ListID, &meta.CompactionID)
 	return meta, err
 }
 
-func (d *db) doList(ctx context.Context, namespace, name *string, rev int64, after bool, cont, limit int64) (meta tableMeta, _ []record, _ error) {
+func (d *db) doList(ctx context.Context, namespace, name *string, rev int64, after bool, cont cursor, limit int64) (meta tableMeta, _ []record, _ error) {
 	var (
 		rows *sql.Rows
 		err  error
 	)
-	if after {
-		rows, err = d.queryContext(ctx, d.stmt.ListAfterSQL(limit), namespace, name, rev)
-	} else {
-		rows, err = d.queryContext(ctx, d.stmt.ListSQL(limit), namespace, name, rev, cont)
+	partitionID := getPartitionID(ctx)
+	filters, filterArgs := indexFilterArgs(ctx)
+	switch {
+	case after && readPreviousValues(ctx):
+		rows, err = d.queryContext(ctx, d.stmt.ListAfterPreviousSQL(limit), namespace, name, rev, partitionID)
+	case after:
+		rows, err = d.queryContext(ctx, d.stmt.ListAfterSQL(limit), namespace, name, rev, partitionID)
+	case d.orderByName:
+		var contNamespace, contName *string
+		if !cont.isZero() {
+			contNamespace, contName = &cont.namespace, &cont.name
+		}
+		rows, err = d.queryContext(ctx, d.stmt.WithIndexFilters(d.stmt.ListByNameSQL(limit), filters, 7),
+			append([]any{namespace, name, rev, contNamespace, partitionID, contName}, filterArgs...)...)
+	default:
+		rows, err = d.queryContext(ctx, d.stmt.WithIndexFilters(d.stmt.ListSQL(limit), filters, 6),
+			append([]any{namespace, name, rev, cont.id, partitionID}, filterArgs...)...)
 	}
 	if err != nil {
 		return meta, nil, err
@@ -172,18 +389,23 @@ func (d *db) doList(ctx context.Context, namespace, name *string, rev int64, aft
 	var records []record
 	for rows.Next() {
 		var (
-			r       record
-			created sql.NullInt16
+			r        record
+			created  sql.NullInt16
+			checksum sql.NullInt64
 		)
 		if err := rows.Scan(
 			&meta.ListID,
 			&meta.CompactionID,
-			&r.id, &r.name, &r.namespace, &r.previousID, &r.uid, &created, &r.deleted, &r.value); err != nil {
+			&r.id, &r.name, &r.namespace, &r.previousID, &r.uid, &created, &r.deleted, &r.value, &r.partitionID,
+			&checksum, &r.previousValue, &r.previousChecksum); err != nil {
 			return meta, nil, err
 		}
 		if created.Valid {
 			r.created = created.Int16
 		}
+		if err := d.readValue(ctx, &r, checksum); err != nil {
+			return meta, nil, err
+		}
 		records = append(records, r)
 	}
 	return meta, records, nil
@@ -203,7 +425,11 @@ func (d *db) insert(ctx context.Context, rec record) (id int64, _ error) {
 		return 0, err
 	}
 
-	return id, tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	d.observeWrite(id)
+	return id, nil
 }
 
 type sqlError interface {
@@ -214,6 +440,17 @@ type sqlCode interface {
 	Code() int
 }
 
+// isUniqueViolation returns true if err is the violation of a unique constraint in postgres or sqlite.
+func isUniqueViolation(err error) bool {
+	if pgErr, ok := err.(sqlError); ok {
+		return pgErr.SQLState() == "23505"
+	}
+	if sqliteErr, ok := err.(sqlCode); ok {
+		return sqliteErr.Code() == 2067
+	}
+	return false
+}
+
 func (d *db) doInsert(ctx context.Context, rec record) (id int64, err error) {
 	_, err = d.execContext(ctx, d.stmt.TableLockSQL())
 	if err != nil {
@@ -230,26 +467,50 @@ func (d *db) doInsert(ctx context.Context, rec record) (id int64, err error) {
 		panic("previousID must be set when created is false")
 	}
 
+	if partitionID, ok := PartitionIDFrom(ctx); ok {
+		rec.partitionID = partitionID
+	} else if d.partitionRequired {
+		return 0, errors.NewPartitionRequired(d.gvk, rec.name)
+	}
+
 	// only check on update, on create DB constraints errors
 	if rec.created == 0 {
+		// If the caller is scoped to a partition this will not find objects in other partitions
 		existing, err := d.get(ctx, rec.namespace, rec.name)
 		if apierrors.IsNotFound(err) {
-			return 0, errors.NewResourceVersionMismatch(d.gvk, rec.name)
+			return 0, errors.NewResourceVersionMismatch(d.gvk, rec.name, *rec.previousID, 0)
 		} else if err != nil {
 			return 0, err
 		} else if existing.id != *rec.previousID {
-			return 0, errors.NewResourceVersionMismatch(d.gvk, rec.name)
+			return 0, errors.NewResourceVersionMismatch(d.gvk, rec.name, *rec.previousID, existing.id)
 		} else if existing.uid != rec.uid {
-			return 0, errors.NewUIDMismatch(rec.name, existing.uid, rec.uid)
+			return 0, errors.NewUIDMismatch(d.gvk, rec.name, existing.uid, rec.uid)
 		} else if rec.deleted == 0 && existing.value == rec.value {
 			return existing.id, nil
 		}
+		// An object never moves between partitions
+		rec.partitionID = existing.partitionID
 	}
 
 	var createdAny any
 	if rec.created == 1 {
 		createdAny = 1
 	}
+	value, err := d.encodeValue(ctx, rec.namespace, rec.name, rec.value)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.checkQuota(ctx, rec, value); err != nil {
+		return 0, err
+	}
+	if rec.created == 1 {
+		if err := d.checkCreated(ctx, rec); err != nil {
+			return 0, err
+		}
+	}
+	if err := d.ensureNextPartition(ctx); err != nil {
+		return 0, err
+	}
 	err = d.queryRowContext(ctx, d.stmt.InsertSQL(),
 		rec.name,
 		rec.namespace,
@@ -257,14 +518,41 @@ func (d *db) doInsert(ctx context.Context, rec record) (id int64, err error) {
 		rec.uid,
 		createdAny,
 		rec.deleted,
-		rec.value).Scan(&id)
-	if pgErr, ok := err.(sqlError); ok && pgErr.SQLState() == "23505" {
-		return 0, errors.NewAlreadyExists(d.gvk, rec.name)
-	} else if sqliteErr, ok := err.(sqlCode); ok && sqliteErr.Code() == 2067 {
+		value,
+		rec.partitionID,
+		time.Now().UnixMilli(),
+		valueChecksum(value),
+		d.gvk.Version).Scan(&id)
+	if isUniqueViolation(err) {
 		return 0, errors.NewAlreadyExists(d.gvk, rec.name)
 	} else if err != nil {
 		return 0, err
 	}
+
+	if d.historyLimit > 0 && rec.created == 0 {
+		if d.archive {
+			if _, err := d.execContext(ctx, d.stmt.ArchivePruneSQL(), rec.namespace, rec.name, d.historyLimit, time.Now().UnixMilli()); err != nil {
+				return 0, err
+			}
+		}
+		// Record the revisions before deleting them, so that reads at the resourceVersions of the deleted revisions
+		// fail like after compaction instead of returning older revisions
+		if _, err := d.execContext(ctx, d.stmt.PrunedHistorySQL(), rec.namespace, rec.name, d.historyLimit); err != nil {
+			return 0, err
+		}
+		if _, err := d.execContext(ctx, d.stmt.PruneHistorySQL(), rec.namespace, rec.name, d.historyLimit); err != nil {
+			return 0, err
+		}
+	}
+	if err := d.updateUnique(ctx, rec); err != nil {
+		return 0, err
+	}
+	if err := d.updateIndexes(ctx, id, rec); err != nil {
+		return 0, err
+	}
+	if err := d.updateSearch(ctx, id, rec); err != nil {
+		return 0, err
+	}
 	return
 }
 
@@ -293,16 +581,62 @@ func (d *db) delete(ctx context.Context, r record) (int64, error) {
 		return 0, err
 	}
 
-	return id, tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	d.observeWrite(id)
+	return id, nil
 }
 
-func (d *db) compact(ctx context.Context) (resultCount int64, _ error) {
-	for {
+// compactBatch deletes a batch of compacted revisions, moving them to the archive table if the table is archived, and
+// returns the number of revisions deleted.
+func (d *db) compactBatch(ctx context.Context) (int64, error) {
+	if !d.archive && !d.rowLevelSecurity {
 		result, err := d.execContext(ctx, d.stmt.CompactSQL())
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+
+	ctx, tx, err := d.beginTx(ctx, &sql.TxOptions{
+		// Both statements must see the same revisions. Row-level security needs the transaction for the tenant.
+		Isolation: sql.LevelRepeatableRead,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if d.archive {
+		if _, err := d.execContext(ctx, d.stmt.ArchiveCompactSQL(), time.Now().UnixMilli()); err != nil {
+			return 0, err
+		}
+	}
+	result, err := d.execContext(ctx, d.stmt.CompactSQL())
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+func (d *db) compact(ctx context.Context) (resultCount int64, _ error) {
+	// Partitions that only hold compacted rows are dropped before their rows would be deleted one by one, unless the
+	// rows must be archived first
+	if !d.archive {
+		count, err := d.dropCompactedPartitions(ctx)
+		resultCount += count
 		if err != nil {
 			return resultCount, err
 		}
-		count, err := result.RowsAffected()
+	}
+
+	for {
+		count, err := d.compactBatch(ctx)
 		resultCount += count
 		if err != nil {
 			return resultCount, err
@@ -311,6 +645,37 @@ func (d *db) compact(ctx context.Context) (resultCount int64, _ error) {
 		}
 	}
 
-	_, err := d.execContext(ctx, d.stmt.UpdateCompactionSQL())
-	return resultCount, err
+	// Partitions emptied by the compaction are dropped as well
+	count, err := d.dropCompactedPartitions(ctx)
+	resultCount += count
+	if err != nil {
+		return resultCount, err
+	}
+
+	if err := d.updateCompaction(ctx); err != nil {
+		return resultCount, err
+	}
+	return resultCount, d.maintain(ctx, resultCount)
+}
+
+// updateCompaction prunes the indexes and advances the compaction ID once the compacted revisions are deleted. Row-level
+// security needs a transaction for the tenant, otherwise the statements would not see the rows of the table.
+func (d *db) updateCompaction(ctx context.Context) error {
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := d.pruneIndexes(ctx); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.UpdateCompactionSQL()); err != nil {
+		return err
+	}
+	// Revisions pruned before the compaction point expire reads like compacted ones, so they needn't be tracked
+	if _, err := d.execContext(ctx, d.stmt.PrunedHistoryClearSQL()); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -1,20 +1,28 @@
 package db
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	_ "github.com/glebarez/go-sqlite"
 	_ "github.com/lib/pq"
+	"github.com/obot-platform/kinm/pkg/db/errors"
 	"github.com/obot-platform/kinm/pkg/db/statements"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apiserver/pkg/storage"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -25,33 +33,32 @@ const (
 	dbname   = "knowledge"
 )
 
-func newDatabase(t *testing.T) *db {
+func newDatabase(t testing.TB) *db {
 	t.Helper()
-	sqldb, lock := newSQLDB(t)
-	_, err := sqldb.ExecContext(context.Background(), "DROP TABLE IF EXISTS recordstest")
-	require.NoError(t, err)
+	sqldb, dialect := newSQLDB(t)
+	dropTable(t, sqldb, "recordstest")
 	s := &db{
 		sqlDB: sqldb,
-		stmt:  statements.New("recordstest", lock),
+		stmt:  statements.New("recordstest", dialect),
 		gvk:   testGVK,
 	}
 	require.NoError(t, s.migrate(context.Background()))
 	insertRows(t, s)
-	_, err = sqldb.Exec("INSERT INTO compaction(name, id) values('recordstest', 1) ON CONFLICT(name) DO UPDATE SET id = 1")
+	_, err := sqldb.Exec("INSERT INTO compaction(name, id) values('recordstest', 1) ON CONFLICT(name) DO UPDATE SET id = 1")
 	require.NoError(t, err)
 	return s
 }
 
-func newSQLDB(t *testing.T) (*sql.DB, bool) {
+func newSQLDB(t testing.TB) (*sql.DB, statements.Dialect) {
 	t.Helper()
 
 	var (
-		err  error
-		lock bool
-		db   *sql.DB
+		err     error
+		dialect = statements.SQLite
+		db      *sql.DB
 	)
 	if os.Getenv("KINM_TEST_DB") == "postgres" {
-		lock = true
+		dialect = statements.Postgres
 		psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
 			"password=%s dbname=%s sslmode=disable",
 			host, port, user, password, dbname)
@@ -72,14 +79,36 @@ func newSQLDB(t *testing.T) (*sql.DB, bool) {
 		t.Fatal(err)
 	}
 
-	return db, lock
+	return db, dialect
+}
+
+// dropTable drops the table and forgets its schema version, compaction point, pruned history and unique rules so that
+// it will be fully migrated again.
+func dropTable(t testing.TB, sqldb *sql.DB, table string) {
+	t.Helper()
+	_, err := sqldb.ExecContext(context.Background(), "DROP TABLE IF EXISTS "+table)
+	require.NoError(t, err)
+	_, err = sqldb.ExecContext(context.Background(), "DROP TABLE IF EXISTS "+table+"_unique_rules")
+	require.NoError(t, err)
+	_, err = sqldb.ExecContext(context.Background(), "CREATE TABLE IF NOT EXISTS schema_version (name VARCHAR(255) NOT NULL UNIQUE, version INTEGER NOT NULL)")
+	require.NoError(t, err)
+	_, err = sqldb.ExecContext(context.Background(), "DELETE FROM schema_version WHERE name = $1", table)
+	require.NoError(t, err)
+	_, err = sqldb.ExecContext(context.Background(), "CREATE TABLE IF NOT EXISTS compaction (name VARCHAR(255) NOT NULL UNIQUE, id INTEGER)")
+	require.NoError(t, err)
+	_, err = sqldb.ExecContext(context.Background(), "DELETE FROM compaction WHERE name = $1", table)
+	require.NoError(t, err)
+	_, err = sqldb.ExecContext(context.Background(), "CREATE TABLE IF NOT EXISTS pruned_history (name VARCHAR(255) NOT NULL, namespace VARCHAR(255) NOT NULL, object_name VARCHAR(255) NOT NULL, first_id INTEGER NOT NULL, id INTEGER NOT NULL, kept_id INTEGER NOT NULL, PRIMARY KEY (name, namespace, object_name))")
+	require.NoError(t, err)
+	_, err = sqldb.ExecContext(context.Background(), "DELETE FROM pruned_history WHERE name = $1", table)
+	require.NoError(t, err)
 }
 
 func TestMigrate(t *testing.T) {
 	_ = newDatabase(t)
 }
 
-func insertRows(t *testing.T, s *db) {
+func insertRows(t testing.TB, s *db) {
 	t.Helper()
 
 	id, err := s.insert(context.Background(), record{
@@ -115,13 +144,13 @@ func insertRows(t *testing.T, s *db) {
 func TestInsert(t *testing.T) {
 	s := newDatabase(t)
 
-	_, records, err := s.list(context.Background(), nil, nil, 1, false, 0, 0)
+	_, records, err := s.list(context.Background(), nil, nil, 1, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 1)
 
 	assert.Equal(t, int16(1), records[0].created)
 
-	_, records, err = s.list(context.Background(), nil, nil, 1, true, 0, 0)
+	_, records, err = s.list(context.Background(), nil, nil, 1, true, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 2)
 
@@ -170,7 +199,7 @@ func ptr[T any](v T) *T {
 func TestCompactionError(t *testing.T) {
 	s := newDatabase(t)
 
-	meta, records, err := s.list(context.Background(), ptr("default"), nil, 0, false, 0, 0)
+	meta, records, err := s.list(context.Background(), ptr("default"), nil, 0, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 1)
 
@@ -180,35 +209,40 @@ func TestCompactionError(t *testing.T) {
 	_, err = s.sqlDB.Exec("UPDATE compaction SET id = 3 WHERE name = 'recordstest'")
 	require.NoError(t, err)
 
-	meta, records, err = s.list(context.Background(), ptr("default"), nil, 0, false, 0, 0)
+	meta, records, err = s.list(context.Background(), ptr("default"), nil, 0, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 1)
 
 	assert.Equal(t, int64(3), meta.ListID)
 	assert.Equal(t, int64(3), meta.CompactionID)
 
-	_, _, err = s.list(context.Background(), ptr("default"), nil, 2, false, 0, 0)
+	_, _, err = s.list(context.Background(), ptr("default"), nil, 2, false, cursor{}, 0)
 	assert.True(t, apierrors.IsResourceExpired(err))
+	assert.True(t, errors.IsCompacted(err))
+	assert.Equal(t, []metav1.StatusCause{
+		{Type: errors.CauseTypeCompacted, Field: "metadata.resourceVersion", Message: "requested: 2"},
+		{Type: errors.CauseTypeCompacted, Field: "metadata.resourceVersion", Message: "compacted: 3"},
+	}, err.(*apierrors.StatusError).ErrStatus.Details.Causes)
 }
 
 func TestList(t *testing.T) {
 	s := newDatabase(t)
 
-	meta, records, err := s.list(context.Background(), ptr("default"), nil, 0, false, 0, 0)
+	meta, records, err := s.list(context.Background(), ptr("default"), nil, 0, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 1)
 
 	assert.Equal(t, int64(3), meta.ListID)
 	assert.Equal(t, int64(1), meta.CompactionID)
 
-	meta, records, err = s.list(context.Background(), ptr("not_default"), nil, 0, false, 0, 0)
+	meta, records, err = s.list(context.Background(), ptr("not_default"), nil, 0, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 0)
 
 	assert.Equal(t, int64(3), meta.ListID)
 	assert.Equal(t, int64(1), meta.CompactionID)
 
-	meta, records, err = s.list(context.Background(), nil, nil, 0, false, 0, 0)
+	meta, records, err = s.list(context.Background(), nil, nil, 0, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 1)
 
@@ -217,7 +251,7 @@ func TestList(t *testing.T) {
 	assert.Equal(t, int64(3), meta.ListID)
 	assert.Equal(t, int64(1), meta.CompactionID)
 
-	meta, records, err = s.list(context.Background(), nil, nil, 2, false, 0, 0)
+	meta, records, err = s.list(context.Background(), nil, nil, 2, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 1)
 
@@ -230,7 +264,7 @@ func TestList(t *testing.T) {
 func TestListAfter(t *testing.T) {
 	s := newDatabase(t)
 
-	meta, records, err := s.list(context.Background(), ptr("default"), nil, 1, true, 0, 0)
+	meta, records, err := s.list(context.Background(), ptr("default"), nil, 1, true, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 2)
 
@@ -256,7 +290,7 @@ func TestDelete(t *testing.T) {
 
 	assert.Equal(t, int64(4), id)
 
-	_, records, err := s.list(context.Background(), ptr("default"), ptr("test"), 0, false, 0, 0)
+	_, records, err := s.list(context.Background(), ptr("default"), ptr("test"), 0, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 0)
 
@@ -266,17 +300,17 @@ func TestDelete(t *testing.T) {
 	_, err = s.sqlDB.ExecContext(context.Background(), "DELETE FROM compaction WHERE name = 'recordstest'")
 	require.NoError(t, err)
 
-	_, records, err = s.list(context.Background(), &r.namespace, &r.name, id-1, false, 0, 0)
+	_, records, err = s.list(context.Background(), &r.namespace, &r.name, id-1, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 1)
 	assert.True(t, records[0].created == 0)
 
-	_, records, err = s.list(context.Background(), &r.namespace, &r.name, 1, false, 0, 0)
+	_, records, err = s.list(context.Background(), &r.namespace, &r.name, 1, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 1)
 	assert.True(t, records[0].created == 1)
 
-	_, records, err = s.list(context.Background(), &r.namespace, &r.name, 1, true, 0, 0)
+	_, records, err = s.list(context.Background(), &r.namespace, &r.name, 1, true, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 3)
 
@@ -335,7 +369,7 @@ func TestCompaction(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	_, records, err := s.list(context.Background(), nil, nil, 1, true, 0, 0)
+	_, records, err := s.list(context.Background(), nil, nil, 1, true, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 7)
 
@@ -352,7 +386,7 @@ func TestCompaction(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, int64(4), count)
 
-	_, records, err = s.list(context.Background(), nil, nil, 8, false, 0, 0)
+	_, records, err = s.list(context.Background(), nil, nil, 8, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 2)
 
@@ -414,7 +448,7 @@ func TestCompactionGreaterThan500Records(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	_, records, err := s.list(context.Background(), nil, nil, 1, true, 0, 0)
+	_, records, err := s.list(context.Background(), nil, nil, 1, true, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 557)
 
@@ -431,7 +465,7 @@ func TestCompactionGreaterThan500Records(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, int64(554), count)
 
-	_, records, err = s.list(context.Background(), nil, nil, 558, false, 0, 0)
+	_, records, err = s.list(context.Background(), nil, nil, 558, false, cursor{}, 0)
 	require.NoError(t, err)
 	assert.Len(t, records, 552)
 
@@ -453,6 +487,12 @@ func TestConflict(t *testing.T) {
 		value:      "value",
 	})
 	assert.True(t, apierrors.IsConflict(err))
+	assert.True(t, errors.IsResourceVersionMismatch(err))
+	assert.False(t, errors.IsUIDMismatch(err))
+	assert.Equal(t, []metav1.StatusCause{
+		{Type: errors.CauseTypeResourceVersionMismatch, Field: "metadata.resourceVersion", Message: "requested: 1"},
+		{Type: errors.CauseTypeResourceVersionMismatch, Field: "metadata.resourceVersion", Message: "current: 3"},
+	}, err.(*apierrors.StatusError).ErrStatus.Details.Causes)
 }
 
 func TestUIDMatch(t *testing.T) {
@@ -465,7 +505,410 @@ func TestUIDMatch(t *testing.T) {
 		value:      "value",
 	})
 	require.NotNil(t, err)
-	assert.Equal(t, `StorageError: invalid object, Code: 4, Key: test, ResourceVersion: 0, AdditionalErrorMsg: Precondition failed: UID in precondition: , UID in object meta: uid`, err.Error())
-	_, ok := err.(*storage.StorageError)
+	assert.True(t, apierrors.IsConflict(err))
+	assert.True(t, errors.IsUIDMismatch(err))
+	assert.Equal(t, []metav1.StatusCause{
+		{Type: errors.CauseTypeUIDMismatch, Field: "metadata.uid", Message: "stored: "},
+		{Type: errors.CauseTypeUIDMismatch, Field: "metadata.uid", Message: "given: uid"},
+	}, err.(*apierrors.StatusError).ErrStatus.Details.Causes)
+}
+
+func TestCompression(t *testing.T) {
+	s := newDatabase(t)
+	s.compressThreshold = 10
+
+	value := strings.Repeat("compressible", 100)
+	id, err := s.insert(context.Background(), record{
+		name:      "compressed",
+		namespace: "default",
+		created:   1,
+		value:     value,
+	})
+	require.NoError(t, err)
+
+	var raw string
+	err = s.sqlDB.QueryRow("SELECT value FROM recordstest WHERE id = $1", id).Scan(&raw)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, zstdPrefix))
+	assert.Less(t, len(raw), len(value))
+
+	rec, err := s.get(context.Background(), "default", "compressed")
+	require.NoError(t, err)
+	assert.Equal(t, value, rec.value)
+
+	// Values under the threshold are stored as is
+	rec, err = s.get(context.Background(), "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "value3", rec.value)
+}
+
+func TestBlobStore(t *testing.T) {
+	s := newDatabase(t)
+	blobs, err := NewFileBlobStore(t.TempDir())
+	require.NoError(t, err)
+	s.blobs = blobs
+	s.blobThreshold = 100
+
+	value := strings.Repeat("large", 100)
+	id, err := s.insert(context.Background(), record{
+		name:      "large",
+		namespace: "default",
+		created:   1,
+		value:     value,
+	})
+	require.NoError(t, err)
+
+	var raw string
+	err = s.sqlDB.QueryRow("SELECT value FROM recordstest WHERE id = $1", id).Scan(&raw)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, blobPrefix))
+
+	rec, err := s.get(context.Background(), "default", "large")
+	require.NoError(t, err)
+	assert.Equal(t, value, rec.value)
+}
+
+func TestHistoryLimit(t *testing.T) {
+	s := newDatabase(t)
+	s.historyLimit = 2
+
+	rec, err := s.get(context.Background(), "default", "test")
+	require.NoError(t, err)
+
+	id := rec.id
+	for i := range 3 {
+		id, err = s.insert(context.Background(), record{
+			name:       "test",
+			namespace:  "default",
+			previousID: &id,
+			value:      fmt.Sprintf("value%d", i+4),
+		})
+		require.NoError(t, err)
+	}
+
+	// The revision 4 was pruned, so the changes after 3 are incomplete
+	_, _, err = s.list(context.Background(), ptr("default"), ptr("test"), 3, true, cursor{}, 0)
+	assert.True(t, errors.IsCompacted(err))
+
+	_, records, err := s.list(context.Background(), ptr("default"), ptr("test"), 4, true, cursor{}, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "value5", records[0].value)
+	assert.Equal(t, "value6", records[1].value)
+
+	// The creation record is always retained
+	var count int64
+	err = s.sqlDB.QueryRow("SELECT count(*) FROM recordstest WHERE id = 1").Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestListAfterPreviousValues(t *testing.T) {
+	s := newDatabase(t)
+
+	// Only consumers of the previous objects read them
+	_, records, err := s.list(context.Background(), nil, nil, 2, true, cursor{}, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Nil(t, records[0].previousValue)
+
+	_, records, err = s.list(withPreviousValues(context.Background()), nil, nil, 2, true, cursor{}, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.NotNil(t, records[0].previousValue)
+	assert.Equal(t, "value2", *records[0].previousValue)
+}
+
+func TestPartition(t *testing.T) {
+	s := newDatabase(t)
+	s.partitionRequired = true
+
+	_, err := s.insert(context.Background(), record{
+		name:      "partitioned",
+		namespace: "default",
+		created:   1,
+		value:     "value1",
+	})
+	assert.True(t, apierrors.IsBadRequest(err))
+
+	tenant1 := WithPartitionID(context.Background(), "tenant1")
+	tenant2 := WithPartitionID(context.Background(), "tenant2")
+
+	id, err := s.insert(tenant1, record{
+		name:      "partitioned",
+		namespace: "default",
+		created:   1,
+		value:     "value1",
+	})
+	require.NoError(t, err)
+
+	rec, err := s.get(tenant1, "default", "partitioned")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant1", rec.partitionID)
+
+	_, err = s.get(tenant2, "default", "partitioned")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	_, err = s.insert(tenant2, record{
+		name:       "partitioned",
+		namespace:  "default",
+		previousID: &id,
+		value:      "value2",
+	})
+	assert.True(t, apierrors.IsConflict(err))
+
+	// Names are unique across partitions
+	_, err = s.insert(tenant2, record{
+		name:      "partitioned",
+		namespace: "default",
+		created:   1,
+		value:     "value2",
+	})
+	assert.True(t, apierrors.IsAlreadyExists(err))
+
+	// Without a partition all objects are visible
+	_, records, err := s.list(context.Background(), ptr("default"), nil, 0, false, cursor{}, 0)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestRowLevelSecurity(t *testing.T) {
+	s := newDatabase(t)
+	s.rowLevelSecurity = true
+
+	err := s.enableRowLevelSecurity(context.Background())
+	if s.stmt.Dialect() == "sqlite" {
+		assert.ErrorContains(t, err, "not supported by sqlite")
+		return
+	}
+	require.NoError(t, err)
+
+	tenant1 := WithPartitionID(context.Background(), "tenant1")
+	id, err := s.insert(tenant1, record{
+		name:      "partitioned",
+		namespace: "default",
+		created:   1,
+		value:     "value1",
+	})
+	require.NoError(t, err)
+
+	// A query that forgets the partition only sees the rows of the partition of the transaction
+	count := func(ctx context.Context) int {
+		ctx, tx, err := s.beginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+		var count int
+		require.NoError(t, s.queryRowContext(ctx, "SELECT count(*) FROM recordstest WHERE name = 'partitioned'").Scan(&count))
+		return count
+	}
+	assert.Equal(t, 1, count(tenant1))
+	assert.Equal(t, 0, count(WithPartitionID(context.Background(), "tenant2")))
+	assert.Equal(t, 1, count(WithRowLevelSecurityBypass(context.Background())))
+
+	// The policy fails closed: without a tenant, outside of a transaction or after clearing the tenant, nothing is read
+	assert.Equal(t, 0, count(context.Background()))
+	var rows int
+	require.NoError(t, s.sqlDB.QueryRow("SELECT count(*) FROM recordstest WHERE name = 'partitioned'").Scan(&rows))
+	assert.Equal(t, 0, rows)
+	cleared, tx, err := s.beginTx(tenant1, nil)
+	require.NoError(t, err)
+	_, err = s.execContext(cleared, "SELECT set_config('app.tenant_id', '', true)")
+	require.NoError(t, err)
+	require.NoError(t, s.queryRowContext(cleared, "SELECT count(*) FROM recordstest WHERE name = 'partitioned'").Scan(&rows))
+	assert.Equal(t, 0, rows)
+	require.NoError(t, tx.Rollback())
+
+	// Reads outside of a transaction set the tenant as well
+	head, err := s.headRevision(tenant1, "default", "partitioned")
+	require.NoError(t, err)
+	assert.Equal(t, id, head)
+	rec, err := s.getByID(tenant1, id)
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	rec, err = s.getByID(WithPartitionID(context.Background(), "tenant2"), id)
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+	meta, err := s.getTableMeta(WithRowLevelSecurityBypass(context.Background()))
+	require.NoError(t, err)
+	assert.Equal(t, id, meta.ListID)
+
+	// Rows can't be written to another partition
+	ctx, tx, err := s.beginTx(tenant1, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = s.execContext(ctx, "UPDATE recordstest SET partition_id = 'tenant2' WHERE name = 'partitioned'")
+	assert.Error(t, err)
+}
+
+func TestMigrateExistingTable(t *testing.T) {
+	sqldb, dialect := newSQLDB(t)
+	dropTable(t, sqldb, "recordstest")
+
+	// A table created before migrations were versioned
+	_, err := sqldb.Exec(`CREATE TABLE recordstest
+(
+    id          INTEGER PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    namespace   VARCHAR(255) NOT NULL,
+    previous_id INTEGER UNIQUE,
+    uid         VARCHAR(255) NOT NULL,
+    created     INTEGER,
+    deleted     INTEGER       DEFAULT 0 NOT NULL,
+    value       TEXT NOT NULL DEFAULT '',
+    CONSTRAINT recordstest_unique_name_namespace_created UNIQUE (name, namespace, created)
+)`)
+	require.NoError(t, err)
+
+	s := &db{
+		sqlDB: sqldb,
+		stmt:  statements.New("recordstest", dialect),
+		gvk:   testGVK,
+	}
+	require.NoError(t, s.migrate(context.Background()))
+	// Migrating again is a no-op
+	require.NoError(t, s.migrate(context.Background()))
+
+	ok, err := s.hasColumn(context.Background(), "partition_id")
+	require.NoError(t, err)
 	assert.True(t, ok)
+
+	var version int
+	err = sqldb.QueryRow("SELECT version FROM schema_version WHERE name = 'recordstest'").Scan(&version)
+	require.NoError(t, err)
+	assert.Equal(t, len(s.stmt.Migrations()), version)
+}
+
+func TestQueryLogger(t *testing.T) {
+	d := newDatabase(t)
+
+	var buf bytes.Buffer
+	d.logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	_, err := d.insert(context.Background(), record{
+		name:      "logged",
+		namespace: "default",
+		created:   1,
+		value:     "secret-value",
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `"msg":"sql query executed"`)
+	assert.Contains(t, buf.String(), `"table":"recordstest"`)
+	assert.Contains(t, buf.String(), "<redacted 12 bytes>")
+	assert.NotContains(t, buf.String(), "secret-value")
+
+	// Creating an existing object is an expected failure
+	buf.Reset()
+	d.logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	_, err = d.insert(context.Background(), record{
+		name:      "logged",
+		namespace: "default",
+		created:   1,
+		value:     "secret-value",
+	})
+	require.True(t, apierrors.IsAlreadyExists(err))
+	assert.Empty(t, buf.String())
+}
+
+// fakeServer is a database for testing failover, answering whether it is a replica and, to any other query, its name.
+type fakeServer struct {
+	name    string
+	down    atomic.Bool
+	replica atomic.Bool
+}
+
+func (s *fakeServer) Connect(context.Context) (driver.Conn, error) {
+	if s.down.Load() {
+		return nil, fmt.Errorf("%s is down", s.name)
+	}
+	return &fakeConn{server: s}, nil
+}
+
+func (s *fakeServer) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	server *fakeServer
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if c.server.down.Load() {
+		return nil, driver.ErrBadConn
+	}
+	var value driver.Value = c.server.name
+	if query == "SELECT pg_is_in_recovery()" {
+		value = c.server.replica.Load()
+	}
+	return &fakeRows{value: value}, nil
+}
+
+type fakeRows struct {
+	value driver.Value
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+func TestFailover(t *testing.T) {
+	a, b := &fakeServer{name: "a"}, &fakeServer{name: "b"}
+	b.replica.Store(true)
+	c := &failoverConnector{
+		names:      []string{"a", "b"},
+		connectors: []driver.Connector{a, b},
+	}
+	sqlDB := sql.OpenDB(c)
+	defer sqlDB.Close()
+	sqlDB.SetMaxOpenConns(1)
+
+	server := func() string {
+		t.Helper()
+		var name string
+		require.NoError(t, sqlDB.QueryRow("SELECT name").Scan(&name))
+		return name
+	}
+	assert.Equal(t, "a", server())
+
+	// Switchover, the pooled connection to a is retired
+	a.replica.Store(true)
+	b.replica.Store(false)
+	c.check(context.Background(), time.Second)
+	assert.Equal(t, "b", server())
+
+	// The primary goes down and a is promoted before the next check
+	b.down.Store(true)
+	a.replica.Store(false)
+	assert.Equal(t, "a", server())
+
+	a.down.Store(true)
+	c.check(context.Background(), time.Second)
+	err := sqlDB.QueryRow("SELECT name").Scan(new(string))
+	assert.ErrorContains(t, err, "no primary database reachable")
 }
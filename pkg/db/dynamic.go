@@ -0,0 +1,186 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/apigroup"
+	"github.com/obot-platform/kinm/pkg/stores"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/registry/rest"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	openapierrors "k8s.io/kube-openapi/pkg/validation/errors"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// DynamicKind is a kind that is registered at runtime instead of compiled in, such as a kind added by a plugin. Like
+// the custom resources of a CRD, its objects have no Go type, are handled as unstructured.Unstructured, and are
+// validated against the schema of the kind.
+type DynamicKind struct {
+	// GroupVersionKind is the kind. The kind of its lists has a "List" suffix.
+	GroupVersionKind schema.GroupVersionKind
+	// Schema is the OpenAPI v3 schema that objects are validated against when they are created or updated. The
+	// metadata is validated by kinm, so the schema only needs to describe the other fields. Fields that the schema
+	// doesn't declare are stored as given. A nil schema accepts any object.
+	Schema *spec.Schema
+	// ClusterScoped makes the kind cluster scoped instead of namespaced.
+	ClusterScoped bool
+	// TableName is the name of the table of the kind. The default is the lowercase kind.
+	TableName string
+}
+
+// register adds the kind and its list to scheme as unstructured types.
+func (k DynamicKind) register(scheme *runtime.Scheme) error {
+	listGVK := k.GroupVersionKind.GroupVersion().WithKind(k.GroupVersionKind.Kind + "List")
+	for _, gvk := range []schema.GroupVersionKind{k.GroupVersionKind, listGVK} {
+		if !scheme.Recognizes(gvk) {
+			continue
+		}
+		if obj, err := scheme.New(gvk); err != nil {
+			return err
+		} else if _, ok := obj.(runtime.Unstructured); !ok {
+			return fmt.Errorf("%s is already registered as %T", gvk, obj)
+		}
+	}
+	scheme.AddKnownTypeWithName(k.GroupVersionKind, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(listGVK, &unstructured.UnstructuredList{})
+	return nil
+}
+
+// NewDynamicStrategy registers kind in the scheme of the factory, creates its table if needed, and returns a strategy
+// for its objects. The scheme isn't safe for concurrent writes, so dynamic kinds must be registered before the scheme
+// is used, such as before the apiserver is started.
+func (f *Factory) NewDynamicStrategy(kind DynamicKind, opts ...Option) (strategy.CompleteStrategy, error) {
+	if err := kind.register(f.schema); err != nil {
+		return nil, err
+	}
+	tableName := kind.TableName
+	if tableName == "" {
+		tableName = strings.ToLower(kind.GroupVersionKind.Kind)
+	}
+	s, err := f.newStrategy(kind.GroupVersionKind, tableName, append([]Option{func(s *Strategy) {
+		if kind.ClusterScoped {
+			WithClusterScoped()(s)
+		}
+		if kind.Schema != nil {
+			s.schemaValidator = validate.NewSchemaValidator(kind.Schema, nil, "", strfmt.Default)
+		}
+	}}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DynamicStores is Stores for dynamic kinds.
+func (f *Factory) DynamicStores(kinds ...DynamicKind) (map[string]rest.Storage, error) {
+	result := make(map[string]rest.Storage, len(kinds))
+	for _, kind := range kinds {
+		plural, _ := meta.UnsafeGuessKindToResource(kind.GroupVersionKind)
+		resource := plural.Resource
+		if _, ok := result[resource]; ok {
+			return nil, fmt.Errorf("resource %s is defined more than once", resource)
+		}
+
+		s, err := f.NewDynamicStrategy(kind)
+		if err != nil {
+			return nil, err
+		}
+		result[resource] = stores.NewComplete(f.schema, s)
+		if undeleter, ok := s.(strategy.Undeleter); ok && f.undelete {
+			result[resource+"/undelete"] = stores.NewUndelete(undeleter)
+		}
+	}
+	return result, nil
+}
+
+// DynamicAPIGroup is APIGroup for dynamic kinds, which must all belong to groupVersion. The server needs OpenAPI
+// definitions for unstructured objects, such as the ones of pkg/openapi.
+//
+// The apiserver creates empty objects without their kind, which it can't do for unstructured objects, so it logs an
+// error and records no managed fields when an object of a dynamic kind is created.
+func (f *Factory) DynamicAPIGroup(groupVersion schema.GroupVersion, kinds ...DynamicKind) (*genericapiserver.APIGroupInfo, error) {
+	for _, kind := range kinds {
+		if kind.GroupVersionKind.GroupVersion() != groupVersion {
+			return nil, fmt.Errorf("%s does not belong to %s", kind.GroupVersionKind, groupVersion)
+		}
+	}
+	storage, err := f.DynamicStores(kinds...)
+	if err != nil {
+		return nil, err
+	}
+	return apigroup.ForStores(func(scheme *runtime.Scheme) error {
+		// The options of requests are decoded as the unversioned options, as for custom resources
+		metav1.AddToGroupVersion(scheme, schema.GroupVersion{Version: "v1"})
+		metav1.AddToGroupVersion(scheme, groupVersion)
+		for _, kind := range kinds {
+			if err := kind.register(scheme); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, storage, groupVersion)
+}
+
+// validateSchema validates obj against the schema of a dynamic kind.
+func (s *Strategy) validateSchema(obj types.Object) error {
+	if s.schemaValidator == nil {
+		return nil
+	}
+	u, ok := obj.(runtime.Unstructured)
+	if !ok {
+		return nil
+	}
+	content := u.UnstructuredContent()
+	// The metadata is validated by the strategy
+	if _, ok := content["metadata"]; ok {
+		content = maps.Clone(content)
+		delete(content, "metadata")
+	}
+
+	var errs field.ErrorList
+	for _, err := range s.schemaValidator.Validate(content).Errors {
+		errs = append(errs, schemaErrors(err)...)
+	}
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(s.db.gvk.GroupKind(), obj.GetName(), errs)
+	}
+	return nil
+}
+
+// schemaErrors converts an error of the schema validator to field errors.
+func schemaErrors(err error) field.ErrorList {
+	var composite *openapierrors.CompositeError
+	if errors.As(err, &composite) {
+		var errs field.ErrorList
+		for _, err := range composite.Errors {
+			errs = append(errs, schemaErrors(err)...)
+		}
+		return errs
+	}
+
+	var validation *openapierrors.Validation
+	if !errors.As(err, &validation) {
+		return field.ErrorList{field.InternalError(nil, err)}
+	}
+	var path *field.Path
+	if name := strings.TrimPrefix(validation.Name, "."); name != "" {
+		path = field.NewPath(name)
+	}
+	if validation.Code() == openapierrors.RequiredFailCode {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	return field.ErrorList{field.Invalid(path, validation.Value, validation.Error())}
+}
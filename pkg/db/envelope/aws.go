@@ -0,0 +1,167 @@
+package envelope
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSCredentials are the credentials requests to AWS KMS are signed with.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+}
+
+// AWSKMS encrypts DEKs with an AWS KMS key, calling its API. The key ID of a value is the ARN of the key that
+// encrypted its DEK. AWS KMS keeps the old material of rotated keys, so only changing Key to another key needs the
+// values to be re-encrypted.
+type AWSKMS struct {
+	// Key is the ID, ARN or alias of the key.
+	Key string
+	// Region is the AWS region of the key.
+	Region string
+	// Credentials returns the credentials requests are signed with. The default is AWSEnvCredentials.
+	Credentials func(ctx context.Context) (AWSCredentials, error)
+	// Client sends the requests. The default is http.DefaultClient.
+	Client *http.Client
+	// Endpoint is the URL of the API. The default is https://kms.REGION.amazonaws.com.
+	Endpoint string
+}
+
+func (k *AWSKMS) Encrypt(ctx context.Context, plaintext []byte) (string, []byte, error) {
+	var resp struct {
+		KeyID          string `json:"KeyId"`
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+	if err := k.call(ctx, "Encrypt", map[string]any{"KeyId": k.Key, "Plaintext": plaintext}, &resp); err != nil {
+		return "", nil, err
+	}
+	return resp.KeyID, resp.CiphertextBlob, nil
+}
+
+func (k *AWSKMS) Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+	if err := k.call(ctx, "Decrypt", map[string]any{"KeyId": keyID, "CiphertextBlob": ciphertext}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+func (k *AWSKMS) call(ctx context.Context, action string, req, resp any) error {
+	if k.Region == "" {
+		return fmt.Errorf("the region of the AWS KMS key is required")
+	}
+	credentials := k.Credentials
+	if credentials == nil {
+		credentials = AWSEnvCredentials
+	}
+	creds, err := credentials(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS credentials: %w", err)
+	}
+
+	endpoint := k.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + k.Region + ".amazonaws.com"
+	}
+	return postJSON(ctx, k.Client, strings.TrimSuffix(endpoint, "/")+"/", req, resp, func(r *http.Request, body []byte) error {
+		r.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		r.Header.Set("X-Amz-Target", "TrentService."+action)
+		signAWSRequest(r, body, creds, k.Region, "kms", time.Now())
+		return nil
+	})
+}
+
+// AWSEnvCredentials returns the credentials in $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN.
+func AWSEnvCredentials(context.Context) (AWSCredentials, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return AWSCredentials{}, fmt.Errorf("$AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY are required")
+	}
+	return creds, nil
+}
+
+// signAWSRequest signs r, whose body is body, with AWS signature version 4.
+func signAWSRequest(r *http.Request, body []byte, creds AWSCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	r.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": r.URL.Host}
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		path,
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func canonicalQuery(query url.Values) string {
+	// url.Values.Encode sorts by key, but encodes spaces as +, which signature version 4 doesn't allow
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
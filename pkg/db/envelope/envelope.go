@@ -0,0 +1,241 @@
+// Package envelope encrypts the values stored by kinm with envelope encryption: every value is encrypted with
+// AES-GCM by a data encryption key (DEK), and the DEK is encrypted by a key encryption key held by a KMS and stored
+// with the value. DEKs are reused for many values and cached, so that the KMS is only called when a DEK is created or
+// first read, and rotating the key of the KMS only needs the values to be re-encrypted with a new DEK, such as with
+// db.Strategy.MigrateStorage or the kinm rotate-key command.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+const (
+	formatVersion = 1
+	dekSize       = 32
+
+	defaultDEKMaxUses  = 1 << 20
+	defaultDEKLifetime = 24 * time.Hour
+	defaultCacheSize   = 1000
+)
+
+// KMS encrypts and decrypts DEKs with a key encryption key that doesn't leave the KMS.
+type KMS interface {
+	// Encrypt encrypts plaintext with the current key and returns the ID of the key with the ciphertext.
+	Encrypt(ctx context.Context, plaintext []byte) (keyID string, ciphertext []byte, err error)
+	// Decrypt decrypts ciphertext returned by Encrypt with the key keyID.
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// Options configures a Transformer.
+type Options struct {
+	// DEKMaxUses is the number of values a DEK encrypts before a new one is created. The default is 2^20, well below
+	// the limit of random AES-GCM nonces.
+	DEKMaxUses int64
+	// DEKLifetime is the time after which a new DEK is created. The default is 24 hours.
+	DEKLifetime time.Duration
+	// CacheSize is the number of decrypted DEKs kept in memory. The default is 1000.
+	CacheSize int
+}
+
+// Transformer is a value.Transformer that encrypts values with envelope encryption. Use it with db.WithTransformer or
+// db.WithValueTransformer.
+type Transformer struct {
+	kms  KMS
+	opts Options
+
+	lock    sync.Mutex
+	current *dek
+	cache   map[string]cipher.AEAD
+}
+
+// dek is the DEK new values are encrypted with.
+type dek struct {
+	aead    cipher.AEAD
+	keyID   string
+	wrapped []byte
+	uses    int64
+	expires time.Time
+}
+
+// NewTransformer returns a Transformer that encrypts DEKs with kms.
+func NewTransformer(kms KMS, opts Options) *Transformer {
+	if opts.DEKMaxUses <= 0 {
+		opts.DEKMaxUses = defaultDEKMaxUses
+	}
+	if opts.DEKLifetime <= 0 {
+		opts.DEKLifetime = defaultDEKLifetime
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = defaultCacheSize
+	}
+	return &Transformer{
+		kms:   kms,
+		opts:  opts,
+		cache: map[string]cipher.AEAD{},
+	}
+}
+
+// Rotate makes the transformer create a new DEK, encrypted with the current key of the KMS, for the next value. Call
+// it after rotating the key of the KMS in a running program.
+func (t *Transformer) Rotate() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.current = nil
+}
+
+// TransformToStorage encrypts data with the current DEK, the authenticated data of dataCtx binding it to its object.
+func (t *Transformer) TransformToStorage(ctx context.Context, data []byte, dataCtx value.Context) ([]byte, error) {
+	d, err := t.currentDEK(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, d.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 1+2+len(d.keyID)+2+len(d.wrapped)+len(nonce)+len(data)+d.aead.Overhead())
+	out = append(out, formatVersion)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(d.keyID)))
+	out = append(out, d.keyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(d.wrapped)))
+	out = append(out, d.wrapped...)
+	out = append(out, nonce...)
+	return d.aead.Seal(out, nonce, data, dataCtx.AuthenticatedData()), nil
+}
+
+// TransformFromStorage decrypts data. The value is stale if it wasn't encrypted with the current key of the KMS, so
+// that it is re-encrypted when rewritten.
+func (t *Transformer) TransformFromStorage(ctx context.Context, data []byte, dataCtx value.Context) ([]byte, bool, error) {
+	keyID, wrapped, rest, err := parse(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	aead, err := t.decryptDEK(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, false, fmt.Errorf("invalid envelope: value is too short")
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, dataCtx.AuthenticatedData())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+
+	t.lock.Lock()
+	stale := t.current != nil && t.current.keyID != keyID
+	t.lock.Unlock()
+	return plaintext, stale, nil
+}
+
+// currentDEK returns the DEK to encrypt the next value with, creating a new one if there is none or it is used up.
+func (t *Transformer) currentDEK(ctx context.Context) (*dek, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if d := t.current; d != nil && d.uses < t.opts.DEKMaxUses && time.Now().Before(d.expires) {
+		d.uses++
+		return d, nil
+	}
+
+	key := make([]byte, dekSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := t.kms.Encrypt(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data encryption key: %w", err)
+	}
+	if len(keyID) > 0xffff || len(wrapped) > 0xffff {
+		return nil, fmt.Errorf("encrypted data encryption key is too long")
+	}
+
+	t.current = &dek{
+		aead:    aead,
+		keyID:   keyID,
+		wrapped: wrapped,
+		uses:    1,
+		expires: time.Now().Add(t.opts.DEKLifetime),
+	}
+	t.cacheDEK(wrapped, aead)
+	return t.current, nil
+}
+
+// decryptDEK returns the DEK encrypted as wrapped with the key keyID, from the cache or the KMS.
+func (t *Transformer) decryptDEK(ctx context.Context, keyID string, wrapped []byte) (cipher.AEAD, error) {
+	t.lock.Lock()
+	aead, ok := t.cache[string(wrapped)]
+	t.lock.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	key, err := t.kms.Decrypt(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data encryption key with key %q: %w", keyID, err)
+	}
+	aead, err = newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	t.cacheDEK(wrapped, aead)
+	t.lock.Unlock()
+	return aead, nil
+}
+
+// cacheDEK adds a decrypted DEK to the cache, emptying it first if it is full. t.lock must be held.
+func (t *Transformer) cacheDEK(wrapped []byte, aead cipher.AEAD) {
+	if len(t.cache) >= t.opts.CacheSize {
+		clear(t.cache)
+	}
+	t.cache[string(wrapped)] = aead
+}
+
+// parse splits an encrypted value into the key ID, the encrypted DEK and the nonce followed by the ciphertext.
+func parse(data []byte) (keyID string, wrapped, rest []byte, err error) {
+	if len(data) == 0 || data[0] != formatVersion {
+		return "", nil, nil, fmt.Errorf("invalid envelope: unknown format")
+	}
+	rest = data[1:]
+
+	var fields [2][]byte
+	for i := range fields {
+		if len(rest) < 2 {
+			return "", nil, nil, fmt.Errorf("invalid envelope: value is too short")
+		}
+		n := int(binary.BigEndian.Uint16(rest))
+		rest = rest[2:]
+		if len(rest) < n {
+			return "", nil, nil, fmt.Errorf("invalid envelope: value is too short")
+		}
+		fields[i], rest = rest[:n], rest[n:]
+	}
+	return string(fields[0]), fields[1], rest, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,130 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+var ctx = context.Background()
+
+func TestTransformerRotation(t *testing.T) {
+	dataCtx := value.DefaultContext("default/test")
+
+	key1, err := NewLocalKey("key1")
+	require.NoError(t, err)
+	kms1, err := NewLocalKMS(key1)
+	require.NoError(t, err)
+	old, err := NewTransformer(kms1, Options{}).TransformToStorage(ctx, []byte("data"), dataCtx)
+	require.NoError(t, err)
+
+	// After adding a new primary key, values of the old key are read and reported as stale
+	key2, err := NewLocalKey("key2")
+	require.NoError(t, err)
+	kms2, err := NewLocalKMS(key2, key1)
+	require.NoError(t, err)
+	rotated := NewTransformer(kms2, Options{})
+	current, err := rotated.TransformToStorage(ctx, []byte("data"), dataCtx)
+	require.NoError(t, err)
+
+	plaintext, stale, err := rotated.TransformFromStorage(ctx, old, dataCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(plaintext))
+	assert.True(t, stale)
+
+	plaintext, stale, err = rotated.TransformFromStorage(ctx, current, dataCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(plaintext))
+	assert.False(t, stale)
+
+	// Once the old key is removed, only the re-encrypted values can be read
+	kms3, err := NewLocalKMS(key2)
+	require.NoError(t, err)
+	_, _, err = NewTransformer(kms3, Options{}).TransformFromStorage(ctx, old, dataCtx)
+	assert.Error(t, err)
+	plaintext, _, err = NewTransformer(kms3, Options{}).TransformFromStorage(ctx, current, dataCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(plaintext))
+}
+
+func TestCloudKMS(t *testing.T) {
+	dataCtx := value.DefaultContext("default/test")
+
+	// Fakes that "encrypt" by prefixing the plaintext
+	var (
+		lock    sync.Mutex
+		calls   []string
+		headers []http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		calls = append(calls, r.URL.Path+" "+r.Header.Get("X-Amz-Target"))
+		headers = append(headers, r.Header.Clone())
+		lock.Unlock()
+
+		// The field names of GCP are lowercase, which Unmarshal matches to those of AWS
+		var req struct {
+			Plaintext, Ciphertext, CiphertextBlob []byte
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		resp := map[string]any{}
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":encrypt"):
+			resp["name"], resp["ciphertext"] = "projects/p/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", append([]byte("gcp:"), req.Plaintext...)
+		case strings.HasSuffix(r.URL.Path, ":decrypt"):
+			resp["plaintext"] = bytes.TrimPrefix(req.Ciphertext, []byte("gcp:"))
+		case r.Header.Get("X-Amz-Target") == "TrentService.Encrypt":
+			resp["KeyId"], resp["CiphertextBlob"] = "arn:aws:kms:us-east-1:1:key/k", append([]byte("aws:"), req.Plaintext...)
+		case r.Header.Get("X-Amz-Target") == "TrentService.Decrypt":
+			resp["Plaintext"] = bytes.TrimPrefix(req.CiphertextBlob, []byte("aws:"))
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	for _, kms := range []KMS{
+		&GCPKMS{
+			Key:      "projects/p/keyRings/r/cryptoKeys/k",
+			Token:    func(context.Context) (string, error) { return "token", nil },
+			Endpoint: server.URL,
+		},
+		&AWSKMS{
+			Key:    "alias/k",
+			Region: "us-east-1",
+			Credentials: func(context.Context) (AWSCredentials, error) {
+				return AWSCredentials{AccessKeyID: "id", SecretAccessKey: "secret"}, nil
+			},
+			Endpoint: server.URL,
+		},
+	} {
+		ciphertext, err := NewTransformer(kms, Options{}).TransformToStorage(ctx, []byte("data"), dataCtx)
+		require.NoError(t, err)
+		// A new transformer doesn't have the DEK cached and decrypts it with the KMS
+		plaintext, _, err := NewTransformer(kms, Options{}).TransformFromStorage(ctx, ciphertext, dataCtx)
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(plaintext))
+
+		// The value is bound to its object
+		_, _, err = NewTransformer(kms, Options{}).TransformFromStorage(ctx, ciphertext, value.DefaultContext("default/other"))
+		assert.Error(t, err)
+	}
+	assert.Equal(t, []string{
+		"/v1/projects/p/keyRings/r/cryptoKeys/k:encrypt ",
+		"/v1/projects/p/keyRings/r/cryptoKeys/k:decrypt ",
+		"/v1/projects/p/keyRings/r/cryptoKeys/k:decrypt ",
+		"/ TrentService.Encrypt",
+		"/ TrentService.Decrypt",
+		"/ TrentService.Decrypt",
+	}, calls)
+	assert.Equal(t, "Bearer token", headers[0].Get("Authorization"))
+	assert.True(t, strings.HasPrefix(headers[3].Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/"), headers[3].Get("Authorization"))
+}
@@ -0,0 +1,115 @@
+package envelope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gcpEndpoint       = "https://cloudkms.googleapis.com"
+	gcpMetadataTokens = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// GCPKMS encrypts DEKs with a Google Cloud KMS key, calling its REST API. The key ID of a value is the key version
+// that encrypted its DEK, and Cloud KMS decrypts with any enabled version of the key, so rotating the key in Cloud KMS
+// only needs the values to be re-encrypted before the old version is disabled.
+type GCPKMS struct {
+	// Key is the resource name of the key, projects/P/locations/L/keyRings/R/cryptoKeys/K.
+	Key string
+	// Token returns the OAuth2 access token requests are authorized with. The default is GCPMetadataToken, the token
+	// of the service account of the VM or pod.
+	Token func(ctx context.Context) (string, error)
+	// Client sends the requests. The default is http.DefaultClient.
+	Client *http.Client
+	// Endpoint is the URL of the API. The default is https://cloudkms.googleapis.com.
+	Endpoint string
+}
+
+func (k *GCPKMS) Encrypt(ctx context.Context, plaintext []byte) (string, []byte, error) {
+	var resp struct {
+		Name       string `json:"name"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := k.call(ctx, "encrypt", map[string][]byte{"plaintext": plaintext}, &resp); err != nil {
+		return "", nil, err
+	}
+	return resp.Name, resp.Ciphertext, nil
+}
+
+func (k *GCPKMS) Decrypt(ctx context.Context, _ string, ciphertext []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := k.call(ctx, "decrypt", map[string][]byte{"ciphertext": ciphertext}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+func (k *GCPKMS) call(ctx context.Context, method string, req, resp any) error {
+	token := k.Token
+	if token == nil {
+		token = GCPMetadataToken()
+	}
+	accessToken, err := token(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a Google Cloud access token: %w", err)
+	}
+
+	endpoint := k.Endpoint
+	if endpoint == "" {
+		endpoint = gcpEndpoint
+	}
+	url := strings.TrimSuffix(endpoint, "/") + "/v1/" + k.Key + ":" + method
+	return postJSON(ctx, k.Client, url, req, resp, func(r *http.Request, _ []byte) error {
+		r.Header.Set("Authorization", "Bearer "+accessToken)
+		return nil
+	})
+}
+
+// GCPMetadataToken returns a token source for GCPKMS that gets the token of the default service account from the
+// metadata server of the VM, or of GKE workload identity, and caches it until shortly before it expires.
+func GCPMetadataToken() func(ctx context.Context) (string, error) {
+	var (
+		lock    sync.Mutex
+		token   string
+		expires time.Time
+	)
+	return func(ctx context.Context) (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if token != "" && time.Now().Before(expires) {
+			return token, nil
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataTokens, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		httpResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("metadata server returned %s", httpResp.Status)
+		}
+
+		var resp struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int64  `json:"expires_in"`
+		}
+		if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+			return "", err
+		}
+		token = resp.AccessToken
+		expires = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - time.Minute)
+		return token, nil
+	}
+}
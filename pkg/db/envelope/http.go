@@ -0,0 +1,46 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody is the number of bytes of the body of a failed response included in the error.
+const maxErrorBody = 1024
+
+// postJSON posts req as JSON to url and decodes the response into resp. prepare is called with the request and its
+// body before it is sent, to set headers and sign it.
+func postJSON(ctx context.Context, client *http.Client, url string, req, resp any, prepare func(*http.Request, []byte) error) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if err := prepare(httpReq, body); err != nil {
+		return err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxErrorBody))
+		return fmt.Errorf("%s returned %s: %s", url, httpResp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
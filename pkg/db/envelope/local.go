@@ -0,0 +1,124 @@
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LocalKey is a key encryption key of a LocalKMS.
+type LocalKey struct {
+	// ID names the key in the values it encrypted. It must not change once used.
+	ID string
+	// Key is the 32 byte AES-256 key.
+	Key []byte
+}
+
+// LocalKMS encrypts DEKs with keys held in memory, such as read from a file or a Kubernetes secret. The first key
+// encrypts new DEKs, the others are only used to decrypt, so a key is rotated by adding a new key first and removing
+// the old one once the values are re-encrypted.
+type LocalKMS struct {
+	keys map[string]LocalKey
+	// primary is the ID of the key new DEKs are encrypted with.
+	primary string
+}
+
+// NewLocalKMS returns a LocalKMS with keys, the first being the one new DEKs are encrypted with.
+func NewLocalKMS(keys ...LocalKey) (*LocalKMS, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+	k := &LocalKMS{
+		keys:    map[string]LocalKey{},
+		primary: keys[0].ID,
+	}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("key ID must not be empty")
+		}
+		if len(key.Key) != dekSize {
+			return nil, fmt.Errorf("key %q must be %d bytes, not %d", key.ID, dekSize, len(key.Key))
+		}
+		if _, ok := k.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key.ID)
+		}
+		k.keys[key.ID] = key
+	}
+	return k, nil
+}
+
+// LoadLocalKMS reads the keys of a LocalKMS from a file with a key per line of the form ID=BASE64KEY, the first being
+// the one new DEKs are encrypted with. Empty lines and lines starting with # are ignored.
+func LoadLocalKMS(path string) (*LocalKMS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		keys    []LocalKey
+		scanner = bufio.NewScanner(bytes.NewReader(data))
+		lineNum int
+	)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected ID=BASE64KEY", path, lineNum)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid key: %w", path, lineNum, err)
+		}
+		keys = append(keys, LocalKey{ID: strings.TrimSpace(id), Key: key})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewLocalKMS(keys...)
+}
+
+// NewLocalKey returns a new random key with the given ID.
+func NewLocalKey(id string) (LocalKey, error) {
+	key := make([]byte, dekSize)
+	if _, err := rand.Read(key); err != nil {
+		return LocalKey{}, err
+	}
+	return LocalKey{ID: id, Key: key}, nil
+}
+
+func (k *LocalKMS) Encrypt(_ context.Context, plaintext []byte) (string, []byte, error) {
+	aead, err := newAEAD(k.keys[k.primary].Key)
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.primary, aead.Seal(nonce, nonce, plaintext, []byte(k.primary)), nil
+}
+
+func (k *LocalKMS) Decrypt(_ context.Context, keyID string, ciphertext []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	aead, err := newAEAD(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], []byte(keyID))
+}
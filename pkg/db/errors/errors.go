@@ -1,17 +1,31 @@
 package errors
 
 import (
+	goerrors "errors"
 	"fmt"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 const (
 	OptimisticLockErrorMsg = "the object has been modified; please apply your changes to the latest version and try again"
 )
 
+// The types of the causes in the details of Conflict and Expired errors, which tell the reasons of these errors apart.
+// Each cause has the field the reason applies to and a message of the form "<what>: <value>".
+const (
+	// CauseTypeResourceVersionMismatch causes carry the requested resource version and the current resource version
+	// of the object, which is 0 if the object no longer exists.
+	CauseTypeResourceVersionMismatch metav1.CauseType = "ResourceVersionMismatch"
+	// CauseTypeUIDMismatch causes carry the stored and the given UID of the object.
+	CauseTypeUIDMismatch metav1.CauseType = "UIDMismatch"
+	// CauseTypeCompacted causes carry the requested resource version and the current compaction ID.
+	CauseTypeCompacted metav1.CauseType = "Compacted"
+)
+
 func NewConflict(gvk schema.GroupVersionKind, name string, err error) error {
 	return apierrors.NewConflict(
 		schema.GroupResource{
@@ -36,19 +50,182 @@ func NewNotFound(gvk schema.GroupVersionKind, name string) error {
 		}, name)
 }
 
+// NewCompactionError returns an Expired error for a read at the requested resource version, which was compacted.
 func NewCompactionError(requested, current uint) error {
-	return apierrors.NewResourceExpired(fmt.Sprintf("resource version %d before current compaction %d", requested, current))
+	err := apierrors.NewResourceExpired(fmt.Sprintf("resource version %d before current compaction %d", requested, current))
+	err.ErrStatus.Details = &metav1.StatusDetails{
+		Causes: []metav1.StatusCause{
+			{
+				Type:    CauseTypeCompacted,
+				Field:   "metadata.resourceVersion",
+				Message: fmt.Sprintf("requested: %d", requested),
+			},
+			{
+				Type:    CauseTypeCompacted,
+				Field:   "metadata.resourceVersion",
+				Message: fmt.Sprintf("compacted: %d", current),
+			},
+		},
+	}
+	return err
+}
+
+// IsCompacted returns true if err is an Expired error returned by NewCompactionError.
+func IsCompacted(err error) bool {
+	return apierrors.IsResourceExpired(err) && hasCause(err, CauseTypeCompacted)
+}
+
+// NewUIDMismatch returns a Conflict error for a write of an object whose UID differs from the UID of the stored
+// object, as when the object was deleted and created again since it was read. The stored and given UIDs are returned
+// as causes for the field metadata.uid.
+func NewUIDMismatch(gvk schema.GroupVersionKind, name, oldUID, newUID string) error {
+	err := NewConflict(gvk, name, fmt.Errorf("UID in object meta %q doesn't match the stored UID %q", newUID, oldUID)).(*apierrors.StatusError)
+	err.ErrStatus.Details.Causes = []metav1.StatusCause{
+		{
+			Type:    CauseTypeUIDMismatch,
+			Field:   "metadata.uid",
+			Message: "stored: " + oldUID,
+		},
+		{
+			Type:    CauseTypeUIDMismatch,
+			Field:   "metadata.uid",
+			Message: "given: " + newUID,
+		},
+	}
+	return err
+}
+
+// IsUIDMismatch returns true if err is a Conflict error returned by NewUIDMismatch.
+func IsUIDMismatch(err error) bool {
+	return apierrors.IsConflict(err) && hasCause(err, CauseTypeUIDMismatch)
+}
+
+// NewResourceVersionMismatch returns a Conflict error for a write of an object at the requested resource version,
+// which is not the current resource version of the object. current is 0 if the object no longer exists.
+func NewResourceVersionMismatch(gvk schema.GroupVersionKind, name string, requested, current int64) error {
+	err := NewConflict(gvk, name, fmt.Errorf(OptimisticLockErrorMsg)).(*apierrors.StatusError)
+	err.ErrStatus.Details.Causes = []metav1.StatusCause{
+		{
+			Type:    CauseTypeResourceVersionMismatch,
+			Field:   "metadata.resourceVersion",
+			Message: fmt.Sprintf("requested: %d", requested),
+		},
+		{
+			Type:    CauseTypeResourceVersionMismatch,
+			Field:   "metadata.resourceVersion",
+			Message: fmt.Sprintf("current: %d", current),
+		},
+	}
+	return err
+}
+
+// IsResourceVersionMismatch returns true if err is a Conflict error returned by NewResourceVersionMismatch.
+func IsResourceVersionMismatch(err error) bool {
+	return apierrors.IsConflict(err) && hasCause(err, CauseTypeResourceVersionMismatch)
+}
+
+func hasCause(err error, causeType metav1.CauseType) bool {
+	var status apierrors.APIStatus
+	if !goerrors.As(err, &status) || status.Status().Details == nil {
+		return false
+	}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == causeType {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDuplicateValue returns an Invalid error for a write of the named object that gives the field at path the value
+// already held by the object ownerNamespace/ownerName.
+func NewDuplicateValue(gvk schema.GroupVersionKind, name, path, value, ownerNamespace, ownerName string) error {
+	owner := ownerName
+	if ownerNamespace != "" {
+		owner = ownerNamespace + "/" + ownerName
+	}
+	return apierrors.NewInvalid(gvk.GroupKind(), name, field.ErrorList{
+		{
+			Type:     field.ErrorTypeDuplicate,
+			Field:    path,
+			BadValue: value,
+			Detail:   fmt.Sprintf("already used by %s %s", gvk.Kind, owner),
+		},
+	})
+}
+
+func NewPartitionRequired(gvk schema.GroupVersionKind, name string) error {
+	return apierrors.NewBadRequest(fmt.Sprintf("a partition ID is required to write %s %s", gvk.Kind, name))
+}
+
+// NewQuotaExceeded returns a Forbidden error for a write of the named object that would exceed the quota of its
+// namespace, described by detail.
+func NewQuotaExceeded(gvk schema.GroupVersionKind, namespace, name, detail string) error {
+	scope := "cluster"
+	if namespace != "" {
+		scope = "namespace " + namespace
+	}
+	return apierrors.NewForbidden(
+		schema.GroupResource{
+			Group:    gvk.Group,
+			Resource: gvk.Kind,
+		}, name, fmt.Errorf("exceeded quota of %s in %s: %s", gvk.Kind, scope, detail))
+}
+
+// DatabaseUnreachableError is returned when the database can't be reached while creating a factory.
+type DatabaseUnreachableError struct {
+	Err error
+}
+
+func (e *DatabaseUnreachableError) Error() string {
+	return fmt.Sprintf("database is unreachable: %v", e.Err)
+}
+
+func (e *DatabaseUnreachableError) Unwrap() error {
+	return e.Err
+}
+
+func NewDatabaseUnreachable(err error) error {
+	return &DatabaseUnreachableError{Err: err}
+}
+
+// IsDatabaseUnreachable returns true if err is or wraps a DatabaseUnreachableError.
+func IsDatabaseUnreachable(err error) bool {
+	var unreachable *DatabaseUnreachableError
+	return goerrors.As(err, &unreachable)
+}
+
+func NewWatcherTooSlow(gvk schema.GroupVersionKind) error {
+	return apierrors.NewResourceExpired(fmt.Sprintf("watch of %s closed because the client is not keeping up, list and watch again", gvk.Kind))
+}
+
+// CorruptValueError is returned when a stored value doesn't match the checksum written with it.
+type CorruptValueError struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	ID               int64
+}
+
+func (e *CorruptValueError) Error() string {
+	name := e.Name
+	if e.Namespace != "" {
+		name = e.Namespace + "/" + name
+	}
+	return fmt.Sprintf("stored value of %s %s at resource version %d is corrupt: checksum mismatch", e.GroupVersionKind.Kind, name, e.ID)
 }
 
-func NewUIDMismatch(name, oldUID, newUID string) error {
-	err := fmt.Sprintf(
-		"Precondition failed: UID in precondition: %v, UID in object meta: %v", oldUID, newUID)
-	return storage.NewInvalidObjError(name, err)
+func NewCorruptValue(gvk schema.GroupVersionKind, namespace, name string, id int64) error {
+	return &CorruptValueError{
+		GroupVersionKind: gvk,
+		Namespace:        namespace,
+		Name:             name,
+		ID:               id,
+	}
 }
 
-func NewResourceVersionMismatch(gvk schema.GroupVersionKind, name string) error {
-	return apierrors.NewConflict(schema.GroupResource{
-		Group:    gvk.Group,
-		Resource: gvk.Kind,
-	}, name, fmt.Errorf(OptimisticLockErrorMsg))
+// IsCorruptValue returns true if err is or wraps a CorruptValueError.
+func IsCorruptValue(err error) bool {
+	var corrupt *CorruptValueError
+	return goerrors.As(err, &corrupt)
 }
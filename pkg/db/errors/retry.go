@@ -0,0 +1,117 @@
+package errors
+
+import (
+	"context"
+	"database/sql/driver"
+	goerrors "errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// conflictBackoff matches the first step of the default retry of k8s.io/client-go/util/retry.
+	conflictBackoff      = 10 * time.Millisecond
+	serializationBackoff = 50 * time.Millisecond
+	busyBackoff          = 100 * time.Millisecond
+	connectionBackoff    = time.Second
+)
+
+// Result codes of sqlite, the extended result codes are the primary code in the low byte.
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// sqlState is implemented by the errors of the Postgres drivers.
+type sqlState interface {
+	SQLState() string
+}
+
+// sqliteCode is implemented by the errors of the sqlite driver.
+type sqliteCode interface {
+	Code() int
+}
+
+// IsRetryable returns true if the operation that failed with err may succeed if it is retried, possibly after
+// reading the object or listing again:
+//
+//   - conflicts, such as a stale resource version or UID mismatch; get the object and apply the change again
+//   - compaction and other expired resource version errors; list again from the latest revision
+//   - rate limiting and server timeouts
+//   - Postgres serialization failures and deadlocks
+//   - sqlite busy and locked errors
+//   - dropped and refused database connections, and an unreachable database
+//
+// Errors from a canceled context or an expired deadline are not retryable.
+func IsRetryable(err error) bool {
+	_, ok := classify(err)
+	return ok
+}
+
+// SuggestedBackoff returns how long to wait before retrying an operation that failed with err, or zero if err is not
+// retryable or the operation can be retried right away. The backoff is a starting point for retries spread over a
+// wait.Backoff or similar; it honors the Retry-After of rate limiting errors.
+func SuggestedBackoff(err error) time.Duration {
+	backoff, _ := classify(err)
+	return backoff
+}
+
+func classify(err error) (time.Duration, bool) {
+	if err == nil || goerrors.Is(err, context.Canceled) || goerrors.Is(err, context.DeadlineExceeded) {
+		return 0, false
+	}
+
+	switch {
+	case apierrors.IsConflict(err):
+		return conflictBackoff, true
+	case apierrors.IsResourceExpired(err), apierrors.IsGone(err):
+		return 0, true
+	case apierrors.IsTooManyRequests(err), apierrors.IsServerTimeout(err), apierrors.IsTimeout(err):
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok && seconds > 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		return connectionBackoff, true
+	case IsDatabaseUnreachable(err):
+		return connectionBackoff, true
+	}
+
+	var state sqlState
+	if goerrors.As(err, &state) {
+		switch code := state.SQLState(); {
+		// serialization_failure, deadlock_detected
+		case code == "40001", code == "40P01":
+			return serializationBackoff, true
+		// lock_not_available
+		case code == "55P03":
+			return busyBackoff, true
+		// connection_exception, admin_shutdown, crash_shutdown, cannot_connect_now
+		case strings.HasPrefix(code, "08"), code == "57P01", code == "57P02", code == "57P03":
+			return connectionBackoff, true
+		}
+		return 0, false
+	}
+
+	var sqlite sqliteCode
+	if goerrors.As(err, &sqlite) {
+		if code := sqlite.Code() & 0xff; code == sqliteBusy || code == sqliteLocked {
+			return busyBackoff, true
+		}
+		return 0, false
+	}
+
+	var netErr net.Error
+	if goerrors.Is(err, driver.ErrBadConn) ||
+		goerrors.Is(err, io.ErrUnexpectedEOF) ||
+		goerrors.Is(err, syscall.ECONNRESET) ||
+		goerrors.Is(err, syscall.ECONNREFUSED) ||
+		goerrors.Is(err, syscall.EPIPE) ||
+		goerrors.As(err, &netErr) {
+		return connectionBackoff, true
+	}
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package errors
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var testGVK = schema.GroupVersionKind{
+	Group:   "testgroup",
+	Version: "testversion",
+	Kind:    "TestKind",
+}
+
+type sqliteError int
+
+func (e sqliteError) Error() string {
+	return fmt.Sprintf("sqlite error %d", int(e))
+}
+
+func (e sqliteError) Code() int {
+	return int(e)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		backoff time.Duration
+	}{
+		{"conflict", NewResourceVersionMismatch(testGVK, "name", 1, 2), 10 * time.Millisecond},
+		{"compaction", NewCompactionError(1, 2), 0},
+		{"rate limit", apierrors.NewTooManyRequests("slow down", 3), 3 * time.Second},
+		{"serialization failure", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "40001"}), 50 * time.Millisecond},
+		{"postgres shutdown", &pgconn.PgError{Code: "57P01"}, time.Second},
+		{"sqlite busy", sqliteError(5), 100 * time.Millisecond},
+		{"sqlite busy snapshot", sqliteError(517), 100 * time.Millisecond},
+		{"bad connection", fmt.Errorf("query: %w", driver.ErrBadConn), time.Second},
+		{"unreachable", NewDatabaseUnreachable(io.EOF), time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.True(t, IsRetryable(test.err))
+			assert.Equal(t, test.backoff, SuggestedBackoff(test.err))
+		})
+	}
+
+	for _, err := range []error{
+		nil,
+		NewAlreadyExists(testGVK, "name"),
+		NewNotFound(testGVK, "name"),
+		NewCorruptValue(testGVK, "", "name", 1),
+		&pgconn.PgError{Code: "23505"},
+		sqliteError(2067),
+		fmt.Errorf("query: %w", context.Canceled),
+	} {
+		assert.False(t, IsRetryable(err), "%v", err)
+		assert.Zero(t, SuggestedBackoff(err), "%v", err)
+	}
+}
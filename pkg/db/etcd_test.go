@@ -0,0 +1,302 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/etcd"
+	kinmtypes "github.com/obot-platform/kinm/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+func TestEtcdServer(t *testing.T) {
+	s := newStrategy(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		_ = etcd.NewServer(s, "/registry/testkinds", true).Serve(ctx, listener)
+	}()
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{listener.Addr().String()},
+		DialTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	resp, err := client.Get(ctx, "/registry/testkinds/", clientv3.WithPrefix())
+	require.NoError(t, err)
+	require.Len(t, resp.Kvs, 3)
+	assert.Equal(t, "/registry/testkinds/testnamespace1/testname1", string(resp.Kvs[0].Key))
+	assert.EqualValues(t, 1, resp.Kvs[0].ModRevision)
+	assert.EqualValues(t, 3, resp.Header.Revision)
+
+	resp, err = client.Get(ctx, "/registry/testkinds/testnamespace2/", clientv3.WithPrefix())
+	require.NoError(t, err)
+	require.Len(t, resp.Kvs, 1)
+	assert.Equal(t, "/registry/testkinds/testnamespace2/testname2", string(resp.Kvs[0].Key))
+
+	watch := client.Watch(ctx, "/registry/testkinds/", clientv3.WithPrefix(), clientv3.WithRev(4), clientv3.WithPrevKV())
+
+	// Create the key only if it doesn't exist, the way kube-apiserver does
+	value, err := json.Marshal(&TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "testname4", Namespace: "testnamespace4", UID: "testuid4"},
+		Value:      "testvalue4",
+	})
+	require.NoError(t, err)
+	key := "/registry/testkinds/testnamespace4/testname4"
+	txn, err := client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	require.NoError(t, err)
+	assert.True(t, txn.Succeeded)
+	assert.EqualValues(t, 4, txn.Header.Revision)
+
+	// A stale revision runs the failure branch
+	txn, err = client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", 1)).
+		Then(clientv3.OpPut(key, string(value))).
+		Else(clientv3.OpGet(key)).
+		Commit()
+	require.NoError(t, err)
+	assert.False(t, txn.Succeeded)
+	require.Len(t, txn.Responses[0].GetResponseRange().Kvs, 1)
+	assert.EqualValues(t, 4, txn.Responses[0].GetResponseRange().Kvs[0].ModRevision)
+
+	txn, err = client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", 4)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	require.NoError(t, err)
+	assert.True(t, txn.Succeeded)
+
+	var events []*clientv3.Event
+	for len(events) < 2 {
+		select {
+		case watchResp := <-watch:
+			require.NoError(t, watchResp.Err())
+			events = append(events, watchResp.Events...)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for watch events")
+		}
+	}
+	assert.True(t, events[0].IsCreate())
+	assert.Equal(t, key, string(events[0].Kv.Key))
+	assert.EqualValues(t, 4, events[0].Kv.ModRevision)
+	assert.Equal(t, clientv3.EventTypeDelete, events[1].Type)
+	assert.EqualValues(t, 5, events[1].Kv.ModRevision)
+	require.NotNil(t, events[1].PrevKv)
+
+	var deleted TestKind
+	require.NoError(t, json.Unmarshal(events[1].PrevKv.Value, &deleted))
+	assert.Equal(t, "testvalue4", deleted.Value)
+
+	resp, err = client.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Kvs)
+}
+
+// listRecorder records the options of the lists of a strategy.
+type listRecorder struct {
+	*Strategy
+
+	lock  sync.Mutex
+	lists []storage.ListOptions
+}
+
+func (l *listRecorder) List(ctx context.Context, namespace string, opts storage.ListOptions) (kinmtypes.ObjectList, error) {
+	l.lock.Lock()
+	l.lists = append(l.lists, opts)
+	l.lock.Unlock()
+	return l.Strategy.List(ctx, namespace, opts)
+}
+
+// scans returns the number of lists that read every object of a namespace.
+func (l *listRecorder) scans() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var result int
+	for _, opts := range l.lists {
+		if opts.Predicate.Limit != 1 {
+			result++
+		}
+	}
+	return result
+}
+
+func TestEtcdServerRevision(t *testing.T) {
+	s := &listRecorder{Strategy: newStrategy(t)}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		_ = etcd.NewServer(s, "/registry/testkinds", true).Serve(ctx, listener)
+	}()
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{listener.Addr().String()},
+		DialTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// A key deleted since is read at the revision
+	key := "/registry/testkinds/testnamespace1/testname1"
+	_, err = client.Delete(ctx, key)
+	require.NoError(t, err)
+	resp, err := client.Get(ctx, key, clientv3.WithRev(3))
+	require.NoError(t, err)
+	require.Len(t, resp.Kvs, 1)
+	assert.Equal(t, key, string(resp.Kvs[0].Key))
+	assert.EqualValues(t, 1, resp.Kvs[0].ModRevision)
+	resp, err = client.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Kvs)
+
+	// Reading a key at a future revision fails like etcd
+	_, err = client.Get(ctx, key, clientv3.WithRev(100))
+	assert.ErrorContains(t, err, "future revision")
+
+	// Single keys are read without listing their namespace
+	assert.Zero(t, s.scans())
+
+	// Keys outside the prefix are never objects of the table
+	_, err = client.Put(ctx, "compact_rev_key", "4")
+	require.NoError(t, err)
+	resp, err = client.Get(ctx, "compact_rev_key", clientv3.WithRev(4))
+	require.NoError(t, err)
+	require.Len(t, resp.Kvs, 1)
+	assert.Equal(t, "compact_rev_key", string(resp.Kvs[0].Key))
+	assert.Equal(t, "4", string(resp.Kvs[0].Value))
+	resp, err = client.Get(ctx, "compact_rev_key", clientv3.WithRev(3))
+	require.NoError(t, err)
+	assert.Empty(t, resp.Kvs)
+
+	// Reading only the key doesn't drop the stored value
+	_, err = client.Get(ctx, "compact_rev_key", clientv3.WithKeysOnly())
+	require.NoError(t, err)
+	resp, err = client.Get(ctx, "compact_rev_key")
+	require.NoError(t, err)
+	require.Len(t, resp.Kvs, 1)
+	assert.Equal(t, "4", string(resp.Kvs[0].Value))
+
+	// Protobuf values are rejected with a hint
+	_, err = client.Put(ctx, "/registry/testkinds/testnamespace5/testname5", "k8s\x00\x0a\x0f")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--storage-media-type=application/json")
+}
+
+func TestEtcdImportExport(t *testing.T) {
+	src := newStrategy(t)
+
+	newTable := func(name string) *Strategy {
+		dropTable(t, src.db.sqlDB, name)
+		s, err := New(ctx, src.db.sqlDB, testGVK, src.Scheme(), name)
+		require.NoError(t, err)
+		return s
+	}
+	serve := func(s *Strategy) *clientv3.Client {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		go func() {
+			_ = etcd.NewServer(s, "/registry/testkinds", true).Serve(ctx, listener)
+		}()
+
+		client, err := clientv3.New(clientv3.Config{
+			Endpoints:   []string{listener.Addr().String()},
+			DialTimeout: 5 * time.Second,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = client.Close() })
+		return client
+	}
+
+	// Import from etcd keeps the order of the source and is idempotent
+	imported := newTable("etcdimport")
+	importer := etcd.NewServer(imported, "/registry/testkinds", true)
+	result, err := importer.Import(ctx, etcd.NewEtcdSource(serve(src)))
+	require.NoError(t, err)
+	assert.Equal(t, etcd.ImportResult{Imported: 3}, result)
+
+	for i := 1; i <= 3; i++ {
+		suffix := strconv.Itoa(i)
+		obj, err := imported.Get(ctx, "testnamespace"+suffix, "testname"+suffix)
+		require.NoError(t, err)
+		assert.Equal(t, suffix, obj.GetResourceVersion())
+		assert.EqualValues(t, "testuid"+suffix, obj.GetUID())
+		assert.Equal(t, "testvalue"+suffix, obj.(*TestKind).Value)
+	}
+
+	result, err = importer.Import(ctx, etcd.NewEtcdSource(serve(src)))
+	require.NoError(t, err)
+	assert.Equal(t, etcd.ImportResult{Existing: 3}, result)
+
+	// Export writes every object to etcd
+	exported := newTable("etcdexport")
+	count, err := importer.Export(ctx, serve(exported))
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	obj, err := exported.Get(ctx, "testnamespace2", "testname2")
+	require.NoError(t, err)
+	assert.EqualValues(t, "testuid2", obj.GetUID())
+	assert.Equal(t, "testvalue2", obj.(*TestKind).Value)
+
+	// Import from a kine table uses the latest row of each key
+	_, err = src.db.sqlDB.ExecContext(ctx, "DROP TABLE IF EXISTS kine")
+	require.NoError(t, err)
+	_, err = src.db.sqlDB.ExecContext(ctx, "CREATE TABLE kine (id INTEGER PRIMARY KEY, name TEXT, deleted INTEGER, value TEXT)")
+	require.NoError(t, err)
+	for i, row := range []struct {
+		name    string
+		deleted int
+		value   string
+	}{
+		{"/registry/testkinds/testnamespace1/testname1", 0, "testvalue1"},
+		{"/registry/testkinds/testnamespace1/testname1", 0, "testvalue2"},
+		{"/registry/testkinds/testnamespace2/testname2", 0, "testvalue3"},
+		{"/registry/testkinds/testnamespace2/testname2", 1, "testvalue3"},
+		{"/registry/testkinds/testnamespace3/testname3/extra", 0, "testvalue4"},
+		{"/registry/otherkinds/testnamespace4/testname4", 0, "testvalue5"},
+	} {
+		namespace, name, _ := strings.Cut(strings.TrimPrefix(row.name, "/registry/testkinds/"), "/")
+		value, err := json.Marshal(&TestKind{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: "kineuid"},
+			Value:      row.value,
+		})
+		require.NoError(t, err)
+		_, err = src.db.sqlDB.ExecContext(ctx, "INSERT INTO kine (id, name, deleted, value) VALUES ($1, $2, $3, $4)",
+			i+1, row.name, row.deleted, string(value))
+		require.NoError(t, err)
+	}
+
+	kine := newTable("kineimport")
+	result, err = etcd.NewServer(kine, "/registry/testkinds", true).Import(ctx, etcd.NewKineSource(src.db.sqlDB, "kine"))
+	require.NoError(t, err)
+	assert.Equal(t, etcd.ImportResult{Imported: 1, Skipped: 1}, result)
+
+	obj, err = kine.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	assert.Equal(t, "testvalue2", obj.(*TestKind).Value)
+}
@@ -4,20 +4,29 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"github.com/obot-platform/kinm/pkg/db/errors"
 	"github.com/obot-platform/kinm/pkg/db/glogrus"
+	"github.com/obot-platform/kinm/pkg/db/gslog"
+	"github.com/obot-platform/kinm/pkg/db/statements"
 	"github.com/obot-platform/kinm/pkg/strategy"
 	"github.com/obot-platform/kinm/pkg/types"
-	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apiserver/pkg/storage/value"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
@@ -28,12 +37,50 @@ type Factory struct {
 	migrationTimeout    time.Duration
 	transformers        map[schema.GroupKind]value.Transformer
 	partitionIDRequired bool
+	undelete            bool
+	maxIdleConns        int
+	maxOpenConns        int
+	logger              *slog.Logger
+	slowQueryThreshold  time.Duration
+	strategyOptions     []Option
+	schemaName          string
+	connectBackoff      wait.Backoff
+	waitForReady        time.Duration
+	health              health
+	strategiesLock      sync.Mutex
+	strategies          []*Strategy
+	changes             broadcaster
+	backups             BackupStore
+	backupOptions       BackupOptions
+	replicaTarget       ReplicaTarget
+	replicationOptions  ReplicationOptions
+	replicator          *replicator
+	replicaDSNs         []string
+	replicas            *replicaPool
+	failover            *FailoverOptions
+	sqliteEncryption    *SQLiteEncryption
+	sqliteConnector     *keyConnector
+	// dialect is the SQL dialect of the database, known from the DSN
+	dialect           statements.Dialect
+	failoverConnector *failoverConnector
+	// ctx is canceled to stop the background work of the factory
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func NewFactory(schema *runtime.Scheme, dsn string) (*Factory, error) {
+func NewFactory(schema *runtime.Scheme, dsn string, opts ...FactoryOption) (*Factory, error) {
 	f := &Factory{
 		schema: schema,
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.logger == nil {
+		f.logger = slog.New(glogrus.NewHandler(nil))
+	}
+	if f.slowQueryThreshold == 0 {
+		f.slowQueryThreshold = 200 * time.Millisecond
+	}
 
 	var (
 		gdb                    gorm.Dialector
@@ -41,26 +88,85 @@ func NewFactory(schema *runtime.Scheme, dsn string) (*Factory, error) {
 		skipDefaultTransaction bool
 	)
 	if strings.HasPrefix(dsn, "sqlite://") {
+		f.dialect = statements.SQLite
 		skipDefaultTransaction = true
-		gdb = sqlite.Open(strings.TrimPrefix(dsn, "sqlite://"))
-	} else if strings.HasPrefix(dsn, "postgres://") {
+		path := strings.TrimPrefix(dsn, "sqlite://")
+		if f.replicaTarget != nil {
+			var err error
+			if path, err = f.prepareReplication(path); err != nil {
+				return nil, err
+			}
+		}
+		if f.sqliteEncryption != nil {
+			if f.replicaTarget != nil {
+				return nil, fmt.Errorf("WAL replication is not supported for encrypted sqlite databases")
+			}
+			var err error
+			if f.sqliteConnector, err = newKeyConnector(path, *f.sqliteEncryption); err != nil {
+				return nil, err
+			}
+		}
+		gdb = sqlite.Open(path)
+	} else if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		dsn, err := f.postgresDSN(strings.Replace(dsn, "postgresql://", "postgres://", 1))
+		if err != nil {
+			return nil, err
+		}
 		gdb = postgres.Open(dsn)
 		pool = true
-	} else if strings.HasPrefix(dsn, "postgresql://") {
-		gdb = postgres.Open(strings.Replace(dsn, "postgresql://", "postgres://", 1))
-		pool = true
+		f.dialect = statements.Postgres
+		if f.failover != nil {
+			if f.failoverConnector, err = f.newFailoverConnector(dsn); err != nil {
+				return nil, err
+			}
+		}
 	} else {
 		return nil, fmt.Errorf("unsupported database: %s", dsn)
 	}
-	db, err := gorm.Open(gdb, &gorm.Config{
+	if !pool && f.schemaName != "" {
+		return nil, fmt.Errorf("schemas are not supported for sqlite")
+	}
+	if pool && f.sqliteEncryption != nil {
+		return nil, fmt.Errorf("encryption is only supported for sqlite")
+	}
+	if !pool && f.failover != nil {
+		return nil, fmt.Errorf("failover is only supported for Postgres")
+	}
+	if pool && f.replicaTarget != nil {
+		return nil, fmt.Errorf("WAL replication is only supported for sqlite")
+	}
+	if !pool && len(f.replicaDSNs) > 0 {
+		return nil, fmt.Errorf("read replicas are only supported for Postgres")
+	}
+
+	config := &gorm.Config{
 		SkipDefaultTransaction: skipDefaultTransaction,
-		Logger: glogrus.New(glogrus.Config{
-			SlowThreshold:             200 * time.Millisecond,
+		Logger: gslog.New(gslog.Config{
+			Logger:                    f.logger,
+			SlowThreshold:             f.slowQueryThreshold,
 			IgnoreRecordNotFoundError: true,
 			LogSQL:                    true,
 		}),
-	})
-	if err != nil {
+	}
+
+	// gorm.Open pings the database, so retry it until the database is reachable
+	var db *gorm.DB
+	if err := f.waitForDatabase(func() (err error) {
+		if f.failoverConnector != nil {
+			// A failed attempt closes the connection pool, so each attempt needs its own
+			gdb = postgres.New(postgres.Config{Conn: sql.OpenDB(f.failoverConnector)})
+		}
+		if f.sqliteConnector != nil {
+			gdb = &sqlite.Dialector{Conn: sql.OpenDB(f.sqliteConnector)}
+		}
+		db, err = gorm.Open(gdb, config)
+		if err != nil && db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				_ = sqlDB.Close()
+			}
+		}
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
@@ -69,18 +175,130 @@ func NewFactory(schema *runtime.Scheme, dsn string) (*Factory, error) {
 		return nil, err
 	}
 	sqlDB.SetConnMaxLifetime(time.Minute * 3)
-	if pool {
-		sqlDB.SetMaxIdleConns(5)
-		sqlDB.SetMaxOpenConns(5)
-	} else {
-		sqlDB.SetMaxIdleConns(1)
-		sqlDB.SetMaxOpenConns(1)
+	if !pool && f.maxOpenConns > 1 {
+		return nil, fmt.Errorf("sqlite only supports a single open connection, got %d", f.maxOpenConns)
+	}
+	if f.maxOpenConns == 0 {
+		f.maxIdleConns, f.maxOpenConns = 1, 1
+		if pool {
+			f.maxIdleConns, f.maxOpenConns = 5, 5
+		}
+	}
+	sqlDB.SetMaxIdleConns(f.maxIdleConns)
+	sqlDB.SetMaxOpenConns(f.maxOpenConns)
+
+	if f.sqliteEncryption != nil {
+		if err := verifySQLiteKey(context.Background(), sqlDB, f.sqliteEncryption.DriverName); err != nil {
+			_ = sqlDB.Close()
+			return nil, err
+		}
+	}
+
+	if f.schemaName != "" {
+		if _, err := sqlDB.Exec(fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS "%s"`, f.schemaName)); err != nil {
+			return nil, fmt.Errorf("failed to create schema %q: %w", f.schemaName, err)
+		}
+	}
+	if len(f.replicaDSNs) > 0 {
+		if f.replicas, err = f.openReplicas(); err != nil {
+			return nil, err
+		}
 	}
 	f.DB = db
 	f.SQLDB = sqlDB
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.ctx, f.cancel = ctx, cancel
+	if f.backups != nil && f.backupOptions.Interval > 0 {
+		go f.runBackups(ctx)
+	}
+	if f.failoverConnector != nil {
+		go f.failoverConnector.runChecks(ctx, f.failover.CheckInterval)
+	}
+	if f.replicaTarget != nil {
+		path, _, _ := strings.Cut(strings.TrimPrefix(dsn, "sqlite://"), "?")
+		if f.replicator, err = newReplicator(path, f.replicaTarget, f.replicationOptions, sqlDB); err != nil {
+			cancel()
+			return nil, err
+		}
+		go f.replicator.run(ctx)
+	}
 	return f, nil
 }
 
+// Close stops the background work of the factory, such as scheduled backups, and closes the database. With WAL
+// replication, the frames written since the last sync are shipped first.
+func (f *Factory) Close() error {
+	f.cancel()
+	if f.replicator != nil {
+		if err := f.replicator.sync(context.Background()); err != nil {
+			klog.Errorf("failed to replicate on close: %v", err)
+		}
+	}
+	err := f.SQLDB.Close()
+	if f.replicas != nil {
+		if closeErr := f.replicas.close(); err == nil {
+			err = closeErr
+		}
+	}
+	if f.replicator != nil {
+		if closeErr := f.replicator.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// waitForDatabase calls connect until it succeeds, retrying according to the configured backoff and wait timeout. By
+// default connect is only called once.
+func (f *Factory) waitForDatabase(connect func() error) error {
+	ctx := context.Background()
+	backoff := f.connectBackoff
+	if f.waitForReady > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.waitForReady)
+		defer cancel()
+		if backoff.Steps == 0 {
+			backoff = defaultWaitForReadyBackoff
+		}
+	}
+	if backoff.Steps == 0 {
+		backoff.Steps = 1
+	}
+
+	var lastErr error
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		if lastErr = connect(); lastErr != nil {
+			f.logger.Warn("Failed to connect to database", "error", lastErr)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		if lastErr == nil {
+			lastErr = err
+		}
+		return errors.NewDatabaseUnreachable(lastErr)
+	}
+	return nil
+}
+
+// postgresDSN sets the search_path of every connection so that unqualified table names resolve to the configured
+// schema.
+func (f *Factory) postgresDSN(dsn string) (string, error) {
+	if f.schemaName == "" {
+		return dsn, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse postgres dsn: %w", err)
+	}
+	q := u.Query()
+	q.Set("search_path", f.schemaName)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
 func (f *Factory) Scheme() *runtime.Scheme {
 	return f.schema
 }
@@ -92,7 +310,7 @@ func (f *Factory) Name() string {
 func (f *Factory) Check(req *http.Request) error {
 	err := f.SQLDB.PingContext(req.Context())
 	if err != nil {
-		logrus.Warnf("Failed to ping database: %v", err)
+		f.logger.Warn("Failed to ping database", "error", err)
 	}
 
 	return err
@@ -102,7 +320,7 @@ type TableNamer interface {
 	TableName() string
 }
 
-func (f *Factory) NewDBStrategy(obj types.Object) (strategy.CompleteStrategy, error) {
+func (f *Factory) NewDBStrategy(obj types.Object, opts ...Option) (strategy.CompleteStrategy, error) {
 	gvk, err := apiutil.GVKForObject(obj, f.schema)
 	if err != nil {
 		return nil, err
@@ -112,7 +330,15 @@ func (f *Factory) NewDBStrategy(obj types.Object) (strategy.CompleteStrategy, er
 	if tn, ok := obj.(TableNamer); ok {
 		tableName = tn.TableName()
 	}
+	s, err := f.newStrategy(gvk, tableName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
 
+// newStrategy creates the strategy for gvk stored in tableName, with the options of the factory followed by opts.
+func (f *Factory) newStrategy(gvk schema.GroupVersionKind, tableName string, opts ...Option) (*Strategy, error) {
 	ctx := context.Background()
 	if f.migrationTimeout != 0 {
 		// If configured, set a timeout for the migration
@@ -120,5 +346,63 @@ func (f *Factory) NewDBStrategy(obj types.Object) (strategy.CompleteStrategy, er
 		ctx, cancel = context.WithTimeout(ctx, f.migrationTimeout)
 		defer cancel()
 	}
-	return New(ctx, f.SQLDB, gvk, f.schema, tableName)
+	strategyOpts := append([]Option{WithQueryLogger(f.logger, f.slowQueryThreshold)}, f.strategyOptions...)
+	if f.partitionIDRequired {
+		strategyOpts = append(strategyOpts, func(s *Strategy) {
+			s.db.partitionRequired = true
+		})
+	}
+	strategyOpts = append(strategyOpts, func(s *Strategy) {
+		s.onChange = f.changes.notify
+		s.onDestroy = func() {
+			f.removeStrategy(s)
+		}
+		s.dialect = f.dialect
+	})
+	if f.replicas != nil {
+		strategyOpts = append(strategyOpts, func(s *Strategy) {
+			s.db.replicas = f.replicas
+			s.db.written = new(atomic.Int64)
+		})
+	}
+	if transformer, ok := f.transformers[gvk.GroupKind()]; ok {
+		strategyOpts = append(strategyOpts, WithValueTransformer(transformer))
+	}
+	s, err := New(ctx, f.SQLDB, gvk, f.schema, tableName, append(strategyOpts, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	f.strategiesLock.Lock()
+	f.strategies = append(f.strategies, s)
+	f.strategiesLock.Unlock()
+	if s.namespaceLifecycle {
+		go f.runNamespaceLifecycle(WithRowLevelSecurityBypass(f.ctx), s)
+	}
+	return s, nil
+}
+
+// getStrategies returns the strategies created by the factory.
+func (f *Factory) getStrategies() []*Strategy {
+	f.strategiesLock.Lock()
+	defer f.strategiesLock.Unlock()
+	return append([]*Strategy(nil), f.strategies...)
+}
+
+// removeStrategy forgets s, which has been destroyed, and the results of its health checks.
+func (f *Factory) removeStrategy(s *Strategy) {
+	f.strategiesLock.Lock()
+	f.strategies = slices.DeleteFunc(f.strategies, func(other *Strategy) bool {
+		return other == s
+	})
+	f.strategiesLock.Unlock()
+	f.health.forget("ready/" + s.db.stmt.TableName())
+}
+
+// StrategyFor returns the strategy the factory created for gvk, so that pkg/client can call it directly.
+func (f *Factory) StrategyFor(gvk schema.GroupVersionKind) (strategy.CompleteStrategy, error) {
+	strategies, err := f.strategiesFor([]schema.GroupVersionKind{gvk})
+	if err != nil {
+		return nil, err
+	}
+	return strategies[0], nil
 }
@@ -0,0 +1,1087 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/cdc"
+	"github.com/obot-platform/kinm/pkg/client"
+	"github.com/obot-platform/kinm/pkg/db/errors"
+	"github.com/obot-platform/kinm/pkg/db/statements"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/strategy/audit"
+	kinmtypes "github.com/obot-platform/kinm/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestFactoryConnectRetry(t *testing.T) {
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "missing", "kinm.db")
+
+	start := time.Now()
+	_, err := NewFactory(runtime.NewScheme(), dsn, WithConnectRetry(wait.Backoff{
+		Duration: 10 * time.Millisecond,
+		Steps:    3,
+	}))
+	require.Error(t, err)
+	assert.True(t, errors.IsDatabaseUnreachable(err))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestFactoryWaitForReady(t *testing.T) {
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "missing", "kinm.db")
+
+	_, err := NewFactory(runtime.NewScheme(), dsn, WithWaitForReady(50*time.Millisecond))
+	require.Error(t, err)
+	assert.True(t, errors.IsDatabaseUnreachable(err))
+
+	f, err := NewFactory(runtime.NewScheme(), "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"), WithWaitForReady(time.Second))
+	require.NoError(t, err)
+	require.NoError(t, f.SQLDB.Close())
+}
+
+func TestFactoryDialect(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	// The dialect comes from the DSN, not from the size of the connection pool
+	f.SQLDB.SetMaxOpenConns(2)
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	assert.Equal(t, statements.SQLite, s.(*Strategy).db.stmt.Dialect())
+	assert.Equal(t, statements.SQLite, f.Admin().open("testkind").stmt.Dialect())
+
+	_, err = s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+	})
+	require.NoError(t, err)
+}
+
+func TestFactoryHealth(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	_, err = f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	require.NoError(t, f.ReadinessCheck().Check(req))
+	require.NoError(t, f.LivenessCheck(0).Check(req))
+
+	var names []string
+	for _, result := range f.HealthResults() {
+		names = append(names, result.Name)
+		assert.NoError(t, result.Err)
+		assert.False(t, result.Time.IsZero())
+	}
+	assert.Equal(t, []string{"live", "ready", "ready/testkind"}, names)
+
+	// Hold the only connection so that the pool is exhausted
+	conn, err := f.SQLDB.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Error(t, f.LivenessCheck(50*time.Millisecond).Check(req))
+}
+
+func TestFactoryHealthDestroy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	require.NoError(t, f.ReadinessCheck().Check(req))
+
+	s.(*Strategy).Destroy()
+	assert.Empty(t, f.getStrategies())
+
+	var names []string
+	for _, result := range f.HealthResults() {
+		names = append(names, result.Name)
+	}
+	assert.Equal(t, []string{"ready"}, names)
+}
+
+func TestFactoryWatchSet(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+
+	_, err = s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "before", Namespace: "default", UID: "uid"},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := f.WatchSet(ctx, testGVK)
+	require.NoError(t, err)
+
+	_, err = f.WatchSet(ctx, testGVK.GroupVersion().WithKind("Missing"))
+	require.Error(t, err)
+
+	_, err = s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "after", Namespace: "default", UID: "uid"},
+	})
+	require.NoError(t, err)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, testGVK, event.GroupVersionKind)
+		assert.Equal(t, watch.Added, event.Type)
+		assert.Equal(t, "after", event.Object.(*TestKind).Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestFactoryWatches(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	table := s.(*Strategy).db.stmt.TableName()
+
+	created, err := s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := s.Watch(ctx, "default", storage.ListOptions{})
+	require.NoError(t, err)
+
+	// The added event is queued but not received
+	require.Eventually(t, func() bool {
+		return len(w) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	watches := f.Watches()
+	require.Len(t, watches, 1)
+	assert.Equal(t, table, watches[0].Table)
+	assert.Equal(t, "default", watches[0].Namespace)
+	assert.Equal(t, created.GetResourceVersion(), watches[0].ResourceVersion)
+	assert.Equal(t, 1, watches[0].QueueDepth)
+
+	rec := httptest.NewRecorder()
+	f.WatchesHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/watches?table="+table, nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var served []WatchInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &served))
+	require.Len(t, served, 1)
+	assert.Equal(t, created.GetResourceVersion(), served[0].ResourceVersion)
+
+	rec = httptest.NewRecorder()
+	f.WatchesHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/watches?table=other", nil))
+	assert.Equal(t, "[]\n", rec.Body.String())
+
+	cancel()
+	for range w {
+	}
+	assert.Eventually(t, func() bool {
+		return len(f.Watches()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestFactoryNamespaceLifecycle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{}, &StatusKind{}, &StatusKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	// StatusKind objects are the namespaces of TestKind objects
+	namespaces, err := f.NewDBStrategy(&StatusKind{}, WithNamespaceLifecycle())
+	require.NoError(t, err)
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+
+	namespace, err := namespaces.Create(ctx, &StatusKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", UID: "uid"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{NamespaceFinalizer}, namespace.GetFinalizers())
+
+	for _, obj := range []*TestKind{
+		{ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "team", UID: "uid1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "finalized", Namespace: "team", UID: "uid2", Finalizers: []string{"test"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other", UID: "uid3"}},
+	} {
+		_, err := s.Create(ctx, obj)
+		require.NoError(t, err)
+	}
+
+	_, err = namespaces.Delete(ctx, namespace)
+	require.NoError(t, err)
+
+	// The namespace waits for the finalizer of its remaining object
+	var finalized kinmtypes.Object
+	require.Eventually(t, func() bool {
+		_, err := s.Get(ctx, "team", "plain")
+		if !apierrors.IsNotFound(err) {
+			return false
+		}
+		finalized, err = s.Get(ctx, "team", "finalized")
+		return err == nil && finalized.GetDeletionTimestamp() != nil
+	}, 10*time.Second, 10*time.Millisecond)
+	_, err = namespaces.Get(ctx, "", "team")
+	require.NoError(t, err)
+
+	finalized.SetFinalizers(nil)
+	_, err = s.Update(ctx, finalized)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := namespaces.Get(ctx, "", "team")
+		return apierrors.IsNotFound(err)
+	}, 10*time.Second, 10*time.Millisecond)
+
+	_, err = s.Get(ctx, "other", "other")
+	assert.NoError(t, err)
+}
+
+func TestFactoryChanges(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	published := make(chan cdc.Change, 10)
+	var failed atomic.Bool
+	source := &readySource{Source: f, ready: make(chan struct{})}
+	p := cdc.NewPublisher(source, cdc.SinkFunc(func(ctx context.Context, change cdc.Change) error {
+		if failed.CompareAndSwap(false, true) {
+			// The change is retried until it is published
+			return fmt.Errorf("bus unavailable")
+		}
+		published <- change
+		return nil
+	}), cdc.WithRetryBackoff(wait.Backoff{Duration: time.Millisecond, Steps: 5}))
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- p.Run(ctx)
+	}()
+
+	// Wait for the publisher to start following the feed
+	select {
+	case <-source.ready:
+	case err := <-runErr:
+		t.Fatalf("publisher failed: %v", err)
+	}
+
+	created, err := s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "cdc", Namespace: "default", UID: "uid"},
+		Value:      "v1",
+	})
+	require.NoError(t, err)
+	created.(*TestKind).Value = "v2"
+	_, err = s.Update(context.Background(), created)
+	require.NoError(t, err)
+
+	next := func() cdc.Change {
+		select {
+		case change := <-published:
+			return change
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for change")
+			return cdc.Change{}
+		}
+	}
+
+	change := next()
+	assert.Equal(t, watch.Added, change.Type)
+	assert.Nil(t, change.OldObject)
+
+	change = next()
+	assert.Equal(t, watch.Modified, change.Type)
+	assert.Equal(t, "v2", change.Object.(*TestKind).Value)
+	assert.Equal(t, "v1", change.OldObject.(*TestKind).Value)
+
+	cancel()
+	assert.ErrorIs(t, <-runErr, context.Canceled)
+}
+
+// readySource closes ready once the change feed has been started.
+type readySource struct {
+	cdc.Source
+	ready chan struct{}
+}
+
+func (r *readySource) Changes(ctx context.Context, start map[schema.GroupVersionKind]string, gvks ...schema.GroupVersionKind) (<-chan cdc.Change, error) {
+	changes, err := r.Source.Changes(ctx, start, gvks...)
+	close(r.ready)
+	return changes, err
+}
+
+func TestFactorySubscribe(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+
+	// Objects created before subscribing aren't notified
+	_, err = s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "before", Namespace: "default", UID: "uid1"},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notifications := make(chan Notification, 10)
+	require.NoError(t, f.Subscribe(ctx, testGVK, func(n Notification) {
+		notifications <- n
+	}))
+	assert.Error(t, f.Subscribe(ctx, testGVK.GroupVersion().WithKind("Missing"), func(Notification) {}))
+
+	created, err := s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "sub", Namespace: "default", UID: "uid2"},
+		Value:      "v1",
+	})
+	require.NoError(t, err)
+	created.(*TestKind).Value = "v2"
+	updated, err := s.Update(context.Background(), created)
+	require.NoError(t, err)
+	deleted, err := s.Delete(context.Background(), updated)
+	require.NoError(t, err)
+
+	for _, expected := range []struct {
+		typ NotificationType
+		obj kinmtypes.Object
+	}{{Created, created}, {Updated, updated}, {Deleted, deleted}} {
+		select {
+		case n := <-notifications:
+			assert.Equal(t, expected.typ, n.Type)
+			assert.Equal(t, "default", n.Namespace)
+			assert.Equal(t, "sub", n.Name)
+			assert.Equal(t, expected.obj.GetResourceVersion(), strconv.FormatInt(n.ResourceVersion, 10))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for notification")
+		}
+	}
+}
+
+func TestFactoryClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	_, err = f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+
+	c := client.New(scheme, f)
+
+	namespaced, err := c.IsObjectNamespaced(&TestKind{})
+	require.NoError(t, err)
+	assert.True(t, namespaced)
+
+	w, err := c.Watch(ctx, &TestKindList{}, kclient.InNamespace("default"))
+	require.NoError(t, err)
+	defer w.Stop()
+
+	obj := &TestKind{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "test-",
+			Namespace:    "default",
+		},
+		Value: "created",
+	}
+	require.NoError(t, c.Create(ctx, obj))
+	assert.NotEmpty(t, obj.Name)
+	assert.NotEmpty(t, obj.UID)
+	assert.Equal(t, int64(1), obj.Generation)
+
+	var got TestKind
+	require.NoError(t, c.Get(ctx, kclient.ObjectKeyFromObject(obj), &got))
+	assert.Equal(t, "created", got.Value)
+
+	patched := got.DeepCopyObject().(*TestKind)
+	patched.Value = "patched"
+	require.NoError(t, c.Patch(ctx, patched, kclient.MergeFrom(&got)))
+	assert.Equal(t, "patched", patched.Value)
+
+	// A stale update conflicts, a patch without a resource version doesn't
+	got.Value = "stale"
+	assert.True(t, apierrors.IsConflict(c.Update(ctx, &got)))
+
+	patched.Labels = map[string]string{"status": "true"}
+	require.NoError(t, c.Status().Update(ctx, patched))
+
+	var list TestKindList
+	require.NoError(t, c.List(ctx, &list, kclient.InNamespace("default"), kclient.MatchingLabels{"status": "true"}))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "patched", list.Items[0].Value)
+
+	require.NoError(t, c.Delete(ctx, patched))
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, kclient.ObjectKeyFromObject(obj), &got)))
+
+	var events []watch.EventType
+	for len(events) < 4 {
+		select {
+		case event := <-w.ResultChan():
+			events = append(events, event.Type)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for watch events")
+		}
+	}
+	assert.Equal(t, []watch.EventType{watch.Added, watch.Modified, watch.Modified, watch.Deleted}, events)
+}
+
+func TestFactoryAPIGroup(t *testing.T) {
+	addToScheme := func(scheme *runtime.Scheme) error {
+		scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+		return nil
+	}
+	scheme := runtime.NewScheme()
+	require.NoError(t, addToScheme(scheme))
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	_, err = f.APIGroup(addToScheme, schema.GroupVersion{Group: "other", Version: "v1"}, &TestKind{})
+	require.Error(t, err)
+
+	info, err := f.APIGroup(addToScheme, testGVK.GroupVersion(), &TestKind{})
+	require.NoError(t, err)
+
+	storage := info.VersionedResourcesStorageMap[testGVK.Version]
+	require.Contains(t, storage, "testkinds")
+	assert.NotContains(t, storage, "testkinds/status")
+	assert.Equal(t, "testkind", storage["testkinds"].(rest.SingularNameProvider).GetSingularName())
+	_, ok := storage["testkinds"].(rest.TableConvertor)
+	assert.True(t, ok)
+
+	info, err = f.VersionedAPIGroup(addToScheme, testGVK.GroupVersion(), []string{"v2"}, &TestKind{})
+	require.NoError(t, err)
+	assert.Contains(t, info.VersionedResourcesStorageMap["v2"], "testkinds")
+	assert.Equal(t, []schema.GroupVersion{testGVK.GroupVersion(), {Group: testGVK.Group, Version: "v2"}}, info.PrioritizedVersions)
+}
+
+func TestFactoryDynamicKind(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	_, err = f.NewDynamicStrategy(DynamicKind{GroupVersionKind: testGVK})
+	assert.Error(t, err, "a compiled kind can't be registered as a dynamic kind")
+
+	widgetSchema := &spec.Schema{}
+	widgetSchema.Typed("object", "").
+		SetProperty("spec", *(&spec.Schema{}).Typed("object", "").
+			SetProperty("size", *spec.Int64Property()).
+			WithRequired("size"))
+	widgetGVK := schema.GroupVersionKind{Group: "plugin.example.com", Version: "v1", Kind: "Widget"}
+	s, err := f.NewDynamicStrategy(DynamicKind{GroupVersionKind: widgetGVK, Schema: widgetSchema})
+	require.NoError(t, err)
+	assert.IsType(t, &unstructured.Unstructured{}, s.New())
+	assert.Equal(t, widgetGVK, s.New().GetObjectKind().GroupVersionKind())
+	assert.True(t, strategy.NewScoper(s).NamespaceScoped())
+
+	c := client.New(scheme, f)
+	widget := func(name string, spec map[string]any) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+		obj.SetGroupVersionKind(widgetGVK)
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		return obj
+	}
+
+	require.NoError(t, c.Create(ctx, widget("small", map[string]any{"size": int64(1), "color": "red"})))
+	assert.True(t, apierrors.IsInvalid(c.Create(ctx, widget("unsized", map[string]any{}))))
+	err = c.Create(ctx, widget("wrong", map[string]any{"size": "big"}))
+	require.True(t, apierrors.IsInvalid(err))
+	assert.Contains(t, err.Error(), "spec.size")
+
+	got := &unstructured.Unstructured{}
+	got.SetGroupVersionKind(widgetGVK)
+	require.NoError(t, c.Get(ctx, kclient.ObjectKey{Namespace: "default", Name: "small"}, got))
+	size, _, _ := unstructured.NestedInt64(got.Object, "spec", "size")
+	assert.Equal(t, int64(1), size)
+	color, _, _ := unstructured.NestedString(got.Object, "spec", "color")
+	assert.Equal(t, "red", color, "fields that the schema doesn't declare are kept")
+
+	require.NoError(t, unstructured.SetNestedField(got.Object, "big", "spec", "size"))
+	assert.True(t, apierrors.IsInvalid(c.Update(ctx, got)))
+	require.NoError(t, unstructured.SetNestedField(got.Object, int64(2), "spec", "size"))
+	require.NoError(t, c.Update(ctx, got))
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(widgetGVK.GroupVersion().WithKind("WidgetList"))
+	require.NoError(t, c.List(ctx, list, kclient.InNamespace("default")))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "small", list.Items[0].GetName())
+
+	gadgetGVK := schema.GroupVersionKind{Group: "plugin.example.com", Version: "v1", Kind: "Gadget"}
+	info, err := f.DynamicAPIGroup(widgetGVK.GroupVersion(), DynamicKind{GroupVersionKind: gadgetGVK, ClusterScoped: true})
+	require.NoError(t, err)
+	storage := info.VersionedResourcesStorageMap["v1"]
+	require.Contains(t, storage, "gadgets")
+	assert.False(t, storage["gadgets"].(rest.Scoper).NamespaceScoped())
+	assert.True(t, info.Scheme.Recognizes(gadgetGVK))
+}
+
+func TestFactorySnapshotRestore(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{}, WithCompression(1))
+	require.NoError(t, err)
+
+	obj, err := s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+		Value:      "first",
+	})
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "second"
+	obj, err = s.Update(context.Background(), obj)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, f.Snapshot(context.Background(), &buf))
+
+	restored, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "restored.db"))
+	require.NoError(t, err)
+	defer restored.SQLDB.Close()
+
+	rs, err := restored.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	require.NoError(t, restored.Restore(context.Background(), bytes.NewReader(buf.Bytes())))
+
+	got, err := rs.Get(context.Background(), "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "second", got.(*TestKind).Value)
+	assert.Equal(t, obj.GetResourceVersion(), got.GetResourceVersion())
+
+	history, err := rs.(*Strategy).History(context.Background(), "default", "test", HistoryOptions{})
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "first", history[1].Object.(*TestKind).Value)
+
+	// New writes continue after the restored revisions
+	got.(*TestKind).Value = "third"
+	updated, err := rs.Update(context.Background(), got)
+	require.NoError(t, err)
+	assert.Equal(t, "3", updated.GetResourceVersion())
+
+	// Restoring into a table that isn't empty fails
+	assert.Error(t, restored.Restore(context.Background(), bytes.NewReader(buf.Bytes())))
+}
+
+func TestFactoryExportPurgeTenant(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{}, WithUniqueFields(UniqueRule{Field: "value", ClusterWide: true}), WithSearch("value"))
+	require.NoError(t, err)
+
+	for _, tenant := range []string{"tenant1", "tenant2"} {
+		ctx := WithPartitionID(context.Background(), tenant)
+		obj, err := s.Create(ctx, &TestKind{
+			ObjectMeta: metav1.ObjectMeta{Name: tenant, Namespace: "default", UID: k8stypes.UID(tenant)},
+			Value:      tenant + "-first",
+		})
+		require.NoError(t, err)
+		obj.(*TestKind).Value = tenant + "-second"
+		_, err = s.Update(ctx, obj)
+		require.NoError(t, err)
+	}
+
+	var (
+		buf      bytes.Buffer
+		progress []TenantProgress
+	)
+	require.NoError(t, f.ExportTenant(context.Background(), "tenant1", &buf, TenantOptions{
+		Progress: func(p TenantProgress) {
+			progress = append(progress, p)
+		},
+	}))
+	assert.Equal(t, []TenantProgress{{Table: "testkind", Records: 2}}, progress)
+	assert.Contains(t, buf.String(), "tenant1-first")
+	assert.Contains(t, buf.String(), "tenant1-second")
+	assert.NotContains(t, buf.String(), "tenant2")
+
+	purged, err := f.PurgeTenant(context.Background(), "tenant1", TenantOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	_, err = s.Get(context.Background(), "default", "tenant1")
+	assert.True(t, apierrors.IsNotFound(err), err)
+	_, err = s.Get(context.Background(), "default", "tenant2")
+	require.NoError(t, err)
+
+	// The unique values and search text of the purged objects are gone
+	result, err := s.(*Strategy).Search(context.Background(), "", "tenant1", storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, result.(*TestKindList).Items)
+	_, err = s.Create(WithPartitionID(context.Background(), "tenant3"), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant3", Namespace: "default", UID: "tenant3"},
+		Value:      "tenant1-second",
+	})
+	require.NoError(t, err)
+}
+
+func TestFactoryBackup(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	store, err := NewFileBackupStore(t.TempDir())
+	require.NoError(t, err)
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"), WithBackups(store, BackupOptions{Retain: 2}))
+	require.NoError(t, err)
+	defer f.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	_, err = s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+		Value:      "value",
+	})
+	require.NoError(t, err)
+
+	var backups []*BackupInfo
+	for range 3 {
+		info, err := f.Backup(context.Background())
+		require.NoError(t, err)
+		backups = append(backups, info)
+		// Backups are named by the time they are taken, with millisecond precision
+		time.Sleep(2 * time.Millisecond)
+	}
+	assert.Equal(t, []string{backups[0].Name}, backups[2].Deleted)
+
+	names, err := store.List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{backups[1].Name, backups[2].Name}, names)
+
+	file, err := os.Open(filepath.Join(store.dir, backups[2].Name))
+	require.NoError(t, err)
+	defer file.Close()
+	r, err := NewBackupReader(file)
+	require.NoError(t, err)
+	defer r.Close()
+
+	restored, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "restored.db"))
+	require.NoError(t, err)
+	defer restored.Close()
+	require.NoError(t, restored.Admin().Restore(context.Background(), r))
+
+	rs, err := restored.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	got, err := rs.Get(context.Background(), "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "value", got.(*TestKind).Value)
+}
+
+func TestFactoryWALReplication(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	target, err := NewFileBackupStore(t.TempDir())
+	require.NoError(t, err)
+	dir := t.TempDir()
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(dir, "kinm.db"), WithWALReplication(target, ReplicationOptions{
+		Interval:        time.Hour,
+		CheckpointBytes: 64 << 10,
+	}))
+	require.NoError(t, err)
+
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	obj, err := s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+		Value:      "0",
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.Replicate(context.Background()))
+
+	// Enough updates to checkpoint the WAL several times
+	for i := range 100 {
+		obj.(*TestKind).Value = strconv.Itoa(i + 1)
+		obj, err = s.Update(context.Background(), obj)
+		require.NoError(t, err)
+		if i%10 == 0 {
+			require.NoError(t, f.Replicate(context.Background()))
+		}
+	}
+	_, err = s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "last", Namespace: "default", UID: "uid2"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.Replicate(context.Background()))
+
+	names, err := target.List(context.Background())
+	require.NoError(t, err)
+	var epochs int
+	for _, name := range names {
+		if strings.HasSuffix(name, "-000000000000.wal.zst") {
+			epochs++
+		}
+	}
+	assert.Greater(t, epochs, 1)
+
+	// Restore from the replica, without the writes being checkpointed on close
+	restoredPath := filepath.Join(t.TempDir(), "restored.db")
+	restored, err := RestoreReplica(context.Background(), target, restoredPath)
+	require.NoError(t, err)
+	assert.True(t, restored)
+	require.NoError(t, f.Close())
+
+	rf, err := NewFactory(scheme, "sqlite://"+restoredPath)
+	require.NoError(t, err)
+	defer rf.Close()
+	rs, err := rf.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	got, err := rs.Get(context.Background(), "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "100", got.(*TestKind).Value)
+	assert.Equal(t, obj.GetResourceVersion(), got.GetResourceVersion())
+	_, err = rs.Get(context.Background(), "default", "last")
+	assert.NoError(t, err)
+
+	// A new database is restored from the replica at startup
+	startup, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "startup.db"), WithWALReplication(target, ReplicationOptions{
+		Interval: time.Hour,
+		Restore:  true,
+	}))
+	require.NoError(t, err)
+	defer startup.Close()
+	ss, err := startup.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	_, err = ss.Get(context.Background(), "default", "last")
+	assert.NoError(t, err)
+}
+
+func TestFactoryTablePrefixWatchCursor(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "kinm.db")
+
+	for i, prefix := range []string{"one_", "two_"} {
+		f, err := NewFactory(scheme, dsn, WithTablePrefix(prefix))
+		require.NoError(t, err)
+		s, err := f.NewDBStrategy(&TestKind{})
+		require.NoError(t, err)
+		require.NoError(t, s.(*Strategy).WatchCursor("consumer").Save(context.Background(), strconv.Itoa(i+1)))
+		rv, err := s.(*Strategy).WatchCursor("consumer").ResourceVersion(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(i+1), rv)
+		s.Destroy()
+		require.NoError(t, f.Close())
+	}
+
+	// Each prefix has its own cursor table
+	f, err := NewFactory(scheme, dsn)
+	require.NoError(t, err)
+	defer f.Close()
+	for i, prefix := range []string{"one_", "two_"} {
+		var name string
+		var rv int64
+		require.NoError(t, f.SQLDB.QueryRow(`SELECT name, resource_version FROM "`+prefix+`watch_cursor"`).Scan(&name, &rv))
+		assert.Equal(t, prefix+"testkind", name)
+		assert.Equal(t, int64(i+1), rv)
+	}
+	var tables int
+	require.NoError(t, f.SQLDB.QueryRow(`SELECT count(*) FROM sqlite_master WHERE name = 'watch_cursor'`).Scan(&tables))
+	assert.Zero(t, tables)
+}
+
+func TestFactoryAuditSink(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"), WithTablePrefix("app_"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	sink, err := f.NewAuditSink(context.Background(), "audit")
+	require.NoError(t, err)
+	require.NoError(t, sink.Record(context.Background(), audit.Event{
+		Time:    time.Now(),
+		User:    "alice",
+		Verb:    audit.VerbCreate,
+		Name:    "audited",
+		Outcome: audit.OutcomeSuccess,
+	}))
+
+	var user string
+	require.NoError(t, f.SQLDB.QueryRow(`SELECT username FROM app_audit`).Scan(&user))
+	assert.Equal(t, "alice", user)
+}
+
+func TestFactoryAdmin(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"), WithTablePrefix("app_"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+
+	obj, err := s.Create(context.Background(), &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+		Value:      "first",
+	})
+	require.NoError(t, err)
+	first := obj.GetResourceVersion()
+	obj.(*TestKind).Value = "second"
+	obj, err = s.Update(context.Background(), obj)
+	require.NoError(t, err)
+
+	admin := f.Admin()
+	tables, err := admin.Tables(context.Background())
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+	assert.Equal(t, "testkind", tables[0].Name)
+	assert.Equal(t, int64(2), tables[0].Records)
+	assert.Equal(t, int64(1), tables[0].Objects)
+	assert.Equal(t, int64(2), tables[0].Revision)
+
+	history, err := admin.History(context.Background(), "testkind", "default", "test", HistoryOptions{})
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.True(t, history[1].Created)
+
+	revision, err := admin.Rollback(context.Background(), "testkind", first)
+	require.NoError(t, err)
+	assert.Equal(t, "3", revision.ResourceVersion)
+
+	got, err := s.Get(context.Background(), "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "first", got.(*TestKind).Value)
+	assert.Equal(t, obj.GetGeneration()+1, got.GetGeneration())
+
+	_, err = admin.Table(context.Background(), "missing")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	var buf bytes.Buffer
+	require.NoError(t, admin.Dump(context.Background(), &buf))
+
+	restored, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "restored.db"))
+	require.NoError(t, err)
+	defer restored.SQLDB.Close()
+	require.NoError(t, restored.Admin().Restore(context.Background(), &buf))
+
+	rs, err := restored.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	got, err = rs.Get(context.Background(), "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "first", got.(*TestKind).Value)
+	assert.Equal(t, "3", got.GetResourceVersion())
+}
+
+func TestAdminCopyTo(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	src, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "src.db"))
+	require.NoError(t, err)
+	defer src.SQLDB.Close()
+
+	s, err := src.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+	for i := range 3 {
+		_, err := s.Create(context.Background(), &TestKind{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("test%d", i), Namespace: "default", UID: "uid"},
+		})
+		require.NoError(t, err)
+	}
+	_, err = src.Admin().Compact(context.Background(), "testkind")
+	require.NoError(t, err)
+
+	dst, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "dst.db"), WithTablePrefix("copy_"))
+	require.NoError(t, err)
+	defer dst.SQLDB.Close()
+
+	var progress []CopyProgress
+	err = src.Admin().CopyTo(context.Background(), dst.Admin(), CopyOptions{
+		Progress: func(p CopyProgress) {
+			progress = append(progress, p)
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, progress, 3)
+	assert.Equal(t, CopyProgress{Table: "testkind", Copied: 3, Total: 3}, progress[2])
+
+	info, err := dst.Admin().Table(context.Background(), "testkind")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), info.Objects)
+	assert.Equal(t, int64(3), info.Revision)
+	assert.Equal(t, int64(3), info.CompactionID)
+
+	// The destination tables are no longer empty
+	assert.Error(t, src.Admin().CopyTo(context.Background(), dst.Admin(), CopyOptions{}))
+}
+
+func TestFactorySeed(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	f, err := NewFactory(scheme, "sqlite://"+filepath.Join(t.TempDir(), "kinm.db"))
+	require.NoError(t, err)
+	defer f.SQLDB.Close()
+
+	s, err := f.NewDBStrategy(&TestKind{})
+	require.NoError(t, err)
+
+	manifests := fstest.MapFS{
+		"README.md": {Data: []byte("not a manifest")},
+		"defaults/objects.yaml": {Data: []byte(`apiVersion: testgroup/testversion
+kind: TestKind
+metadata:
+  name: first
+  namespace: default
+  labels:
+    app: seed
+value: one
+---
+apiVersion: testgroup/testversion
+kind: TestKind
+metadata:
+  name: second
+  namespace: default
+value: two
+`)},
+		"list.json": {Data: []byte(`{"apiVersion": "v1", "kind": "List", "items": [
+  {"apiVersion": "testgroup/testversion", "kind": "TestKind", "metadata": {"name": "third", "namespace": "default"}, "value": "three"}
+]}`)},
+	}
+
+	result, err := f.Seed(context.Background(), manifests, SeedOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, SeedResult{Created: 3}, result)
+
+	obj, err := s.Get(context.Background(), "default", "first")
+	require.NoError(t, err)
+	assert.Equal(t, "one", obj.(*TestKind).Value)
+	assert.NotEmpty(t, obj.GetUID())
+
+	// Objects changed after seeding are kept unless updating
+	obj.(*TestKind).Value = "changed"
+	obj.SetLabels(map[string]string{"app": "seed", "owner": "user"})
+	_, err = s.Update(context.Background(), obj)
+	require.NoError(t, err)
+
+	result, err = f.Seed(context.Background(), manifests, SeedOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, SeedResult{Unchanged: 3}, result)
+
+	result, err = f.Seed(context.Background(), manifests, SeedOptions{Update: true})
+	require.NoError(t, err)
+	assert.Equal(t, SeedResult{Updated: 1, Unchanged: 2}, result)
+
+	obj, err = s.Get(context.Background(), "default", "first")
+	require.NoError(t, err)
+	assert.Equal(t, "one", obj.(*TestKind).Value)
+	assert.Equal(t, map[string]string{"app": "seed", "owner": "user"}, obj.GetLabels())
+
+	result, err = f.Seed(context.Background(), manifests, SeedOptions{Update: true})
+	require.NoError(t, err)
+	assert.Equal(t, SeedResult{Unchanged: 3}, result)
+
+	// Kinds without a strategy are an error
+	_, err = f.Seed(context.Background(), fstest.MapFS{"other.yaml": {Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: other\n")}}, SeedOptions{})
+	assert.Error(t, err)
+}
+
+func TestFactorySQLiteEncryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kinm.db")
+	t.Setenv(SQLiteKeyEnv, "")
+
+	_, err := NewFactory(runtime.NewScheme(), "sqlite://"+path, WithSQLiteEncryption(SQLiteEncryption{DriverName: "sqlite"}))
+	assert.ErrorContains(t, err, SQLiteKeyEnv)
+
+	_, err = NewFactory(runtime.NewScheme(), "sqlite://"+path, WithSQLiteEncryption(SQLiteEncryption{DriverName: "unknown", Key: "secret"}))
+	assert.ErrorContains(t, err, "unknown")
+
+	// The bundled driver isn't built with SQLCipher, so it must not silently write an unencrypted database
+	t.Setenv(SQLiteKeyEnv, "secret")
+	_, err = NewFactory(runtime.NewScheme(), "sqlite://"+path, WithSQLiteEncryption(SQLiteEncryption{DriverName: "sqlite"}))
+	assert.ErrorContains(t, err, "not built with SQLCipher")
+
+	err = RekeySQLite(context.Background(), path, SQLiteEncryption{DriverName: "sqlite"}, "new")
+	assert.ErrorContains(t, err, "not built with SQLCipher")
+}
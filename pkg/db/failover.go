@@ -0,0 +1,295 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"k8s.io/klog/v2"
+)
+
+// FailoverOptions configures WithFailover.
+type FailoverOptions struct {
+	// DSNs are the Postgres databases to fail over to, tried in order after the DSN given to NewFactory.
+	DSNs []string
+	// CheckInterval is the time between checks that the current database is still a reachable primary. The default
+	// is five seconds.
+	CheckInterval time.Duration
+}
+
+// WithFailover connects to whichever of the DSN given to NewFactory and opts.DSNs is the writable primary, such as
+// the members of a Patroni cluster, so that a failover doesn't require restarting the process. The current primary is
+// checked every opts.CheckInterval; when it becomes unreachable or turns into a replica, connections to it are
+// retired as they are returned to the pool and new connections go to the new primary. Operations running during the
+// failover fail and can be retried. Failover is not supported for sqlite.
+func WithFailover(opts FailoverOptions) FactoryOption {
+	return func(f *Factory) {
+		if opts.CheckInterval == 0 {
+			opts.CheckInterval = 5 * time.Second
+		}
+		f.failover = &opts
+	}
+}
+
+// failoverConnector connects to the first reachable primary of a list of databases, starting with the last one found.
+type failoverConnector struct {
+	names      []string
+	connectors []driver.Connector
+
+	lock    sync.Mutex
+	current int
+	// generation changes when the primary changes, retiring the connections made before.
+	generation atomic.Int64
+}
+
+func (f *Factory) newFailoverConnector(dsn string) (*failoverConnector, error) {
+	pgx, ok := stdlib.GetDefaultDriver().(driver.DriverContext)
+	if !ok {
+		return nil, fmt.Errorf("postgres driver doesn't support connectors")
+	}
+	c := &failoverConnector{}
+	for _, dsn := range append([]string{dsn}, f.failover.DSNs...) {
+		dsn, err := f.postgresDSN(strings.Replace(dsn, "postgresql://", "postgres://", 1))
+		if err != nil {
+			return nil, err
+		}
+		connector, err := pgx.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		c.names = append(c.names, redactDSN(dsn))
+		c.connectors = append(c.connectors, connector)
+	}
+	return c, nil
+}
+
+// redactDSN removes the password from a DSN so that it can be logged.
+func redactDSN(dsn string) string {
+	if user, rest, ok := strings.Cut(dsn, "@"); ok {
+		if i := strings.LastIndex(user, ":"); i > strings.Index(user, "://")+2 {
+			return user[:i] + ":xxxxx@" + rest
+		}
+	}
+	return dsn
+}
+
+func (c *failoverConnector) Driver() driver.Driver {
+	return c.connectors[0].Driver()
+}
+
+func (c *failoverConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.lock.Lock()
+	start, generation := c.current, c.generation.Load()
+	c.lock.Unlock()
+
+	var errs []string
+	for i := range c.connectors {
+		index := (start + i) % len(c.connectors)
+		conn, err := c.connectPrimary(ctx, index)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", c.names[index], err))
+			continue
+		}
+		if index != start {
+			generation = c.switchTo(index, generation)
+		}
+		return &failoverConn{Conn: conn, connector: c, generation: generation}, nil
+	}
+	return nil, fmt.Errorf("no primary database reachable: %s", strings.Join(errs, "; "))
+}
+
+// connectPrimary connects to the database at index, failing if it is not a primary.
+func (c *failoverConnector) connectPrimary(ctx context.Context, index int) (driver.Conn, error) {
+	conn, err := c.connectors[index].Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrimary(ctx, conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// switchTo makes the database at index the current one, if the primary didn't change since generation was read, and
+// returns the current generation.
+func (c *failoverConnector) switchTo(index int, generation int64) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.generation.Load() == generation && c.current != index {
+		klog.Warningf("failing over from %s to %s", c.names[c.current], c.names[index])
+		c.current = index
+		c.generation.Add(1)
+	}
+	return c.generation.Load()
+}
+
+// retire retires the connections to the current database, if the primary didn't change since generation was read.
+func (c *failoverConnector) retire(generation int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation.CompareAndSwap(generation, generation+1)
+}
+
+// check checks that the current database is still a reachable primary. If not, it fails over to the next primary
+// found, or retires the connections to the current database so that new connections look for a primary.
+func (c *failoverConnector) check(ctx context.Context, timeout time.Duration) {
+	c.lock.Lock()
+	current, generation := c.current, c.generation.Load()
+	c.lock.Unlock()
+
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := c.connectPrimary(checkCtx, current)
+	if err == nil {
+		_ = conn.Close()
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	klog.Warningf("primary database %s failed its check: %v", c.names[current], err)
+
+	for i := 1; i < len(c.connectors); i++ {
+		index := (current + i) % len(c.connectors)
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		conn, err := c.connectPrimary(checkCtx, index)
+		cancel()
+		if err == nil {
+			_ = conn.Close()
+			c.switchTo(index, generation)
+			return
+		}
+	}
+	c.retire(generation)
+}
+
+// runChecks checks the primary every interval until ctx is done.
+func (c *failoverConnector) runChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.check(ctx, interval)
+		}
+	}
+}
+
+// checkPrimary returns an error if conn is not connected to a writable primary.
+func checkPrimary(ctx context.Context, conn driver.Conn) error {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return fmt.Errorf("connection doesn't support queries")
+	}
+	rows, err := queryer.QueryContext(ctx, "SELECT pg_is_in_recovery()", nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("no recovery status returned")
+		}
+		return err
+	}
+	if recovery, _ := dest[0].(bool); recovery {
+		return fmt.Errorf("database is a read-only replica")
+	}
+	return nil
+}
+
+// failoverConn is a connection made by a failoverConnector. It forwards the optional interfaces of the driver's
+// connections that kinm relies on, and is discarded by the pool once the primary changed.
+type failoverConn struct {
+	driver.Conn
+	connector  *failoverConnector
+	generation int64
+}
+
+var (
+	_ driver.ConnBeginTx        = (*failoverConn)(nil)
+	_ driver.ConnPrepareContext = (*failoverConn)(nil)
+	_ driver.ExecerContext      = (*failoverConn)(nil)
+	_ driver.QueryerContext     = (*failoverConn)(nil)
+	_ driver.Pinger             = (*failoverConn)(nil)
+	_ driver.NamedValueChecker  = (*failoverConn)(nil)
+	_ driver.SessionResetter    = (*failoverConn)(nil)
+	_ driver.Validator          = (*failoverConn)(nil)
+	_ driver.Connector          = (*failoverConnector)(nil)
+)
+
+func (c *failoverConn) retired() bool {
+	return c.generation != c.connector.generation.Load()
+}
+
+func (c *failoverConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return nil, fmt.Errorf("connection doesn't support transaction options")
+}
+
+func (c *failoverConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *failoverConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *failoverConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *failoverConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *failoverConn) CheckNamedValue(value *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func (c *failoverConn) ResetSession(ctx context.Context) error {
+	if c.retired() {
+		return driver.ErrBadConn
+	}
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *failoverConn) IsValid() bool {
+	if c.retired() {
+		return false
+	}
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
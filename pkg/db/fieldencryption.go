@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// WithFieldEncryption encrypts the values of the fields of the kind tagged `kinm:"encrypt"`, and of the given dot
+// separated paths, with transformer, so that secrets embedded in otherwise public objects aren't stored as plain text.
+// A "*" segment matches every element of an array or value of an object, such as "spec.credentials.*.token". Each
+// value is replaced by a string holding the encrypted JSON, authenticated with the namespace, name and path of the
+// object so that it can't be moved to another object or field; the rest of the object stays readable.
+//
+// Encrypted fields are decrypted on read. A field that is no longer configured stays encrypted until the object is
+// written again by a strategy with the field configured. Encrypted fields shouldn't be indexed or searched, since the
+// auxiliary tables hold plain text.
+func WithFieldEncryption(transformer value.Transformer, paths ...string) Option {
+	return func(s *Strategy) {
+		s.db.fieldTransformer = transformer
+		s.db.encryptedFields = append(s.db.encryptedFields, paths...)
+	}
+}
+
+// initFieldEncryption adds the fields tagged to be encrypted in the type of the kind to the encrypted fields. Tagged
+// fields are never silently stored as plain text.
+func (s *Strategy) initFieldEncryption() error {
+	s.db.encryptedFields = append(s.db.encryptedFields, types.TaggedFields(s.objTemplate, "encrypt")...)
+	if len(s.db.encryptedFields) > 0 && s.db.fieldTransformer == nil {
+		return fmt.Errorf("fields %s of %s are to be encrypted, but no transformer is configured with WithFieldEncryption",
+			strings.Join(s.db.encryptedFields, ", "), s.db.gvk.Kind)
+	}
+	return nil
+}
+
+// encryptFields replaces the values of the encrypted fields of the JSON object v with their encrypted form.
+func (d *db) encryptFields(ctx context.Context, namespace, name, v string) (string, error) {
+	if len(d.encryptedFields) == 0 {
+		return v, nil
+	}
+	result, err := types.RewriteFields([]byte(v), d.encryptedFields, func(path string, field json.RawMessage) (json.RawMessage, error) {
+		data, err := d.fieldTransformer.TransformToStorage(ctx, field, fieldTransformContext(namespace, name, path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt field %s: %w", path, err)
+		}
+		return json.Marshal(encPrefix + base64.StdEncoding.EncodeToString(data))
+	})
+	return string(result), err
+}
+
+// decryptFields reverses encryptFields. Fields that aren't encrypted are left as they are, so that fields can be added
+// to the encrypted fields of an existing table.
+func (d *db) decryptFields(ctx context.Context, namespace, name, v string) (string, error) {
+	if len(d.encryptedFields) == 0 || !strings.Contains(v, encPrefix) {
+		return v, nil
+	}
+	result, err := types.RewriteFields([]byte(v), d.encryptedFields, func(path string, field json.RawMessage) (json.RawMessage, error) {
+		var encoded string
+		if json.Unmarshal(field, &encoded) != nil || !strings.HasPrefix(encoded, encPrefix) {
+			return field, nil
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encoded, encPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode encrypted field %s: %w", path, err)
+		}
+		result, _, err := d.fieldTransformer.TransformFromStorage(ctx, data, fieldTransformContext(namespace, name, path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt field %s: %w", path, err)
+		}
+		return result, nil
+	})
+	return string(result), err
+}
+
+func fieldTransformContext(namespace, name, path string) value.Context {
+	return value.DefaultContext(namespace + "/" + name + "#" + path)
+}
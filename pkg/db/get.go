@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/obot-platform/kinm/pkg/db/errors"
+	"github.com/obot-platform/kinm/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// GetWithOptions is Get with the resourceVersion of opts: empty or "0" reads the latest revision of the object, any
+// other resourceVersion reads the object as it was at that revision of the table. Reading a revision that has been
+// compacted returns a ResourceExpired error, and a revision newer than the latest revision of the table returns a
+// Timeout error with a ResourceVersionTooLarge cause, like the API server.
+func (s *Strategy) GetWithOptions(ctx context.Context, namespace, name string, opts metav1.GetOptions) (types.Object, error) {
+	if opts.ResourceVersion == "" || opts.ResourceVersion == "0" {
+		return s.Get(ctx, namespace, name)
+	}
+
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+
+	rev, err := parseResourceVersion(opts.ResourceVersion)
+	if err != nil {
+		return nil, err
+	}
+	// Check the latest revision before reading, since the read can't tell a future revision from the latest one
+	meta, err := s.db.getTableMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current := max(meta.ListID, meta.CompactionID); rev > current {
+		return nil, storage.NewTooLargeResourceVersionError(uint64(rev), uint64(current), 1)
+	}
+	_, records, err := s.db.list(ctx, getNamespace(namespace), &name, rev, false, cursor{}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.NewNotFound(s.db.gvk, name)
+	}
+	result := s.New()
+	if err := records[0].Unmarshal(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetIfModified returns the named object unless its latest revision is resourceVersion, in which case it returns false
+// without reading the stored value. It lets clients that poll an object cheaply tell that it didn't change, like the
+// If-None-Match header of HTTP.
+func (s *Strategy) GetIfModified(ctx context.Context, namespace, name, resourceVersion string) (_ types.Object, modified bool, _ error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, false, err
+	}
+	defer s.endRequest(readOnlyRequest)
+
+	if resourceVersion != "" {
+		id, err := s.db.headRevision(ctx, namespace, name)
+		if err != nil {
+			return nil, false, err
+		}
+		if rev, err := parseResourceVersion(resourceVersion); err == nil && rev == id {
+			return nil, false, nil
+		}
+	}
+
+	obj, err := s.get(ctx, namespace, name)
+	if err != nil {
+		return nil, false, err
+	}
+	return obj, true, nil
+}
+
+// headRevision returns the latest revision of the named object, or a NotFound error if it doesn't exist.
+func (d *db) headRevision(ctx context.Context, namespace, name string) (int64, error) {
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var id, deleted int64
+	err = d.queryRowContext(ctx, d.stmt.HeadRevisionSQL(), namespace, name, getPartitionID(ctx)).Scan(&id, &deleted)
+	if err == sql.ErrNoRows || deleted == 1 {
+		return 0, errors.NewNotFound(d.gvk, name)
+	} else if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
@@ -0,0 +1,106 @@
+package glogrus
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewHandler returns a slog.Handler that writes to the given logrus.Logger, so that code logging with slog can keep
+// using an existing logrus configuration. If logger is nil, logrus.StandardLogger() is used.
+func NewHandler(logger *logrus.Logger) slog.Handler {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &handler{
+		logger: logger,
+		fields: logrus.Fields{},
+	}
+}
+
+type handler struct {
+	logger *logrus.Logger
+	fields logrus.Fields
+	group  string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.IsLevelEnabled(logrusLevel(level))
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	fields := make(logrus.Fields, len(h.fields)+record.NumAttrs())
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		h.addAttr(fields, h.group, attr)
+		return true
+	})
+
+	entry := h.logger.WithContext(ctx).WithFields(fields)
+	if !record.Time.IsZero() {
+		entry = entry.WithTime(record.Time)
+	}
+	entry.Log(logrusLevel(record.Level), record.Message)
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(logrus.Fields, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	for _, attr := range attrs {
+		h.addAttr(fields, h.group, attr)
+	}
+	return &handler{
+		logger: h.logger,
+		fields: fields,
+		group:  h.group,
+	}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{
+		logger: h.logger,
+		fields: h.fields,
+		group:  h.group + name + ".",
+	}
+}
+
+func (h *handler) addAttr(fields logrus.Fields, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, a := range value.Group() {
+			h.addAttr(fields, prefix, a)
+		}
+		return
+	}
+	if attr.Key == "" {
+		return
+	}
+	fields[prefix+attr.Key] = value.Any()
+}
+
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
+}
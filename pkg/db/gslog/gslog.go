@@ -0,0 +1,115 @@
+// Package gslog provides a gorm logger that wraps a slog.Logger.
+package gslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+	gutils "gorm.io/gorm/utils"
+)
+
+// Config is used to configure a gorm Logger that wraps a slog.Logger.
+type Config struct {
+	// Logger is the slog logger to use. If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// SlowThreshold is the threshold for logging slow queries. If zero, 500ms is used.
+	SlowThreshold time.Duration
+
+	// IgnoreRecordNotFoundError determines if `gorm.ErrRecordNotFound` errors are logged.
+	// `gorm.ErrRecordNotFound` logging is disabled IFF IgnoreRecordNotFoundError is true.
+	IgnoreRecordNotFoundError bool
+
+	// LogSQL determines if SQL queries are included in the log output produced by calls to Logger.Trace.
+	LogSQL bool
+}
+
+// New returns a new *Logger configured with the given config.
+func New(cfg Config) *Logger {
+	l := &Logger{
+		logger:                    cfg.Logger,
+		slowThreshold:             cfg.SlowThreshold,
+		ignoreRecordNotFoundError: cfg.IgnoreRecordNotFoundError,
+		logSQL:                    cfg.LogSQL,
+	}
+	l.complete()
+
+	return l
+}
+
+// Logger is a gorm logger that wraps a slog.Logger.
+// The zero value of Logger is valid and writes to slog.Default() with default settings.
+type Logger struct {
+	logger                    *slog.Logger
+	once                      sync.Once
+	slowThreshold             time.Duration
+	ignoreRecordNotFoundError bool
+	logSQL                    bool
+}
+
+func (l *Logger) LogMode(glogger.LogLevel) glogger.Interface {
+	l.complete()
+	return l
+}
+
+func (l *Logger) Info(ctx context.Context, s string, args ...any) {
+	l.complete()
+	l.logger.InfoContext(ctx, fmt.Sprintf(s, args...))
+}
+
+func (l *Logger) Warn(ctx context.Context, s string, args ...any) {
+	l.complete()
+	l.logger.WarnContext(ctx, fmt.Sprintf(s, args...))
+}
+
+func (l *Logger) Error(ctx context.Context, s string, args ...any) {
+	l.complete()
+	l.logger.ErrorContext(ctx, fmt.Sprintf(s, args...))
+}
+
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.complete()
+	elapsed := time.Since(begin)
+	sql, affected := fc()
+
+	attrs := []any{
+		slog.Duration("elapsed", elapsed),
+		slog.Int64("affected", affected),
+		slog.String("caller", gutils.FileWithLineNum()),
+	}
+
+	if l.logSQL {
+		attrs = append(attrs, slog.String("sql", sql))
+	}
+
+	if err != nil && !(l.ignoreRecordNotFoundError && errors.Is(err, gorm.ErrRecordNotFound)) {
+		l.logger.ErrorContext(ctx, "sql query error", append(attrs, slog.Any("error", err))...)
+		return
+	}
+
+	if l.slowThreshold != 0 && elapsed > l.slowThreshold {
+		l.logger.InfoContext(ctx, "sql query slow", attrs...)
+		return
+	}
+
+	l.logger.DebugContext(ctx, "sql query executed", attrs...)
+}
+
+// complete ensures that the Logger is fully initialized.
+// It's idempotent and should be called at the beginning of every method exported by Logger.
+func (l *Logger) complete() {
+	l.once.Do(func() {
+		if l.logger == nil {
+			l.logger = slog.Default()
+		}
+		if l.slowThreshold == 0 {
+			l.slowThreshold = 500 * time.Millisecond
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/healthz"
+)
+
+const defaultLivenessTimeout = 10 * time.Second
+
+// CheckResult is the outcome of the most recent run of a health check.
+type CheckResult struct {
+	Name    string
+	Time    time.Time
+	Latency time.Duration
+	Err     error
+}
+
+type health struct {
+	lock    sync.Mutex
+	results map[string]CheckResult
+}
+
+func (h *health) record(name string, start time.Time, err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.results == nil {
+		h.results = map[string]CheckResult{}
+	}
+	h.results[name] = CheckResult{
+		Name:    name,
+		Time:    start,
+		Latency: time.Since(start),
+		Err:     err,
+	}
+}
+
+func (h *health) forget(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.results, name)
+}
+
+// HealthResults returns the result of the most recent run of each health check, including the per table readiness
+// probes, sorted by name.
+func (f *Factory) HealthResults() []CheckResult {
+	f.health.lock.Lock()
+	defer f.health.lock.Unlock()
+	result := make([]CheckResult, 0, len(f.health.results))
+	for _, r := range f.health.results {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+type healthChecker struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (h healthChecker) Name() string {
+	return h.name
+}
+
+func (h healthChecker) Check(req *http.Request) error {
+	return h.check(req.Context())
+}
+
+// ReadinessCheck returns a health check that succeeds when every table of the strategies created by the factory can
+// be queried. The latency of each table is recorded separately and available from HealthResults.
+func (f *Factory) ReadinessCheck() healthz.HealthChecker {
+	return healthChecker{
+		name:  "kinm-db-ready",
+		check: f.checkReady,
+	}
+}
+
+func (f *Factory) checkReady(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() {
+		f.health.record("ready", start, err)
+	}()
+
+	if err := f.SQLDB.PingContext(ctx); err != nil {
+		f.logger.Warn("Failed to ping database", "error", err)
+		return err
+	}
+
+	var errs []error
+	for _, s := range f.getStrategies() {
+		tableStart := time.Now()
+		_, err := s.db.getTableMeta(ctx)
+		f.health.record("ready/"+s.db.stmt.TableName(), tableStart, err)
+		if err != nil {
+			f.logger.Warn("Failed to query table", "table", s.db.stmt.TableName(), "error", err)
+			errs = append(errs, fmt.Errorf("table %s: %w", s.db.stmt.TableName(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LivenessCheck returns a health check that fails when no database connection can be acquired within timeout, which
+// indicates that the connection pool is exhausted or deadlocked. An unreachable database doesn't fail the check
+// because restarting the process won't fix it. If timeout is zero, ten seconds is used.
+func (f *Factory) LivenessCheck(timeout time.Duration) healthz.HealthChecker {
+	if timeout == 0 {
+		timeout = defaultLivenessTimeout
+	}
+	return healthChecker{
+		name: "kinm-db-live",
+		check: func(ctx context.Context) error {
+			return f.checkLive(ctx, timeout)
+		},
+	}
+}
+
+func (f *Factory) checkLive(ctx context.Context, timeout time.Duration) (err error) {
+	start := time.Now()
+	defer func() {
+		f.health.record("live", start, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := f.SQLDB.Conn(ctx)
+	if err == nil {
+		return conn.Close()
+	}
+	if ctx.Err() == nil {
+		// The pool is working but the database may be unavailable
+		return nil
+	}
+
+	stats := f.SQLDB.Stats()
+	return fmt.Errorf("no database connection available after %v: %d of %d connections in use, %d waited",
+		timeout, stats.InUse, stats.MaxOpenConnections, stats.WaitCount)
+}
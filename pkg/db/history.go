@@ -0,0 +1,240 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/db/errors"
+	"github.com/obot-platform/kinm/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+)
+
+// WithHistoryLimit keeps at most limit revisions of each object, plus the row that recorded its creation which is
+// needed to enforce name uniqueness. Older revisions are deleted in the same transaction as the write that superseded
+// them instead of waiting for compaction. Like after compaction, a list at a resourceVersion older than the retained
+// revisions of an object, or a watch from one older than its deleted revisions, fails with an expired error. This is
+// tracked per object, so reads that don't include a pruned object are not affected.
+func WithHistoryLimit(limit int) Option {
+	return func(s *Strategy) {
+		s.db.historyLimit = int64(limit)
+	}
+}
+
+// WithArchive moves the revisions removed by compaction and WithHistoryLimit to an archive table, named after the table
+// with an "_archive" suffix, instead of deleting them, so that History can still return them with
+// HistoryOptions.IncludeArchived. The table stays small while the history of every object is kept as an audit trail.
+// Archived revisions are never deleted by kinm.
+func WithArchive() Option {
+	return func(s *Strategy) {
+		s.db.archive = true
+	}
+}
+
+// Revision is a retained revision of an object.
+type Revision struct {
+	// Object is the object as written by this revision, with its resourceVersion set to the revision.
+	Object types.Object
+	// Time is when the revision was written. It is zero for revisions written before times were recorded.
+	Time metav1.Time
+	// Created is true for the revision that created the object.
+	Created bool
+	// Deleted is true for the revision that deleted the object. Its Object is the object as it was when deleted.
+	Deleted bool
+	// Archived is when the revision was moved to the archive table, or zero if it is still in the table.
+	Archived metav1.Time
+}
+
+// HistoryOptions selects the revisions returned by History.
+type HistoryOptions struct {
+	// Limit bounds the number of revisions returned. Zero returns all retained revisions.
+	Limit int64
+	// Before only returns revisions older than this resourceVersion, so that the last resourceVersion returned can be
+	// used to fetch the next page.
+	Before string
+	// IncludeArchived also returns the revisions moved to the archive table of a table stored with WithArchive.
+	IncludeArchived bool
+}
+
+// History returns the retained revisions of the named object, newest first. Revisions are kept until they are
+// compacted or pruned by WithHistoryLimit. If the object was deleted and created again, the revisions of the earlier
+// objects are included, and can be told apart by their UID.
+func (s *Strategy) History(ctx context.Context, namespace, name string, opts HistoryOptions) ([]Revision, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+
+	var before int64
+	if opts.Before != "" {
+		var err error
+		if before, err = parseResourceVersion(opts.Before); err != nil {
+			return nil, err
+		}
+	}
+
+	records, err := s.db.history(ctx, namespace, name, before, opts.Limit, opts.IncludeArchived && s.db.archive)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Revision, 0, len(records))
+	for _, rec := range records {
+		obj := s.New()
+		if err := rec.Unmarshal(obj); err != nil {
+			return nil, err
+		}
+		revision := Revision{
+			Object:  obj,
+			Created: rec.created == 1 || rec.previousID == nil,
+			Deleted: rec.deleted == 1,
+		}
+		if rec.modified != 0 {
+			revision.Time = metav1.NewTime(time.UnixMilli(rec.modified))
+		}
+		if rec.archived != 0 {
+			revision.Archived = metav1.NewTime(time.UnixMilli(rec.archived))
+		}
+		result = append(result, revision)
+	}
+	return result, nil
+}
+
+// GetAtRevision returns the named object as it was at resourceVersion, which must be a revision of that object. It
+// returns a ResourceExpired error if the revision has been compacted or pruned and NotFound if it isn't a revision of
+// the object.
+func (s *Strategy) GetAtRevision(ctx context.Context, namespace, name, resourceVersion string) (types.Object, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+
+	id, err := parseResourceVersion(resourceVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := s.db.getByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		meta, err := s.db.getTableMeta(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if id <= meta.CompactionID {
+			return nil, errors.NewCompactionError(uint(id), uint(meta.CompactionID))
+		}
+		if _, keptID, err := s.db.prunedIDs(ctx, &namespace, &name, id); err != nil {
+			return nil, err
+		} else if id < keptID {
+			return nil, errors.NewCompactionError(uint(id), uint(keptID))
+		}
+		return nil, errors.NewNotFound(s.db.gvk, name)
+	}
+	if rec.namespace != namespace || rec.name != name {
+		return nil, errors.NewNotFound(s.db.gvk, name)
+	}
+	if partitionID := getPartitionID(ctx); partitionID != nil && *partitionID != rec.partitionID {
+		return nil, errors.NewNotFound(s.db.gvk, name)
+	}
+
+	obj := s.New()
+	if err := rec.Unmarshal(obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Undelete recreates the named object, which must be deleted, from the revision that deleted it, which holds the object
+// as it was last stored. The recreated object is a new object with a new resourceVersion, generation and
+// creationTimestamp, without its deletion timestamp, and with uid as its UID, or its previous UID if uid is empty. It
+// returns NotFound if the revisions of the object have been compacted or pruned, and AlreadyExists if the object
+// isn't deleted.
+func (s *Strategy) Undelete(ctx context.Context, namespace, name string, uid ktypes.UID) (types.Object, error) {
+	if err := s.beginRequest(ctx, mutatingRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(mutatingRequest)
+
+	records, err := s.db.history(ctx, namespace, name, 0, 1, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.NewNotFound(s.db.gvk, name)
+	}
+	if records[0].deleted != 1 {
+		return nil, errors.NewAlreadyExists(s.db.gvk, name)
+	}
+
+	obj := s.New()
+	if err := records[0].Unmarshal(obj); err != nil {
+		return nil, err
+	}
+	obj.SetDeletionTimestamp(nil)
+	obj.SetDeletionGracePeriodSeconds(nil)
+	obj.SetCreationTimestamp(metav1.Now())
+	if uid != "" {
+		obj.SetUID(uid)
+	}
+	s.addNamespaceFinalizer(obj)
+
+	defer s.broadcastChange()
+	return s.create(ctx, obj)
+}
+
+func (d *db) history(ctx context.Context, namespace, name string, before, limit int64, archived bool) ([]record, error) {
+	query := d.stmt.HistorySQL(limit)
+	if archived {
+		query = d.stmt.HistoryArchiveSQL(limit)
+	}
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := d.queryContext(ctx, query, namespace, name, before, getPartitionID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []record
+	for rows.Next() {
+		var (
+			r        record
+			created  sql.NullInt16
+			modified sql.NullInt64
+			checksum sql.NullInt64
+			archived sql.NullInt64
+		)
+		if err := rows.Scan(
+			&r.id, &r.name, &r.namespace, &r.previousID, &r.uid, &created, &r.deleted, &r.value, &r.partitionID,
+			&modified, &checksum, &archived); err != nil {
+			return nil, err
+		}
+		r.created = created.Int16
+		r.modified = modified.Int64
+		r.archived = archived.Int64
+		if err := d.readValue(ctx, &r, checksum); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// createArchive creates the archive table of a table stored with WithArchive.
+func (d *db) createArchive(ctx context.Context) error {
+	if !d.archive {
+		return nil
+	}
+	if _, err := d.execContext(ctx, d.stmt.ArchiveCreateSQL()); err != nil {
+		return err
+	}
+	_, err := d.execContext(ctx, d.stmt.ArchiveCreateIndexSQL())
+	return err
+}
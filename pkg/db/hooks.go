@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+)
+
+// WithDefaulter calls defaulter before every create and update. Objects that implement strategy.ObjectDefaulter are
+// defaulted even without this option.
+func WithDefaulter(defaulter strategy.Defaulter) Option {
+	return func(s *Strategy) {
+		s.defaulter = defaulter
+	}
+}
+
+// WithPrepareForCreate calls p after defaulting and before an object is created, typically to clear status.
+func WithPrepareForCreate(p strategy.PrepareForCreator) Option {
+	return func(s *Strategy) {
+		s.prepareForCreater = p
+	}
+}
+
+// WithPrepareForUpdate calls p with the stored object after defaulting and before an object is updated, typically to
+// keep the stored status. It is not called for status updates or deletes.
+func WithPrepareForUpdate(p strategy.PrepareForUpdater) Option {
+	return func(s *Strategy) {
+		s.prepareForUpdater = p
+	}
+}
+
+// WithStatusIsolation keeps the stored status on Update, and everything but the status on UpdateStatus, so that a
+// client updating from a stale copy can't overwrite the part of the object that it doesn't own, typically the status
+// written by a controller. The object is merged with the stored object in the write transaction, see
+// strategy.ResetFields. Objects without a Status field are stored as given.
+func WithStatusIsolation() Option {
+	return func(s *Strategy) {
+		s.statusIsolation = true
+	}
+}
+
+// Hooks are called when the strategy writes objects, such as to maintain derived tables or to emit side effects. Any
+// of them may be nil.
+//
+// The Before hooks are called in the transaction of the write, with the object as it is stored, just before it is
+// written. They can write to other tables with tx, which is committed or rolled back with the write, and the write
+// fails with their error. They must not change the object, nor use another connection, which sqlite doesn't have. A
+// Before hook may be called for an update that turns out not to change the stored object.
+//
+// The After hooks are called with the written object once the write is committed, unless an update didn't change the
+// object.
+//
+// The Delete hooks are called when an object is removed, which is when it is deleted without finalizers or when its
+// last finalizer is removed. Deleting an object with finalizers updates it.
+type Hooks struct {
+	BeforeCreate func(ctx context.Context, tx *sql.Tx, obj types.Object) error
+	AfterCreate  func(ctx context.Context, obj types.Object)
+	BeforeUpdate func(ctx context.Context, tx *sql.Tx, obj types.Object) error
+	AfterUpdate  func(ctx context.Context, obj types.Object)
+	BeforeDelete func(ctx context.Context, tx *sql.Tx, obj types.Object) error
+	AfterDelete  func(ctx context.Context, obj types.Object)
+}
+
+// WithHooks calls hooks when objects are written. The hooks of several WithHooks options are called in order.
+func WithHooks(hooks Hooks) Option {
+	return func(s *Strategy) {
+		s.hooks = append(s.hooks, hooks)
+	}
+}
+
+// writeOp is the kind of a write, which selects the hooks that are called.
+type writeOp int
+
+const (
+	noWrite writeOp = iota
+	createWrite
+	updateWrite
+	deleteWrite
+)
+
+// write calls write in a transaction with the Before hooks of op, if there are any, which are called with obj.
+func (s *Strategy) write(ctx context.Context, op writeOp, obj types.Object, write func(ctx context.Context) (int64, error)) (int64, error) {
+	if len(s.hooks) == 0 {
+		return write(ctx)
+	}
+
+	ctx, tx, err := s.db.beginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	sqlTx := ctx.Value(txKey{}).(*sql.Tx)
+	// The resource version of the stored object is assigned by the write
+	obj.SetResourceVersion("")
+	for _, hooks := range s.hooks {
+		before := hooks.BeforeUpdate
+		switch op {
+		case createWrite:
+			before = hooks.BeforeCreate
+		case deleteWrite:
+			before = hooks.BeforeDelete
+		}
+		if before == nil {
+			continue
+		}
+		if err := before(ctx, sqlTx, obj); err != nil {
+			return 0, err
+		}
+	}
+
+	id, err := write(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// afterWrite calls the After hooks of op with obj and records its resource version for the invariant checks.
+func (s *Strategy) afterWrite(ctx context.Context, op writeOp, obj types.Object) {
+	s.checkWrite(ctx, obj)
+	for _, hooks := range s.hooks {
+		var after func(context.Context, types.Object)
+		switch op {
+		case createWrite:
+			after = hooks.AfterCreate
+		case updateWrite:
+			after = hooks.AfterUpdate
+		case deleteWrite:
+			after = hooks.AfterDelete
+		}
+		if after != nil {
+			after(ctx, obj)
+		}
+	}
+}
+
+func (s *Strategy) setDefaults(ctx context.Context, obj types.Object) {
+	if d, ok := obj.(strategy.ObjectDefaulter); ok {
+		d.Default()
+	}
+	if s.defaulter != nil {
+		s.defaulter.Default(ctx, obj)
+	}
+}
+
+func (s *Strategy) prepareForCreate(ctx context.Context, obj types.Object) {
+	s.setDefaults(ctx, obj)
+	if s.prepareForCreater != nil {
+		s.prepareForCreater.PrepareForCreate(ctx, obj)
+	}
+	s.addNamespaceFinalizer(obj)
+}
+
+// updateIsolated stores obj after resetting the fields that an update of the main resource or, if status is true, of
+// the status subresource doesn't own to the stored values.
+func (s *Strategy) updateIsolated(ctx context.Context, obj types.Object, status bool) (types.Object, writeOp, error) {
+	ctx, tx, err := s.db.beginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+	})
+	if err != nil {
+		return nil, noWrite, err
+	}
+	defer tx.Rollback()
+
+	// Keep the stored object from changing before the update is written
+	if _, err := s.db.execContext(ctx, s.db.stmt.TableLockSQL()); err != nil {
+		return nil, noWrite, err
+	}
+	old, err := s.get(ctx, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return nil, noWrite, err
+	}
+	obj = obj.DeepCopyObject().(types.Object)
+	strategy.ResetFields(obj, old, status)
+
+	result, op, err := s.doUpdate(ctx, obj, !status)
+	if err != nil {
+		return nil, noWrite, err
+	}
+	return result, op, tx.Commit()
+}
+
+// prepareForUpdate returns a copy of obj with the update hooks applied.
+func (s *Strategy) prepareForUpdate(ctx context.Context, obj types.Object) (types.Object, error) {
+	obj = obj.DeepCopyObject().(types.Object)
+	s.setDefaults(ctx, obj)
+	if s.prepareForUpdater != nil {
+		old, err := s.get(ctx, obj.GetNamespace(), obj.GetName())
+		if err != nil {
+			return nil, err
+		}
+		s.prepareForUpdater.PrepareForUpdate(ctx, obj, old)
+	}
+	return obj, nil
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// IndexType is the type of the value of an indexed field, which decides how the value is written as the string that
+// field selectors compare it to.
+type IndexType string
+
+const (
+	// IndexString indexes a string field. Values of other types are indexed as JSON.
+	IndexString IndexType = "string"
+	// IndexInteger indexes an integer field, written in decimal.
+	IndexInteger IndexType = "integer"
+	// IndexBoolean indexes a boolean field, written as "true" or "false".
+	IndexBoolean IndexType = "boolean"
+)
+
+// IndexSpec is a field of an object type that is indexed in the database.
+type IndexSpec struct {
+	// Field is the dot separated path of the field in the JSON of the object, such as "spec.nodeName". It is also the
+	// name of the field in field selectors, so the type should return the field from types.Fields as well.
+	Field string
+	// Type is the type of the field. The default is IndexString.
+	Type IndexType
+}
+
+// FieldIndexer is implemented by object types that declare the fields that lists can filter on in the database. The
+// equality requirements of a field selector on those fields, such as "spec.nodeName=node1", are evaluated by the list
+// query instead of after reading every object of the table. Missing and null fields are indexed as empty strings.
+//
+// The values are kept in an auxiliary table, named after the table with an "_index" suffix, that holds the values of
+// every revision so that lists at older revisions are filtered as well. It is created when the strategy is created,
+// and revisions written before a field was declared are indexed then.
+type FieldIndexer interface {
+	IndexFields() []IndexSpec
+}
+
+type indexFiltersKey struct{}
+
+// indexFilter is a field selector requirement evaluated by the database.
+type indexFilter struct {
+	field, value string
+}
+
+// withIndexFilters adds the requirements of the field selector of opts on indexed fields to ctx, so that lists made
+// with ctx only return the objects that match them. The predicate must still be matched, the filters only spare reading
+// objects that don't match.
+func (d *db) withIndexFilters(ctx context.Context, opts storage.ListOptions) context.Context {
+	if len(d.indexes) == 0 || opts.Predicate.Field == nil {
+		return ctx
+	}
+
+	var filters []indexFilter
+	for _, req := range opts.Predicate.Field.Requirements() {
+		if req.Operator != selection.Equals && req.Operator != selection.DoubleEquals {
+			continue
+		}
+		for _, spec := range d.indexes {
+			if spec.Field == req.Field {
+				filters = append(filters, indexFilter{field: req.Field, value: req.Value})
+			}
+		}
+	}
+	if len(filters) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, indexFiltersKey{}, filters)
+}
+
+// indexFilterArgs returns the number of index filters in ctx and their parameters.
+func indexFilterArgs(ctx context.Context) (int, []any) {
+	filters, _ := ctx.Value(indexFiltersKey{}).([]indexFilter)
+	args := make([]any, 0, 2*len(filters))
+	for _, filter := range filters {
+		args = append(args, filter.field, filter.value)
+	}
+	return len(filters), args
+}
+
+// createIndexes creates the auxiliary table of the indexed fields and indexes the revisions that are missing values.
+func (d *db) createIndexes(ctx context.Context) error {
+	if len(d.indexes) == 0 {
+		return nil
+	}
+
+	ctx, tx, err := d.beginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := d.execContext(ctx, d.stmt.IndexCreateSQL()); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.IndexCreateValuesSQL()); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.TableLockSQL()); err != nil {
+		return err
+	}
+
+	for _, spec := range d.indexes {
+		ids, err := d.queryIDs(ctx, d.stmt.IndexMissingSQL(), spec.Field)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			rec, err := d.getByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := d.insertIndex(ctx, id, rec.value, spec); err != nil {
+				return fmt.Errorf("failed to index field %s of %s %s/%s: %w", spec.Field, d.gvk.Kind, rec.namespace, rec.name, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// updateIndexes indexes the fields of rec, which was written with id in the transaction in ctx.
+func (d *db) updateIndexes(ctx context.Context, id int64, rec record) error {
+	if len(d.indexes) == 0 || rec.deleted == 1 {
+		// Deleted objects are never listed
+		return nil
+	}
+	for _, spec := range d.indexes {
+		if err := d.insertIndex(ctx, id, rec.value, spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *db) insertIndex(ctx context.Context, id int64, value string, spec IndexSpec) error {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(value), &obj); err != nil {
+		return err
+	}
+	_, err := d.execContext(ctx, d.stmt.IndexInsertSQL(), id, spec.Field, indexValue(obj, spec))
+	return err
+}
+
+// pruneIndexes deletes the values of the revisions that have been deleted by compaction or history pruning. Until then
+// the values of deleted revisions match no objects.
+func (d *db) pruneIndexes(ctx context.Context) error {
+	if len(d.indexes) == 0 {
+		return nil
+	}
+	_, err := d.execContext(ctx, d.stmt.IndexPruneSQL())
+	return err
+}
+
+// indexValue returns the value of the field of spec in obj as field selectors see it.
+func indexValue(obj map[string]any, spec IndexSpec) string {
+	var value any = obj
+	for _, key := range strings.Split(spec.Field, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		value = m[key]
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		if spec.Type == IndexInteger {
+			return strconv.FormatInt(int64(v), 10)
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
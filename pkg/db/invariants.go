@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/klog/v2"
+)
+
+// WithInvariantChecks checks the ordering guarantees of resource versions while the strategy runs, to find out whether
+// events are ever delivered out of order. The resource versions of a table are the IDs of its records, so every write
+// gets a resource version greater than all earlier writes of the table, and the strategy guarantees that:
+//
+//   - a watch sends the objects of its initial list, if it has no resource version, at resource versions no newer than
+//     the list, followed by every change after the list or the given resource version in strictly increasing resource
+//     version order, each change once;
+//   - a list of the latest objects has a resource version no older than any list of the latest objects or write that
+//     completed before it started, in the same partition.
+//
+// A violation logs the diagnostics and calls onViolation with an error describing it, or panics if onViolation is
+// nil. The checks serialize lists and writes of the strategy on a mutex, so they are meant for tests and debugging.
+func WithInvariantChecks(onViolation func(error)) Option {
+	return func(s *Strategy) {
+		s.invariants = &invariants{
+			onViolation: onViolation,
+			floors:      map[string]int64{},
+		}
+	}
+}
+
+type invariants struct {
+	onViolation func(error)
+
+	lock sync.Mutex
+	// floors are the greatest resource versions of the completed lists and writes, by partition ID
+	floors map[string]int64
+}
+
+// violation reports the violation of an invariant of the table.
+func (s *Strategy) violation(format string, args ...any) {
+	err := fmt.Errorf("resource version invariant violated in table %s: %s", s.db.stmt.TableName(), fmt.Sprintf(format, args...))
+	klog.Errorf("%v", err)
+	if s.invariants.onViolation == nil {
+		panic(err)
+	}
+	s.invariants.onViolation(err)
+}
+
+// listFloor returns the resource version that a list of the latest objects starting now must not be older than, or -1
+// if the checks are disabled or opts doesn't list the latest objects.
+func (s *Strategy) listFloor(ctx context.Context, opts storage.ListOptions) int64 {
+	if s.invariants == nil || opts.ResourceVersion != "" || opts.Predicate.Continue != "" {
+		return -1
+	}
+	partitionID, _ := PartitionIDFrom(ctx)
+
+	s.invariants.lock.Lock()
+	defer s.invariants.lock.Unlock()
+	return s.invariants.floors[partitionID]
+}
+
+// checkList checks the resource version of a list of the latest objects that started at floor.
+func (s *Strategy) checkList(ctx context.Context, namespace string, floor int64, resourceVersion string) {
+	if floor < 0 {
+		return
+	}
+	rv, err := strconv.ParseInt(resourceVersion, 10, 64)
+	if err != nil {
+		s.violation("list of namespace %q has invalid resource version %q", namespace, resourceVersion)
+		return
+	}
+	partitionID, _ := PartitionIDFrom(ctx)
+	if rv < floor {
+		s.violation("list of namespace %q in partition %q has resource version %d, older than %d of a list or write "+
+			"that completed before it started", namespace, partitionID, rv, floor)
+	}
+	s.raiseFloor(partitionID, rv)
+}
+
+// checkWrite records the resource version of obj, which has just been written.
+func (s *Strategy) checkWrite(ctx context.Context, obj interface{ GetResourceVersion() string }) {
+	if s.invariants == nil {
+		return
+	}
+	rv, err := strconv.ParseInt(obj.GetResourceVersion(), 10, 64)
+	if err != nil {
+		s.violation("write returned invalid resource version %q", obj.GetResourceVersion())
+		return
+	}
+	// Lists without a partition see the writes of every partition
+	partitionID, _ := PartitionIDFrom(ctx)
+	s.raiseFloor(partitionID, rv)
+	if partitionID != "" {
+		s.raiseFloor("", rv)
+	}
+}
+
+func (s *Strategy) raiseFloor(partitionID string, rv int64) {
+	s.invariants.lock.Lock()
+	defer s.invariants.lock.Unlock()
+	s.invariants.floors[partitionID] = max(s.invariants.floors[partitionID], rv)
+}
+
+// watchCheck checks the order of the events of a watch.
+type watchCheck struct {
+	s         *Strategy
+	namespace string
+	opts      storage.ListOptions
+	// listRV is the resource version of the initial list
+	listRV int64
+	// last is the resource version of the last change sent
+	last     int64
+	lastName string
+}
+
+// newWatchCheck returns the checks of a watch starting at resourceVersion, or nil if the checks are disabled.
+func (s *Strategy) newWatchCheck(namespace string, opts storage.ListOptions, resourceVersion string) *watchCheck {
+	if s.invariants == nil {
+		return nil
+	}
+	c := &watchCheck{
+		s:         s,
+		namespace: namespace,
+		opts:      opts,
+	}
+	c.listRV, _ = strconv.ParseInt(resourceVersion, 10, 64)
+	c.last = c.listRV
+	return c
+}
+
+// event checks the record of an event, sent by the initial list if initial is true.
+func (c *watchCheck) event(rec record, initial bool) {
+	if c == nil {
+		return
+	}
+	if initial {
+		if rec.id > c.listRV {
+			c.violation("initial event of %s/%s has resource version %d, newer than the list at %d", rec.namespace,
+				rec.name, rec.id, c.listRV)
+		}
+		return
+	}
+	if rec.id <= c.last {
+		c.violation("event of %s/%s at resource version %d was sent after the event of %s at %d", rec.namespace,
+			rec.name, rec.id, c.lastName, c.last)
+		return
+	}
+	c.last, c.lastName = rec.id, rec.namespace+"/"+rec.name
+}
+
+// requery checks the resource version of a query for the changes after resourceVersion.
+func (c *watchCheck) requery(resourceVersion, newResourceVersion string) {
+	if c == nil {
+		return
+	}
+	rv, _ := strconv.ParseInt(resourceVersion, 10, 64)
+	newRV, _ := strconv.ParseInt(newResourceVersion, 10, 64)
+	if newRV < rv {
+		c.violation("query for the changes after %d has resource version %d", rv, newRV)
+	}
+}
+
+func (c *watchCheck) violation(format string, args ...any) {
+	c.s.violation("watch of namespace %q with label selector %q and field selector %q: %s", c.namespace,
+		c.opts.Predicate.Label, c.opts.Predicate.Field, fmt.Sprintf(format, args...))
+}
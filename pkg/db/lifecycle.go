@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+const defaultDrainTimeout = 30 * time.Second
+
+// WithDrainTimeout bounds how long Destroy waits for in-flight operations to finish before closing the database. The
+// default is 30 seconds.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(s *Strategy) {
+		s.drainTimeout = timeout
+	}
+}
+
+// lifecycle tracks in-flight operations so that Destroy can drain them.
+type lifecycle struct {
+	lock        sync.Mutex
+	closing     bool
+	inflight    sync.WaitGroup
+	destroyOnce sync.Once
+	watches     watchRegistry
+}
+
+// watchRegistry tracks the active watches of a strategy, so that Destroy can end the watches of consumers that stopped
+// reading without canceling their context.
+type watchRegistry struct {
+	lock    sync.Mutex
+	closed  bool
+	watches map[*watcher]struct{}
+}
+
+// add registers w and returns false if the registry was closed, in which case the watch must end.
+func (r *watchRegistry) add(w *watcher) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return false
+	}
+	if r.watches == nil {
+		r.watches = map[*watcher]struct{}{}
+	}
+	r.watches[w] = struct{}{}
+	return true
+}
+
+func (r *watchRegistry) remove(w *watcher) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.watches, w)
+}
+
+// closeAll cancels every registered watch, and every watch added later.
+func (r *watchRegistry) closeAll() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closed = true
+	for w := range r.watches {
+		w.cancel()
+	}
+}
+
+// begin registers an operation. Every successful call must be paired with a call to end.
+func (s *Strategy) begin() error {
+	s.lifecycle.lock.Lock()
+	defer s.lifecycle.lock.Unlock()
+	if s.lifecycle.closing {
+		return apierrors.NewServiceUnavailable("storage for " + s.db.gvk.Kind + " is shutting down")
+	}
+	s.lifecycle.inflight.Add(1)
+	return nil
+}
+
+func (s *Strategy) end() {
+	s.lifecycle.inflight.Done()
+}
+
+// watchContext returns the context of the watch w, which is canceled when either ctx is done, the strategy is
+// destroyed or the maximum watch duration has passed. w is registered until the returned cancel function is called.
+func (s *Strategy) watchContext(ctx context.Context, w *watcher) (context.Context, context.CancelFunc) {
+	var cancel context.CancelFunc
+	if s.maxWatchDuration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.maxWatchDuration)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	w.cancel = cancel
+	if !s.lifecycle.watches.add(w) {
+		cancel()
+	}
+	return ctx, func() {
+		s.lifecycle.watches.remove(w)
+		cancel()
+	}
+}
+
+// Destroy stops accepting new operations, ends all watches by closing their channels, waits for in-flight operations
+// to finish and then closes the database. If in-flight operations don't finish within the drain timeout, the
+// database is closed anyway.
+func (s *Strategy) Destroy() {
+	s.lifecycle.destroyOnce.Do(func() {
+		s.lifecycle.lock.Lock()
+		s.lifecycle.closing = true
+		s.lifecycle.lock.Unlock()
+
+		s.cancel()
+		s.lifecycle.watches.closeAll()
+
+		done := make(chan struct{})
+		go func() {
+			s.lifecycle.inflight.Wait()
+			close(done)
+		}()
+
+		timeout := s.drainTimeout
+		if timeout == 0 {
+			timeout = defaultDrainTimeout
+		}
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			klog.Warningf("timed out after %v waiting for in-flight %s operations to finish", timeout, s.db.gvk.Kind)
+		}
+
+		s.db.Close()
+		if s.onDestroy != nil {
+			s.onDestroy()
+		}
+	})
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"iter"
 	"strconv"
@@ -11,10 +12,65 @@ import (
 	"k8s.io/apiserver/pkg/storage"
 )
 
+// WithNameOrdering orders lists by namespace and name instead of by resource version, so that pages of a paginated
+// list don't depend on which objects were updated between pages and lists are sorted as kubectl shows them. Watches
+// are still sent in resource version order. Continue tokens of lists made before the option was set are invalid.
+func WithNameOrdering() Option {
+	return func(s *Strategy) {
+		s.db.orderByName = true
+	}
+}
+
+// cursor is the position of a paginated list after the last record returned: its id, or its namespace and name if
+// lists are ordered by name. The zero cursor is the start of the list.
+type cursor struct {
+	id              int64
+	namespace, name string
+}
+
+func (c cursor) isZero() bool {
+	return c == cursor{}
+}
+
+// cursorAfter returns the cursor of a list that ended with rec.
+func (d *db) cursorAfter(rec record) cursor {
+	if d.orderByName {
+		return cursor{namespace: rec.namespace, name: rec.name}
+	}
+	return cursor{id: rec.id}
+}
+
+// continueToken returns the continue token of a list at revision rev whose page ended with rec.
+func (d *db) continueToken(rev string, rec record) string {
+	if d.orderByName {
+		// Namespaces can't contain a slash, names might
+		return rev + ":" + base64.RawURLEncoding.EncodeToString([]byte(rec.namespace+"/"+rec.name))
+	}
+	return rev + ":" + strconv.FormatInt(rec.id, 10)
+}
+
+// parseCursor parses the position part of a continue token returned by continueToken.
+func (d *db) parseCursor(token string) (cursor, error) {
+	if d.orderByName {
+		key, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return cursor{}, err
+		}
+		namespace, name, ok := strings.Cut(string(key), "/")
+		if !ok || name == "" {
+			return cursor{}, fmt.Errorf("missing name")
+		}
+		return cursor{namespace: namespace, name: name}, nil
+	}
+	id, err := strconv.ParseInt(token, 10, 64)
+	return cursor{id: id}, err
+}
+
 func newLister(ctx context.Context, db *db, namespace string, opts storage.ListOptions, after bool) (string, iter.Seq2[record, error], error) {
 	var (
-		rev, cont int64
-		err       error
+		rev  int64
+		cont cursor
+		err  error
 	)
 
 	if opts.ResourceVersion != "" {
@@ -33,12 +89,17 @@ func newLister(ctx context.Context, db *db, namespace string, opts storage.ListO
 		if err != nil {
 			return "", nil, fmt.Errorf("invalid continue token %q, failed to parse revision: %w", opts.Predicate.Continue, err)
 		}
-		cont, err = strconv.ParseInt(contAfter, 10, 64)
+		cont, err = db.parseCursor(contAfter)
 		if err != nil {
 			return "", nil, fmt.Errorf("invalid continue token %q, failed to parse: %w", opts.Predicate.Continue, err)
 		}
 	}
 
+	if !after {
+		// Watches must see the objects that stop matching the selector
+		ctx = db.withIndexFilters(ctx, opts)
+	}
+
 	listMeta, records, err := db.list(ctx, getNamespace(namespace), getName(opts), rev, after, cont, opts.Predicate.Limit)
 	if err != nil {
 		return "", nil, err
@@ -66,7 +127,7 @@ func newLister(ctx context.Context, db *db, namespace string, opts storage.ListO
 			}
 
 			// Continue to paginate records
-			_, records, err = db.list(ctx, getNamespace(namespace), getName(opts), rev, false, records[len(records)-1].id, opts.Predicate.Limit)
+			_, records, err = db.list(ctx, getNamespace(namespace), getName(opts), rev, false, db.cursorAfter(records[len(records)-1]), opts.Predicate.Limit)
 			if err != nil {
 				yield(record{}, err)
 				return
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// WithQueryLogger logs every query run by the strategy with its duration and the number of affected rows. Successful
+// queries are logged at debug level, queries slower than slowThreshold at warn level and failed queries at error
+// level, except for expected failures such as the unique constraint violations of creating an existing object, which
+// are logged at debug level. String arguments are redacted because they may contain object data.
+func WithQueryLogger(logger *slog.Logger, slowThreshold time.Duration) Option {
+	return func(s *Strategy) {
+		s.db.logger = logger
+		s.db.slowThreshold = slowThreshold
+	}
+}
+
+// logQuery logs a query that started at start. rows is the number of affected rows, or -1 if unknown.
+func (d *db) logQuery(ctx context.Context, start time.Time, query string, args []any, rows int64, err error) {
+	if d.logger == nil {
+		return
+	}
+
+	elapsed := time.Since(start)
+	level, msg := slog.LevelDebug, "sql query executed"
+	switch {
+	case err != nil && err != sql.ErrNoRows && !expectedError(err):
+		level, msg = slog.LevelError, "sql query error"
+	case d.slowThreshold > 0 && elapsed > d.slowThreshold:
+		level, msg = slog.LevelWarn, "sql query slow"
+	case err != nil && err != sql.ErrNoRows:
+		msg = "sql query failed"
+	}
+	if !d.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("table", d.stmt.TableName()),
+		slog.String("sql", query),
+		slog.Any("args", redactArgs(args)),
+		slog.Duration("elapsed", elapsed),
+	}
+	if rows >= 0 {
+		attrs = append(attrs, slog.Int64("rows", rows))
+	}
+	if err != nil && err != sql.ErrNoRows {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	d.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// expectedError returns true if err is returned to the client as part of normal operation rather than being a
+// problem of the database, such as a unique constraint violation that fails a create with AlreadyExists or a query
+// canceled because the client went away.
+func expectedError(err error) bool {
+	return isUniqueViolation(err) || errors.Is(err, context.Canceled)
+}
+
+// redactArgs hides the values of string and byte arguments, which may be names or object data. Numbers, such as ids
+// and resource versions, are kept as they are useful for debugging.
+func redactArgs(args []any) []any {
+	result := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			result[i] = fmt.Sprintf("<redacted %d bytes>", len(v))
+		case *string:
+			if v == nil {
+				result[i] = nil
+			} else {
+				result[i] = fmt.Sprintf("<redacted %d bytes>", len(*v))
+			}
+		case []byte:
+			result[i] = fmt.Sprintf("<redacted %d bytes>", len(v))
+		default:
+			result[i] = arg
+		}
+	}
+	return result
+}
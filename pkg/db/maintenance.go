@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const defaultAnalyzeThreshold = 10000
+
+// MaintenanceOptions configures the maintenance of a table after compaction. The rows deleted by compactions are
+// counted until a threshold is reached, so that frequent small compactions also lead to maintenance eventually.
+type MaintenanceOptions struct {
+	// AnalyzeThreshold is the number of deleted rows after which the table is analyzed, refreshing the statistics
+	// the query planner uses to choose indexes. The default is 10000, a negative threshold disables it.
+	AnalyzeThreshold int64
+	// VacuumThreshold is the number of deleted rows after which the space of the deleted rows is reclaimed. Zero, the
+	// default, disables it.
+	//
+	// Postgres runs a VACUUM of the table, which doesn't block reads or writes but competes with them for I/O, and is
+	// often not needed with autovacuum. Sqlite runs PRAGMA incremental_vacuum, which frees the unused pages of the
+	// whole database file; it only has an effect if the database was created with PRAGMA auto_vacuum = INCREMENTAL.
+	VacuumThreshold int64
+}
+
+// WithMaintenance configures the maintenance of the table after compaction. Without it, tables are analyzed with the
+// default threshold and not vacuumed.
+func WithMaintenance(opts MaintenanceOptions) Option {
+	return func(s *Strategy) {
+		s.db.maintenance.opts = opts
+	}
+}
+
+// maintenance counts the rows deleted since the table was last analyzed and vacuumed.
+type maintenance struct {
+	opts MaintenanceOptions
+
+	lock            sync.Mutex
+	deletedAnalyzed int64
+	deletedVacuumed int64
+}
+
+// maintain analyzes and vacuums the table once compactions have deleted enough rows, deleted being the rows deleted
+// by the last compaction.
+func (d *db) maintain(ctx context.Context, deleted int64) error {
+	m := &d.maintenance
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	analyzeThreshold := m.opts.AnalyzeThreshold
+	if analyzeThreshold == 0 {
+		analyzeThreshold = defaultAnalyzeThreshold
+	}
+	m.deletedAnalyzed += deleted
+	m.deletedVacuumed += deleted
+
+	if m.opts.VacuumThreshold > 0 && m.deletedVacuumed >= m.opts.VacuumThreshold {
+		if _, err := d.execContext(ctx, d.stmt.VacuumSQL()); err != nil {
+			return fmt.Errorf("failed to vacuum: %w", err)
+		}
+		m.deletedVacuumed = 0
+	}
+	if analyzeThreshold > 0 && m.deletedAnalyzed >= analyzeThreshold {
+		if _, err := d.execContext(ctx, d.stmt.AnalyzeSQL()); err != nil {
+			return fmt.Errorf("failed to analyze: %w", err)
+		}
+		m.deletedAnalyzed = 0
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/klog/v2"
+)
+
+// NamespaceFinalizer keeps an object of a kind stored with WithNamespaceLifecycle from being removed until every
+// object in the namespace it names has been deleted.
+const NamespaceFinalizer = "kinm.obot.ai/namespace"
+
+// WithNamespaceLifecycle makes the objects of the table namespaces, like core/v1 Namespaces: the name of each object is
+// a namespace, and deleting the object deletes every object in that namespace, in every table of the factory. The
+// NamespaceFinalizer is added to objects when they are created, and removed once the objects in the namespace are
+// gone, including objects whose deletion waits for their own finalizers. The objects are deleted through their
+// strategies, so watches see them deleted.
+//
+// The deletions are made by a controller that runs in the background of the factory until it is closed, so the option
+// has no effect other than adding the finalizer for strategies not created with Factory.NewDBStrategy.
+func WithNamespaceLifecycle() Option {
+	return func(s *Strategy) {
+		s.namespaceLifecycle = true
+	}
+}
+
+func (s *Strategy) addNamespaceFinalizer(obj types.Object) {
+	if s.namespaceLifecycle && !slices.Contains(obj.GetFinalizers(), NamespaceFinalizer) {
+		obj.SetFinalizers(append(obj.GetFinalizers(), NamespaceFinalizer))
+	}
+}
+
+// DeleteNamespace deletes every object in namespace from the tables of the strategies created by the factory and
+// returns the number of objects that remain, because their finalizers haven't been removed yet or they changed while
+// being deleted. Call it again until no objects remain.
+func (f *Factory) DeleteNamespace(ctx context.Context, namespace string) (remaining int, _ error) {
+	for _, s := range f.getStrategies() {
+		list, err := s.List(ctx, namespace, storage.ListOptions{})
+		if err != nil {
+			return 0, err
+		}
+		err = meta.EachListItem(list, func(item runtime.Object) error {
+			result, err := s.Delete(ctx, item.(types.Object))
+			if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+				// The object changed, it will be deleted on the next pass
+				remaining++
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if len(result.GetFinalizers()) > 0 {
+				remaining++
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return remaining, nil
+}
+
+// finalizeNamespaces deletes the contents of the namespaces of s that are being deleted, and removes their
+// NamespaceFinalizer once their contents are gone.
+func (f *Factory) finalizeNamespaces(ctx context.Context, s *Strategy) error {
+	list, err := s.List(ctx, "", storage.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	return meta.EachListItem(list, func(item runtime.Object) error {
+		obj := item.(types.Object)
+		if obj.GetDeletionTimestamp() == nil || !slices.Contains(obj.GetFinalizers(), NamespaceFinalizer) {
+			return nil
+		}
+
+		remaining, err := f.DeleteNamespace(ctx, obj.GetName())
+		if err != nil || remaining > 0 {
+			return err
+		}
+
+		obj.SetFinalizers(slices.DeleteFunc(obj.GetFinalizers(), func(finalizer string) bool {
+			return finalizer == NamespaceFinalizer
+		}))
+		if _, err := s.Delete(ctx, obj); apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			// The object changed, it will be checked again on the next pass
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// runNamespaceLifecycle finalizes the namespaces of s after every change made through the factory, and at least every
+// poll interval, until ctx is done or s is destroyed.
+func (f *Factory) runNamespaceLifecycle(ctx context.Context, s *Strategy) {
+	// The controller also stops when the strategy is destroyed
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(s.ctx, cancel)()
+
+	for {
+		// Get the wait channel before finalizing so that changes made meanwhile aren't missed
+		changed := f.changes.wait()
+		if err := f.finalizeNamespaces(ctx, s); err != nil && ctx.Err() == nil {
+			klog.Errorf("failed to delete the contents of deleted %s namespaces: %v", s.db.gvk.Kind, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-time.After(defaultWatchPollInterval):
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"slices"
+)
+
+// Namespaces returns the namespaces that have at least one object in the table, sorted. Objects of cluster scoped
+// kinds have no namespace and are not included. If ctx has a partition ID, only the objects of that partition are
+// considered.
+func (s *Strategy) Namespaces(ctx context.Context) ([]string, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+	return s.db.namespaces(ctx)
+}
+
+// Namespaces returns the namespaces that have at least one object in any table of the strategies created by the
+// factory, sorted. Embedders without a store of core/v1 Namespaces can use it to serve a virtual Namespace kind.
+func (f *Factory) Namespaces(ctx context.Context) ([]string, error) {
+	var result []string
+	for _, s := range f.getStrategies() {
+		namespaces, err := s.Namespaces(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, namespaces...)
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+func (d *db) namespaces(ctx context.Context) ([]string, error) {
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := d.queryContext(ctx, d.stmt.NamespacesSQL(), getPartitionID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var namespace string
+		if err := rows.Scan(&namespace); err != nil {
+			return nil, err
+		}
+		result = append(result, namespace)
+	}
+	return result, rows.Err()
+}
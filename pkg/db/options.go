@@ -0,0 +1,124 @@
+package db
+
+import (
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/db/glogrus"
+	"github.com/obot-platform/kinm/pkg/db/statements"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// FactoryOption configures optional behavior of a Factory created by NewFactory.
+type FactoryOption func(*Factory)
+
+// WithMigrationTimeout bounds the time each table migration may take when a strategy is created.
+func WithMigrationTimeout(timeout time.Duration) FactoryOption {
+	return func(f *Factory) {
+		f.migrationTimeout = timeout
+	}
+}
+
+// WithTransformer transforms the stored values of the given kind, typically to encrypt them at rest.
+func WithTransformer(gk schema.GroupKind, transformer value.Transformer) FactoryOption {
+	return func(f *Factory) {
+		if f.transformers == nil {
+			f.transformers = map[schema.GroupKind]value.Transformer{}
+		}
+		f.transformers[gk] = transformer
+	}
+}
+
+// WithPartitionRequired rejects writes that don't have a partition ID in their context.
+func WithPartitionRequired() FactoryOption {
+	return func(f *Factory) {
+		f.partitionIDRequired = true
+	}
+}
+
+// WithUndeleteSubresource serves an undelete subresource for every resource of Stores and APIGroup. Creating it, such as
+// POST /apis/group/version/namespaces/default/kinds/name/undelete, recreates the deleted object with Strategy.Undelete.
+func WithUndeleteSubresource() FactoryOption {
+	return func(f *Factory) {
+		f.undelete = true
+	}
+}
+
+// WithPoolSizes overrides the default connection pool sizes. By default sqlite uses a single connection and postgres
+// uses five.
+func WithPoolSizes(maxIdle, maxOpen int) FactoryOption {
+	return func(f *Factory) {
+		f.maxIdleConns = maxIdle
+		f.maxOpenConns = maxOpen
+	}
+}
+
+// WithLogger sets the logrus logger used for database logging. If no logger is set, logrus.StandardLogger() is used.
+func WithLogger(logger *logrus.Logger) FactoryOption {
+	return WithSlogLogger(slog.New(glogrus.NewHandler(logger)))
+}
+
+// WithSlogLogger sets the logger used for database logging. The logger is also used by every strategy created by the
+// factory to log the queries it runs at debug level.
+func WithSlogLogger(logger *slog.Logger) FactoryOption {
+	return func(f *Factory) {
+		f.logger = logger
+	}
+}
+
+// WithSlowQueryThreshold sets the duration after which queries are logged as slow. The default is 200ms.
+func WithSlowQueryThreshold(threshold time.Duration) FactoryOption {
+	return func(f *Factory) {
+		f.slowQueryThreshold = threshold
+	}
+}
+
+// WithStrategyOptions applies the given options to every strategy created by the factory. Options passed to
+// NewDBStrategy are applied after these.
+func WithStrategyOptions(opts ...Option) FactoryOption {
+	return func(f *Factory) {
+		f.strategyOptions = append(f.strategyOptions, opts...)
+	}
+}
+
+// WithTablePrefix prefixes the names of all tables created by the factory.
+func WithTablePrefix(prefix string) FactoryOption {
+	return WithStrategyOptions(WithStatementOptions(statements.WithTablePrefix(prefix)))
+}
+
+// WithSchema places all tables in the given Postgres schema, creating it if needed. This is not supported for sqlite.
+func WithSchema(schema string) FactoryOption {
+	return func(f *Factory) {
+		f.schemaName = schema
+	}
+}
+
+// defaultWaitForReadyBackoff is used by WithWaitForReady when no backoff is configured with WithConnectRetry.
+var defaultWaitForReadyBackoff = wait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    math.MaxInt32,
+	Cap:      10 * time.Second,
+}
+
+// WithConnectRetry retries the initial connection to the database according to backoff instead of failing on the
+// first error. If the database is still unreachable after backoff.Steps attempts, NewFactory returns an error for
+// which errors.IsDatabaseUnreachable is true.
+func WithConnectRetry(backoff wait.Backoff) FactoryOption {
+	return func(f *Factory) {
+		f.connectBackoff = backoff
+	}
+}
+
+// WithWaitForReady makes NewFactory wait up to timeout for the database to become reachable. Attempts are spaced by
+// the backoff from WithConnectRetry or, if not set, an exponential backoff capped at ten seconds.
+func WithWaitForReady(timeout time.Duration) FactoryOption {
+	return func(f *Factory) {
+		f.waitForReady = timeout
+	}
+}
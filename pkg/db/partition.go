@@ -0,0 +1,29 @@
+package db
+
+import "context"
+
+type partitionIDKey struct{}
+
+// WithPartitionID returns a context that scopes all reads and writes of a Strategy to the given partition. Objects
+// created with this context are stored in the partition, and reads only see objects in the partition. A context
+// without a partition ID sees objects in all partitions, unless the strategy has WithRowLevelSecurity.
+//
+// Names are unique across partitions, not within each one: creating an object fails with AlreadyExists if another
+// partition has an object of the same namespace and name, so partitions that don't trust each other should not share
+// namespaces. The values of WithUniqueFields are likewise unique across partitions.
+func WithPartitionID(ctx context.Context, partitionID string) context.Context {
+	return context.WithValue(ctx, partitionIDKey{}, partitionID)
+}
+
+// PartitionIDFrom returns the partition ID set with WithPartitionID.
+func PartitionIDFrom(ctx context.Context) (string, bool) {
+	partitionID, ok := ctx.Value(partitionIDKey{}).(string)
+	return partitionID, ok && partitionID != ""
+}
+
+func getPartitionID(ctx context.Context) *string {
+	if partitionID, ok := PartitionIDFrom(ctx); ok {
+		return &partitionID
+	}
+	return nil
+}
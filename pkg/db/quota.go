@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/obot-platform/kinm/pkg/db/errors"
+)
+
+// Quota limits the objects of a kind in a namespace. A zero limit is unlimited.
+type Quota struct {
+	// MaxObjects is the maximum number of objects.
+	MaxObjects int64
+	// MaxBytes is the maximum total size of the stored values of the objects, after compression and encryption.
+	// Values moved to a blob store only count the size of their reference.
+	MaxBytes int64
+}
+
+func (q Quota) isZero() bool {
+	return q.MaxObjects <= 0 && q.MaxBytes <= 0
+}
+
+// QuotaUsage is the usage of the quota of a namespace.
+type QuotaUsage struct {
+	// Quota is the quota of the namespace.
+	Quota Quota
+	// Objects is the number of objects.
+	Objects int64
+	// Bytes is the total size of the stored values of the objects.
+	Bytes int64
+}
+
+// quotas are the quotas of the namespaces of a table.
+type quotas struct {
+	// all is the quota of the namespaces without one of their own.
+	all        Quota
+	namespaces map[string]Quota
+}
+
+func (q *quotas) get(namespace string) Quota {
+	if quota, ok := q.namespaces[namespace]; ok {
+		return quota
+	}
+	return q.all
+}
+
+// WithQuota limits the objects of the kind in every namespace that doesn't have a quota set by WithNamespaceQuota.
+// Objects of a cluster-scoped kind are limited as if they were in a namespace named "".
+//
+// Quotas are enforced when an object is written, in the transaction of the write, which fails with a Forbidden error
+// if it would increase the usage past the quota. Writes that don't increase the usage are allowed even if the
+// namespace is already over its quota, such as after the quota was lowered, so that objects can be shrunk or deleted.
+func WithQuota(quota Quota) Option {
+	return func(s *Strategy) {
+		s.db.quotas.all = quota
+	}
+}
+
+// WithNamespaceQuota limits the objects of the kind in namespace, replacing the quota set by WithQuota. A zero quota
+// exempts the namespace.
+func WithNamespaceQuota(namespace string, quota Quota) Option {
+	return func(s *Strategy) {
+		if s.db.quotas.namespaces == nil {
+			s.db.quotas.namespaces = map[string]Quota{}
+		}
+		s.db.quotas.namespaces[namespace] = quota
+	}
+}
+
+// QuotaUsage returns the quota of namespace and how much of it is used.
+func (s *Strategy) QuotaUsage(ctx context.Context, namespace string) (QuotaUsage, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return QuotaUsage{}, err
+	}
+	defer s.endRequest(readOnlyRequest)
+
+	usage, _, err := s.db.quotaUsage(ctx, namespace, "")
+	return usage, err
+}
+
+// quotaUsage returns the usage of namespace and the size of the stored value of the named object, which is zero if
+// the object doesn't exist.
+func (d *db) quotaUsage(ctx context.Context, namespace, name string) (usage QuotaUsage, size int64, _ error) {
+	usage.Quota = d.quotas.get(namespace)
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return usage, 0, err
+	}
+	defer tx.Rollback()
+	err = d.queryRowContext(ctx, d.stmt.QuotaUsageSQL(), namespace, name).Scan(&usage.Objects, &usage.Bytes, &size)
+	return usage, size, err
+}
+
+// checkQuota returns a Forbidden error if writing rec with the stored value would exceed the quota of its namespace.
+// It must be called in the transaction of the write, after the table is locked.
+func (d *db) checkQuota(ctx context.Context, rec record, value string) error {
+	quota := d.quotas.get(rec.namespace)
+	if quota.isZero() || rec.deleted == 1 {
+		return nil
+	}
+
+	usage, size, err := d.quotaUsage(ctx, rec.namespace, rec.name)
+	if err != nil {
+		return err
+	}
+	objects, bytes := usage.Objects, usage.Bytes-size+int64(len(value))
+	if rec.created == 1 {
+		objects++
+	}
+
+	if quota.MaxObjects > 0 && objects > quota.MaxObjects && objects > usage.Objects {
+		return errors.NewQuotaExceeded(d.gvk, rec.namespace, rec.name,
+			fmt.Sprintf("requested 1 object, used %d, limited to %d", usage.Objects, quota.MaxObjects))
+	}
+	if quota.MaxBytes > 0 && bytes > quota.MaxBytes && bytes > usage.Bytes {
+		return errors.NewQuotaExceeded(d.gvk, rec.namespace, rec.name,
+			fmt.Sprintf("requested %d bytes, used %d, limited to %d", len(value), usage.Bytes-size, quota.MaxBytes))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// WithRateLimit limits the operations on the table to qps per second on average, allowing bursts of up to burst
+// operations. Opening a watch counts as one operation. Operations over the limit fail with a TooManyRequests error
+// whose retry-after is the time until the operation would have been allowed.
+func WithRateLimit(qps float64, burst int) Option {
+	return func(s *Strategy) {
+		s.limits.limiter = rate.NewLimiter(rate.Limit(qps), burst)
+	}
+}
+
+// WithMaxInflight limits the number of concurrent get, list, create, update and delete operations on the table.
+// Operations over the limit fail with a TooManyRequests error instead of queuing for a database connection.
+func WithMaxInflight(max int) Option {
+	return func(s *Strategy) {
+		s.limits.maxInflight = int64(max)
+	}
+}
+
+// WithMaxWatches limits the number of concurrent watches of the table. Watches over the limit fail with a
+// TooManyRequests error.
+func WithMaxWatches(max int) Option {
+	return func(s *Strategy) {
+		s.limits.maxWatches = int64(max)
+	}
+}
+
+// WithMaxListSize limits the size of lists of the table to maxObjects objects and maxBytes bytes of stored values, so
+// that a single list of a large table can't exhaust the memory of the server. A limit of zero is no limit.
+//
+// A list without a limit that exceeds either limit fails with a BadRequest error telling the client to paginate. A
+// paginated list returns at most maxObjects objects, and ends its page early with a continue token once maxBytes is
+// reached, as clients must already handle pages shorter than the limit they asked for.
+func WithMaxListSize(maxObjects int, maxBytes int64) Option {
+	return func(s *Strategy) {
+		s.limits.maxListObjects = int64(maxObjects)
+		s.limits.maxListBytes = maxBytes
+	}
+}
+
+// limits guards a table against callers that would starve the connection pool for everybody else.
+type limits struct {
+	limiter        *rate.Limiter
+	maxInflight    int64
+	maxWatches     int64
+	maxListObjects int64
+	maxListBytes   int64
+	concurrency    *ConcurrencyLimiter
+	inflight       atomic.Int64
+	watches        atomic.Int64
+}
+
+// listTooLarge returns the error of an unpaginated list that exceeded the list size limits.
+func (l *limits) listTooLarge(kind string) error {
+	var limit string
+	switch {
+	case l.maxListObjects > 0 && l.maxListBytes > 0:
+		limit = fmt.Sprintf("%d objects or %d bytes", l.maxListObjects, l.maxListBytes)
+	case l.maxListObjects > 0:
+		limit = fmt.Sprintf("%d objects", l.maxListObjects)
+	default:
+		limit = fmt.Sprintf("%d bytes", l.maxListBytes)
+	}
+	return apierrors.NewBadRequest(fmt.Sprintf("list of %s exceeds the maximum of %s, use limit and continue to paginate", kind, limit))
+}
+
+// allow takes a token from the rate limiter without waiting for one.
+func (l *limits) allow(kind string) error {
+	if l.limiter == nil {
+		return nil
+	}
+	reservation := l.limiter.Reserve()
+	if !reservation.OK() {
+		return apierrors.NewTooManyRequests(fmt.Sprintf("rate limit exceeded for %s", kind), 1)
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return apierrors.NewTooManyRequests(fmt.Sprintf("rate limit exceeded for %s", kind), int(math.Ceil(delay.Seconds())))
+	}
+	return nil
+}
+
+// acquire takes a slot of counter if it has fewer than max in use. A max of zero means no limit.
+func acquire(counter *atomic.Int64, max int64) bool {
+	if counter.Add(1) > max && max > 0 {
+		counter.Add(-1)
+		return false
+	}
+	return true
+}
+
+// beginRequest registers a non-watch operation of class, applying the rate, in-flight and concurrency limits. Every
+// successful call must be paired with a call to endRequest.
+func (s *Strategy) beginRequest(ctx context.Context, class requestClass) error {
+	if err := s.limits.allow(s.db.gvk.Kind); err != nil {
+		return err
+	}
+	if !acquire(&s.limits.inflight, s.limits.maxInflight) {
+		return apierrors.NewTooManyRequests(fmt.Sprintf("too many concurrent requests for %s", s.db.gvk.Kind), 1)
+	}
+	if err := s.limits.concurrency.acquire(ctx, class, s.db.gvk.Kind); err != nil {
+		s.limits.inflight.Add(-1)
+		return err
+	}
+	if err := s.begin(); err != nil {
+		s.limits.concurrency.release(class)
+		s.limits.inflight.Add(-1)
+		return err
+	}
+	return nil
+}
+
+func (s *Strategy) endRequest(class requestClass) {
+	s.end()
+	s.limits.concurrency.release(class)
+	s.limits.inflight.Add(-1)
+}
+
+// beginWatch registers a watch, applying the rate and watch limits. Every successful call must be paired with a call
+// to endWatch.
+func (s *Strategy) beginWatch() error {
+	if err := s.limits.allow(s.db.gvk.Kind); err != nil {
+		return err
+	}
+	if !acquire(&s.limits.watches, s.limits.maxWatches) {
+		return apierrors.NewTooManyRequests(fmt.Sprintf("too many concurrent watches for %s", s.db.gvk.Kind), 1)
+	}
+	if err := s.begin(); err != nil {
+		s.limits.watches.Add(-1)
+		return err
+	}
+	return nil
+}
+
+func (s *Strategy) endWatch() {
+	s.end()
+	s.limits.watches.Add(-1)
+}
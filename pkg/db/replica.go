@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"k8s.io/klog/v2"
+)
+
+// WithReadReplicas routes the reads of lists, gets and watches to the given Postgres read replicas, in turn, to
+// reduce the load on the primary database given to NewFactory. Writes, transactions and history always use the
+// primary.
+//
+// A replica is only used if it has caught up with the revision being read and with the latest write made through the
+// factory, so a client always reads its own writes; otherwise the read falls back to the primary. Writes made by
+// other processes may be seen late by reads at the latest revision, up to the replication lag. Reads also fall back
+// to the primary if the replica fails.
+func WithReadReplicas(dsns ...string) FactoryOption {
+	return func(f *Factory) {
+		f.replicaDSNs = append(f.replicaDSNs, dsns...)
+	}
+}
+
+// replicaPool is the read replicas of a factory.
+type replicaPool struct {
+	dbs  []*sql.DB
+	next atomic.Uint64
+}
+
+func (f *Factory) openReplicas() (*replicaPool, error) {
+	pool := &replicaPool{}
+	for _, dsn := range f.replicaDSNs {
+		dsn, err := f.postgresDSN(strings.Replace(dsn, "postgresql://", "postgres://", 1))
+		if err != nil {
+			return nil, err
+		}
+		sqlDB, err := sql.Open("pgx", dsn)
+		if err != nil {
+			_ = pool.close()
+			return nil, fmt.Errorf("failed to open read replica: %w", err)
+		}
+		sqlDB.SetMaxIdleConns(f.maxIdleConns)
+		sqlDB.SetMaxOpenConns(f.maxOpenConns)
+		pool.dbs = append(pool.dbs, sqlDB)
+	}
+	return pool, nil
+}
+
+func (r *replicaPool) pick() *sql.DB {
+	return r.dbs[(r.next.Add(1)-1)%uint64(len(r.dbs))]
+}
+
+func (r *replicaPool) close() error {
+	var err error
+	for _, sqlDB := range r.dbs {
+		if closeErr := sqlDB.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// observeWrite records a committed write, so that reads are not routed to replicas that haven't replicated it yet.
+func (d *db) observeWrite(id int64) {
+	if d.written == nil {
+		return
+	}
+	for {
+		last := d.written.Load()
+		if id <= last || d.written.CompareAndSwap(last, id) {
+			return
+		}
+	}
+}
+
+// listReplica runs a list on a read replica. ok is false if there is no replica, the read is part of a transaction on
+// the primary, or the replica is behind or failed, in which case the list must run on the primary.
+func (d *db) listReplica(ctx context.Context, namespace, name *string, rev int64, after bool, cont cursor, limit int64) (_ tableMeta, _ []record, ok bool) {
+	if d.replicas == nil || len(d.replicas.dbs) == 0 {
+		return tableMeta{}, nil, false
+	}
+	if _, inTx := ctx.Value(txKey{}).(*sql.Tx); inTx {
+		return tableMeta{}, nil, false
+	}
+
+	tx, err := d.replicas.pick().BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		klog.V(4).Infof("failed to read %s from replica: %v", d.stmt.TableName(), err)
+		return tableMeta{}, nil, false
+	}
+	defer tx.Rollback()
+	if err := d.setTenant(ctx, tx); err != nil {
+		klog.V(4).Infof("failed to read %s from replica: %v", d.stmt.TableName(), err)
+		return tableMeta{}, nil, false
+	}
+	ctx = context.WithValue(ctx, txKey{}, tx)
+
+	head, err := d.getTableMeta(ctx)
+	if err != nil {
+		klog.V(4).Infof("failed to read %s from replica: %v", d.stmt.TableName(), err)
+		return tableMeta{}, nil, false
+	}
+	if head.ListID < rev || head.ListID < d.written.Load() {
+		return tableMeta{}, nil, false
+	}
+
+	// Errors such as compaction errors are returned by the primary as well, so they don't need to be told apart
+	meta, records, err := d.listTx(ctx, tx, namespace, name, rev, after, cont, limit)
+	if err != nil {
+		return tableMeta{}, nil, false
+	}
+	return meta, records, true
+}
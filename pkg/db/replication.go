@@ -0,0 +1,484 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"k8s.io/klog/v2"
+)
+
+const (
+	replicaPrefix         = "replica-"
+	replicaSnapshotSuffix = "-snapshot.db.zst"
+	replicaSegmentSuffix  = ".wal.zst"
+
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+)
+
+// ReplicaTarget stores the files of a replica written by WithWALReplication, typically in an object store such as S3.
+// Names are flat, without directories, so a BackupStore that can also read files back is a ReplicaTarget.
+type ReplicaTarget interface {
+	BackupStore
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// ReplicationOptions configures WithWALReplication.
+type ReplicationOptions struct {
+	// Interval is the time between shipping new WAL frames to the target, and bounds the writes lost with the
+	// database. The default is one second.
+	Interval time.Duration
+	// CheckpointBytes is the size of the WAL at which it is checkpointed into the database. The default is 4MiB.
+	CheckpointBytes int64
+	// Restore restores the database from the target when the factory is created if the database file doesn't exist.
+	Restore bool
+}
+
+// WithWALReplication continuously replicates a sqlite database to target by shipping its write-ahead log, in the
+// manner of Litestream. The replica is made of generations, each starting with a copy of the database file followed
+// by the WAL frames written since, split at every checkpoint. Only the latest generation is kept. RestoreReplica
+// rebuilds the database from the replica.
+//
+// Replication takes over checkpointing from sqlite so that no frame is checkpointed before it was shipped, and relies
+// on every write going through the factory; the database must not be written by other processes. It is not
+// supported for Postgres.
+func WithWALReplication(target ReplicaTarget, opts ReplicationOptions) FactoryOption {
+	return func(f *Factory) {
+		if opts.Interval == 0 {
+			opts.Interval = time.Second
+		}
+		if opts.CheckpointBytes == 0 {
+			opts.CheckpointBytes = 4 << 20
+		}
+		f.replicaTarget = target
+		f.replicationOptions = opts
+	}
+}
+
+// Replicate ships the WAL frames written since the last time the replica was updated, without waiting for the
+// replication interval.
+func (f *Factory) Replicate(ctx context.Context) error {
+	if f.replicator == nil {
+		return fmt.Errorf("WAL replication is not enabled")
+	}
+	return f.replicator.sync(ctx)
+}
+
+// prepareReplication restores the database file if configured and returns the dsn with the pragmas replication needs
+// on every connection.
+func (f *Factory) prepareReplication(dsn string) (string, error) {
+	path, _, _ := strings.Cut(dsn, "?")
+	if f.replicationOptions.Restore {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			restored, err := RestoreReplica(context.Background(), f.replicaTarget, path)
+			if err != nil {
+				return "", fmt.Errorf("failed to restore %s from replica: %w", path, err)
+			}
+			if restored {
+				klog.Infof("restored %s from replica", path)
+			}
+		}
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=journal_mode(WAL)&_pragma=wal_autocheckpoint(0)", nil
+}
+
+type replicator struct {
+	lock   sync.Mutex
+	path   string
+	target ReplicaTarget
+	opts   ReplicationOptions
+	// sqlDB is the pool of the factory. sqlite only has a single connection, so holding it blocks all writers.
+	sqlDB *sql.DB
+	// hold keeps the database open, since closing its last connection checkpoints the WAL and deletes it.
+	hold *sql.DB
+
+	// generation is empty until the first copy of the database is shipped, or after frames were lost.
+	generation string
+	epoch      int
+	salt       []byte
+	frames     int64
+}
+
+func newReplicator(path string, target ReplicaTarget, opts ReplicationOptions, sqlDB *sql.DB) (*replicator, error) {
+	hold, err := sql.Open("sqlite", path+"?_pragma=wal_autocheckpoint(0)")
+	if err != nil {
+		return nil, err
+	}
+	hold.SetMaxOpenConns(1)
+	hold.SetMaxIdleConns(1)
+	hold.SetConnMaxLifetime(0)
+	if err := hold.Ping(); err != nil {
+		_ = hold.Close()
+		return nil, err
+	}
+	return &replicator{
+		path:   path,
+		target: target,
+		opts:   opts,
+		sqlDB:  sqlDB,
+		hold:   hold,
+	}, nil
+}
+
+// run ships the WAL every interval until ctx is done.
+func (r *replicator) run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.sync(ctx); err != nil {
+				klog.Errorf("failed to replicate %s: %v", r.path, err)
+			}
+		}
+	}
+}
+
+func (r *replicator) close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.hold.Close()
+}
+
+func (r *replicator) sync(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.generation == "" {
+		if err := r.startGeneration(ctx); err != nil {
+			return err
+		}
+	}
+	size, err := r.ship(ctx)
+	if err != nil {
+		return err
+	}
+	if size >= r.opts.CheckpointBytes {
+		return r.checkpoint(ctx)
+	}
+	return nil
+}
+
+// startGeneration checkpoints the WAL and ships a copy of the database file, which is not written again until the
+// next checkpoint.
+func (r *replicator) startGeneration(ctx context.Context) error {
+	conn, err := r.sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	err = truncateWAL(ctx, conn)
+	_ = conn.Close()
+	if err != nil {
+		return err
+	}
+
+	generation := time.Now().UTC().Format(backupTimeFormat)
+	if err := r.put(ctx, replicaPrefix+generation+replicaSnapshotSuffix, func(w io.Writer) error {
+		f, err := os.Open(r.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}); err != nil {
+		return err
+	}
+	r.generation, r.epoch, r.salt, r.frames = generation, 0, nil, 0
+
+	// Older generations are no longer needed
+	names, err := r.target.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if gen, ok := replicaGeneration(name); ok && gen < generation {
+			if err := r.target.Delete(ctx, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ship ships the committed frames of the WAL that were not shipped yet and returns the size of the WAL.
+func (r *replicator) ship(ctx context.Context) (int64, error) {
+	wal, err := os.ReadFile(r.path + "-wal")
+	if errors.Is(err, fs.ErrNotExist) || len(wal) < walHeaderSize {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	salt := wal[16:24]
+	if r.salt != nil && !bytes.Equal(salt, r.salt) {
+		// The WAL was restarted without a checkpoint by the replicator, so frames may have been lost
+		r.generation = ""
+		return 0, fmt.Errorf("WAL of %s was restarted outside of replication, starting a new generation", r.path)
+	}
+
+	frameSize := int64(walFrameHeaderSize + binary.BigEndian.Uint32(wal[8:12]))
+	var committed int64
+	for i := r.frames; walHeaderSize+(i+1)*frameSize <= int64(len(wal)); i++ {
+		frame := wal[walHeaderSize+i*frameSize:]
+		if !bytes.Equal(frame[8:16], salt) {
+			break
+		}
+		if binary.BigEndian.Uint32(frame[4:8]) != 0 {
+			committed = i + 1
+		}
+	}
+	if committed <= r.frames {
+		return int64(len(wal)), nil
+	}
+
+	data := wal[walHeaderSize+r.frames*frameSize : walHeaderSize+committed*frameSize]
+	if r.frames == 0 {
+		data = wal[:walHeaderSize+committed*frameSize]
+	}
+	name := fmt.Sprintf("%s%s-%08d-%012d%s", replicaPrefix, r.generation, r.epoch, r.frames, replicaSegmentSuffix)
+	if err := r.put(ctx, name, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+	r.salt = bytes.Clone(salt)
+	r.frames = committed
+	return int64(len(wal)), nil
+}
+
+// checkpoint blocks writers, ships the frames written since the last sync and checkpoints the WAL, starting a new
+// epoch of the generation.
+func (r *replicator) checkpoint(ctx context.Context) error {
+	conn, err := r.sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := r.ship(ctx); err != nil {
+		return err
+	}
+	if err := truncateWAL(ctx, conn); err != nil {
+		// Readers may keep the checkpoint from completing, try again at the next sync
+		klog.V(4).Infof("failed to checkpoint %s: %v", r.path, err)
+		return nil
+	}
+	r.epoch, r.salt, r.frames = r.epoch+1, nil, 0
+	return nil
+}
+
+func (r *replicator) put(ctx context.Context, name string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp("", "kinm-replica-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	enc, err := zstd.NewWriter(tmp)
+	if err != nil {
+		return err
+	}
+	if err := write(enc); err != nil {
+		_ = enc.Close()
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return r.target.Put(ctx, name, tmp)
+}
+
+// truncateWAL checkpoints the whole WAL into the database and truncates it.
+func truncateWAL(ctx context.Context, conn *sql.Conn) error {
+	var busy, log, checkpointed int
+	if err := conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &log, &checkpointed); err != nil {
+		return err
+	}
+	if busy != 0 {
+		return fmt.Errorf("checkpoint blocked by readers")
+	}
+	return nil
+}
+
+// replicaGeneration returns the generation of a file of a replica.
+func replicaGeneration(name string) (string, bool) {
+	rest, ok := strings.CutPrefix(name, replicaPrefix)
+	if !ok || len(rest) < len(backupTimeFormat) {
+		return "", false
+	}
+	return rest[:len(backupTimeFormat)], true
+}
+
+// RestoreReplica rebuilds the sqlite database at path from the latest generation of the replica in target, replaying
+// the WAL frames of each epoch on top of the copy of the database. It returns false if the target holds no replica.
+// The database must not be open.
+func RestoreReplica(ctx context.Context, target ReplicaTarget, path string) (bool, error) {
+	names, err := target.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	var generation string
+	for _, name := range names {
+		if gen, ok := replicaGeneration(name); ok && strings.HasSuffix(name, replicaSnapshotSuffix) && gen > generation {
+			generation = gen
+		}
+	}
+	if generation == "" {
+		return false, nil
+	}
+
+	// Segments are named by generation, epoch and first frame, all zero padded, so they sort in the order to replay
+	var segments []string
+	for _, name := range names {
+		if gen, ok := replicaGeneration(name); ok && gen == generation && strings.HasSuffix(name, replicaSegmentSuffix) {
+			segments = append(segments, name)
+		}
+	}
+	slices.Sort(segments)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return false, err
+	}
+	tmp := path + ".restore"
+	defer removeDatabaseFiles(tmp)
+
+	if err := getReplicaFile(ctx, target, replicaPrefix+generation+replicaSnapshotSuffix, tmp, false); err != nil {
+		return false, err
+	}
+
+	var (
+		epoch  = -1
+		frames int64
+	)
+	for _, name := range segments {
+		fields := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, replicaPrefix+generation+"-"), replicaSegmentSuffix), "-")
+		if len(fields) != 2 {
+			continue
+		}
+		segmentEpoch, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return false, fmt.Errorf("invalid replica segment %s", name)
+		}
+		first, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid replica segment %s", name)
+		}
+
+		if segmentEpoch != epoch {
+			if err := checkpointRestored(ctx, tmp); err != nil {
+				return false, err
+			}
+			epoch, frames = segmentEpoch, 0
+		}
+		if first != frames {
+			return false, fmt.Errorf("replica segment %s doesn't follow frame %d", name, frames)
+		}
+		if err := getReplicaFile(ctx, target, name, tmp+"-wal", true); err != nil {
+			return false, err
+		}
+		if frames, err = walFrames(tmp + "-wal"); err != nil {
+			return false, err
+		}
+	}
+	if err := checkpointRestored(ctx, tmp); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, path)
+}
+
+// getReplicaFile decompresses a file of a replica to path, appending to it if requested.
+func getReplicaFile(ctx context.Context, target ReplicaTarget, name, path string, appendTo bool) error {
+	src, err := target.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dec, err := zstd.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	dst, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, dec); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// walFrames returns the number of frames in a WAL file.
+func walFrames(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return 0, err
+	}
+	return (info.Size() - walHeaderSize) / int64(walFrameHeaderSize+binary.BigEndian.Uint32(header[8:12])), nil
+}
+
+// checkpointRestored applies the WAL of a database being restored, if any, to the database file.
+func checkpointRestored(ctx context.Context, path string) error {
+	if _, err := os.Stat(path + "-wal"); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return truncateWAL(ctx, conn)
+}
+
+func removeDatabaseFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		_ = os.Remove(path + suffix)
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// WithRowLevelSecurity enforces partitions in Postgres with row-level security, so that a bug or an injected query
+// can't read or write the rows of another partition. The table gets a policy that only allows the rows whose
+// partition_id is the setting app.tenant_id, which is set to the partition ID of the context in every transaction of
+// the strategy. The policy fails closed: a query without the setting, such as one outside of a transaction or one
+// that cleared it, sees no rows, and so do transactions whose context has no partition ID. Use it with
+// WithPartitionRequired so that writes always set the partition, and pass it to every strategy with
+// WithStrategyOptions.
+//
+// Compaction and the other maintenance of the strategy, Admin and requests whose context comes from
+// WithRowLevelSecurityBypass set app.tenant_bypass instead, which allows every row. The policy applies to the owner of
+// the table as well, but not to superusers or roles with BYPASSRLS, so the database user of kinm must not be one. The
+// auxiliary tables of indexes, search and unique fields are not covered. Removing the option leaves the policy in
+// place, which then hides every row from the strategy, so disable row-level security on the table first. Sqlite
+// doesn't support row-level security.
+func WithRowLevelSecurity() Option {
+	return func(s *Strategy) {
+		s.db.rowLevelSecurity = true
+	}
+}
+
+// enableRowLevelSecurity creates the row-level security policy of the table, if configured.
+func (d *db) enableRowLevelSecurity(ctx context.Context) error {
+	if !d.rowLevelSecurity {
+		return nil
+	}
+	if d.stmt.RowSecuritySQL() == "" {
+		return fmt.Errorf("row-level security is not supported by %s", d.stmt.Dialect())
+	}
+
+	ctx, tx, err := d.beginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Concurrent processes replace the policy one at a time
+	if _, err := d.execContext(ctx, d.stmt.TableLockSQL()); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.RowSecuritySQL()); err != nil {
+		return fmt.Errorf("failed to enable row-level security on %s: %w", d.stmt.TableName(), err)
+	}
+	return tx.Commit()
+}
+
+type rowLevelSecurityBypassKey struct{}
+
+// WithRowLevelSecurityBypass returns a context whose transactions see and write the rows of all partitions despite
+// WithRowLevelSecurity, for requests that span partitions such as controllers of every tenant. A partition ID in the
+// context takes precedence. Without row-level security a context without a partition ID already sees every partition.
+func WithRowLevelSecurityBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rowLevelSecurityBypassKey{}, true)
+}
+
+func bypassesRowLevelSecurity(ctx context.Context) bool {
+	bypass, _ := ctx.Value(rowLevelSecurityBypassKey{}).(bool)
+	return bypass
+}
+
+// setTenant sets the partition of ctx as the partition the row-level security policy allows in the transaction tx, or
+// bypasses the policy if ctx or the table bypasses it and has no partition. Both settings are always set, so that a
+// transaction doesn't depend on what an earlier one left on the connection.
+func (d *db) setTenant(ctx context.Context, tx *sql.Tx) error {
+	if !d.rowLevelSecurity || d.stmt.SetTenantSQL() == "" {
+		return nil
+	}
+	partitionID, ok := PartitionIDFrom(ctx)
+	bypass := "off"
+	if !ok && (d.bypassRowLevelSecurity || bypassesRowLevelSecurity(ctx)) {
+		bypass = "on"
+	}
+	_, err := tx.ExecContext(ctx, d.stmt.SetTenantSQL(), partitionID, bypass)
+	return err
+}
+
+// tenantTx returns ctx in a transaction if row-level security is enabled and ctx isn't in one yet. The tenant is a
+// setting of the transaction, so a query outside of one sees no rows. The caller commits the transaction if it wrote
+// and rolls it back otherwise.
+func (d *db) tenantTx(ctx context.Context) (context.Context, tx, error) {
+	if !d.rowLevelSecurity {
+		return ctx, noopTx{}, nil
+	}
+	return d.beginTx(ctx, nil)
+}
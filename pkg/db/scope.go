@@ -0,0 +1,41 @@
+package db
+
+import (
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// WithClusterScoped makes the kind of the strategy cluster scoped, for types that don't implement
+// strategy.NamespaceScoper. Objects of cluster scoped kinds are stored with an empty namespace, and writing an object
+// with a namespace is rejected.
+func WithClusterScoped() Option {
+	return func(s *Strategy) {
+		s.clusterScoped = true
+	}
+}
+
+// NamespaceScoped returns false for kinds made cluster scoped with WithClusterScoped, otherwise whether the objects of
+// the strategy are namespace scoped, which they are unless they implement strategy.NamespaceScoper.
+func (s *Strategy) NamespaceScoped() bool {
+	if s.clusterScoped {
+		return false
+	}
+	if scoper, ok := s.objTemplate.(strategy.NamespaceScoper); ok {
+		return scoper.NamespaceScoped()
+	}
+	return true
+}
+
+// validateScope rejects an object with a namespace for a cluster scoped kind.
+func (s *Strategy) validateScope(obj types.Object) error {
+	if obj.GetNamespace() != "" && !s.NamespaceScoped() {
+		return apierrors.NewInvalid(s.db.gvk.GroupKind(), obj.GetName(), field.ErrorList{scopeError()})
+	}
+	return nil
+}
+
+func scopeError() *field.Error {
+	return field.Forbidden(field.NewPath("metadata", "namespace"), "namespace must not be set for cluster scoped resource")
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// WithSearch enables Strategy.Search over the strings found at the given dot separated paths in the JSON of the
+// objects, such as "spec.content". A path to an object or array searches every string below it.
+//
+// The text is indexed in an auxiliary table, named after the table with a "_search" suffix, which is a full-text
+// search table of sqlite (FTS5) or a table with a tsvector column in Postgres. Words are matched whole and without
+// stemming, so that both databases return the same objects. The auxiliary table is rebuilt from the current objects
+// when the strategy is created.
+func WithSearch(paths ...string) Option {
+	return func(s *Strategy) {
+		s.db.searchPaths = append(s.db.searchPaths, paths...)
+	}
+}
+
+// Search returns the objects in namespace, or in all namespaces if namespace is empty, whose text contains every word
+// of query, ordered by relevance. The predicate of opts filters the results and its limit caps their number;
+// continuing a search is not supported. The table must be stored with WithSearch.
+func (s *Strategy) Search(ctx context.Context, namespace, query string, opts storage.ListOptions) (types.ObjectList, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+
+	if len(s.db.searchPaths) == 0 {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("search is not enabled for %s", s.db.gvk.Kind))
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, apierrors.NewBadRequest("search query must not be empty")
+	}
+	if opts.Predicate.Continue != "" {
+		return nil, apierrors.NewBadRequest("continuing a search is not supported")
+	}
+
+	opts, err := s.prepareList(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	head, records, err := s.db.search(ctx, getNamespace(namespace), query)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []runtime.Object
+	for _, rec := range records {
+		obj := s.New()
+		if err := rec.Unmarshal(obj); err != nil {
+			return nil, err
+		}
+		if match, err := opts.Predicate.Matches(obj); err != nil {
+			return nil, err
+		} else if !match {
+			continue
+		}
+		objs = append(objs, obj)
+		if opts.Predicate.Limit > 0 && len(objs) >= int(opts.Predicate.Limit) {
+			break
+		}
+	}
+
+	listResult := s.NewList()
+	listResult.SetResourceVersion(strconv.FormatInt(head.ListID, 10))
+	return listResult, meta.SetList(listResult, objs)
+}
+
+// search returns the objects that match query, ordered by relevance, as of the revision of the returned table meta.
+func (d *db) search(ctx context.Context, namespace *string, query string) (tableMeta, []record, error) {
+	ctx, tx, err := d.beginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return tableMeta{}, nil, err
+	}
+	defer tx.Rollback()
+
+	head, err := d.getTableMeta(ctx)
+	if err != nil {
+		return tableMeta{}, nil, err
+	}
+
+	ids, err := d.queryIDs(ctx, d.stmt.SearchSQL(), d.stmt.SearchQuery(query), namespace)
+	if err != nil {
+		return tableMeta{}, nil, err
+	}
+
+	partitionID, partitioned := PartitionIDFrom(ctx)
+	var records []record
+	for _, id := range ids {
+		rec, err := d.getByID(ctx, id)
+		if err != nil {
+			return tableMeta{}, nil, err
+		}
+		if partitioned && rec.partitionID != partitionID {
+			continue
+		}
+		records = append(records, *rec)
+	}
+	return head, records, tx.Commit()
+}
+
+// rebuildSearch creates the auxiliary table of the searched text and fills it from the current objects.
+func (d *db) rebuildSearch(ctx context.Context) error {
+	if len(d.searchPaths) == 0 {
+		return nil
+	}
+
+	ctx, tx, err := d.beginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := d.execContext(ctx, d.stmt.SearchCreateSQL()); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.SearchCreateIndexSQL()); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.TableLockSQL()); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.SearchClearSQL()); err != nil {
+		return err
+	}
+	_, records, err := d.list(ctx, nil, nil, 0, false, cursor{}, 0)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if err := d.insertSearch(ctx, rec.id, rec); err != nil {
+			return fmt.Errorf("failed to index text of %s %s/%s: %w", d.gvk.Kind, rec.namespace, rec.name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// updateSearch replaces the text of the previous revision of rec with the text of rec, which was written with id in
+// the transaction in ctx.
+func (d *db) updateSearch(ctx context.Context, id int64, rec record) error {
+	if len(d.searchPaths) == 0 {
+		return nil
+	}
+	if rec.previousID != nil {
+		if _, err := d.execContext(ctx, d.stmt.SearchDeleteSQL(), *rec.previousID); err != nil {
+			return err
+		}
+	}
+	if rec.deleted == 1 {
+		return nil
+	}
+	return d.insertSearch(ctx, id, rec)
+}
+
+func (d *db) insertSearch(ctx context.Context, id int64, rec record) error {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(rec.value), &obj); err != nil {
+		return err
+	}
+	var text []string
+	for _, path := range d.searchPaths {
+		var value any = obj
+		for _, key := range strings.Split(path, ".") {
+			m, _ := value.(map[string]any)
+			value = m[key]
+		}
+		text = appendStrings(text, value)
+	}
+	_, err := d.execContext(ctx, d.stmt.SearchInsertSQL(), id, rec.namespace, rec.name, strings.Join(text, "\n"))
+	return err
+}
+
+// appendStrings appends the strings in value, and in the objects and arrays below it, to text.
+func appendStrings(text []string, value any) []string {
+	switch v := value.(type) {
+	case string:
+		return append(text, v)
+	case []any:
+		for _, item := range v {
+			text = appendStrings(text, item)
+		}
+	case map[string]any:
+		for _, item := range v {
+			text = appendStrings(text, item)
+		}
+	}
+	return text
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// SeedOptions configures Seed.
+type SeedOptions struct {
+	// Update updates existing objects to match their manifests, like an apply that owns every field of the manifest.
+	// Labels and annotations of the manifest are added to those of the object, and the rest of the metadata and the
+	// status of the object are kept. By default existing objects are left unchanged.
+	Update bool
+}
+
+// SeedResult counts the objects handled by Seed.
+type SeedResult struct {
+	Created   int
+	Updated   int
+	Unchanged int
+}
+
+// Seed applies the YAML and JSON manifests in fsys, so that embedders can ship default objects. Files with the
+// extensions .yaml, .yml and .json are read in lexical order, and may contain several documents and v1 Lists. Every
+// kind in the manifests must have a strategy created by the factory, so Seed is called at startup after the
+// strategies are created. Objects without a UID are given one.
+//
+// Seed is idempotent: objects that don't exist are created, and existing objects are only updated if opts.Update is
+// set and they differ from their manifest. It stops at the first error, leaving the objects applied before it.
+func (f *Factory) Seed(ctx context.Context, fsys fs.FS, opts SeedOptions) (SeedResult, error) {
+	var result SeedResult
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(path.Ext(name)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		file, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		return f.seedFile(ctx, file, opts, &result)
+	})
+	return result, err
+}
+
+func (f *Factory) seedFile(ctx context.Context, r io.Reader, opts SeedOptions, result *SeedResult) error {
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var manifest unstructured.Unstructured
+		if err := decoder.Decode(&manifest.Object); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(manifest.Object) == 0 {
+			continue
+		}
+
+		if manifest.IsList() {
+			err := manifest.EachListItem(func(item runtime.Object) error {
+				return f.seedObject(ctx, item.(*unstructured.Unstructured), opts, result)
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := f.seedObject(ctx, &manifest, opts, result); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *Factory) seedObject(ctx context.Context, manifest *unstructured.Unstructured, opts SeedOptions, result *SeedResult) error {
+	s, err := f.StrategyFor(manifest.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	id := manifest.GetName()
+	if manifest.GetNamespace() != "" {
+		id = manifest.GetNamespace() + "/" + id
+	}
+
+	existing, err := s.Get(ctx, manifest.GetNamespace(), manifest.GetName())
+	if apierrors.IsNotFound(err) {
+		obj := s.New()
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(manifest.Object, obj); err != nil {
+			return fmt.Errorf("invalid %s %s: %w", manifest.GetKind(), id, err)
+		}
+		obj.SetResourceVersion("")
+		if obj.GetUID() == "" {
+			obj.SetUID(uuid.NewUUID())
+		}
+		if _, err := s.Create(ctx, obj); err != nil {
+			return fmt.Errorf("failed to create %s %s: %w", manifest.GetKind(), id, err)
+		}
+		result.Created++
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !opts.Update {
+		result.Unchanged++
+		return nil
+	}
+	updated, err := seedUpdate(s, existing, manifest)
+	if err != nil {
+		return fmt.Errorf("invalid %s %s: %w", manifest.GetKind(), id, err)
+	}
+	if updated == nil {
+		result.Unchanged++
+		return nil
+	}
+	if _, err := s.Update(ctx, updated); err != nil {
+		return fmt.Errorf("failed to update %s %s: %w", manifest.GetKind(), id, err)
+	}
+	result.Updated++
+	return nil
+}
+
+// seedUpdate returns existing updated to match manifest, or nil if it already does.
+func seedUpdate(s strategy.CompleteStrategy, existing types.Object, manifest *unstructured.Unstructured) (types.Object, error) {
+	current, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+	if err != nil {
+		return nil, err
+	}
+	desired := runtime.DeepCopyJSON(manifest.Object)
+	for _, obj := range []map[string]any{current, desired} {
+		delete(obj, "apiVersion")
+		delete(obj, "kind")
+	}
+
+	// The manifest only contributes labels and annotations to the metadata
+	metadata, _ := runtime.DeepCopyJSONValue(current["metadata"]).(map[string]any)
+	for _, field := range []string{"labels", "annotations"} {
+		values, _, _ := unstructured.NestedStringMap(manifest.Object, "metadata", field)
+		if len(values) == 0 {
+			continue
+		}
+		merged, _, _ := unstructured.NestedStringMap(metadata, field)
+		if merged == nil {
+			merged = map[string]string{}
+		}
+		for k, v := range values {
+			merged[k] = v
+		}
+		if err := unstructured.SetNestedStringMap(metadata, merged, field); err != nil {
+			return nil, err
+		}
+	}
+	desired["metadata"] = metadata
+	if status, ok := current["status"]; ok {
+		desired["status"] = status
+	} else {
+		delete(desired, "status")
+	}
+
+	obj := s.New()
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(desired, obj); err != nil {
+		return nil, err
+	}
+	// Compare after the round trip through the type, so fields the manifest leaves at their defaults aren't changes
+	if normalized, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+		return nil, err
+	} else if equality.Semantic.DeepEqual(current, normalized) {
+		return nil, nil
+	}
+	return obj, nil
+}
@@ -0,0 +1,338 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// snapshotVersion is the version of the snapshot format written by Snapshot.
+const snapshotVersion = 1
+
+// snapshotLine is a single line of a snapshot. Exactly one field is set. A snapshot starts with a header, followed by
+// each table and then its records in revision order.
+type snapshotLine struct {
+	Header *snapshotHeader `json:"header,omitempty"`
+	Table  *snapshotTable  `json:"table,omitempty"`
+	Record *snapshotRecord `json:"record,omitempty"`
+}
+
+type snapshotHeader struct {
+	Version int `json:"version"`
+}
+
+type snapshotTable struct {
+	// GroupVersionKind is empty if the snapshot was taken without knowing the kind stored in the table.
+	GroupVersionKind schema.GroupVersionKind `json:"groupVersionKind"`
+	Name             string                  `json:"name"`
+	Revision         int64                   `json:"revision"`
+	CompactionID     int64                   `json:"compactionID,omitempty"`
+	// Records is the number of records that follow.
+	Records int64 `json:"records"`
+	// Objects is the number of objects that existed when the snapshot was taken.
+	Objects int64 `json:"objects"`
+}
+
+type snapshotRecord struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Namespace   string          `json:"namespace,omitempty"`
+	PreviousID  *int64          `json:"previousID,omitempty"`
+	UID         string          `json:"uid"`
+	Created     bool            `json:"created,omitempty"`
+	Deleted     bool            `json:"deleted,omitempty"`
+	PartitionID string          `json:"partitionID,omitempty"`
+	Modified    int64           `json:"modified,omitempty"`
+	Version     string          `json:"version,omitempty"`
+	Value       json.RawMessage `json:"value"`
+}
+
+// Snapshot writes every table of the strategies created by the factory to w as newline-delimited JSON. All tables
+// are read in a single transaction so the snapshot is consistent across tables. Retained history is included and
+// values are written decoded, so a snapshot can be restored into a database with different compression, blob or
+// encryption settings.
+func (f *Factory) Snapshot(ctx context.Context, w io.Writer) error {
+	var tables []snapshotSource
+	for _, s := range f.getStrategies() {
+		tables = append(tables, snapshotSource{db: &s.db, gvk: s.db.gvk})
+	}
+	return writeSnapshot(WithRowLevelSecurityBypass(ctx), w, tables)
+}
+
+// snapshotSource is a table to snapshot. gvk is empty if the kind stored in the table isn't known. If partitionID is
+// set, only the records of the partition are written. progress is called with the number of records written once the
+// table is done, if set.
+type snapshotSource struct {
+	db          *db
+	gvk         schema.GroupVersionKind
+	partitionID string
+	progress    func(records int64)
+}
+
+func writeSnapshot(ctx context.Context, w io.Writer, tables []snapshotSource) error {
+	if len(tables) == 0 {
+		return nil
+	}
+
+	ctx, tx, err := tables[0].db.beginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	if err := enc.Encode(snapshotLine{Header: &snapshotHeader{Version: snapshotVersion}}); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := table.db.snapshot(ctx, enc, table); err != nil {
+			return fmt.Errorf("failed to snapshot %s: %w", table.db.stmt.TableName(), err)
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// baseTableName returns the name of the table without the prefix, so that snapshots can be restored into a database
+// with a different prefix.
+func (d *db) baseTableName() string {
+	return strings.TrimPrefix(d.stmt.TableName(), d.stmt.Prefix())
+}
+
+func (d *db) snapshot(ctx context.Context, enc *json.Encoder, source snapshotSource) error {
+	meta, err := d.getTableMeta(ctx)
+	if err != nil {
+		return err
+	}
+	table := &snapshotTable{
+		GroupVersionKind: source.gvk,
+		Name:             d.baseTableName(),
+		Revision:         meta.ListID,
+		CompactionID:     meta.CompactionID,
+	}
+	statsSQL, snapshotSQL, args := d.stmt.TableStatsSQL(), d.stmt.SnapshotSQL(), []any(nil)
+	if source.partitionID != "" {
+		statsSQL, snapshotSQL, args = d.stmt.TenantStatsSQL(), d.stmt.TenantSnapshotSQL(), []any{source.partitionID}
+	}
+	if err := d.queryRowContext(ctx, statsSQL, args...).Scan(&table.Records, &table.Objects); err != nil {
+		return err
+	}
+	if err := enc.Encode(snapshotLine{Table: table}); err != nil {
+		return err
+	}
+
+	rows, err := d.queryContext(ctx, snapshotSQL, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var written int64
+
+	for rows.Next() {
+		var (
+			r        record
+			created  sql.NullInt16
+			modified sql.NullInt64
+			checksum sql.NullInt64
+			version  sql.NullString
+		)
+		if err := rows.Scan(
+			&r.id, &r.name, &r.namespace, &r.previousID, &r.uid, &created, &r.deleted, &r.value, &r.partitionID,
+			&modified, &checksum, &version); err != nil {
+			return err
+		}
+		if err := d.readValue(ctx, &r, checksum); err != nil {
+			return err
+		}
+		if err := enc.Encode(snapshotLine{Record: &snapshotRecord{
+			ID:          r.id,
+			Name:        r.name,
+			Namespace:   r.namespace,
+			PreviousID:  r.previousID,
+			UID:         r.uid,
+			Created:     created.Valid && created.Int16 == 1,
+			Deleted:     r.deleted == 1,
+			PartitionID: r.partitionID,
+			Modified:    modified.Int64,
+			Version:     version.String,
+			Value:       json.RawMessage(r.value),
+		}}); err != nil {
+			return err
+		}
+		written++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if source.progress != nil {
+		source.progress(written)
+	}
+	return nil
+}
+
+// Restore loads a snapshot written by Snapshot. Every table in the snapshot must belong to a strategy already created
+// by the factory, and must be empty. Tables are matched by kind, or by name if the snapshot doesn't record the kind.
+// Records keep their IDs, so the resourceVersions of restored objects are the same as when the snapshot was taken.
+// The restore runs in a single transaction and nothing is restored if it fails.
+func (f *Factory) Restore(ctx context.Context, r io.Reader) error {
+	strategies := f.getStrategies()
+	if len(strategies) == 0 {
+		return fmt.Errorf("no tables to restore into")
+	}
+
+	var restored []*Strategy
+	_, err := readSnapshot(WithRowLevelSecurityBypass(ctx), r, &strategies[0].db, nil, func(_ context.Context, table *snapshotTable) (*db, error) {
+		for _, s := range strategies {
+			if table.GroupVersionKind.Kind != "" && s.db.gvk == table.GroupVersionKind ||
+				table.GroupVersionKind.Kind == "" && s.db.baseTableName() == table.Name {
+				restored = append(restored, s)
+				return &s.db, nil
+			}
+		}
+		return nil, fmt.Errorf("no strategy for %s in snapshot table %s", table.GroupVersionKind, table.Name)
+	})
+	if err != nil {
+		return err
+	}
+	for _, s := range restored {
+		s.broadcastChange()
+	}
+	return nil
+}
+
+// readSnapshot restores a snapshot in a single transaction started on txDB and returns the tables it restored. lookup
+// returns the table to restore each snapshot table into; it is called within the transaction. If progress is not nil
+// it is called after each restored record with the number of records restored into the table so far.
+func readSnapshot(ctx context.Context, r io.Reader, txDB *db, progress func(table *snapshotTable, records int64),
+	lookup func(context.Context, *snapshotTable) (*db, error)) ([]*snapshotTable, error) {
+	ctx, tx, err := txDB.beginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		dec     = json.NewDecoder(bufio.NewReader(r))
+		tables  []*snapshotTable
+		current *db
+		records int64
+		header  bool
+	)
+	for {
+		var line snapshotLine
+		if err := dec.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid snapshot: %w", err)
+		}
+
+		switch {
+		case line.Header != nil:
+			if line.Header.Version != snapshotVersion {
+				return nil, fmt.Errorf("unsupported snapshot version %d", line.Header.Version)
+			}
+			header = true
+		case !header:
+			return nil, fmt.Errorf("invalid snapshot: missing header")
+		case line.Table != nil:
+			if current, err = lookup(ctx, line.Table); err != nil {
+				return nil, err
+			}
+			if err := current.restoreTable(ctx, line.Table); err != nil {
+				return nil, err
+			}
+			tables = append(tables, line.Table)
+			records = 0
+		case line.Record != nil:
+			if current == nil {
+				return nil, fmt.Errorf("invalid snapshot: record before table")
+			}
+			if err := current.restoreRecord(ctx, line.Record); err != nil {
+				return nil, fmt.Errorf("failed to restore %s %s: %w", current.stmt.TableName(), line.Record.Name, err)
+			}
+			records++
+			if progress != nil {
+				progress(tables[len(tables)-1], records)
+			}
+		}
+	}
+
+	return tables, tx.Commit()
+}
+
+func (d *db) restoreTable(ctx context.Context, table *snapshotTable) error {
+	if _, err := d.execContext(ctx, d.stmt.TableLockSQL()); err != nil {
+		return err
+	}
+	meta, err := d.getTableMeta(ctx)
+	if err != nil {
+		return err
+	}
+	if meta.ListID != 0 {
+		return fmt.Errorf("table %s is not empty", d.stmt.TableName())
+	}
+	if err := d.invalidateUnique(ctx); err != nil {
+		return err
+	}
+	if table.CompactionID != 0 {
+		if _, err := d.execContext(ctx, d.stmt.SetCompactionSQL(), table.CompactionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *db) restoreRecord(ctx context.Context, rec *snapshotRecord) error {
+	value, err := d.encodeValue(ctx, rec.Namespace, rec.Name, string(rec.Value))
+	if err != nil {
+		return err
+	}
+
+	var created, modified, version any
+	if rec.Created {
+		created = 1
+	}
+	if rec.Modified != 0 {
+		modified = rec.Modified
+	}
+	if rec.Version != "" {
+		version = rec.Version
+	}
+	deleted := 0
+	if rec.Deleted {
+		deleted = 1
+	}
+
+	if err := d.ensurePartition(ctx, rec.ID); err != nil {
+		return err
+	}
+	_, err = d.execContext(ctx, d.stmt.RestoreSQL(),
+		rec.ID,
+		rec.Name,
+		rec.Namespace,
+		rec.PreviousID,
+		rec.UID,
+		created,
+		deleted,
+		value,
+		rec.PartitionID,
+		modified,
+		valueChecksum(value),
+		version)
+	return err
+}
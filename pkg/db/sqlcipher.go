@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SQLiteKeyEnv is the environment variable the key of an encrypted sqlite database is read from if
+// SQLiteEncryption.Key is empty.
+const SQLiteKeyEnv = "KINM_SQLITE_KEY"
+
+// SQLiteEncryption configures WithSQLiteEncryption.
+type SQLiteEncryption struct {
+	// DriverName is the name of a database/sql driver built with SQLCipher that the program registers, such as
+	// "sqlite3" of github.com/mutecomm/go-sqlcipher/v4. The sqlite driver used by default doesn't support encryption.
+	DriverName string
+	// Key is the passphrase the database is encrypted with. If empty, it is read from $KINM_SQLITE_KEY.
+	Key string
+}
+
+// WithSQLiteEncryption opens a sqlite database encrypted with SQLCipher. The key is set on every connection, and
+// NewFactory fails if the driver isn't built with SQLCipher or the key doesn't decrypt the database, instead of
+// writing an unencrypted database or failing on the first query. A new database is created encrypted with the key;
+// an existing unencrypted database can't be opened and must be exported to an encrypted one, such as with the
+// sqlcipher_export function of SQLCipher. Use RekeySQLite to change the key.
+//
+// Encryption is not supported with WAL replication, which reads the database file with the default driver.
+func WithSQLiteEncryption(opts SQLiteEncryption) FactoryOption {
+	return func(f *Factory) {
+		f.sqliteEncryption = &opts
+	}
+}
+
+// key returns the configured key, or the one of $KINM_SQLITE_KEY.
+func (e *SQLiteEncryption) key() (string, error) {
+	key := e.Key
+	if key == "" {
+		key = os.Getenv(SQLiteKeyEnv)
+	}
+	if key == "" {
+		return "", fmt.Errorf("the key of the encrypted sqlite database is required, set it in the options or $%s", SQLiteKeyEnv)
+	}
+	return key, nil
+}
+
+// newKeyConnector returns a connector to the sqlite database at path that sets the key of opts on every connection.
+func newKeyConnector(path string, opts SQLiteEncryption) (*keyConnector, error) {
+	if opts.DriverName == "" {
+		return nil, fmt.Errorf("the name of a sqlite driver built with SQLCipher is required")
+	}
+	key, err := opts.key()
+	if err != nil {
+		return nil, err
+	}
+	// sql.Open doesn't connect, it only looks up the driver
+	db, err := sql.Open(opts.DriverName, path)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	_ = db.Close()
+	return &keyConnector{driver: drv, dsn: path, key: key}, nil
+}
+
+// verifySQLiteKey checks that db is opened by a driver built with SQLCipher and that its key decrypts the database.
+func verifySQLiteKey(ctx context.Context, db *sql.DB, driverName string) error {
+	var version string
+	if err := db.QueryRowContext(ctx, "PRAGMA cipher_version").Scan(&version); err == sql.ErrNoRows || version == "" {
+		return fmt.Errorf("sqlite driver %q is not built with SQLCipher, the database can't be encrypted", driverName)
+	} else if err != nil {
+		return err
+	}
+	// The key is only used when the database is first read, which fails if the key is wrong
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master").Scan(&count); err != nil {
+		return fmt.Errorf("failed to decrypt the sqlite database, the key may be wrong: %w", err)
+	}
+	return nil
+}
+
+// RekeySQLite encrypts the sqlite database at path, encrypted with the key of opts, with newKey instead. The database
+// must not be in use.
+func RekeySQLite(ctx context.Context, path string, opts SQLiteEncryption, newKey string) error {
+	if newKey == "" {
+		return fmt.Errorf("the new key must not be empty")
+	}
+	connector, err := newKeyConnector(path, opts)
+	if err != nil {
+		return err
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := verifySQLiteKey(ctx, db, opts.DriverName); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA rekey = "+quoteSQLiteString(newKey)); err != nil {
+		return fmt.Errorf("failed to rekey the sqlite database: %w", err)
+	}
+	return verifySQLiteKey(ctx, db, opts.DriverName)
+}
+
+// keyConnector opens connections to a sqlite database and sets the key of each before it is used.
+type keyConnector struct {
+	driver driver.Driver
+	dsn    string
+	key    string
+}
+
+func (c *keyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		_ = conn.Close()
+		return nil, fmt.Errorf("sqlite driver doesn't support ExecContext")
+	}
+	if _, err := execer.ExecContext(ctx, "PRAGMA key = "+quoteSQLiteString(c.key), nil); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to set the key of the sqlite database: %w", err)
+	}
+	return conn, nil
+}
+
+func (c *keyConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func quoteSQLiteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,12 @@
+SELECT $GROUPS count(*)
+FROM (SELECT namespace,
+             deleted,
+             value,
+             row_number() OVER (PARTITION BY name, namespace
+                 ORDER BY ID DESC) AS rn
+      FROM placeholder
+      WHERE (namespace = $1 OR $1 IS NULL)
+        AND (partition_id = $2 OR $2 IS NULL)) AS r
+WHERE rn = 1
+  AND deleted = 0
+  AND value LIKE '{%'
@@ -0,0 +1 @@
+coalesce(value::jsonb #>> $PATH::text[], '')
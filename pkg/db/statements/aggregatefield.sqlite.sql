@@ -0,0 +1,6 @@
+CASE json_type(value, $PATH)
+    WHEN 'true' THEN 'true'
+    WHEN 'false' THEN 'false'
+    WHEN 'null' THEN ''
+    ELSE coalesce(CAST(json_extract(value, $PATH) AS TEXT), '')
+    END
@@ -0,0 +1 @@
+ANALYZE placeholder
\ No newline at end of file
@@ -0,0 +1,31 @@
+WITH to_delete AS (SELECT prev.id AS id
+                   FROM placeholder AS prev
+                            JOIN placeholder AS cur ON (
+                       ((prev.id = cur.previous_id AND prev.created IS NULL) OR
+                        (prev.id = cur.id AND cur.deleted = 1))
+                           AND cur.id <= coalesce(
+                               (SELECT id AS id
+                                FROM compaction
+                                WHERE name = 'placeholder')
+                           , 0)
+                       )
+                   ORDER BY prev.id
+                   LIMIT 500)
+
+INSERT
+INTO placeholder_archive (id, name, namespace, previous_id, uid, created, deleted, value, partition_id, modified,
+                          checksum, archived)
+SELECT id,
+       name,
+       namespace,
+       previous_id,
+       uid,
+       created,
+       deleted,
+       value,
+       partition_id,
+       modified,
+       checksum,
+       $1
+FROM placeholder
+WHERE id IN (SELECT id FROM to_delete);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS placeholder_archive
+(
+    id           BIGINT PRIMARY KEY,
+    name         VARCHAR(255) NOT NULL,
+    namespace    VARCHAR(255) NOT NULL,
+    previous_id  BIGINT,
+    uid          VARCHAR(255) NOT NULL,
+    created      INTEGER,
+    deleted      INTEGER      NOT NULL,
+    value        TEXT         NOT NULL,
+    partition_id VARCHAR(255) NOT NULL,
+    modified     BIGINT,
+    checksum     BIGINT,
+    archived     BIGINT       NOT NULL
+)
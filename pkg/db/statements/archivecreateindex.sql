@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS placeholder_archive_namespace_name ON placeholder_archive (namespace, name, id DESC)
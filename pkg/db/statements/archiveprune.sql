@@ -0,0 +1,26 @@
+INSERT
+INTO placeholder_archive (id, name, namespace, previous_id, uid, created, deleted, value, partition_id, modified,
+                          checksum, archived)
+SELECT id,
+       name,
+       namespace,
+       previous_id,
+       uid,
+       created,
+       deleted,
+       value,
+       partition_id,
+       modified,
+       checksum,
+       $4
+FROM placeholder
+WHERE namespace = $1
+  AND name = $2
+  AND created IS NULL
+  AND id < (SELECT min(latest.id)
+            FROM (SELECT id
+                  FROM placeholder
+                  WHERE namespace = $1
+                    AND name = $2
+                  ORDER BY id DESC
+                  LIMIT $3) AS latest);
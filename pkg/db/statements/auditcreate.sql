@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS placeholder
+(
+    time                 BIGINT       NOT NULL,
+    username             VARCHAR(255) NOT NULL,
+    verb                 VARCHAR(32)  NOT NULL,
+    api_group            VARCHAR(255) NOT NULL,
+    api_version          VARCHAR(255) NOT NULL,
+    kind                 VARCHAR(255) NOT NULL,
+    namespace            VARCHAR(255) NOT NULL,
+    name                 VARCHAR(255) NOT NULL,
+    old_resource_version VARCHAR(32)  NOT NULL,
+    new_resource_version VARCHAR(32)  NOT NULL,
+    outcome              VARCHAR(32)  NOT NULL,
+    error                TEXT         NOT NULL
+)
@@ -0,0 +1,3 @@
+INSERT INTO placeholder (time, username, verb, api_group, api_version, kind, namespace, name, old_resource_version,
+                         new_resource_version, outcome, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
@@ -0,0 +1,3 @@
+UPDATE placeholder
+SET created = NULL
+WHERE id = $1
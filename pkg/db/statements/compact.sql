@@ -9,6 +9,7 @@ WITH to_delete AS (SELECT prev.id AS id
                                 WHERE name = 'placeholder')
                            , 0)
                        )
+                   ORDER BY prev.id
                    LIMIT 500)
 
 DELETE
@@ -0,0 +1,4 @@
+DELETE
+FROM watch_cursor
+WHERE consumer = $1
+  AND name = 'placeholder'
@@ -0,0 +1,4 @@
+SELECT resource_version
+FROM watch_cursor
+WHERE consumer = $1
+  AND name = 'placeholder'
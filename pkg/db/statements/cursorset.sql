@@ -0,0 +1,3 @@
+INSERT INTO watch_cursor(consumer, name, resource_version)
+VALUES ($1, 'placeholder', $2)
+ON CONFLICT (consumer, name) DO UPDATE SET resource_version = EXCLUDED.resource_version;
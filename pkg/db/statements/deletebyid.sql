@@ -0,0 +1,3 @@
+DELETE
+FROM placeholder
+WHERE id = $1
@@ -0,0 +1,12 @@
+SELECT id,
+       name,
+       namespace,
+       previous_id,
+       uid,
+       created,
+       deleted,
+       value,
+       partition_id,
+       checksum
+FROM placeholder
+WHERE id = $1
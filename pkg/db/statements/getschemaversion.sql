@@ -0,0 +1,3 @@
+SELECT coalesce((SELECT version
+                 FROM schema_version
+                 WHERE name = 'placeholder'), 0) AS version
@@ -0,0 +1,5 @@
+SELECT count(*)
+FROM information_schema.columns
+WHERE table_schema = current_schema()
+  AND table_name = 'placeholder'
+  AND column_name = $1
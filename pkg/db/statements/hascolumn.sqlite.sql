@@ -0,0 +1,3 @@
+SELECT count(*)
+FROM pragma_table_info('placeholder')
+WHERE name = $1
@@ -0,0 +1,7 @@
+SELECT id, deleted
+FROM placeholder
+WHERE namespace = $1
+  AND name = $2
+  AND (partition_id = $3 OR $3 IS NULL)
+ORDER BY id DESC
+LIMIT 1
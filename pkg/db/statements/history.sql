@@ -0,0 +1,18 @@
+SELECT id,
+       name,
+       namespace,
+       previous_id,
+       uid,
+       created,
+       deleted,
+       value,
+       partition_id,
+       modified,
+       checksum,
+       NULL AS archived
+FROM placeholder
+WHERE namespace = $1
+  AND name = $2
+  AND ($3 = 0 OR id < $3)
+  AND (partition_id = $4 OR $4 IS NULL)
+ORDER BY id DESC
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS placeholder_index
+(
+    id    BIGINT       NOT NULL,
+    field VARCHAR(255) NOT NULL,
+    value TEXT         NOT NULL,
+    PRIMARY KEY (id, field)
+)
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS placeholder_index_field_value ON placeholder_index (field, value, id)
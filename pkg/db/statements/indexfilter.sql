@@ -0,0 +1,4 @@
+  AND id IN (SELECT i.id
+              FROM placeholder_index AS i
+              WHERE i.field = $FIELD
+                AND i.value = $VALUE)
@@ -0,0 +1,2 @@
+INSERT INTO placeholder_index (id, field, value)
+VALUES ($1, $2, $3)
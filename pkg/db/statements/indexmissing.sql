@@ -0,0 +1,8 @@
+SELECT id
+FROM placeholder AS r
+WHERE deleted = 0
+  AND NOT EXISTS (SELECT 1
+                  FROM placeholder_index AS i
+                  WHERE i.id = r.id
+                    AND i.field = $1)
+ORDER BY id
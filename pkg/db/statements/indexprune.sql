@@ -0,0 +1,3 @@
+DELETE
+FROM placeholder_index
+WHERE id NOT IN (SELECT id FROM placeholder)
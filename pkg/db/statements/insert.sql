@@ -1,4 +1,4 @@
-INSERT INTO placeholder(id, name, namespace, previous_id, uid, created, deleted, value)
+INSERT INTO placeholder(id, name, namespace, previous_id, uid, created, deleted, value, partition_id, modified, checksum, version)
 VALUES ((SELECT COALESCE(MAX(id), 0) + 1 FROM placeholder),
         $1,
         $2,
@@ -6,4 +6,8 @@ VALUES ((SELECT COALESCE(MAX(id), 0) + 1 FROM placeholder),
         $4,
         $5,
         $6,
-        $7) RETURNING id;
\ No newline at end of file
+        $7,
+        $8,
+        $9,
+        $10,
+        $11) RETURNING id;
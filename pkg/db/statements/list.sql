@@ -9,7 +9,11 @@ SELECT (SELECT max(id) FROM placeholder) AS max_id,
        uid,
        CASE WHEN created = 1 OR previous_id IS NULL THEN 1 ELSE 0 END AS created,
        deleted,
-       value
+       value,
+       partition_id,
+       checksum,
+       NULL                                                 AS previous_value,
+       NULL                                                 AS previous_checksum
 FROM (SELECT id,
              name,
              namespace,
@@ -18,13 +22,16 @@ FROM (SELECT id,
              created,
              deleted,
              value,
+             partition_id,
+             checksum,
              row_number() OVER (PARTITION BY name, namespace
                  ORDER BY ID DESC) AS rn
       FROM placeholder
       WHERE (namespace = $1 OR $1 IS NULL)
         AND (name = $2 OR $2 IS NULL)
         AND ($3 = 0 OR id <= $3)
-        AND ($4 = 0 OR id > $4)) AS r
+        AND ($4 = 0 OR id > $4)
+        AND (partition_id = $5 OR $5 IS NULL)) AS r
 WHERE rn = 1
   AND deleted = 0
 ORDER BY id
\ No newline at end of file
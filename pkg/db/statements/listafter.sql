@@ -9,9 +9,14 @@ SELECT (SELECT max(id) FROM placeholder)           AS max_id,
        uid,
        CASE WHEN created = 1 OR previous_id IS NULL THEN 1 ELSE 0 END AS created,
        deleted,
-       value
+       value,
+       partition_id,
+       checksum,
+       NULL                                        AS previous_value,
+       NULL                                        AS previous_checksum
 FROM placeholder
 WHERE (namespace = $1 OR $1 IS NULL)
   AND (name = $2 OR $2 IS NULL)
   AND id > $3
+  AND (partition_id = $4 OR $4 IS NULL)
 ORDER BY id
\ No newline at end of file
@@ -0,0 +1,23 @@
+SELECT (SELECT max(id) FROM placeholder)           AS max_id,
+       coalesce((SELECT c.id
+                 FROM compaction AS c
+                 WHERE c.name = 'placeholder'), 0) as compaction_id,
+       cur.id,
+       cur.name,
+       cur.namespace,
+       cur.previous_id,
+       cur.uid,
+       CASE WHEN cur.created = 1 OR cur.previous_id IS NULL THEN 1 ELSE 0 END AS created,
+       cur.deleted,
+       cur.value,
+       cur.partition_id,
+       cur.checksum,
+       prev.value                                  AS previous_value,
+       prev.checksum                               AS previous_checksum
+FROM placeholder AS cur
+         LEFT JOIN placeholder AS prev ON prev.id = cur.previous_id
+WHERE (cur.namespace = $1 OR $1 IS NULL)
+  AND (cur.name = $2 OR $2 IS NULL)
+  AND cur.id > $3
+  AND (cur.partition_id = $4 OR $4 IS NULL)
+ORDER BY cur.id
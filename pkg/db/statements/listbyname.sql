@@ -0,0 +1,37 @@
+SELECT (SELECT max(id) FROM placeholder) AS max_id,
+       coalesce((SELECT c.id
+                 FROM compaction AS c
+                 WHERE c.name = 'placeholder'), 0)              as compaction_id,
+       id,
+       name,
+       namespace,
+       previous_id,
+       uid,
+       CASE WHEN created = 1 OR previous_id IS NULL THEN 1 ELSE 0 END AS created,
+       deleted,
+       value,
+       partition_id,
+       checksum,
+       NULL                                                 AS previous_value,
+       NULL                                                 AS previous_checksum
+FROM (SELECT id,
+             name,
+             namespace,
+             previous_id,
+             uid,
+             created,
+             deleted,
+             value,
+             partition_id,
+             checksum,
+             row_number() OVER (PARTITION BY name, namespace
+                 ORDER BY ID DESC) AS rn
+      FROM placeholder
+      WHERE (namespace = $1 OR $1 IS NULL)
+        AND (name = $2 OR $2 IS NULL)
+        AND ($3 = 0 OR id <= $3)
+        AND ($4 IS NULL OR namespace > $4 OR (namespace = $4 AND name > $6))
+        AND (partition_id = $5 OR $5 IS NULL)) AS r
+WHERE rn = 1
+  AND deleted = 0
+ORDER BY namespace, name
\ No newline at end of file
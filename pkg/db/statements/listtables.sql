@@ -0,0 +1,4 @@
+SELECT name,
+       version
+FROM schema_version
+ORDER BY name
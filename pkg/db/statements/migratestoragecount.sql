@@ -0,0 +1,5 @@
+SELECT count(*)
+FROM placeholder
+WHERE version IS NULL
+   OR version <> $1
+   OR $2 = 1
@@ -0,0 +1,6 @@
+SELECT id, namespace, name, value, checksum, version
+FROM placeholder
+WHERE id > $1
+  AND (version IS NULL OR version <> $2 OR $3 = 1)
+ORDER BY id
+LIMIT $4
@@ -0,0 +1,5 @@
+UPDATE placeholder
+SET value    = $2,
+    checksum = $3,
+    version  = $4
+WHERE id = $1
@@ -0,0 +1,86 @@
+package statements
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migration is a single step in the schema history of a table. Migrations are applied in version order and each
+// version is only applied once per table.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+	// SkipIfColumn marks a migration that adds a column which may already exist because the table was created
+	// before migrations were versioned.
+	SkipIfColumn string
+}
+
+// skipIfColumn lists the migrations that predate versioning and may already be applied.
+var skipIfColumn = map[int]string{
+	2: "partition_id",
+}
+
+func (s *Statements) Migrations() []Migration {
+	return s.migrations
+}
+
+// Dialect returns the dialect of the statements.
+func (s *Statements) Dialect() Dialect {
+	return s.dialect
+}
+
+// loadMigrations reads migrations/NNNN_name.sql. A migration can have dialect specific versions named
+// NNNN_name.postgres.sql and NNNN_name.sqlite.sql, which are used instead of the generic file.
+func (s *Statements) loadMigrations() {
+	entries, err := migrationsFS.ReadDir("migrations")
+	if err != nil {
+		panic("failed to read migrations: " + err.Error())
+	}
+
+	files := map[int]map[string]string{}
+	names := map[int]string{}
+	for _, entry := range entries {
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		base, dialect, _ := strings.Cut(base, ".")
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok {
+			panic("invalid migration file name: " + entry.Name())
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			panic("invalid migration file name: " + entry.Name())
+		}
+		if files[version] == nil {
+			files[version] = map[string]string{}
+		}
+		files[version][dialect] = entry.Name()
+		names[version] = name
+	}
+
+	for version := 1; version <= len(files); version++ {
+		file, ok := files[version][string(s.Dialect())]
+		if !ok {
+			file, ok = files[version][""]
+		}
+		if !ok {
+			panic(fmt.Sprintf("missing migration %d for %s", version, s.Dialect()))
+		}
+		sql, err := migrationsFS.ReadFile(path.Join("migrations", file))
+		if err != nil {
+			panic("failed to read migration: " + err.Error())
+		}
+		s.migrations = append(s.migrations, Migration{
+			Version:      version,
+			Name:         names[version],
+			SQL:          s.replacePlaceholders(sql),
+			SkipIfColumn: skipIfColumn[version],
+		})
+	}
+}
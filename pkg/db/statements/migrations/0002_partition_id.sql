@@ -0,0 +1 @@
+ALTER TABLE placeholder ADD COLUMN partition_id VARCHAR(255) NOT NULL DEFAULT ''
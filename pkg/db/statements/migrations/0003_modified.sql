@@ -0,0 +1 @@
+ALTER TABLE placeholder ADD COLUMN modified BIGINT
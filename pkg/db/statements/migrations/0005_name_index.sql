@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS placeholder_namespace_name_id ON placeholder (namespace, name, id)
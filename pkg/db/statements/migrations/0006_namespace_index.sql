@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS placeholder_namespace_id ON placeholder (namespace, id DESC)
\ No newline at end of file
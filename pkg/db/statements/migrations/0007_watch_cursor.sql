@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS watch_cursor
+(
+    consumer         VARCHAR(255) NOT NULL,
+    name             VARCHAR(255) NOT NULL,
+    resource_version BIGINT       NOT NULL,
+    PRIMARY KEY (consumer, name)
+)
@@ -0,0 +1 @@
+ALTER TABLE placeholder ADD COLUMN version VARCHAR(255)
\ No newline at end of file
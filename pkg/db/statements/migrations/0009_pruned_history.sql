@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS pruned_history
+(
+    name        VARCHAR(255) NOT NULL,
+    namespace   VARCHAR(255) NOT NULL,
+    object_name VARCHAR(255) NOT NULL,
+    first_id    INTEGER      NOT NULL,
+    id          INTEGER      NOT NULL,
+    kept_id     INTEGER      NOT NULL,
+    PRIMARY KEY (name, namespace, object_name)
+)
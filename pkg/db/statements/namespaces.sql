@@ -0,0 +1,11 @@
+SELECT DISTINCT namespace
+FROM (SELECT namespace,
+             deleted,
+             row_number() OVER (PARTITION BY name, namespace
+                 ORDER BY id DESC) AS rn
+      FROM placeholder
+      WHERE namespace <> ''
+        AND (partition_id = $1 OR $1 IS NULL)) AS r
+WHERE rn = 1
+  AND deleted = 0
+ORDER BY namespace
\ No newline at end of file
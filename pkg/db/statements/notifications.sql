@@ -0,0 +1,8 @@
+SELECT id,
+       namespace,
+       name,
+       CASE WHEN created = 1 OR previous_id IS NULL THEN 1 ELSE 0 END AS created,
+       deleted
+FROM placeholder
+WHERE id > $1
+ORDER BY id
@@ -0,0 +1,125 @@
+package statements
+
+import (
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// WithOverrides replaces and extends the statements with the .sql files of fsys, which can be laid out in layers:
+//
+//	<file>.sql                    for every table
+//	<dialect>/<file>.sql          for every table of a dialect, "postgres" or "sqlite"
+//	<table>/<file>.sql            for one table, named without the table prefix
+//	<table>/<dialect>/<file>.sql  for one table of a dialect
+//
+// A file in a more specific layer wins over the same file in a less specific one, and later calls of WithOverrides win
+// over earlier ones. In every layer:
+//
+//   - A statement file, such as list.sql, replaces the statement of the same name. Overriding a statement that
+//     doesn't exist is an error.
+//   - A migration file, migrations/NNNN_name.sql, replaces the migration of that version, for instance to create the
+//     table with storage parameters such as fillfactor or as a partitioned table. It only applies to tables that
+//     haven't been migrated to that version yet.
+//   - An extension file, extensions/<name>.sql, is run after the migrations every time the table is opened, in the
+//     order of the names, for instance to create custom indexes. Extensions must be idempotent.
+//
+// The placeholders of the files are replaced like those of the embedded statements.
+func WithOverrides(fsys iofs.FS) Option {
+	return func(s *Statements) {
+		s.overrides = append(s.overrides, fsys)
+	}
+}
+
+// Extensions returns the extension statements, in the order they are run.
+func (s *Statements) Extensions() []string {
+	return s.extensions
+}
+
+// Err returns the error of loading the overrides, if any.
+func (s *Statements) Err() error {
+	return s.err
+}
+
+// applyOverrides applies the files of the overrides to the loaded statements and migrations.
+func (s *Statements) applyOverrides(table string) error {
+	extensions := map[string]string{}
+	for _, fsys := range s.overrides {
+		for _, layer := range []string{".", string(s.Dialect()), table, path.Join(table, string(s.Dialect()))} {
+			if err := s.applyLayer(fsys, layer, extensions); err != nil {
+				return err
+			}
+		}
+	}
+
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		s.extensions = append(s.extensions, extensions[name])
+	}
+	return nil
+}
+
+func (s *Statements) applyLayer(fsys iofs.FS, layer string, extensions map[string]string) error {
+	files, err := readSQLFiles(fsys, layer)
+	if err != nil {
+		return err
+	}
+	for name, sql := range files {
+		if _, ok := s.statements[name]; !ok {
+			return fmt.Errorf("failed to override statement %s: no such statement", path.Join(layer, name))
+		}
+		s.statements[name] = s.replacePlaceholders(sql)
+	}
+
+	files, err = readSQLFiles(fsys, path.Join(layer, "migrations"))
+	if err != nil {
+		return err
+	}
+	for name, sql := range files {
+		versionStr, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version < 1 || version > len(s.migrations) {
+			return fmt.Errorf("failed to override migration %s: no such migration", path.Join(layer, "migrations", name))
+		}
+		s.migrations[version-1].SQL = s.replacePlaceholders(sql)
+	}
+
+	files, err = readSQLFiles(fsys, path.Join(layer, "extensions"))
+	if err != nil {
+		return err
+	}
+	for name, sql := range files {
+		extensions[name] = s.replacePlaceholders(sql)
+	}
+	return nil
+}
+
+// readSQLFiles returns the content of the .sql files of dir by name. A missing dir has no files.
+func readSQLFiles(fsys iofs.FS, dir string) (map[string][]byte, error) {
+	entries, err := iofs.ReadDir(fsys, dir)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		data, err := iofs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = data
+	}
+	return files, nil
+}
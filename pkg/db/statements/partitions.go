@@ -0,0 +1,102 @@
+package statements
+
+import (
+	"embed"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:embed partitions/*.sql
+var partitionsFS embed.FS
+
+// The ways a table can be partitioned.
+const (
+	// PartitionByIDRange partitions a table by ranges of ids.
+	PartitionByIDRange = "idrange"
+	// PartitionByNamespaceHash partitions a table by the hash of the namespace.
+	PartitionByNamespaceHash = "namespacehash"
+)
+
+// WithTablePartitioning creates the table as a Postgres partitioned table, partitioned by, which is one of
+// PartitionByIDRange and PartitionByNamespaceHash. The partitions themselves are created with the statements of PartitionCreateRangeSQL and
+// PartitionCreateHashSQL. It only applies to tables that don't exist yet.
+func WithTablePartitioning(by string) Option {
+	return func(s *Statements) {
+		s.tablePartitioning = by
+	}
+}
+
+// TablePartitioning returns how the table is partitioned, or an empty string if it isn't.
+func (s *Statements) TablePartitioning() string {
+	return s.tablePartitioning
+}
+
+// loadPartitions replaces the migration creating the table with the one creating a partitioned table and loads the
+// statements managing the partitions.
+func (s *Statements) loadPartitions() error {
+	if s.dialect != Postgres {
+		return fmt.Errorf("table partitioning is only supported for Postgres")
+	}
+	if s.tablePartitioning != PartitionByIDRange && s.tablePartitioning != PartitionByNamespaceHash {
+		return fmt.Errorf("unknown table partitioning %q", s.tablePartitioning)
+	}
+
+	entries, err := partitionsFS.ReadDir("partitions")
+	if err != nil {
+		panic("failed to read partition sql files: " + err.Error())
+	}
+	for _, entry := range entries {
+		sql, err := partitionsFS.ReadFile("partitions/" + entry.Name())
+		if err != nil {
+			panic("failed to read partition sql file: " + err.Error())
+		}
+		s.statements["partitions/"+entry.Name()] = s.replacePlaceholders(sql)
+	}
+	s.migrations[0].SQL = s.statements["partitions/create."+s.tablePartitioning+".sql"]
+	return nil
+}
+
+func (s *Statements) PartitionListSQL() string    { return s.statements["partitions/list.sql"] }
+func (s *Statements) PartitionCreatedSQL() string { return s.statements["partitions/created.sql"] }
+
+// PartitionCreateRangeSQL returns the statement creating the partition index of a table partitioned by id range,
+// holding the ids from lower up to, but not including, upper.
+func (s *Statements) PartitionCreateRangeSQL(index, lower, upper int64) string {
+	return strings.NewReplacer(
+		"$INDEX", strconv.FormatInt(index, 10),
+		"$LOWER", strconv.FormatInt(lower, 10),
+		"$UPPER", strconv.FormatInt(upper, 10),
+	).Replace(s.statements["partitions/createrange.sql"])
+}
+
+// PartitionCreateHashSQL returns the statement creating the partition with remainder of a table partitioned by the
+// hash of the namespace into modulus partitions.
+func (s *Statements) PartitionCreateHashSQL(modulus, remainder int) string {
+	return strings.NewReplacer(
+		"$MODULUS", strconv.Itoa(modulus),
+		"$REMAINDER", strconv.Itoa(remainder),
+	).Replace(s.statements["partitions/createhash.sql"])
+}
+
+// PartitionCompactedSQL returns the statement counting the rows of the partition index of a table partitioned by id
+// range and the rows that compaction up to parameter 1 would keep.
+func (s *Statements) PartitionCompactedSQL(index int64) string {
+	return strings.ReplaceAll(s.statements["partitions/compacted.sql"], "$INDEX", strconv.FormatInt(index, 10))
+}
+
+// PartitionDropSQL returns the statement dropping the partition index of a table partitioned by id range.
+func (s *Statements) PartitionDropSQL(index int64) string {
+	return strings.ReplaceAll(s.statements["partitions/drop.sql"], "$INDEX", strconv.FormatInt(index, 10))
+}
+
+// PartitionIndex returns the index of the partition of a table partitioned by id range with the given name, and false
+// if name isn't such a partition.
+func (s *Statements) PartitionIndex(name string) (int64, bool) {
+	suffix, ok := strings.CutPrefix(name, s.tableName+"_p")
+	if !ok {
+		return 0, false
+	}
+	index, err := strconv.ParseInt(suffix, 10, 64)
+	return index, err == nil
+}
@@ -0,0 +1,7 @@
+SELECT count(*),
+       count(*) FILTER (WHERE NOT ((prev.deleted = 1 AND prev.id <= $1) OR
+                                   (prev.created IS NULL AND EXISTS (SELECT 1
+                                                                     FROM placeholder AS cur
+                                                                     WHERE cur.previous_id = prev.id
+                                                                       AND cur.id <= $1))))
+FROM placeholder_p$INDEX AS prev
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS placeholder
+(
+    id          INTEGER NOT NULL,
+    name        VARCHAR(255) NOT NULL,
+    namespace   VARCHAR(255) NOT NULL,
+    previous_id INTEGER,
+    uid         VARCHAR(255) NOT NULL,
+    created     INTEGER,
+    deleted     INTEGER       DEFAULT 0 NOT NULL,
+    value       TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (id)
+) PARTITION BY RANGE (id);
+
+CREATE INDEX IF NOT EXISTS placeholder_previous_id ON placeholder (previous_id);
+
+CREATE TABLE IF NOT EXISTS compaction
+(
+    name VARCHAR(255) NOT NULL UNIQUE,
+    id   INTEGER
+);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS placeholder
+(
+    id          INTEGER NOT NULL,
+    name        VARCHAR(255) NOT NULL,
+    namespace   VARCHAR(255) NOT NULL,
+    previous_id INTEGER,
+    uid         VARCHAR(255) NOT NULL,
+    created     INTEGER,
+    deleted     INTEGER       DEFAULT 0 NOT NULL,
+    value       TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (id, namespace),
+    CONSTRAINT placeholder_unique_previous_id_namespace UNIQUE (previous_id, namespace),
+    CONSTRAINT placeholder_unique_name_namespace_created UNIQUE (name, namespace, created)
+) PARTITION BY HASH (namespace);
+
+CREATE TABLE IF NOT EXISTS compaction
+(
+    name VARCHAR(255) NOT NULL UNIQUE,
+    id   INTEGER
+);
@@ -0,0 +1,5 @@
+SELECT count(*)
+FROM placeholder
+WHERE namespace = $1
+  AND name = $2
+  AND created = 1
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS placeholder_h$REMAINDER PARTITION OF placeholder FOR VALUES WITH (MODULUS $MODULUS, REMAINDER $REMAINDER)
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS placeholder_p$INDEX PARTITION OF placeholder FOR VALUES FROM ($LOWER) TO ($UPPER)
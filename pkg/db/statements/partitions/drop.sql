@@ -0,0 +1 @@
+DROP TABLE IF EXISTS placeholder_p$INDEX
@@ -0,0 +1,4 @@
+SELECT c.relname
+FROM pg_inherits AS i
+         JOIN pg_class AS c ON c.oid = i.inhrelid
+WHERE i.inhparent = 'placeholder'::regclass
@@ -0,0 +1,20 @@
+WITH kept AS (SELECT min(latest.id) AS id
+              FROM (SELECT id
+                    FROM placeholder
+                    WHERE namespace = $1
+                      AND name = $2
+                    ORDER BY id DESC
+                    LIMIT $3) AS latest)
+INSERT
+INTO pruned_history(name, namespace, object_name, first_id, id, kept_id)
+SELECT 'placeholder', $1, $2, min(pruned.id), max(pruned.id), kept.id
+FROM placeholder AS pruned,
+     kept
+WHERE pruned.namespace = $1
+  AND pruned.name = $2
+  AND pruned.created IS NULL
+  AND pruned.id < kept.id
+GROUP BY kept.id
+ON CONFLICT (name, namespace, object_name) DO UPDATE SET first_id = CASE WHEN EXCLUDED.first_id < pruned_history.first_id THEN EXCLUDED.first_id ELSE pruned_history.first_id END,
+                                                         id       = CASE WHEN EXCLUDED.id > pruned_history.id THEN EXCLUDED.id ELSE pruned_history.id END,
+                                                         kept_id  = CASE WHEN EXCLUDED.kept_id > pruned_history.kept_id THEN EXCLUDED.kept_id ELSE pruned_history.kept_id END;
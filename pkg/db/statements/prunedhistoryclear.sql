@@ -0,0 +1,6 @@
+DELETE
+FROM pruned_history
+WHERE name = 'placeholder'
+  AND kept_id <= coalesce((SELECT id
+                           FROM compaction
+                           WHERE name = 'placeholder'), 0);
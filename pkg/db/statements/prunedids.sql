@@ -0,0 +1,6 @@
+SELECT coalesce(max(id), 0)                                     AS pruned_id,
+       coalesce(max(CASE WHEN first_id <= $3 THEN kept_id END), 0) AS kept_id
+FROM pruned_history
+WHERE name = 'placeholder'
+  AND (namespace = $1 OR $1 IS NULL)
+  AND (object_name = $2 OR $2 IS NULL);
@@ -0,0 +1,12 @@
+DELETE
+FROM placeholder
+WHERE namespace = $1
+  AND name = $2
+  AND created IS NULL
+  AND id < (SELECT min(latest.id)
+            FROM (SELECT id
+                  FROM placeholder
+                  WHERE namespace = $1
+                    AND name = $2
+                  ORDER BY id DESC
+                  LIMIT $3) AS latest);
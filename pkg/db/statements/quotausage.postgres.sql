@@ -0,0 +1,9 @@
+SELECT count(*),
+       coalesce(sum(octet_length(r.value)), 0),
+       coalesce(sum(CASE WHEN r.name = $2 THEN octet_length(r.value) ELSE 0 END), 0)
+FROM (SELECT max(id) AS id
+      FROM placeholder
+      WHERE namespace = $1
+      GROUP BY name) AS latest
+         JOIN placeholder AS r ON r.id = latest.id
+WHERE r.deleted = 0
@@ -0,0 +1,2 @@
+INSERT INTO placeholder(id, name, namespace, previous_id, uid, created, deleted, value, partition_id, modified, checksum, version)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
@@ -0,0 +1,8 @@
+ALTER TABLE placeholder ENABLE ROW LEVEL SECURITY;
+ALTER TABLE placeholder FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS placeholder_partition ON placeholder;
+CREATE POLICY placeholder_partition ON placeholder
+    USING (partition_id = NULLIF(current_setting('app.tenant_id', true), '')
+        OR current_setting('app.tenant_bypass', true) = 'on')
+    WITH CHECK (partition_id = NULLIF(current_setting('app.tenant_id', true), '')
+        OR current_setting('app.tenant_bypass', true) = 'on')
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS schema_version
+(
+    name    VARCHAR(255) NOT NULL UNIQUE,
+    version INTEGER      NOT NULL
+);
@@ -0,0 +1,5 @@
+SELECT id, namespace, name, value, checksum
+FROM placeholder
+WHERE id > $1
+ORDER BY id
+LIMIT 500
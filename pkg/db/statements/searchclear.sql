@@ -0,0 +1,2 @@
+DELETE
+FROM placeholder_search
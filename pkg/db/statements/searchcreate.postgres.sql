@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS placeholder_search
+(
+    id        BIGINT PRIMARY KEY,
+    namespace VARCHAR(255) NOT NULL,
+    name      VARCHAR(255) NOT NULL,
+    content   TEXT         NOT NULL,
+    document  tsvector GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED
+)
@@ -0,0 +1 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS placeholder_search USING fts5(content, namespace UNINDEXED, name UNINDEXED)
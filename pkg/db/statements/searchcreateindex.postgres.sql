@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS placeholder_search_document ON placeholder_search USING GIN (document)
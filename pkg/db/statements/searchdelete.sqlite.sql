@@ -0,0 +1,3 @@
+DELETE
+FROM placeholder_search
+WHERE rowid = $1
@@ -0,0 +1,2 @@
+INSERT INTO placeholder_search (rowid, namespace, name, content)
+VALUES ($1, $2, $3, $4)
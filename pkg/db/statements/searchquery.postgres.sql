@@ -0,0 +1,5 @@
+SELECT id
+FROM placeholder_search
+WHERE document @@ plainto_tsquery('simple', $1)
+  AND (namespace = $2 OR $2 IS NULL)
+ORDER BY ts_rank(document, plainto_tsquery('simple', $1)) DESC, id
@@ -0,0 +1,5 @@
+SELECT rowid
+FROM placeholder_search
+WHERE placeholder_search MATCH $1
+  AND (namespace = $2 OR $2 IS NULL)
+ORDER BY rank, rowid
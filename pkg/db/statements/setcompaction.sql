@@ -0,0 +1,3 @@
+INSERT INTO compaction(name, id)
+VALUES ('placeholder', $1)
+ON CONFLICT (name) DO UPDATE SET id = EXCLUDED.id;
@@ -0,0 +1,3 @@
+INSERT INTO schema_version(name, version)
+VALUES ('placeholder', $1)
+ON CONFLICT (name) DO UPDATE SET version = EXCLUDED.version;
@@ -0,0 +1 @@
+SELECT set_config('app.tenant_id', $1, true), set_config('app.tenant_bypass', $2, true)
\ No newline at end of file
@@ -0,0 +1,14 @@
+SELECT id,
+       name,
+       namespace,
+       previous_id,
+       uid,
+       created,
+       deleted,
+       value,
+       partition_id,
+       modified,
+       checksum,
+       version
+FROM placeholder
+ORDER BY id
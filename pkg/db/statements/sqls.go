@@ -8,18 +8,158 @@ import (
 //go:embed *.sql
 var fs embed.FS
 
-func (s *Statements) CreateSQL() string           { return s.statements["migrate.sql"] }
-func (s *Statements) InsertSQL() string           { return s.statements["insert.sql"] }
-func (s *Statements) TableMetaSQL() string        { return s.statements["tablemeta.sql"] }
-func (s *Statements) ClearCreatedSQL() string     { return s.statements["clearcreated.sql"] }
-func (s *Statements) UpdateCompactionSQL() string { return s.statements["updatecompaction.sql"] }
-func (s *Statements) CompactSQL() string          { return s.statements["compact.sql"] }
-func (s *Statements) listSQL() string             { return s.statements["list.sql"] }
-func (s *Statements) listAfterSQL() string        { return s.statements["listafter.sql"] }
+func (s *Statements) InsertSQL() string              { return s.statements["insert.sql"] }
+func (s *Statements) TableMetaSQL() string           { return s.statements["tablemeta.sql"] }
+func (s *Statements) ClearCreatedSQL() string        { return s.statements["clearcreated.sql"] }
+func (s *Statements) UpdateCompactionSQL() string    { return s.statements["updatecompaction.sql"] }
+func (s *Statements) CompactSQL() string             { return s.statements["compact.sql"] }
+func (s *Statements) PruneHistorySQL() string        { return s.statements["prunehistory.sql"] }
+func (s *Statements) PrunedHistorySQL() string       { return s.statements["prunedhistory.sql"] }
+func (s *Statements) PrunedIDsSQL() string           { return s.statements["prunedids.sql"] }
+func (s *Statements) PrunedHistoryClearSQL() string  { return s.statements["prunedhistoryclear.sql"] }
+func (s *Statements) GetByIDSQL() string             { return s.statements["getbyid.sql"] }
+func (s *Statements) historySQL() string             { return s.statements["history.sql"] }
+func (s *Statements) SnapshotSQL() string            { return s.statements["snapshot.sql"] }
+func (s *Statements) RestoreSQL() string             { return s.statements["restore.sql"] }
+func (s *Statements) SetCompactionSQL() string       { return s.statements["setcompaction.sql"] }
+func (s *Statements) ListTablesSQL() string          { return s.statements["listtables.sql"] }
+func (s *Statements) TableStatsSQL() string          { return s.statements["tablestats.sql"] }
+func (s *Statements) TenantSnapshotSQL() string      { return s.statements["tenantsnapshot.sql"] }
+func (s *Statements) TenantStatsSQL() string         { return s.statements["tenantstats.sql"] }
+func (s *Statements) TenantPurgeSQL() string         { return s.statements["tenantpurge.sql"] }
+func (s *Statements) TenantPurgeArchiveSQL() string  { return s.statements["tenantpurgearchive.sql"] }
+func (s *Statements) SchemaVersionSQL() string       { return s.statements["schemaversion.sql"] }
+func (s *Statements) GetSchemaVersionSQL() string    { return s.statements["getschemaversion.sql"] }
+func (s *Statements) SetSchemaVersionSQL() string    { return s.statements["setschemaversion.sql"] }
+func (s *Statements) VerifyChainsSQL() string        { return s.statements["verifychains.sql"] }
+func (s *Statements) VerifyHeadsSQL() string         { return s.statements["verifyheads.sql"] }
+func (s *Statements) VerifyCreatedSQL() string       { return s.statements["verifycreated.sql"] }
+func (s *Statements) VerifyTombstonesSQL() string    { return s.statements["verifytombstones.sql"] }
+func (s *Statements) DeleteByIDSQL() string          { return s.statements["deletebyid.sql"] }
+func (s *Statements) ClearCreatedByIDSQL() string    { return s.statements["clearcreatedbyid.sql"] }
+func (s *Statements) ScrubSQL() string               { return s.statements["scrub.sql"] }
+func (s *Statements) NamespacesSQL() string          { return s.statements["namespaces.sql"] }
+func (s *Statements) UniqueCreateSQL() string        { return s.statements["uniquecreate.sql"] }
+func (s *Statements) UniqueClearSQL() string         { return s.statements["uniqueclear.sql"] }
+func (s *Statements) UniqueDeleteSQL() string        { return s.statements["uniquedelete.sql"] }
+func (s *Statements) UniqueInsertSQL() string        { return s.statements["uniqueinsert.sql"] }
+func (s *Statements) UniqueOwnerSQL() string         { return s.statements["uniqueowner.sql"] }
+func (s *Statements) UniqueRulesCreateSQL() string   { return s.statements["uniquerulescreate.sql"] }
+func (s *Statements) UniqueRulesDropSQL() string     { return s.statements["uniquerulesdrop.sql"] }
+func (s *Statements) UniqueRulesGetSQL() string      { return s.statements["uniquerulesget.sql"] }
+func (s *Statements) UniqueRulesClearSQL() string    { return s.statements["uniquerulesclear.sql"] }
+func (s *Statements) UniqueRulesInsertSQL() string   { return s.statements["uniquerulesinsert.sql"] }
+func (s *Statements) IndexCreateSQL() string         { return s.statements["indexcreate.sql"] }
+func (s *Statements) IndexCreateValuesSQL() string   { return s.statements["indexcreatevalues.sql"] }
+func (s *Statements) IndexInsertSQL() string         { return s.statements["indexinsert.sql"] }
+func (s *Statements) IndexMissingSQL() string        { return s.statements["indexmissing.sql"] }
+func (s *Statements) IndexPruneSQL() string          { return s.statements["indexprune.sql"] }
+func (s *Statements) SearchClearSQL() string         { return s.statements["searchclear.sql"] }
+func (s *Statements) AggregateEncodedSQL() string    { return s.statements["aggregateencoded.sql"] }
+func (s *Statements) ArchiveCreateSQL() string       { return s.statements["archivecreate.sql"] }
+func (s *Statements) ArchiveCreateIndexSQL() string  { return s.statements["archivecreateindex.sql"] }
+func (s *Statements) ArchiveCompactSQL() string      { return s.statements["archivecompact.sql"] }
+func (s *Statements) ArchivePruneSQL() string        { return s.statements["archiveprune.sql"] }
+func (s *Statements) historyArchiveSQL() string      { return s.statements["historyarchive.sql"] }
+func (s *Statements) CursorGetSQL() string           { return s.statements["cursorget.sql"] }
+func (s *Statements) CursorSetSQL() string           { return s.statements["cursorset.sql"] }
+func (s *Statements) CursorDeleteSQL() string        { return s.statements["cursordelete.sql"] }
+func (s *Statements) AuditCreateSQL() string         { return s.statements["auditcreate.sql"] }
+func (s *Statements) AuditInsertSQL() string         { return s.statements["auditinsert.sql"] }
+func (s *Statements) NotificationsSQL() string       { return s.statements["notifications.sql"] }
+func (s *Statements) HeadRevisionSQL() string        { return s.statements["headrevision.sql"] }
+func (s *Statements) MigrateStorageListSQL() string  { return s.statements["migratestoragelist.sql"] }
+func (s *Statements) MigrateStorageCountSQL() string { return s.statements["migratestoragecount.sql"] }
+func (s *Statements) MigrateStorageUpdateSQL() string {
+	return s.statements["migratestorageupdate.sql"]
+}
+func (s *Statements) listSQL() string              { return s.statements["list.sql"] }
+func (s *Statements) listAfterSQL() string         { return s.statements["listafter.sql"] }
+func (s *Statements) listAfterPreviousSQL() string { return s.statements["listafterprevious.sql"] }
+func (s *Statements) listByNameSQL() string        { return s.statements["listbyname.sql"] }
+
+func (s *Statements) HasColumnSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["hascolumn.postgres.sql"]
+	}
+	return s.statements["hascolumn.sqlite.sql"]
+}
+
+func (s *Statements) SearchCreateSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["searchcreate.postgres.sql"]
+	}
+	return s.statements["searchcreate.sqlite.sql"]
+}
+
+func (s *Statements) SearchCreateIndexSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["searchcreateindex.postgres.sql"]
+	}
+	return ""
+}
+
+func (s *Statements) SearchDeleteSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["searchdelete.postgres.sql"]
+	}
+	return s.statements["searchdelete.sqlite.sql"]
+}
+
+func (s *Statements) SearchInsertSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["searchinsert.postgres.sql"]
+	}
+	return s.statements["searchinsert.sqlite.sql"]
+}
+
+func (s *Statements) SearchSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["searchquery.postgres.sql"]
+	}
+	return s.statements["searchquery.sqlite.sql"]
+}
+
+func (s *Statements) QuotaUsageSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["quotausage.postgres.sql"]
+	}
+	return s.statements["quotausage.sqlite.sql"]
+}
+
+func (s *Statements) AnalyzeSQL() string { return s.statements["analyze.sql"] }
+
+func (s *Statements) VacuumSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["vacuum.postgres.sql"]
+	}
+	return s.statements["vacuum.sqlite.sql"]
+}
+
+func (s *Statements) SchemaVersionLockSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["schemaversionlock.sql"]
+	}
+	return ""
+}
 
 func (s *Statements) TableLockSQL() string {
-	if s.lock {
+	if s.dialect == Postgres {
 		return s.statements["tablelock.sql"]
 	}
 	return ""
 }
+
+func (s *Statements) RowSecuritySQL() string {
+	if s.dialect == Postgres {
+		return s.statements["rowsecurity.postgres.sql"]
+	}
+	return ""
+}
+
+func (s *Statements) SetTenantSQL() string {
+	if s.dialect == Postgres {
+		return s.statements["settenant.postgres.sql"]
+	}
+	return ""
+}
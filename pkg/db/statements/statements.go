@@ -3,22 +3,56 @@ package statements
 import (
 	_ "embed"
 	"fmt"
+	iofs "io/fs"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+// sharedTables are the tables shared by all kinds that need to be prefixed
+var sharedTables = regexp.MustCompile(`\b(compaction|schema_version|watch_cursor|pruned_history)\b`)
+
+// Dialect is the SQL dialect of a database.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
 type Statements struct {
-	tableName  string
-	statements map[string]string
-	lock       bool
+	tableName         string
+	prefix            string
+	statements        map[string]string
+	migrations        []Migration
+	extensions        []string
+	overrides         []iofs.FS
+	tablePartitioning string
+	err               error
+	dialect           Dialect
+}
+
+// Option configures optional behavior of Statements created by New.
+type Option func(*Statements)
+
+// WithTablePrefix prefixes the names of all tables, including the shared compaction, schema version, watch cursor and
+// pruned history tables, so that multiple applications can use the same database without their tables colliding.
+func WithTablePrefix(prefix string) Option {
+	return func(s *Statements) {
+		s.prefix = prefix
+	}
 }
 
-func New(tableName string, lock bool) *Statements {
+// New returns the statements of the table tableName in dialect.
+func New(tableName string, dialect Dialect, opts ...Option) *Statements {
 	s := &Statements{
-		tableName:  tableName,
 		statements: map[string]string{},
-		lock:       lock,
+		dialect:    dialect,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.tableName = s.prefix + tableName
 	entries, err := fs.ReadDir(".")
 	if err != nil {
 		panic("failed to read sql files: " + err.Error())
@@ -31,17 +65,37 @@ func New(tableName string, lock bool) *Statements {
 		if err != nil {
 			panic("failed to read sql file: " + err.Error())
 		}
-		s.initSQL(entry.Name(), sql)
+		s.statements[entry.Name()] = s.replacePlaceholders(sql)
+	}
+	s.loadMigrations()
+	if s.tablePartitioning != "" {
+		s.err = s.loadPartitions()
+	}
+	if s.err == nil && len(s.overrides) > 0 {
+		s.err = s.applyOverrides(tableName)
 	}
 	return s
 }
 
-func (s *Statements) initSQL(name string, sqlData []byte) {
+// Prefix returns the prefix of all table names.
+func (s *Statements) Prefix() string {
+	return s.prefix
+}
+
+// TableName returns the name of the table, including any prefix.
+func (s *Statements) TableName() string {
+	return s.tableName
+}
+
+func (s *Statements) replacePlaceholders(sqlData []byte) string {
 	// This is hacky, sue me
 	sql := strings.ReplaceAll(string(sqlData), "'placeholder'", fmt.Sprintf(`'%s'`, s.tableName))
 	sql = strings.ReplaceAll(sql, "placeholder", fmt.Sprintf(`"%s"`, s.tableName))
 	sql = strings.ReplaceAll(sql, fmt.Sprintf(`"%s"_`, s.tableName), fmt.Sprintf(`%s_`, s.tableName))
-	s.statements[name] = strings.TrimSpace(sql)
+	if s.prefix != "" {
+		sql = sharedTables.ReplaceAllString(sql, fmt.Sprintf(`"%s$1"`, s.prefix))
+	}
+	return strings.TrimSpace(sql)
 }
 
 func (s *Statements) ListSQL(limit int64) string {
@@ -57,3 +111,90 @@ func (s *Statements) ListAfterSQL(limit int64) string {
 	}
 	return s.listAfterSQL()
 }
+
+// ListAfterPreviousSQL is like ListAfterSQL, but also reads the value of the previous revision of each record.
+func (s *Statements) ListAfterPreviousSQL(limit int64) string {
+	if limit > 0 {
+		return s.listAfterPreviousSQL() + " LIMIT " + strconv.FormatInt(limit+1, 10)
+	}
+	return s.listAfterPreviousSQL()
+}
+
+func (s *Statements) ListByNameSQL(limit int64) string {
+	if limit > 0 {
+		return s.listByNameSQL() + " LIMIT " + strconv.FormatInt(limit+1, 10)
+	}
+	return s.listByNameSQL()
+}
+
+// WithIndexFilters restricts query, a list statement, to the objects whose indexed fields have the given values. The
+// field and value of each of the count filters are consecutive parameters, starting with parameter first.
+func (s *Statements) WithIndexFilters(query string, count, first int) string {
+	if count == 0 {
+		return query
+	}
+	var filters strings.Builder
+	for i := range count {
+		filter := strings.ReplaceAll(s.statements["indexfilter.sql"], "$FIELD", "$"+strconv.Itoa(first+2*i))
+		filters.WriteString("\n  ")
+		filters.WriteString(strings.ReplaceAll(filter, "$VALUE", "$"+strconv.Itoa(first+2*i+1)))
+	}
+	// The filters apply to the latest revision of each object, selected by the outer query
+	orderBy := strings.LastIndex(query, "ORDER BY")
+	return query[:orderBy] + strings.TrimPrefix(filters.String(), "\n") + "\n" + query[orderBy:]
+}
+
+// SearchQuery returns the parameter of SearchSQL that matches the text containing every word of query.
+func (s *Statements) SearchQuery(query string) string {
+	if s.dialect == Postgres {
+		// plainto_tsquery ignores punctuation and requires every word
+		return query
+	}
+	// Quote the words so that FTS5 doesn't parse them as operators
+	words := strings.Fields(query)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// AggregateSQL returns the statement that counts the objects by the values of count fields, whose paths are the
+// parameters starting with parameter 3, as returned by AggregatePath.
+func (s *Statements) AggregateSQL(count int) string {
+	field := s.statements["aggregatefield.sqlite.sql"]
+	if s.dialect == Postgres {
+		field = s.statements["aggregatefield.postgres.sql"]
+	}
+	var groups, groupBy []string
+	for i := range count {
+		groups = append(groups, strings.ReplaceAll(field, "$PATH", "$"+strconv.Itoa(3+i))+",")
+		groupBy = append(groupBy, strconv.Itoa(i+1))
+	}
+	query := strings.Replace(s.statements["aggregate.sql"], "$GROUPS ", strings.Join(groups, " ")+" ", 1)
+	if count > 0 {
+		query += "\nGROUP BY " + strings.Join(groupBy, ", ")
+	}
+	return query
+}
+
+// AggregatePath returns the parameter of AggregateSQL for the field at the dot separated path.
+func (s *Statements) AggregatePath(path string) string {
+	if s.dialect == Postgres {
+		return "{" + strings.ReplaceAll(path, ".", ",") + "}"
+	}
+	return "$." + path
+}
+
+func (s *Statements) HistoryArchiveSQL(limit int64) string {
+	if limit > 0 {
+		return s.historyArchiveSQL() + " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+	return s.historyArchiveSQL()
+}
+
+func (s *Statements) HistorySQL(limit int64) string {
+	if limit > 0 {
+		return s.historySQL() + " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+	return s.historySQL()
+}
@@ -0,0 +1,3 @@
+SELECT count(*)                                                  AS records,
+       coalesce(sum(CASE WHEN created = 1 THEN 1 ELSE 0 END), 0) AS objects
+FROM placeholder
@@ -0,0 +1,3 @@
+DELETE
+FROM placeholder
+WHERE partition_id = $1
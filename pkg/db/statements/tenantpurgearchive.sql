@@ -0,0 +1,3 @@
+DELETE
+FROM placeholder_archive
+WHERE partition_id = $1
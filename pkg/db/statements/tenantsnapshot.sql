@@ -0,0 +1,15 @@
+SELECT id,
+       name,
+       namespace,
+       previous_id,
+       uid,
+       created,
+       deleted,
+       value,
+       partition_id,
+       modified,
+       checksum,
+       version
+FROM placeholder
+WHERE partition_id = $1
+ORDER BY id
@@ -0,0 +1,2 @@
+DELETE
+FROM placeholder_unique
\ No newline at end of file
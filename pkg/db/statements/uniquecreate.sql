@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS placeholder_unique
+(
+    field     VARCHAR(255) NOT NULL,
+    scope     VARCHAR(255) NOT NULL,
+    value     TEXT         NOT NULL,
+    namespace VARCHAR(255) NOT NULL,
+    name      VARCHAR(255) NOT NULL,
+    CONSTRAINT placeholder_unique_field_scope_value UNIQUE (field, scope, value)
+)
\ No newline at end of file
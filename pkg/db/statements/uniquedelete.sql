@@ -0,0 +1,4 @@
+DELETE
+FROM placeholder_unique
+WHERE namespace = $1
+  AND name = $2
\ No newline at end of file
@@ -0,0 +1,2 @@
+INSERT INTO placeholder_unique (field, scope, value, namespace, name)
+VALUES ($1, $2, $3, $4, $5)
\ No newline at end of file
@@ -0,0 +1,6 @@
+SELECT namespace,
+       name
+FROM placeholder_unique
+WHERE field = $1
+  AND scope = $2
+  AND value = $3
\ No newline at end of file
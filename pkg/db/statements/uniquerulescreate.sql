@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS placeholder_unique_rules
+(
+    hash VARCHAR(64) NOT NULL
+)
\ No newline at end of file
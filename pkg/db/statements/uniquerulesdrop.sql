@@ -0,0 +1 @@
+DROP TABLE IF EXISTS placeholder_unique_rules
\ No newline at end of file
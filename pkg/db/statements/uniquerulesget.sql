@@ -0,0 +1,2 @@
+SELECT hash
+FROM placeholder_unique_rules
\ No newline at end of file
@@ -0,0 +1,2 @@
+INSERT INTO placeholder_unique_rules (hash)
+VALUES ($1)
\ No newline at end of file
@@ -0,0 +1 @@
+VACUUM placeholder
\ No newline at end of file
@@ -0,0 +1 @@
+PRAGMA incremental_vacuum
\ No newline at end of file
@@ -0,0 +1,8 @@
+SELECT cur.id, cur.namespace, cur.name
+FROM placeholder AS cur
+         JOIN placeholder AS prev ON prev.id = cur.previous_id
+WHERE prev.namespace <> cur.namespace
+   OR prev.name <> cur.name
+   OR prev.id >= cur.id
+   OR prev.deleted = 1
+ORDER BY cur.id
@@ -0,0 +1,10 @@
+SELECT r.id, r.namespace, r.name
+FROM placeholder AS r
+WHERE r.created = 1
+  AND EXISTS (SELECT 1
+              FROM placeholder AS t
+              WHERE t.namespace = r.namespace
+                AND t.name = r.name
+                AND t.deleted = 1
+                AND t.id > r.id)
+ORDER BY r.id
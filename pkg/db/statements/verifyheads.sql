@@ -0,0 +1,7 @@
+SELECT r.id, r.namespace, r.name
+FROM placeholder AS r
+WHERE r.deleted = 0
+  AND r.created IS NULL
+  AND NOT EXISTS (SELECT 1 FROM placeholder AS n WHERE n.previous_id = r.id)
+  AND r.id < (SELECT max(l.id) FROM placeholder AS l WHERE l.namespace = r.namespace AND l.name = r.name)
+ORDER BY r.id
@@ -0,0 +1,5 @@
+SELECT id, namespace, name
+FROM placeholder
+WHERE deleted = 1
+  AND previous_id IS NULL
+ORDER BY id
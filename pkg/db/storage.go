@@ -0,0 +1,32 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+	"k8s.io/apiserver/pkg/storage/storagebackend/factory"
+	"k8s.io/client-go/tools/cache"
+)
+
+// StorageDecorator returns a decorator for generic.RESTOptions that stores each resource in its own kinm table
+// instead of etcd. opts are applied to every strategy that is created.
+func (f *Factory) StorageDecorator(opts ...Option) generic.StorageDecorator {
+	return func(_ *storagebackend.ConfigForResource, resourcePrefix string, _ func(obj runtime.Object) (string, error),
+		newFunc func() runtime.Object, _ func() runtime.Object, _ storage.AttrFunc, _ storage.IndexerFuncs,
+		_ *cache.Indexers) (storage.Interface, factory.DestroyFunc, error) {
+		obj, ok := newFunc().(types.Object)
+		if !ok {
+			return nil, nil, fmt.Errorf("unexpected object type %T", newFunc())
+		}
+		s, err := f.NewDBStrategy(obj, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return strategy.NewStorage(s, resourcePrefix), s.Destroy, nil
+	}
+}
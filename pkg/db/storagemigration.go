@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// StorageMigrationOptions configures a storage migration, which rewrites the rows of a table, including retained
+// history, that were written in another API version than the storage version of the table.
+type StorageMigrationOptions struct {
+	// BatchSize is the number of rows rewritten per transaction. The default is 500.
+	BatchSize int
+	// Reencode also rewrites the rows that are already in the storage version, so that every value is stored with the
+	// current compression, transformer and blob settings, such as after rotating an encryption key.
+	Reencode bool
+	// UnknownVersion is the version of the rows written before the version of rows was recorded. The default is the
+	// storage version.
+	UnknownVersion string
+	// Progress is called after every batch.
+	Progress func(StorageMigrationProgress)
+}
+
+// StorageMigrationProgress is the progress of a storage migration.
+type StorageMigrationProgress struct {
+	// Migrated is the number of rows rewritten so far.
+	Migrated int64
+	// Total is the number of rows to rewrite, counted when the migration started.
+	Total int64
+}
+
+// WithStorageMigration migrates the table to the storage version in the background when the strategy is created, see
+// Strategy.MigrateStorage. Progress is logged.
+func WithStorageMigration(opts StorageMigrationOptions) Option {
+	return func(s *Strategy) {
+		s.storageMigration = &opts
+	}
+}
+
+// MigrateStorage rewrites the rows of the table that were written in another API version than the version of the
+// strategy, converting their values with the conversion functions of the scheme, which must have the types of those
+// versions. Rows are rewritten in place in batches, keeping their resourceVersions, so objects can be read and written
+// during the migration and no events are sent. A migration that is interrupted continues where it stopped when it is
+// run again.
+//
+// Until its rows are migrated, an object stored in an older version is read as if it were in the storage version, so
+// the storage version should only change in ways that older values still decode into.
+func (s *Strategy) MigrateStorage(ctx context.Context, opts StorageMigrationOptions) (StorageMigrationProgress, error) {
+	if err := s.beginRequest(ctx, mutatingRequest); err != nil {
+		return StorageMigrationProgress{}, err
+	}
+	defer s.endRequest(mutatingRequest)
+	return s.db.migrateStorage(WithRowLevelSecurityBypass(ctx), s.db.gvk.Version, opts, s.convertValue)
+}
+
+// MigrateStorage records version as the version of the rows of table without a version and, with opts.Reencode,
+// rewrites every row with the value options of the factory. Admin doesn't have the types of the table, so it fails if
+// a row was written in another version; use Strategy.MigrateStorage in the program that serves the table instead.
+func (a *Admin) MigrateStorage(ctx context.Context, table, version string, opts StorageMigrationOptions) (StorageMigrationProgress, error) {
+	d, err := a.table(ctx, table, false)
+	if err != nil {
+		return StorageMigrationProgress{}, err
+	}
+	return d.migrateStorage(ctx, version, opts, nil)
+}
+
+// migrateStorageInBackground runs the migration configured with WithStorageMigration.
+func (s *Strategy) migrateStorageInBackground(ctx context.Context) {
+	opts := *s.storageMigration
+	progress := opts.Progress
+	opts.Progress = func(p StorageMigrationProgress) {
+		klog.Infof("migrating %q to %s: %d/%d rows", s.db.stmt.TableName(), s.db.gvk.Version, p.Migrated, p.Total)
+		if progress != nil {
+			progress(p)
+		}
+	}
+	if _, err := s.MigrateStorage(ctx, opts); err != nil {
+		klog.Errorf("failed to migrate %q to %s: %v", s.db.stmt.TableName(), s.db.gvk.Version, err)
+	}
+}
+
+// convertValue converts a value written in version from to the storage version.
+func (s *Strategy) convertValue(from, value string) (string, error) {
+	gvk := s.db.gvk
+	gvk.Version = from
+	old, err := s.scheme.New(gvk)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal([]byte(value), old); err != nil {
+		return "", err
+	}
+	obj := s.New()
+	if err := s.scheme.Convert(old, obj, nil); err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	if err := json.NewEncoder(&buf).Encode(obj); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// migrateStorage rewrites the rows that are not in version, or every row if opts.Reencode is set, converting values
+// with convert.
+func (d *db) migrateStorage(ctx context.Context, version string, opts StorageMigrationOptions, convert func(from, value string) (string, error)) (progress StorageMigrationProgress, _ error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.UnknownVersion == "" {
+		opts.UnknownVersion = version
+	}
+	reencode := 0
+	if opts.Reencode {
+		reencode = 1
+	}
+
+	total, err := d.countStorageMigration(ctx, version, reencode)
+	if err != nil {
+		return progress, err
+	}
+	progress.Total = total
+
+	var after int64
+	for {
+		count, last, err := d.migrateStorageBatch(ctx, version, after, reencode, opts, convert)
+		if err != nil {
+			return progress, err
+		}
+		if count == 0 {
+			return progress, nil
+		}
+		after = last
+		progress.Migrated += count
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+}
+
+// countStorageMigration returns the number of rows that the migration to version rewrites.
+func (d *db) countStorageMigration(ctx context.Context, version string, reencode int) (total int64, _ error) {
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	err = d.queryRowContext(ctx, d.stmt.MigrateStorageCountSQL(), version, reencode).Scan(&total)
+	return total, err
+}
+
+// migrateStorageBatch rewrites the next batch of rows after the given id and returns the number of rows rewritten and
+// the id of the last one.
+func (d *db) migrateStorageBatch(ctx context.Context, version string, after int64, reencode int, opts StorageMigrationOptions, convert func(from, value string) (string, error)) (int64, int64, error) {
+	ctx, tx, err := d.beginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := d.queryContext(ctx, d.stmt.MigrateStorageListSQL(), after, version, reencode, opts.BatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	type migrateRecord struct {
+		record
+		checksum sql.NullInt64
+		version  sql.NullString
+	}
+	var batch []migrateRecord
+	for rows.Next() {
+		var r migrateRecord
+		if err := rows.Scan(&r.id, &r.namespace, &r.name, &r.value, &r.checksum, &r.version); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	// The rows are closed before values are decoded, since decoding may need the connection
+	for _, r := range batch {
+		if err := d.readValue(ctx, &r.record, r.checksum); err != nil {
+			return 0, 0, fmt.Errorf("failed to read %s %s/%s at %d: %w", d.gvk.Kind, r.namespace, r.name, r.id, err)
+		}
+		from := opts.UnknownVersion
+		if r.version.Valid {
+			from = r.version.String
+		}
+		value := r.value
+		if from != version {
+			if convert == nil {
+				return 0, 0, fmt.Errorf("%s/%s at %d is stored in version %s, converting it to %s needs the types of the table",
+					r.namespace, r.name, r.id, from, version)
+			}
+			if value, err = convert(from, value); err != nil {
+				return 0, 0, fmt.Errorf("failed to convert %s %s/%s at %d from %s: %w", d.gvk.Kind, r.namespace, r.name, r.id, from, err)
+			}
+		}
+		if value, err = d.encodeValue(ctx, r.namespace, r.name, value); err != nil {
+			return 0, 0, err
+		}
+		if _, err := d.execContext(ctx, d.stmt.MigrateStorageUpdateSQL(), r.id, value, valueChecksum(value), version); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if len(batch) == 0 {
+		return 0, 0, nil
+	}
+	return int64(len(batch)), batch[len(batch)-1].id, tx.Commit()
+}
@@ -1,43 +1,89 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"iter"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/obot-platform/kinm/pkg/db/errors"
 	"github.com/obot-platform/kinm/pkg/db/statements"
 	"github.com/obot-platform/kinm/pkg/strategy"
 	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/apiserver/pkg/storage"
-	"k8s.io/klog/v2"
+	"k8s.io/kube-openapi/pkg/validation/validate"
 )
 
 var _ strategy.CompleteStrategy = (*Strategy)(nil)
 
 type Strategy struct {
-	db               db
-	objTemplate      types.Object
-	objListTemplate  types.ObjectList
-	scheme           *runtime.Scheme
-	cancelCompaction func()
-
-	broadcastLock sync.Mutex
-	broadcast     chan struct{}
+	db              db
+	objTemplate     types.Object
+	objListTemplate types.ObjectList
+	scheme          *runtime.Scheme
+	// ctx is canceled when the strategy is destroyed
+	ctx          context.Context
+	cancel       context.CancelFunc
+	lifecycle    lifecycle
+	limits       limits
+	drainTimeout time.Duration
+	watchBuffer  int
+
+	watchPollInterval time.Duration
+	bookmarkInterval  time.Duration
+	maxWatchDuration  time.Duration
+	scrubInterval     time.Duration
+	storageMigration  *StorageMigrationOptions
+
+	changes broadcaster
+	// invariants checks the ordering of resource versions if set
+	invariants *invariants
+	// onChange is called after every change, in addition to notifying the watches of this strategy
+	onChange func()
+	// onDestroy is called when the strategy has been destroyed
+	onDestroy func()
+
+	statementOptions []statements.Option
+	// dialect is the SQL dialect of the database, set by the factory or else detected from the driver
+	dialect statements.Dialect
+
+	defaulter         strategy.Defaulter
+	prepareForCreater strategy.PrepareForCreator
+	prepareForUpdater strategy.PrepareForUpdater
+	statusIsolation   bool
+	nameValidator     strategy.NameValidator
+
+	namespaceLifecycle bool
+	hooks              []Hooks
+
+	compactionMetrics         *CompactionMetrics
+	compactionEvents          strategy.Creater
+	compactionEventsNamespace string
+
+	clusterScoped           bool
+	creationTimestampPolicy CreationTimestampPolicy
+	// schemaValidator is set for dynamic kinds
+	schemaValidator *validate.SchemaValidator
 }
 
 type record struct {
@@ -47,87 +93,203 @@ type record struct {
 	uid              string
 	created, deleted int16
 	value            string
+	partitionID      string
+	// modified is the time the record was written in Unix milliseconds. It is only loaded by history queries and
+	// is zero for records written before it was tracked.
+	modified int64
+	// archived is the time the record was moved to the archive table in Unix milliseconds. It is only loaded by
+	// history queries and is zero for records that are not archived.
+	archived int64
+	// previousValue is the stored value of the previous revision, as read from the database. It is only loaded by
+	// queries for changes in a context from withPreviousValues and is nil if there is no previous revision or it has
+	// been compacted.
+	previousValue    *string
+	previousChecksum sql.NullInt64
 }
 
 func (r *record) Unmarshal(obj types.Object) error {
-	if err := json.Unmarshal([]byte(r.value), obj); err != nil {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		// The value is merged into the content of the empty object, which keeps its kind if the value was written by a
+		// typed strategy without one
+		if err := utiljson.Unmarshal([]byte(r.value), &u.Object); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal([]byte(r.value), obj); err != nil {
 		return err
 	}
 	obj.SetResourceVersion(strconv.FormatInt(r.id, 10))
 	return nil
 }
 
-func New(ctx context.Context, sqlDB *sql.DB, gvk schema.GroupVersionKind, scheme *runtime.Scheme, tableName string) (*Strategy, error) {
-	objTemplate, err := scheme.New(gvk)
+// Option configures optional behavior of a Strategy created by New.
+type Option func(*Strategy)
+
+// WithStatementOptions configures the SQL statements used by the strategy.
+func WithStatementOptions(opts ...statements.Option) Option {
+	return func(s *Strategy) {
+		s.statementOptions = append(s.statementOptions, opts...)
+	}
+}
+
+// WithStatementOverrides replaces and extends the SQL statements of the table with the files of fsys, see
+// statements.WithOverrides.
+func WithStatementOverrides(fsys fs.FS) Option {
+	return WithStatementOptions(statements.WithOverrides(fsys))
+}
+
+// New creates the table for gvk if needed and returns a strategy for it. The objects of kinds that aren't registered
+// in the scheme are unstructured.Unstructured, so that generic tooling can open any table without its Go types.
+func New(ctx context.Context, sqlDB *sql.DB, gvk schema.GroupVersionKind, scheme *runtime.Scheme, tableName string, opts ...Option) (*Strategy, error) {
+	objTemplate, objListTemplate, err := newTemplates(scheme, gvk)
 	if err != nil {
 		return nil, err
 	}
-	objListTemplate, err := scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
-	if err != nil {
+
+	s := &Strategy{
+		db: db{
+			sqlDB: sqlDB,
+			gvk:   gvk,
+		},
+		objTemplate:     objTemplate,
+		objListTemplate: objListTemplate,
+		scheme:          scheme,
+	}
+	if indexer, ok := objTemplate.(FieldIndexer); ok {
+		s.db.indexes = indexer.IndexFields()
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.initFieldEncryption(); err != nil {
 		return nil, err
 	}
-	newDB := db{
-		sqlDB: sqlDB,
-		stmt:  statements.New(tableName, sqlDB.Stats().MaxOpenConnections != 1),
-		gvk:   gvk,
+	if s.dialect == "" {
+		s.dialect = driverDialect(sqlDB)
 	}
-	if err := newDB.migrate(ctx); err != nil {
+	s.db.stmt = statements.New(tableName, s.dialect, s.statementOptions...)
+	if err := s.db.stmt.Err(); err != nil {
 		return nil, err
 	}
 
-	s := &Strategy{
-		db:              newDB,
-		objTemplate:     objTemplate.(types.Object),
-		objListTemplate: objListTemplate.(types.ObjectList),
-		scheme:          scheme,
-		broadcast:       make(chan struct{}),
+	// Migrations, rebuilds and the background maintenance, such as compaction, work on the rows of all partitions
+	ctx = WithRowLevelSecurityBypass(ctx)
+	if err := s.db.migrate(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.db.enableRowLevelSecurity(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.db.rebuildUnique(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.db.createIndexes(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.db.rebuildSearch(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.db.createArchive(ctx); err != nil {
+		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
-	go func() {
-		ticker := time.NewTicker(15 * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if count, err := s.db.compact(ctx); err != nil {
-					klog.Errorf("failed to compact %q: %v", tableName, err)
-				} else if count > 0 {
-					klog.Infof("compacted %q: %d records", tableName, count)
-				}
-			}
-		}
-	}()
+	// The context passed in may only be scoped to the migration, so don't let it stop compaction.
+	s.ctx, s.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	ctx = s.ctx
+	go s.compactPeriodically(ctx)
+	if s.scrubInterval > 0 {
+		go s.scrubPeriodically(ctx, s.scrubInterval)
+	}
+	if s.storageMigration != nil {
+		go s.migrateStorageInBackground(ctx)
+	}
 
-	s.cancelCompaction = cancel
 	return s, nil
 }
 
+// newTemplates returns an empty object and list of gvk, which are unstructured if gvk isn't registered in the scheme.
+func newTemplates(scheme *runtime.Scheme, gvk schema.GroupVersionKind) (types.Object, types.ObjectList, error) {
+	listGVK := gvk.GroupVersion().WithKind(gvk.Kind + "List")
+	obj, err := scheme.New(gvk)
+	if runtime.IsNotRegisteredError(err) {
+		obj, list := &unstructured.Unstructured{}, &unstructured.UnstructuredList{}
+		obj.SetGroupVersionKind(gvk)
+		list.SetGroupVersionKind(listGVK)
+		return obj, list, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	list, err := scheme.New(listGVK)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The unstructured objects of dynamic kinds don't know their kind until it is set
+	if u, ok := obj.(runtime.Unstructured); ok {
+		u.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+	if u, ok := list.(runtime.Unstructured); ok {
+		u.GetObjectKind().SetGroupVersionKind(listGVK)
+	}
+	return obj.(types.Object), list.(types.ObjectList), nil
+}
+
 func (s *Strategy) Create(ctx context.Context, object types.Object) (types.Object, error) {
+	if err := s.beginRequest(ctx, mutatingRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(mutatingRequest)
+
 	if object.GetUID() == "" {
 		return nil, fmt.Errorf("object must have a UID")
 	}
 
+	if err := s.validateObjectMeta(ctx, object); err != nil {
+		return nil, err
+	}
+	if err := s.setCreationTimestamp(object); err != nil {
+		return nil, err
+	}
+
 	defer s.broadcastChange()
 
+	s.prepareForCreate(ctx, object)
+	if err := s.validateSchema(object); err != nil {
+		return nil, err
+	}
+	return s.create(ctx, object)
+}
+
+// create stores object as a new object and calls the after hooks.
+func (s *Strategy) create(ctx context.Context, object types.Object) (types.Object, error) {
+	result, err := s.doCreate(ctx, object)
+	if err != nil {
+		return nil, err
+	}
+	s.afterWrite(ctx, createWrite, result)
+	return result, nil
+}
+
+// doCreate stores object as a new object and returns it with its resource version.
+func (s *Strategy) doCreate(ctx context.Context, object types.Object) (types.Object, error) {
 	// On create all objects have a generation of 1
 	object.SetGeneration(1)
 	// All stored objects have a resource version of 0
 	object.SetResourceVersion("0")
+	sortManagedFields(object)
 
 	var buf strings.Builder
 	if err := json.NewEncoder(&buf).Encode(object); err != nil {
 		return nil, err
 	}
 
-	id, err := s.db.insert(ctx, record{
-		name:      object.GetName(),
-		namespace: object.GetNamespace(),
-		uid:       string(object.GetUID()),
-		created:   1,
-		value:     buf.String(),
+	id, err := s.write(ctx, createWrite, object, func(ctx context.Context) (int64, error) {
+		return s.db.insert(ctx, record{
+			name:      object.GetName(),
+			namespace: object.GetNamespace(),
+			uid:       string(object.GetUID()),
+			created:   1,
+			value:     buf.String(),
+		})
 	})
 	if err != nil {
 		return nil, err
@@ -143,24 +305,68 @@ func (s *Strategy) New() types.Object {
 }
 
 func (s *Strategy) Get(ctx context.Context, namespace, name string) (types.Object, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+
+	return s.get(ctx, namespace, name)
+}
+
+func (s *Strategy) get(ctx context.Context, namespace, name string) (types.Object, error) {
 	rec, err := s.db.get(ctx, namespace, name)
 	if err != nil {
 		return nil, err
 	}
 	result := s.New()
-	if err := json.Unmarshal([]byte(rec.value), result); err != nil {
+	if err := rec.Unmarshal(result); err != nil {
 		return nil, err
 	}
-	result.SetResourceVersion(strconv.FormatInt(rec.id, 10))
 	return result, nil
 }
 
 func (s *Strategy) Update(ctx context.Context, obj types.Object) (types.Object, error) {
+	if err := s.beginRequest(ctx, mutatingRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(mutatingRequest)
+
 	defer s.broadcastChange()
-	return s.doUpdate(ctx, obj, true)
+	if err := s.validateScope(obj); err != nil {
+		return nil, err
+	}
+	obj, err := s.prepareForUpdate(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateSchema(obj); err != nil {
+		return nil, err
+	}
+	return s.update(ctx, obj, false)
+}
+
+// update stores obj, updating its generation unless status is true, and calls the after hooks.
+func (s *Strategy) update(ctx context.Context, obj types.Object, status bool) (types.Object, error) {
+	var (
+		result types.Object
+		op     writeOp
+		err    error
+	)
+	if s.statusIsolation {
+		result, op, err = s.updateIsolated(ctx, obj, status)
+	} else {
+		result, op, err = s.doUpdate(ctx, obj, !status)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.afterWrite(ctx, op, result)
+	return result, nil
 }
 
-func (s *Strategy) doUpdate(ctx context.Context, obj types.Object, updateGeneration bool) (types.Object, error) {
+// doUpdate stores obj and returns it with its new resource version and the kind of write, which is noWrite if obj
+// didn't change.
+func (s *Strategy) doUpdate(ctx context.Context, obj types.Object, updateGeneration bool) (types.Object, writeOp, error) {
 	var (
 		buf             strings.Builder
 		resourceVersion int64
@@ -170,19 +376,24 @@ func (s *Strategy) doUpdate(ctx context.Context, obj types.Object, updateGenerat
 	if obj.GetResourceVersion() != "" {
 		resourceVersion, err = strconv.ParseInt(obj.GetResourceVersion(), 10, 64)
 		if err != nil {
-			return nil, err
+			return nil, noWrite, err
 		}
 	}
 
 	obj = obj.DeepCopyObject().(types.Object)
 	if updateGeneration {
-		obj.SetGeneration(obj.GetGeneration() + 1)
+		generation, err := s.nextGeneration(ctx, obj, resourceVersion)
+		if err != nil {
+			return nil, noWrite, err
+		}
+		obj.SetGeneration(generation)
 	}
 	// All stored objects have a resource version of 0
 	obj.SetResourceVersion("0")
+	sortManagedFields(obj)
 
 	if err := json.NewEncoder(&buf).Encode(obj); err != nil {
-		return nil, err
+		return nil, noWrite, err
 	}
 
 	rec := record{
@@ -193,23 +404,103 @@ func (s *Strategy) doUpdate(ctx context.Context, obj types.Object, updateGenerat
 		value:      buf.String(),
 	}
 
-	var id int64
+	op := updateWrite
 	if obj.GetDeletionTimestamp() != nil && len(obj.GetFinalizers()) == 0 {
-		id, err = s.db.delete(ctx, rec)
-	} else {
-		id, err = s.db.insert(ctx, rec)
+		op = deleteWrite
 	}
+	id, err := s.write(ctx, op, obj, func(ctx context.Context) (int64, error) {
+		if op == deleteWrite {
+			return s.db.delete(ctx, rec)
+		}
+		return s.db.insert(ctx, rec)
+	})
 	if err != nil {
-		return nil, err
+		return nil, noWrite, err
+	}
+	if id == resourceVersion {
+		op = noWrite
 	}
 
 	obj.SetResourceVersion(strconv.FormatInt(id, 10))
-	return obj, nil
+	return obj, op, nil
+}
+
+// sortManagedFields sorts the managed fields entries of obj, so that an update that only reorders them stores the same
+// value as before and is detected as a no-op.
+func sortManagedFields(obj types.Object) {
+	managedFields := obj.GetManagedFields()
+	if len(managedFields) < 2 {
+		return
+	}
+	slices.SortStableFunc(managedFields, func(a, b metav1.ManagedFieldsEntry) int {
+		return cmp.Or(
+			strings.Compare(a.Manager, b.Manager),
+			strings.Compare(string(a.Operation), string(b.Operation)),
+			strings.Compare(a.Subresource, b.Subresource),
+			strings.Compare(a.APIVersion, b.APIVersion),
+		)
+	})
+	obj.SetManagedFields(managedFields)
+}
+
+// nextGeneration returns the generation of obj when it replaces the revision resourceVersion: the generation of that
+// revision, incremented if the spec changed. The spec is everything but the metadata and status. If the revision no
+// longer exists, the write fails with a conflict, so the generation of obj is incremented without comparing.
+func (s *Strategy) nextGeneration(ctx context.Context, obj types.Object, resourceVersion int64) (int64, error) {
+	rec, err := s.db.getByID(ctx, resourceVersion)
+	if err != nil {
+		return 0, err
+	}
+	if rec == nil {
+		return obj.GetGeneration() + 1, nil
+	}
+	old := s.New()
+	if err := rec.Unmarshal(old); err != nil {
+		return 0, err
+	}
+
+	changed, err := specChanged(obj, old)
+	if err != nil {
+		return 0, err
+	}
+	if changed {
+		return old.GetGeneration() + 1, nil
+	}
+	return old.GetGeneration(), nil
+}
+
+// specChanged returns true if obj and old differ in anything but their metadata and status.
+func specChanged(obj, old types.Object) (bool, error) {
+	var specs [2]map[string]any
+	for i, o := range []types.Object{obj, old} {
+		spec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			return false, err
+		}
+		// The content of unstructured objects isn't copied by the converter
+		spec = maps.Clone(spec)
+		for _, field := range []string{"apiVersion", "kind", "metadata", "status"} {
+			delete(spec, field)
+		}
+		specs[i] = spec
+	}
+	return !equality.Semantic.DeepEqual(specs[0], specs[1]), nil
 }
 
 func (s *Strategy) UpdateStatus(ctx context.Context, obj types.Object) (types.Object, error) {
+	if err := s.beginRequest(ctx, mutatingRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(mutatingRequest)
+
 	defer s.broadcastChange()
-	return s.doUpdate(ctx, obj, false)
+	if err := s.validateScope(obj); err != nil {
+		return nil, err
+	}
+	if err := s.validateSchema(obj); err != nil {
+		return nil, err
+	}
+	return s.update(ctx, obj, true)
 }
 
 func (s *Strategy) prepareList(opts storage.ListOptions) (storage.ListOptions, error) {
@@ -225,12 +516,20 @@ func (s *Strategy) prepareList(opts storage.ListOptions) (storage.ListOptions, e
 	}
 	if opts.Predicate.GetAttrs == nil {
 		opts.Predicate.GetAttrs = storage.DefaultNamespaceScopedAttr
+		if !s.NamespaceScoped() {
+			opts.Predicate.GetAttrs = storage.DefaultClusterScopedAttr
+		}
 	}
 
 	return opts, nil
 }
 
 func (s *Strategy) List(ctx context.Context, namespace string, opts storage.ListOptions) (types.ObjectList, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+
 	var (
 		objs       []runtime.Object
 		listResult = s.NewList()
@@ -242,10 +541,22 @@ func (s *Strategy) List(ctx context.Context, namespace string, opts storage.List
 		return nil, err
 	}
 
+	paginated := opts.Predicate.Limit > 0
+	if maxObjects := s.limits.maxListObjects; maxObjects > 0 && (!paginated || opts.Predicate.Limit > maxObjects) {
+		// An unpaginated list over the limit is detected by reading one object more
+		opts.Predicate.Limit = maxObjects
+	}
+	var (
+		size int64
+		last record
+	)
+
+	floor := s.listFloor(ctx, opts)
 	listResourceVersion, iter, err := newLister(ctx, &s.db, namespace, opts, false)
 	if err != nil {
 		return nil, err
 	}
+	s.checkList(ctx, namespace, floor, listResourceVersion)
 
 	for rec, err := range iter {
 		if err != nil {
@@ -263,13 +574,23 @@ func (s *Strategy) List(ctx context.Context, namespace string, opts storage.List
 			continue
 		}
 
+		size += int64(len(rec.value))
+
 		// We check this at the end because the next object could possibly not match the predicate so
 		// we don't want to do continue token to them result in the next call being an empty list.
-		if opts.Predicate.Limit > 0 && len(objs) >= int(opts.Predicate.Limit) {
-			listResult.SetContinue(listResourceVersion + ":" + objs[len(objs)-1].(types.Object).GetResourceVersion())
+		full := opts.Predicate.Limit > 0 && len(objs) >= int(opts.Predicate.Limit)
+		if !full && s.limits.maxListBytes > 0 && size > s.limits.maxListBytes && len(objs) > 0 {
+			full = true
+		}
+		if full {
+			if !paginated {
+				return nil, s.limits.listTooLarge(s.db.gvk.Kind)
+			}
+			listResult.SetContinue(s.db.continueToken(listResourceVersion, last))
 			break
 		}
 		objs = append(objs, obj)
+		last = rec
 	}
 
 	listResult.SetResourceVersion(listResourceVersion)
@@ -281,16 +602,32 @@ func (s *Strategy) NewList() types.ObjectList {
 }
 
 func (s *Strategy) Delete(ctx context.Context, obj types.Object) (types.Object, error) {
-	defer s.broadcastChange()
-	if obj.GetDeletionTimestamp() == nil {
-		now := metav1.Now()
-		obj.SetDeletionTimestamp(&now)
+	if err := s.beginRequest(ctx, mutatingRequest); err != nil {
+		return nil, err
 	}
+	defer s.endRequest(mutatingRequest)
+
+	defer s.broadcastChange()
+	setDeletionTimestamp(obj)
 
-	return s.doUpdate(ctx, obj, false)
+	result, op, err := s.doUpdate(ctx, obj, false)
+	if err != nil {
+		return nil, err
+	}
+	s.afterWrite(ctx, op, result)
+	return result, nil
 }
 
 func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	w, err := s.watch(ctx, namespace, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return w.ch, nil
+}
+
+// watch starts a watch, sending events with the previous object if ext is true.
+func (s *Strategy) watch(ctx context.Context, namespace string, opts storage.ListOptions, ext bool) (*watcher, error) {
 	opts, err := s.prepareList(opts)
 	if err != nil {
 		return nil, err
@@ -308,17 +645,58 @@ func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.Lis
 		opts.ResourceVersion = ""
 	}
 
+	if err := s.beginWatch(); err != nil {
+		return nil, err
+	}
+
+	// The watch ends when the strategy is destroyed
+	w := s.newWatcher(namespace, ext)
+	ctx, cancel := s.watchContext(ctx, w)
+	if ext {
+		ctx = withPreviousValues(ctx)
+	}
+
 	// If resourceVersion is set we immediately go to watch phase and skip the historical list
-	resourceVersion, lister, err := newLister(ctx, &s.db, namespace, opts, opts.ResourceVersion != "")
-	if err != nil {
+	resourceVersion, lister, err := s.newWatchLister(ctx, namespace, opts, opts.ResourceVersion != "")
+	if errors.IsCompacted(err) {
+		// Like the watch cache of the API server, a compacted resourceVersion is reported as an error event rather than
+		// failing the request, so reflectors relist no matter when the compaction is noticed
+		go func() {
+			defer s.endWatch()
+			defer cancel()
+			defer w.close()
+			w.sendError(ctx, err)
+		}()
+		return w, nil
+	} else if err != nil {
+		cancel()
+		s.endWatch()
 		return nil, err
 	}
 
+	initial := opts.ResourceVersion == ""
+	check := s.newWatchCheck(namespace, opts, resourceVersion)
+	if !initial {
+		// The changes are after the requested resource version rather than the one the query returned
+		check = s.newWatchCheck(namespace, opts, opts.ResourceVersion)
+	}
 	opts.ResourceVersion = resourceVersion
 
-	ch := make(chan watch.Event)
-	go s.streamWatch(ctx, namespace, opts, lister, ch)
-	return ch, nil
+	go func() {
+		defer s.endWatch()
+		defer cancel()
+		s.streamWatch(ctx, namespace, opts, lister, initial, check, w)
+	}()
+	return w, nil
+}
+
+// newWatchLister is newLister in a slot of the watch concurrency limit.
+func (s *Strategy) newWatchLister(ctx context.Context, namespace string, opts storage.ListOptions, after bool) (string, iter.Seq2[record, error], error) {
+	if err := s.limits.concurrency.acquire(ctx, watchRequest, s.db.gvk.Kind); err != nil {
+		return "", nil, err
+	}
+	defer s.limits.concurrency.release(watchRequest)
+	return newLister(ctx, &s.db, namespace, opts, after)
 }
 
 func toWatchEventError(err error) watch.Event {
@@ -348,62 +726,90 @@ func (s *Strategy) toWatchEvent(rec record) watch.Event {
 }
 
 func (s *Strategy) broadcastChange() {
-	s.broadcastLock.Lock()
-	defer s.broadcastLock.Unlock()
-	close(s.broadcast)
-	s.broadcast = make(chan struct{})
-	return
+	s.changes.notify()
+	if s.onChange != nil {
+		s.onChange()
+	}
 }
 
 func (s *Strategy) waitChange() <-chan struct{} {
-	s.broadcastLock.Lock()
-	defer s.broadcastLock.Unlock()
-	return s.broadcast
+	return s.changes.wait()
 }
 
-func (s *Strategy) streamWatch(ctx context.Context, namespace string, opts storage.ListOptions, lister iter.Seq2[record, error], ch chan watch.Event) {
-	defer close(ch)
+// streamWatch sends the events of lister and then of the changes after it. If initial is true, lister is the list of
+// the existing objects rather than of changes. The events are checked by check, if set.
+func (s *Strategy) streamWatch(ctx context.Context, namespace string, opts storage.ListOptions, lister iter.Seq2[record, error], initial bool, check *watchCheck, w *watcher) {
+	defer w.close()
 
 	var bookmarks <-chan time.Time
 	if opts.ProgressNotify {
-		ticker := time.NewTicker(time.Minute)
+		ticker := time.NewTicker(s.getBookmarkInterval())
 		defer ticker.Stop()
 		bookmarks = ticker.C
 	}
 
+	// last is the ID of the last change sent, or the resource version of the initial list. Every query is for the
+	// changes after it and the changes at or before it are skipped, so that a change returned again by a later query,
+	// such as one at the boundary of the previous query, is never sent twice.
+	var last int64
 	for {
 		for rec, err := range lister {
 			if err != nil {
-				ch <- toWatchEventError(err)
+				w.sendError(ctx, err)
 				return
 			}
-			event := s.toWatchEvent(rec)
+			if !initial {
+				if rec.id <= last {
+					continue
+				}
+				last = rec.id
+			}
+			check.event(rec, initial)
+			event := ExtendedEvent{Event: s.toWatchEvent(rec)}
 			if ok, err := opts.Predicate.Matches(event.Object); err != nil {
-				ch <- toWatchEventError(err)
+				if !w.send(ctx, toWatchEventError(err)) {
+					return
+				}
 			} else if ok {
-				ch <- event
+				if w.ext != nil && event.Type != watch.Error {
+					if event.OldObject, err = s.previousObject(ctx, rec); err != nil {
+						event.Event = toWatchEventError(err)
+					}
+				}
+				if !w.sendExt(ctx, event) {
+					return
+				}
 			}
 		}
 
-		var (
-			newResourceVersion string
-			err                error
-		)
+		// The query covered the changes up to its resource version
+		boundary, err := strconv.ParseInt(opts.ResourceVersion, 10, 64)
+		if err != nil {
+			w.sendError(ctx, err)
+			return
+		}
+		last = max(last, boundary)
+		opts.ResourceVersion = strconv.FormatInt(last, 10)
 
-		newResourceVersion, lister, err = newLister(ctx, &s.db, namespace, opts, true)
+		var newResourceVersion string
+		initial = false
+		newResourceVersion, lister, err = s.newWatchLister(ctx, namespace, opts, true)
 		if err != nil {
-			ch <- toWatchEventError(err)
+			w.sendError(ctx, err)
 			return
 		}
+		check.requery(opts.ResourceVersion, newResourceVersion)
 
-		if newResourceVersion == opts.ResourceVersion {
+		if newBoundary, _ := strconv.ParseInt(newResourceVersion, 10, 64); newBoundary <= last {
 			select {
 			case <-ctx.Done():
 				return
 			case <-bookmarks:
-				ch <- watch.Event{Type: watch.Bookmark, Object: nil}
+				if !w.send(ctx, watch.Event{Type: watch.Bookmark, Object: nil}) {
+					return
+				}
 			case <-s.waitChange():
-			case <-time.After(2 * time.Second):
+			case <-time.After(s.getWatchPollInterval()):
 			}
 		}
 
@@ -411,11 +817,6 @@ func (s *Strategy) streamWatch(ctx context.Context, namespace string, opts stora
 	}
 }
 
-func (s *Strategy) Destroy() {
-	s.cancelCompaction()
-	s.db.Close()
-}
-
 func (s *Strategy) Scheme() *runtime.Scheme {
 	return s.scheme
 }
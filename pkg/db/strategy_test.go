@@ -2,20 +2,61 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/obot-platform/kinm/pkg/db/envelope"
+	"github.com/obot-platform/kinm/pkg/db/errors"
+	"github.com/obot-platform/kinm/pkg/db/statements"
+	"github.com/obot-platform/kinm/pkg/informer"
+	"github.com/obot-platform/kinm/pkg/standalone"
+	"github.com/obot-platform/kinm/pkg/stores"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/strategy/admission"
+	"github.com/obot-platform/kinm/pkg/strategy/aggregate"
+	"github.com/obot-platform/kinm/pkg/strategy/audit"
+	"github.com/obot-platform/kinm/pkg/strategy/middleware"
+	"github.com/obot-platform/kinm/pkg/strategy/versioned"
+	"github.com/obot-platform/kinm/pkg/strategy/view"
+	kinmtypes "github.com/obot-platform/kinm/pkg/types"
+	"github.com/obot-platform/kinm/pkg/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
+	authuser "k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
 	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/tools/cache"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 )
 
 var ctx = context.Background()
@@ -52,16 +93,15 @@ func (t *TestKindList) DeepCopyObject() runtime.Object {
 	return &TestKindList{}
 }
 
-func newStrategy(t *testing.T) *Strategy {
+func newStrategy(t testing.TB, opts ...Option) *Strategy {
 	t.Helper()
 
 	schema := runtime.NewScheme()
 	schema.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
 
 	db := newDatabase(t)
-	_, err := db.sqlDB.Exec("DROP TABLE IF EXISTS strategytest")
-	require.NoError(t, err)
-	s, err := New(ctx, db.sqlDB, testGVK, schema, "strategytest")
+	dropTable(t, db.sqlDB, "strategytest")
+	s, err := New(ctx, db.sqlDB, testGVK, schema, "strategytest", opts...)
 	require.NoError(t, err)
 
 	for i := range 3 {
@@ -138,6 +178,193 @@ func TestStrategyListFilterNeedTwoChunks(t *testing.T) {
 	assert.Equal(t, "testname3", list.Items[0].Name)
 }
 
+func TestStrategyListMaxSize(t *testing.T) {
+	s := newStrategy(t, WithMaxListSize(2, 0))
+
+	_, err := s.List(ctx, "", storage.ListOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), err)
+
+	// Lists within the limit still succeed without pagination
+	result, err := s.List(ctx, "testnamespace1", storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, result.(*TestKindList).Items, 1)
+
+	// Paginated lists are capped at the maximum
+	result, err = s.List(ctx, "", storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 10}})
+	require.NoError(t, err)
+	list := result.(*TestKindList)
+	assert.Len(t, list.Items, 2)
+	require.NotEmpty(t, list.Continue)
+
+	result, err = s.List(ctx, "", storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 10, Continue: list.Continue}})
+	require.NoError(t, err)
+	assert.Len(t, result.(*TestKindList).Items, 1)
+	assert.Empty(t, result.(*TestKindList).Continue)
+
+	// Every value is larger than half the byte limit, so pages hold a single object
+	s.limits.maxListObjects = 0
+	s.limits.maxListBytes = 300
+	_, err = s.List(ctx, "", storage.ListOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), err)
+
+	var names []string
+	opts := storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 10}}
+	for {
+		result, err = s.List(ctx, "", opts)
+		require.NoError(t, err)
+		list := result.(*TestKindList)
+		require.Len(t, list.Items, 1)
+		names = append(names, list.Items[0].Name)
+		if list.Continue == "" {
+			break
+		}
+		opts.Predicate.Continue = list.Continue
+	}
+	assert.Equal(t, []string{"testname1", "testname2", "testname3"}, names)
+}
+
+func TestStrategyListNameOrdering(t *testing.T) {
+	s := newStrategy(t, WithNameOrdering())
+
+	_, err := s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "testnamespace2", UID: "uida"},
+	})
+	require.NoError(t, err)
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "updated"
+	_, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+
+	names := func(list kinmtypes.ObjectList) (result []string) {
+		for _, item := range list.(*TestKindList).Items {
+			result = append(result, item.Namespace+"/"+item.Name)
+		}
+		return result
+	}
+
+	result, err := s.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"testnamespace1/testname1",
+		"testnamespace2/a",
+		"testnamespace2/testname2",
+		"testnamespace3/testname3",
+	}, names(result))
+
+	result, err = s.List(ctx, "", storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 2}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testnamespace1/testname1", "testnamespace2/a"}, names(result))
+	require.NotEmpty(t, result.GetContinue())
+
+	result, err = s.List(ctx, "", storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 2, Continue: result.GetContinue()}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testnamespace2/testname2", "testnamespace3/testname3"}, names(result))
+	assert.Empty(t, result.GetContinue())
+
+	// Continue tokens of lists ordered by resource version are rejected
+	_, err = s.List(ctx, "", storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 2, Continue: "5:2"}})
+	assert.Error(t, err)
+}
+
+func TestNamespaces(t *testing.T) {
+	s := newStrategy(t)
+
+	namespaces, err := s.Namespaces(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testnamespace1", "testnamespace2", "testnamespace3"}, namespaces)
+
+	obj, err := s.Get(ctx, "testnamespace3", "testname3")
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+
+	namespaces, err = s.Namespaces(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testnamespace1", "testnamespace2"}, namespaces)
+}
+
+func TestUniqueFields(t *testing.T) {
+	s := newStrategy(t, WithUniqueFields(UniqueRule{Field: "value"}))
+
+	_, err := s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "duplicate", Namespace: "testnamespace1", UID: "uid"},
+		Value:      "testvalue1",
+	})
+	require.True(t, apierrors.IsInvalid(err), err)
+	causes := err.(*apierrors.StatusError).ErrStatus.Details.Causes
+	require.Len(t, causes, 1)
+	assert.Equal(t, metav1.CauseTypeFieldValueDuplicate, causes[0].Type)
+	assert.Equal(t, "value", causes[0].Field)
+	assert.Contains(t, causes[0].Message, "testnamespace1/testname1")
+
+	// Values are unique per namespace
+	_, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "duplicate", Namespace: "testnamespace2", UID: "uid"},
+		Value:      "testvalue1",
+	})
+	require.NoError(t, err)
+
+	// Updating or deleting an object releases its value
+	obj, err := s.Get(ctx, "testnamespace2", "testname2")
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "testvalue1"
+	_, err = s.Update(ctx, obj)
+	assert.True(t, apierrors.IsInvalid(err), err)
+	obj.(*TestKind).Value = "changed"
+	_, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	_, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "reuse", Namespace: "testnamespace2", UID: "uid"},
+		Value:      "testvalue2",
+	})
+	require.NoError(t, err)
+
+	obj, err = s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+	_, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "duplicate", Namespace: "testnamespace1", UID: "uid"},
+		Value:      "testvalue1",
+	})
+	require.NoError(t, err)
+
+	// Existing objects violate a cluster wide rule
+	_, err = New(ctx, s.db.sqlDB, testGVK, s.scheme, "strategytest", WithUniqueFields(UniqueRule{Field: "value", ClusterWide: true}))
+	assert.Error(t, err)
+}
+
+func TestUniqueFieldsRebuild(t *testing.T) {
+	rule := UniqueRule{Field: "value"}
+	s := newStrategy(t, WithUniqueFields(rule))
+
+	count := func() (count int) {
+		t.Helper()
+		require.NoError(t, s.db.sqlDB.QueryRow("SELECT count(*) FROM strategytest_unique").Scan(&count))
+		return count
+	}
+	require.Equal(t, 3, count())
+	_, err := s.db.sqlDB.Exec("DELETE FROM strategytest_unique WHERE name = 'testname1'")
+	require.NoError(t, err)
+
+	// The table isn't rebuilt with the same rules
+	_, err = New(ctx, s.db.sqlDB, testGVK, s.scheme, "strategytest", WithUniqueFields(rule, rule))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count())
+
+	_, err = New(ctx, s.db.sqlDB, testGVK, s.scheme, "strategytest", WithUniqueFields(rule, UniqueRule{Field: "metadata.uid"}))
+	require.NoError(t, err)
+	assert.Equal(t, 6, count())
+
+	// Writes without rules don't maintain the table, so it is rebuilt when the rules are added back
+	_, err = New(ctx, s.db.sqlDB, testGVK, s.scheme, "strategytest")
+	require.NoError(t, err)
+	_, err = New(ctx, s.db.sqlDB, testGVK, s.scheme, "strategytest", WithUniqueFields(rule))
+	require.NoError(t, err)
+	assert.Equal(t, 3, count())
+}
+
 func TestStrategyDeleteNeedRevision(t *testing.T) {
 	s := newStrategy(t)
 	_, err := s.Delete(context.Background(), &TestKind{
@@ -200,6 +427,149 @@ func TestWatchRv(t *testing.T) {
 	assert.Equal(t, "testname3", event.Object.(kclient.Object).GetName())
 }
 
+func TestWatchCompacted(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newStrategy(t)
+	_, err := s.db.execContext(ctx, s.db.stmt.SetCompactionSQL(), 3)
+	require.NoError(t, err)
+
+	// The changes after 2 may have been compacted, so the watch ends with a 410 error event
+	w, err := s.Watch(ctx, "", storage.ListOptions{ResourceVersion: "2"})
+	require.NoError(t, err)
+	var events []watch.Event
+	for event := range w {
+		events = append(events, event)
+	}
+	require.Len(t, events, 1)
+	assert.Equal(t, watch.Error, events[0].Type)
+	assert.Equal(t, int32(410), events[0].Object.(*metav1.Status).Code)
+	assert.Equal(t, metav1.StatusReasonExpired, events[0].Object.(*metav1.Status).Reason)
+
+	// The changes after the compaction ID are all there
+	w, err = s.Watch(ctx, "", storage.ListOptions{ResourceVersion: "3"})
+	require.NoError(t, err)
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "updated"
+	_, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	event := <-w
+	assert.Equal(t, watch.Modified, event.Type)
+	assert.Equal(t, "4", event.Object.(kclient.Object).GetResourceVersion())
+}
+
+func TestWatchCursor(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newStrategy(t)
+	cursor := s.WatchCursor("test")
+	// Cursors are kept when the table is dropped
+	require.NoError(t, cursor.Reset(ctx))
+
+	rv, err := cursor.ResourceVersion(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rv)
+
+	w, err := cursor.Watch(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	event := <-w
+	assert.Equal(t, "testname1", event.Object.(kclient.Object).GetName())
+	event = <-w
+	assert.Equal(t, "testname2", event.Object.(kclient.Object).GetName())
+	require.NoError(t, cursor.Save(ctx, event.Object.(kclient.Object).GetResourceVersion()))
+
+	rv, err = cursor.ResourceVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, event.Object.(kclient.Object).GetResourceVersion(), rv)
+
+	// Another consumer has its own cursor
+	rv, err = s.WatchCursor("other").ResourceVersion(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rv)
+
+	w, err = cursor.Watch(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	event = <-w
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, "testname3", event.Object.(kclient.Object).GetName())
+
+	_, err = s.db.execContext(ctx, s.db.stmt.SetCompactionSQL(), 3)
+	require.NoError(t, err)
+	_, err = cursor.Watch(ctx, "", storage.ListOptions{})
+	assert.True(t, errors.IsCompacted(err))
+
+	assert.True(t, apierrors.IsBadRequest(cursor.Save(ctx, "invalid")))
+}
+
+func TestWatchNoDuplicates(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newStrategy(t)
+	_, records, err := s.db.list(ctx, nil, ptr("testname3"), 0, false, cursor{}, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	// A query at resource version 2 that read the change at 3 past its boundary
+	opts, err := s.prepareList(storage.ListOptions{ResourceVersion: "2"})
+	require.NoError(t, err)
+	lister := func(yield func(record, error) bool) {
+		yield(records[0], nil)
+	}
+	w := s.newWatcher("", false)
+	go s.streamWatch(ctx, "", opts, lister, false, nil, w)
+
+	event := <-w.ch
+	assert.Equal(t, "3", event.Object.(kclient.Object).GetResourceVersion())
+
+	// The next query starts after the change sent rather than the boundary, so it isn't sent again
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "updated"
+	_, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	event = <-w.ch
+	assert.Equal(t, watch.Modified, event.Type)
+	assert.Equal(t, "4", event.Object.(kclient.Object).GetResourceVersion())
+}
+
+func TestWatchExt(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newStrategy(t)
+	w, err := s.WatchExt(ctx, "", storage.ListOptions{
+		ResourceVersion: "2",
+	})
+	require.NoError(t, err)
+
+	event := <-w
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, "testname3", event.Object.(kclient.Object).GetName())
+	assert.Nil(t, event.OldObject)
+
+	test2, err := s.Get(ctx, "", "testname2")
+	require.NoError(t, err)
+	test2.(*TestKind).Value = "newvalue"
+	test2, err = s.Update(ctx, test2)
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, test2)
+	require.NoError(t, err)
+
+	event = <-w
+	assert.Equal(t, watch.Modified, event.Type)
+	assert.Equal(t, "newvalue", event.Object.(*TestKind).Value)
+	assert.Equal(t, "testvalue2", event.OldObject.(*TestKind).Value)
+	assert.Equal(t, "2", event.OldObject.(kclient.Object).GetResourceVersion())
+
+	event = <-w
+	assert.Equal(t, watch.Deleted, event.Type)
+	assert.Equal(t, "newvalue", event.OldObject.(*TestKind).Value)
+}
+
 func TestWatchChanges(t *testing.T) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -301,3 +671,2599 @@ func TestContinue(t *testing.T) {
 	assert.Equal(t, "", list.Continue)
 
 }
+
+func TestAudit(t *testing.T) {
+	s := newStrategy(t)
+	_, err := s.db.sqlDB.Exec("DROP TABLE IF EXISTS audittest")
+	require.NoError(t, err)
+	sink, err := NewAuditSink(ctx, s.db.sqlDB, "audittest")
+	require.NoError(t, err)
+
+	var events []audit.Event
+	a := audit.NewStrategy(s, audit.SinkFunc(func(ctx context.Context, event audit.Event) error {
+		events = append(events, event)
+		return sink.Record(ctx, event)
+	}), nil)
+
+	ctx := request.WithUser(ctx, &authuser.DefaultInfo{Name: "alice"})
+	created, err := a.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "audited", Namespace: "default", UID: "uid"},
+	})
+	require.NoError(t, err)
+	_, err = a.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "audited", Namespace: "default", UID: "uid"},
+	})
+	require.Error(t, err)
+	updated, err := a.Update(ctx, created)
+	require.NoError(t, err)
+
+	require.Len(t, events, 3)
+	assert.Equal(t, audit.VerbCreate, events[0].Verb)
+	assert.Equal(t, "alice", events[0].User)
+	assert.Equal(t, testGVK, events[0].GroupVersionKind)
+	assert.Equal(t, created.GetResourceVersion(), events[0].NewResourceVersion)
+	assert.Equal(t, audit.OutcomeFailure, events[1].Outcome)
+	assert.Equal(t, created.GetResourceVersion(), events[2].OldResourceVersion)
+	assert.Equal(t, updated.GetResourceVersion(), events[2].NewResourceVersion)
+
+	var count int
+	require.NoError(t, s.db.sqlDB.QueryRow("SELECT COUNT(*) FROM audittest WHERE username = 'alice'").Scan(&count))
+	assert.Equal(t, 3, count)
+}
+
+func TestAdmission(t *testing.T) {
+	s := newStrategy(t)
+
+	var oldNames []string
+	a, err := admission.NewStrategy(s,
+		admission.MutatingFunc{
+			PluginName: "label",
+			Func: func(ctx context.Context, attr admission.Attributes) error {
+				attr.Object.SetLabels(map[string]string{"admitted": "true"})
+				if attr.OldObject != nil {
+					oldNames = append(oldNames, attr.OldObject.GetName())
+				}
+				return nil
+			},
+		},
+		admission.ValidatingFunc{
+			PluginName: "deny",
+			Func: func(ctx context.Context, attr admission.Attributes) error {
+				if attr.Name == "denied" {
+					return fmt.Errorf("name is not allowed")
+				}
+				return nil
+			},
+		})
+	require.NoError(t, err)
+
+	created, err := a.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "admitted", Namespace: "default", UID: "uid"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "true", created.GetLabels()["admitted"])
+
+	_, err = a.Update(ctx, created)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admitted"}, oldNames)
+
+	_, err = a.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "denied", Namespace: "default", UID: "uid2"},
+	})
+	assert.True(t, apierrors.IsForbidden(err))
+	_, err = s.Get(ctx, "default", "denied")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestCELValidation(t *testing.T) {
+	s := newStrategy(t)
+
+	v, err := admission.NewCELValidator()
+	require.NoError(t, err)
+	require.Error(t, v.Register(testGVK, admission.Rule{Rule: "self.value +"}))
+	require.NoError(t, v.Register(testGVK,
+		admission.Rule{
+			Rule:      "self.value.startsWith('ok')",
+			Message:   "value must start with ok",
+			FieldPath: ".value",
+		},
+		admission.Rule{
+			Rule: "self.value.size() >= oldSelf.value.size()",
+		}))
+
+	a, err := admission.NewStrategy(s, v)
+	require.NoError(t, err)
+
+	_, err = a.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "default", UID: "uid"},
+		Value:      "bad",
+	})
+	require.True(t, apierrors.IsInvalid(err))
+	assert.Contains(t, err.Error(), `value: Invalid value: "bad": value must start with ok`)
+
+	created, err := a.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "valid", Namespace: "default", UID: "uid"},
+		Value:      "ok-long",
+	})
+	require.NoError(t, err)
+
+	created.(*TestKind).Value = "ok"
+	_, err = a.Update(ctx, created)
+	require.True(t, apierrors.IsInvalid(err))
+	// The object isn't included in the errors of whole-object rules
+	assert.Contains(t, err.Error(), "Invalid value: failed rule: self.value.size() >= oldSelf.value.size()")
+}
+
+type testDefaulter struct{}
+
+func (testDefaulter) Default(_ context.Context, obj runtime.Object) {
+	if obj.(*TestKind).Value == "" {
+		obj.(*TestKind).Value = "default"
+	}
+}
+
+type testPreparer struct{}
+
+func (testPreparer) PrepareForCreate(_ context.Context, obj runtime.Object) {
+	obj.(*TestKind).SetLabels(nil)
+}
+
+func (testPreparer) PrepareForUpdate(_ context.Context, obj, old runtime.Object) {
+	obj.(*TestKind).SetLabels(old.(*TestKind).GetLabels())
+}
+
+func TestPrepareHooks(t *testing.T) {
+	db := newDatabase(t)
+	schema := runtime.NewScheme()
+	schema.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+	dropTable(t, db.sqlDB, "hookstest")
+	s, err := New(ctx, db.sqlDB, testGVK, schema, "hookstest",
+		WithDefaulter(testDefaulter{}),
+		WithPrepareForCreate(testPreparer{}),
+		WithPrepareForUpdate(testPreparer{}))
+	require.NoError(t, err)
+	defer s.Destroy()
+
+	created, err := s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "hooks", Namespace: "default", UID: "uid", Labels: map[string]string{"a": "b"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "default", created.(*TestKind).Value)
+	assert.Empty(t, created.GetLabels())
+
+	created.SetLabels(map[string]string{"c": "d"})
+	created.(*TestKind).Value = ""
+	updated, err := s.Update(ctx, created)
+	require.NoError(t, err)
+	assert.Equal(t, "default", updated.(*TestKind).Value)
+	assert.Empty(t, updated.GetLabels())
+}
+
+func TestNameValidation(t *testing.T) {
+	s := newStrategy(t)
+
+	_, err := s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "Not_Valid", Namespace: "Bad.Namespace", UID: "uid"},
+	})
+	require.True(t, apierrors.IsInvalid(err))
+	causes := err.(apierrors.APIStatus).Status().Details.Causes
+	require.Len(t, causes, 2)
+	assert.Equal(t, "metadata.name", causes[0].Field)
+	assert.Equal(t, "metadata.namespace", causes[1].Field)
+
+	s.nameValidator = validator.NoValidation
+	_, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "Not_Valid", Namespace: "default", UID: "uid"},
+	})
+	require.NoError(t, err)
+}
+
+func TestDestroyDrainsWatches(t *testing.T) {
+	s := newStrategy(t)
+
+	w, err := s.Watch(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+
+	s.Destroy()
+
+	for event := range w {
+		assert.NotEqual(t, watch.Error, event.Type)
+	}
+
+	_, err = s.Get(ctx, "default", "anything")
+	assert.True(t, apierrors.IsServiceUnavailable(err))
+	_, err = s.Watch(ctx, "", storage.ListOptions{})
+	assert.True(t, apierrors.IsServiceUnavailable(err))
+
+	// Destroying again is a no-op
+	s.Destroy()
+}
+
+func TestDestroyClosesAbandonedWatches(t *testing.T) {
+	s := newStrategy(t, WithWatchBuffer(-1), WithDrainTimeout(time.Minute))
+
+	// The consumer never reads and never cancels the context, so the watch blocks sending the first object
+	w, err := s.Watch(context.Background(), "", storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, s.lifecycle.watches.watches, 1)
+
+	start := time.Now()
+	s.Destroy()
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	for event := range w {
+		assert.NotEqual(t, watch.Error, event.Type)
+	}
+	assert.Empty(t, s.lifecycle.watches.watches)
+}
+
+func TestWatchTooSlow(t *testing.T) {
+	s := newStrategy(t)
+	s.watchBuffer = 2
+
+	for i := 0; i < 5; i++ {
+		_, err := s.Create(ctx, &TestKind{
+			ObjectMeta: metav1.ObjectMeta{Name: "slow" + strconv.Itoa(i), Namespace: "default", UID: "uid"},
+		})
+		require.NoError(t, err)
+	}
+
+	w, err := s.Watch(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+
+	// Don't read until the buffer is full
+	require.Eventually(t, func() bool {
+		return len(w) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var events []watch.Event
+	for event := range w {
+		events = append(events, event)
+	}
+	require.Len(t, events, 3)
+	assert.Equal(t, watch.Added, events[0].Type)
+	assert.Equal(t, watch.Added, events[1].Type)
+	assert.Equal(t, watch.Error, events[2].Type)
+	assert.Equal(t, int32(410), events[2].Object.(*metav1.Status).Code)
+}
+
+func TestMaxWatchDuration(t *testing.T) {
+	s := newStrategy(t)
+	s.maxWatchDuration = 100 * time.Millisecond
+	s.watchPollInterval = 10 * time.Millisecond
+
+	w, err := s.Watch(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+
+	start := time.Now()
+	for event := range w {
+		assert.NotEqual(t, watch.Error, event.Type)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestStorageAdapter(t *testing.T) {
+	s := newStrategy(t)
+	store := strategy.NewStorage(s, "testkinds")
+
+	var out TestKind
+	err := store.Create(ctx, "/testkinds/testnamespace4/testname4", &TestKind{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testname4",
+			Namespace: "testnamespace4",
+			UID:       "testuid4",
+		},
+		Value: "testvalue4",
+	}, &out, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "4", out.ResourceVersion)
+
+	err = store.Create(ctx, "/testkinds/testnamespace4/testname4", &TestKind{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testname4",
+			Namespace: "testnamespace4",
+			UID:       "testuid4",
+		},
+	}, &out, 0)
+	assert.True(t, storage.IsExist(err))
+
+	var got TestKind
+	require.NoError(t, store.Get(ctx, "/testkinds/testnamespace1/testname1", storage.GetOptions{}, &got))
+	assert.Equal(t, "testvalue1", got.Value)
+
+	err = store.Get(ctx, "/testkinds/testnamespace1/missing", storage.GetOptions{}, &got)
+	assert.True(t, storage.IsNotFound(err))
+	require.NoError(t, store.Get(ctx, "/testkinds/testnamespace1/missing", storage.GetOptions{IgnoreNotFound: true}, &got))
+	assert.Empty(t, got.Name)
+
+	var list TestKindList
+	require.NoError(t, store.GetList(ctx, "/testkinds", storage.ListOptions{Recursive: true}, &list))
+	assert.Len(t, list.Items, 4)
+	assert.Equal(t, "4", list.ResourceVersion)
+
+	require.NoError(t, store.GetList(ctx, "/testkinds/testnamespace2", storage.ListOptions{Recursive: true}, &list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "testname2", list.Items[0].Name)
+
+	count, err := store.Count("/testkinds")
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, count)
+
+	w, err := store.Watch(ctx, "/testkinds/testnamespace1/testname1", storage.ListOptions{ResourceVersion: "4"})
+	require.NoError(t, err)
+	defer w.Stop()
+
+	// The first attempt is made against a stale object to exercise the retry on conflict
+	attempts := 0
+	var updated TestKind
+	err = store.GuaranteedUpdate(ctx, "/testkinds/testnamespace1/testname1", &updated, false, nil,
+		func(input runtime.Object, res storage.ResponseMeta) (runtime.Object, *uint64, error) {
+			attempts++
+			obj := input.(*TestKind)
+			if attempts == 1 {
+				other := obj.DeepCopyObject().(*TestKind)
+				other.Labels = map[string]string{"concurrent": "true"}
+				_, err := s.Update(ctx, other)
+				require.NoError(t, err)
+			}
+			obj.Value = "updated"
+			return obj, nil, nil
+		}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, "updated", updated.Value)
+	assert.Equal(t, "6", updated.ResourceVersion)
+
+	var deleted TestKind
+	err = store.Delete(ctx, "/testkinds/testnamespace1/testname1", &deleted,
+		storage.NewUIDPreconditions("wrong"), storage.ValidateAllObjectFunc, nil)
+	assert.True(t, storage.IsInvalidObj(err))
+	require.NoError(t, store.Delete(ctx, "/testkinds/testnamespace1/testname1", &deleted,
+		storage.NewUIDPreconditions("testuid1"), storage.ValidateAllObjectFunc, nil))
+	assert.True(t, storage.IsNotFound(store.Get(ctx, "/testkinds/testnamespace1/testname1", storage.GetOptions{}, &got)))
+
+	var events []watch.EventType
+	for len(events) < 3 {
+		select {
+		case event := <-w.ResultChan():
+			events = append(events, event.Type)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for watch events")
+		}
+	}
+	assert.Equal(t, []watch.EventType{watch.Modified, watch.Modified, watch.Deleted}, events)
+}
+
+func TestInformer(t *testing.T) {
+	s := newStrategy(t)
+
+	inf := informer.NewSharedIndexInformer(s, "", 0, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	}, nil)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go inf.Run(ctx.Done())
+	require.True(t, cache.WaitForCacheSync(ctx.Done(), inf.HasSynced))
+	assert.Len(t, inf.GetStore().List(), 3)
+
+	_, err := s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testname4",
+			Namespace: "testnamespace1",
+			UID:       "testuid4",
+		},
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		objs, err := inf.GetIndexer().ByIndex(cache.NamespaceIndex, "testnamespace1")
+		return err == nil && len(objs) == 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// Only the namespace is listed and watched
+	nsInformer := informer.NewSharedIndexInformer(s, "testnamespace2", 0, cache.Indexers{}, nil)
+	go nsInformer.Run(ctx.Done())
+	require.True(t, cache.WaitForCacheSync(ctx.Done(), nsInformer.HasSynced))
+	assert.Len(t, nsInformer.GetStore().List(), 1)
+}
+
+func TestStandaloneHandler(t *testing.T) {
+	s := newStrategy(t)
+
+	handler := standalone.NewHandler(s.scheme)
+	require.NoError(t, handler.Add("testkinds", s))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	base := server.URL + "/apis/testgroup/testversion"
+	do := func(method, path, contentType, body string, out any) int {
+		t.Helper()
+		req, err := http.NewRequest(method, base+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if out != nil {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+		}
+		return resp.StatusCode
+	}
+
+	var list TestKindList
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/testkinds", "", "", &list))
+	assert.Len(t, list.Items, 3)
+	assert.Equal(t, "TestKindList", list.Kind)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/namespaces/testnamespace1/testkinds?labelSelector=test%3D1", "", "", &list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "testname1", list.Items[0].Name)
+
+	resp, err := http.Get(base + "/namespaces/testnamespace4/testkinds?watch=true")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var created TestKind
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/namespaces/testnamespace4/testkinds", "",
+		`{"metadata":{"name":"testname4"},"value":"testvalue4"}`, &created))
+	assert.Equal(t, "testnamespace4", created.Namespace)
+	assert.NotEmpty(t, created.UID)
+	assert.Equal(t, "testgroup/testversion", created.APIVersion)
+
+	var patched TestKind
+	assert.Equal(t, http.StatusOK, do(http.MethodPatch, "/namespaces/testnamespace4/testkinds/testname4",
+		string(types.MergePatchType), `{"value":"patched"}`, &patched))
+	assert.Equal(t, "patched", patched.Value)
+
+	var status metav1.Status
+	assert.Equal(t, http.StatusConflict, do(http.MethodPut, "/namespaces/testnamespace4/testkinds/testname4", "",
+		`{"metadata":{"name":"testname4","resourceVersion":"1"},"value":"stale"}`, &status))
+	assert.Equal(t, metav1.StatusReasonConflict, status.Reason)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/namespaces/testnamespace4/testkinds/testname4", "", "", &status))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/namespaces/testnamespace4/testkinds/testname4", "", "", &status))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/testmissing", "", "", &status))
+
+	decoder := json.NewDecoder(resp.Body)
+	var events []string
+	for len(events) < 3 {
+		var event metav1.WatchEvent
+		require.NoError(t, decoder.Decode(&event))
+		events = append(events, event.Type)
+	}
+	assert.Equal(t, []string{"ADDED", "MODIFIED", "DELETED"}, events)
+}
+
+func TestStoreBuilder(t *testing.T) {
+	s := newStrategy(t)
+
+	store := stores.NewBuilder(s.scheme, &TestKind{}).
+		WithCreate(s).
+		WithList(s).
+		WithDestroy(s).
+		Build()
+	_, ok := store.(rest.Creater)
+	assert.True(t, ok)
+	_, ok = store.(rest.Lister)
+	assert.True(t, ok)
+	_, ok = store.(rest.Getter)
+	assert.False(t, ok)
+	_, ok = store.(rest.Watcher)
+	assert.False(t, ok)
+	assert.True(t, store.(rest.Scoper).NamespaceScoped())
+
+	store = stores.NewBuilder(s.scheme, &TestKind{}).
+		WithGet(s).
+		WithDelete(s).
+		WithWatch(s).
+		Build()
+	_, ok = store.(rest.GracefulDeleter)
+	assert.True(t, ok)
+	_, ok = store.(rest.Lister)
+	assert.False(t, ok)
+	_, ok = store.New().(*TestKind)
+	assert.True(t, ok)
+
+	assert.Panics(t, func() {
+		stores.NewBuilder(s.scheme, &TestKind{}).Build()
+	})
+}
+
+func TestView(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newStrategy(t)
+	v := view.NewStrategy(s, nil, func(_ context.Context, obj kinmtypes.Object) (kinmtypes.Object, error) {
+		if obj.GetName() == "testname2" {
+			return nil, nil
+		}
+		obj.(*TestKind).Value = "redacted"
+		obj.SetLabels(nil)
+		return obj, nil
+	})
+
+	obj, err := v.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	assert.Equal(t, "redacted", obj.(*TestKind).Value)
+	assert.Empty(t, obj.GetLabels())
+
+	_, err = v.Get(ctx, "testnamespace2", "testname2")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	list, err := v.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	items := list.(*TestKindList).Items
+	require.Len(t, items, 2)
+	assert.Equal(t, "testname1", items[0].Name)
+	assert.Equal(t, "testname3", items[1].Name)
+	assert.Equal(t, "3", list.GetResourceVersion())
+
+	w, err := v.Watch(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	for _, name := range []string{"testname1", "testname3"} {
+		event := <-w
+		assert.Equal(t, watch.Added, event.Type)
+		assert.Equal(t, name, event.Object.(*TestKind).Name)
+		assert.Equal(t, "redacted", event.Object.(*TestKind).Value)
+	}
+
+	store := view.NewStore(s, nil, func(_ context.Context, obj kinmtypes.Object) (kinmtypes.Object, error) {
+		return obj, nil
+	})
+	_, ok := store.(rest.Getter)
+	assert.True(t, ok)
+	_, ok = store.(rest.Watcher)
+	assert.True(t, ok)
+	_, ok = store.(rest.Creater)
+	assert.False(t, ok)
+	_, ok = store.(rest.Updater)
+	assert.False(t, ok)
+	_, ok = store.(rest.GracefulDeleter)
+	assert.False(t, ok)
+}
+
+func TestMiddleware(t *testing.T) {
+	s := newStrategy(t)
+
+	var calls []string
+	record := func(prefix string) middleware.Middleware {
+		return middleware.Intercept(func(ctx context.Context, op middleware.Operation, next func(ctx context.Context) error) error {
+			calls = append(calls, fmt.Sprintf("%s %s %s/%s", prefix, op.Verb, op.Namespace, op.Name))
+			return next(ctx)
+		})
+	}
+
+	registry := prometheus.NewRegistry()
+	metrics, err := middleware.NewMetrics(registry)
+	require.NoError(t, err)
+
+	authz := authorizer.AuthorizerFunc(func(_ context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+		if a.GetUser().GetName() == "admin" || a.GetVerb() == "get" {
+			return authorizer.DecisionAllow, "", nil
+		}
+		return authorizer.DecisionDeny, "read only", nil
+	})
+
+	wrapped := middleware.Wrap(s, record("outer"), record("inner"), metrics.Middleware(), middleware.Authorization(authz))
+
+	ctx := request.WithUser(ctx, &authuser.DefaultInfo{Name: "reader"})
+	_, err = wrapped.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"outer get testnamespace1/testname1",
+		"inner get testnamespace1/testname1",
+	}, calls)
+
+	_, err = wrapped.List(ctx, "", storage.ListOptions{})
+	assert.True(t, apierrors.IsForbidden(err))
+
+	_, err = wrapped.List(request.WithUser(ctx, &authuser.DefaultInfo{Name: "admin"}), "", storage.ListOptions{})
+	require.NoError(t, err)
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP kinm_storage_requests_total Number of storage operations by group, kind, verb, and result code.
+# TYPE kinm_storage_requests_total counter
+kinm_storage_requests_total{code="Forbidden",group="testgroup",kind="TestKind",verb="list"} 1
+kinm_storage_requests_total{code="OK",group="testgroup",kind="TestKind",verb="get"} 1
+kinm_storage_requests_total{code="OK",group="testgroup",kind="TestKind",verb="list"} 1
+`), "kinm_storage_requests_total"))
+}
+
+func TestFilterMiddleware(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newStrategy(t)
+	wrapped := middleware.Wrap(s, middleware.Filter(func(ctx context.Context, obj kinmtypes.Object) (bool, error) {
+		u, ok := request.UserFrom(ctx)
+		if !ok {
+			return false, apierrors.NewUnauthorized("no user")
+		}
+		return obj.GetNamespace() == u.GetName(), nil
+	}))
+
+	_, err := wrapped.Get(ctx, "testnamespace1", "testname1")
+	assert.True(t, apierrors.IsUnauthorized(err))
+
+	ctx = request.WithUser(ctx, &authuser.DefaultInfo{Name: "testnamespace2"})
+	_, err = wrapped.Get(ctx, "testnamespace1", "testname1")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	obj, err := wrapped.Get(ctx, "testnamespace2", "testname2")
+	require.NoError(t, err)
+	assert.Equal(t, "testvalue2", obj.(*TestKind).Value)
+
+	list, err := wrapped.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.(*TestKindList).Items, 1)
+	assert.Equal(t, "testname2", list.(*TestKindList).Items[0].Name)
+
+	w, err := wrapped.Watch(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	event := <-w
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, "testname2", event.Object.(*TestKind).Name)
+
+	obj.(*TestKind).Value = "updated"
+	_, err = wrapped.Update(ctx, obj)
+	require.NoError(t, err)
+	event = <-w
+	assert.Equal(t, watch.Modified, event.Type)
+	assert.Equal(t, "updated", event.Object.(*TestKind).Value)
+}
+
+func TestFaultsMiddleware(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newStrategy(t)
+
+	obj, err := middleware.Wrap(s, middleware.Faults(middleware.FaultConfig{ConflictRate: 1})).Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	_, err = middleware.Wrap(s, middleware.Faults(middleware.FaultConfig{ConflictRate: 1})).Update(ctx, obj)
+	assert.True(t, apierrors.IsConflict(err))
+
+	timeouts := middleware.Wrap(s, middleware.Faults(middleware.FaultConfig{
+		Verbs:       []middleware.Verb{middleware.VerbGet},
+		TimeoutRate: 1,
+	}))
+	_, err = timeouts.Get(ctx, "testnamespace1", "testname1")
+	assert.True(t, apierrors.IsTimeout(err))
+	_, err = timeouts.List(ctx, "", storage.ListOptions{})
+	assert.NoError(t, err)
+
+	// The same seed fails the same calls
+	failures := func() (result []bool) {
+		wrapped := middleware.Wrap(s, middleware.Faults(middleware.FaultConfig{Seed: 42, TimeoutRate: 0.5}))
+		for range 20 {
+			_, err := wrapped.Get(ctx, "testnamespace1", "testname1")
+			result = append(result, err != nil)
+		}
+		return result
+	}
+	first := failures()
+	assert.Equal(t, first, failures())
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+
+	w, err := middleware.Wrap(s, middleware.Faults(middleware.FaultConfig{DropEventRate: 1})).Watch(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	select {
+	case event := <-w:
+		t.Fatalf("unexpected event %v", event.Type)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	// The three objects created by newStrategy use up the burst
+	s := newStrategy(t, WithRateLimit(0.5, 3))
+
+	_, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.True(t, apierrors.IsTooManyRequests(err))
+	seconds, ok := apierrors.SuggestsClientDelay(err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, seconds)
+
+	_, err = s.Watch(ctx, "", storage.ListOptions{})
+	assert.True(t, apierrors.IsTooManyRequests(err))
+}
+
+func TestMaxWatches(t *testing.T) {
+	s := newStrategy(t, WithMaxWatches(1))
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	w, err := s.Watch(watchCtx, "", storage.ListOptions{})
+	require.NoError(t, err)
+
+	_, err = s.Watch(ctx, "", storage.ListOptions{})
+	assert.True(t, apierrors.IsTooManyRequests(err))
+
+	// Other operations are not limited by watches
+	_, err = s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+
+	cancel()
+	for range w {
+	}
+
+	// The slot is released once the watch has finished
+	require.Eventually(t, func() bool {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		_, err := s.Watch(ctx, "", storage.ListOptions{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyLimits{
+		ReadOnly: ConcurrencyLimit{Concurrency: 1, QueueLength: 1, QueueTimeout: 50 * time.Millisecond},
+		Watch:    ConcurrencyLimit{Concurrency: 1},
+	})
+	s := newStrategy(t, WithConcurrencyLimiter(limiter))
+
+	// Hold the only read and watch slots
+	require.NoError(t, limiter.acquire(ctx, readOnlyRequest, "test"))
+	require.NoError(t, limiter.acquire(ctx, watchRequest, "test"))
+
+	// Reads queue until they time out, writes are not affected
+	_, err := s.Get(ctx, "testnamespace1", "testname1")
+	assert.True(t, apierrors.IsTooManyRequests(err), err)
+	created, err := s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "write", Namespace: "testnamespace1", UID: "writeuid"},
+	})
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, created)
+	require.NoError(t, err)
+	_, err = s.Undelete(ctx, "testnamespace1", "write", "")
+	require.NoError(t, err)
+
+	// Watches don't queue
+	_, err = s.Watch(ctx, "", storage.ListOptions{})
+	assert.True(t, apierrors.IsTooManyRequests(err), err)
+	limiter.release(watchRequest)
+
+	// A queued read runs once the slot is released
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		limiter.release(readOnlyRequest)
+	}()
+	_, err = s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	_, err = s.Watch(watchCtx, "", storage.ListOptions{})
+	require.NoError(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	s := newStrategy(t)
+
+	problems, err := s.Verify(ctx, VerifyOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+
+	exec := func(query string, args ...any) {
+		t.Helper()
+		_, err := s.db.sqlDB.ExecContext(ctx, query, args...)
+		require.NoError(t, err)
+	}
+
+	// A deleted object still marked as created can't be created again
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+	exec("UPDATE strategytest SET created = 1 WHERE id = 1")
+
+	// A revision left behind by a crashed update
+	for range 2 {
+		obj, err := s.Get(ctx, "testnamespace2", "testname2")
+		require.NoError(t, err)
+		obj.(*TestKind).Value = "updated" + obj.GetResourceVersion()
+		_, err = s.Update(ctx, obj)
+		require.NoError(t, err)
+	}
+	exec("UPDATE strategytest SET previous_id = NULL WHERE id = 5")
+	exec("UPDATE strategytest SET previous_id = 2 WHERE id = 6")
+
+	insert := func(id int, namespace, name string, previousID any, created any, deleted int) {
+		exec("INSERT INTO strategytest (id, name, namespace, previous_id, uid, created, deleted, value) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			id, name, namespace, previousID, "rawuid", created, deleted, "{}")
+	}
+	insert(99, "other", "other", nil, 1, 0)
+	insert(100, "testnamespace3", "testname3", 99, nil, 0)
+	insert(101, "gone", "gone", nil, nil, 1)
+	exec(s.db.stmt.SetCompactionSQL(), 1000)
+
+	kinds := func(problems []Problem) (result []string) {
+		for _, p := range problems {
+			result = append(result, fmt.Sprintf("%s %s %t", p.Kind, p.ResourceVersion, p.Repaired))
+		}
+		return result
+	}
+
+	problems, err = s.Verify(ctx, VerifyOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"BrokenChain 100 false",
+		"StaleHead 5 false",
+		"StaleCreated 1 false",
+		"OrphanedTombstone 101 false",
+		"CompactionDrift  false",
+	}, kinds(problems))
+
+	problems, err = s.Verify(ctx, VerifyOptions{Repair: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"BrokenChain 100 false",
+		"StaleHead 5 true",
+		"StaleCreated 1 true",
+		"OrphanedTombstone 101 true",
+		"CompactionDrift  true",
+	}, kinds(problems))
+
+	problems, err = s.Verify(ctx, VerifyOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"BrokenChain 100 false"}, kinds(problems))
+
+	_, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "testname1", Namespace: "testnamespace1", UID: "testuid1b"},
+	})
+	assert.NoError(t, err)
+}
+
+// TestUIDMismatch runs against the backend selected by KINM_TEST_DB, so every backend returns the same error.
+func TestUIDMismatch(t *testing.T) {
+	s := newStrategy(t)
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	obj.SetUID("recreated")
+
+	_, err = s.Update(ctx, obj)
+	assert.True(t, apierrors.IsConflict(err), err)
+	assert.True(t, errors.IsUIDMismatch(err), err)
+
+	_, err = s.Delete(ctx, obj)
+	assert.True(t, errors.IsUIDMismatch(err), err)
+
+	// Other conflicts are not UID mismatches
+	obj.SetUID("testuid1")
+	obj.SetResourceVersion("100")
+	_, err = s.Update(ctx, obj)
+	assert.True(t, apierrors.IsConflict(err), err)
+	assert.False(t, errors.IsUIDMismatch(err))
+}
+
+func TestChecksum(t *testing.T) {
+	s := newStrategy(t, WithChecksumVerification())
+
+	problems, err := s.Scrub(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+
+	_, err = s.db.sqlDB.ExecContext(ctx, `UPDATE strategytest SET value = replace(value, 'testvalue1', 'testvalueX') WHERE id = 1`)
+	require.NoError(t, err)
+
+	_, err = s.Get(ctx, "testnamespace1", "testname1")
+	assert.True(t, errors.IsCorruptValue(err), err)
+	_, err = s.Get(ctx, "testnamespace2", "testname2")
+	assert.NoError(t, err)
+
+	problems, err = s.Scrub(ctx)
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Equal(t, ProblemCorruptValue, problems[0].Kind)
+	assert.Equal(t, "1", problems[0].ResourceVersion)
+	assert.Equal(t, "testname1", problems[0].Name)
+}
+
+func TestReadReplicas(t *testing.T) {
+	replicaDB, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "replica.db"))
+	require.NoError(t, err)
+	defer replicaDB.Close()
+	replicaDB.SetMaxOpenConns(1)
+
+	schema := runtime.NewScheme()
+	schema.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+	replica, err := New(ctx, replicaDB, testGVK, schema, "strategytest")
+	require.NoError(t, err)
+	defer replica.Destroy()
+	_, err = replica.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "replicaonly", Namespace: "default", UID: "replicauid"},
+	})
+	require.NoError(t, err)
+
+	s := newStrategy(t)
+	s.db.replicas = &replicaPool{dbs: []*sql.DB{replicaDB}}
+	s.db.written = new(atomic.Int64)
+
+	// Reads go to the replica while it is current
+	_, err = s.Get(ctx, "default", "replicaonly")
+	assert.NoError(t, err)
+
+	// A replica behind the latest write is not used
+	_, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "default", UID: "newuid"},
+	})
+	require.NoError(t, err)
+	_, err = s.Get(ctx, "default", "new")
+	assert.NoError(t, err)
+	_, err = s.Get(ctx, "default", "replicaonly")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestHistory(t *testing.T) {
+	s := newStrategy(t)
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	for _, value := range []string{"second", "third"} {
+		obj.(*TestKind).Value = value
+		obj, err = s.Update(ctx, obj)
+		require.NoError(t, err)
+	}
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+
+	revisions, err := s.History(ctx, "testnamespace1", "testname1", HistoryOptions{})
+	require.NoError(t, err)
+	require.Len(t, revisions, 4)
+	assert.True(t, revisions[0].Deleted)
+	assert.Equal(t, "third", revisions[1].Object.(*TestKind).Value)
+	assert.Equal(t, "second", revisions[2].Object.(*TestKind).Value)
+	assert.Equal(t, "testvalue1", revisions[3].Object.(*TestKind).Value)
+	assert.True(t, revisions[3].Created)
+	assert.False(t, revisions[2].Created)
+	for _, revision := range revisions {
+		assert.False(t, revision.Time.IsZero())
+	}
+
+	page, err := s.History(ctx, "testnamespace1", "testname1", HistoryOptions{
+		Limit:  2,
+		Before: revisions[1].Object.GetResourceVersion(),
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, revisions[2].Object.GetResourceVersion(), page[0].Object.GetResourceVersion())
+	assert.Equal(t, revisions[3].Object.GetResourceVersion(), page[1].Object.GetResourceVersion())
+
+	old, err := s.GetAtRevision(ctx, "testnamespace1", "testname1", revisions[2].Object.GetResourceVersion())
+	require.NoError(t, err)
+	assert.Equal(t, "second", old.(*TestKind).Value)
+
+	// A revision of another object isn't returned
+	other, err := s.Get(ctx, "testnamespace2", "testname2")
+	require.NoError(t, err)
+	_, err = s.GetAtRevision(ctx, "testnamespace1", "testname1", other.GetResourceVersion())
+	assert.True(t, apierrors.IsNotFound(err))
+
+	_, err = s.GetAtRevision(ctx, "testnamespace1", "testname1", "invalid")
+	assert.True(t, apierrors.IsBadRequest(err))
+}
+
+func TestHistoryArchive(t *testing.T) {
+	s := newStrategy(t, WithHistoryLimit(2), WithArchive())
+	// The archive table isn't dropped with the table
+	_, err := s.db.sqlDB.ExecContext(ctx, "DELETE FROM strategytest_archive")
+	require.NoError(t, err)
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	for _, value := range []string{"second", "third", "fourth"} {
+		obj.(*TestKind).Value = value
+		obj, err = s.Update(ctx, obj)
+		require.NoError(t, err)
+	}
+
+	// The creation is kept with the two latest revisions
+	revisions, err := s.History(ctx, "testnamespace1", "testname1", HistoryOptions{})
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+
+	revisions, err = s.History(ctx, "testnamespace1", "testname1", HistoryOptions{IncludeArchived: true})
+	require.NoError(t, err)
+	require.Len(t, revisions, 4)
+	for i, value := range []string{"fourth", "third", "second", "testvalue1"} {
+		assert.Equal(t, value, revisions[i].Object.(*TestKind).Value)
+		assert.Equal(t, i == 2, !revisions[i].Archived.IsZero())
+	}
+	assert.True(t, revisions[3].Created)
+
+	page, err := s.History(ctx, "testnamespace1", "testname1", HistoryOptions{
+		Limit:           1,
+		Before:          revisions[1].Object.GetResourceVersion(),
+		IncludeArchived: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "second", page[0].Object.(*TestKind).Value)
+}
+
+func TestHistoryLimitExpired(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newStrategy(t, WithHistoryLimit(1))
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	for _, value := range []string{"second", "third"} {
+		obj.(*TestKind).Value = value
+		obj, err = s.Update(ctx, obj)
+		require.NoError(t, err)
+	}
+	// The revision 4 was pruned by the write of 5
+	require.Equal(t, "5", obj.GetResourceVersion())
+
+	// Without the pruned revision the list at 4 would return the creation of testname1
+	_, err = s.List(ctx, "", storage.ListOptions{ResourceVersion: "4"})
+	assert.True(t, apierrors.IsResourceExpired(err))
+	_, err = s.GetAtRevision(ctx, "testnamespace1", "testname1", "4")
+	assert.True(t, apierrors.IsResourceExpired(err))
+
+	list, err := s.List(ctx, "", storage.ListOptions{ResourceVersion: "5"})
+	require.NoError(t, err)
+	require.Len(t, list.(*TestKindList).Items, 3)
+	assert.Equal(t, "third", list.(*TestKindList).Items[2].Value)
+
+	// Reads that don't return a pruned revision still work at older resourceVersions, including the pages of a list.
+	// At 3 the revision of testname1 is its creation, which is kept.
+	var values []string
+	opts := storage.ListOptions{ResourceVersion: "3", Predicate: storage.SelectionPredicate{Limit: 1}}
+	for {
+		list, err = s.List(ctx, "", opts)
+		require.NoError(t, err)
+		require.Len(t, list.(*TestKindList).Items, 1)
+		values = append(values, list.(*TestKindList).Items[0].Value)
+		if list.(*TestKindList).Continue == "" {
+			break
+		}
+		opts = storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 1, Continue: list.(*TestKindList).Continue}}
+	}
+	assert.Equal(t, []string{"testvalue1", "testvalue2", "testvalue3"}, values)
+
+	list, err = s.List(ctx, "testnamespace2", storage.ListOptions{ResourceVersion: "4"})
+	require.NoError(t, err)
+	require.Len(t, list.(*TestKindList).Items, 1)
+	assert.Equal(t, "testvalue2", list.(*TestKindList).Items[0].Value)
+
+	_, err = s.GetWithOptions(ctx, "testnamespace2", "testname2", metav1.GetOptions{ResourceVersion: "4"})
+	require.NoError(t, err)
+	_, err = s.GetWithOptions(ctx, "testnamespace1", "testname1", metav1.GetOptions{ResourceVersion: "4"})
+	assert.True(t, apierrors.IsResourceExpired(err))
+
+	// The watch from 3 would miss the revision 4
+	w, err := s.Watch(ctx, "", storage.ListOptions{ResourceVersion: "3"})
+	require.NoError(t, err)
+	event := <-w
+	assert.Equal(t, watch.Error, event.Type)
+	assert.Equal(t, metav1.StatusReasonExpired, event.Object.(*metav1.Status).Reason)
+
+	w, err = s.Watch(ctx, "", storage.ListOptions{ResourceVersion: "4"})
+	require.NoError(t, err)
+	event = <-w
+	assert.Equal(t, watch.Modified, event.Type)
+	assert.Equal(t, "5", event.Object.(kclient.Object).GetResourceVersion())
+
+	// Watches of other namespaces don't miss any revision
+	w, err = s.Watch(ctx, "testnamespace2", storage.ListOptions{ResourceVersion: "1"})
+	require.NoError(t, err)
+	event = <-w
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, "2", event.Object.(kclient.Object).GetResourceVersion())
+}
+
+func TestUndelete(t *testing.T) {
+	s := newStrategy(t)
+
+	_, err := s.Undelete(ctx, "testnamespace1", "testname1", "")
+	assert.True(t, apierrors.IsAlreadyExists(err))
+	_, err = s.Undelete(ctx, "testnamespace1", "missing", "")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	deleted, err := s.Delete(ctx, obj)
+	require.NoError(t, err)
+
+	undeleted, err := s.Undelete(ctx, "testnamespace1", "testname1", "")
+	require.NoError(t, err)
+	assert.Equal(t, "testvalue1", undeleted.(*TestKind).Value)
+	assert.Equal(t, obj.GetUID(), undeleted.GetUID())
+	assert.Nil(t, undeleted.GetDeletionTimestamp())
+	assert.NotEqual(t, deleted.GetResourceVersion(), undeleted.GetResourceVersion())
+
+	got, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	assert.Equal(t, undeleted.GetResourceVersion(), got.GetResourceVersion())
+
+	// The subresource recreates the object with the UID of the body
+	_, err = s.Delete(ctx, got)
+	require.NoError(t, err)
+	result, err := strategy.NewUndelete(s).Create(request.WithNamespace(ctx, "testnamespace1"), "testname1",
+		&TestKind{ObjectMeta: metav1.ObjectMeta{UID: "newuid"}}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, types.UID("newuid"), result.(*TestKind).UID)
+	assert.Equal(t, "testvalue1", result.(*TestKind).Value)
+}
+
+func TestDeleteWithFinalizers(t *testing.T) {
+	s := newStrategy(t)
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	obj.SetFinalizers([]string{"test"})
+	obj, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w, err := s.Watch(watchCtx, "testnamespace1", storage.ListOptions{ResourceVersion: obj.GetResourceVersion()})
+	require.NoError(t, err)
+	next := func() watch.Event {
+		t.Helper()
+		select {
+		case event := <-w:
+			return event
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for watch event")
+			return watch.Event{}
+		}
+	}
+
+	// Deleting an object with finalizers only marks it as deleted
+	deleted, err := s.Delete(ctx, obj)
+	require.NoError(t, err)
+	assert.NotNil(t, deleted.GetDeletionTimestamp())
+	event := next()
+	assert.Equal(t, watch.Modified, event.Type)
+	assert.NotNil(t, event.Object.(*TestKind).DeletionTimestamp)
+
+	got, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test"}, got.GetFinalizers())
+	assert.NotNil(t, got.GetDeletionTimestamp())
+
+	// The object is deleted once its last finalizer is removed
+	got.SetFinalizers(nil)
+	_, err = s.Update(ctx, got)
+	require.NoError(t, err)
+	assert.Equal(t, watch.Deleted, next().Type)
+	_, err = s.Get(ctx, "testnamespace1", "testname1")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// And can be created again
+	_, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "testname1", Namespace: "testnamespace1", UID: "testuid1b"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestGracefulDelete(t *testing.T) {
+	s := newStrategy(t)
+	deleter := strategy.NewGracefulDelete(s, 1)
+	ctx := request.WithNamespace(ctx, "testnamespace1")
+
+	obj, deleted, err := deleter.Delete(ctx, "testname1", nil, nil)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	assert.Equal(t, int64(1), *obj.(*TestKind).DeletionGracePeriodSeconds)
+	assert.Contains(t, obj.(*TestKind).Finalizers, strategy.GracefulDeleteFinalizer)
+
+	// The object stays until the grace period has expired
+	require.NoError(t, deleter.Reap(ctx))
+	_, err = s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+
+	// A longer grace period doesn't extend the deadline
+	_, deleted, err = deleter.Delete(ctx, "testname1", nil, &metav1.DeleteOptions{GracePeriodSeconds: ptr(int64(60))})
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	require.Eventually(t, func() bool {
+		require.NoError(t, deleter.Reap(ctx))
+		_, err := s.Get(ctx, "testnamespace1", "testname1")
+		return apierrors.IsNotFound(err)
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// A zero grace period deletes immediately unless other finalizers remain
+	ctx = request.WithNamespace(ctx, "testnamespace2")
+	_, deleted, err = deleter.Delete(ctx, "testname2", nil, &metav1.DeleteOptions{GracePeriodSeconds: ptr(int64(0))})
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	ctx = request.WithNamespace(ctx, "testnamespace3")
+	obj3, err := s.Get(ctx, "testnamespace3", "testname3")
+	require.NoError(t, err)
+	obj3.SetFinalizers([]string{"test"})
+	_, err = s.Update(ctx, obj3)
+	require.NoError(t, err)
+	obj, deleted, err = deleter.Delete(ctx, "testname3", nil, &metav1.DeleteOptions{GracePeriodSeconds: ptr(int64(0))})
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	assert.Equal(t, []string{"test"}, obj.(*TestKind).Finalizers)
+}
+
+func TestColumnTableConvertor(t *testing.T) {
+	s := newStrategy(t)
+
+	convertor, err := strategy.NewColumnTableConvertor(
+		strategy.Column{
+			TableColumnDefinition: metav1.TableColumnDefinition{Name: "Value", Type: "string"},
+			JSONPath:              ".value",
+		},
+		strategy.Column{
+			TableColumnDefinition: metav1.TableColumnDefinition{Name: "Test", Type: "integer"},
+			Func: func(obj runtime.Object) (any, error) {
+				return strconv.Atoi(obj.(*TestKind).Labels["test"])
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	list, err := s.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	table, err := convertor.ConvertToTable(ctx, list, nil)
+	require.NoError(t, err)
+
+	require.Len(t, table.ColumnDefinitions, 4)
+	assert.Equal(t, "Name", table.ColumnDefinitions[0].Name)
+	assert.Equal(t, "Value", table.ColumnDefinitions[1].Name)
+	assert.Equal(t, "Age", table.ColumnDefinitions[3].Name)
+	assert.Equal(t, "3", table.ResourceVersion)
+	require.Len(t, table.Rows, 3)
+	assert.Equal(t, []any{"testname1", "testvalue1", 1}, table.Rows[0].Cells[:3])
+
+	table, err = convertor.ConvertToTable(ctx, list, &metav1.TableOptions{NoHeaders: true})
+	require.NoError(t, err)
+	assert.Empty(t, table.ColumnDefinitions)
+
+	_, err = strategy.NewColumnTableConvertor(strategy.Column{
+		TableColumnDefinition: metav1.TableColumnDefinition{Name: "Invalid"},
+		JSONPath:              ".value[",
+	})
+	assert.Error(t, err)
+}
+
+type categorizedKind struct {
+	TestKind
+}
+
+func (*categorizedKind) ShortNames() []string {
+	return []string{"tk"}
+}
+
+func (*categorizedKind) Categories() []string {
+	return []string{"all"}
+}
+
+func TestShortNamesAndCategories(t *testing.T) {
+	s := newStrategy(t)
+
+	store := stores.NewComplete(s.scheme, s)
+	assert.Empty(t, store.(rest.ShortNamesProvider).ShortNames())
+	assert.Empty(t, store.(rest.CategoriesProvider).Categories())
+
+	adapter := strategy.NewSingularNameAdapter(&categorizedKind{}, s.scheme)
+	assert.Equal(t, []string{"tk"}, adapter.ShortNames())
+	assert.Equal(t, []string{"all"}, adapter.Categories())
+}
+
+type StatusKind struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              StatusKindSpec   `json:"spec,omitempty"`
+	Status            StatusKindStatus `json:"status,omitempty"`
+}
+
+type StatusKindSpec struct {
+	Value string `json:"value,omitempty"`
+}
+
+type StatusKindStatus struct {
+	Value string `json:"value,omitempty"`
+}
+
+func (s *StatusKind) DeepCopyObject() runtime.Object {
+	return &StatusKind{
+		TypeMeta:   s.TypeMeta,
+		ObjectMeta: *s.ObjectMeta.DeepCopy(),
+		Spec:       s.Spec,
+		Status:     s.Status,
+	}
+}
+
+type StatusKindList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []StatusKind `json:"items"`
+}
+
+func (s *StatusKindList) DeepCopyObject() runtime.Object {
+	return &StatusKindList{}
+}
+
+func TestResetFields(t *testing.T) {
+	gvk := testGVK.GroupVersion().WithKind("StatusKind")
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &StatusKind{}, &StatusKindList{})
+
+	db := newDatabase(t)
+	dropTable(t, db.sqlDB, "statuskindtest")
+	s, err := New(ctx, db.sqlDB, gvk, scheme, "statuskindtest")
+	require.NoError(t, err)
+
+	_, err = s.Create(ctx, &StatusKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "testuid"},
+		Spec:       StatusKindSpec{Value: "old"},
+		Status:     StatusKindStatus{Value: "old"},
+	})
+	require.NoError(t, err)
+	ctx := request.WithNamespace(ctx, "default")
+
+	update := func(u interface {
+		Update(context.Context, string, rest.UpdatedObjectInfo, rest.ValidateObjectFunc, rest.ValidateObjectUpdateFunc, bool, *metav1.UpdateOptions) (runtime.Object, bool, error)
+	}, spec, status string) *StatusKind {
+		t.Helper()
+		obj, err := s.Get(ctx, "default", "test")
+		require.NoError(t, err)
+		obj.(*StatusKind).Spec.Value = spec
+		obj.(*StatusKind).Status.Value = status
+		result, _, err := u.Update(ctx, "test", rest.DefaultUpdatedObjectInfo(obj), nil, nil, false, &metav1.UpdateOptions{})
+		require.NoError(t, err)
+		return result.(*StatusKind)
+	}
+
+	// The main resource can't change the status
+	result := update(strategy.NewUpdate(scheme, s), "new", "new")
+	assert.Equal(t, "new", result.Spec.Value)
+	assert.Equal(t, "old", result.Status.Value)
+
+	// The status subresource can only change the status
+	statusStore := strategy.NewStatus(scheme, s)
+	result = update(statusStore, "newer", "new")
+	assert.Equal(t, "new", result.Spec.Value)
+	assert.Equal(t, "new", result.Status.Value)
+
+	resetFields := strategy.NewUpdate(scheme, s).GetResetFields()
+	require.Contains(t, resetFields, fieldpath.APIVersion("testgroup/testversion"))
+	assert.True(t, resetFields["testgroup/testversion"].Has(fieldpath.MakePathOrDie("status")))
+	assert.True(t, statusStore.GetResetFields()["testgroup/testversion"].Has(fieldpath.MakePathOrDie("spec")))
+
+	// Objects without a status have nothing to reset
+	testStrategy := newStrategy(t)
+	assert.Nil(t, strategy.NewUpdate(testStrategy.scheme, testStrategy).GetResetFields())
+}
+
+func TestStatusIsolation(t *testing.T) {
+	gvk := testGVK.GroupVersion().WithKind("StatusKind")
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &StatusKind{}, &StatusKindList{})
+
+	db := newDatabase(t)
+	dropTable(t, db.sqlDB, "statuskindtest")
+	s, err := New(ctx, db.sqlDB, gvk, scheme, "statuskindtest", WithStatusIsolation())
+	require.NoError(t, err)
+
+	created, err := s.Create(ctx, &StatusKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "testuid"},
+		Spec:       StatusKindSpec{Value: "old"},
+		Status:     StatusKindStatus{Value: "old"},
+	})
+	require.NoError(t, err)
+
+	// A controller writes the status
+	obj := created.DeepCopyObject().(*StatusKind)
+	obj.Status.Value = "controller"
+	obj.Spec.Value = "ignored"
+	status, err := s.UpdateStatus(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, "old", status.(*StatusKind).Spec.Value)
+	assert.Equal(t, "controller", status.(*StatusKind).Status.Value)
+
+	// A client updating the spec can't overwrite the status
+	obj = status.DeepCopyObject().(*StatusKind)
+	obj.Spec.Value = "new"
+	obj.Status.Value = "stale"
+	updated, err := s.Update(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, "new", updated.(*StatusKind).Spec.Value)
+	assert.Equal(t, "controller", updated.(*StatusKind).Status.Value)
+
+	got, err := s.Get(ctx, "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "new", got.(*StatusKind).Spec.Value)
+	assert.Equal(t, "controller", got.(*StatusKind).Status.Value)
+
+	// Stale resource versions still conflict
+	_, err = s.Update(ctx, created)
+	assert.True(t, apierrors.IsConflict(err))
+}
+
+func TestGenerationOnSpecChange(t *testing.T) {
+	gvk := testGVK.GroupVersion().WithKind("StatusKind")
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &StatusKind{}, &StatusKindList{})
+
+	db := newDatabase(t)
+	dropTable(t, db.sqlDB, "statuskindtest")
+	s, err := New(ctx, db.sqlDB, gvk, scheme, "statuskindtest")
+	require.NoError(t, err)
+
+	created, err := s.Create(ctx, &StatusKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "testuid"},
+		Spec:       StatusKindSpec{Value: "old"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.GetGeneration())
+
+	// Metadata changes keep the generation
+	obj := created.DeepCopyObject().(*StatusKind)
+	obj.Labels = map[string]string{"foo": "bar"}
+	updated, err := s.Update(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated.GetGeneration())
+
+	// So do status changes made through Update
+	obj = updated.DeepCopyObject().(*StatusKind)
+	obj.Status.Value = "status"
+	updated, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated.GetGeneration())
+
+	obj = updated.DeepCopyObject().(*StatusKind)
+	obj.Spec.Value = "new"
+	updated, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.GetGeneration())
+
+	// The generation is taken from the stored object, not the request
+	obj = updated.DeepCopyObject().(*StatusKind)
+	obj.Generation = 10
+	updated, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.GetGeneration())
+}
+
+func TestNoOpUpdate(t *testing.T) {
+	s := newStrategy(t)
+
+	created, err := s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "noop",
+			Namespace: "default",
+			UID:       "uid",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply},
+				{Manager: "controller", Operation: metav1.ManagedFieldsOperationUpdate},
+			},
+		},
+		Value: "value",
+	})
+	require.NoError(t, err)
+
+	// Resyncing the same object doesn't write a new revision
+	updated, err := s.Update(ctx, created.DeepCopyObject().(*TestKind))
+	require.NoError(t, err)
+	assert.Equal(t, created.GetResourceVersion(), updated.GetResourceVersion())
+
+	// Neither does reordering the managed fields
+	obj := updated.DeepCopyObject().(*TestKind)
+	slices.Reverse(obj.ManagedFields)
+	updated, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, created.GetResourceVersion(), updated.GetResourceVersion())
+
+	obj = updated.DeepCopyObject().(*TestKind)
+	obj.Value = "changed"
+	updated, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	assert.NotEqual(t, created.GetResourceVersion(), updated.GetResourceVersion())
+}
+
+type IndexedKind struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Node              string `json:"node,omitempty"`
+	Replicas          int    `json:"replicas,omitempty"`
+}
+
+func (t *IndexedKind) DeepCopyObject() runtime.Object {
+	return &IndexedKind{
+		TypeMeta:   t.TypeMeta,
+		ObjectMeta: *t.ObjectMeta.DeepCopy(),
+		Node:       t.Node,
+		Replicas:   t.Replicas,
+	}
+}
+
+func (t *IndexedKind) IndexFields() []IndexSpec {
+	return []IndexSpec{{Field: "node"}, {Field: "replicas", Type: IndexInteger}}
+}
+
+type IndexedKindList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IndexedKind `json:"items"`
+}
+
+func (t *IndexedKindList) DeepCopyObject() runtime.Object {
+	return &IndexedKindList{}
+}
+
+func TestIndexFields(t *testing.T) {
+	gvk := testGVK.GroupVersion().WithKind("IndexedKind")
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &IndexedKind{}, &IndexedKindList{})
+
+	db := newDatabase(t)
+	dropTable(t, db.sqlDB, "indexedtest")
+	dropTable(t, db.sqlDB, "indexedtest_index")
+	s, err := New(ctx, db.sqlDB, gvk, scheme, "indexedtest")
+	require.NoError(t, err)
+
+	for i, node := range []string{"a", "b", "a", ""} {
+		_, err := s.Create(ctx, &IndexedKind{
+			ObjectMeta: metav1.ObjectMeta{Name: "obj" + strconv.Itoa(i), Namespace: "default", UID: types.UID("uid" + strconv.Itoa(i))},
+			Node:       node,
+			Replicas:   i,
+		})
+		require.NoError(t, err)
+	}
+
+	var matched int
+	list := func(selector string, rev string) []string {
+		t.Helper()
+		matched = 0
+		result, err := s.List(ctx, "default", storage.ListOptions{
+			ResourceVersion: rev,
+			Predicate: storage.SelectionPredicate{
+				Label: labels.Everything(),
+				Field: fields.ParseSelectorOrDie(selector),
+				GetAttrs: func(obj runtime.Object) (labels.Set, fields.Set, error) {
+					matched++
+					o := obj.(*IndexedKind)
+					return labels.Set(o.Labels), fields.Set{
+						"metadata.name": o.Name,
+						"node":          o.Node,
+						"replicas":      strconv.Itoa(o.Replicas),
+					}, nil
+				},
+			},
+		})
+		require.NoError(t, err)
+		var names []string
+		for _, item := range result.(*IndexedKindList).Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	// Only the objects that match the indexed fields are read
+	assert.Equal(t, []string{"obj0", "obj2"}, list("node=a", ""))
+	assert.Equal(t, 2, matched)
+	assert.Equal(t, []string{"obj2"}, list("node=a,replicas=2", ""))
+	assert.Equal(t, 1, matched)
+	assert.Equal(t, []string{"obj3"}, list("node=", ""))
+	assert.Equal(t, 1, matched)
+	// Other requirements are matched after reading the objects
+	assert.Equal(t, []string{"obj1", "obj3"}, list("node!=a", ""))
+	assert.Equal(t, 4, matched)
+
+	// Lists at older revisions see the values of that revision
+	last, err := s.Get(ctx, "default", "obj3")
+	require.NoError(t, err)
+	rev := last.GetResourceVersion()
+	obj, err := s.Get(ctx, "default", "obj0")
+	require.NoError(t, err)
+	obj.(*IndexedKind).Node = "b"
+	_, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"obj2"}, list("node=a", ""))
+	assert.Equal(t, []string{"obj0", "obj2"}, list("node=a", rev))
+
+	// Revisions written before the index existed are indexed when the strategy is created
+	_, err = db.sqlDB.Exec(`DELETE FROM indexedtest_index WHERE field = 'node'`)
+	require.NoError(t, err)
+	s, err = New(ctx, db.sqlDB, gvk, scheme, "indexedtest")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"obj2"}, list("node=a", ""))
+}
+
+func TestSearch(t *testing.T) {
+	s := newStrategy(t, WithSearch("value"))
+
+	for i, value := range []string{"the quick brown fox", "a lazy brown dog", "quick \"thinking\" OR NOT"} {
+		_, err := s.Create(ctx, &TestKind{
+			ObjectMeta: metav1.ObjectMeta{Name: "search" + strconv.Itoa(i), Namespace: "default", UID: types.UID("searchuid" + strconv.Itoa(i))},
+			Value:      value,
+		})
+		require.NoError(t, err)
+	}
+
+	search := func(namespace, query string) []string {
+		t.Helper()
+		result, err := s.Search(ctx, namespace, query, storage.ListOptions{})
+		require.NoError(t, err)
+		var names []string
+		for _, item := range result.(*TestKindList).Items {
+			names = append(names, item.Name)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	assert.Equal(t, []string{"search0", "search1"}, search("", "brown"))
+	assert.Equal(t, []string{"search0"}, search("default", "brown quick"))
+	assert.Equal(t, []string{"search2"}, search("", `"thinking" OR`))
+	assert.Empty(t, search("testnamespace1", "brown"))
+
+	// Updates and deletes replace the text of the object
+	obj, err := s.Get(ctx, "default", "search0")
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "slow"
+	_, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	obj, err = s.Get(ctx, "default", "search1")
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+	assert.Empty(t, search("", "brown"))
+	assert.Equal(t, []string{"search0"}, search("", "slow"))
+
+	// The list verb searches with the search field selector
+	result, err := strategy.NewList(s).List(request.WithNamespace(ctx, "default"), &metainternalversion.ListOptions{
+		FieldSelector: fields.ParseSelectorOrDie("search=slow,metadata.name=search0"),
+	})
+	require.NoError(t, err)
+	require.Len(t, result.(*TestKindList).Items, 1)
+
+	_, err = newStrategy(t).Search(ctx, "", "brown", storage.ListOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), err)
+}
+
+func TestAggregate(t *testing.T) {
+	// Large values are compressed, so they are counted by the strategy instead of the database
+	s := newStrategy(t, WithCompression(300))
+
+	for i, value := range []string{"running", "running", "done", strings.Repeat("x", 300)} {
+		_, err := s.Create(ctx, &TestKind{
+			ObjectMeta: metav1.ObjectMeta{Name: "agg" + strconv.Itoa(i), Namespace: "default", UID: types.UID("agguid" + strconv.Itoa(i))},
+			Value:      value,
+		})
+		require.NoError(t, err)
+	}
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+
+	groups, err := s.Aggregate(ctx, "", AggregateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []AggregateGroup{{Values: []string{}, Count: 6}}, groups)
+
+	groups, err = s.Aggregate(ctx, "default", AggregateOptions{GroupBy: []string{"metadata.namespace", "value"}})
+	require.NoError(t, err)
+	assert.Equal(t, []AggregateGroup{
+		{Values: []string{"default", "done"}, Count: 1},
+		{Values: []string{"default", "running"}, Count: 2},
+		{Values: []string{"default", strings.Repeat("x", 300)}, Count: 1},
+	}, groups)
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(s.AggregateCollector("kinm_test_objects", "Objects by value.", AggregateOptions{GroupBy: []string{"value"}})))
+	count, err := testutil.GatherAndCount(registry, "kinm_test_objects")
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+}
+
+func TestAggregateStrategy(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	live := newStrategy(t)
+	dropTable(t, live.db.sqlDB, "archivetest")
+	archive, err := New(ctx, live.db.sqlDB, testGVK, live.scheme, "archivetest")
+	require.NoError(t, err)
+	_, err = archive.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "archived", Namespace: "testnamespace1", UID: "archiveduid"},
+	})
+	require.NoError(t, err)
+
+	s := aggregate.NewStrategy(live, archive)
+	names := func(list kinmtypes.ObjectList) []string {
+		var names []string
+		for _, item := range list.(*TestKindList).Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	list, err := s.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testname1", "testname2", "testname3", "archived"}, names(list))
+
+	// Pages continue with the next member
+	page, err := s.List(ctx, "", storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 3}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testname1", "testname2", "testname3"}, names(page))
+	require.NotEmpty(t, page.GetContinue())
+	page, err = s.List(ctx, "", storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 3, Continue: page.GetContinue()}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"archived"}, names(page))
+	assert.Empty(t, page.GetContinue())
+
+	obj, err := s.Get(ctx, "testnamespace1", "archived")
+	require.NoError(t, err)
+	assert.Equal(t, "archived", obj.GetName())
+
+	// A watch from the resource version of the list only sees later changes, in any member
+	w, err := s.Watch(ctx, "", storage.ListOptions{ResourceVersion: list.GetResourceVersion()})
+	require.NoError(t, err)
+	_, err = archive.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "archived2", Namespace: "testnamespace1", UID: "archiveduid2"},
+	})
+	require.NoError(t, err)
+	event := <-w
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, "archived2", event.Object.(kclient.Object).GetName())
+
+	// The resource version of the event resumes every member
+	list, err = s.List(ctx, "", storage.ListOptions{ResourceVersion: event.Object.(kclient.Object).GetResourceVersion()})
+	require.NoError(t, err)
+	assert.Len(t, list.(*TestKindList).Items, 5)
+
+	_, err = s.List(ctx, "", storage.ListOptions{ResourceVersion: "12"})
+	assert.True(t, apierrors.IsBadRequest(err), err)
+}
+
+// TestKindV2 is TestKind in another version, with Value renamed to Data.
+type TestKindV2 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Data              string `json:"data,omitempty"`
+}
+
+func (t *TestKindV2) DeepCopyObject() runtime.Object {
+	return &TestKindV2{
+		TypeMeta:   t.TypeMeta,
+		ObjectMeta: *t.ObjectMeta.DeepCopy(),
+		Data:       t.Data,
+	}
+}
+
+type TestKindV2List struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TestKindV2 `json:"items"`
+}
+
+func (t *TestKindV2List) DeepCopyObject() runtime.Object {
+	return &TestKindV2List{}
+}
+
+// newVersionedScheme returns a scheme with TestKind and TestKindV2 and the conversions between them.
+func newVersionedScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+	v2 := schema.GroupVersion{Group: testGVK.Group, Version: "v2"}
+	scheme.AddKnownTypeWithName(v2.WithKind("TestKind"), &TestKindV2{})
+	scheme.AddKnownTypeWithName(v2.WithKind("TestKindList"), &TestKindV2List{})
+	require.NoError(t, scheme.AddConversionFunc((*TestKind)(nil), (*TestKindV2)(nil), func(a, b any, _ conversion.Scope) error {
+		b.(*TestKindV2).ObjectMeta = a.(*TestKind).ObjectMeta
+		b.(*TestKindV2).Data = a.(*TestKind).Value
+		return nil
+	}))
+	require.NoError(t, scheme.AddConversionFunc((*TestKindV2)(nil), (*TestKind)(nil), func(a, b any, _ conversion.Scope) error {
+		b.(*TestKind).ObjectMeta = a.(*TestKindV2).ObjectMeta
+		b.(*TestKind).Value = a.(*TestKindV2).Data
+		return nil
+	}))
+	return scheme
+}
+
+func TestHooks(t *testing.T) {
+	var events []string
+	names := func(sqlDB *sql.DB) []string {
+		rows, err := sqlDB.QueryContext(ctx, "SELECT name FROM hooktest ORDER BY name")
+		require.NoError(t, err)
+		defer rows.Close()
+		var result []string
+		for rows.Next() {
+			var name string
+			require.NoError(t, rows.Scan(&name))
+			result = append(result, name)
+		}
+		return result
+	}
+	s := newStrategy(t, WithHooks(Hooks{
+		BeforeCreate: func(ctx context.Context, tx *sql.Tx, obj kinmtypes.Object) error {
+			if _, err := tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS hooktest (name VARCHAR(255) PRIMARY KEY)"); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO hooktest(name) VALUES ($1)", obj.GetName()); err != nil {
+				return err
+			}
+			if obj.(*TestKind).Value == "invalid" {
+				return apierrors.NewBadRequest("invalid value")
+			}
+			return nil
+		},
+		BeforeUpdate: func(ctx context.Context, tx *sql.Tx, obj kinmtypes.Object) error {
+			assert.Empty(t, obj.GetResourceVersion())
+			if obj.(*TestKind).Value == "invalid" {
+				return apierrors.NewBadRequest("invalid value")
+			}
+			return nil
+		},
+		BeforeDelete: func(ctx context.Context, tx *sql.Tx, obj kinmtypes.Object) error {
+			_, err := tx.ExecContext(ctx, "DELETE FROM hooktest WHERE name = $1", obj.GetName())
+			return err
+		},
+		AfterCreate: func(ctx context.Context, obj kinmtypes.Object) {
+			events = append(events, "created "+obj.GetName())
+		},
+		AfterUpdate: func(ctx context.Context, obj kinmtypes.Object) {
+			events = append(events, "updated "+obj.GetName()+" "+obj.(*TestKind).Value)
+		},
+		AfterDelete: func(ctx context.Context, obj kinmtypes.Object) {
+			events = append(events, "deleted "+obj.GetName())
+		},
+	}))
+	defer dropTable(t, s.db.sqlDB, "hooktest")
+	assert.Equal(t, []string{"testname1", "testname2", "testname3"}, names(s.db.sqlDB))
+	assert.Equal(t, []string{"created testname1", "created testname2", "created testname3"}, events)
+	events = nil
+
+	// The writes of a failed Before hook are rolled back with the object
+	_, err := s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "testnamespace1", UID: "invalid"},
+		Value:      "invalid",
+	})
+	assert.True(t, apierrors.IsBadRequest(err))
+	assert.Equal(t, []string{"testname1", "testname2", "testname3"}, names(s.db.sqlDB))
+	_, err = s.Get(ctx, "testnamespace1", "invalid")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "invalid"
+	_, err = s.Update(ctx, obj)
+	assert.True(t, apierrors.IsBadRequest(err))
+
+	obj.(*TestKind).Value = "updated"
+	updated, err := s.Update(ctx, obj)
+	require.NoError(t, err)
+	// An update that doesn't change the object calls no After hook
+	_, err = s.Update(ctx, updated)
+	require.NoError(t, err)
+
+	obj, err = s.Get(ctx, "testnamespace2", "testname2")
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testname1", "testname3"}, names(s.db.sqlDB))
+	assert.Equal(t, []string{"updated testname1 updated", "deleted testname2"}, events)
+}
+
+func TestStrategyUnstructured(t *testing.T) {
+	typed := newStrategy(t)
+
+	// Without the Go type in the scheme, the objects of the table are unstructured
+	s, err := New(ctx, typed.db.sqlDB, testGVK, runtime.NewScheme(), "strategytest")
+	require.NoError(t, err)
+	assert.IsType(t, &unstructured.Unstructured{}, s.New())
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	u := obj.(*unstructured.Unstructured)
+	assert.Equal(t, testGVK, u.GroupVersionKind())
+	assert.Equal(t, "testvalue1", u.Object["value"])
+
+	list, err := s.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.(*unstructured.UnstructuredList).Items, 3)
+	assert.Equal(t, "testname2", list.(*unstructured.UnstructuredList).Items[1].GetName())
+
+	u.Object["value"] = "updated"
+	_, err = s.Update(ctx, u)
+	require.NoError(t, err)
+	got, err := typed.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	assert.Equal(t, "updated", got.(*TestKind).Value)
+}
+
+func TestVersioned(t *testing.T) {
+	scheme := newVersionedScheme(t)
+	db := newDatabase(t)
+	dropTable(t, db.sqlDB, "versionedtest")
+	stored, err := New(ctx, db.sqlDB, testGVK, scheme, "versionedtest")
+	require.NoError(t, err)
+	s := versioned.NewStrategy(stored, &TestKindV2{})
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w, err := s.Watch(watchCtx, "", storage.ListOptions{})
+	require.NoError(t, err)
+
+	created, err := s.Create(ctx, &TestKindV2{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+		Data:       "first",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "first", created.(*TestKindV2).Data)
+
+	// The object is stored in the storage version, which is recorded with it
+	obj, err := stored.Get(ctx, "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "first", obj.(*TestKind).Value)
+	var version string
+	require.NoError(t, db.sqlDB.QueryRow("SELECT version FROM versionedtest").Scan(&version))
+	assert.Equal(t, testGVK.Version, version)
+
+	created.(*TestKindV2).Data = "second"
+	updated, err := s.Update(ctx, created)
+	require.NoError(t, err)
+	assert.Equal(t, "second", updated.(*TestKindV2).Data)
+
+	list, err := s.List(ctx, "default", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.(*TestKindV2List).Items, 1)
+	assert.Equal(t, "second", list.(*TestKindV2List).Items[0].Data)
+	assert.Equal(t, updated.GetResourceVersion(), list.GetResourceVersion())
+
+	for _, value := range []string{"first", "second"} {
+		event := <-w
+		assert.Equal(t, value, event.Object.(*TestKindV2).Data)
+	}
+}
+
+func TestStorageMigration(t *testing.T) {
+	scheme := newVersionedScheme(t)
+	db := newDatabase(t)
+	dropTable(t, db.sqlDB, "migrationtest")
+	v1, err := New(ctx, db.sqlDB, testGVK, scheme, "migrationtest")
+	require.NoError(t, err)
+	for i := range 3 {
+		_, err := v1.Create(ctx, &TestKind{
+			ObjectMeta: metav1.ObjectMeta{Name: "test" + strconv.Itoa(i), Namespace: "default", UID: types.UID("uid" + strconv.Itoa(i))},
+			Value:      "value" + strconv.Itoa(i),
+		})
+		require.NoError(t, err)
+	}
+	// Rows written before versions were recorded
+	_, err = db.sqlDB.Exec("UPDATE migrationtest SET version = NULL WHERE name = 'test0'")
+	require.NoError(t, err)
+
+	// The Admin can't convert the rows without their types
+	_, err = (&Factory{SQLDB: db.sqlDB}).Admin().MigrateStorage(ctx, "migrationtest", "v2", StorageMigrationOptions{})
+	assert.ErrorContains(t, err, "needs the types")
+
+	v2GVK := schema.GroupVersionKind{Group: testGVK.Group, Version: "v2", Kind: testGVK.Kind}
+	v2, err := New(ctx, db.sqlDB, v2GVK, scheme, "migrationtest", WithCompression(1))
+	require.NoError(t, err)
+
+	var progress []StorageMigrationProgress
+	result, err := v2.MigrateStorage(ctx, StorageMigrationOptions{
+		BatchSize:      2,
+		UnknownVersion: testGVK.Version,
+		Progress: func(p StorageMigrationProgress) {
+			progress = append(progress, p)
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StorageMigrationProgress{Migrated: 3, Total: 3}, result)
+	assert.Equal(t, []StorageMigrationProgress{{Migrated: 2, Total: 3}, {Migrated: 3, Total: 3}}, progress)
+
+	for i := range 3 {
+		obj, err := v2.Get(ctx, "default", "test"+strconv.Itoa(i))
+		require.NoError(t, err)
+		assert.Equal(t, "value"+strconv.Itoa(i), obj.(*TestKindV2).Data)
+	}
+	var compressed int
+	require.NoError(t, db.sqlDB.QueryRow("SELECT count(*) FROM migrationtest WHERE version = 'v2' AND value LIKE 'kinm:zstd:%'").Scan(&compressed))
+	assert.Equal(t, 3, compressed)
+
+	// Migrated rows are not rewritten again
+	result, err = v2.MigrateStorage(ctx, StorageMigrationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, StorageMigrationProgress{}, result)
+}
+
+func TestEnvelopeEncryption(t *testing.T) {
+	key1, err := envelope.NewLocalKey("key1")
+	require.NoError(t, err)
+	kms1, err := envelope.NewLocalKMS(key1)
+	require.NoError(t, err)
+	s := newStrategy(t, WithValueTransformer(envelope.NewTransformer(kms1, envelope.Options{})))
+
+	var encrypted int
+	require.NoError(t, s.db.sqlDB.QueryRow("SELECT count(*) FROM strategytest WHERE value LIKE 'kinm:enc:%'").Scan(&encrypted))
+	assert.Equal(t, 3, encrypted)
+
+	// After adding a new primary key, the values are re-encrypted so that the old key can be removed
+	key2, err := envelope.NewLocalKey("key2")
+	require.NoError(t, err)
+	kms2, err := envelope.NewLocalKMS(key2, key1)
+	require.NoError(t, err)
+	rotated, err := New(ctx, s.db.sqlDB, testGVK, s.scheme, "strategytest", WithValueTransformer(envelope.NewTransformer(kms2, envelope.Options{})))
+	require.NoError(t, err)
+	result, err := rotated.MigrateStorage(ctx, StorageMigrationOptions{Reencode: true, BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, StorageMigrationProgress{Migrated: 3, Total: 3}, result)
+
+	kms3, err := envelope.NewLocalKMS(key2)
+	require.NoError(t, err)
+	s, err = New(ctx, rotated.db.sqlDB, testGVK, rotated.scheme, "strategytest", WithValueTransformer(envelope.NewTransformer(kms3, envelope.Options{})))
+	require.NoError(t, err)
+	for i := range 3 {
+		obj, err := s.Get(ctx, "testnamespace"+strconv.Itoa(i+1), "testname"+strconv.Itoa(i+1))
+		require.NoError(t, err)
+		assert.Equal(t, "testvalue"+strconv.Itoa(i+1), obj.(*TestKind).Value)
+	}
+}
+
+// TestSecretKind embeds a secret in an otherwise public object.
+type TestSecretKind struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Public            string            `json:"public,omitempty"`
+	Token             string            `json:"token,omitempty" kinm:"encrypt,redact"`
+	Credentials       map[string]string `json:"credentials,omitempty"`
+}
+
+func (t *TestSecretKind) DeepCopyObject() runtime.Object {
+	c := *t
+	c.ObjectMeta = *t.ObjectMeta.DeepCopy()
+	c.Credentials = maps.Clone(t.Credentials)
+	return &c
+}
+
+type TestSecretKindList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TestSecretKind `json:"items"`
+}
+
+func (t *TestSecretKindList) DeepCopyObject() runtime.Object {
+	return &TestSecretKindList{TypeMeta: t.TypeMeta, ListMeta: t.ListMeta, Items: slices.Clone(t.Items)}
+}
+
+func TestFieldEncryption(t *testing.T) {
+	gvk := testGVK.GroupVersion().WithKind("TestSecretKind")
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(gvk.GroupVersion(), &TestSecretKind{}, &TestSecretKindList{})
+	db := newDatabase(t)
+	dropTable(t, db.sqlDB, "secrettest")
+
+	// Tagged fields are never stored as plain text
+	_, err := New(ctx, db.sqlDB, gvk, scheme, "secrettest")
+	assert.ErrorContains(t, err, "token")
+
+	key, err := envelope.NewLocalKey("key")
+	require.NoError(t, err)
+	kms, err := envelope.NewLocalKMS(key)
+	require.NoError(t, err)
+	s, err := New(ctx, db.sqlDB, gvk, scheme, "secrettest",
+		WithFieldEncryption(envelope.NewTransformer(kms, envelope.Options{}), "credentials.*"))
+	require.NoError(t, err)
+
+	created, err := s.Create(ctx, &TestSecretKind{
+		ObjectMeta:  metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+		Public:      "visible",
+		Token:       "s3cret-token",
+		Credentials: map[string]string{"password": "s3cret-password"},
+	})
+	require.NoError(t, err)
+
+	var stored string
+	require.NoError(t, db.sqlDB.QueryRow("SELECT value FROM secrettest WHERE name = 'test'").Scan(&stored))
+	assert.Contains(t, stored, `"public":"visible"`)
+	assert.Contains(t, stored, `"token":"kinm:enc:`)
+	assert.Contains(t, stored, `"credentials":{"password":"kinm:enc:`)
+	assert.NotContains(t, stored, "s3cret")
+
+	obj, err := s.Get(ctx, "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret-token", obj.(*TestSecretKind).Token)
+	assert.Equal(t, map[string]string{"password": "s3cret-password"}, obj.(*TestSecretKind).Credentials)
+
+	// Decrypted values are identical to the written ones, so an unchanged object isn't written again
+	updated, err := s.Update(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, created.GetResourceVersion(), updated.GetResourceVersion())
+
+	// Only callers allowed to get the secrets subresource see the tagged fields
+	authz := authorizer.AuthorizerFunc(func(_ context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+		if a.GetUser().GetName() == "admin" && a.GetSubresource() == "secrets" {
+			return authorizer.DecisionAllow, "", nil
+		}
+		return authorizer.DecisionNoOpinion, "", nil
+	})
+	wrapped := middleware.Wrap(s, middleware.Redact(middleware.Authorized(authz, scheme, "get", "secrets")))
+
+	list, err := wrapped.List(request.WithUser(ctx, &authuser.DefaultInfo{Name: "reader"}), "", storage.ListOptions{})
+	require.NoError(t, err)
+	items := list.(*TestSecretKindList).Items
+	require.Len(t, items, 1)
+	assert.Equal(t, "visible", items[0].Public)
+	assert.Empty(t, items[0].Token)
+
+	obj, err = wrapped.Get(request.WithUser(ctx, &authuser.DefaultInfo{Name: "admin"}), "default", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret-token", obj.(*TestSecretKind).Token)
+}
+
+func TestInvariantChecks(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		lock       sync.Mutex
+		violations []string
+	)
+	s := newStrategy(t, WithInvariantChecks(func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		violations = append(violations, err.Error())
+	}))
+	getViolations := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return slices.Clone(violations)
+	}
+
+	// Lists, writes and watches of a working strategy keep the invariants
+	w, err := s.Watch(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	for range 3 {
+		assert.Equal(t, watch.Added, (<-w).Type)
+	}
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "updated"
+	obj, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, watch.Modified, (<-w).Type)
+	assert.Equal(t, watch.Deleted, (<-w).Type)
+	list, err := s.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "5", list.GetResourceVersion())
+	assert.Empty(t, getViolations())
+
+	// A list older than a completed write is reported
+	s.checkWrite(ctx, &TestKind{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "10"}})
+	_, err = s.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, getViolations(), 1)
+	assert.Contains(t, getViolations()[0], "has resource version 5, older than 10")
+
+	// So are events out of order
+	check := s.newWatchCheck("testnamespace1", storage.ListOptions{Predicate: storage.SelectionPredicate{
+		Label: labels.Everything(),
+		Field: fields.Everything(),
+	}}, "5")
+	check.event(record{id: 3, namespace: "testnamespace1", name: "testname1"}, true)
+	check.event(record{id: 7, namespace: "testnamespace1", name: "testname1"}, false)
+	check.event(record{id: 6, namespace: "testnamespace1", name: "testname2"}, false)
+	require.Len(t, getViolations(), 2)
+	assert.Contains(t, getViolations()[1], "event of testnamespace1/testname2 at resource version 6 was sent after the event of testnamespace1/testname1 at 7")
+
+	// Without a handler, violations panic
+	s.invariants.onViolation = nil
+	assert.Panics(t, func() {
+		check.event(record{id: 7}, false)
+	})
+}
+
+func TestQuota(t *testing.T) {
+	s := newStrategy(t,
+		WithQuota(Quota{MaxObjects: 2}),
+		WithNamespaceQuota("testnamespace2", Quota{MaxBytes: 400}),
+		WithNamespaceQuota("testnamespace3", Quota{}))
+
+	newObj := func(namespace, name, value string) *TestKind {
+		return &TestKind{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID(namespace + name)},
+			Value:      value,
+		}
+	}
+
+	// The default quota allows one more object
+	_, err := s.Create(ctx, newObj("testnamespace1", "second", ""))
+	require.NoError(t, err)
+	_, err = s.Create(ctx, newObj("testnamespace1", "third", ""))
+	assert.True(t, apierrors.IsForbidden(err), err)
+	_, err = s.Create(ctx, newObj("othernamespace", "first", ""))
+	require.NoError(t, err)
+
+	usage, err := s.QuotaUsage(ctx, "testnamespace1")
+	require.NoError(t, err)
+	assert.Equal(t, Quota{MaxObjects: 2}, usage.Quota)
+	assert.Equal(t, int64(2), usage.Objects)
+	assert.Positive(t, usage.Bytes)
+
+	// Deleting an object frees its share of the quota
+	obj, err := s.Get(ctx, "testnamespace1", "second")
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+	_, err = s.Create(ctx, newObj("testnamespace1", "third", ""))
+	require.NoError(t, err)
+
+	// Writes that would grow the namespace past its size are forbidden, shrinking it is allowed
+	usage, err = s.QuotaUsage(ctx, "testnamespace2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), usage.Objects)
+	obj, err = s.Get(ctx, "testnamespace2", "testname2")
+	require.NoError(t, err)
+	obj.(*TestKind).Value = strings.Repeat("x", int(400-usage.Bytes)+len("testvalue2")+1)
+	_, err = s.Update(ctx, obj)
+	assert.True(t, apierrors.IsForbidden(err), err)
+	obj.(*TestKind).Value = ""
+	_, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+
+	// A zero namespace quota exempts the namespace from the default one
+	for i := range 3 {
+		_, err = s.Create(ctx, newObj("testnamespace3", "extra"+strconv.Itoa(i), ""))
+		require.NoError(t, err)
+	}
+}
+
+func TestStatementOverrides(t *testing.T) {
+	overrides := fstest.MapFS{
+		// Only the first namespace is listed for this table
+		"strategytest/namespaces.sql": {Data: []byte(`SELECT DISTINCT namespace
+FROM placeholder
+WHERE namespace = 'testnamespace1'
+  AND (partition_id = $1 OR $1 IS NULL)`)},
+		"othertable/namespaces.sql":  {Data: []byte("not sql")},
+		"extensions/01_extra.sql":    {Data: []byte("CREATE TABLE IF NOT EXISTS placeholder_extra (id INTEGER)")},
+		"sqlite/extensions/02.sql":   {Data: []byte("INSERT INTO placeholder_extra (id) VALUES (1)")},
+		"postgres/extensions/02.sql": {Data: []byte("INSERT INTO placeholder_extra (id) VALUES (1)")},
+	}
+	s := newStrategy(t, WithStatementOverrides(overrides))
+
+	result, err := s.Namespaces(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testnamespace1"}, result)
+
+	var count int
+	require.NoError(t, s.db.sqlDB.QueryRowContext(ctx, "SELECT count(*) FROM strategytest_extra").Scan(&count))
+	assert.Positive(t, count)
+
+	// Overriding a statement that doesn't exist fails
+	_, err = New(ctx, s.db.sqlDB, testGVK, s.scheme, "strategytest", WithStatementOverrides(fstest.MapFS{
+		"missing.sql": {Data: []byte("SELECT 1")},
+	}))
+	assert.ErrorContains(t, err, "no such statement")
+}
+
+func TestTablePartitioning(t *testing.T) {
+	sqlDB, dialect := newSQLDB(t)
+	dropTable(t, sqlDB, "partitiontest")
+
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+
+	s, err := New(ctx, sqlDB, testGVK, scheme, "partitiontest", WithTablePartitioning(TablePartitioning{IDRange: 2}))
+	if dialect != statements.Postgres {
+		assert.ErrorContains(t, err, "only supported for Postgres")
+		return
+	}
+	require.NoError(t, err)
+
+	newObj := func(name string) *TestKind {
+		return &TestKind{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)}}
+	}
+
+	// Revisions 1 to 3 are the history of a deleted object, revision 4 is a live object
+	obj, err := s.Create(ctx, newObj("deleted"))
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "updated"
+	obj, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	_, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+	_, err = s.Create(ctx, newObj("live"))
+	require.NoError(t, err)
+
+	// Names are unique across partitions
+	_, err = s.Create(ctx, newObj("live"))
+	assert.True(t, apierrors.IsAlreadyExists(err), err)
+
+	// The first compaction sets the compaction point, the second drops the partitions of revisions 1 and 2-3
+	_, err = s.db.compact(ctx)
+	require.NoError(t, err)
+	deleted, err := s.db.compact(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	var partitions int
+	require.NoError(t, sqlDB.QueryRowContext(ctx, "SELECT count(*) FROM pg_inherits WHERE inhparent = 'partitiontest'::regclass").Scan(&partitions))
+	assert.Equal(t, 2, partitions)
+
+	list, err := s.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.(*TestKindList).Items, 1)
+	assert.Equal(t, "live", list.(*TestKindList).Items[0].Name)
+
+	// Writes continue in new partitions
+	for i := range 5 {
+		_, err = s.Create(ctx, newObj("more"+strconv.Itoa(i)))
+		require.NoError(t, err)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	s := newStrategy(t, WithMaintenance(MaintenanceOptions{AnalyzeThreshold: 1, VacuumThreshold: 1}))
+
+	// The revision of the first update is compacted, the one of the create is kept
+	for i := range 2 {
+		obj, err := s.Get(ctx, "testnamespace1", "testname1")
+		require.NoError(t, err)
+		obj.(*TestKind).Value = "updated" + strconv.Itoa(i)
+		_, err = s.Update(ctx, obj)
+		require.NoError(t, err)
+	}
+
+	// The first compaction sets the compaction point and deletes nothing, so the table isn't maintained yet
+	deleted, err := s.db.compact(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	assert.Zero(t, s.db.maintenance.deletedAnalyzed)
+
+	deleted, err = s.db.compact(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Zero(t, s.db.maintenance.deletedAnalyzed)
+	assert.Zero(t, s.db.maintenance.deletedVacuumed)
+
+	if s.db.stmt.Dialect() == "sqlite" {
+		var stats int
+		require.NoError(t, s.db.sqlDB.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_stat1 WHERE tbl = 'strategytest'").Scan(&stats))
+		assert.Positive(t, stats)
+	}
+}
+
+func TestCompactionMetricsAndEvents(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	database := newDatabase(t)
+	dropTable(t, database.sqlDB, "strategytestevents")
+	events, err := New(ctx, database.sqlDB, corev1.SchemeGroupVersion.WithKind("Event"), scheme, "strategytestevents")
+	require.NoError(t, err)
+	t.Cleanup(events.Destroy)
+
+	registry := prometheus.NewRegistry()
+	metrics, err := NewCompactionMetrics(registry)
+	require.NoError(t, err)
+	s := newStrategy(t, WithCompactionMetrics(metrics), WithCompactionEvents(events, "kube-system"))
+
+	for i := range 2 {
+		obj, err := s.Get(ctx, "testnamespace1", "testname1")
+		require.NoError(t, err)
+		obj.(*TestKind).Value = "updated" + strconv.Itoa(i)
+		_, err = s.Update(ctx, obj)
+		require.NoError(t, err)
+	}
+
+	// The first compaction sets the compaction point, the second removes the first update
+	s.runCompaction(ctx)
+	s.runCompaction(ctx)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.runs.WithLabelValues("strategytest", "success")))
+	assert.Zero(t, testutil.ToFloat64(metrics.runs.WithLabelValues("strategytest", "error")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.rows.WithLabelValues("strategytest")))
+	assert.Equal(t, 5.0, testutil.ToFloat64(metrics.compactionID.WithLabelValues("strategytest")))
+	assert.NotZero(t, testutil.ToFloat64(metrics.lastSuccess.WithLabelValues("strategytest")))
+
+	list, err := events.List(ctx, "kube-system", storage.ListOptions{Predicate: storage.Everything})
+	require.NoError(t, err)
+	items := list.(*corev1.EventList).Items
+	require.Len(t, items, 1)
+	assert.Equal(t, "Compacted", items[0].Reason)
+	assert.Equal(t, corev1.EventTypeNormal, items[0].Type)
+	assert.Equal(t, testGVK.Kind, items[0].InvolvedObject.Kind)
+	assert.Equal(t, "strategytest", items[0].InvolvedObject.Name)
+}
+
+func TestClusterScoped(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGVK.GroupVersion(), &TestKind{}, &TestKindList{})
+	database := newDatabase(t)
+	dropTable(t, database.sqlDB, "strategytestcluster")
+	s, err := New(ctx, database.sqlDB, testGVK, scheme, "strategytestcluster", WithClusterScoped())
+	require.NoError(t, err)
+	t.Cleanup(s.Destroy)
+	assert.False(t, s.NamespaceScoped())
+
+	_, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "namespaced", Namespace: "default", UID: "uid1"},
+	})
+	assert.True(t, apierrors.IsInvalid(err))
+
+	obj, err := s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", UID: "uid2"},
+	})
+	require.NoError(t, err)
+	obj.SetNamespace("default")
+	_, err = s.Update(ctx, obj)
+	assert.True(t, apierrors.IsInvalid(err))
+
+	list, err := s.List(ctx, "", storage.ListOptions{Predicate: storage.SelectionPredicate{
+		Field: fields.OneTermEqualSelector("metadata.name", "cluster"),
+	}})
+	require.NoError(t, err)
+	assert.Len(t, list.(*TestKindList).Items, 1)
+
+	// The scope is kept by the stores and through middleware
+	wrapped := middleware.Wrap(s, middleware.Intercept(func(ctx context.Context, _ middleware.Operation, next func(ctx context.Context) error) error {
+		return next(ctx)
+	}))
+	assert.False(t, stores.NewComplete(scheme, wrapped).(rest.Scoper).NamespaceScoped())
+}
+
+func TestCreationTimestampAndDeletion(t *testing.T) {
+	s := newStrategy(t)
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	assert.False(t, ptr(obj.GetCreationTimestamp()).IsZero(), "Create sets a missing creationTimestamp")
+
+	created := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	obj, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "kept", Namespace: "default", UID: "uid1", CreationTimestamp: created},
+	})
+	require.NoError(t, err)
+	assert.True(t, created.Equal(ptr(obj.GetCreationTimestamp())))
+
+	s.creationTimestampPolicy = CreationTimestampOverwrite
+	obj, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "overwritten", Namespace: "default", UID: "uid2", CreationTimestamp: created},
+	})
+	require.NoError(t, err)
+	assert.True(t, obj.GetCreationTimestamp().After(created.Time))
+
+	s.creationTimestampPolicy = CreationTimestampReject
+	_, err = s.Create(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "rejected", Namespace: "default", UID: "uid3", CreationTimestamp: created},
+	})
+	assert.True(t, apierrors.IsInvalid(err))
+
+	// An object with finalizers stays until they are removed, with a grace period of zero
+	obj, err = s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	obj.SetFinalizers([]string{"test"})
+	obj, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+	obj, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+	assert.NotNil(t, obj.GetDeletionTimestamp())
+	assert.Equal(t, ptr(int64(0)), obj.GetDeletionGracePeriodSeconds())
+
+	// A deletionTimestamp set by the caller gets the grace period left until it
+	obj, err = s.Get(ctx, "testnamespace2", "testname2")
+	require.NoError(t, err)
+	obj.SetFinalizers([]string{"test"})
+	deadline := metav1.NewTime(time.Now().Add(time.Minute))
+	obj.SetDeletionTimestamp(&deadline)
+	obj, err = s.Delete(ctx, obj)
+	require.NoError(t, err)
+	require.NotNil(t, obj.GetDeletionGracePeriodSeconds())
+	assert.InDelta(t, 60, *obj.GetDeletionGracePeriodSeconds(), 1)
+}
+
+func TestCreateOrUpdate(t *testing.T) {
+	s := newStrategy(t)
+
+	obj, created, err := s.CreateOrUpdate(ctx, &TestKind{
+		ObjectMeta: metav1.ObjectMeta{Name: "testname1", Namespace: "testnamespace1", UID: "otheruid", ResourceVersion: "100"},
+		Value:      "replaced",
+	})
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, types.UID("testuid1"), obj.GetUID())
+	assert.Equal(t, "replaced", obj.(*TestKind).Value)
+	assert.Equal(t, "4", obj.GetResourceVersion())
+
+	var (
+		wg   sync.WaitGroup
+		adds atomic.Int32
+	)
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, created, err := s.CreateOrUpdate(ctx, &TestKind{
+				ObjectMeta: metav1.ObjectMeta{Name: "upserted", Namespace: "default", UID: types.UID("uid" + strconv.Itoa(i))},
+				Value:      strconv.Itoa(i),
+			})
+			assert.NoError(t, err)
+			if created {
+				adds.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), adds.Load())
+
+	// Replacing an object with itself doesn't write
+	obj, err = s.Get(ctx, "default", "upserted")
+	require.NoError(t, err)
+	again, created, err := s.CreateOrUpdate(ctx, obj)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, obj.GetResourceVersion(), again.GetResourceVersion())
+}
+
+func TestGetWithOptions(t *testing.T) {
+	s := newStrategy(t)
+
+	obj, err := s.Get(ctx, "testnamespace1", "testname1")
+	require.NoError(t, err)
+	obj.(*TestKind).Value = "updated"
+	_, err = s.Update(ctx, obj)
+	require.NoError(t, err)
+
+	obj, err = s.GetWithOptions(ctx, "testnamespace1", "testname1", metav1.GetOptions{ResourceVersion: "0"})
+	require.NoError(t, err)
+	assert.Equal(t, "updated", obj.(*TestKind).Value)
+
+	obj, err = s.GetWithOptions(ctx, "testnamespace1", "testname1", metav1.GetOptions{ResourceVersion: "3"})
+	require.NoError(t, err)
+	assert.Equal(t, "testvalue1", obj.(*TestKind).Value)
+	assert.Equal(t, "1", obj.GetResourceVersion())
+
+	_, err = s.GetWithOptions(ctx, "testnamespace3", "testname3", metav1.GetOptions{ResourceVersion: "2"})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	_, err = s.GetWithOptions(ctx, "testnamespace1", "testname1", metav1.GetOptions{ResourceVersion: "5"})
+	assert.True(t, storage.IsTooLargeResourceVersion(err), err)
+
+	// The decorators read at the resourceVersion too
+	for _, wrapped := range []strategy.CompleteStrategy{
+		middleware.Wrap(s, middleware.Faults(middleware.FaultConfig{DropEventRate: 1})),
+		middleware.Wrap(s, middleware.Filter(func(context.Context, kinmtypes.Object) (bool, error) { return true, nil })),
+		audit.NewStrategy(s, audit.SinkFunc(func(context.Context, audit.Event) error { return nil }), nil),
+	} {
+		got, err := strategy.NewGet(wrapped).Get(request.WithNamespace(ctx, "testnamespace1"), "testname1",
+			&metav1.GetOptions{ResourceVersion: "3"})
+		require.NoError(t, err)
+		assert.Equal(t, "testvalue1", got.(*TestKind).Value)
+	}
+
+	// Strategies that can't read at a resourceVersion return an error instead of the latest revision
+	_, err = strategy.GetWithOptions(ctx, struct{ strategy.Getter }{s}, "testnamespace1", "testname1",
+		metav1.GetOptions{ResourceVersion: "3"})
+	assert.True(t, apierrors.IsBadRequest(err), err)
+
+	_, err = s.db.execContext(ctx, s.db.stmt.SetCompactionSQL(), 3)
+	require.NoError(t, err)
+	_, err = s.GetWithOptions(ctx, "testnamespace1", "testname1", metav1.GetOptions{ResourceVersion: "2"})
+	assert.True(t, errors.IsCompacted(err))
+
+	obj, modified, err := s.GetIfModified(ctx, "testnamespace1", "testname1", "4")
+	require.NoError(t, err)
+	assert.False(t, modified)
+	assert.Nil(t, obj)
+
+	obj, modified, err = s.GetIfModified(ctx, "testnamespace1", "testname1", "1")
+	require.NoError(t, err)
+	assert.True(t, modified)
+	assert.Equal(t, "4", obj.GetResourceVersion())
+
+	_, _, err = s.GetIfModified(ctx, "testnamespace1", "missing", "1")
+	assert.True(t, apierrors.IsNotFound(err))
+}
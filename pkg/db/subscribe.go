@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// NotificationType is the kind of change of a Notification.
+type NotificationType int8
+
+const (
+	Created NotificationType = iota
+	Updated
+	Deleted
+)
+
+func (t NotificationType) String() string {
+	switch t {
+	case Created:
+		return "Created"
+	case Updated:
+		return "Updated"
+	case Deleted:
+		return "Deleted"
+	}
+	return "Unknown"
+}
+
+// Notification tells a subscriber that an object changed. It only has the identity of the object, which the
+// subscriber gets from the strategy if it needs the object.
+type Notification struct {
+	Type            NotificationType
+	Namespace       string
+	Name            string
+	ResourceVersion int64
+}
+
+// Subscribe calls handler for every change made to the objects of gvk, which must have been created with
+// NewDBStrategy, after Subscribe is called. It is fed by the same change feed as watches, but the values of the objects
+// are not read or decoded, so it suits internal components that only need to know that an object changed.
+//
+// The handler is called from a single goroutine per subscription, in the order of the changes, until ctx is done or the
+// strategy is destroyed. A failed query is logged and retried. Changes that have been compacted before they are
+// polled, such as an update immediately followed by another one, are not notified, but the latest change of an object
+// always is.
+func (f *Factory) Subscribe(ctx context.Context, gvk schema.GroupVersionKind, handler func(Notification)) error {
+	strategies, err := f.strategiesFor([]schema.GroupVersionKind{gvk})
+	if err != nil {
+		return err
+	}
+	s := strategies[0]
+
+	meta, err := s.db.getTableMeta(ctx)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		rev := meta.ListID
+		for {
+			// Get the wait channel before polling so that changes made while polling aren't missed
+			changed := s.waitChange()
+			next, ok := s.notify(ctx, rev, handler)
+			if !ok {
+				return
+			}
+			rev = next
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.ctx.Done():
+				return
+			case <-changed:
+			case <-time.After(defaultWatchPollInterval):
+			}
+		}
+	}()
+	return nil
+}
+
+// notify calls handler for the records after rev and returns the id of the last one. It returns false if the
+// subscription should end.
+func (s *Strategy) notify(ctx context.Context, rev int64, handler func(Notification)) (int64, bool) {
+	if err := s.begin(); err != nil {
+		return rev, false
+	}
+	notifications, err := s.db.notifications(ctx, rev)
+	s.end()
+	if err != nil {
+		if ctx.Err() != nil {
+			return rev, false
+		}
+		klog.Errorf("failed to poll changes of %s: %v", s.db.gvk.Kind, err)
+		return rev, true
+	}
+
+	// The handler is called after the rows are closed, since it may need the connection, and outside of the operation,
+	// so that a slow handler doesn't delay Destroy
+	for _, n := range notifications {
+		handler(n)
+		rev = n.ResourceVersion
+		if ctx.Err() != nil {
+			return rev, false
+		}
+	}
+	return rev, true
+}
+
+// notifications returns the changes of the records after rev.
+func (d *db) notifications(ctx context.Context, rev int64) ([]Notification, error) {
+	ctx, tx, err := d.tenantTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := d.queryContext(ctx, d.stmt.NotificationsSQL(), rev)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Notification
+	for rows.Next() {
+		var (
+			n                Notification
+			created, deleted int16
+		)
+		if err := rows.Scan(&n.ResourceVersion, &n.Namespace, &n.Name, &created, &deleted); err != nil {
+			return nil, err
+		}
+		switch {
+		case created == 1:
+			n.Type = Created
+		case deleted == 1:
+			n.Type = Deleted
+		default:
+			n.Type = Updated
+		}
+		result = append(result, n)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/obot-platform/kinm/pkg/db/errors"
+	"github.com/obot-platform/kinm/pkg/db/statements"
+	"k8s.io/klog/v2"
+)
+
+// TablePartitioning stores the table as a Postgres partitioned table, which suits very large kinds. Exactly one of the
+// fields must be set. Table partitions are unrelated to the partition IDs of objects. Partitioning only applies to
+// tables that don't exist yet; an existing table is not converted.
+type TablePartitioning struct {
+	// IDRange partitions the table by ranges of IDRange resource versions. Partitions are created as the resource
+	// versions grow, and compaction drops the partitions below the compaction point that only hold compacted rows
+	// instead of deleting their rows one by one, which also spares the vacuum of those rows. Objects that are never
+	// updated keep the partition of their last write alive.
+	//
+	// Postgres can't enforce the uniqueness of names and previous revisions across the partitions of a range, so
+	// they are checked by the writes, which already lock the table.
+	IDRange int64
+	// NamespaceHash partitions the table by the hash of the namespace into NamespaceHash partitions, which are all
+	// created with the table. Compaction works as for an unpartitioned table.
+	NamespaceHash int
+}
+
+// WithTablePartitioning stores the table as a Postgres partitioned table. Creating the strategy fails for sqlite.
+func WithTablePartitioning(p TablePartitioning) Option {
+	return func(s *Strategy) {
+		switch {
+		case p.IDRange > 0:
+			s.statementOptions = append(s.statementOptions, statements.WithTablePartitioning(statements.PartitionByIDRange))
+		case p.NamespaceHash > 0:
+			s.statementOptions = append(s.statementOptions, statements.WithTablePartitioning(statements.PartitionByNamespaceHash))
+		}
+		s.db.tablePartitions = &tablePartitions{TablePartitioning: p}
+	}
+}
+
+// tablePartitions tracks the partitions of a partitioned table.
+type tablePartitions struct {
+	TablePartitioning
+	// upper is the id up to which partitions of an id range partitioned table are known to exist.
+	upper atomic.Int64
+}
+
+// createPartitions creates the partitions of a new table, or the missing partitions of an existing one. It must be
+// called in the transaction of the migrations.
+func (d *db) createPartitions(ctx context.Context) error {
+	if d.tablePartitions != nil && d.tablePartitions.IDRange > 0 && d.tablePartitions.NamespaceHash > 0 {
+		return fmt.Errorf("a table can't be partitioned both by id range and by namespace hash")
+	}
+	switch d.stmt.TablePartitioning() {
+	case statements.PartitionByNamespaceHash:
+		for remainder := range d.tablePartitions.NamespaceHash {
+			if _, err := d.execContext(ctx, d.stmt.PartitionCreateHashSQL(d.tablePartitions.NamespaceHash, remainder)); err != nil {
+				return fmt.Errorf("failed to create partition %d of %s: %w", remainder, d.stmt.TableName(), err)
+			}
+		}
+	case statements.PartitionByIDRange:
+		return d.ensureNextPartition(ctx)
+	}
+	return nil
+}
+
+// ensurePartition creates the partition of an id range partitioned table that holds id, and the next one, so that
+// writes rarely have to create a partition.
+func (d *db) ensurePartition(ctx context.Context, id int64) error {
+	if d.stmt.TablePartitioning() != statements.PartitionByIDRange {
+		return nil
+	}
+	size := d.tablePartitions.IDRange
+	if id < d.tablePartitions.upper.Load()-size {
+		return nil
+	}
+
+	index := id / size
+	for i := index; i <= index+1; i++ {
+		if _, err := d.execContext(ctx, d.stmt.PartitionCreateRangeSQL(i, i*size, (i+1)*size)); err != nil {
+			return fmt.Errorf("failed to create partition %d of %s: %w", i, d.stmt.TableName(), err)
+		}
+	}
+	d.tablePartitions.upper.Store((index + 2) * size)
+	return nil
+}
+
+// ensureNextPartition creates the partition of the id of the next write, if needed. It must be called in a
+// transaction that locks the table.
+func (d *db) ensureNextPartition(ctx context.Context) error {
+	if d.stmt.TablePartitioning() != statements.PartitionByIDRange {
+		return nil
+	}
+	meta, err := d.getTableMeta(ctx)
+	if err != nil {
+		return err
+	}
+	return d.ensurePartition(ctx, meta.ListID+1)
+}
+
+// checkCreated returns an AlreadyExists error if the object of rec, which is being created, exists. Only id range
+// partitioned tables need it, the other tables have a unique constraint.
+func (d *db) checkCreated(ctx context.Context, rec record) error {
+	if d.stmt.TablePartitioning() != statements.PartitionByIDRange {
+		return nil
+	}
+	var count int
+	if err := d.queryRowContext(ctx, d.stmt.PartitionCreatedSQL(), rec.namespace, rec.name).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.NewAlreadyExists(d.gvk, rec.name)
+	}
+	return nil
+}
+
+// dropCompactedPartitions drops the partitions of an id range partitioned table whose ids are all below the
+// compaction point and whose rows would all be deleted by compaction, and returns the number of rows dropped.
+func (d *db) dropCompactedPartitions(ctx context.Context) (int64, error) {
+	if d.stmt.TablePartitioning() != statements.PartitionByIDRange {
+		return 0, nil
+	}
+	meta, err := d.getTableMeta(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := d.queryContext(ctx, d.stmt.PartitionListSQL())
+	if err != nil {
+		return 0, err
+	}
+	var indexes []int64
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if index, ok := d.stmt.PartitionIndex(name); ok && (index+1)*d.tablePartitions.IDRange-1 <= meta.CompactionID {
+			indexes = append(indexes, index)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var dropped int64
+	for _, index := range indexes {
+		count, err := d.dropCompactedPartition(ctx, index, meta.CompactionID)
+		if err != nil {
+			return dropped, err
+		}
+		dropped += count
+	}
+	return dropped, nil
+}
+
+// dropCompactedPartition drops the partition index if compaction up to compactionID would delete all of its rows,
+// and returns the number of rows dropped. Writes never add rows to the partition or make its rows kept again, so the
+// rows don't change between the count and the drop in ways that matter.
+func (d *db) dropCompactedPartition(ctx context.Context, index, compactionID int64) (int64, error) {
+	var count, kept int64
+	if err := d.queryRowContext(ctx, d.stmt.PartitionCompactedSQL(index), compactionID).Scan(&count, &kept); err != nil {
+		return 0, err
+	}
+	if kept > 0 {
+		return 0, nil
+	}
+	if _, err := d.execContext(ctx, d.stmt.PartitionDropSQL(index)); err != nil {
+		return 0, err
+	}
+	klog.Infof("dropped compacted partition %d of %s with %d rows", index, d.stmt.TableName(), count)
+	return count, nil
+}
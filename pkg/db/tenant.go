@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+)
+
+// TenantOptions configures ExportTenant and PurgeTenant.
+type TenantOptions struct {
+	// Progress is called after each table.
+	Progress func(TenantProgress)
+}
+
+// TenantProgress is the progress of exporting or purging a partition.
+type TenantProgress struct {
+	// Table is the table that was just exported or purged.
+	Table string
+	// Records is the number of records of the partition exported from or deleted in the table.
+	Records int64
+}
+
+// ExportTenant writes every record of the partition partitionID, including retained history, in the tables of the
+// strategies created by the factory to w, such as to answer a data subject access request. The export has the format
+// of Snapshot and is read in a single transaction, so it is consistent across tables. Values are written decoded.
+// Revisions moved to the archive of WithArchive are not exported.
+func (f *Factory) ExportTenant(ctx context.Context, partitionID string, w io.Writer, opts TenantOptions) error {
+	if partitionID == "" {
+		return fmt.Errorf("partition ID is required")
+	}
+
+	var tables []snapshotSource
+	for _, s := range f.getStrategies() {
+		source := snapshotSource{db: &s.db, gvk: s.db.gvk, partitionID: partitionID}
+		if opts.Progress != nil {
+			table := s.db.stmt.TableName()
+			source.progress = func(records int64) {
+				opts.Progress(TenantProgress{Table: table, Records: records})
+			}
+		}
+		tables = append(tables, source)
+	}
+	return writeSnapshot(WithPartitionID(ctx, partitionID), w, tables)
+}
+
+// PurgeTenant deletes every record of the partition partitionID, including retained and archived history, from the
+// tables of the strategies created by the factory, such as to answer a data subject erasure request. The records are
+// deleted in a single transaction across all tables, so either the whole partition is purged or nothing is, and their
+// unique, search and index values are deleted with them. It returns the number of records deleted.
+//
+// Purged objects are removed without deletion events, so watches of the partition should be stopped and caches
+// relisted.
+func (f *Factory) PurgeTenant(ctx context.Context, partitionID string, opts TenantOptions) (int64, error) {
+	if partitionID == "" {
+		return 0, fmt.Errorf("partition ID is required")
+	}
+	strategies := f.getStrategies()
+	if len(strategies) == 0 {
+		return 0, nil
+	}
+
+	ctx, tx, err := strategies[0].db.beginTx(WithPartitionID(ctx, partitionID), &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var (
+		total    int64
+		progress []TenantProgress
+	)
+	for _, s := range strategies {
+		count, err := s.db.purgeTenant(ctx, partitionID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge %s: %w", s.db.stmt.TableName(), err)
+		}
+		total += count
+		progress = append(progress, TenantProgress{Table: s.db.stmt.TableName(), Records: count})
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// Progress is only reported for what was committed
+	if opts.Progress != nil {
+		for _, p := range progress {
+			opts.Progress(p)
+		}
+	}
+	for _, s := range strategies {
+		s.broadcastChange()
+	}
+	return total, nil
+}
+
+// purgeTenant deletes the records of the partition in the transaction in ctx and returns their number.
+func (d *db) purgeTenant(ctx context.Context, partitionID string) (int64, error) {
+	if _, err := d.execContext(ctx, d.stmt.TableLockSQL()); err != nil {
+		return 0, err
+	}
+
+	// The latest revisions of the objects of the partition hold unique and search values
+	if len(d.uniqueRules) == 0 {
+		if err := d.invalidateUnique(ctx); err != nil {
+			return 0, err
+		}
+	}
+	if len(d.uniqueRules) > 0 || len(d.searchPaths) > 0 {
+		_, records, err := d.list(ctx, nil, nil, 0, false, cursor{}, 0)
+		if err != nil {
+			return 0, err
+		}
+		for _, rec := range records {
+			rec.deleted = 1
+			if err := d.updateUnique(ctx, rec); err != nil {
+				return 0, err
+			}
+			if len(d.searchPaths) > 0 {
+				if _, err := d.execContext(ctx, d.stmt.SearchDeleteSQL(), rec.id); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+
+	result, err := d.execContext(ctx, d.stmt.TenantPurgeSQL(), partitionID)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if d.archive {
+		if _, err := d.execContext(ctx, d.stmt.TenantPurgeArchiveSQL(), partitionID); err != nil {
+			return 0, err
+		}
+	}
+	return count, d.pruneIndexes(ctx)
+}
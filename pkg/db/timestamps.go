@@ -0,0 +1,65 @@
+package db
+
+import (
+	"math"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// CreationTimestampPolicy decides what Create does with a creationTimestamp set by the caller.
+type CreationTimestampPolicy string
+
+const (
+	// CreationTimestampKeep keeps the creationTimestamp set by the caller, for instance by a migration copying objects
+	// from another store. It is the default.
+	CreationTimestampKeep CreationTimestampPolicy = "Keep"
+	// CreationTimestampOverwrite sets the creationTimestamp of every created object to the current time.
+	CreationTimestampOverwrite CreationTimestampPolicy = "Overwrite"
+	// CreationTimestampReject rejects objects created with a creationTimestamp. The REST handlers and the client of
+	// this module set it before calling Create, so it is meant for strategies that are written to directly.
+	CreationTimestampReject CreationTimestampPolicy = "Reject"
+)
+
+// WithCreationTimestampPolicy sets what Create does with a creationTimestamp set by the caller. Objects created without
+// one always get the current time.
+func WithCreationTimestampPolicy(policy CreationTimestampPolicy) Option {
+	return func(s *Strategy) {
+		s.creationTimestampPolicy = policy
+	}
+}
+
+// setCreationTimestamp sets the creationTimestamp of obj, which is about to be created, according to the policy.
+func (s *Strategy) setCreationTimestamp(obj types.Object) error {
+	if creationTimestamp := obj.GetCreationTimestamp(); creationTimestamp.IsZero() || s.creationTimestampPolicy == CreationTimestampOverwrite {
+		obj.SetCreationTimestamp(metav1.Now())
+		return nil
+	}
+	if s.creationTimestampPolicy == CreationTimestampReject {
+		return apierrors.NewInvalid(s.db.gvk.GroupKind(), obj.GetName(), field.ErrorList{
+			field.Forbidden(field.NewPath("metadata", "creationTimestamp"), "creationTimestamp is set by the server"),
+		})
+	}
+	return nil
+}
+
+// setDeletionTimestamp marks obj, which is about to be deleted, as deleted the way the API server does for kinds
+// without graceful deletion: an object that isn't being deleted yet is deleted now with a grace period of zero. A
+// deletionTimestamp set by the caller, such as the graceful delete adapter, is kept, and its grace period is the time
+// left until it if the caller didn't set one.
+func setDeletionTimestamp(obj types.Object) {
+	now := metav1.Now()
+	deletionTimestamp := obj.GetDeletionTimestamp()
+	if deletionTimestamp == nil {
+		obj.SetDeletionTimestamp(&now)
+		obj.SetDeletionGracePeriodSeconds(new(int64))
+		return
+	}
+	if obj.GetDeletionGracePeriodSeconds() == nil {
+		gracePeriod := int64(math.Ceil(deletionTimestamp.Sub(now.Time).Seconds()))
+		gracePeriod = max(gracePeriod, 0)
+		obj.SetDeletionGracePeriodSeconds(&gracePeriod)
+	}
+}
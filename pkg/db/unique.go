@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/db/errors"
+)
+
+// UniqueRule requires the values of a field to be unique among the objects of a table.
+type UniqueRule struct {
+	// Field is the dot separated path of the field in the JSON of the object, such as "spec.alias". Objects without
+	// the field, or with an empty or null value, are not constrained.
+	Field string
+	// ClusterWide makes the values unique across all namespaces instead of per namespace.
+	ClusterWide bool
+}
+
+// WithUniqueFields enforces the given uniqueness rules on create and update. A write that would give an object the
+// same value as another object fails with an Invalid error whose cause is a duplicate value of the field.
+//
+// The values are kept in an auxiliary table, named after the table with a "_unique" suffix, which is written in the
+// same transaction as the object, so concurrent writers can't both claim a value. The auxiliary table is rebuilt from
+// the current objects when the strategy is created with different rules than it was built with, which fails if
+// existing objects already violate a rule.
+func WithUniqueFields(rules ...UniqueRule) Option {
+	return func(s *Strategy) {
+		s.db.uniqueRules = append(s.db.uniqueRules, rules...)
+	}
+}
+
+// rebuildUnique creates the auxiliary table of the unique values and fills it from the current objects if the rules
+// differ from the rules the table was filled with. Without rules the stored rules are dropped, because writes don't
+// maintain the table, so that it is filled again when rules are added back.
+func (d *db) rebuildUnique(ctx context.Context) error {
+	if len(d.uniqueRules) == 0 {
+		return d.invalidateUnique(ctx)
+	}
+
+	ctx, tx, err := d.beginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Writers hold the same lock while they check and record unique values
+	if _, err := d.execContext(ctx, d.stmt.TableLockSQL()); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.UniqueCreateSQL()); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.UniqueRulesCreateSQL()); err != nil {
+		return err
+	}
+
+	hash := uniqueRulesHash(d.uniqueRules)
+	var stored string
+	if err := d.queryRowContext(ctx, d.stmt.UniqueRulesGetSQL()).Scan(&stored); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if stored == hash {
+		return tx.Commit()
+	}
+
+	if _, err := d.execContext(ctx, d.stmt.UniqueClearSQL()); err != nil {
+		return err
+	}
+	_, records, err := d.list(ctx, nil, nil, 0, false, cursor{}, 0)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if err := d.updateUnique(ctx, rec); err != nil {
+			return fmt.Errorf("failed to index unique fields of %s %s/%s: %w", d.gvk.Kind, rec.namespace, rec.name, err)
+		}
+	}
+	if _, err := d.execContext(ctx, d.stmt.UniqueRulesClearSQL()); err != nil {
+		return err
+	}
+	if _, err := d.execContext(ctx, d.stmt.UniqueRulesInsertSQL(), hash); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// invalidateUnique makes the next strategy created with rules rebuild the auxiliary table of the unique values, after
+// objects were changed without maintaining it in the transaction in ctx.
+func (d *db) invalidateUnique(ctx context.Context) error {
+	_, err := d.execContext(ctx, d.stmt.UniqueRulesDropSQL())
+	return err
+}
+
+// uniqueRulesHash returns a hash of rules that doesn't depend on their order.
+func uniqueRulesHash(rules []UniqueRule) string {
+	keys := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		keys = append(keys, fmt.Sprintf("%s/%t", rule.Field, rule.ClusterWide))
+	}
+	slices.Sort(keys)
+	sum := sha256.Sum256([]byte(strings.Join(slices.Compact(keys), "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// updateUnique replaces the unique values held by the object of rec with the values of rec, which is being written
+// in the transaction in ctx. A deleted object releases its values.
+func (d *db) updateUnique(ctx context.Context, rec record) error {
+	if len(d.uniqueRules) == 0 {
+		return nil
+	}
+	if _, err := d.execContext(ctx, d.stmt.UniqueDeleteSQL(), rec.namespace, rec.name); err != nil {
+		return err
+	}
+	if rec.deleted == 1 {
+		return nil
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(rec.value), &obj); err != nil {
+		return err
+	}
+	for _, rule := range d.uniqueRules {
+		value, ok := uniqueValue(obj, rule.Field)
+		if !ok {
+			continue
+		}
+		scope := rec.namespace
+		if rule.ClusterWide {
+			scope = ""
+		}
+
+		var owner record
+		err := d.queryRowContext(ctx, d.stmt.UniqueOwnerSQL(), rule.Field, scope, value).Scan(&owner.namespace, &owner.name)
+		if err == nil {
+			return errors.NewDuplicateValue(d.gvk, rec.name, rule.Field, value, owner.namespace, owner.name)
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+		if _, err := d.execContext(ctx, d.stmt.UniqueInsertSQL(), rule.Field, scope, value, rec.namespace, rec.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueValue returns the value of the field at the dot separated path in obj, encoded as a string. ok is false if
+// the field is missing, null or empty.
+func uniqueValue(obj map[string]any, path string) (_ string, ok bool) {
+	var value any = obj
+	for _, key := range strings.Split(path, ".") {
+		m, isMap := value.(map[string]any)
+		if !isMap {
+			return "", false
+		}
+		if value, ok = m[key]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, v != ""
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// CreateOrUpdate creates obj, or replaces the object with its namespace and name if there is one, and returns the
+// stored object and whether it was created. The lookup and the write happen in one transaction, so concurrent calls
+// for the same object don't fail with AlreadyExists or Conflict errors.
+//
+// A replaced object keeps its UID and creationTimestamp, and the resourceVersion of obj is ignored: the last call
+// wins. obj must have a UID, which is only used if it is created.
+func (s *Strategy) CreateOrUpdate(ctx context.Context, obj types.Object) (_ types.Object, created bool, _ error) {
+	if err := s.beginRequest(ctx, mutatingRequest); err != nil {
+		return nil, false, err
+	}
+	defer s.endRequest(mutatingRequest)
+
+	defer s.broadcastChange()
+
+	ctx, tx, err := s.db.beginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// Keep the object from being created or changed between the lookup and the write
+	if _, err := s.db.execContext(ctx, s.db.stmt.TableLockSQL()); err != nil {
+		return nil, false, err
+	}
+
+	op := createWrite
+	old, err := s.get(ctx, obj.GetNamespace(), obj.GetName())
+	switch {
+	case apierrors.IsNotFound(err):
+		obj, err = s.prepareCreateOrUpdate(ctx, obj, nil)
+		if err == nil {
+			obj, err = s.doCreate(ctx, obj)
+		}
+	case err == nil:
+		obj, err = s.prepareCreateOrUpdate(ctx, obj, old)
+		if err == nil {
+			obj, op, err = s.doUpdate(ctx, obj, true)
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	s.afterWrite(ctx, op, obj)
+	return obj, op == createWrite, nil
+}
+
+// prepareCreateOrUpdate returns a copy of obj prepared to be created, or to replace old if it isn't nil.
+func (s *Strategy) prepareCreateOrUpdate(ctx context.Context, obj, old types.Object) (types.Object, error) {
+	if old == nil {
+		if obj.GetUID() == "" {
+			return nil, fmt.Errorf("object must have a UID")
+		}
+		obj = obj.DeepCopyObject().(types.Object)
+		obj.SetResourceVersion("")
+		if err := s.validateObjectMeta(ctx, obj); err != nil {
+			return nil, err
+		}
+		if err := s.setCreationTimestamp(obj); err != nil {
+			return nil, err
+		}
+		s.prepareForCreate(ctx, obj)
+		return obj, s.validateSchema(obj)
+	}
+
+	if err := s.validateScope(obj); err != nil {
+		return nil, err
+	}
+	obj = obj.DeepCopyObject().(types.Object)
+	obj.SetUID(old.GetUID())
+	obj.SetCreationTimestamp(old.GetCreationTimestamp())
+	obj.SetResourceVersion(old.GetResourceVersion())
+	if s.statusIsolation {
+		strategy.ResetFields(obj, old, false)
+	}
+	obj, err := s.prepareForUpdate(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	return obj, s.validateSchema(obj)
+}
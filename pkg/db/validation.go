@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	"github.com/obot-platform/kinm/pkg/validator"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// WithNameValidator replaces the default DNS-1123 subdomain validation of object names. Use validator.NoValidation to
+// accept any name. Objects that implement strategy.NameValidator validate their own names even without this option.
+func WithNameValidator(v strategy.NameValidator) Option {
+	return func(s *Strategy) {
+		s.nameValidator = v
+	}
+}
+
+// validateObjectMeta checks that the name and namespace of obj can be safely stored and later used by clients and in
+// field selectors.
+func (s *Strategy) validateObjectMeta(ctx context.Context, obj types.Object) error {
+	var errs field.ErrorList
+
+	nameValidator := s.nameValidator
+	if v, ok := obj.(strategy.NameValidator); ok {
+		nameValidator = v
+	} else if nameValidator == nil {
+		nameValidator = validator.ValidDNSSubdomain
+	}
+	if obj.GetName() == "" {
+		errs = append(errs, field.Required(field.NewPath("metadata", "name"), "name is required"))
+	} else {
+		errs = append(errs, nameValidator.ValidateName(ctx, obj)...)
+	}
+
+	if ns := obj.GetNamespace(); ns != "" {
+		if !s.NamespaceScoped() {
+			errs = append(errs, scopeError())
+		} else if msgs := validation.IsDNS1123Label(ns); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(field.NewPath("metadata", "namespace"), ns, strings.Join(msgs, ",")))
+		}
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(s.db.gvk.GroupKind(), obj.GetName(), errs)
+	}
+	return nil
+}
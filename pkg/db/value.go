@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// Stored values are plain JSON unless they carry one of the prefixes below. JSON objects always start with '{' so
+// the prefixes can never collide with an unencoded value.
+const (
+	zstdPrefix = "kinm:zstd:"
+	encPrefix  = "kinm:enc:"
+	blobPrefix = "kinm:blob:sha256:"
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// WithCompression enables zstd compression of stored values that are larger than threshold bytes. Compressed values
+// are always decoded on read, regardless of this setting, so it is safe to turn compression on or off for an
+// existing table.
+func WithCompression(threshold int) Option {
+	return func(s *Strategy) {
+		s.db.compressThreshold = threshold
+	}
+}
+
+// WithValueTransformer transforms stored values with transformer, typically to encrypt them. The namespace and name
+// of the object are used as the authenticated data so that a value can't be moved to another row.
+func WithValueTransformer(transformer value.Transformer) Option {
+	return func(s *Strategy) {
+		s.db.transformer = transformer
+	}
+}
+
+func transformContext(namespace, name string) value.Context {
+	return value.DefaultContext(namespace + "/" + name)
+}
+
+// encodeValue converts a value to the form that is written to the value column.
+func (d *db) encodeValue(ctx context.Context, namespace, name, value string) (string, error) {
+	value, err := d.encryptFields(ctx, namespace, name, value)
+	if err != nil {
+		return "", err
+	}
+	if d.compressThreshold > 0 && len(value) > d.compressThreshold {
+		compressed := zstdEncoder.EncodeAll([]byte(value), nil)
+		value = zstdPrefix + base64.StdEncoding.EncodeToString(compressed)
+	}
+	if d.transformer != nil {
+		data, err := d.transformer.TransformToStorage(ctx, []byte(value), transformContext(namespace, name))
+		if err != nil {
+			return "", fmt.Errorf("failed to transform value: %w", err)
+		}
+		value = encPrefix + base64.StdEncoding.EncodeToString(data)
+	}
+	if d.blobs != nil && d.blobThreshold > 0 && len(value) > d.blobThreshold {
+		sum := sha256.Sum256([]byte(value))
+		key := hex.EncodeToString(sum[:])
+		if err := d.blobs.Put(ctx, key, []byte(value)); err != nil {
+			return "", fmt.Errorf("failed to store blob: %w", err)
+		}
+		value = blobPrefix + key
+	}
+	return value, nil
+}
+
+// decodeValue reverses encodeValue. The encodings are nested, so prefixes are removed until a plain value remains, whose
+// encrypted fields are then decrypted.
+func (d *db) decodeValue(ctx context.Context, namespace, name, value string) (string, error) {
+	for {
+		if key, ok := strings.CutPrefix(value, blobPrefix); ok {
+			if d.blobs == nil {
+				return "", fmt.Errorf("value is stored in blob %s but no blob store is configured", key)
+			}
+			data, err := d.blobs.Get(ctx, key)
+			if err != nil {
+				return "", err
+			}
+			if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != key {
+				return "", fmt.Errorf("checksum mismatch for blob %s", key)
+			}
+			value = string(data)
+		} else if data, ok := strings.CutPrefix(value, zstdPrefix); ok {
+			compressed, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return "", fmt.Errorf("failed to decode compressed value: %w", err)
+			}
+			result, err := zstdDecoder.DecodeAll(compressed, nil)
+			if err != nil {
+				return "", fmt.Errorf("failed to decompress value: %w", err)
+			}
+			value = string(result)
+		} else if data, ok := strings.CutPrefix(value, encPrefix); ok {
+			if d.transformer == nil {
+				return "", fmt.Errorf("value is transformed but no transformer is configured")
+			}
+			transformed, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return "", fmt.Errorf("failed to decode transformed value: %w", err)
+			}
+			result, _, err := d.transformer.TransformFromStorage(ctx, transformed, transformContext(namespace, name))
+			if err != nil {
+				return "", fmt.Errorf("failed to transform value: %w", err)
+			}
+			value = string(result)
+		} else {
+			return d.decryptFields(ctx, namespace, name, value)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// ProblemKind identifies an inconsistency found by Verify.
+type ProblemKind string
+
+const (
+	// ProblemBrokenChain is a record whose previous record belongs to another object, is not older, or is a tombstone.
+	// It can't be repaired automatically.
+	ProblemBrokenChain ProblemKind = "BrokenChain"
+	// ProblemStaleHead is a revision that no later revision of its object follows, although it is neither the latest
+	// revision nor a tombstone, as left by a write that crashed half way. Reads ignore it. Repair deletes it.
+	ProblemStaleHead ProblemKind = "StaleHead"
+	// ProblemStaleCreated is the first revision of an object that has been deleted since, but still marked as
+	// created. It prevents the object from being created again. Repair clears the mark.
+	ProblemStaleCreated ProblemKind = "StaleCreated"
+	// ProblemOrphanedTombstone is a tombstone without a previous revision of the object it deletes. Repair deletes it.
+	ProblemOrphanedTombstone ProblemKind = "OrphanedTombstone"
+	// ProblemCompactionDrift is a compaction point ahead of the latest revision of the table, for example after rows
+	// were deleted by hand, which would let new revisions be compacted immediately. Repair moves the compaction point
+	// back to the latest revision.
+	ProblemCompactionDrift ProblemKind = "CompactionDrift"
+)
+
+// Problem is an inconsistency found by Verify. ResourceVersion, Namespace and Name identify the record, and are empty
+// for problems of the table as a whole.
+type Problem struct {
+	Kind            ProblemKind `json:"kind"`
+	Table           string      `json:"table"`
+	ResourceVersion string      `json:"resourceVersion,omitempty"`
+	Namespace       string      `json:"namespace,omitempty"`
+	Name            string      `json:"name,omitempty"`
+	// Repaired is true if the problem was repaired.
+	Repaired bool `json:"repaired,omitempty"`
+}
+
+func (p Problem) String() string {
+	if p.ResourceVersion == "" {
+		return fmt.Sprintf("%s: %s", p.Table, p.Kind)
+	}
+	name := p.Name
+	if p.Namespace != "" {
+		name = p.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s: %s at revision %s of %s", p.Table, p.Kind, p.ResourceVersion, name)
+}
+
+// VerifyOptions configures Verify.
+type VerifyOptions struct {
+	// Repair repairs the problems that can be repaired, in the same transaction as the checks.
+	Repair bool
+}
+
+// Verify checks the records of the table for inconsistencies that the database constraints don't prevent, and returns
+// the problems found. Gaps in the history of objects are expected after compaction or with WithHistoryLimit, and are
+// not reported.
+func (s *Strategy) Verify(ctx context.Context, opts VerifyOptions) ([]Problem, error) {
+	if err := s.beginRequest(ctx, readOnlyRequest); err != nil {
+		return nil, err
+	}
+	defer s.endRequest(readOnlyRequest)
+
+	problems, err := s.db.verify(WithRowLevelSecurityBypass(ctx), opts.Repair)
+	if err == nil && opts.Repair {
+		s.broadcastChange()
+	}
+	return problems, err
+}
+
+// Verify checks the named table for inconsistencies, see Strategy.Verify. Writers of the table should be stopped
+// before repairing it.
+func (a *Admin) Verify(ctx context.Context, table string, opts VerifyOptions) ([]Problem, error) {
+	d, err := a.table(ctx, table, false)
+	if err != nil {
+		return nil, err
+	}
+	return d.verify(ctx, opts.Repair)
+}
+
+func (d *db) verify(ctx context.Context, repair bool) ([]Problem, error) {
+	ctx, tx, err := d.beginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  !repair,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if repair {
+		if _, err := d.execContext(ctx, d.stmt.TableLockSQL()); err != nil {
+			return nil, err
+		}
+	}
+
+	var problems []Problem
+	for _, check := range []struct {
+		kind   ProblemKind
+		query  string
+		repair string
+	}{
+		{ProblemBrokenChain, d.stmt.VerifyChainsSQL(), ""},
+		{ProblemStaleHead, d.stmt.VerifyHeadsSQL(), d.stmt.DeleteByIDSQL()},
+		{ProblemStaleCreated, d.stmt.VerifyCreatedSQL(), d.stmt.ClearCreatedByIDSQL()},
+		{ProblemOrphanedTombstone, d.stmt.VerifyTombstonesSQL(), d.stmt.DeleteByIDSQL()},
+	} {
+		found, err := d.verifyRecords(ctx, check.kind, check.query)
+		if err != nil {
+			return nil, err
+		}
+		if repair && check.repair != "" && len(found) > 0 {
+			if err := d.invalidateUnique(ctx); err != nil {
+				return nil, err
+			}
+			for i := range found {
+				id, _ := strconv.ParseInt(found[i].ResourceVersion, 10, 64)
+				if _, err := d.execContext(ctx, check.repair, id); err != nil {
+					return nil, err
+				}
+				found[i].Repaired = true
+			}
+		}
+		problems = append(problems, found...)
+	}
+
+	meta, err := d.getTableMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if meta.CompactionID > meta.ListID {
+		problem := Problem{
+			Kind:  ProblemCompactionDrift,
+			Table: d.baseTableName(),
+		}
+		if repair {
+			if _, err := d.execContext(ctx, d.stmt.SetCompactionSQL(), meta.ListID); err != nil {
+				return nil, err
+			}
+			problem.Repaired = true
+		}
+		problems = append(problems, problem)
+	}
+
+	return problems, tx.Commit()
+}
+
+func (d *db) verifyRecords(ctx context.Context, kind ProblemKind, query string) ([]Problem, error) {
+	rows, err := d.queryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []Problem
+	for rows.Next() {
+		var (
+			id      int64
+			problem = Problem{
+				Kind:  kind,
+				Table: d.baseTableName(),
+			}
+		)
+		if err := rows.Scan(&id, &problem.Namespace, &problem.Name); err != nil {
+			return nil, err
+		}
+		problem.ResourceVersion = strconv.FormatInt(id, 10)
+		problems = append(problems, problem)
+	}
+	return problems, rows.Err()
+}
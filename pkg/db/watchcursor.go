@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/obot-platform/kinm/pkg/db/errors"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// WatchCursor is the resourceVersion of the last event of a table processed by a named consumer. It is stored in the
+// database, so that a consumer such as an embedded controller can resume watching where it left off after a restart
+// without missing events.
+type WatchCursor struct {
+	s        *Strategy
+	consumer string
+}
+
+// WatchCursor returns the cursor of consumer for the table of the strategy. Consumers sharing a name share a cursor.
+func (s *Strategy) WatchCursor(consumer string) *WatchCursor {
+	return &WatchCursor{
+		s:        s,
+		consumer: consumer,
+	}
+}
+
+// ResourceVersion returns the saved resourceVersion, or an empty string if none was saved.
+func (c *WatchCursor) ResourceVersion(ctx context.Context) (string, error) {
+	var rv int64
+	err := c.s.db.queryRowContext(ctx, c.s.db.stmt.CursorGetSQL(), c.consumer).Scan(&rv)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(rv, 10), nil
+}
+
+// Save saves resourceVersion as the last processed event. Saving after an event has been processed, rather than when
+// it is received, delivers every event at least once.
+func (c *WatchCursor) Save(ctx context.Context, resourceVersion string) error {
+	rv, err := parseResourceVersion(resourceVersion)
+	if err != nil {
+		return err
+	}
+	_, err = c.s.db.execContext(ctx, c.s.db.stmt.CursorSetSQL(), c.consumer, rv)
+	return err
+}
+
+// Reset deletes the saved resourceVersion, so that the next watch starts with the current objects.
+func (c *WatchCursor) Reset(ctx context.Context) error {
+	_, err := c.s.db.execContext(ctx, c.s.db.stmt.CursorDeleteSQL(), c.consumer)
+	return err
+}
+
+// Watch watches namespace, or all namespaces if namespace is empty, from the saved resourceVersion, ignoring the
+// resourceVersion of opts. Without a saved resourceVersion the watch starts with the current objects as ADDED events.
+//
+// If the saved resourceVersion has been compacted, some events after it may be gone, so Watch returns an Expired error
+// for which errors.IsCompacted is true instead of silently skipping them. The consumer must then resync, by listing
+// the objects and saving the resourceVersion of the list, or Reset the cursor.
+//
+// Events are not saved by the watch; the consumer calls Save once it has processed an event.
+func (c *WatchCursor) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	rv, err := c.ResourceVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rv != "" {
+		meta, err := c.s.db.getTableMeta(ctx)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := strconv.ParseInt(rv, 10, 64)
+		if id < meta.CompactionID {
+			return nil, errors.NewCompactionError(uint(id), uint(meta.CompactionID))
+		}
+		prunedID, _, err := c.s.db.prunedIDs(ctx, getNamespace(namespace), nil, id)
+		if err != nil {
+			return nil, err
+		}
+		if id < prunedID {
+			return nil, errors.NewCompactionError(uint(id), uint(prunedID))
+		}
+	}
+	opts.ResourceVersion = rv
+	return c.s.Watch(ctx, namespace, opts)
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/db/errors"
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const defaultWatchBuffer = 100
+
+// WithWatchBuffer sets the number of events buffered for each watcher. A watcher that falls this many events behind
+// is closed with a 410 Gone error so that the client lists and watches again, instead of stalling the query loop of
+// the watch. A negative size disables buffering and blocks until the client receives each event. The default is 100.
+func WithWatchBuffer(size int) Option {
+	return func(s *Strategy) {
+		s.watchBuffer = size
+	}
+}
+
+type watcher struct {
+	s  *Strategy
+	ch chan watch.Event
+	// ext replaces ch for watches started with WatchExt
+	ext       chan ExtendedEvent
+	size      int
+	namespace string
+	started   time.Time
+	// cancel ends the watch, closing ch once the watch goroutine returns
+	cancel context.CancelFunc
+	// delivered is the resource version of the last object queued to the client
+	delivered atomic.Int64
+}
+
+func (s *Strategy) newWatcher(namespace string, ext bool) *watcher {
+	w := &watcher{
+		s:         s,
+		size:      s.watchBuffer,
+		namespace: namespace,
+		started:   time.Now(),
+	}
+	if w.size == 0 {
+		w.size = defaultWatchBuffer
+	}
+	// One extra slot is reserved for the error sent when the watcher is too slow
+	buffer := w.size + 1
+	if w.size < 0 {
+		buffer = 0
+	}
+	if ext {
+		w.ext = make(chan ExtendedEvent, buffer)
+	} else {
+		w.ch = make(chan watch.Event, buffer)
+	}
+	return w
+}
+
+// queued returns the number of events sent that the client didn't receive yet.
+func (w *watcher) queued() int {
+	// Only one of the channels is set, the length of the other is zero
+	return len(w.ch) + len(w.ext)
+}
+
+// close closes the channel of the watcher.
+func (w *watcher) close() {
+	if w.ext != nil {
+		close(w.ext)
+	} else {
+		close(w.ch)
+	}
+}
+
+// send delivers an event to the watcher and returns false if the watch has ended, either because ctx is done or
+// because the watcher has fallen too far behind.
+func (w *watcher) send(ctx context.Context, event watch.Event) bool {
+	return w.sendExt(ctx, ExtendedEvent{Event: event})
+}
+
+// sendExt is send with the previous object, which is dropped unless the watch was started with WatchExt.
+func (w *watcher) sendExt(ctx context.Context, event ExtendedEvent) bool {
+	// Only this goroutine sends, so the buffer can't fill up between checking its length and sending
+	if w.size > 0 && w.queued() >= w.size {
+		// The slot reserved for this error is always free, so this doesn't block
+		tooSlow := toWatchEventError(errors.NewWatcherTooSlow(w.s.db.gvk))
+		select {
+		case w.ch <- tooSlow:
+		case w.ext <- ExtendedEvent{Event: tooSlow}:
+		}
+		return false
+	}
+	// Sending on the nil channel of the two never proceeds
+	select {
+	case w.ch <- event.Event:
+	case w.ext <- event:
+	case <-ctx.Done():
+		return false
+	}
+	if obj, ok := event.Object.(types.Object); ok {
+		if rv, err := strconv.ParseInt(obj.GetResourceVersion(), 10, 64); err == nil {
+			w.delivered.Store(rv)
+		}
+	}
+	return true
+}
+
+// WatchInfo describes an active watch, see Factory.Watches.
+type WatchInfo struct {
+	Table string `json:"table"`
+	// Namespace is the namespace watched, empty for all namespaces.
+	Namespace string `json:"namespace,omitempty"`
+	// ResourceVersion is the resource version of the last object queued to the client, empty if none was.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// QueueDepth is the number of events queued that the client didn't receive yet.
+	QueueDepth int       `json:"queueDepth"`
+	Started    time.Time `json:"started"`
+	// Age is the time since the watch started, in seconds.
+	Age float64 `json:"age"`
+}
+
+func (w *watcher) info(now time.Time) WatchInfo {
+	info := WatchInfo{
+		Table:      w.s.db.stmt.TableName(),
+		Namespace:  w.namespace,
+		QueueDepth: w.queued(),
+		Started:    w.started,
+		Age:        now.Sub(w.started).Seconds(),
+	}
+	if rv := w.delivered.Load(); rv != 0 {
+		info.ResourceVersion = strconv.FormatInt(rv, 10)
+	}
+	return info
+}
+
+// Watches returns the active watches of the strategy, oldest first.
+func (s *Strategy) Watches() []WatchInfo {
+	now := time.Now()
+	s.lifecycle.watches.lock.Lock()
+	result := make([]WatchInfo, 0, len(s.lifecycle.watches.watches))
+	for w := range s.lifecycle.watches.watches {
+		result = append(result, w.info(now))
+	}
+	s.lifecycle.watches.lock.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result
+}
+
+// Watches returns the active watches of the strategies created by the factory, sorted by table and oldest first, to
+// find stuck or leaking watches. A watch whose queue stays full is not keeping up with changes; one whose resource
+// version stays behind while its table changes is not being sent events. The watches of WatchSet and Changes are not
+// included.
+func (f *Factory) Watches() []WatchInfo {
+	var result []WatchInfo
+	for _, s := range f.getStrategies() {
+		result = append(result, s.Watches()...)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Table < result[j].Table
+	})
+	return result
+}
+
+// WatchesHandler returns an HTTP handler that serves the active watches returned by Watches as JSON, to be mounted on
+// a debug endpoint. The table query parameter limits the watches to a table.
+func (f *Factory) WatchesHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		watches := f.Watches()
+		if table := req.URL.Query().Get("table"); table != "" {
+			watches = slices.DeleteFunc(watches, func(info WatchInfo) bool {
+				return info.Table != table
+			})
+		}
+		if watches == nil {
+			watches = []WatchInfo{}
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(watches)
+	})
+}
+
+// sendError sends err to the watcher unless the watch ended, in which case err is most likely caused by the canceled
+// context and the channel is closed without an error.
+func (w *watcher) sendError(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	w.send(ctx, toWatchEventError(err))
+}
+
+const (
+	defaultWatchPollInterval = 2 * time.Second
+	defaultBookmarkInterval  = time.Minute
+)
+
+// WithWatchPollInterval sets how often watches query for changes made by other processes. Changes made through the
+// same strategy are seen immediately. The default is two seconds.
+func WithWatchPollInterval(interval time.Duration) Option {
+	return func(s *Strategy) {
+		s.watchPollInterval = interval
+	}
+}
+
+// WithBookmarkInterval sets how often bookmarks are sent to watches that requested progress notifications. The
+// default is one minute.
+func WithBookmarkInterval(interval time.Duration) Option {
+	return func(s *Strategy) {
+		s.bookmarkInterval = interval
+	}
+}
+
+// WithMaxWatchDuration closes watches after the given duration so that clients reconnect, which spreads them across
+// replicas. By default watches are not closed.
+//
+// These watch options can be applied to all strategies of a factory with WithStrategyOptions.
+func WithMaxWatchDuration(duration time.Duration) Option {
+	return func(s *Strategy) {
+		s.maxWatchDuration = duration
+	}
+}
+
+func (s *Strategy) getWatchPollInterval() time.Duration {
+	if s.watchPollInterval > 0 {
+		return s.watchPollInterval
+	}
+	return defaultWatchPollInterval
+}
+
+func (s *Strategy) getBookmarkInterval() time.Duration {
+	if s.bookmarkInterval > 0 {
+		return s.bookmarkInterval
+	}
+	return defaultBookmarkInterval
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// ExtendedEvent is a watch event with the object before the change.
+type ExtendedEvent struct {
+	watch.Event
+	// OldObject is the previous revision of the object for Modified and Deleted events. It is nil for other events and
+	// when the previous revision has already been compacted.
+	OldObject runtime.Object
+}
+
+// WatchExt is like Watch but also sends the previous revision of modified and deleted objects, so that consumers can
+// tell what changed without keeping a copy of every object. The previous revisions are read by the same queries as
+// the changes. The objects sent by the initial list, when opts has no resource version, have no previous object.
+func (s *Strategy) WatchExt(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan ExtendedEvent, error) {
+	w, err := s.watch(ctx, namespace, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	return w.ext, nil
+}
+
+type previousValuesKey struct{}
+
+// withPreviousValues makes the queries for changes in ctx also read the values of the previous revisions, which
+// previousObject needs. Only consumers of the previous objects use it, to not double the reads of the other watches.
+func withPreviousValues(ctx context.Context) context.Context {
+	return context.WithValue(ctx, previousValuesKey{}, true)
+}
+
+func readPreviousValues(ctx context.Context) bool {
+	read, _ := ctx.Value(previousValuesKey{}).(bool)
+	return read
+}
+
+// previousObject returns the previous revision of the object of rec, which must have been read by a query for changes
+// in a context from withPreviousValues, or nil if rec was created or the previous revision is gone.
+func (s *Strategy) previousObject(ctx context.Context, rec record) (types.Object, error) {
+	if rec.created == 1 || rec.previousID == nil || rec.previousValue == nil {
+		return nil, nil
+	}
+	prev := record{
+		id:        *rec.previousID,
+		name:      rec.name,
+		namespace: rec.namespace,
+		value:     *rec.previousValue,
+	}
+	if err := s.db.readValue(ctx, &prev, rec.previousChecksum); err != nil {
+		return nil, err
+	}
+	obj := s.New()
+	if err := prev.Unmarshal(obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/cdc"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+var _ cdc.Source = (*Factory)(nil)
+
+// WatchSetEvent is a change to an object of one of the kinds of a WatchSet.
+type WatchSetEvent struct {
+	GroupVersionKind schema.GroupVersionKind
+	watch.Event
+}
+
+// WatchSet returns a single channel of the changes to objects of the given kinds, which must have been created with
+// NewDBStrategy. If no kinds are given, all kinds created by the factory are watched. Unlike Watch, the current
+// objects aren't sent first, only the changes made after WatchSet is called.
+//
+// All kinds are polled by a single goroutine, which is woken up by any change made through the factory. The channel
+// is closed when ctx is done. If a query fails, an error event is sent and the channel is closed.
+func (f *Factory) WatchSet(ctx context.Context, gvks ...schema.GroupVersionKind) (<-chan WatchSetEvent, error) {
+	ch := make(chan WatchSetEvent, defaultWatchBuffer)
+	err := f.pollChanges(ctx, nil, gvks, func(s *Strategy, rec record, err error) bool {
+		event := WatchSetEvent{GroupVersionKind: s.db.gvk}
+		if err != nil {
+			event.Event = toWatchEventError(err)
+		} else {
+			event.Event = s.toWatchEvent(rec)
+		}
+		select {
+		case ch <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() {
+		close(ch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// Changes implements cdc.Source. It is like WatchSet but includes the previous version of modified and deleted
+// objects, and can resume from a resource version.
+func (f *Factory) Changes(ctx context.Context, start map[schema.GroupVersionKind]string, gvks ...schema.GroupVersionKind) (<-chan cdc.Change, error) {
+	ch := make(chan cdc.Change, defaultWatchBuffer)
+	ctx = withPreviousValues(ctx)
+	err := f.pollChanges(ctx, start, gvks, func(s *Strategy, rec record, err error) bool {
+		change := cdc.Change{GroupVersionKind: s.db.gvk}
+		if err == nil {
+			change, err = s.toChange(ctx, rec)
+		}
+		if err != nil {
+			event := toWatchEventError(err)
+			change.Type, change.Object = event.Type, event.Object
+		}
+		select {
+		case ch <- change:
+			return err == nil
+		case <-ctx.Done():
+			return false
+		}
+	}, func() {
+		close(ch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (s *Strategy) toChange(ctx context.Context, rec record) (cdc.Change, error) {
+	event := s.toWatchEvent(rec)
+	if event.Type == watch.Error {
+		return cdc.Change{}, fmt.Errorf("failed to decode %s %s/%s", s.db.gvk.Kind, rec.namespace, rec.name)
+	}
+	change := cdc.Change{
+		GroupVersionKind: s.db.gvk,
+		Type:             event.Type,
+		ResourceVersion:  strconv.FormatInt(rec.id, 10),
+		Object:           event.Object,
+	}
+	var err error
+	if change.OldObject, err = s.previousObject(ctx, rec); err != nil {
+		return cdc.Change{}, err
+	}
+	return change, nil
+}
+
+// pollChanges starts a goroutine that calls send for every record written to the tables of gvks after the
+// revisions in start, or after the current revision for kinds not in start. After an error is sent, or when send
+// returns false, polling stops and done is called.
+func (f *Factory) pollChanges(ctx context.Context, start map[schema.GroupVersionKind]string, gvks []schema.GroupVersionKind,
+	send func(s *Strategy, rec record, err error) bool, done func()) error {
+	strategies, err := f.strategiesFor(gvks)
+	if err != nil {
+		return err
+	}
+
+	revisions := make([]string, len(strategies))
+	for i, s := range strategies {
+		if rev, ok := start[s.db.gvk]; ok {
+			revisions[i] = rev
+			continue
+		}
+		meta, err := s.db.getTableMeta(ctx)
+		if err != nil {
+			return err
+		}
+		revisions[i] = strconv.FormatInt(meta.ListID, 10)
+	}
+
+	go func() {
+		defer done()
+		for {
+			// Get the wait channel before polling so that changes made while polling aren't missed
+			changed := f.changes.wait()
+			for i, s := range strategies {
+				rev, ok := pollStrategy(ctx, s, revisions[i], send)
+				if !ok {
+					return
+				}
+				revisions[i] = rev
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			case <-time.After(defaultWatchPollInterval):
+			}
+		}
+	}()
+	return nil
+}
+
+// pollStrategy sends the records of s after revision and returns the new revision. It returns false if polling
+// should stop.
+func pollStrategy(ctx context.Context, s *Strategy, revision string, send func(s *Strategy, rec record, err error) bool) (string, bool) {
+	sendError := func(err error) {
+		if ctx.Err() == nil {
+			send(s, record{}, err)
+		}
+	}
+
+	if err := s.begin(); err != nil {
+		sendError(err)
+		return "", false
+	}
+	defer s.end()
+
+	newRevision, lister, err := s.newWatchLister(ctx, "", storage.ListOptions{ResourceVersion: revision}, true)
+	if err != nil {
+		sendError(err)
+		return "", false
+	}
+	for rec, err := range lister {
+		if err != nil {
+			sendError(err)
+			return "", false
+		}
+		if !send(s, rec, nil) {
+			return "", false
+		}
+	}
+	return newRevision, true
+}
+
+func (f *Factory) strategiesFor(gvks []schema.GroupVersionKind) ([]*Strategy, error) {
+	all := f.getStrategies()
+	if len(gvks) == 0 {
+		return all, nil
+	}
+
+	var result []*Strategy
+	for _, gvk := range gvks {
+		found := false
+		for _, s := range all {
+			if s.db.gvk == gvk {
+				result = append(result, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no strategy has been created for %s", gvk)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package etcd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// leases tracks granted leases so that clients can grant, renew and revoke them. Keys attached to a lease are not
+// removed when it expires.
+type leases struct {
+	lock   sync.Mutex
+	nextID int64
+	leases map[int64]*lease
+}
+
+type lease struct {
+	ttl     int64
+	expires time.Time
+}
+
+func (l *leases) grant(id, ttl int64) int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.leases == nil {
+		l.leases = map[int64]*lease{}
+	}
+	if id == 0 {
+		l.nextID++
+		id = l.nextID
+	}
+	l.leases[id] = &lease{
+		ttl:     ttl,
+		expires: time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+	return id
+}
+
+func (l *leases) get(id int64) (*lease, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	lease, ok := l.leases[id]
+	if ok && time.Now().After(lease.expires) {
+		delete(l.leases, id)
+		return nil, false
+	}
+	return lease, ok
+}
+
+func (l *leases) renew(id int64) (int64, bool) {
+	lease, ok := l.get(id)
+	if !ok {
+		return 0, false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	lease.expires = time.Now().Add(time.Duration(lease.ttl) * time.Second)
+	return lease.ttl, true
+}
+
+func (l *leases) revoke(id int64) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, ok := l.leases[id]
+	delete(l.leases, id)
+	return ok
+}
+
+func (s *Server) LeaseGrant(ctx context.Context, req *etcdserverpb.LeaseGrantRequest) (*etcdserverpb.LeaseGrantResponse, error) {
+	rev, err := s.currentRevision(ctx)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	return &etcdserverpb.LeaseGrantResponse{
+		Header: s.header(rev),
+		ID:     s.leases.grant(req.ID, req.TTL),
+		TTL:    req.TTL,
+	}, nil
+}
+
+func (s *Server) LeaseRevoke(ctx context.Context, req *etcdserverpb.LeaseRevokeRequest) (*etcdserverpb.LeaseRevokeResponse, error) {
+	if !s.leases.revoke(req.ID) {
+		return nil, status.Error(codes.NotFound, "etcdserver: requested lease not found")
+	}
+	rev, err := s.currentRevision(ctx)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	return &etcdserverpb.LeaseRevokeResponse{Header: s.header(rev)}, nil
+}
+
+func (s *Server) LeaseKeepAlive(stream etcdserverpb.Lease_LeaseKeepAliveServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		rev, err := s.currentRevision(stream.Context())
+		if err != nil {
+			return toGRPCError(err)
+		}
+		// An unknown lease is reported with a TTL of zero
+		ttl, _ := s.leases.renew(req.ID)
+		if err := stream.Send(&etcdserverpb.LeaseKeepAliveResponse{
+			Header: s.header(rev),
+			ID:     req.ID,
+			TTL:    ttl,
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) LeaseTimeToLive(ctx context.Context, req *etcdserverpb.LeaseTimeToLiveRequest) (*etcdserverpb.LeaseTimeToLiveResponse, error) {
+	rev, err := s.currentRevision(ctx)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	resp := &etcdserverpb.LeaseTimeToLiveResponse{
+		Header: s.header(rev),
+		ID:     req.ID,
+		TTL:    -1,
+	}
+	if lease, ok := s.leases.get(req.ID); ok {
+		resp.GrantedTTL = lease.ttl
+		resp.TTL = int64(time.Until(lease.expires).Seconds())
+	}
+	return resp, nil
+}
+
+func (s *Server) LeaseLeases(ctx context.Context, _ *etcdserverpb.LeaseLeasesRequest) (*etcdserverpb.LeaseLeasesResponse, error) {
+	rev, err := s.currentRevision(ctx)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	resp := &etcdserverpb.LeaseLeasesResponse{Header: s.header(rev)}
+	s.leases.lock.Lock()
+	defer s.leases.lock.Unlock()
+	for id, lease := range s.leases.leases {
+		if time.Now().Before(lease.expires) {
+			resp.Leases = append(resp.Leases, &etcdserverpb.LeaseStatus{ID: id})
+		}
+	}
+	return resp, nil
+}
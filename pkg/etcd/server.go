@@ -0,0 +1,641 @@
+// Package etcd serves a kinm table over a subset of the etcd v3 gRPC API, similar to kine, so that etcd clients such
+// as kube-apiserver can read and write the same objects that are served through strategies.
+//
+// A Server serves the objects of a single strategy under a key prefix, for example "/registry/widgets/", with keys
+// of the form <prefix><namespace>/<name> for namespaced kinds and <prefix><name> otherwise. kube-apiserver can use it
+// for one resource with --etcd-servers-overrides. Values are stored as JSON objects of the kind, so kube-apiserver must
+// be run with --storage-media-type=application/json; protobuf values, its default for built-in resources, are
+// rejected. Revisions are the resource versions of the table, so one server can't serve several tables.
+//
+// The supported subset is: Range, Put, DeleteRange and the Txn patterns used by kube-apiserver (compare the mod
+// revision of a key, then put, delete or get it), Watch without progress requests, and Lease grants that are tracked
+// but never expire keys. Create revisions and versions aren't tracked, so CreateRevision is reported as the mod
+// revision and Version as 1. Keys outside the prefix, such as kube-apiserver's compaction key, are kept in memory.
+//
+// Server.Import and Server.Export copy the objects of a resource between an existing etcd cluster, or a kine
+// database, and a table, for migrating a cluster's data into an embedded kinm deployment and back.
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+var (
+	_ etcdserverpb.KVServer    = (*Server)(nil)
+	_ etcdserverpb.WatchServer = (*Server)(nil)
+	_ etcdserverpb.LeaseServer = (*Server)(nil)
+)
+
+type Server struct {
+	strategy   strategy.CompleteStrategy
+	prefix     string
+	namespaced bool
+
+	// other holds the keys outside the prefix
+	otherLock sync.Mutex
+	other     map[string]*mvccpb.KeyValue
+
+	leases leases
+}
+
+// NewServer serves the objects of s under prefix. namespaced must match the scope of the kind.
+func NewServer(s strategy.CompleteStrategy, prefix string, namespaced bool) *Server {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &Server{
+		strategy:   s,
+		prefix:     prefix,
+		namespaced: namespaced,
+		other:      map[string]*mvccpb.KeyValue{},
+	}
+}
+
+// Register registers the KV, Watch and Lease services with g.
+func (s *Server) Register(g *grpc.Server) {
+	etcdserverpb.RegisterKVServer(g, s)
+	etcdserverpb.RegisterWatchServer(g, s)
+	etcdserverpb.RegisterLeaseServer(g, s)
+}
+
+// Serve serves the etcd API on listener until ctx is done.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	g := grpc.NewServer()
+	s.Register(g)
+	go func() {
+		<-ctx.Done()
+		g.GracefulStop()
+	}()
+	return g.Serve(listener)
+}
+
+// parseKey returns the namespace and name of key. ok is false if key is outside the prefix.
+func (s *Server) parseKey(key string) (namespace, name string, ok bool) {
+	rest, ok := strings.CutPrefix(key, s.prefix)
+	if !ok || rest == "" {
+		return "", "", false
+	}
+	if !s.namespaced {
+		return "", rest, !strings.Contains(rest, "/")
+	}
+	namespace, name, ok = strings.Cut(rest, "/")
+	return namespace, name, ok && name != "" && !strings.Contains(name, "/")
+}
+
+func (s *Server) keyFor(obj types.Object) string {
+	if s.namespaced {
+		return s.prefix + obj.GetNamespace() + "/" + obj.GetName()
+	}
+	return s.prefix + obj.GetName()
+}
+
+func (s *Server) toKV(obj types.Object, keysOnly bool) (*mvccpb.KeyValue, error) {
+	rev, err := strconv.ParseInt(obj.GetResourceVersion(), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	kv := &mvccpb.KeyValue{
+		Key:            []byte(s.keyFor(obj)),
+		CreateRevision: rev,
+		ModRevision:    rev,
+		Version:        1,
+	}
+	if !keysOnly {
+		// Stored values don't contain the resource version
+		obj = obj.DeepCopyObject().(types.Object)
+		obj.SetResourceVersion("")
+		if kv.Value, err = json.Marshal(obj); err != nil {
+			return nil, err
+		}
+	}
+	return kv, nil
+}
+
+func (s *Server) header(rev int64) *etcdserverpb.ResponseHeader {
+	return &etcdserverpb.ResponseHeader{
+		Revision: rev,
+	}
+}
+
+// currentRevision returns the latest revision of the table.
+func (s *Server) currentRevision(ctx context.Context) (int64, error) {
+	list, err := s.strategy.List(ctx, "", storage.ListOptions{
+		Predicate: storage.SelectionPredicate{Limit: 1},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(list.GetResourceVersion(), 10, 64)
+}
+
+// list returns the objects of namespace at revision rev, sorted by key.
+func (s *Server) list(ctx context.Context, namespace string, rev int64) (int64, []types.Object, error) {
+	opts := storage.ListOptions{}
+	if rev > 0 {
+		opts.ResourceVersion = strconv.FormatInt(rev, 10)
+	}
+	list, err := s.strategy.List(ctx, namespace, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	listRev, err := strconv.ParseInt(list.GetResourceVersion(), 10, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return 0, nil, err
+	}
+	result := make([]types.Object, 0, len(items))
+	for _, item := range items {
+		result = append(result, item.(types.Object))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return s.keyFor(result[i]) < s.keyFor(result[j])
+	})
+	return listRev, result, nil
+}
+
+// get returns the current key value of key, or nil if it doesn't exist.
+func (s *Server) get(ctx context.Context, key string) (int64, *mvccpb.KeyValue, error) {
+	namespace, name, ok := s.parseKey(key)
+	if !ok {
+		return s.getOther(ctx, key, 0)
+	}
+	obj, err := s.strategy.Get(ctx, namespace, name)
+	if apierrors.IsNotFound(err) {
+		obj, err = nil, nil
+	} else if err != nil {
+		return 0, nil, err
+	}
+	// The revision is read after the object, so that it is never older than the mod revision of the key
+	rev, err := s.currentRevision(ctx)
+	if err != nil || obj == nil {
+		return rev, nil, err
+	}
+	kv, err := s.toKV(obj, false)
+	return rev, kv, err
+}
+
+func (s *Server) Range(ctx context.Context, req *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	key, end := string(req.Key), string(req.RangeEnd)
+	if end == "" {
+		var (
+			rev int64
+			kv  *mvccpb.KeyValue
+			err error
+		)
+		if req.Revision > 0 {
+			// The key may have been deleted or recreated since the revision
+			rev, kv, err = s.getAt(ctx, key, req.Revision)
+		} else {
+			rev, kv, err = s.get(ctx, key)
+		}
+		if err != nil {
+			return nil, toGRPCError(err)
+		}
+		resp := &etcdserverpb.RangeResponse{Header: s.header(rev)}
+		if kv != nil {
+			resp.Count = 1
+			if !req.CountOnly {
+				if req.KeysOnly {
+					kv.Value = nil
+				}
+				resp.Kvs = []*mvccpb.KeyValue{kv}
+			}
+		}
+		return resp, nil
+	}
+
+	namespace, err := s.rangeNamespace(key, end)
+	if err != nil {
+		return nil, err
+	}
+	rev, objs, err := s.list(ctx, namespace, req.Revision)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+
+	resp := &etcdserverpb.RangeResponse{Header: s.header(rev)}
+	for _, obj := range objs {
+		objKey := s.keyFor(obj)
+		if objKey < key || (end != "\x00" && objKey >= end) {
+			continue
+		}
+		resp.Count++
+		if req.CountOnly {
+			continue
+		}
+		if req.Limit > 0 && int64(len(resp.Kvs)) >= req.Limit {
+			resp.More = true
+			continue
+		}
+		kv, err := s.toKV(obj, req.KeysOnly)
+		if err != nil {
+			return nil, toGRPCError(err)
+		}
+		resp.Kvs = append(resp.Kvs, kv)
+	}
+	return resp, nil
+}
+
+// getAt returns the key value of key at revision rev, or nil if it didn't exist at rev.
+func (s *Server) getAt(ctx context.Context, key string, rev int64) (int64, *mvccpb.KeyValue, error) {
+	namespace, name, ok := s.parseKey(key)
+	if !ok {
+		return s.getOther(ctx, key, rev)
+	}
+	obj, err := strategy.GetWithOptions(ctx, s.strategy, namespace, name, metav1.GetOptions{
+		ResourceVersion: strconv.FormatInt(rev, 10),
+	})
+	if apierrors.IsNotFound(err) {
+		return rev, nil, nil
+	} else if err != nil {
+		return 0, nil, err
+	}
+	kv, err := s.toKV(obj, false)
+	return rev, kv, err
+}
+
+// getOther returns a copy of the key value of key outside the prefix, or nil if it doesn't exist. Keys outside the
+// prefix have no history, so at a revision rev other than 0 the key is only returned if it was last written at or
+// before rev.
+func (s *Server) getOther(ctx context.Context, key string, rev int64) (int64, *mvccpb.KeyValue, error) {
+	current, err := s.currentRevision(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	s.otherLock.Lock()
+	defer s.otherLock.Unlock()
+	kv, ok := s.other[key]
+	if !ok || (rev > 0 && kv.ModRevision > rev) {
+		return current, nil, nil
+	}
+	// Callers modify the key value they get, such as to drop the value of a keys only range
+	result := *kv
+	return current, &result, nil
+}
+
+// rangeNamespace returns the namespace to list for a range request. Only ranges within the prefix whose start is the
+// prefix itself, a namespace of the prefix, or a key continuing a previous page are supported.
+func (s *Server) rangeNamespace(key, end string) (string, error) {
+	prefixEnd := string(getPrefixEnd([]byte(s.prefix)))
+	if !strings.HasPrefix(key, s.prefix) || (end != prefixEnd && end != "\x00" && !strings.HasPrefix(end, s.prefix)) {
+		return "", status.Errorf(codes.Unimplemented, "range %q to %q is outside of prefix %q", key, end, s.prefix)
+	}
+	if !s.namespaced || end == prefixEnd || end == "\x00" {
+		return "", nil
+	}
+	// A range over a single namespace ends at <prefix><namespace>0
+	namespace := strings.TrimSuffix(strings.TrimPrefix(end, s.prefix), "0")
+	if strings.Contains(namespace, "/") {
+		return "", status.Errorf(codes.Unimplemented, "range %q to %q is not a namespace", key, end)
+	}
+	return namespace, nil
+}
+
+func (s *Server) Put(ctx context.Context, req *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	_, current, err := s.get(ctx, string(req.Key))
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	rev, err := s.put(ctx, string(req.Key), req.Value, current)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	resp := &etcdserverpb.PutResponse{Header: s.header(rev)}
+	if req.PrevKv {
+		resp.PrevKv = current
+	}
+	return resp, nil
+}
+
+// put writes value to key, which currently has the key value current, and returns the new revision.
+func (s *Server) put(ctx context.Context, key string, value []byte, current *mvccpb.KeyValue) (int64, error) {
+	namespace, name, ok := s.parseKey(key)
+	if !ok {
+		return s.putOther(ctx, key, value)
+	}
+
+	if bytes.HasPrefix(value, protobufPrefix) {
+		return 0, status.Errorf(codes.InvalidArgument, "value of %s is protobuf, but only JSON is supported: run "+
+			"kube-apiserver with --storage-media-type=application/json", key)
+	}
+	obj := s.strategy.New()
+	if err := json.Unmarshal(value, obj); err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "value of %s is not a valid object: %v", key, err)
+	}
+	if obj.GetName() != name || obj.GetNamespace() != namespace {
+		return 0, status.Errorf(codes.InvalidArgument, "object %s/%s doesn't match key %s", obj.GetNamespace(), obj.GetName(), key)
+	}
+
+	var (
+		result types.Object
+		err    error
+	)
+	if current == nil {
+		obj.SetResourceVersion("")
+		result, err = s.strategy.Create(ctx, obj)
+	} else {
+		obj.SetResourceVersion(strconv.FormatInt(current.ModRevision, 10))
+		result, err = s.strategy.Update(ctx, obj)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(result.GetResourceVersion(), 10, 64)
+}
+
+func (s *Server) putOther(ctx context.Context, key string, value []byte) (int64, error) {
+	rev, err := s.currentRevision(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	s.otherLock.Lock()
+	defer s.otherLock.Unlock()
+	kv := &mvccpb.KeyValue{
+		Key:            []byte(key),
+		Value:          value,
+		CreateRevision: rev,
+		ModRevision:    rev,
+		Version:        1,
+	}
+	if existing, ok := s.other[key]; ok {
+		kv.CreateRevision = existing.CreateRevision
+		kv.Version = existing.Version + 1
+	}
+	s.other[key] = kv
+	return rev, nil
+}
+
+func (s *Server) DeleteRange(ctx context.Context, req *etcdserverpb.DeleteRangeRequest) (*etcdserverpb.DeleteRangeResponse, error) {
+	if len(req.RangeEnd) > 0 {
+		return nil, status.Errorf(codes.Unimplemented, "deleting a range of keys is not supported")
+	}
+	rev, current, err := s.get(ctx, string(req.Key))
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	resp := &etcdserverpb.DeleteRangeResponse{Header: s.header(rev)}
+	if current == nil {
+		return resp, nil
+	}
+	if resp.Header.Revision, err = s.delete(ctx, string(req.Key), current); err != nil {
+		return nil, toGRPCError(err)
+	}
+	resp.Deleted = 1
+	if req.PrevKv {
+		resp.PrevKvs = []*mvccpb.KeyValue{current}
+	}
+	return resp, nil
+}
+
+// delete deletes key, which currently has the key value current, and returns the new revision.
+func (s *Server) delete(ctx context.Context, key string, current *mvccpb.KeyValue) (int64, error) {
+	if _, _, ok := s.parseKey(key); !ok {
+		s.otherLock.Lock()
+		delete(s.other, key)
+		s.otherLock.Unlock()
+		return s.currentRevision(ctx)
+	}
+
+	obj := s.strategy.New()
+	if err := json.Unmarshal(current.Value, obj); err != nil {
+		return 0, err
+	}
+	obj.SetResourceVersion(strconv.FormatInt(current.ModRevision, 10))
+	// etcd clients remove keys unconditionally, finalizers are handled by the client
+	obj.SetFinalizers(nil)
+	result, err := s.strategy.Delete(ctx, obj)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(result.GetResourceVersion(), 10, 64)
+}
+
+func (s *Server) Txn(ctx context.Context, req *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	var (
+		rev       int64
+		current   *mvccpb.KeyValue
+		key       string
+		err       error
+		succeeded = true
+	)
+	for _, cmp := range req.Compare {
+		if key == "" {
+			key = string(cmp.Key)
+			if rev, current, err = s.get(ctx, key); err != nil {
+				return nil, toGRPCError(err)
+			}
+		} else if key != string(cmp.Key) || len(cmp.RangeEnd) > 0 {
+			return nil, status.Errorf(codes.Unimplemented, "comparing more than one key is not supported")
+		}
+		if !compare(cmp, current) {
+			succeeded = false
+		}
+	}
+
+	ops := req.Success
+	if !succeeded {
+		ops = req.Failure
+	}
+	resp, ok, err := s.applyOps(ctx, rev, key, current, ops)
+	if err != nil {
+		return nil, err
+	}
+	if !ok && succeeded {
+		// The key changed after it was compared, so the comparison is failed
+		if rev, current, err = s.get(ctx, key); err != nil {
+			return nil, toGRPCError(err)
+		}
+		succeeded = false
+		if resp, _, err = s.applyOps(ctx, rev, key, current, req.Failure); err != nil {
+			return nil, err
+		}
+	}
+	resp.Succeeded = succeeded
+	return resp, nil
+}
+
+// applyOps applies the operations of a transaction. It returns false if a write conflicted with a concurrent change.
+func (s *Server) applyOps(ctx context.Context, rev int64, key string, current *mvccpb.KeyValue, ops []*etcdserverpb.RequestOp) (*etcdserverpb.TxnResponse, bool, error) {
+	resp := &etcdserverpb.TxnResponse{Header: s.header(rev)}
+	for _, op := range ops {
+		switch {
+		case op.GetRequestRange() != nil:
+			rangeResp, err := s.Range(ctx, op.GetRequestRange())
+			if err != nil {
+				return nil, false, err
+			}
+			resp.Responses = append(resp.Responses, &etcdserverpb.ResponseOp{
+				Response: &etcdserverpb.ResponseOp_ResponseRange{ResponseRange: rangeResp},
+			})
+		case op.GetRequestPut() != nil:
+			put := op.GetRequestPut()
+			if key != "" && string(put.Key) != key {
+				return nil, false, status.Errorf(codes.Unimplemented, "writing a key other than the compared key is not supported")
+			}
+			newRev, err := s.put(ctx, string(put.Key), put.Value, current)
+			if isConflict(err) {
+				return nil, false, nil
+			} else if err != nil {
+				return nil, false, toGRPCError(err)
+			}
+			resp.Header.Revision = newRev
+			putResp := &etcdserverpb.PutResponse{Header: s.header(newRev)}
+			if put.PrevKv {
+				putResp.PrevKv = current
+			}
+			resp.Responses = append(resp.Responses, &etcdserverpb.ResponseOp{
+				Response: &etcdserverpb.ResponseOp_ResponsePut{ResponsePut: putResp},
+			})
+		case op.GetRequestDeleteRange() != nil:
+			del := op.GetRequestDeleteRange()
+			if (key != "" && string(del.Key) != key) || len(del.RangeEnd) > 0 {
+				return nil, false, status.Errorf(codes.Unimplemented, "deleting a key other than the compared key is not supported")
+			}
+			delResp := &etcdserverpb.DeleteRangeResponse{Header: s.header(rev)}
+			if current != nil {
+				newRev, err := s.delete(ctx, string(del.Key), current)
+				if isConflict(err) {
+					return nil, false, nil
+				} else if err != nil {
+					return nil, false, toGRPCError(err)
+				}
+				resp.Header.Revision = newRev
+				delResp.Header.Revision = newRev
+				delResp.Deleted = 1
+				if del.PrevKv {
+					delResp.PrevKvs = []*mvccpb.KeyValue{current}
+				}
+			}
+			resp.Responses = append(resp.Responses, &etcdserverpb.ResponseOp{
+				Response: &etcdserverpb.ResponseOp_ResponseDeleteRange{ResponseDeleteRange: delResp},
+			})
+		default:
+			return nil, false, status.Errorf(codes.Unimplemented, "nested transactions are not supported")
+		}
+	}
+	return resp, true, nil
+}
+
+func (s *Server) Compact(ctx context.Context, req *etcdserverpb.CompactionRequest) (*etcdserverpb.CompactionResponse, error) {
+	// kinm compacts its tables itself
+	rev, err := s.currentRevision(ctx)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	return &etcdserverpb.CompactionResponse{Header: s.header(rev)}, nil
+}
+
+func compare(cmp *etcdserverpb.Compare, kv *mvccpb.KeyValue) bool {
+	var (
+		actual, expected int64
+		result           int
+	)
+	switch cmp.Target {
+	case etcdserverpb.Compare_VALUE:
+		var value []byte
+		if kv != nil {
+			value = kv.Value
+		}
+		result = strings.Compare(string(value), string(cmp.GetValue()))
+	default:
+		if kv != nil {
+			switch cmp.Target {
+			case etcdserverpb.Compare_VERSION:
+				actual = kv.Version
+			case etcdserverpb.Compare_CREATE:
+				actual = kv.CreateRevision
+			case etcdserverpb.Compare_MOD:
+				actual = kv.ModRevision
+			case etcdserverpb.Compare_LEASE:
+				actual = kv.Lease
+			}
+		}
+		switch cmp.Target {
+		case etcdserverpb.Compare_VERSION:
+			expected = cmp.GetVersion()
+		case etcdserverpb.Compare_CREATE:
+			expected = cmp.GetCreateRevision()
+		case etcdserverpb.Compare_MOD:
+			expected = cmp.GetModRevision()
+		case etcdserverpb.Compare_LEASE:
+			expected = cmp.GetLease()
+		}
+		switch {
+		case actual < expected:
+			result = -1
+		case actual > expected:
+			result = 1
+		}
+	}
+
+	switch cmp.Result {
+	case etcdserverpb.Compare_EQUAL:
+		return result == 0
+	case etcdserverpb.Compare_NOT_EQUAL:
+		return result != 0
+	case etcdserverpb.Compare_GREATER:
+		return result > 0
+	case etcdserverpb.Compare_LESS:
+		return result < 0
+	}
+	return false
+}
+
+func isConflict(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) || apierrors.IsNotFound(err)
+}
+
+func toGRPCError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case apierrors.IsResourceExpired(err) || apierrors.IsGone(err):
+		return status.Error(codes.OutOfRange, "etcdserver: mvcc: required revision has been compacted")
+	case storage.IsTooLargeResourceVersion(err):
+		return status.Error(codes.OutOfRange, "etcdserver: mvcc: required revision is a future revision")
+	case apierrors.IsBadRequest(err) || apierrors.IsInvalid(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case apierrors.IsServiceUnavailable(err):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, fmt.Sprintf("%v", err))
+}
+
+// getPrefixEnd returns the end of the range of keys with the given prefix, as computed by etcd clients.
+func getPrefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
@@ -0,0 +1,225 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/serializer/protobuf"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// sourcePageSize is the number of keys read from etcd per request.
+const sourcePageSize = 500
+
+var (
+	protobufPrefix  = []byte("k8s\x00")
+	encryptedPrefix = []byte("k8s:enc:")
+)
+
+// KeyValue is the current value of a key read from a Source.
+type KeyValue struct {
+	Key         string
+	Value       []byte
+	ModRevision int64
+}
+
+// Source reads the current keys of an existing store, so they can be imported into a kinm table.
+type Source interface {
+	// Range calls fn for every key with prefix, in ascending order of mod revision.
+	Range(ctx context.Context, prefix string, fn func(KeyValue) error) error
+}
+
+// NewEtcdSource reads keys from an etcd cluster, or anything else serving the etcd API such as kine. All keys are read
+// at the same revision.
+func NewEtcdSource(client *clientv3.Client) Source {
+	return &etcdSource{client: client}
+}
+
+type etcdSource struct {
+	client *clientv3.Client
+}
+
+func (e *etcdSource) Range(ctx context.Context, prefix string, fn func(KeyValue) error) error {
+	var (
+		kvs []KeyValue
+		rev int64
+		key = prefix
+		end = string(getPrefixEnd([]byte(prefix)))
+	)
+	for {
+		opts := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithLimit(sourcePageSize)}
+		if rev != 0 {
+			opts = append(opts, clientv3.WithRev(rev))
+		}
+		resp, err := e.client.Get(ctx, key, opts...)
+		if err != nil {
+			return err
+		}
+		rev = resp.Header.Revision
+		for _, kv := range resp.Kvs {
+			kvs = append(kvs, KeyValue{
+				Key:         string(kv.Key),
+				Value:       kv.Value,
+				ModRevision: kv.ModRevision,
+			})
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		key = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+
+	sort.SliceStable(kvs, func(i, j int) bool {
+		return kvs[i].ModRevision < kvs[j].ModRevision
+	})
+	for _, kv := range kvs {
+		if err := fn(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewKineSource reads keys directly from the table of a kine database, usually named "kine", without running kine.
+// The latest row of each key is used and deleted keys are skipped. Only sqlite and postgres databases are supported,
+// and the table name is used in the query as is.
+func NewKineSource(db *sql.DB, table string) Source {
+	return &kineSource{db: db, table: table}
+}
+
+type kineSource struct {
+	db    *sql.DB
+	table string
+}
+
+func (k *kineSource) Range(ctx context.Context, prefix string, fn func(KeyValue) error) error {
+	rows, err := k.db.QueryContext(ctx, fmt.Sprintf(`SELECT kv.id, kv.name, kv.value FROM %[1]s AS kv
+JOIN (SELECT MAX(mkv.id) AS id FROM %[1]s AS mkv WHERE mkv.name >= $1 AND mkv.name < $2 GROUP BY mkv.name) AS maxkv
+	ON maxkv.id = kv.id
+WHERE kv.deleted = 0
+ORDER BY kv.id`, k.table), prefix, string(getPrefixEnd([]byte(prefix))))
+	if err != nil {
+		return err
+	}
+
+	// Read every row before calling fn, since the kine table may be in the same database as the table being imported
+	// into, and sqlite only has a single connection
+	var kvs []KeyValue
+	for rows.Next() {
+		var kv KeyValue
+		if err := rows.Scan(&kv.ModRevision, &kv.Key, &kv.Value); err != nil {
+			rows.Close()
+			return err
+		}
+		kvs = append(kvs, kv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, kv := range kvs {
+		if err := fn(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportResult counts the keys handled by Import.
+type ImportResult struct {
+	Imported int
+	// Existing is the number of keys skipped because an object with the same name already exists in the table.
+	Existing int
+	// Skipped is the number of keys under the prefix that don't name an object, such as deeper keys.
+	Skipped int
+}
+
+// Import creates an object in the table of the server for every key under its prefix in src, for example to migrate
+// a resource out of the etcd of a kube-apiserver. Values must be JSON, or protobuf as written by kube-apiserver for
+// types that support it. Values encrypted at rest are not supported.
+//
+// Objects are created in the order of their mod revisions, so they are assigned new resourceVersions that keep the
+// order they had in the source. Clients must relist after a migration because resourceVersions of the source are not
+// meaningful to kinm. Objects keep their UIDs, but generations restart at 1 like for any created object. Objects that
+// already exist in the table are left unchanged, so an interrupted import can be run again.
+func (s *Server) Import(ctx context.Context, src Source) (ImportResult, error) {
+	var result ImportResult
+	err := src.Range(ctx, s.prefix, func(kv KeyValue) error {
+		namespace, name, ok := s.parseKey(kv.Key)
+		if !ok {
+			result.Skipped++
+			return nil
+		}
+
+		obj, err := s.decode(kv.Value)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s: %w", kv.Key, err)
+		}
+		if obj.GetNamespace() != namespace || obj.GetName() != name {
+			return fmt.Errorf("key %s holds object %s/%s", kv.Key, obj.GetNamespace(), obj.GetName())
+		}
+		obj.SetResourceVersion("")
+		if obj.GetUID() == "" {
+			obj.SetUID(uuid.NewUUID())
+		}
+
+		if _, err := s.strategy.Create(ctx, obj); apierrors.IsAlreadyExists(err) {
+			result.Existing++
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to import %s: %w", kv.Key, err)
+		}
+		result.Imported++
+		return nil
+	})
+	return result, err
+}
+
+// decode decodes a value written by kube-apiserver, kine or Export.
+func (s *Server) decode(value []byte) (types.Object, error) {
+	obj := s.strategy.New()
+	switch {
+	case bytes.HasPrefix(value, encryptedPrefix):
+		return nil, fmt.Errorf("value is encrypted at rest")
+	case bytes.HasPrefix(value, protobufPrefix):
+		serializer := protobuf.NewSerializer(s.strategy.Scheme(), s.strategy.Scheme())
+		if _, _, err := serializer.Decode(value, nil, obj); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+	return obj, json.Unmarshal(value, obj)
+}
+
+// Export writes every object of the table of the server to client under the prefix of the server, as JSON values that
+// kube-apiserver can read, and returns the number of objects written. Existing keys are overwritten. Like Import,
+// resourceVersions are not kept: each object gets the mod revision etcd assigns when it is written.
+func (s *Server) Export(ctx context.Context, client *clientv3.Client) (int, error) {
+	_, objs, err := s.list(ctx, "", 0)
+	if err != nil {
+		return 0, err
+	}
+	gvk := types.MustGetGVK(s.strategy.New(), s.strategy.Scheme())
+
+	var count int
+	for _, obj := range objs {
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		kv, err := s.toKV(obj, false)
+		if err != nil {
+			return count, err
+		}
+		if _, err := client.Put(ctx, string(kv.Key), string(kv.Value)); err != nil {
+			return count, fmt.Errorf("failed to export %s: %w", kv.Key, err)
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,258 @@
+package etcd
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+type watchStream struct {
+	server *Server
+	stream etcdserverpb.Watch_WatchServer
+
+	sendLock sync.Mutex
+
+	lock    sync.Mutex
+	nextID  int64
+	cancels map[int64]context.CancelFunc
+}
+
+func (s *Server) Watch(stream etcdserverpb.Watch_WatchServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	ws := &watchStream{
+		server:  s,
+		stream:  stream,
+		cancels: map[int64]context.CancelFunc{},
+	}
+	defer ws.cancelAll()
+
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		switch {
+		case req.GetCreateRequest() != nil:
+			if err := ws.create(ctx, req.GetCreateRequest()); err != nil {
+				return err
+			}
+		case req.GetCancelRequest() != nil:
+			if err := ws.cancel(req.GetCancelRequest().WatchId, ""); err != nil {
+				return err
+			}
+		}
+		// Progress requests are not supported, clients fall back to waiting for events
+	}
+}
+
+func (ws *watchStream) send(resp *etcdserverpb.WatchResponse) error {
+	ws.sendLock.Lock()
+	defer ws.sendLock.Unlock()
+	return ws.stream.Send(resp)
+}
+
+func (ws *watchStream) cancelAll() {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	for _, cancel := range ws.cancels {
+		cancel()
+	}
+}
+
+// cancel stops watch id and tells the client.
+func (ws *watchStream) cancel(id int64, reason string) error {
+	ws.lock.Lock()
+	cancel, ok := ws.cancels[id]
+	delete(ws.cancels, id)
+	ws.lock.Unlock()
+	if !ok {
+		return nil
+	}
+	cancel()
+
+	rev, _ := ws.server.currentRevision(ws.stream.Context())
+	return ws.send(&etcdserverpb.WatchResponse{
+		Header:       ws.server.header(rev),
+		WatchId:      id,
+		Canceled:     true,
+		CancelReason: reason,
+	})
+}
+
+func (ws *watchStream) create(ctx context.Context, req *etcdserverpb.WatchCreateRequest) error {
+	ws.lock.Lock()
+	id := req.WatchId
+	if id == 0 {
+		ws.nextID++
+		id = ws.nextID
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	ws.cancels[id] = cancel
+	ws.lock.Unlock()
+
+	rev, events, err := ws.server.watch(ctx, req)
+	if err != nil {
+		cancel()
+		ws.lock.Lock()
+		delete(ws.cancels, id)
+		ws.lock.Unlock()
+		return ws.send(&etcdserverpb.WatchResponse{
+			Header:       ws.server.header(rev),
+			WatchId:      id,
+			Created:      true,
+			Canceled:     true,
+			CancelReason: err.Error(),
+		})
+	}
+
+	if err := ws.send(&etcdserverpb.WatchResponse{
+		Header:  ws.server.header(rev),
+		WatchId: id,
+		Created: true,
+	}); err != nil {
+		cancel()
+		return err
+	}
+
+	go ws.streamEvents(ctx, id, req, events)
+	return nil
+}
+
+// streamEvents sends the events of a watch until it is canceled or the underlying watch ends.
+func (ws *watchStream) streamEvents(ctx context.Context, id int64, req *etcdserverpb.WatchCreateRequest, events <-chan watch.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				_ = ws.cancel(id, "watch closed")
+				return
+			}
+			resp, err := ws.server.toWatchResponse(id, req, event)
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				_ = ws.send(&etcdserverpb.WatchResponse{
+					Header:          resp.GetHeader(),
+					WatchId:         id,
+					Canceled:        true,
+					CompactRevision: req.StartRevision,
+					CancelReason:    err.Error(),
+				})
+				ws.lock.Lock()
+				delete(ws.cancels, id)
+				ws.lock.Unlock()
+				return
+			} else if err != nil {
+				_ = ws.cancel(id, err.Error())
+				return
+			}
+			if resp == nil {
+				continue
+			}
+			if err := ws.send(resp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// watch starts a strategy watch for req and returns the revision it starts after.
+func (s *Server) watch(ctx context.Context, req *etcdserverpb.WatchCreateRequest) (int64, <-chan watch.Event, error) {
+	var (
+		opts storage.ListOptions
+		rev  = req.StartRevision - 1
+		err  error
+	)
+	if req.StartRevision <= 0 {
+		if rev, err = s.currentRevision(ctx); err != nil {
+			return 0, nil, err
+		}
+	}
+	opts.ResourceVersion = strconv.FormatInt(rev, 10)
+
+	namespace := ""
+	if len(req.RangeEnd) == 0 {
+		var (
+			name string
+			ok   bool
+		)
+		namespace, name, ok = s.parseKey(string(req.Key))
+		if !ok {
+			return rev, nil, apierrors.NewBadRequest("watching keys outside of " + s.prefix + " is not supported")
+		}
+		opts.Predicate.Field = fields.OneTermEqualSelector("metadata.name", name)
+	} else if namespace, err = s.rangeNamespace(string(req.Key), string(req.RangeEnd)); err != nil {
+		return rev, nil, err
+	}
+
+	events, err := s.strategy.Watch(ctx, namespace, opts)
+	return rev, events, err
+}
+
+// toWatchResponse converts a strategy watch event. It returns nil for events that etcd clients don't expect.
+func (s *Server) toWatchResponse(id int64, req *etcdserverpb.WatchCreateRequest, event watch.Event) (*etcdserverpb.WatchResponse, error) {
+	if event.Type == watch.Error {
+		if status, ok := event.Object.(*metav1.Status); ok {
+			return nil, apierrors.FromObject(status)
+		}
+		return nil, apierrors.NewInternalError(nil)
+	}
+	if event.Type == watch.Bookmark {
+		return nil, nil
+	}
+
+	obj, ok := event.Object.(types.Object)
+	if !ok {
+		return nil, nil
+	}
+	key := s.keyFor(obj)
+	if string(req.Key) > key || (len(req.RangeEnd) == 0 && string(req.Key) != key) ||
+		(len(req.RangeEnd) > 0 && string(req.RangeEnd) != "\x00" && key >= string(req.RangeEnd)) {
+		return nil, nil
+	}
+
+	kv, err := s.toKV(obj, false)
+	if err != nil {
+		return nil, err
+	}
+
+	etcdEvent := &mvccpb.Event{
+		Type: mvccpb.PUT,
+		Kv:   kv,
+	}
+	if event.Type == watch.Deleted {
+		etcdEvent.Type = mvccpb.DELETE
+		etcdEvent.Kv = &mvccpb.KeyValue{
+			Key:         kv.Key,
+			ModRevision: kv.ModRevision,
+		}
+		etcdEvent.PrevKv = kv
+	}
+	if !req.PrevKv {
+		etcdEvent.PrevKv = nil
+	}
+
+	for _, filter := range req.Filters {
+		if (filter == etcdserverpb.WatchCreateRequest_NOPUT && etcdEvent.Type == mvccpb.PUT) ||
+			(filter == etcdserverpb.WatchCreateRequest_NODELETE && etcdEvent.Type == mvccpb.DELETE) {
+			return nil, nil
+		}
+	}
+
+	return &etcdserverpb.WatchResponse{
+		Header:  s.header(kv.ModRevision),
+		WatchId: id,
+		Events:  []*mvccpb.Event{etcdEvent},
+	}, nil
+}
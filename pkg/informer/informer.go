@@ -0,0 +1,72 @@
+// Package informer builds client-go informers on top of strategies so that controllers running in the same process
+// can use the list and watch machinery of client-go without going through the API server.
+package informer
+
+import (
+	"context"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ListerWatcher is the part of a strategy that informers need.
+type ListerWatcher interface {
+	strategy.Lister
+	strategy.Watcher
+}
+
+// NewListWatch returns a cache.ListWatch for the objects of s in namespace, or all namespaces if namespace is empty.
+// tweak, if set, can change the options of every list and watch, typically to add selectors.
+//
+// A watch that starts from a compacted resource version fails with a 410 error, which makes the reflector relist.
+func NewListWatch(s ListerWatcher, namespace string, tweak func(*metav1.ListOptions)) *cache.ListWatch {
+	lister := strategy.NewList(s)
+	watcher := strategy.NewWatch(s)
+	ctx := request.WithNamespace(context.Background(), namespace)
+
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			opts, err := toInternalListOptions(options, tweak)
+			if err != nil {
+				return nil, err
+			}
+			return lister.List(ctx, opts)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			opts, err := toInternalListOptions(options, tweak)
+			if err != nil {
+				return nil, err
+			}
+			// Watches don't page
+			opts.Limit = 0
+			opts.Continue = ""
+			return watcher.Watch(ctx, opts)
+		},
+	}
+}
+
+func toInternalListOptions(options metav1.ListOptions, tweak func(*metav1.ListOptions)) (*metainternalversion.ListOptions, error) {
+	if tweak != nil {
+		tweak(&options)
+	}
+	// Strategies serve the exact resource version, which satisfies every match the reflector asks for
+	options.ResourceVersionMatch = ""
+
+	var result metainternalversion.ListOptions
+	if err := metainternalversion.Convert_v1_ListOptions_To_internalversion_ListOptions(&options, &result, nil); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// NewSharedIndexInformer returns an informer for the objects of s in namespace, or all namespaces if namespace is
+// empty. The informer must be started with Run.
+func NewSharedIndexInformer(s ListerWatcher, namespace string, resync time.Duration, indexers cache.Indexers, tweak func(*metav1.ListOptions)) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(NewListWatch(s, namespace, tweak), s.New(), resync, indexers)
+}
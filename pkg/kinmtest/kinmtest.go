@@ -0,0 +1,172 @@
+// Package kinmtest creates isolated kinm databases for tests, so that projects embedding kinm can test their
+// strategies against a real database without sharing files or credentials between tests.
+//
+// By default every test gets its own sqlite file in a temporary directory. With WithPostgres, or when $KINM_TEST_DB
+// is "postgres", every test gets its own schema in a Postgres database: the one at $KINM_TEST_POSTGRES_DSN if set,
+// otherwise a container started with the docker CLI for the test. Tests are skipped if Postgres is requested but
+// neither is available.
+package kinmtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/db"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// PostgresImage is the image of the Postgres container started when $KINM_TEST_POSTGRES_DSN is not set.
+var PostgresImage = "postgres:16-alpine"
+
+type options struct {
+	postgres       bool
+	factoryOptions []db.FactoryOption
+}
+
+// Option configures NewTestFactory.
+type Option func(*options)
+
+// WithPostgres uses Postgres instead of sqlite.
+func WithPostgres() Option {
+	return func(o *options) {
+		o.postgres = true
+	}
+}
+
+// WithFactoryOptions passes opts to db.NewFactory.
+func WithFactoryOptions(opts ...db.FactoryOption) Option {
+	return func(o *options) {
+		o.factoryOptions = append(o.factoryOptions, opts...)
+	}
+}
+
+// NewTestFactory returns a factory for an empty database. The factory is closed and the database removed when the test
+// ends.
+func NewTestFactory(t testing.TB, scheme *runtime.Scheme, opts ...Option) *db.Factory {
+	t.Helper()
+
+	o := options{
+		postgres: os.Getenv("KINM_TEST_DB") == "postgres",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "kinm.db")
+	factoryOptions := o.factoryOptions
+	if o.postgres {
+		var schema string
+		dsn, schema = postgresDSN(t)
+		factoryOptions = append([]db.FactoryOption{db.WithSchema(schema), db.WithWaitForReady(time.Minute)}, factoryOptions...)
+	}
+
+	f, err := db.NewFactory(scheme, dsn, factoryOptions...)
+	if err != nil {
+		t.Fatalf("failed to create factory: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = f.Close()
+	})
+	return f
+}
+
+// NewStrategy creates the strategy for obj with f, failing the test on error. The strategy is destroyed when the test
+// ends.
+func NewStrategy(t testing.TB, f *db.Factory, obj types.Object, opts ...db.Option) strategy.CompleteStrategy {
+	t.Helper()
+
+	s, err := f.NewDBStrategy(obj, opts...)
+	if err != nil {
+		t.Fatalf("failed to create strategy: %v", err)
+	}
+	t.Cleanup(s.Destroy)
+	return s
+}
+
+// postgresDSN returns the DSN of a Postgres database and a schema that is dropped when the test ends.
+func postgresDSN(t testing.TB) (string, string) {
+	t.Helper()
+
+	dsn := os.Getenv("KINM_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		dsn = startPostgres(t)
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	schema := "kinmtest_" + hex.EncodeToString(suffix)
+
+	t.Cleanup(func() {
+		f, err := db.NewFactory(runtime.NewScheme(), dsn)
+		if err != nil {
+			t.Logf("failed to drop schema %s: %v", schema, err)
+			return
+		}
+		defer f.Close()
+		if _, err := f.SQLDB.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, schema)); err != nil {
+			t.Logf("failed to drop schema %s: %v", schema, err)
+		}
+	})
+	return dsn, schema
+}
+
+// startPostgres starts a Postgres container that is removed when the test ends and returns its DSN. The test is
+// skipped if docker isn't available.
+func startPostgres(t testing.TB) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("Postgres requested but $KINM_TEST_POSTGRES_DSN is not set and docker is not available")
+	}
+
+	id, err := docker(t, "run", "--detach", "--rm",
+		"--env", "POSTGRES_USER=kinm",
+		"--env", "POSTGRES_PASSWORD=kinm",
+		"--env", "POSTGRES_DB=kinm",
+		"--publish", "127.0.0.1::5432",
+		PostgresImage)
+	if err != nil {
+		t.Skipf("Postgres requested but its container could not be started: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := docker(t, "rm", "--force", id); err != nil {
+			t.Logf("failed to remove container %s: %v", id, err)
+		}
+	})
+
+	address, err := docker(t, "port", id, "5432/tcp")
+	if err != nil {
+		t.Fatalf("failed to get the port of container %s: %v", id, err)
+	}
+	// docker port prints an address per line, the first one is the IPv4 binding
+	address, _, _ = strings.Cut(address, "\n")
+	return fmt.Sprintf("postgres://kinm:kinm@%s/kinm?sslmode=disable", address)
+}
+
+func docker(t testing.TB, args ...string) (string, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,58 @@
+package kinmtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/obot-platform/kinm/pkg/db"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/strategy/conformance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNewTestFactory(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	// Each factory has its own database
+	for range 2 {
+		f := NewTestFactory(t, scheme)
+		s := NewStrategy(t, f, &corev1.ConfigMap{})
+
+		obj, err := s.Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "1", obj.GetResourceVersion())
+	}
+}
+
+func TestConformance(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	factories := map[strategy.CompleteStrategy]*db.Factory{}
+	conformance.Run(t, conformance.Backend{
+		New: func(t *testing.T) strategy.CompleteStrategy {
+			f := NewTestFactory(t, scheme)
+			s := NewStrategy(t, f, &corev1.ConfigMap{}, db.WithInvariantChecks(func(err error) {
+				t.Error(err)
+			}))
+			factories[s] = f
+			return s
+		},
+		Compact: func(t *testing.T, s strategy.CompleteStrategy) {
+			admin := factories[s].Admin()
+			tables, err := admin.Tables(context.Background())
+			require.NoError(t, err)
+			for _, table := range tables {
+				_, err := admin.Compact(context.Background(), table.Name)
+				require.NoError(t, err)
+			}
+		},
+	})
+}
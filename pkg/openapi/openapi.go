@@ -0,0 +1,323 @@
+// Package openapi publishes the OpenAPI schemas of the kinds served by kinm, so that the apiserver serves them under
+// /openapi/v2 and /openapi/v3 and kubectl explain and client-side validation work against an embedded apiserver.
+//
+// Schemas are either registered per kind or derived from the Go types of the kind by reflection. Definitions are
+// passed to the server as its OpenAPIConfig:
+//
+//	defs := openapi.NewDefinitions(scheme)
+//	_ = defs.Reflect(v1.SchemeGroupVersion.WithKind("Widget"))
+//	server.New(&server.Config{OpenAPIConfig: defs.GetOpenAPIDefinitions, ...})
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/kube-openapi/pkg/common"
+	"k8s.io/kube-openapi/pkg/util"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// metaTypes are the types of the apiserver that every API group refers to, such as in the responses of its routes,
+// and the types of the objects of dynamic kinds.
+var metaTypes = []any{
+	metav1.APIGroup{},
+	metav1.APIGroupList{},
+	metav1.APIResourceList{},
+	metav1.APIVersions{},
+	metav1.DeleteOptions{},
+	metav1.ListMeta{},
+	metav1.ObjectMeta{},
+	metav1.Patch{},
+	metav1.Status{},
+	metav1.WatchEvent{},
+	unstructured.Unstructured{},
+	unstructured.UnstructuredList{},
+	version.Info{},
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	objectMeta    = util.GetCanonicalTypeName(metav1.ObjectMeta{})
+)
+
+// Definitions are the OpenAPI definitions of the kinds of a scheme.
+//
+// Definitions derived by reflection have a property for every field that is marshaled to JSON, named after its json
+// tag, and no descriptions or required fields. Fields whose types marshal themselves to JSON, such as
+// runtime.RawExtension, accept any value, unless the type declares its OpenAPI type with an OpenAPISchemaType method,
+// as metav1.Time and resource.Quantity do.
+type Definitions struct {
+	scheme *runtime.Scheme
+	base   []common.GetOpenAPIDefinitions
+	// types are the Go types whose definitions are derived by reflection, by definition name
+	types map[string]reflect.Type
+	// schemas are the registered schemas, by definition name
+	schemas map[string]spec.Schema
+}
+
+// NewDefinitions returns the definitions of the kinds of scheme, which also include the definitions of base, such as
+// the definitions generated by openapi-gen for other types. Registered and generated definitions take precedence over
+// the ones derived by reflection.
+func NewDefinitions(scheme *runtime.Scheme, base ...common.GetOpenAPIDefinitions) *Definitions {
+	d := &Definitions{
+		scheme:  scheme,
+		base:    base,
+		types:   map[string]reflect.Type{},
+		schemas: map[string]spec.Schema{},
+	}
+	for _, obj := range metaTypes {
+		d.types[util.GetCanonicalTypeName(obj)] = reflect.TypeOf(obj)
+	}
+	return d
+}
+
+// Add registers schema as the schema of the kind gvk, which must be in the scheme. The apiVersion, kind and metadata
+// properties are added to the schema unless it has them. The schema of the list of the kind is derived by reflection.
+func (d *Definitions) Add(gvk schema.GroupVersionKind, schema spec.Schema) error {
+	obj, err := d.scheme.New(gvk)
+	if err != nil {
+		return err
+	}
+	if err := d.reflectList(gvk); err != nil {
+		return err
+	}
+	d.schemas[util.GetCanonicalTypeName(obj)] = schema
+	return nil
+}
+
+// Reflect derives the schemas of the kinds gvks, which must be in the scheme, and of their lists from their Go types.
+func (d *Definitions) Reflect(gvks ...schema.GroupVersionKind) error {
+	for _, gvk := range gvks {
+		obj, err := d.scheme.New(gvk)
+		if err != nil {
+			return err
+		}
+		d.types[util.GetCanonicalTypeName(obj)] = reflect.TypeOf(obj).Elem()
+		if err := d.reflectList(gvk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reflectList derives the schema of the list of gvk if the scheme has one.
+func (d *Definitions) reflectList(gvk schema.GroupVersionKind) error {
+	list, err := d.scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if runtime.IsNotRegisteredError(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	d.types[util.GetCanonicalTypeName(list)] = reflect.TypeOf(list).Elem()
+	return nil
+}
+
+// GetOpenAPIDefinitions returns the definitions, by the name of their Go type. It is a common.GetOpenAPIDefinitions
+// for the OpenAPIConfig of the server.
+func (d *Definitions) GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+	result := map[string]common.OpenAPIDefinition{}
+	r := reflector{
+		ref:         ref,
+		definitions: result,
+	}
+	for _, t := range d.types {
+		r.definition(t)
+	}
+	for _, base := range d.base {
+		for name, def := range base(ref) {
+			result[name] = def
+		}
+	}
+	for name, s := range d.schemas {
+		result[name] = withObjectProperties(s, ref)
+	}
+	return result
+}
+
+// withObjectProperties returns the definition of the registered schema s of a kind, with the apiVersion, kind and
+// metadata properties that it doesn't have.
+func withObjectProperties(s spec.Schema, ref common.ReferenceCallback) common.OpenAPIDefinition {
+	properties := map[string]spec.Schema{}
+	for name, property := range s.Properties {
+		properties[name] = property
+	}
+	if _, ok := properties["apiVersion"]; !ok {
+		properties["apiVersion"] = *spec.StringProperty()
+	}
+	if _, ok := properties["kind"]; !ok {
+		properties["kind"] = *spec.StringProperty()
+	}
+	if _, ok := properties["metadata"]; !ok {
+		properties["metadata"] = spec.Schema{SchemaProps: spec.SchemaProps{Ref: ref(objectMeta)}}
+	}
+	s.Properties = properties
+	if len(s.Type) == 0 {
+		s.Type = []string{"object"}
+	}
+	return common.OpenAPIDefinition{
+		Schema:       s,
+		Dependencies: []string{objectMeta},
+	}
+}
+
+// reflector derives definitions from Go types.
+type reflector struct {
+	ref         common.ReferenceCallback
+	definitions map[string]common.OpenAPIDefinition
+}
+
+// definition adds the definition of the struct type t and of the types it refers to, and returns its name.
+func (r *reflector) definition(t reflect.Type) string {
+	name := util.GetCanonicalTypeName(reflect.New(t).Interface())
+	if _, ok := r.definitions[name]; ok {
+		return name
+	}
+	// The name is reserved first, so that recursive types refer to it
+	r.definitions[name] = common.OpenAPIDefinition{}
+
+	deps := map[string]bool{}
+	var s spec.Schema
+	if reflect.PointerTo(t).Implements(jsonMarshaler) {
+		// The fields of types that marshal themselves, such as unstructured.Unstructured, don't describe their JSON
+		s = anySchema()
+		s.Type = []string{"object"}
+	} else {
+		s = r.object(t, deps)
+	}
+	def := common.OpenAPIDefinition{
+		Schema: s,
+	}
+	for dep := range deps {
+		def.Dependencies = append(def.Dependencies, dep)
+	}
+	sort.Strings(def.Dependencies)
+	r.definitions[name] = def
+	return name
+}
+
+// object returns the schema of the struct type t and adds the names of the definitions it refers to to deps.
+func (r *reflector) object(t reflect.Type, deps map[string]bool) spec.Schema {
+	s := spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type:       []string{"object"},
+			Properties: map[string]spec.Schema{},
+		},
+	}
+	r.properties(t, &s, deps)
+	return s
+}
+
+// properties adds the fields of the struct type t to s, including the fields of embedded and inlined structs.
+func (r *reflector) properties(t reflect.Type, s *spec.Schema, deps map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		options := strings.Split(opts, ",")
+
+		if (f.Anonymous && name == "") || slices.Contains(options, "inline") {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.properties(ft, s, deps)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if slices.Contains(options, "string") {
+			s.Properties[name] = *spec.StringProperty()
+		} else {
+			s.Properties[name] = r.schema(f.Type, deps)
+		}
+	}
+}
+
+// schema returns the schema of a value of type t.
+func (r *reflector) schema(t reflect.Type, deps map[string]bool) spec.Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	v := reflect.New(t).Interface()
+	if typer, ok := v.(interface{ OpenAPISchemaType() []string }); ok {
+		s := spec.Schema{SchemaProps: spec.SchemaProps{Type: typer.OpenAPISchemaType()}}
+		if formatter, ok := v.(interface{ OpenAPISchemaFormat() string }); ok {
+			s.Format = formatter.OpenAPISchemaFormat()
+		}
+		return s
+	}
+	if t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler) {
+		return anySchema()
+	}
+	if t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler) {
+		return *spec.StringProperty()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return *spec.BooleanProperty()
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return *spec.Int32Property()
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return *spec.Int64Property()
+	case reflect.Float32:
+		return *spec.Float32Property()
+	case reflect.Float64:
+		return *spec.Float64Property()
+	case reflect.String:
+		return *spec.StringProperty()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are marshaled as base64 strings
+			return *spec.StrFmtProperty("byte")
+		}
+		return *spec.ArrayProperty(ptr(r.schema(t.Elem(), deps)))
+	case reflect.Map:
+		return *spec.MapProperty(ptr(r.schema(t.Elem(), deps)))
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.object(t, deps)
+		}
+		name := r.definition(t)
+		deps[name] = true
+		return spec.Schema{SchemaProps: spec.SchemaProps{Ref: r.ref(name)}}
+	default:
+		return anySchema()
+	}
+}
+
+// anySchema is the schema of a value of any type.
+func anySchema() spec.Schema {
+	return spec.Schema{
+		VendorExtensible: spec.VendorExtensible{
+			Extensions: spec.Extensions{
+				"x-kubernetes-preserve-unknown-fields": true,
+			},
+		},
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
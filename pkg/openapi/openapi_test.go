@@ -0,0 +1,133 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	endpointsopenapi "k8s.io/apiserver/pkg/endpoints/openapi"
+	"k8s.io/apiserver/pkg/server"
+	"k8s.io/kube-openapi/pkg/builder3"
+	"k8s.io/kube-openapi/pkg/util"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+var testGV = schema.GroupVersion{Group: "test.kinm.io", Version: "v1"}
+
+type Widget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WidgetSpec `json:"spec,omitempty"`
+	Status struct {
+		Ready bool `json:"ready,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+type WidgetSpec struct {
+	Size     int32                `json:"size,omitempty"`
+	Labels   map[string]string    `json:"labels,omitempty"`
+	Parts    []*WidgetSpec        `json:"parts,omitempty"`
+	Deadline *metav1.Time         `json:"deadline,omitempty"`
+	Raw      []byte               `json:"raw,omitempty"`
+	Extra    runtime.RawExtension `json:"extra,omitempty"`
+	internal string
+}
+
+func (w *Widget) DeepCopyObject() runtime.Object {
+	c := *w
+	return &c
+}
+
+type WidgetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Widget `json:"items"`
+}
+
+func (w *WidgetList) DeepCopyObject() runtime.Object {
+	c := *w
+	return &c
+}
+
+type Gadget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
+
+func (g *Gadget) DeepCopyObject() runtime.Object {
+	c := *g
+	return &c
+}
+
+func TestDefinitions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(testGV, &Widget{}, &WidgetList{}, &Gadget{})
+	metav1.AddToGroupVersion(scheme, testGV)
+
+	defs := NewDefinitions(scheme)
+	require.NoError(t, defs.Reflect(testGV.WithKind("Widget")))
+	gadget := spec.Schema{}
+	gadget.SetProperty("color", *spec.StringProperty().WithDescription("The color of the gadget."))
+	require.NoError(t, defs.Add(testGV.WithKind("Gadget"), gadget))
+	assert.Error(t, defs.Reflect(testGV.WithKind("Unknown")))
+
+	config := server.DefaultOpenAPIV3Config(defs.GetOpenAPIDefinitions, endpointsopenapi.NewDefinitionNamer(scheme))
+	schemas, err := builder3.BuildOpenAPIDefinitionsForResources(config,
+		util.GetCanonicalTypeName(&Widget{}),
+		util.GetCanonicalTypeName(&WidgetList{}),
+		util.GetCanonicalTypeName(&Gadget{}),
+		util.GetCanonicalTypeName(&metav1.Status{}),
+		util.GetCanonicalTypeName(&metav1.WatchEvent{}))
+	require.NoError(t, err)
+
+	widget := schemas["com.github.obot-platform.kinm.pkg.openapi.Widget"]
+	require.NotNil(t, widget)
+	assert.Equal(t, []any{map[string]any{"group": "test.kinm.io", "version": "v1", "kind": "Widget"}},
+		widget.Extensions["x-kubernetes-group-version-kind"])
+	assert.ElementsMatch(t, []string{"apiVersion", "kind", "metadata", "spec", "status"}, keys(widget.Properties))
+	assert.Equal(t, "#/components/schemas/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta", refOf(widget.Properties["metadata"]))
+	assert.Equal(t, spec.StringOrArray{"boolean"}, widget.Properties["status"].Properties["ready"].Type)
+
+	widgetSpec := schemas["com.github.obot-platform.kinm.pkg.openapi.WidgetSpec"]
+	require.NotNil(t, widgetSpec)
+	assert.ElementsMatch(t, []string{"size", "labels", "parts", "deadline", "raw", "extra"}, keys(widgetSpec.Properties))
+	assert.Equal(t, "int32", widgetSpec.Properties["size"].Format)
+	assert.Equal(t, spec.StringOrArray{"string"}, widgetSpec.Properties["labels"].AdditionalProperties.Schema.Type)
+	assert.Equal(t, "#/components/schemas/com.github.obot-platform.kinm.pkg.openapi.WidgetSpec",
+		refOf(*widgetSpec.Properties["parts"].Items.Schema))
+	assert.Equal(t, "date-time", widgetSpec.Properties["deadline"].Format)
+	assert.Equal(t, "byte", widgetSpec.Properties["raw"].Format)
+	assert.Equal(t, true, widgetSpec.Properties["extra"].Extensions["x-kubernetes-preserve-unknown-fields"])
+
+	list := schemas["com.github.obot-platform.kinm.pkg.openapi.WidgetList"]
+	require.NotNil(t, list)
+	assert.Equal(t, "#/components/schemas/com.github.obot-platform.kinm.pkg.openapi.Widget",
+		refOf(*list.Properties["items"].Items.Schema))
+
+	g := schemas["com.github.obot-platform.kinm.pkg.openapi.Gadget"]
+	require.NotNil(t, g)
+	assert.ElementsMatch(t, []string{"apiVersion", "kind", "metadata", "color"}, keys(g.Properties))
+	assert.Equal(t, "The color of the gadget.", g.Properties["color"].Description)
+	assert.Equal(t, "#/components/schemas/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta", refOf(g.Properties["metadata"]))
+}
+
+func keys(m map[string]spec.Schema) []string {
+	var result []string
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
+
+// refOf returns the reference of s, which the builder may wrap in allOf to add a default.
+func refOf(s spec.Schema) string {
+	if len(s.AllOf) == 1 {
+		s = s.AllOf[0]
+	}
+	return s.Ref.String()
+}
@@ -0,0 +1,348 @@
+// Package standalone serves strategies over Kubernetes-style REST paths without the k8s.io/apiserver machinery, for
+// deployments that only need basic CRUD and watch over HTTP.
+//
+// The paths are /apis/<group>/<version>[/namespaces/<namespace>]/<resource>[/<name>[/status]], or /api/v1/... for the
+// core group. GET gets, lists or, with ?watch=true, watches; POST creates; PUT updates; PATCH applies JSON, merge or
+// strategic merge patches; DELETE deletes. Requests go through pkg/client, so there is no authentication,
+// authorization, admission, discovery or content negotiation beyond JSON.
+package standalone
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/client"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// maxRequestBytes matches the request size limit of the apiserver.
+const maxRequestBytes = 3 * 1024 * 1024
+
+type resource struct {
+	gvk      schema.GroupVersionKind
+	strategy strategy.CompleteStrategy
+}
+
+type Handler struct {
+	scheme     *runtime.Scheme
+	strategies client.Strategies
+	client     *client.Client
+	resources  map[schema.GroupVersionResource]resource
+}
+
+func NewHandler(scheme *runtime.Scheme) *Handler {
+	strategies := client.Strategies{}
+	return &Handler{
+		scheme:     scheme,
+		strategies: strategies,
+		client:     client.New(scheme, strategies),
+		resources:  map[schema.GroupVersionResource]resource{},
+	}
+}
+
+// Add serves the objects of s under the given plural resource name. It must be called before the handler serves
+// requests.
+func (h *Handler) Add(resourceName string, s strategy.CompleteStrategy) error {
+	gvk, err := apiutil.GVKForObject(s.New(), h.scheme)
+	if err != nil {
+		return err
+	}
+	h.strategies[gvk] = s
+	h.resources[gvk.GroupVersion().WithResource(resourceName)] = resource{
+		gvk:      gvk,
+		strategy: s,
+	}
+	return nil
+}
+
+type request struct {
+	resource
+	namespace   string
+	name        string
+	subresource string
+}
+
+// parsePath parses /apis/<group>/<version>[/namespaces/<namespace>]/<resource>[/<name>[/<subresource>]]. A
+// namespace is only parsed if "namespaces" isn't the name of a served resource.
+func (h *Handler) parsePath(path string) (request, error) {
+	var (
+		result request
+		gv     schema.GroupVersion
+		parts  = strings.Split(strings.Trim(path, "/"), "/")
+	)
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		gv, parts = schema.GroupVersion{Version: parts[1]}, parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		gv, parts = schema.GroupVersion{Group: parts[1], Version: parts[2]}, parts[3:]
+	default:
+		return result, apierrors.NewNotFound(schema.GroupResource{}, path)
+	}
+
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		if _, ok := h.resources[gv.WithResource("namespaces")]; !ok {
+			result.namespace, parts = parts[1], parts[2:]
+		}
+	}
+
+	res, ok := h.resources[gv.WithResource(parts[0])]
+	if !ok || len(parts) > 3 {
+		return result, apierrors.NewNotFound(schema.GroupResource{}, path)
+	}
+	result.resource = res
+	if len(parts) > 1 {
+		result.name = parts[1]
+	}
+	if len(parts) > 2 {
+		result.subresource = parts[2]
+		if result.subresource != "status" {
+			return result, apierrors.NewNotFound(schema.GroupResource{}, path)
+		}
+	}
+	return result, nil
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	req, err := h.parsePath(r.URL.Path)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	r.Body = http.MaxBytesReader(rw, r.Body, maxRequestBytes)
+
+	switch {
+	case r.Method == http.MethodGet && req.name == "" && isWatch(r):
+		err = h.watch(rw, r, req)
+	case r.Method == http.MethodGet && req.name == "":
+		err = h.list(rw, r, req)
+	case r.Method == http.MethodGet:
+		err = h.get(rw, r, req)
+	case r.Method == http.MethodPost && req.name == "":
+		err = h.create(rw, r, req)
+	case r.Method == http.MethodPut && req.name != "":
+		err = h.update(rw, r, req)
+	case r.Method == http.MethodPatch && req.name != "":
+		err = h.patch(rw, r, req)
+	case r.Method == http.MethodDelete && req.name != "" && req.subresource == "":
+		err = h.delete(rw, r, req)
+	default:
+		err = apierrors.NewMethodNotSupported(schema.GroupResource{Group: req.gvk.Group, Resource: req.gvk.Kind}, r.Method)
+	}
+	if err != nil {
+		writeError(rw, err)
+	}
+}
+
+func isWatch(r *http.Request) bool {
+	watch, _ := strconv.ParseBool(r.URL.Query().Get("watch"))
+	return watch
+}
+
+func (h *Handler) get(rw http.ResponseWriter, r *http.Request, req request) error {
+	obj := req.strategy.New()
+	if err := h.client.Get(r.Context(), kclient.ObjectKey{Namespace: req.namespace, Name: req.name}, obj); err != nil {
+		return err
+	}
+	return writeObject(rw, http.StatusOK, req.gvk, obj)
+}
+
+func listOptions(r *http.Request, namespace string) (*kclient.ListOptions, error) {
+	query := r.URL.Query()
+	opts := &kclient.ListOptions{
+		Namespace: namespace,
+		Continue:  query.Get("continue"),
+		Raw: &metav1.ListOptions{
+			ResourceVersion: query.Get("resourceVersion"),
+		},
+	}
+	opts.Raw.AllowWatchBookmarks, _ = strconv.ParseBool(query.Get("allowWatchBookmarks"))
+
+	var err error
+	if limit := query.Get("limit"); limit != "" {
+		if opts.Limit, err = strconv.ParseInt(limit, 10, 64); err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid limit %q", limit))
+		}
+	}
+	if selector := query.Get("labelSelector"); selector != "" {
+		if opts.LabelSelector, err = labels.Parse(selector); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	}
+	if selector := query.Get("fieldSelector"); selector != "" {
+		if opts.FieldSelector, err = fields.ParseSelector(selector); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	}
+	return opts, nil
+}
+
+func (h *Handler) list(rw http.ResponseWriter, r *http.Request, req request) error {
+	opts, err := listOptions(r, req.namespace)
+	if err != nil {
+		return err
+	}
+	list := req.strategy.NewList()
+	if err := h.client.List(r.Context(), list, opts); err != nil {
+		return err
+	}
+	return writeObject(rw, http.StatusOK, req.gvk.GroupVersion().WithKind(req.gvk.Kind+"List"), list)
+}
+
+func (h *Handler) watch(rw http.ResponseWriter, r *http.Request, req request) error {
+	opts, err := listOptions(r, req.namespace)
+	if err != nil {
+		return err
+	}
+	w, err := h.client.Watch(r.Context(), req.strategy.NewList(), opts)
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	flusher, _ := rw.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	encoder := json.NewEncoder(rw)
+	for event := range w.ResultChan() {
+		if obj, ok := event.Object.(types.Object); ok {
+			obj.GetObjectKind().SetGroupVersionKind(req.gvk)
+		}
+		if err := encoder.Encode(&metav1.WatchEvent{
+			Type:   string(event.Type),
+			Object: runtime.RawExtension{Object: event.Object},
+		}); err != nil {
+			return nil
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if event.Type == watch.Error {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (h *Handler) readObject(r *http.Request, req request) (types.Object, error) {
+	obj := req.strategy.New()
+	if err := json.NewDecoder(r.Body).Decode(obj); err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("failed to decode request body: %v", err))
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(req.namespace)
+	}
+	if obj.GetNamespace() != req.namespace || (req.name != "" && obj.GetName() != req.name) {
+		return nil, apierrors.NewBadRequest("the namespace and name of the object must match the request")
+	}
+	return obj, nil
+}
+
+func (h *Handler) create(rw http.ResponseWriter, r *http.Request, req request) error {
+	obj, err := h.readObject(r, req)
+	if err != nil {
+		return err
+	}
+	if err := h.client.Create(r.Context(), obj); err != nil {
+		return err
+	}
+	return writeObject(rw, http.StatusCreated, req.gvk, obj)
+}
+
+func (h *Handler) update(rw http.ResponseWriter, r *http.Request, req request) error {
+	obj, err := h.readObject(r, req)
+	if err != nil {
+		return err
+	}
+	if req.subresource == "status" {
+		err = h.client.Status().Update(r.Context(), obj)
+	} else {
+		err = h.client.Update(r.Context(), obj)
+	}
+	if err != nil {
+		return err
+	}
+	return writeObject(rw, http.StatusOK, req.gvk, obj)
+}
+
+func (h *Handler) patch(rw http.ResponseWriter, r *http.Request, req request) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+	patchType := ktypes.PatchType(strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0]))
+
+	obj := req.strategy.New()
+	obj.SetNamespace(req.namespace)
+	obj.SetName(req.name)
+	patch := kclient.RawPatch(patchType, data)
+	if req.subresource == "status" {
+		err = h.client.Status().Patch(r.Context(), obj, patch)
+	} else {
+		err = h.client.Patch(r.Context(), obj, patch)
+	}
+	if err != nil {
+		return err
+	}
+	return writeObject(rw, http.StatusOK, req.gvk, obj)
+}
+
+func (h *Handler) delete(rw http.ResponseWriter, r *http.Request, req request) error {
+	obj := req.strategy.New()
+	obj.SetNamespace(req.namespace)
+	obj.SetName(req.name)
+	if err := h.client.Delete(r.Context(), obj); err != nil {
+		return err
+	}
+	return writeJSON(rw, http.StatusOK, &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusSuccess,
+		Details: &metav1.StatusDetails{
+			Name:  req.name,
+			Group: req.gvk.Group,
+			Kind:  req.gvk.Kind,
+		},
+	})
+}
+
+func writeObject(rw http.ResponseWriter, code int, gvk schema.GroupVersionKind, obj runtime.Object) error {
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return writeJSON(rw, code, obj)
+}
+
+func writeJSON(rw http.ResponseWriter, code int, obj any) error {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	return json.NewEncoder(rw).Encode(obj)
+}
+
+func writeError(rw http.ResponseWriter, err error) {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok {
+		status = apierrors.NewInternalError(err)
+	}
+	result := status.Status()
+	result.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+	if result.Code == 0 {
+		result.Code = http.StatusInternalServerError
+	}
+	_ = writeJSON(rw, int(result.Code), &result)
+}
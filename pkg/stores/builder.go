@@ -1,8 +1,8 @@
 package stores
 
-import (
-	"fmt"
+//go:generate go run ./internal/gen
 
+import (
 	"github.com/obot-platform/kinm/pkg/strategy"
 	"github.com/obot-platform/kinm/pkg/types"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -300,9 +300,17 @@ func (b Builder) Build() rest.Storage {
 			TableAdapter:        b.tableAdapter(),
 		}
 	}
-	panic(fmt.Sprintf("createSet=%v, getSet=%v, listSet=%v, updateSet=%v, deleteSet=%v, watchSet=%v "+
-		"combination is not currently supported, PRs welcomed!", createSet, getSet, listSet, updateSet, deleteSet,
-		watchSet))
+
+	verbs := 0
+	for i, set := range []bool{createSet, getSet, listSet, updateSet, deleteSet, watchSet} {
+		if set {
+			verbs |= 1 << i
+		}
+	}
+	if store := b.generatedStore(verbs); store != nil {
+		return store
+	}
+	panic("at least one of create, get, list, update, delete or watch must be set")
 }
 
 func (b Builder) watchAdapter() *strategy.WatchAdapter {
@@ -358,6 +366,10 @@ func (b Builder) destroyAdapter() *strategy.DestroyAdapter {
 }
 
 func (b Builder) tableAdapter() *strategy.TableAdapter {
+	if b.TableConverter == nil {
+		// Use the columns the object declares, if any
+		return strategy.NewTable(&newer{obj: b.obj})
+	}
 	return strategy.NewTable(b.TableConverter)
 }
 
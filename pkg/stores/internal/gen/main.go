@@ -0,0 +1,136 @@
+// Command gen writes zz_generated_stores.go, which defines a store for every combination of verbs that doesn't have
+// a hand written store, so that Builder.Build supports any combination.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"strings"
+)
+
+type verb struct {
+	name    string
+	adapter string
+	build   string
+	iface   string
+}
+
+var verbs = []verb{
+	{"Create", "CreateAdapter", "b.createAdapter()", "rest.Creater"},
+	{"Get", "GetAdapter", "b.getAdapter()", "rest.Getter"},
+	{"List", "ListAdapter", "b.listAdapter()", "rest.Lister"},
+	{"Update", "UpdateAdapter", "b.updateAdapter()", "rest.Updater"},
+	{"Delete", "DeleteAdapter", "b.deleteAdapter()", "rest.GracefulDeleter"},
+	{"Watch", "WatchAdapter", "b.watchAdapter()", "rest.Watcher"},
+}
+
+// handWritten are the combinations Builder.Build already returns a hand written store for, as masks over verbs.
+var handWritten = map[int]bool{
+	mask("Create", "Get"):                   true,
+	mask("Create", "Get", "List", "Delete"): true,
+	mask("Create"):                          true,
+	mask("Get", "List"):                     true,
+	mask("Get", "List", "Delete"):           true,
+	mask("Get"):                             true,
+	mask("List"):                            true,
+	mask("Get", "List", "Delete", "Watch"):  true,
+	mask("Create", "Get", "List", "Update", "Delete", "Watch"): true,
+	mask("Create", "Get", "List", "Delete", "Watch"):           true,
+	mask("Create", "Get", "List", "Update", "Delete"):          true,
+	mask("Get", "List", "Update", "Delete"):                    true,
+	mask("Get", "List", "Update", "Delete", "Watch"):           true,
+	mask("List", "Watch"):                                      true,
+	mask("Get", "List", "Watch"):                               true,
+}
+
+func mask(names ...string) int {
+	result := 0
+	for _, name := range names {
+		for i, v := range verbs {
+			if v.name == name {
+				result |= 1 << i
+			}
+		}
+	}
+	return result
+}
+
+func main() {
+	out := &bytes.Buffer{}
+	fmt.Fprint(out, `// Code generated by internal/gen. DO NOT EDIT.
+
+package stores
+
+import (
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+`)
+
+	var cases strings.Builder
+	for m := 1; m < 1<<len(verbs); m++ {
+		if handWritten[m] {
+			continue
+		}
+		var set []verb
+		for i, v := range verbs {
+			if m&(1<<i) != 0 {
+				set = append(set, v)
+			}
+		}
+
+		name := ""
+		for _, v := range set {
+			name += v.name
+		}
+		name += "Store"
+
+		fmt.Fprintf(out, "\nvar (\n")
+		for _, v := range set {
+			fmt.Fprintf(out, "\t_ %s = (*%s)(nil)\n", v.iface, name)
+		}
+		fmt.Fprintf(out, "\t_ strategy.Base = (*%s)(nil)\n)\n", name)
+
+		fmt.Fprintf(out, "\ntype %s struct {\n\t*strategy.SingularNameAdapter\n", name)
+		for _, v := range set {
+			fmt.Fprintf(out, "\t*strategy.%s\n", v.adapter)
+		}
+		// Create and update adapters provide New
+		hasNew := m&(mask("Create", "Update")) != 0
+		if !hasNew {
+			fmt.Fprintf(out, "\t*strategy.NewAdapter\n")
+		}
+		fmt.Fprintf(out, "\t*strategy.DestroyAdapter\n\t*strategy.TableAdapter\n\n\tscoper *strategy.ScoperAdapter\n}\n")
+		fmt.Fprintf(out, "\nfunc (r *%s) NamespaceScoped() bool {\n\treturn r.scoper.NamespaceScoped()\n}\n", name)
+
+		fmt.Fprintf(&cases, "\tcase %d:\n\t\treturn &%s{\n\t\t\tSingularNameAdapter: b.getSingularNameAdapter(),\n", m, name)
+		for _, v := range set {
+			fmt.Fprintf(&cases, "\t\t\t%s: %s,\n", v.adapter, v.build)
+		}
+		if !hasNew {
+			fmt.Fprintf(&cases, "\t\t\tNewAdapter: b.newAdapter(),\n")
+		}
+		fmt.Fprintf(&cases, "\t\t\tDestroyAdapter: b.destroyAdapter(),\n\t\t\tTableAdapter: b.tableAdapter(),\n\t\t\tscoper: b.scoperAdapter(),\n\t\t}\n")
+	}
+
+	fmt.Fprintf(out, `
+// generatedStore returns the generated store for a combination of verbs, given as a bit mask in the order create,
+// get, list, update, delete, watch, or nil if the combination has a hand written store.
+func (b Builder) generatedStore(verbs int) rest.Storage {
+	switch verbs {
+%s	}
+	return nil
+}
+`, cases.String())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, out.String())
+		panic(err)
+	}
+	if err := os.WriteFile("zz_generated_stores.go", src, 0644); err != nil {
+		panic(err)
+	}
+}
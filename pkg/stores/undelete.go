@@ -0,0 +1,11 @@
+package stores
+
+import (
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+// NewUndelete returns the storage of an undelete subresource, which recreates the deleted object when it is created.
+func NewUndelete(undeleter strategy.Undeleter) rest.Storage {
+	return strategy.NewUndelete(undeleter)
+}
@@ -0,0 +1,1568 @@
+// Code generated by internal/gen. DO NOT EDIT.
+
+package stores
+
+import (
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+var (
+	_ rest.Creater  = (*CreateListStore)(nil)
+	_ rest.Lister   = (*CreateListStore)(nil)
+	_ strategy.Base = (*CreateListStore)(nil)
+)
+
+type CreateListStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.ListAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateListStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateGetListStore)(nil)
+	_ rest.Getter   = (*CreateGetListStore)(nil)
+	_ rest.Lister   = (*CreateGetListStore)(nil)
+	_ strategy.Base = (*CreateGetListStore)(nil)
+)
+
+type CreateGetListStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.ListAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetListStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Updater  = (*UpdateStore)(nil)
+	_ strategy.Base = (*UpdateStore)(nil)
+)
+
+type UpdateStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.UpdateAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *UpdateStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateUpdateStore)(nil)
+	_ rest.Updater  = (*CreateUpdateStore)(nil)
+	_ strategy.Base = (*CreateUpdateStore)(nil)
+)
+
+type CreateUpdateStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.UpdateAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateUpdateStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Getter   = (*GetUpdateStore)(nil)
+	_ rest.Updater  = (*GetUpdateStore)(nil)
+	_ strategy.Base = (*GetUpdateStore)(nil)
+)
+
+type GetUpdateStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.GetAdapter
+	*strategy.UpdateAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *GetUpdateStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateGetUpdateStore)(nil)
+	_ rest.Getter   = (*CreateGetUpdateStore)(nil)
+	_ rest.Updater  = (*CreateGetUpdateStore)(nil)
+	_ strategy.Base = (*CreateGetUpdateStore)(nil)
+)
+
+type CreateGetUpdateStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.UpdateAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetUpdateStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Lister   = (*ListUpdateStore)(nil)
+	_ rest.Updater  = (*ListUpdateStore)(nil)
+	_ strategy.Base = (*ListUpdateStore)(nil)
+)
+
+type ListUpdateStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *ListUpdateStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateListUpdateStore)(nil)
+	_ rest.Lister   = (*CreateListUpdateStore)(nil)
+	_ rest.Updater  = (*CreateListUpdateStore)(nil)
+	_ strategy.Base = (*CreateListUpdateStore)(nil)
+)
+
+type CreateListUpdateStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateListUpdateStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Getter   = (*GetListUpdateStore)(nil)
+	_ rest.Lister   = (*GetListUpdateStore)(nil)
+	_ rest.Updater  = (*GetListUpdateStore)(nil)
+	_ strategy.Base = (*GetListUpdateStore)(nil)
+)
+
+type GetListUpdateStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.GetAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *GetListUpdateStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateGetListUpdateStore)(nil)
+	_ rest.Getter   = (*CreateGetListUpdateStore)(nil)
+	_ rest.Lister   = (*CreateGetListUpdateStore)(nil)
+	_ rest.Updater  = (*CreateGetListUpdateStore)(nil)
+	_ strategy.Base = (*CreateGetListUpdateStore)(nil)
+)
+
+type CreateGetListUpdateStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetListUpdateStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.GracefulDeleter = (*DeleteStore)(nil)
+	_ strategy.Base        = (*DeleteStore)(nil)
+)
+
+type DeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.DeleteAdapter
+	*strategy.NewAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *DeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*CreateDeleteStore)(nil)
+	_ strategy.Base        = (*CreateDeleteStore)(nil)
+)
+
+type CreateDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.DeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Getter          = (*GetDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*GetDeleteStore)(nil)
+	_ strategy.Base        = (*GetDeleteStore)(nil)
+)
+
+type GetDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.GetAdapter
+	*strategy.DeleteAdapter
+	*strategy.NewAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *GetDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateGetDeleteStore)(nil)
+	_ rest.Getter          = (*CreateGetDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*CreateGetDeleteStore)(nil)
+	_ strategy.Base        = (*CreateGetDeleteStore)(nil)
+)
+
+type CreateGetDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.DeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Lister          = (*ListDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*ListDeleteStore)(nil)
+	_ strategy.Base        = (*ListDeleteStore)(nil)
+)
+
+type ListDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.ListAdapter
+	*strategy.DeleteAdapter
+	*strategy.NewAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *ListDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateListDeleteStore)(nil)
+	_ rest.Lister          = (*CreateListDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*CreateListDeleteStore)(nil)
+	_ strategy.Base        = (*CreateListDeleteStore)(nil)
+)
+
+type CreateListDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.ListAdapter
+	*strategy.DeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateListDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Updater         = (*UpdateDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*UpdateDeleteStore)(nil)
+	_ strategy.Base        = (*UpdateDeleteStore)(nil)
+)
+
+type UpdateDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *UpdateDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateUpdateDeleteStore)(nil)
+	_ rest.Updater         = (*CreateUpdateDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*CreateUpdateDeleteStore)(nil)
+	_ strategy.Base        = (*CreateUpdateDeleteStore)(nil)
+)
+
+type CreateUpdateDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateUpdateDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Getter          = (*GetUpdateDeleteStore)(nil)
+	_ rest.Updater         = (*GetUpdateDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*GetUpdateDeleteStore)(nil)
+	_ strategy.Base        = (*GetUpdateDeleteStore)(nil)
+)
+
+type GetUpdateDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.GetAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *GetUpdateDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateGetUpdateDeleteStore)(nil)
+	_ rest.Getter          = (*CreateGetUpdateDeleteStore)(nil)
+	_ rest.Updater         = (*CreateGetUpdateDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*CreateGetUpdateDeleteStore)(nil)
+	_ strategy.Base        = (*CreateGetUpdateDeleteStore)(nil)
+)
+
+type CreateGetUpdateDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetUpdateDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Lister          = (*ListUpdateDeleteStore)(nil)
+	_ rest.Updater         = (*ListUpdateDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*ListUpdateDeleteStore)(nil)
+	_ strategy.Base        = (*ListUpdateDeleteStore)(nil)
+)
+
+type ListUpdateDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *ListUpdateDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateListUpdateDeleteStore)(nil)
+	_ rest.Lister          = (*CreateListUpdateDeleteStore)(nil)
+	_ rest.Updater         = (*CreateListUpdateDeleteStore)(nil)
+	_ rest.GracefulDeleter = (*CreateListUpdateDeleteStore)(nil)
+	_ strategy.Base        = (*CreateListUpdateDeleteStore)(nil)
+)
+
+type CreateListUpdateDeleteStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateListUpdateDeleteStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Watcher  = (*WatchStore)(nil)
+	_ strategy.Base = (*WatchStore)(nil)
+)
+
+type WatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.WatchAdapter
+	*strategy.NewAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *WatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateWatchStore)(nil)
+	_ rest.Watcher  = (*CreateWatchStore)(nil)
+	_ strategy.Base = (*CreateWatchStore)(nil)
+)
+
+type CreateWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Getter   = (*GetWatchStore)(nil)
+	_ rest.Watcher  = (*GetWatchStore)(nil)
+	_ strategy.Base = (*GetWatchStore)(nil)
+)
+
+type GetWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.GetAdapter
+	*strategy.WatchAdapter
+	*strategy.NewAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *GetWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateGetWatchStore)(nil)
+	_ rest.Getter   = (*CreateGetWatchStore)(nil)
+	_ rest.Watcher  = (*CreateGetWatchStore)(nil)
+	_ strategy.Base = (*CreateGetWatchStore)(nil)
+)
+
+type CreateGetWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateListWatchStore)(nil)
+	_ rest.Lister   = (*CreateListWatchStore)(nil)
+	_ rest.Watcher  = (*CreateListWatchStore)(nil)
+	_ strategy.Base = (*CreateListWatchStore)(nil)
+)
+
+type CreateListWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.ListAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateListWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateGetListWatchStore)(nil)
+	_ rest.Getter   = (*CreateGetListWatchStore)(nil)
+	_ rest.Lister   = (*CreateGetListWatchStore)(nil)
+	_ rest.Watcher  = (*CreateGetListWatchStore)(nil)
+	_ strategy.Base = (*CreateGetListWatchStore)(nil)
+)
+
+type CreateGetListWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.ListAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetListWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Updater  = (*UpdateWatchStore)(nil)
+	_ rest.Watcher  = (*UpdateWatchStore)(nil)
+	_ strategy.Base = (*UpdateWatchStore)(nil)
+)
+
+type UpdateWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.UpdateAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *UpdateWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateUpdateWatchStore)(nil)
+	_ rest.Updater  = (*CreateUpdateWatchStore)(nil)
+	_ rest.Watcher  = (*CreateUpdateWatchStore)(nil)
+	_ strategy.Base = (*CreateUpdateWatchStore)(nil)
+)
+
+type CreateUpdateWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.UpdateAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateUpdateWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Getter   = (*GetUpdateWatchStore)(nil)
+	_ rest.Updater  = (*GetUpdateWatchStore)(nil)
+	_ rest.Watcher  = (*GetUpdateWatchStore)(nil)
+	_ strategy.Base = (*GetUpdateWatchStore)(nil)
+)
+
+type GetUpdateWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.GetAdapter
+	*strategy.UpdateAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *GetUpdateWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateGetUpdateWatchStore)(nil)
+	_ rest.Getter   = (*CreateGetUpdateWatchStore)(nil)
+	_ rest.Updater  = (*CreateGetUpdateWatchStore)(nil)
+	_ rest.Watcher  = (*CreateGetUpdateWatchStore)(nil)
+	_ strategy.Base = (*CreateGetUpdateWatchStore)(nil)
+)
+
+type CreateGetUpdateWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.UpdateAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetUpdateWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Lister   = (*ListUpdateWatchStore)(nil)
+	_ rest.Updater  = (*ListUpdateWatchStore)(nil)
+	_ rest.Watcher  = (*ListUpdateWatchStore)(nil)
+	_ strategy.Base = (*ListUpdateWatchStore)(nil)
+)
+
+type ListUpdateWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *ListUpdateWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateListUpdateWatchStore)(nil)
+	_ rest.Lister   = (*CreateListUpdateWatchStore)(nil)
+	_ rest.Updater  = (*CreateListUpdateWatchStore)(nil)
+	_ rest.Watcher  = (*CreateListUpdateWatchStore)(nil)
+	_ strategy.Base = (*CreateListUpdateWatchStore)(nil)
+)
+
+type CreateListUpdateWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateListUpdateWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Getter   = (*GetListUpdateWatchStore)(nil)
+	_ rest.Lister   = (*GetListUpdateWatchStore)(nil)
+	_ rest.Updater  = (*GetListUpdateWatchStore)(nil)
+	_ rest.Watcher  = (*GetListUpdateWatchStore)(nil)
+	_ strategy.Base = (*GetListUpdateWatchStore)(nil)
+)
+
+type GetListUpdateWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.GetAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *GetListUpdateWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater  = (*CreateGetListUpdateWatchStore)(nil)
+	_ rest.Getter   = (*CreateGetListUpdateWatchStore)(nil)
+	_ rest.Lister   = (*CreateGetListUpdateWatchStore)(nil)
+	_ rest.Updater  = (*CreateGetListUpdateWatchStore)(nil)
+	_ rest.Watcher  = (*CreateGetListUpdateWatchStore)(nil)
+	_ strategy.Base = (*CreateGetListUpdateWatchStore)(nil)
+)
+
+type CreateGetListUpdateWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetListUpdateWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.GracefulDeleter = (*DeleteWatchStore)(nil)
+	_ rest.Watcher         = (*DeleteWatchStore)(nil)
+	_ strategy.Base        = (*DeleteWatchStore)(nil)
+)
+
+type DeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.NewAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *DeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*CreateDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*CreateDeleteWatchStore)(nil)
+	_ strategy.Base        = (*CreateDeleteWatchStore)(nil)
+)
+
+type CreateDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Getter          = (*GetDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*GetDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*GetDeleteWatchStore)(nil)
+	_ strategy.Base        = (*GetDeleteWatchStore)(nil)
+)
+
+type GetDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.GetAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.NewAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *GetDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateGetDeleteWatchStore)(nil)
+	_ rest.Getter          = (*CreateGetDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*CreateGetDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*CreateGetDeleteWatchStore)(nil)
+	_ strategy.Base        = (*CreateGetDeleteWatchStore)(nil)
+)
+
+type CreateGetDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Lister          = (*ListDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*ListDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*ListDeleteWatchStore)(nil)
+	_ strategy.Base        = (*ListDeleteWatchStore)(nil)
+)
+
+type ListDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.ListAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.NewAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *ListDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateListDeleteWatchStore)(nil)
+	_ rest.Lister          = (*CreateListDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*CreateListDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*CreateListDeleteWatchStore)(nil)
+	_ strategy.Base        = (*CreateListDeleteWatchStore)(nil)
+)
+
+type CreateListDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.ListAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateListDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Updater         = (*UpdateDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*UpdateDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*UpdateDeleteWatchStore)(nil)
+	_ strategy.Base        = (*UpdateDeleteWatchStore)(nil)
+)
+
+type UpdateDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *UpdateDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateUpdateDeleteWatchStore)(nil)
+	_ rest.Updater         = (*CreateUpdateDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*CreateUpdateDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*CreateUpdateDeleteWatchStore)(nil)
+	_ strategy.Base        = (*CreateUpdateDeleteWatchStore)(nil)
+)
+
+type CreateUpdateDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateUpdateDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Getter          = (*GetUpdateDeleteWatchStore)(nil)
+	_ rest.Updater         = (*GetUpdateDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*GetUpdateDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*GetUpdateDeleteWatchStore)(nil)
+	_ strategy.Base        = (*GetUpdateDeleteWatchStore)(nil)
+)
+
+type GetUpdateDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.GetAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *GetUpdateDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateGetUpdateDeleteWatchStore)(nil)
+	_ rest.Getter          = (*CreateGetUpdateDeleteWatchStore)(nil)
+	_ rest.Updater         = (*CreateGetUpdateDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*CreateGetUpdateDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*CreateGetUpdateDeleteWatchStore)(nil)
+	_ strategy.Base        = (*CreateGetUpdateDeleteWatchStore)(nil)
+)
+
+type CreateGetUpdateDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateGetUpdateDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Lister          = (*ListUpdateDeleteWatchStore)(nil)
+	_ rest.Updater         = (*ListUpdateDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*ListUpdateDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*ListUpdateDeleteWatchStore)(nil)
+	_ strategy.Base        = (*ListUpdateDeleteWatchStore)(nil)
+)
+
+type ListUpdateDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *ListUpdateDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+var (
+	_ rest.Creater         = (*CreateListUpdateDeleteWatchStore)(nil)
+	_ rest.Lister          = (*CreateListUpdateDeleteWatchStore)(nil)
+	_ rest.Updater         = (*CreateListUpdateDeleteWatchStore)(nil)
+	_ rest.GracefulDeleter = (*CreateListUpdateDeleteWatchStore)(nil)
+	_ rest.Watcher         = (*CreateListUpdateDeleteWatchStore)(nil)
+	_ strategy.Base        = (*CreateListUpdateDeleteWatchStore)(nil)
+)
+
+type CreateListUpdateDeleteWatchStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.ListAdapter
+	*strategy.UpdateAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+
+	scoper *strategy.ScoperAdapter
+}
+
+func (r *CreateListUpdateDeleteWatchStore) NamespaceScoped() bool {
+	return r.scoper.NamespaceScoped()
+}
+
+// generatedStore returns the generated store for a combination of verbs, given as a bit mask in the order create,
+// get, list, update, delete, watch, or nil if the combination has a hand written store.
+func (b Builder) generatedStore(verbs int) rest.Storage {
+	switch verbs {
+	case 5:
+		return &CreateListStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			ListAdapter:         b.listAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 7:
+		return &CreateGetListStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			ListAdapter:         b.listAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 8:
+		return &UpdateStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 9:
+		return &CreateUpdateStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 10:
+		return &GetUpdateStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			GetAdapter:          b.getAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 11:
+		return &CreateGetUpdateStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 12:
+		return &ListUpdateStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 13:
+		return &CreateListUpdateStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 14:
+		return &GetListUpdateStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			GetAdapter:          b.getAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 15:
+		return &CreateGetListUpdateStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 16:
+		return &DeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			NewAdapter:          b.newAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 17:
+		return &CreateDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 18:
+		return &GetDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			GetAdapter:          b.getAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			NewAdapter:          b.newAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 19:
+		return &CreateGetDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 20:
+		return &ListDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			ListAdapter:         b.listAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			NewAdapter:          b.newAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 21:
+		return &CreateListDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			ListAdapter:         b.listAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 24:
+		return &UpdateDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 25:
+		return &CreateUpdateDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 26:
+		return &GetUpdateDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			GetAdapter:          b.getAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 27:
+		return &CreateGetUpdateDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 28:
+		return &ListUpdateDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 29:
+		return &CreateListUpdateDeleteStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 32:
+		return &WatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			NewAdapter:          b.newAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 33:
+		return &CreateWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 34:
+		return &GetWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			GetAdapter:          b.getAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			NewAdapter:          b.newAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 35:
+		return &CreateGetWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 37:
+		return &CreateListWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			ListAdapter:         b.listAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 39:
+		return &CreateGetListWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			ListAdapter:         b.listAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 40:
+		return &UpdateWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 41:
+		return &CreateUpdateWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 42:
+		return &GetUpdateWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			GetAdapter:          b.getAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 43:
+		return &CreateGetUpdateWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 44:
+		return &ListUpdateWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 45:
+		return &CreateListUpdateWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 46:
+		return &GetListUpdateWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			GetAdapter:          b.getAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 47:
+		return &CreateGetListUpdateWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 48:
+		return &DeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			NewAdapter:          b.newAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 49:
+		return &CreateDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 50:
+		return &GetDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			GetAdapter:          b.getAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			NewAdapter:          b.newAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 51:
+		return &CreateGetDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 52:
+		return &ListDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			ListAdapter:         b.listAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			NewAdapter:          b.newAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 53:
+		return &CreateListDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			ListAdapter:         b.listAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 56:
+		return &UpdateDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 57:
+		return &CreateUpdateDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 58:
+		return &GetUpdateDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			GetAdapter:          b.getAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 59:
+		return &CreateGetUpdateDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			GetAdapter:          b.getAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 60:
+		return &ListUpdateDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	case 61:
+		return &CreateListUpdateDeleteWatchStore{
+			SingularNameAdapter: b.getSingularNameAdapter(),
+			CreateAdapter:       b.createAdapter(),
+			ListAdapter:         b.listAdapter(),
+			UpdateAdapter:       b.updateAdapter(),
+			DeleteAdapter:       b.deleteAdapter(),
+			WatchAdapter:        b.watchAdapter(),
+			DestroyAdapter:      b.destroyAdapter(),
+			TableAdapter:        b.tableAdapter(),
+			scoper:              b.scoperAdapter(),
+		}
+	}
+	return nil
+}
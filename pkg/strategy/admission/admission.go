@@ -0,0 +1,201 @@
+// Package admission provides a strategy decorator that runs in-process admission plugins before objects are
+// persisted. Mutating plugins run first, in registration order, followed by validating plugins.
+package admission
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type Operation string
+
+const (
+	Create Operation = "CREATE"
+	Update Operation = "UPDATE"
+	Delete Operation = "DELETE"
+)
+
+// Attributes describe the operation being admitted.
+type Attributes struct {
+	Operation Operation
+	// Subresource is "status" for status updates and empty otherwise.
+	Subresource      string
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	// Object is the object that will be persisted. Mutating plugins may modify it.
+	Object types.Object
+	// OldObject is the currently stored object for updates and deletes, and nil for creates. It must not be modified.
+	OldObject types.Object
+	// User is the requesting user, if known.
+	User user.Info
+}
+
+// MutatingPlugin may modify the object before it is validated and persisted.
+type MutatingPlugin interface {
+	Name() string
+	Admit(ctx context.Context, attr Attributes) error
+}
+
+// ValidatingPlugin may reject an operation but must not modify the object.
+type ValidatingPlugin interface {
+	Name() string
+	Validate(ctx context.Context, attr Attributes) error
+}
+
+// MutatingFunc adapts a function to a MutatingPlugin.
+type MutatingFunc struct {
+	PluginName string
+	Func       func(ctx context.Context, attr Attributes) error
+}
+
+func (m MutatingFunc) Name() string {
+	return m.PluginName
+}
+
+func (m MutatingFunc) Admit(ctx context.Context, attr Attributes) error {
+	return m.Func(ctx, attr)
+}
+
+// ValidatingFunc adapts a function to a ValidatingPlugin.
+type ValidatingFunc struct {
+	PluginName string
+	Func       func(ctx context.Context, attr Attributes) error
+}
+
+func (v ValidatingFunc) Name() string {
+	return v.PluginName
+}
+
+func (v ValidatingFunc) Validate(ctx context.Context, attr Attributes) error {
+	return v.Func(ctx, attr)
+}
+
+var _ strategy.CompleteStrategy = (*Strategy)(nil)
+
+// Strategy runs admission plugins before create, update, status update and delete operations of the wrapped
+// strategy. Reads and watches are passed through unchanged.
+type Strategy struct {
+	strategy.CompleteStrategy
+	gvk        schema.GroupVersionKind
+	mutating   []MutatingPlugin
+	validating []ValidatingPlugin
+}
+
+// NewStrategy wraps s with the given plugins. Each plugin must implement MutatingPlugin, ValidatingPlugin or both. A
+// plugin implementing both is run in both phases.
+func NewStrategy(s strategy.CompleteStrategy, plugins ...any) (*Strategy, error) {
+	result := &Strategy{
+		CompleteStrategy: s,
+		gvk:              types.MustGetGVK(s.New(), s.Scheme()),
+	}
+	for _, plugin := range plugins {
+		m, isMutating := plugin.(MutatingPlugin)
+		v, isValidating := plugin.(ValidatingPlugin)
+		if !isMutating && !isValidating {
+			return nil, fmt.Errorf("admission plugin %T is neither mutating nor validating", plugin)
+		}
+		if isMutating {
+			result.mutating = append(result.mutating, m)
+		}
+		if isValidating {
+			result.validating = append(result.validating, v)
+		}
+	}
+	return result, nil
+}
+
+func (s *Strategy) NamespaceScoped() bool {
+	return strategy.NewScoper(s.CompleteStrategy).NamespaceScoped()
+}
+
+func (s *Strategy) GetWithOptions(ctx context.Context, namespace, name string, opts metav1.GetOptions) (types.Object, error) {
+	return strategy.GetWithOptions(ctx, s.CompleteStrategy, namespace, name, opts)
+}
+
+func (s *Strategy) Create(ctx context.Context, object types.Object) (types.Object, error) {
+	object = object.DeepCopyObject().(types.Object)
+	if err := s.admit(ctx, Create, "", object, nil); err != nil {
+		return nil, err
+	}
+	return s.CompleteStrategy.Create(ctx, object)
+}
+
+func (s *Strategy) Update(ctx context.Context, obj types.Object) (types.Object, error) {
+	obj, err := s.admitExisting(ctx, Update, "", obj)
+	if err != nil {
+		return nil, err
+	}
+	return s.CompleteStrategy.Update(ctx, obj)
+}
+
+func (s *Strategy) UpdateStatus(ctx context.Context, obj types.Object) (types.Object, error) {
+	obj, err := s.admitExisting(ctx, Update, "status", obj)
+	if err != nil {
+		return nil, err
+	}
+	return s.CompleteStrategy.UpdateStatus(ctx, obj)
+}
+
+func (s *Strategy) Delete(ctx context.Context, obj types.Object) (types.Object, error) {
+	obj, err := s.admitExisting(ctx, Delete, "", obj)
+	if err != nil {
+		return nil, err
+	}
+	return s.CompleteStrategy.Delete(ctx, obj)
+}
+
+func (s *Strategy) admitExisting(ctx context.Context, op Operation, subresource string, obj types.Object) (types.Object, error) {
+	old, err := s.CompleteStrategy.Get(ctx, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return nil, err
+	}
+	obj = obj.DeepCopyObject().(types.Object)
+	return obj, s.admit(ctx, op, subresource, obj, old)
+}
+
+func (s *Strategy) admit(ctx context.Context, op Operation, subresource string, obj, old types.Object) error {
+	attr := Attributes{
+		Operation:        op,
+		Subresource:      subresource,
+		GroupVersionKind: s.gvk,
+		Namespace:        obj.GetNamespace(),
+		Name:             obj.GetName(),
+		Object:           obj,
+		OldObject:        old,
+	}
+	if u, ok := request.UserFrom(ctx); ok {
+		attr.User = u
+	}
+
+	for _, plugin := range s.mutating {
+		if err := plugin.Admit(ctx, attr); err != nil {
+			return s.toError(plugin.Name(), obj.GetName(), err)
+		}
+	}
+	for _, plugin := range s.validating {
+		if err := plugin.Validate(ctx, attr); err != nil {
+			return s.toError(plugin.Name(), obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// toError returns API errors from plugins unchanged and wraps any other error in a Forbidden error.
+func (s *Strategy) toError(plugin, name string, err error) error {
+	if _, ok := err.(apierrors.APIStatus); ok {
+		return err
+	}
+	return apierrors.NewForbidden(schema.GroupResource{
+		Group:    s.gvk.Group,
+		Resource: s.gvk.Kind,
+	}, name, fmt.Errorf("admission plugin %q denied the request: %w", plugin, err))
+}
@@ -0,0 +1,197 @@
+package admission
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// celCostLimit bounds the work a single rule evaluation may do.
+const celCostLimit = 1_000_000
+
+var oldSelfRef = regexp.MustCompile(`\boldSelf\b`)
+
+// Rule is a CEL validation rule, equivalent to an x-kubernetes-validations entry of a CRD. The object is available as
+// self and, on update, the stored object as oldSelf. Rules that reference oldSelf are transition rules and are only
+// evaluated on update.
+type Rule struct {
+	// Rule is the CEL expression, which must evaluate to a bool. For example "self.spec.replicas <= 10".
+	Rule string
+	// Message is returned when the rule evaluates to false. If empty, a message including the rule is used.
+	Message string
+	// FieldPath is the JSON path of the field the error is reported for, for example ".spec.replicas". If empty, the
+	// error is reported for the object.
+	FieldPath string
+}
+
+type compiledRule struct {
+	Rule
+	program    cel.Program
+	transition bool
+	path       *field.Path
+	// fields are the names of the fields of path
+	fields []string
+}
+
+var _ ValidatingPlugin = (*CELValidator)(nil)
+
+// CELValidator is a validating admission plugin that evaluates CEL rules registered per GroupVersionKind.
+type CELValidator struct {
+	env   *cel.Env
+	lock  sync.RWMutex
+	rules map[schema.GroupVersionKind][]compiledRule
+}
+
+func NewCELValidator() (*CELValidator, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("self", cel.DynType),
+		cel.Variable("oldSelf", cel.DynType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &CELValidator{
+		env:   env,
+		rules: map[schema.GroupVersionKind][]compiledRule{},
+	}, nil
+}
+
+func (c *CELValidator) Name() string {
+	return "cel"
+}
+
+// Register compiles rules and adds them to the rules of gvk. No rules are added if any of them fails to compile.
+func (c *CELValidator) Register(gvk schema.GroupVersionKind, rules ...Rule) error {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		ast, issues := c.env.Compile(rule.Rule)
+		if issues != nil && issues.Err() != nil {
+			return fmt.Errorf("failed to compile rule %q: %w", rule.Rule, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return fmt.Errorf("rule %q must evaluate to a bool, not %v", rule.Rule, ast.OutputType())
+		}
+		program, err := c.env.Program(ast, cel.CostLimit(celCostLimit))
+		if err != nil {
+			return fmt.Errorf("failed to compile rule %q: %w", rule.Rule, err)
+		}
+		path, err := parseFieldPath(rule.FieldPath)
+		if err != nil {
+			return fmt.Errorf("invalid field path of rule %q: %w", rule.Rule, err)
+		}
+		compiled = append(compiled, compiledRule{
+			Rule:       rule,
+			program:    program,
+			transition: oldSelfRef.MatchString(rule.Rule),
+			path:       path,
+			fields:     strings.FieldsFunc(rule.FieldPath, func(r rune) bool { return r == '.' }),
+		})
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.rules[gvk] = append(c.rules[gvk], compiled...)
+	return nil
+}
+
+func (c *CELValidator) Validate(_ context.Context, attr Attributes) error {
+	if attr.Operation == Delete {
+		return nil
+	}
+
+	errs := c.ValidateObject(attr.GroupVersionKind, attr.Object, attr.OldObject)
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(attr.GroupVersionKind.GroupKind(), attr.Name, errs)
+}
+
+// ValidateObject evaluates the rules of gvk against obj. old is the stored object on update and nil on create.
+func (c *CELValidator) ValidateObject(gvk schema.GroupVersionKind, obj, old runtime.Object) (result field.ErrorList) {
+	c.lock.RLock()
+	rules := c.rules[gvk]
+	c.lock.RUnlock()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	self, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return field.ErrorList{field.InternalError(nil, err)}
+	}
+	vars := map[string]any{
+		"self":    self,
+		"oldSelf": nil,
+	}
+	if old != nil {
+		oldSelf, err := runtime.DefaultUnstructuredConverter.ToUnstructured(old)
+		if err != nil {
+			return field.ErrorList{field.InternalError(nil, err)}
+		}
+		vars["oldSelf"] = oldSelf
+	}
+
+	for _, rule := range rules {
+		if rule.transition && old == nil {
+			continue
+		}
+		value := badValue(self, rule.fields)
+		out, _, err := rule.program.Eval(vars)
+		if err != nil {
+			result = append(result, field.Invalid(rule.path, value, fmt.Sprintf("rule %q failed: %v", rule.Rule.Rule, err)))
+			continue
+		}
+		if ok, isBool := out.Value().(bool); !isBool {
+			result = append(result, field.Invalid(rule.path, value, fmt.Sprintf("rule %q did not evaluate to a bool", rule.Rule.Rule)))
+		} else if !ok {
+			msg := rule.Message
+			if msg == "" {
+				msg = fmt.Sprintf("failed rule: %s", rule.Rule.Rule)
+			}
+			result = append(result, field.Invalid(rule.path, value, msg))
+		}
+	}
+	return result
+}
+
+// badValue returns the value of the field of a rule to report in its error. The value of whole-object rules, and of
+// fields that aren't set, is omitted from the error.
+func badValue(self map[string]any, fields []string) any {
+	if len(fields) == 0 {
+		return field.OmitValueType{}
+	}
+	value, ok, err := unstructured.NestedFieldNoCopy(self, fields...)
+	if err != nil || !ok {
+		return field.OmitValueType{}
+	}
+	return value
+}
+
+// parseFieldPath converts a simple JSON path like ".spec.replicas" to a field.Path.
+func parseFieldPath(path string) (*field.Path, error) {
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return nil, nil
+	}
+	var result *field.Path
+	for _, part := range strings.Split(path, ".") {
+		if part == "" {
+			return nil, fmt.Errorf("empty segment in %q", path)
+		}
+		if result == nil {
+			result = field.NewPath(part)
+		} else {
+			result = result.Child(part)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,291 @@
+// Package aggregate serves the objects of several strategies as a single read-only resource, such as a union of
+// per-tenant tables or of live and archived objects.
+package aggregate
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/obot-platform/kinm/pkg/stores"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/strategy/view"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+var (
+	_ strategy.Getter  = (*Strategy)(nil)
+	_ strategy.Lister  = (*Strategy)(nil)
+	_ strategy.Watcher = (*Strategy)(nil)
+)
+
+// Strategy is a read-only strategy that merges the objects of its members, which must store the same type. It only
+// implements the get, list, and watch verbs.
+//
+// The resource versions of the members are independent, so the resource versions served by the strategy combine the
+// resource version of every member into an opaque string. A list or watch from such a resource version continues
+// every member from its own resource version. Objects returned by Get only know the resource version of their own
+// member, so a watch from them starts the other members with their current objects.
+//
+// Objects with the same namespace and name in more than one member are all listed and watched; Get returns the one of
+// the first member that has it.
+type Strategy struct {
+	members []view.ReadStrategy
+}
+
+// NewStrategy returns a strategy merging the objects of members, in order.
+func NewStrategy(members ...view.ReadStrategy) *Strategy {
+	if len(members) == 0 {
+		panic("aggregate strategy needs at least one member")
+	}
+	return &Strategy{
+		members: members,
+	}
+}
+
+func (s *Strategy) New() types.Object {
+	return s.members[0].New()
+}
+
+func (s *Strategy) NewList() types.ObjectList {
+	return s.members[0].NewList()
+}
+
+func (s *Strategy) Scheme() *runtime.Scheme {
+	return s.members[0].Scheme()
+}
+
+func (s *Strategy) Destroy() {
+	for _, member := range s.members {
+		if d, ok := member.(strategy.Destroyer); ok {
+			d.Destroy()
+		}
+	}
+}
+
+func (s *Strategy) Get(ctx context.Context, namespace, name string) (types.Object, error) {
+	var notFound error
+	for i, member := range s.members {
+		obj, err := member.Get(ctx, namespace, name)
+		if apierrors.IsNotFound(err) {
+			if notFound == nil {
+				notFound = err
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		rvs := make([]string, len(s.members))
+		rvs[i] = obj.GetResourceVersion()
+		obj.SetResourceVersion(encodeResourceVersion(rvs))
+		return obj, nil
+	}
+	return nil, notFound
+}
+
+// continueToken is the position of a paginated list: the member being listed, its continue token, and the resource
+// versions of the members listed so far.
+type continueToken struct {
+	Member           int      `json:"m"`
+	Continue         string   `json:"c,omitempty"`
+	ResourceVersions []string `json:"rv"`
+}
+
+// List lists the members in order. A paginated list continues with the next member when a member has no more pages.
+func (s *Strategy) List(ctx context.Context, namespace string, opts storage.ListOptions) (types.ObjectList, error) {
+	var (
+		start = continueToken{ResourceVersions: make([]string, len(s.members))}
+		limit = opts.Predicate.Limit
+		items []runtime.Object
+		// owners are the indexes of the members of items
+		owners []int
+		err    error
+	)
+	if opts.Predicate.Continue != "" {
+		if err := decode(opts.Predicate.Continue, &start); err != nil || start.Member >= len(s.members) ||
+			len(start.ResourceVersions) != len(s.members) {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid continue token %q", opts.Predicate.Continue))
+		}
+	} else if opts.ResourceVersion != "" {
+		if start.ResourceVersions, err = s.decodeResourceVersion(opts.ResourceVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	result := s.NewList()
+	rvs := start.ResourceVersions
+	for i := start.Member; i < len(s.members); i++ {
+		memberOpts := opts
+		memberOpts.ResourceVersion = rvs[i]
+		memberOpts.Predicate.Continue = ""
+		if i == start.Member {
+			memberOpts.Predicate.Continue = start.Continue
+		}
+		if limit > 0 {
+			memberOpts.Predicate.Limit = limit - int64(len(items))
+		}
+
+		list, err := s.members[i].List(ctx, namespace, memberOpts)
+		if err != nil {
+			return nil, err
+		}
+		rvs[i] = list.GetResourceVersion()
+		err = meta.EachListItem(list, func(obj runtime.Object) error {
+			items = append(items, obj)
+			owners = append(owners, i)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		next := continueToken{Member: i, Continue: list.GetContinue(), ResourceVersions: rvs}
+		if next.Continue == "" {
+			next.Member++
+		}
+		if limit > 0 && int64(len(items)) >= limit && next.Member < len(s.members) {
+			token, err := encode(next)
+			if err != nil {
+				return nil, err
+			}
+			result.SetContinue(token)
+			break
+		}
+	}
+
+	// The objects get the resource version of the list with the one of their own member
+	for i, item := range items {
+		obj := item.(types.Object)
+		itemRVs := slices.Clone(rvs)
+		itemRVs[owners[i]] = obj.GetResourceVersion()
+		obj.SetResourceVersion(encodeResourceVersion(itemRVs))
+	}
+
+	result.SetResourceVersion(encodeResourceVersion(rvs))
+	return result, meta.SetList(result, items)
+}
+
+// Watch merges the watches of the members. The watch ends when the watch of any member ends, so that the client
+// watches again from the last resource version it received.
+func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	rvs := make([]string, len(s.members))
+	if opts.ResourceVersion != "" && opts.ResourceVersion != "0" {
+		var err error
+		if rvs, err = s.decodeResourceVersion(opts.ResourceVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	watches := make([]<-chan watch.Event, len(s.members))
+	for i, member := range s.members {
+		memberOpts := opts
+		if opts.ResourceVersion != "0" {
+			memberOpts.ResourceVersion = rvs[i]
+		}
+		w, err := member.Watch(ctx, namespace, memberOpts)
+		if err != nil {
+			cancel()
+			for _, w := range watches[:i] {
+				drain(w)
+			}
+			return nil, err
+		}
+		watches[i] = w
+	}
+
+	var (
+		result = make(chan watch.Event)
+		lock   sync.Mutex
+		wg     sync.WaitGroup
+	)
+	for i, w := range watches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Stop the other members when this one ends
+			defer cancel()
+			defer drain(w)
+
+			for event := range w {
+				if obj, ok := event.Object.(types.Object); ok && event.Type != watch.Error {
+					lock.Lock()
+					rvs[i] = obj.GetResourceVersion()
+					obj.SetResourceVersion(encodeResourceVersion(rvs))
+					lock.Unlock()
+				}
+				select {
+				case result <- event:
+				case <-ctx.Done():
+					return
+				}
+				if event.Type == watch.Error {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(result)
+	}()
+	return result, nil
+}
+
+// NewStore returns a store serving only get, list, and watch for the objects of members.
+func NewStore(members ...view.ReadStrategy) rest.Storage {
+	s := NewStrategy(members...)
+	return stores.NewBuilder(s.Scheme(), s.New()).
+		WithGet(s).
+		WithList(s).
+		WithWatch(s).
+		WithDestroy(s).
+		Build()
+}
+
+func (s *Strategy) decodeResourceVersion(rv string) ([]string, error) {
+	var rvs []string
+	if err := decode(rv, &rvs); err != nil || len(rvs) != len(s.members) {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid resource version %q", rv))
+	}
+	return rvs, nil
+}
+
+func encodeResourceVersion(rvs []string) string {
+	rv, _ := encode(rvs)
+	return rv
+}
+
+func encode(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decode(s string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// drain receives the remaining events of a watch whose context is canceled, so that its sender isn't blocked.
+func drain(w <-chan watch.Event) {
+	go func() {
+		for range w {
+		}
+	}()
+}
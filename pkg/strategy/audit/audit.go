@@ -0,0 +1,143 @@
+// Package audit provides a strategy decorator that records every mutating operation to a Sink.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type Verb string
+
+const (
+	VerbCreate       Verb = "create"
+	VerbUpdate       Verb = "update"
+	VerbUpdateStatus Verb = "update-status"
+	VerbDelete       Verb = "delete"
+)
+
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Event is a single audited operation.
+type Event struct {
+	Time               time.Time               `json:"time"`
+	User               string                  `json:"user,omitempty"`
+	Groups             []string                `json:"groups,omitempty"`
+	Verb               Verb                    `json:"verb"`
+	GroupVersionKind   schema.GroupVersionKind `json:"groupVersionKind"`
+	Namespace          string                  `json:"namespace,omitempty"`
+	Name               string                  `json:"name"`
+	OldResourceVersion string                  `json:"oldResourceVersion,omitempty"`
+	NewResourceVersion string                  `json:"newResourceVersion,omitempty"`
+	Outcome            Outcome                 `json:"outcome"`
+	Error              string                  `json:"error,omitempty"`
+}
+
+// Sink records audit events. Record is called synchronously after each operation completes, so slow sinks should
+// buffer internally.
+type Sink interface {
+	Record(ctx context.Context, event Event) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, event Event) error
+
+func (f SinkFunc) Record(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// ErrorHandler is called when a sink fails to record an event. The operation itself is not affected.
+type ErrorHandler func(ctx context.Context, event Event, err error)
+
+var _ strategy.CompleteStrategy = (*Strategy)(nil)
+
+// Strategy records the create, update, status update and delete operations of the wrapped strategy. Reads and
+// watches are passed through unchanged.
+type Strategy struct {
+	strategy.CompleteStrategy
+	gvk     schema.GroupVersionKind
+	sink    Sink
+	onError ErrorHandler
+}
+
+// NewStrategy wraps s so that every mutating operation is recorded to sink. Errors from the sink are passed to
+// onError, which may be nil to ignore them.
+func NewStrategy(s strategy.CompleteStrategy, sink Sink, onError ErrorHandler) *Strategy {
+	return &Strategy{
+		CompleteStrategy: s,
+		gvk:              types.MustGetGVK(s.New(), s.Scheme()),
+		sink:             sink,
+		onError:          onError,
+	}
+}
+
+func (s *Strategy) NamespaceScoped() bool {
+	return strategy.NewScoper(s.CompleteStrategy).NamespaceScoped()
+}
+
+func (s *Strategy) GetWithOptions(ctx context.Context, namespace, name string, opts metav1.GetOptions) (types.Object, error) {
+	return strategy.GetWithOptions(ctx, s.CompleteStrategy, namespace, name, opts)
+}
+
+func (s *Strategy) Create(ctx context.Context, object types.Object) (types.Object, error) {
+	result, err := s.CompleteStrategy.Create(ctx, object)
+	s.record(ctx, VerbCreate, object, result, err)
+	return result, err
+}
+
+func (s *Strategy) Update(ctx context.Context, obj types.Object) (types.Object, error) {
+	result, err := s.CompleteStrategy.Update(ctx, obj)
+	s.record(ctx, VerbUpdate, obj, result, err)
+	return result, err
+}
+
+func (s *Strategy) UpdateStatus(ctx context.Context, obj types.Object) (types.Object, error) {
+	result, err := s.CompleteStrategy.UpdateStatus(ctx, obj)
+	s.record(ctx, VerbUpdateStatus, obj, result, err)
+	return result, err
+}
+
+func (s *Strategy) Delete(ctx context.Context, obj types.Object) (types.Object, error) {
+	result, err := s.CompleteStrategy.Delete(ctx, obj)
+	s.record(ctx, VerbDelete, obj, result, err)
+	return result, err
+}
+
+func (s *Strategy) record(ctx context.Context, verb Verb, obj, result types.Object, err error) {
+	event := Event{
+		Time:               time.Now().UTC(),
+		Verb:               verb,
+		GroupVersionKind:   s.gvk,
+		Namespace:          obj.GetNamespace(),
+		Name:               obj.GetName(),
+		OldResourceVersion: obj.GetResourceVersion(),
+		Outcome:            OutcomeSuccess,
+	}
+	if verb == VerbCreate {
+		event.OldResourceVersion = ""
+	}
+	if user, ok := request.UserFrom(ctx); ok {
+		event.User = user.GetName()
+		event.Groups = user.GetGroups()
+	}
+	if err != nil {
+		event.Outcome = OutcomeFailure
+		event.Error = err.Error()
+	} else if result != nil {
+		event.NewResourceVersion = result.GetResourceVersion()
+	}
+
+	if err := s.sink.Record(ctx, event); err != nil && s.onError != nil {
+		s.onError(ctx, event, err)
+	}
+}
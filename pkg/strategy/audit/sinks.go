@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+var _ Sink = (*WriterSink)(nil)
+
+// WriterSink writes each event as a line of JSON.
+type WriterSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{
+		w: w,
+	}
+}
+
+func (w *WriterSink) Record(_ context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err = w.w.Write(append(data, '\n'))
+	return err
+}
+
+// NewFileSink appends events as lines of JSON to the file at path, creating it if needed. The caller is responsible
+// for closing the returned file.
+func NewFileSink(path string) (*WriterSink, *os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewWriterSink(f), f, nil
+}
+
+var _ Sink = (*WebhookSink)(nil)
+
+const (
+	// DefaultWebhookTimeout is the timeout of posting an event when NewWebhookSink is given no client.
+	DefaultWebhookTimeout = 10 * time.Second
+	// WebhookQueueSize is the number of events a WebhookSink queues until they are posted.
+	WebhookQueueSize = 1000
+)
+
+// ErrQueueFull is returned by WebhookSink.Record when the event is dropped because the queue is full.
+var ErrQueueFull = errors.New("audit webhook queue is full")
+
+// WebhookSink posts each event as JSON to a URL. Record only queues the event so that a slow or unavailable endpoint
+// doesn't delay the audited operations, and Run posts the queued events in order. Events that fail to post are logged
+// and dropped.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	queue  chan Event
+}
+
+// NewWebhookSink returns a sink that posts events to url. If client is nil, a client with DefaultWebhookTimeout is
+// used. The events are only posted while Run is running.
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+	return &WebhookSink{
+		url:    url,
+		client: client,
+		queue:  make(chan Event, WebhookQueueSize),
+	}
+}
+
+// Record queues event to be posted by Run. It returns ErrQueueFull without waiting if the queue is full.
+func (w *WebhookSink) Record(_ context.Context, event Event) error {
+	select {
+	case w.queue <- event:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run posts the queued events until ctx is done.
+func (w *WebhookSink) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-w.queue:
+			if err := w.post(ctx, event); err != nil {
+				klog.Warningf("failed to post audit event for %s of %s %s/%s: %v", event.Verb,
+					event.GroupVersionKind.Kind, event.Namespace, event.Name, err)
+			}
+		}
+	}
+}
+
+func (w *WebhookSink) post(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook %s returned %s", w.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		release = make(chan struct{})
+		events  = make(chan Event, 1)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err == nil {
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sink := NewWebhookSink(server.URL, nil)
+	go sink.Run(ctx)
+
+	// Recording doesn't wait for the endpoint
+	require.NoError(t, sink.Record(ctx, Event{Verb: VerbCreate, Name: "first"}))
+	for i := 0; i < WebhookQueueSize; i++ {
+		if err := sink.Record(ctx, Event{Verb: VerbUpdate, Name: "queued"}); err != nil {
+			assert.ErrorIs(t, err, ErrQueueFull)
+			break
+		}
+	}
+	assert.ErrorIs(t, sink.Record(ctx, Event{Verb: VerbDelete, Name: "dropped"}), ErrQueueFull)
+
+	release <- struct{}{}
+	select {
+	case event := <-events:
+		assert.Equal(t, "first", event.Name)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the event to be posted")
+	}
+}
@@ -0,0 +1,173 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Column is a column that kubectl get prints for an object, in addition to the name and age. The value is read with
+// JSONPath, a path like ".status.phase" as used by the additionalPrinterColumns of CRDs, or computed by Func.
+type Column struct {
+	metav1.TableColumnDefinition
+
+	JSONPath string
+	Func     func(obj runtime.Object) (any, error)
+}
+
+// Columner is implemented by objects, or strategies, that declare the columns kubectl prints for them.
+type Columner interface {
+	Columns() []Column
+}
+
+var _ rest.TableConvertor = (*ColumnTableConvertor)(nil)
+
+// ColumnTableConvertor converts objects to tables with a name column, the given columns and an age column.
+type ColumnTableConvertor struct {
+	columns []Column
+	paths   []*jsonpath.JSONPath
+}
+
+func NewColumnTableConvertor(columns ...Column) (*ColumnTableConvertor, error) {
+	result := &ColumnTableConvertor{
+		columns: columns,
+		paths:   make([]*jsonpath.JSONPath, len(columns)),
+	}
+	for i, column := range columns {
+		if column.JSONPath == "" {
+			if column.Func == nil {
+				return nil, fmt.Errorf("column %s must have a JSONPath or a Func", column.Name)
+			}
+			continue
+		}
+		path := jsonpath.New(column.Name).AllowMissingKeys(true)
+		if err := path.Parse("{" + strings.TrimSuffix(strings.TrimPrefix(column.JSONPath, "{"), "}") + "}"); err != nil {
+			return nil, fmt.Errorf("invalid JSONPath %q for column %s: %w", column.JSONPath, column.Name, err)
+		}
+		result.paths[i] = path
+	}
+	return result, nil
+}
+
+func (c *ColumnTableConvertor) ConvertToTable(_ context.Context, object runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
+	table := &metav1.Table{}
+	if opt, ok := tableOptions.(*metav1.TableOptions); !ok || !opt.NoHeaders {
+		table.ColumnDefinitions = append(table.ColumnDefinitions, metav1.TableColumnDefinition{
+			Name: "Name", Type: "string", Format: "name", Description: "Name must be unique within a namespace.",
+		})
+		for _, column := range c.columns {
+			table.ColumnDefinitions = append(table.ColumnDefinitions, column.TableColumnDefinition)
+		}
+		table.ColumnDefinitions = append(table.ColumnDefinitions, metav1.TableColumnDefinition{
+			Name: "Age", Type: "date", Description: "CreationTimestamp is the time the object was created.",
+		})
+	}
+
+	addRow := func(obj runtime.Object) error {
+		row, err := c.row(obj)
+		if err != nil {
+			return err
+		}
+		table.Rows = append(table.Rows, row)
+		return nil
+	}
+
+	if meta.IsListType(object) {
+		if err := meta.EachListItem(object, addRow); err != nil {
+			return nil, err
+		}
+		if list, err := meta.ListAccessor(object); err == nil {
+			table.ResourceVersion = list.GetResourceVersion()
+			table.Continue = list.GetContinue()
+			table.RemainingItemCount = list.GetRemainingItemCount()
+		}
+	} else {
+		if err := addRow(object); err != nil {
+			return nil, err
+		}
+		if obj, err := meta.Accessor(object); err == nil {
+			table.ResourceVersion = obj.GetResourceVersion()
+		}
+	}
+	return table, nil
+}
+
+func (c *ColumnTableConvertor) row(obj runtime.Object) (metav1.TableRow, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return metav1.TableRow{}, err
+	}
+
+	var data map[string]any
+	row := metav1.TableRow{
+		Object: runtime.RawExtension{Object: obj},
+		Cells:  []any{accessor.GetName()},
+	}
+	for i, column := range c.columns {
+		var value any
+		if column.Func != nil {
+			value, err = column.Func(obj)
+		} else {
+			if data == nil {
+				if data, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+					return metav1.TableRow{}, err
+				}
+			}
+			value, err = c.lookup(i, data)
+		}
+		if err != nil {
+			return metav1.TableRow{}, err
+		}
+		row.Cells = append(row.Cells, formatCell(column.Type, value))
+	}
+	row.Cells = append(row.Cells, age(accessor.GetCreationTimestamp()))
+	return row, nil
+}
+
+// lookup returns the first value that the JSONPath of column i finds in data, or nil.
+func (c *ColumnTableConvertor) lookup(i int, data map[string]any) (any, error) {
+	results, err := c.paths[i].FindResults(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 || len(results[0]) == 0 || !results[0][0].CanInterface() {
+		return nil, nil
+	}
+	return results[0][0].Interface(), nil
+}
+
+func formatCell(columnType string, value any) any {
+	switch {
+	case value == nil:
+		return nil
+	case columnType == "date":
+		if s, ok := value.(string); ok {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				return age(metav1.NewTime(t))
+			}
+		} else if t, ok := value.(metav1.Time); ok {
+			return age(t)
+		}
+	case columnType == "integer" || columnType == "number" || columnType == "boolean":
+		return value
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t.Time))
+}
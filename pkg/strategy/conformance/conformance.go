@@ -0,0 +1,265 @@
+// Package conformance is a suite of behavioral tests that every implementation of strategy.CompleteStrategy is
+// expected to pass, so that controllers and clients see the same semantics whatever the backend: resource versions,
+// conflicts, the order of watch events, pagination and the expiry of compacted resource versions.
+//
+// Run the suite from a test of the implementation:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Backend{
+//			New: func(t *testing.T) strategy.CompleteStrategy {
+//				return newMyStrategy(t, &corev1.ConfigMap{})
+//			},
+//		})
+//	}
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// Timeout is how long the tests wait for a watch event.
+var Timeout = 10 * time.Second
+
+// Backend creates the strategies under test.
+type Backend struct {
+	// New returns a strategy for an empty table. The tests create their objects with the New method of the strategy,
+	// only setting the name, namespace, UID and labels, so the kind must accept objects without a spec.
+	New func(t *testing.T) strategy.CompleteStrategy
+	// Compact compacts the history of s, so that every resource version older than the latest write expires. The
+	// compaction tests are skipped if it is nil.
+	Compact func(t *testing.T, s strategy.CompleteStrategy)
+}
+
+type test struct {
+	name string
+	run  func(t *testing.T, b Backend)
+}
+
+var tests = []test{
+	{name: "CreateGet", run: testCreateGet},
+	{name: "CreateAlreadyExists", run: testCreateAlreadyExists},
+	{name: "ResourceVersionIncreases", run: testResourceVersionIncreases},
+	{name: "UpdateConflict", run: testUpdateConflict},
+	{name: "UpdateNotFound", run: testUpdateNotFound},
+	{name: "Delete", run: testDelete},
+	{name: "ListResourceVersion", run: testListResourceVersion},
+	{name: "ListSelectors", run: testListSelectors},
+	{name: "Pagination", run: testPagination},
+	{name: "PaginationSnapshot", run: testPaginationSnapshot},
+	{name: "WatchFromResourceVersion", run: testWatchFromResourceVersion},
+	{name: "WatchWithoutResourceVersion", run: testWatchWithoutResourceVersion},
+	{name: "WatchOrdering", run: testWatchOrdering},
+	{name: "WatchSelectors", run: testWatchSelectors},
+	{name: "CompactionExpiry", run: testCompactionExpiry},
+}
+
+// Run runs every test of the suite against the strategies of b, each in a subtest with a new strategy.
+func Run(t *testing.T, b Backend) {
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, b)
+		})
+	}
+}
+
+// suite holds the strategy of a single test.
+type suite struct {
+	t         *testing.T
+	ctx       context.Context
+	s         strategy.CompleteStrategy
+	namespace string
+}
+
+func newSuite(t *testing.T, b Backend) *suite {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := &suite{
+		t:   t,
+		ctx: ctx,
+		s:   b.New(t),
+	}
+	if strategy.NewScoper(s.s).NamespaceScoped() {
+		s.namespace = "conformance"
+	}
+	return s
+}
+
+func (s *suite) newObject(name string, labels map[string]string) types.Object {
+	obj := s.s.New()
+	obj.SetName(name)
+	obj.SetNamespace(s.namespace)
+	obj.SetUID(uuid.NewUUID())
+	obj.SetLabels(labels)
+	return obj
+}
+
+func (s *suite) create(name string, labels map[string]string) types.Object {
+	s.t.Helper()
+
+	obj, err := s.s.Create(s.ctx, s.newObject(name, labels))
+	if err != nil {
+		s.t.Fatalf("failed to create %s: %v", name, err)
+	}
+	return obj
+}
+
+// update sets the label "value" of obj, which must be the latest revision, to value.
+func (s *suite) update(obj types.Object, value string) types.Object {
+	s.t.Helper()
+
+	obj = obj.DeepCopyObject().(types.Object)
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["value"] = value
+	obj.SetLabels(labels)
+
+	result, err := s.s.Update(s.ctx, obj)
+	if err != nil {
+		s.t.Fatalf("failed to update %s: %v", obj.GetName(), err)
+	}
+	return result
+}
+
+func (s *suite) delete(obj types.Object) types.Object {
+	s.t.Helper()
+
+	result, err := s.s.Delete(s.ctx, obj)
+	if err != nil {
+		s.t.Fatalf("failed to delete %s: %v", obj.GetName(), err)
+	}
+	return result
+}
+
+func (s *suite) list(opts storage.ListOptions) (types.ObjectList, []types.Object) {
+	s.t.Helper()
+
+	list, err := s.s.List(s.ctx, s.namespace, withDefaults(opts))
+	if err != nil {
+		s.t.Fatalf("failed to list: %v", err)
+	}
+	var objs []types.Object
+	if err := meta.EachListItem(list, func(obj runtime.Object) error {
+		objs = append(objs, obj.(types.Object))
+		return nil
+	}); err != nil {
+		s.t.Fatal(err)
+	}
+	return list, objs
+}
+
+func (s *suite) watch(opts storage.ListOptions) <-chan watch.Event {
+	s.t.Helper()
+
+	w, err := s.s.Watch(s.ctx, s.namespace, withDefaults(opts))
+	if err != nil {
+		s.t.Fatalf("failed to watch: %v", err)
+	}
+	return w
+}
+
+// next returns the next event of w, failing the test if none arrives before Timeout.
+func (s *suite) next(w <-chan watch.Event) watch.Event {
+	s.t.Helper()
+
+	select {
+	case event, ok := <-w:
+		if !ok {
+			s.t.Fatal("watch closed")
+		}
+		return event
+	case <-time.After(Timeout):
+		s.t.Fatal("timed out waiting for watch event")
+	}
+	return watch.Event{}
+}
+
+// expectEvent asserts that the next event of w is of type eventType for the revision of obj.
+func (s *suite) expectEvent(w <-chan watch.Event, eventType watch.EventType, obj types.Object) {
+	s.t.Helper()
+
+	event := s.next(w)
+	got, ok := event.Object.(types.Object)
+	if event.Type != eventType || !ok || got.GetName() != obj.GetName() ||
+		got.GetResourceVersion() != obj.GetResourceVersion() {
+		s.t.Fatalf("expected %s event of %s at resource version %s, got %s", eventType, obj.GetName(),
+			obj.GetResourceVersion(), describe(event))
+	}
+}
+
+// expectInitialEvent asserts that the next event of w, which is part of the initial events of a watch without a
+// resource version, is for the revision of obj. The API server sends the existing objects as Added, a backend may also
+// send an updated object as Modified.
+func (s *suite) expectInitialEvent(w <-chan watch.Event, obj types.Object) {
+	s.t.Helper()
+
+	event := s.next(w)
+	got, ok := event.Object.(types.Object)
+	if (event.Type != watch.Added && event.Type != watch.Modified) || !ok || got.GetName() != obj.GetName() ||
+		got.GetResourceVersion() != obj.GetResourceVersion() {
+		s.t.Fatalf("expected initial event of %s at resource version %s, got %s", obj.GetName(),
+			obj.GetResourceVersion(), describe(event))
+	}
+}
+
+func withDefaults(opts storage.ListOptions) storage.ListOptions {
+	if opts.Predicate.Label == nil {
+		opts.Predicate.Label = labels.Everything()
+	}
+	if opts.Predicate.Field == nil {
+		opts.Predicate.Field = fields.Everything()
+	}
+	return opts
+}
+
+func describe(event watch.Event) string {
+	if obj, ok := event.Object.(types.Object); ok {
+		return fmt.Sprintf("%s event of %s at resource version %s", event.Type, obj.GetName(), obj.GetResourceVersion())
+	}
+	if status, ok := event.Object.(*metav1.Status); ok {
+		return fmt.Sprintf("%s event: %s", event.Type, status.Message)
+	}
+	return fmt.Sprintf("%s event of %T", event.Type, event.Object)
+}
+
+func resourceVersion(t *testing.T, obj interface{ GetResourceVersion() string }) int64 {
+	t.Helper()
+
+	rv, err := strconv.ParseInt(obj.GetResourceVersion(), 10, 64)
+	if err != nil || rv <= 0 {
+		t.Fatalf("resource version %q is not a positive integer", obj.GetResourceVersion())
+	}
+	return rv
+}
+
+func names(objs []types.Object) []string {
+	var result []string
+	for _, obj := range objs {
+		result = append(result, obj.GetName())
+	}
+	return result
+}
+
+func isExpired(err error) bool {
+	return apierrors.IsResourceExpired(err) || apierrors.IsGone(err)
+}
@@ -0,0 +1,445 @@
+package conformance
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// testCreateGet checks that a created object gets a resource version and is read back at it.
+func testCreateGet(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	created := s.create("a", map[string]string{"app": "test"})
+	resourceVersion(t, created)
+	if ts := created.GetCreationTimestamp(); ts.IsZero() {
+		t.Error("created object has no creation timestamp")
+	}
+
+	got, err := s.s.Get(s.ctx, s.namespace, "a")
+	if err != nil {
+		t.Fatalf("failed to get created object: %v", err)
+	}
+	if got.GetResourceVersion() != created.GetResourceVersion() || got.GetUID() != created.GetUID() {
+		t.Errorf("got resource version %s and UID %s, expected %s and %s", got.GetResourceVersion(), got.GetUID(),
+			created.GetResourceVersion(), created.GetUID())
+	}
+	if got.GetLabels()["app"] != "test" {
+		t.Errorf("labels of created object were not stored: %v", got.GetLabels())
+	}
+
+	if _, err := s.s.Get(s.ctx, s.namespace, "missing"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected NotFound getting a missing object, got %v", err)
+	}
+}
+
+// testCreateAlreadyExists checks that a name can't be created twice.
+func testCreateAlreadyExists(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	s.create("a", nil)
+	if _, err := s.s.Create(s.ctx, s.newObject("a", nil)); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("expected AlreadyExists creating an existing object, got %v", err)
+	}
+}
+
+// testResourceVersionIncreases checks that every write gets a resource version greater than every earlier write of
+// the table, whatever the object.
+func testResourceVersionIncreases(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	var last int64
+	check := func(what string, rv int64) {
+		t.Helper()
+		if rv <= last {
+			t.Fatalf("%s got resource version %d, not greater than the previous write %d", what, rv, last)
+		}
+		last = rv
+	}
+
+	a := s.create("a", nil)
+	check("create of a", resourceVersion(t, a))
+	bObj := s.create("b", nil)
+	check("create of b", resourceVersion(t, bObj))
+	a = s.update(a, "1")
+	check("update of a", resourceVersion(t, a))
+	bObj = s.update(bObj, "1")
+	check("update of b", resourceVersion(t, bObj))
+	a = s.delete(a)
+	check("delete of a", resourceVersion(t, a))
+
+	got, err := s.s.Get(s.ctx, s.namespace, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetResourceVersion() != bObj.GetResourceVersion() {
+		t.Errorf("get returned resource version %s, expected the resource version %s of the update",
+			got.GetResourceVersion(), bObj.GetResourceVersion())
+	}
+}
+
+// testUpdateConflict checks that an update or delete of a stale revision is rejected with a conflict.
+func testUpdateConflict(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	stale := s.create("a", nil)
+	latest := s.update(stale, "1")
+
+	stale = stale.DeepCopyObject().(types.Object)
+	stale.SetLabels(map[string]string{"value": "2"})
+	if _, err := s.s.Update(s.ctx, stale); !apierrors.IsConflict(err) {
+		t.Errorf("expected Conflict updating a stale revision, got %v", err)
+	}
+	if _, err := s.s.Delete(s.ctx, stale); !apierrors.IsConflict(err) {
+		t.Errorf("expected Conflict deleting a stale revision, got %v", err)
+	}
+
+	// The rejected writes changed nothing
+	got, err := s.s.Get(s.ctx, s.namespace, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetResourceVersion() != latest.GetResourceVersion() || got.GetLabels()["value"] != "1" {
+		t.Errorf("rejected writes changed the object to resource version %s with labels %v",
+			got.GetResourceVersion(), got.GetLabels())
+	}
+}
+
+// testUpdateNotFound checks that an update of a missing object fails without creating it. The storage may report it
+// as a conflict with the missing revision, since the API server looks the object up before updating it.
+func testUpdateNotFound(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	obj := s.newObject("missing", nil)
+	obj.SetResourceVersion("1")
+	if _, err := s.s.Update(s.ctx, obj); !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		t.Errorf("expected NotFound or Conflict updating a missing object, got %v", err)
+	}
+	if _, err := s.s.Get(s.ctx, s.namespace, "missing"); !apierrors.IsNotFound(err) {
+		t.Errorf("update of a missing object created it: %v", err)
+	}
+}
+
+// testDelete checks that a deleted object is gone and its name can be created again.
+func testDelete(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	created := s.create("a", nil)
+	deleted := s.delete(created)
+	if resourceVersion(t, deleted) <= resourceVersion(t, created) {
+		t.Errorf("delete returned resource version %s, not greater than %s", deleted.GetResourceVersion(),
+			created.GetResourceVersion())
+	}
+
+	if _, err := s.s.Get(s.ctx, s.namespace, "a"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected NotFound getting a deleted object, got %v", err)
+	}
+	if _, objs := s.list(storage.ListOptions{}); len(objs) != 0 {
+		t.Errorf("list returned deleted objects %v", names(objs))
+	}
+
+	recreated := s.create("a", nil)
+	if recreated.GetUID() == created.GetUID() {
+		t.Error("recreated object has the UID of the deleted object")
+	}
+}
+
+// testListResourceVersion checks that the resource version of a list is at least that of every write before it, and
+// that an empty list has one too.
+func testListResourceVersion(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	list, objs := s.list(storage.ListOptions{})
+	if len(objs) != 0 {
+		t.Fatalf("expected an empty list, got %v", names(objs))
+	}
+	if list.GetResourceVersion() == "" {
+		t.Error("empty list has no resource version")
+	}
+
+	a := s.create("a", nil)
+	bObj := s.update(s.create("b", nil), "1")
+
+	list, objs = s.list(storage.ListOptions{})
+	if len(objs) != 2 {
+		t.Fatalf("expected 2 objects, got %v", names(objs))
+	}
+	listRV := resourceVersion(t, list)
+	for _, obj := range []types.Object{a, bObj} {
+		if listRV < resourceVersion(t, obj) {
+			t.Errorf("list resource version %d is older than the write of %s at %s", listRV, obj.GetName(),
+				obj.GetResourceVersion())
+		}
+	}
+	for _, obj := range objs {
+		if obj.GetName() == "b" && obj.GetResourceVersion() != bObj.GetResourceVersion() {
+			t.Errorf("list returned b at resource version %s, expected %s", obj.GetResourceVersion(),
+				bObj.GetResourceVersion())
+		}
+	}
+
+	// Resource versions of lists never go back
+	next, _ := s.list(storage.ListOptions{})
+	if resourceVersion(t, next) < listRV {
+		t.Errorf("list resource version went back from %d to %s", listRV, next.GetResourceVersion())
+	}
+}
+
+// testListSelectors checks that lists only return the objects matching the label selector.
+func testListSelectors(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	s.create("a", map[string]string{"app": "one"})
+	s.create("b", map[string]string{"app": "two"})
+	s.create("c", map[string]string{"app": "one"})
+
+	selector, err := labels.Parse("app=one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, objs := s.list(storage.ListOptions{
+		Predicate: storage.SelectionPredicate{Label: selector},
+	})
+	if got := names(objs); !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("expected a and c to match the selector, got %v", got)
+	}
+}
+
+// testPagination checks that paginated lists return every object exactly once, in the same order as an unpaginated
+// list, with the resource version of the first page.
+func testPagination(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	for i := range 7 {
+		s.create("obj"+strconv.Itoa(i), nil)
+	}
+	all, expected := s.list(storage.ListOptions{})
+
+	var (
+		got   []string
+		opts  = storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 3}}
+		pages int
+	)
+	for {
+		list, objs := s.list(opts)
+		pages++
+		if len(objs) > 3 {
+			t.Fatalf("page %d has %d objects, more than the limit 3", pages, len(objs))
+		}
+		if list.GetResourceVersion() != all.GetResourceVersion() {
+			t.Errorf("page %d has resource version %s, expected %s", pages, list.GetResourceVersion(),
+				all.GetResourceVersion())
+		}
+		got = append(got, names(objs)...)
+		if list.GetContinue() == "" {
+			break
+		}
+		if pages > 7 {
+			t.Fatal("pagination doesn't end")
+		}
+		opts.Predicate.Continue = list.GetContinue()
+	}
+	if !slices.Equal(got, names(expected)) {
+		t.Errorf("pages returned %v, expected %v", got, names(expected))
+	}
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+}
+
+// testPaginationSnapshot checks that the pages of a list are consistent with its first page: objects written after
+// it are returned as they were at the resource version of the list.
+func testPaginationSnapshot(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	var objs []types.Object
+	for i := range 4 {
+		objs = append(objs, s.create("obj"+strconv.Itoa(i), nil))
+	}
+
+	first, page := s.list(storage.ListOptions{Predicate: storage.SelectionPredicate{Limit: 2}})
+	if first.GetContinue() == "" {
+		t.Fatal("expected a continue token")
+	}
+	got := names(page)
+
+	// Written after the first page
+	s.create("obj9", nil)
+	for _, obj := range objs {
+		if !slices.Contains(got, obj.GetName()) {
+			s.update(obj, "1")
+		}
+	}
+
+	list, page := s.list(storage.ListOptions{Predicate: storage.SelectionPredicate{Continue: first.GetContinue()}})
+	if list.GetResourceVersion() != first.GetResourceVersion() {
+		t.Errorf("continued list has resource version %s, expected %s", list.GetResourceVersion(),
+			first.GetResourceVersion())
+	}
+	for _, obj := range page {
+		if obj.GetName() == "obj9" {
+			t.Error("continued list returned an object created after the first page")
+		}
+		if obj.GetLabels()["value"] != "" {
+			t.Errorf("continued list returned %s as updated after the first page", obj.GetName())
+		}
+	}
+	if got := append(got, names(page)...); len(got) != len(objs) {
+		t.Errorf("pages returned %v, expected the %d objects of the first page's resource version", got, len(objs))
+	}
+}
+
+// testWatchFromResourceVersion checks that a watch from a resource version sends every later write, and only those,
+// in order.
+func testWatchFromResourceVersion(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	a := s.create("a", nil)
+	bObj := s.create("b", nil)
+	a = s.update(a, "1")
+
+	w := s.watch(storage.ListOptions{ResourceVersion: bObj.GetResourceVersion()})
+	s.expectEvent(w, watch.Modified, a)
+
+	c := s.create("c", nil)
+	bObj = s.update(bObj, "1")
+	a = s.delete(a)
+	s.expectEvent(w, watch.Added, c)
+	s.expectEvent(w, watch.Modified, bObj)
+	s.expectEvent(w, watch.Deleted, a)
+}
+
+// testWatchWithoutResourceVersion checks that a watch without a resource version starts with an event for the latest
+// revision of every existing object and continues with the later writes.
+func testWatchWithoutResourceVersion(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	a := s.update(s.create("a", nil), "1")
+	bObj := s.create("b", nil)
+
+	w := s.watch(storage.ListOptions{})
+	s.expectInitialEvent(w, a)
+	s.expectInitialEvent(w, bObj)
+
+	bObj = s.update(bObj, "1")
+	s.expectEvent(w, watch.Modified, bObj)
+}
+
+// testWatchOrdering checks that the events of concurrent writes are sent once each in increasing resource version
+// order.
+func testWatchOrdering(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	list, _ := s.list(storage.ListOptions{})
+	w := s.watch(storage.ListOptions{ResourceVersion: list.GetResourceVersion()})
+
+	const writers, writes = 4, 5
+	errs := make(chan error, writers)
+	for i := range writers {
+		go func() {
+			obj, err := s.s.Create(s.ctx, s.newObject("obj"+strconv.Itoa(i), nil))
+			for j := 0; err == nil && j < writes-1; j++ {
+				obj = obj.DeepCopyObject().(types.Object)
+				obj.SetLabels(map[string]string{"value": strconv.Itoa(j)})
+				obj, err = s.s.Update(s.ctx, obj)
+			}
+			errs <- err
+		}()
+	}
+	for range writers {
+		if err := <-errs; err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+
+	var (
+		last int64
+		seen = map[string]bool{}
+	)
+	for range writers * writes {
+		event := s.next(w)
+		obj, ok := event.Object.(types.Object)
+		if !ok {
+			t.Fatalf("unexpected %s", describe(event))
+		}
+		rv := resourceVersion(t, obj)
+		if rv <= last {
+			t.Fatalf("%s after resource version %d", describe(event), last)
+		}
+		last = rv
+
+		expected := watch.Modified
+		if !seen[obj.GetName()] {
+			expected = watch.Added
+		}
+		if event.Type != expected {
+			t.Errorf("expected %s, got %s", expected, describe(event))
+		}
+		seen[obj.GetName()] = true
+	}
+}
+
+// testWatchSelectors checks that a watch only sends the events of objects matching its label selector.
+func testWatchSelectors(t *testing.T, b Backend) {
+	s := newSuite(t, b)
+
+	selector, err := labels.Parse("app=one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := s.list(storage.ListOptions{})
+	w := s.watch(storage.ListOptions{
+		ResourceVersion: list.GetResourceVersion(),
+		Predicate:       storage.SelectionPredicate{Label: selector},
+	})
+
+	a := s.create("a", map[string]string{"app": "two"})
+	bObj := s.create("b", map[string]string{"app": "one"})
+	s.update(a, "1")
+	updated := s.update(bObj, "1")
+	s.expectEvent(w, watch.Added, bObj)
+	s.expectEvent(w, watch.Modified, updated)
+}
+
+// testCompactionExpiry checks that lists and watches from a compacted resource version fail with an expired error,
+// while those from the latest resource version don't.
+func testCompactionExpiry(t *testing.T, b Backend) {
+	if b.Compact == nil {
+		t.Skip("the backend doesn't compact")
+	}
+	s := newSuite(t, b)
+
+	a := s.create("a", nil)
+	old := a.GetResourceVersion()
+	a = s.update(a, "1")
+	a = s.update(a, "2")
+	b.Compact(t, s.s)
+
+	if _, err := s.s.List(s.ctx, s.namespace, withDefaults(storage.ListOptions{ResourceVersion: old})); !isExpired(err) {
+		t.Errorf("expected an expired error listing at compacted resource version %s, got %v", old, err)
+	}
+
+	w, err := s.s.Watch(s.ctx, s.namespace, withDefaults(storage.ListOptions{ResourceVersion: old}))
+	if err == nil {
+		event := s.next(w)
+		status, ok := event.Object.(*metav1.Status)
+		if event.Type != watch.Error || !ok || status.Code != 410 {
+			t.Errorf("expected an expired error event watching from compacted resource version %s, got %s", old,
+				describe(event))
+		}
+	} else if !isExpired(err) {
+		t.Errorf("expected an expired error watching from compacted resource version %s, got %v", old, err)
+	}
+
+	// The latest resource version is still valid
+	w = s.watch(storage.ListOptions{ResourceVersion: a.GetResourceVersion()})
+	a = s.update(a, "3")
+	s.expectEvent(w, watch.Modified, a)
+}
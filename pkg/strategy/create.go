@@ -192,3 +192,14 @@ func (a *CreateAdapter) NamespaceScoped() bool {
 	}
 	return true
 }
+
+// Defaulter sets default values for unset fields of an object before it is created or updated.
+type Defaulter interface {
+	Default(ctx context.Context, obj runtime.Object)
+}
+
+// ObjectDefaulter is implemented by objects that set default values for their own unset fields. Strategies that
+// support it call Default before every create and update.
+type ObjectDefaulter interface {
+	Default()
+}
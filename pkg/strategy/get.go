@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apiserver/pkg/endpoints/request"
@@ -16,6 +17,24 @@ type Getter interface {
 	Get(ctx context.Context, namespace, name string) (types.Object, error)
 }
 
+// OptionsGetter is implemented by strategies that support reading an object at the resourceVersion of GetOptions.
+type OptionsGetter interface {
+	GetWithOptions(ctx context.Context, namespace, name string, opts metav1.GetOptions) (types.Object, error)
+}
+
+// GetWithOptions reads the named object from s at the resourceVersion of opts. An empty resourceVersion or "0" reads the
+// latest revision. Other resourceVersions need s to implement OptionsGetter, and return a BadRequest error otherwise
+// rather than the latest revision. Decorators of strategies implement OptionsGetter with it.
+func GetWithOptions(ctx context.Context, s Getter, namespace, name string, opts metav1.GetOptions) (types.Object, error) {
+	if o, ok := s.(OptionsGetter); ok {
+		return o.GetWithOptions(ctx, namespace, name, opts)
+	}
+	if opts.ResourceVersion != "" && opts.ResourceVersion != "0" {
+		return nil, apierrors.NewBadRequest("resourceVersion is not supported by this storage")
+	}
+	return s.Get(ctx, namespace, name)
+}
+
 func NewGet(strategy Getter) *GetAdapter {
 	return &GetAdapter{
 		strategy: strategy,
@@ -28,5 +47,8 @@ type GetAdapter struct {
 
 func (a *GetAdapter) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
 	ns, _ := request.NamespaceFrom(ctx)
+	if options != nil && options.ResourceVersion != "" {
+		return GetWithOptions(ctx, a.strategy, ns, name, *options)
+	}
 	return a.strategy.Get(ctx, ns, name)
 }
@@ -0,0 +1,163 @@
+package strategy
+
+import (
+	"context"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/klog/v2"
+)
+
+// GracefulDeleteFinalizer keeps an object whose grace period hasn't expired from being removed. The
+// GracefulDeleteAdapter removes it once the grace period has passed.
+const GracefulDeleteFinalizer = "kinm.obot.ai/graceful-delete"
+
+type GracefulDeleter interface {
+	Deleter
+	Lister
+}
+
+var _ rest.GracefulDeleter = (*GracefulDeleteAdapter)(nil)
+
+// GracefulDeleteAdapter deletes objects like core resources do: the deletion timestamp is set to the end of the
+// grace period and the object is only removed once the grace period has expired and no finalizers remain. Reap, or
+// Run, must be called to remove objects whose grace period has expired.
+type GracefulDeleteAdapter struct {
+	strategy                  GracefulDeleter
+	DefaultGracePeriodSeconds int64
+	ValidateDeleter           ValidateDeleter
+}
+
+func NewGracefulDelete(strategy GracefulDeleter, defaultGracePeriodSeconds int64) *GracefulDeleteAdapter {
+	return &GracefulDeleteAdapter{
+		strategy:                  strategy,
+		DefaultGracePeriodSeconds: defaultGracePeriodSeconds,
+	}
+}
+
+func (a *GracefulDeleteAdapter) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions) (runtime.Object, bool, error) {
+	ns, _ := genericapirequest.NamespaceFrom(ctx)
+	obj, err := a.strategy.Get(ctx, ns, name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if options == nil {
+		options = &metav1.DeleteOptions{}
+	}
+	gracePeriod := a.DefaultGracePeriodSeconds
+	if options.GracePeriodSeconds != nil {
+		gracePeriod = *options.GracePeriodSeconds
+	}
+	if gracePeriod < 0 {
+		return nil, false, apierrors.NewBadRequest("gracePeriodSeconds must not be negative")
+	}
+
+	if options.Preconditions != nil {
+		preconditions := storage.Preconditions{
+			UID:             options.Preconditions.UID,
+			ResourceVersion: options.Preconditions.ResourceVersion,
+		}
+		if err := preconditions.Check(name, obj); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if deleteValidation != nil {
+		if err := deleteValidation(ctx, obj); err != nil {
+			return nil, false, err
+		}
+	}
+
+	now := time.Now()
+	deadline := metav1.NewTime(now.Add(time.Duration(gracePeriod) * time.Second))
+	if current := obj.GetDeletionTimestamp(); current != nil {
+		// An object that is already being deleted can only have its grace period shortened
+		if !deadline.Before(current) {
+			return obj, false, nil
+		}
+	} else if a.ValidateDeleter != nil {
+		if err := a.ValidateDeleter.ValidateDelete(ctx, obj); err != nil {
+			return nil, false, err
+		}
+	}
+
+	obj.SetDeletionTimestamp(&deadline)
+	obj.SetDeletionGracePeriodSeconds(&gracePeriod)
+	finalizers := sets.New(obj.GetFinalizers()...)
+	if gracePeriod > 0 {
+		finalizers.Insert(GracefulDeleteFinalizer)
+	} else {
+		finalizers.Delete(GracefulDeleteFinalizer)
+	}
+	obj.SetFinalizers(withFinalizers(obj.GetFinalizers(), finalizers))
+
+	if len(options.DryRun) != 0 && options.DryRun[0] == metav1.DryRunAll {
+		return obj, false, nil
+	}
+
+	result, err := a.strategy.Delete(ctx, obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return result, len(result.GetFinalizers()) == 0, nil
+}
+
+// withFinalizers returns the finalizers in set, keeping the order of existing.
+func withFinalizers(existing []string, set sets.Set[string]) []string {
+	var result []string
+	for _, finalizer := range existing {
+		if set.Has(finalizer) {
+			result = append(result, finalizer)
+			set.Delete(finalizer)
+		}
+	}
+	return append(result, sets.List(set)...)
+}
+
+// Reap removes the GracefulDeleteFinalizer from objects whose grace period has expired, which removes them unless
+// other finalizers remain.
+func (a *GracefulDeleteAdapter) Reap(ctx context.Context) error {
+	list, err := a.strategy.List(ctx, "", storage.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return meta.EachListItem(list, func(item runtime.Object) error {
+		obj := item.(types.Object)
+		deadline := obj.GetDeletionTimestamp()
+		if deadline == nil || deadline.After(now) || !sets.New(obj.GetFinalizers()...).Has(GracefulDeleteFinalizer) {
+			return nil
+		}
+
+		finalizers := sets.New(obj.GetFinalizers()...)
+		finalizers.Delete(GracefulDeleteFinalizer)
+		obj.SetFinalizers(withFinalizers(obj.GetFinalizers(), finalizers))
+		if _, err := a.strategy.Delete(ctx, obj); apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			// The object changed, it will be checked again on the next pass
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// Run calls Reap every interval until ctx is done.
+func (a *GracefulDeleteAdapter) Run(ctx context.Context, interval time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := a.Reap(ctx); err != nil {
+			klog.Errorf("failed to remove objects whose grace period has expired: %v", err)
+		}
+	}, interval)
+}
@@ -95,6 +95,12 @@ func (l *ListAdapter) ListPredicate(ctx context.Context, p storage.SelectionPred
 		ResourceVersionMatch: options.ResourceVersionMatch,
 		Predicate:            p,
 	}
+	if searcher, ok := l.strategy.(Searcher); ok {
+		if query, field, ok := searchQuery(p.Field); ok {
+			storageOpts.Predicate.Field = field
+			return searcher.Search(ctx, ns, query, storageOpts)
+		}
+	}
 	if name, ok := p.MatchesSingle(); ok {
 		if gtl, ok := l.strategy.(GetToLister); ok {
 			return gtl.GetToList(ctx, ns, name)
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// Authorization checks every operation against authz using the user in the context. Operations without a user in the
+// context are forbidden. The resource is guessed from the kind, and status updates are checked as updates of the
+// status subresource.
+func Authorization(authz authorizer.Authorizer) Middleware {
+	return Intercept(func(ctx context.Context, op Operation, next func(ctx context.Context) error) error {
+		resource, _ := meta.UnsafeGuessKindToResource(op.GroupVersionKind)
+
+		u, ok := request.UserFrom(ctx)
+		if !ok {
+			return apierrors.NewForbidden(resource.GroupResource(), op.Name, errors.New("no user in request context"))
+		}
+
+		attr := authorizer.AttributesRecord{
+			User:            u,
+			Verb:            string(op.Verb),
+			Namespace:       op.Namespace,
+			APIGroup:        op.GroupVersionKind.Group,
+			APIVersion:      op.GroupVersionKind.Version,
+			Resource:        resource.Resource,
+			Name:            op.Name,
+			ResourceRequest: true,
+		}
+		if op.Verb == VerbUpdateStatus {
+			attr.Verb = string(VerbUpdate)
+			attr.Subresource = "status"
+		}
+
+		decision, reason, err := authz.Authorize(ctx, attr)
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		if decision != authorizer.DecisionAllow {
+			if reason == "" {
+				reason = "access denied"
+			}
+			return apierrors.NewForbidden(resource.GroupResource(), op.Name,
+				fmt.Errorf("user %q cannot %s: %s", u.GetName(), attr.Verb, reason))
+		}
+		return next(ctx)
+	})
+}
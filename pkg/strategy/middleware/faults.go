@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// FaultConfig configures the failures injected by Faults. Rates are probabilities between 0 and 1.
+type FaultConfig struct {
+	// Seed seeds the random decisions, so a test making the same calls in the same order sees the same failures.
+	Seed int64
+	// Verbs limits the conflicts, timeouts and latency to operations with these verbs. All verbs are affected if
+	// empty.
+	Verbs []Verb
+	// ConflictRate is the rate at which updates, status updates and deletes fail with a Conflict error, as if the
+	// object had been modified concurrently.
+	ConflictRate float64
+	// TimeoutRate is the rate at which operations fail with a Timeout error without reaching the strategy.
+	TimeoutRate float64
+	// LatencyRate is the rate at which operations are delayed by Latency before reaching the strategy.
+	LatencyRate float64
+	Latency     time.Duration
+	// DropEventRate is the rate at which added, modified and deleted watch events are dropped.
+	DropEventRate float64
+}
+
+// Faults returns a Middleware that injects failures into the operations of a strategy, for testing how controllers
+// cope with storage errors. Injected errors are the same API errors the database strategy returns, so clients handle
+// them as they would real ones.
+func Faults(config FaultConfig) Middleware {
+	f := &faults{
+		config: config,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+	}
+	intercept := Intercept(f.intercept)
+	return func(next strategy.CompleteStrategy) strategy.CompleteStrategy {
+		if config.DropEventRate > 0 {
+			next = &dropEvents{CompleteStrategy: next, faults: f}
+		}
+		return intercept(next)
+	}
+}
+
+type faults struct {
+	config FaultConfig
+
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+// inject returns true with probability rate.
+func (f *faults) inject(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.rand.Float64() < rate
+}
+
+func (f *faults) intercept(ctx context.Context, op Operation, next func(ctx context.Context) error) error {
+	if len(f.config.Verbs) > 0 && !slices.Contains(f.config.Verbs, op.Verb) {
+		return next(ctx)
+	}
+
+	if f.inject(f.config.LatencyRate) {
+		select {
+		case <-time.After(f.config.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if f.inject(f.config.TimeoutRate) {
+		return apierrors.NewTimeoutError("injected fault", 1)
+	}
+	switch op.Verb {
+	case VerbUpdate, VerbUpdateStatus, VerbDelete:
+		if f.inject(f.config.ConflictRate) {
+			return apierrors.NewConflict(schema.GroupResource{
+				Group:    op.GroupVersionKind.Group,
+				Resource: op.GroupVersionKind.Kind,
+			}, op.Name, errors.New("injected fault"))
+		}
+	}
+	return next(ctx)
+}
+
+var _ strategy.CompleteStrategy = (*dropEvents)(nil)
+
+type dropEvents struct {
+	strategy.CompleteStrategy
+	faults *faults
+}
+
+func (d *dropEvents) NamespaceScoped() bool {
+	return strategy.NewScoper(d.CompleteStrategy).NamespaceScoped()
+}
+
+func (d *dropEvents) GetWithOptions(ctx context.Context, namespace, name string, opts metav1.GetOptions) (types.Object, error) {
+	return strategy.GetWithOptions(ctx, d.CompleteStrategy, namespace, name, opts)
+}
+
+func (d *dropEvents) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	w, err := d.CompleteStrategy.Watch(ctx, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+
+		for event := range w {
+			switch event.Type {
+			case watch.Added, watch.Modified, watch.Deleted:
+				if d.faults.inject(d.faults.config.DropEventRate) {
+					continue
+				}
+			}
+
+			select {
+			case result <- event:
+			case <-ctx.Done():
+				// Drain the underlying watch so its sender is not blocked.
+				for range w {
+				}
+				return
+			}
+		}
+	}()
+
+	return result, nil
+}
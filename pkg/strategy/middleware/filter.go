@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// FilterFunc decides whether the caller in ctx may see obj. It is called for every object returned by Get, List, and
+// Watch. Returning an error fails the whole request, which is useful to deny callers outright.
+type FilterFunc func(ctx context.Context, obj types.Object) (bool, error)
+
+// Filter hides the objects rejected by fn from reads. A rejected Get returns the same NotFound error as a missing
+// object so its existence is not revealed, rejected items are removed from lists, and watch events for rejected
+// objects are dropped. Writes are passed through unchanged; pair Filter with Authorization to restrict them.
+func Filter(fn FilterFunc) Middleware {
+	return func(next strategy.CompleteStrategy) strategy.CompleteStrategy {
+		gvk := types.MustGetGVK(next.New(), next.Scheme())
+		return &filter{
+			CompleteStrategy: next,
+			resource: schema.GroupResource{
+				Group:    gvk.Group,
+				Resource: gvk.Kind,
+			},
+			fn: fn,
+		}
+	}
+}
+
+var _ strategy.CompleteStrategy = (*filter)(nil)
+
+type filter struct {
+	strategy.CompleteStrategy
+	resource schema.GroupResource
+	fn       FilterFunc
+}
+
+func (f *filter) NamespaceScoped() bool {
+	return strategy.NewScoper(f.CompleteStrategy).NamespaceScoped()
+}
+
+func (f *filter) Get(ctx context.Context, namespace, name string) (types.Object, error) {
+	obj, err := f.CompleteStrategy.Get(ctx, namespace, name)
+	return f.object(ctx, name, obj, err)
+}
+
+func (f *filter) GetWithOptions(ctx context.Context, namespace, name string, opts metav1.GetOptions) (types.Object, error) {
+	obj, err := strategy.GetWithOptions(ctx, f.CompleteStrategy, namespace, name, opts)
+	return f.object(ctx, name, obj, err)
+}
+
+// object returns obj, read by a Get of name, if the caller may see it.
+func (f *filter) object(ctx context.Context, name string, obj types.Object, err error) (types.Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := f.fn(ctx, obj); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, apierrors.NewNotFound(f.resource, name)
+	}
+	return obj, nil
+}
+
+func (f *filter) List(ctx context.Context, namespace string, opts storage.ListOptions) (types.ObjectList, error) {
+	list, err := f.CompleteStrategy.List(ctx, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []runtime.Object
+	err = meta.EachListItem(list, func(obj runtime.Object) error {
+		ok, err := f.fn(ctx, obj.(types.Object))
+		if ok {
+			items = append(items, obj)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := meta.SetList(list, items); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (f *filter) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	w, err := f.CompleteStrategy.Watch(ctx, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+
+		for event := range w {
+			switch event.Type {
+			case watch.Added, watch.Modified, watch.Deleted:
+				ok, err := f.fn(ctx, event.Object.(types.Object))
+				if err != nil {
+					event = watch.Event{
+						Type:   watch.Error,
+						Object: &apierrors.NewInternalError(err).ErrStatus,
+					}
+				} else if !ok {
+					continue
+				}
+			}
+
+			select {
+			case result <- event:
+			case <-ctx.Done():
+				// Drain the underlying watch so its sender is not blocked.
+				for range w {
+				}
+				return
+			}
+		}
+	}()
+
+	return result, nil
+}
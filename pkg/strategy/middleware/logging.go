@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logging logs every operation with its outcome and duration to logger, or to the standard logrus logger if logger is
+// nil. Successful operations are logged at debug level and failed ones at info level.
+func Logging(logger logrus.FieldLogger) Middleware {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return Intercept(func(ctx context.Context, op Operation, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+
+		entry := logger.WithFields(logrus.Fields{
+			"verb":      op.Verb,
+			"kind":      op.GroupVersionKind.Kind,
+			"namespace": op.Namespace,
+			"name":      op.Name,
+			"duration":  time.Since(start),
+		})
+		if err != nil {
+			entry.WithError(err).Info("storage operation failed")
+		} else {
+			entry.Debug("storage operation")
+		}
+		return err
+	})
+}
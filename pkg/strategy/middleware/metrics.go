@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Metrics records the count and latency of strategy operations. One Metrics may be shared by the middleware of many
+// strategies, which are told apart by the group and kind labels.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewMetrics creates the kinm_storage_requests_total and kinm_storage_request_duration_seconds collectors and
+// registers them with registerer.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kinm",
+			Subsystem: "storage",
+			Name:      "requests_total",
+			Help:      "Number of storage operations by group, kind, verb, and result code.",
+		}, []string{"group", "kind", "verb", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "kinm",
+			Subsystem: "storage",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of storage operations by group, kind, and verb.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"group", "kind", "verb"}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.duration} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Middleware returns a Middleware that records every operation. Watches are counted when they are opened.
+func (m *Metrics) Middleware() Middleware {
+	return Intercept(func(ctx context.Context, op Operation, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+
+		group, kind, verb := op.GroupVersionKind.Group, op.GroupVersionKind.Kind, string(op.Verb)
+		m.duration.WithLabelValues(group, kind, verb).Observe(time.Since(start).Seconds())
+		m.requests.WithLabelValues(group, kind, verb, code(err)).Inc()
+		return err
+	})
+}
+
+func code(err error) string {
+	if err == nil {
+		return "OK"
+	}
+	if reason := apierrors.ReasonForError(err); reason != "" {
+		return string(reason)
+	}
+	return "Unknown"
+}
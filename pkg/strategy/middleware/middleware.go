@@ -0,0 +1,169 @@
+// Package middleware provides a framework for decorating strategies with cross-cutting behavior such as logging,
+// metrics, and authorization.
+package middleware
+
+import (
+	"context"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// Middleware decorates a strategy.
+type Middleware func(next strategy.CompleteStrategy) strategy.CompleteStrategy
+
+// Chain returns a Middleware that applies middlewares in order, so the first one is the outermost and sees every call
+// first.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next strategy.CompleteStrategy) strategy.CompleteStrategy {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// Wrap decorates s with middlewares, the first being the outermost.
+func Wrap(s strategy.CompleteStrategy, middlewares ...Middleware) strategy.CompleteStrategy {
+	return Chain(middlewares...)(s)
+}
+
+type Verb string
+
+const (
+	VerbCreate       Verb = "create"
+	VerbGet          Verb = "get"
+	VerbList         Verb = "list"
+	VerbWatch        Verb = "watch"
+	VerbUpdate       Verb = "update"
+	VerbUpdateStatus Verb = "update-status"
+	VerbDelete       Verb = "delete"
+)
+
+// Operation describes a single call to a strategy. Name is empty for list and watch.
+type Operation struct {
+	Verb             Verb
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+}
+
+// InterceptFunc is called around each operation of a strategy. It must call next to run the operation, and may skip it
+// by returning an error instead. The error returned by next should be returned unless the interceptor replaces it.
+type InterceptFunc func(ctx context.Context, op Operation, next func(ctx context.Context) error) error
+
+// Intercept returns a Middleware that calls fn around every operation. For watches, fn is called around opening the
+// watch, not for each event.
+func Intercept(fn InterceptFunc) Middleware {
+	return func(next strategy.CompleteStrategy) strategy.CompleteStrategy {
+		return &interceptor{
+			CompleteStrategy: next,
+			gvk:              types.MustGetGVK(next.New(), next.Scheme()),
+			fn:               fn,
+		}
+	}
+}
+
+var _ strategy.CompleteStrategy = (*interceptor)(nil)
+
+type interceptor struct {
+	strategy.CompleteStrategy
+	gvk schema.GroupVersionKind
+	fn  InterceptFunc
+}
+
+func (i *interceptor) NamespaceScoped() bool {
+	return strategy.NewScoper(i.CompleteStrategy).NamespaceScoped()
+}
+
+func (i *interceptor) op(verb Verb, namespace, name string) Operation {
+	return Operation{
+		Verb:             verb,
+		GroupVersionKind: i.gvk,
+		Namespace:        namespace,
+		Name:             name,
+	}
+}
+
+func (i *interceptor) object(ctx context.Context, verb Verb, obj types.Object, call func(ctx context.Context) (types.Object, error)) (result types.Object, err error) {
+	err = i.fn(ctx, i.op(verb, obj.GetNamespace(), obj.GetName()), func(ctx context.Context) error {
+		result, err = call(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (i *interceptor) Create(ctx context.Context, obj types.Object) (types.Object, error) {
+	return i.object(ctx, VerbCreate, obj, func(ctx context.Context) (types.Object, error) {
+		return i.CompleteStrategy.Create(ctx, obj)
+	})
+}
+
+func (i *interceptor) Update(ctx context.Context, obj types.Object) (types.Object, error) {
+	return i.object(ctx, VerbUpdate, obj, func(ctx context.Context) (types.Object, error) {
+		return i.CompleteStrategy.Update(ctx, obj)
+	})
+}
+
+func (i *interceptor) UpdateStatus(ctx context.Context, obj types.Object) (types.Object, error) {
+	return i.object(ctx, VerbUpdateStatus, obj, func(ctx context.Context) (types.Object, error) {
+		return i.CompleteStrategy.UpdateStatus(ctx, obj)
+	})
+}
+
+func (i *interceptor) Delete(ctx context.Context, obj types.Object) (types.Object, error) {
+	return i.object(ctx, VerbDelete, obj, func(ctx context.Context) (types.Object, error) {
+		return i.CompleteStrategy.Delete(ctx, obj)
+	})
+}
+
+func (i *interceptor) Get(ctx context.Context, namespace, name string) (result types.Object, err error) {
+	err = i.fn(ctx, i.op(VerbGet, namespace, name), func(ctx context.Context) error {
+		result, err = i.CompleteStrategy.Get(ctx, namespace, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (i *interceptor) GetWithOptions(ctx context.Context, namespace, name string, opts metav1.GetOptions) (result types.Object, err error) {
+	err = i.fn(ctx, i.op(VerbGet, namespace, name), func(ctx context.Context) error {
+		result, err = strategy.GetWithOptions(ctx, i.CompleteStrategy, namespace, name, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (i *interceptor) List(ctx context.Context, namespace string, opts storage.ListOptions) (result types.ObjectList, err error) {
+	err = i.fn(ctx, i.op(VerbList, namespace, ""), func(ctx context.Context) error {
+		result, err = i.CompleteStrategy.List(ctx, namespace, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (i *interceptor) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (result <-chan watch.Event, err error) {
+	err = i.fn(ctx, i.op(VerbWatch, namespace, ""), func(ctx context.Context) error {
+		result, err = i.CompleteStrategy.Watch(ctx, namespace, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/storage"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Redact removes the fields of the kind tagged `kinm:"redact"`, and the given dot separated paths, from the objects
+// returned to callers that fn rejects, so that secrets embedded in otherwise public objects are only seen by
+// privileged callers. A "*" segment matches every element of an array or value of an object. The objects returned by
+// every operation, including lists, watches and writes, are redacted.
+//
+// Writes are passed through unchanged, so an update by a caller that only saw the redacted object clears the redacted
+// fields; pair Redact with Authorization to restrict writes to privileged callers.
+func Redact(fn FilterFunc, paths ...string) Middleware {
+	return func(next strategy.CompleteStrategy) strategy.CompleteStrategy {
+		return &redact{
+			CompleteStrategy: next,
+			paths:            append(types.TaggedFields(next.New(), "redact"), paths...),
+			fn:               fn,
+		}
+	}
+}
+
+// Authorized returns a FilterFunc that accepts the callers that authz allows to perform verb on subresource of an
+// object, such as "get" on a "secrets" subresource that only privileged roles are granted. The kind of the object is
+// looked up in scheme. Callers without a user in the context are rejected.
+func Authorized(authz authorizer.Authorizer, scheme *runtime.Scheme, verb, subresource string) FilterFunc {
+	return func(ctx context.Context, obj types.Object) (bool, error) {
+		u, ok := request.UserFrom(ctx)
+		if !ok {
+			return false, nil
+		}
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return false, err
+		}
+		resource, _ := meta.UnsafeGuessKindToResource(gvk)
+		decision, _, err := authz.Authorize(ctx, authorizer.AttributesRecord{
+			User:            u,
+			Verb:            verb,
+			Namespace:       obj.GetNamespace(),
+			APIGroup:        gvk.Group,
+			APIVersion:      gvk.Version,
+			Resource:        resource.Resource,
+			Subresource:     subresource,
+			Name:            obj.GetName(),
+			ResourceRequest: true,
+		})
+		return decision == authorizer.DecisionAllow, err
+	}
+}
+
+var _ strategy.CompleteStrategy = (*redact)(nil)
+
+type redact struct {
+	strategy.CompleteStrategy
+	paths []string
+	fn    FilterFunc
+}
+
+func (r *redact) NamespaceScoped() bool {
+	return strategy.NewScoper(r.CompleteStrategy).NamespaceScoped()
+}
+
+// redact returns obj without the redacted fields if the caller may not see them.
+func (r *redact) redact(ctx context.Context, obj types.Object) (types.Object, error) {
+	if obj == nil || len(r.paths) == 0 {
+		return obj, nil
+	}
+	if ok, err := r.fn(ctx, obj); err != nil || ok {
+		return obj, err
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	data, err = types.RewriteFields(data, r.paths, func(string, json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Unmarshal into a new object, since obj may be shared, such as by a cache
+	redacted := r.New()
+	if err := json.Unmarshal(data, redacted); err != nil {
+		return nil, err
+	}
+	return redacted, nil
+}
+
+func (r *redact) result(ctx context.Context, obj types.Object, err error) (types.Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	return r.redact(ctx, obj)
+}
+
+func (r *redact) Create(ctx context.Context, obj types.Object) (types.Object, error) {
+	result, err := r.CompleteStrategy.Create(ctx, obj)
+	return r.result(ctx, result, err)
+}
+
+func (r *redact) Update(ctx context.Context, obj types.Object) (types.Object, error) {
+	result, err := r.CompleteStrategy.Update(ctx, obj)
+	return r.result(ctx, result, err)
+}
+
+func (r *redact) UpdateStatus(ctx context.Context, obj types.Object) (types.Object, error) {
+	result, err := r.CompleteStrategy.UpdateStatus(ctx, obj)
+	return r.result(ctx, result, err)
+}
+
+func (r *redact) Delete(ctx context.Context, obj types.Object) (types.Object, error) {
+	result, err := r.CompleteStrategy.Delete(ctx, obj)
+	return r.result(ctx, result, err)
+}
+
+func (r *redact) Get(ctx context.Context, namespace, name string) (types.Object, error) {
+	result, err := r.CompleteStrategy.Get(ctx, namespace, name)
+	return r.result(ctx, result, err)
+}
+
+func (r *redact) GetWithOptions(ctx context.Context, namespace, name string, opts metav1.GetOptions) (types.Object, error) {
+	result, err := strategy.GetWithOptions(ctx, r.CompleteStrategy, namespace, name, opts)
+	return r.result(ctx, result, err)
+}
+
+func (r *redact) List(ctx context.Context, namespace string, opts storage.ListOptions) (types.ObjectList, error) {
+	list, err := r.CompleteStrategy.List(ctx, namespace, opts)
+	if err != nil || len(r.paths) == 0 {
+		return list, err
+	}
+
+	var items []runtime.Object
+	err = meta.EachListItem(list, func(obj runtime.Object) error {
+		redacted, err := r.redact(ctx, obj.(types.Object))
+		items = append(items, redacted)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := meta.SetList(list, items); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *redact) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	w, err := r.CompleteStrategy.Watch(ctx, namespace, opts)
+	if err != nil || len(r.paths) == 0 {
+		return w, err
+	}
+
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+
+		for event := range w {
+			switch event.Type {
+			case watch.Added, watch.Modified, watch.Deleted:
+				obj, err := r.redact(ctx, event.Object.(types.Object))
+				if err != nil {
+					// Never send the unredacted object
+					event = watch.Event{
+						Type:   watch.Error,
+						Object: &apierrors.NewInternalError(errors.New("failed to redact object")).ErrStatus,
+					}
+				} else {
+					event.Object = obj
+				}
+			}
+
+			select {
+			case result <- event:
+			case <-ctx.Done():
+				// Drain the underlying watch so its sender is not blocked.
+				for range w {
+				}
+				return
+			}
+		}
+	}()
+
+	return result, nil
+}
@@ -1,7 +1,13 @@
 package strategy
 
 import (
+	"fmt"
+	"strconv"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apiserver/pkg/storage"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -20,3 +26,62 @@ func ToListOpts(namespace string, opts storage.ListOptions) *kclient.ListOptions
 		},
 	}
 }
+
+// ToStorageListOptions converts the options of a list or watch request, opts.Watch telling them apart, to the options
+// of Lister.List and Watcher.Watch with the semantics kinm supports. Selectors are parsed, and options that kinm
+// doesn't support, such as resourceVersionMatch and sendInitialEvents, or that can't be combined, such as a continue
+// token with a resourceVersion or a limit on a watch, return a BadRequest error. A nil opts lists everything at the
+// latest resource version.
+func ToStorageListOptions(opts *metav1.ListOptions) (storage.ListOptions, error) {
+	result := storage.ListOptions{
+		Predicate: storage.SelectionPredicate{
+			Label: labels.Everything(),
+			Field: fields.Everything(),
+		},
+		Recursive: true,
+	}
+	if opts == nil {
+		return result, nil
+	}
+
+	var err error
+	if opts.LabelSelector != "" {
+		if result.Predicate.Label, err = labels.Parse(opts.LabelSelector); err != nil {
+			return result, apierrors.NewBadRequest(fmt.Sprintf("invalid labelSelector: %v", err))
+		}
+	}
+	if opts.FieldSelector != "" {
+		if result.Predicate.Field, err = fields.ParseSelector(opts.FieldSelector); err != nil {
+			return result, apierrors.NewBadRequest(fmt.Sprintf("invalid fieldSelector: %v", err))
+		}
+	}
+	if opts.ResourceVersion != "" {
+		if _, err := strconv.ParseInt(opts.ResourceVersion, 10, 64); err != nil {
+			return result, apierrors.NewBadRequest(fmt.Sprintf("invalid resourceVersion %q, it must be an integer", opts.ResourceVersion))
+		}
+	}
+
+	switch {
+	case opts.ResourceVersionMatch != "":
+		return result, apierrors.NewBadRequest("resourceVersionMatch is not supported")
+	case opts.SendInitialEvents != nil:
+		return result, apierrors.NewBadRequest("sendInitialEvents is not supported")
+	case opts.Limit < 0:
+		return result, apierrors.NewBadRequest(fmt.Sprintf("invalid limit %d, it must not be negative", opts.Limit))
+	case opts.Watch && opts.Limit != 0:
+		return result, apierrors.NewBadRequest("limit is not supported in watch")
+	case opts.Watch && opts.Continue != "":
+		return result, apierrors.NewBadRequest("continue is not supported in watch")
+	case !opts.Watch && opts.AllowWatchBookmarks:
+		return result, apierrors.NewBadRequest("allowWatchBookmarks is only supported in watch")
+	case opts.Continue != "" && opts.ResourceVersion != "" && opts.ResourceVersion != "0":
+		// The continue token holds the resource version of the list it continues
+		return result, apierrors.NewBadRequest("resourceVersion can't be combined with continue")
+	}
+
+	result.ResourceVersion = opts.ResourceVersion
+	result.Predicate.Limit = opts.Limit
+	result.Predicate.Continue = opts.Continue
+	result.Predicate.AllowWatchBookmarks = opts.AllowWatchBookmarks
+	return result, nil
+}
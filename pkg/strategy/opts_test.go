@@ -0,0 +1,61 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestToStorageListOptions(t *testing.T) {
+	opts, err := ToStorageListOptions(&metav1.ListOptions{
+		LabelSelector: "test in (1,2)",
+		FieldSelector: "metadata.name=testname1",
+		Limit:         1,
+		Continue:      "1:a",
+	})
+	require.NoError(t, err)
+	assert.True(t, opts.Predicate.Label.Matches(labels.Set{"test": "1"}))
+	assert.False(t, opts.Predicate.Label.Matches(labels.Set{"test": "3"}))
+	assert.True(t, opts.Predicate.Field.Matches(fields.Set{"metadata.name": "testname1"}))
+	assert.False(t, opts.Predicate.Field.Matches(fields.Set{"metadata.name": "testname2"}))
+	assert.Equal(t, int64(1), opts.Predicate.Limit)
+	assert.Equal(t, "1:a", opts.Predicate.Continue)
+	assert.True(t, opts.Recursive)
+
+	// A continue token may come with the resourceVersion 0 that clients send by default
+	_, err = ToStorageListOptions(&metav1.ListOptions{ResourceVersion: "0", Continue: "1:a"})
+	require.NoError(t, err)
+
+	opts, err = ToStorageListOptions(&metav1.ListOptions{Watch: true, ResourceVersion: "2", AllowWatchBookmarks: true})
+	require.NoError(t, err)
+	assert.Equal(t, "2", opts.ResourceVersion)
+	assert.True(t, opts.Predicate.AllowWatchBookmarks)
+
+	opts, err = ToStorageListOptions(nil)
+	require.NoError(t, err)
+	assert.True(t, opts.Predicate.Label.Empty())
+	assert.True(t, opts.Predicate.Field.Empty())
+	assert.Empty(t, opts.ResourceVersion)
+
+	sendInitialEvents := true
+	for _, invalid := range []metav1.ListOptions{
+		{LabelSelector: "test in ("},
+		{FieldSelector: "metadata.name==="},
+		{ResourceVersion: "latest"},
+		{ResourceVersion: "1", ResourceVersionMatch: metav1.ResourceVersionMatchExact},
+		{Watch: true, SendInitialEvents: &sendInitialEvents},
+		{Limit: -1},
+		{Watch: true, Limit: 1},
+		{Watch: true, Continue: "1:a"},
+		{AllowWatchBookmarks: true},
+		{ResourceVersion: "2", Continue: "1:a"},
+	} {
+		_, err := ToStorageListOptions(&invalid)
+		assert.True(t, apierrors.IsBadRequest(err), "%+v: %v", invalid, err)
+	}
+}
@@ -0,0 +1,84 @@
+package strategy
+
+import (
+	"reflect"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+var _ rest.ResetFieldsStrategy = (*UpdateAdapter)(nil)
+
+// HasStatus returns true if obj is a struct with a Status field, which is stored through the status subresource.
+func HasStatus(obj runtime.Object) bool {
+	t := reflect.TypeOf(obj)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	_, ok := t.FieldByName("Status")
+	return ok
+}
+
+// GetResetFields returns the fields that updates can't change, so that server-side apply doesn't record ownership of
+// them: the status for the main resource, and the spec and metadata for the status subresource. A strategy that
+// implements rest.ResetFieldsStrategy overrides this for the main resource.
+func (a *UpdateAdapter) GetResetFields() map[fieldpath.APIVersion]*fieldpath.Set {
+	if o, ok := a.strategy.(rest.ResetFieldsStrategy); ok && !a.status {
+		return o.GetResetFields()
+	}
+
+	obj := a.New()
+	if !HasStatus(obj) {
+		return nil
+	}
+	gvk, err := apiutil.GVKForObject(obj, a.Scheme)
+	if err != nil {
+		return nil
+	}
+
+	set := fieldpath.NewSet(fieldpath.MakePathOrDie("status"))
+	if a.status {
+		set = fieldpath.NewSet(fieldpath.MakePathOrDie("spec"), fieldpath.MakePathOrDie("metadata"))
+	}
+	return map[fieldpath.APIVersion]*fieldpath.Set{
+		fieldpath.APIVersion(gvk.GroupVersion().String()): set,
+	}
+}
+
+// resetFields wipes the changes to obj that the adapter doesn't allow. Updates of the main resource keep the old
+// status and updates of the status subresource keep everything but the new status.
+func (a *UpdateAdapter) resetFields(obj, old runtime.Object) {
+	ResetFields(obj, old, a.status)
+}
+
+// ResetFields wipes the changes to obj, compared to old, that an update of the main resource or, if status is true,
+// of the status subresource doesn't own: the main resource keeps the old status, and the status subresource keeps
+// everything of old but the new status, resource version and managed fields. Objects without a Status field are left
+// unchanged.
+func ResetFields(obj, old runtime.Object, status bool) {
+	if !HasStatus(obj) || reflect.TypeOf(obj) != reflect.TypeOf(old) {
+		return
+	}
+
+	newValue := reflect.ValueOf(obj).Elem()
+	oldValue := reflect.ValueOf(old.DeepCopyObject()).Elem()
+	if !status {
+		newValue.FieldByName("Status").Set(oldValue.FieldByName("Status"))
+		return
+	}
+
+	newObj := obj.(types.Object)
+	statusValue := reflect.ValueOf(newValue.FieldByName("Status").Interface())
+	// The resource version is checked against the stored object and the managed fields track the status update
+	resourceVersion, managedFields := newObj.GetResourceVersion(), newObj.GetManagedFields()
+	newValue.Set(oldValue)
+	newValue.FieldByName("Status").Set(statusValue)
+	newObj.SetResourceVersion(resourceVersion)
+	newObj.SetManagedFields(managedFields)
+}
@@ -0,0 +1,71 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// DefaultRetry is the backoff used by UpdateWithRetry and DeleteWithRetry when none is given. It is the same as
+// client-go's retry.DefaultRetry.
+var DefaultRetry = retry.DefaultRetry
+
+// UpdateWithRetry gets the object, applies mutate to it and updates it. If the update fails because the object was
+// modified concurrently, the object is fetched again and the update is retried according to backoff. mutate may be
+// called several times and should only modify the object it is given. A zero backoff uses DefaultRetry.
+func UpdateWithRetry(ctx context.Context, backoff wait.Backoff, s Updater, namespace, name string, mutate func(obj types.Object) error) (result types.Object, err error) {
+	err = onConflict(ctx, backoff, func() error {
+		obj, err := s.Get(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
+		if err := mutate(obj); err != nil {
+			return err
+		}
+		result, err = s.Update(ctx, obj)
+		return err
+	})
+	return result, err
+}
+
+// DeleteWithRetry gets the object and deletes it, retrying according to backoff if the object was modified
+// concurrently. A zero backoff uses DefaultRetry.
+func DeleteWithRetry(ctx context.Context, backoff wait.Backoff, s Deleter, namespace, name string) (result types.Object, err error) {
+	err = onConflict(ctx, backoff, func() error {
+		obj, err := s.Get(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
+		result, err = s.Delete(ctx, obj)
+		return err
+	})
+	return result, err
+}
+
+func onConflict(ctx context.Context, backoff wait.Backoff, fn func() error) error {
+	if backoff.Steps == 0 {
+		backoff = DefaultRetry
+	}
+
+	var lastErr error
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(context.Context) (bool, error) {
+		lastErr = fn()
+		switch {
+		case lastErr == nil:
+			return true, nil
+		case apierrors.IsConflict(lastErr):
+			return false, nil
+		default:
+			return false, lastErr
+		}
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) && lastErr != nil {
+		// Return the last conflict rather than a generic timeout
+		return lastErr
+	}
+	return err
+}
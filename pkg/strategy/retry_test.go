@@ -0,0 +1,127 @@
+package strategy
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var configMapResource = schema.GroupResource{Resource: "configmaps"}
+
+// memoryStrategy stores a single config map, rejecting updates and deletes at a stale resource version.
+type memoryStrategy struct {
+	obj *corev1.ConfigMap
+	rv  int
+}
+
+func (m *memoryStrategy) New() types.Object {
+	return &corev1.ConfigMap{}
+}
+
+func (m *memoryStrategy) Create(_ context.Context, obj types.Object) (types.Object, error) {
+	m.obj = obj.(*corev1.ConfigMap).DeepCopy()
+	return m.write()
+}
+
+func (m *memoryStrategy) Get(_ context.Context, _, name string) (types.Object, error) {
+	if m.obj == nil || m.obj.Name != name {
+		return nil, apierrors.NewNotFound(configMapResource, name)
+	}
+	return m.obj.DeepCopy(), nil
+}
+
+func (m *memoryStrategy) Update(_ context.Context, obj types.Object) (types.Object, error) {
+	if err := m.check(obj); err != nil {
+		return nil, err
+	}
+	m.obj = obj.(*corev1.ConfigMap).DeepCopy()
+	return m.write()
+}
+
+func (m *memoryStrategy) Delete(_ context.Context, obj types.Object) (types.Object, error) {
+	if err := m.check(obj); err != nil {
+		return nil, err
+	}
+	result := m.obj
+	m.obj = nil
+	return result, nil
+}
+
+func (m *memoryStrategy) check(obj types.Object) error {
+	if m.obj == nil || m.obj.Name != obj.GetName() {
+		return apierrors.NewNotFound(configMapResource, obj.GetName())
+	}
+	if m.obj.ResourceVersion != obj.GetResourceVersion() {
+		return apierrors.NewConflict(configMapResource, obj.GetName(), nil)
+	}
+	return nil
+}
+
+func (m *memoryStrategy) write() (types.Object, error) {
+	m.rv++
+	m.obj.ResourceVersion = strconv.Itoa(m.rv)
+	return m.obj.DeepCopy(), nil
+}
+
+func TestUpdateWithRetry(t *testing.T) {
+	ctx := context.Background()
+	s := &memoryStrategy{}
+
+	_, err := s.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "retry", Namespace: "default"},
+	})
+	require.NoError(t, err)
+
+	attempts := 0
+	result, err := UpdateWithRetry(ctx, wait.Backoff{}, s, "default", "retry", func(obj types.Object) error {
+		attempts++
+		if attempts == 1 {
+			// Modify the object concurrently so that the first update conflicts
+			other, err := s.Get(ctx, "default", "retry")
+			require.NoError(t, err)
+			other.SetLabels(map[string]string{"concurrent": "true"})
+			_, err = s.Update(ctx, other)
+			require.NoError(t, err)
+		}
+		obj.(*corev1.ConfigMap).Data = map[string]string{"value": "updated"}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, "updated", result.(*corev1.ConfigMap).Data["value"])
+	assert.Equal(t, "true", result.GetLabels()["concurrent"])
+
+	_, err = DeleteWithRetry(ctx, wait.Backoff{}, s, "default", "retry")
+	require.NoError(t, err)
+	_, err = s.Get(ctx, "default", "retry")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestUpdateWithRetryConflict(t *testing.T) {
+	ctx := context.Background()
+	s := &memoryStrategy{}
+
+	_, err := s.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "retry", Namespace: "default"},
+	})
+	require.NoError(t, err)
+
+	// Every attempt conflicts, so the last conflict is returned once the steps are exhausted
+	attempts := 0
+	_, err = UpdateWithRetry(ctx, wait.Backoff{Steps: 3}, s, "default", "retry", func(obj types.Object) error {
+		attempts++
+		_, err := s.Update(ctx, obj.DeepCopyObject().(types.Object))
+		return err
+	})
+	assert.True(t, apierrors.IsConflict(err), "%v", err)
+	assert.Equal(t, 3, attempts)
+}
@@ -0,0 +1,43 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// SearchField is the field of a field selector that searches the objects of a Searcher instead of listing them, such
+// as "fieldSelector=search=hello". The other requirements of the selector filter the results.
+const SearchField = "search"
+
+type Searcher interface {
+	Search(ctx context.Context, namespace, query string, opts storage.ListOptions) (types.ObjectList, error)
+}
+
+// searchQuery returns the query of the search requirement of selector, and selector without it. ok is false if
+// selector doesn't search.
+func searchQuery(selector fields.Selector) (query string, _ fields.Selector, ok bool) {
+	for _, req := range selector.Requirements() {
+		if req.Field == SearchField && (req.Operator == selection.Equals || req.Operator == selection.DoubleEquals) {
+			query, ok = req.Value, true
+		}
+	}
+	if !ok {
+		return "", selector, false
+	}
+
+	rest, err := selector.Transform(func(field, value string) (string, string, error) {
+		if field == SearchField {
+			// Returning an empty field and value removes the requirement
+			return "", "", nil
+		}
+		return field, value, nil
+	})
+	if err != nil {
+		return "", selector, false
+	}
+	return query, rest, true
+}
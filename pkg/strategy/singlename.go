@@ -4,9 +4,26 @@ import (
 	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
+var (
+	_ rest.SingularNameProvider = (*SingularNameAdapter)(nil)
+	_ rest.ShortNamesProvider   = (*SingularNameAdapter)(nil)
+	_ rest.CategoriesProvider   = (*SingularNameAdapter)(nil)
+)
+
+// ShortNamer is implemented by objects that have short names, like "po" for pods.
+type ShortNamer interface {
+	ShortNames() []string
+}
+
+// Categorizer is implemented by objects that belong to categories, like "all", that kubectl get can list together.
+type Categorizer interface {
+	Categories() []string
+}
+
 type SingularNameAdapter struct {
 	Object runtime.Object
 	Scheme *runtime.Scheme
@@ -26,3 +43,17 @@ func (s *SingularNameAdapter) GetSingularName() string {
 	}
 	return strings.ToLower(name.Kind)
 }
+
+func (s *SingularNameAdapter) ShortNames() []string {
+	if o, ok := s.Object.(ShortNamer); ok {
+		return o.ShortNames()
+	}
+	return nil
+}
+
+func (s *SingularNameAdapter) Categories() []string {
+	if o, ok := s.Object.(Categorizer); ok {
+		return o.Categories()
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 )
 
 var _ rest.Storage = (*Status)(nil)
@@ -31,6 +32,7 @@ func NewStatus(scheme *runtime.Scheme, strategy StatusUpdater) *Status {
 		update: &UpdateAdapter{
 			CreateAdapter: NewCreate(scheme, strategy),
 			strategy:      strategy,
+			status:        true,
 		},
 		get:                   NewGet(strategy),
 		strategy:              strategy,
@@ -53,6 +55,10 @@ func (s *Status) Update(ctx context.Context, name string, objInfo rest.UpdatedOb
 	return s.update.update(ctx, true, name, objInfo, createValidation, updateValidation, false, options)
 }
 
+func (s *Status) GetResetFields() map[fieldpath.APIVersion]*fieldpath.Set {
+	return s.update.GetResetFields()
+}
+
 func (s *Status) ConvertToTable(ctx context.Context, object runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
 	if o, ok := s.strategy.(rest.TableConvertor); ok {
 		return o.ConvertToTable(ctx, object, tableOptions)
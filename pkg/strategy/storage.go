@@ -0,0 +1,309 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+var _ storage.Interface = (*StorageAdapter)(nil)
+
+// StorageAdapter implements storage.Interface on top of a strategy so that a genericregistry.Store can use kinm in
+// place of etcd. Keys are <prefix>/<namespace>/<name> for namespaced objects and <prefix>/<name> otherwise, which is
+// what the registry's default key functions produce.
+//
+// Objects are removed by the strategy once they have a deletion timestamp and no finalizers, so an update that
+// removes the last finalizer of a deleted object removes it, without a separate call to Delete.
+type StorageAdapter struct {
+	strategy  CompleteStrategy
+	prefix    string
+	versioner storage.APIObjectVersioner
+}
+
+func NewStorage(strategy CompleteStrategy, prefix string) *StorageAdapter {
+	return &StorageAdapter{
+		strategy: strategy,
+		prefix:   "/" + strings.Trim(prefix, "/"),
+	}
+}
+
+func (a *StorageAdapter) Versioner() storage.Versioner {
+	return a.versioner
+}
+
+// parseKey splits a key into a namespace and a name. A key with a single segment has no namespace.
+func (a *StorageAdapter) parseKey(key string) (namespace, name string, err error) {
+	rest, ok := strings.CutPrefix(key, a.prefix)
+	if !ok {
+		return "", "", fmt.Errorf("key %q is not under prefix %q", key, a.prefix)
+	}
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return "", parts[0], nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("invalid key %q", key)
+}
+
+// parseListKey returns the namespace of a key that refers to a collection, or "" for all namespaces.
+func (a *StorageAdapter) parseListKey(key string) (string, error) {
+	rest, ok := strings.CutPrefix(key, a.prefix)
+	if !ok {
+		return "", fmt.Errorf("key %q is not under prefix %q", key, a.prefix)
+	}
+	namespace := strings.Trim(rest, "/")
+	if strings.Contains(namespace, "/") {
+		return "", fmt.Errorf("invalid list key %q", key)
+	}
+	return namespace, nil
+}
+
+// toStorageError converts the errors of the strategy into the storage errors that the registry interprets.
+func toStorageError(key string, err error) error {
+	switch {
+	case apierrors.IsNotFound(err):
+		return storage.NewKeyNotFoundError(key, 0)
+	case apierrors.IsAlreadyExists(err):
+		return storage.NewKeyExistsError(key, 0)
+	case apierrors.IsConflict(err):
+		return storage.NewResourceVersionConflictsError(key, 0)
+	}
+	return err
+}
+
+// setObject copies src into dst, which must be a pointer to the same type.
+func setObject(dst, src runtime.Object) error {
+	dstValue, srcValue := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if dstValue.Type() != srcValue.Type() {
+		return fmt.Errorf("can't set %T to %T", dst, src)
+	}
+	dstValue.Elem().Set(srcValue.Elem())
+	return nil
+}
+
+func (a *StorageAdapter) Create(ctx context.Context, key string, obj, out runtime.Object, _ uint64) error {
+	namespace, name, err := a.parseKey(key)
+	if err != nil {
+		return err
+	}
+	kobj, ok := obj.(types.Object)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T", obj)
+	}
+	if kobj.GetResourceVersion() != "" {
+		return storage.ErrResourceVersionSetOnCreate
+	}
+	if kobj.GetName() != name || kobj.GetNamespace() != namespace {
+		return fmt.Errorf("object %s/%s does not match key %q", kobj.GetNamespace(), kobj.GetName(), key)
+	}
+
+	result, err := a.strategy.Create(ctx, kobj)
+	if err != nil {
+		return toStorageError(key, err)
+	}
+	return setObject(out, result)
+}
+
+func (a *StorageAdapter) Delete(ctx context.Context, key string, out runtime.Object, preconditions *storage.Preconditions,
+	validateDeletion storage.ValidateObjectFunc, _ runtime.Object) error {
+	namespace, name, err := a.parseKey(key)
+	if err != nil {
+		return err
+	}
+
+	for {
+		current, err := a.strategy.Get(ctx, namespace, name)
+		if err != nil {
+			return toStorageError(key, err)
+		}
+		if err := preconditions.Check(key, current); err != nil {
+			return err
+		}
+		if err := validateDeletion(ctx, current); err != nil {
+			return err
+		}
+
+		// The registry only deletes objects without finalizers, anything left would keep the strategy from removing it
+		current.SetFinalizers(nil)
+		result, err := a.strategy.Delete(ctx, current)
+		if apierrors.IsConflict(err) {
+			continue
+		} else if err != nil {
+			return toStorageError(key, err)
+		}
+		return setObject(out, result)
+	}
+}
+
+func (a *StorageAdapter) Watch(ctx context.Context, key string, opts storage.ListOptions) (watch.Interface, error) {
+	var (
+		namespace string
+		err       error
+	)
+	if opts.Recursive {
+		namespace, err = a.parseListKey(key)
+	} else {
+		var name string
+		namespace, name, err = a.parseKey(key)
+		opts.Predicate.Field = withName(opts.Predicate.Field, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c, err := a.strategy.Watch(ctx, namespace, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &watchResult{
+		cancel: cancel,
+		c:      c,
+	}, nil
+}
+
+func withName(selector fields.Selector, name string) fields.Selector {
+	nameSelector := fields.OneTermEqualSelector("metadata.name", name)
+	if selector == nil || selector.Empty() {
+		return nameSelector
+	}
+	return fields.AndSelectors(selector, nameSelector)
+}
+
+func (a *StorageAdapter) Get(ctx context.Context, key string, opts storage.GetOptions, objPtr runtime.Object) error {
+	namespace, name, err := a.parseKey(key)
+	if err != nil {
+		return err
+	}
+
+	// The latest version always satisfies the requested resource version
+	obj, err := a.strategy.Get(ctx, namespace, name)
+	if apierrors.IsNotFound(err) && opts.IgnoreNotFound {
+		return setObject(objPtr, reflect.New(reflect.TypeOf(objPtr).Elem()).Interface().(runtime.Object))
+	} else if err != nil {
+		return toStorageError(key, err)
+	}
+	return setObject(objPtr, obj)
+}
+
+func (a *StorageAdapter) GetList(ctx context.Context, key string, opts storage.ListOptions, listObj runtime.Object) error {
+	var (
+		namespace string
+		err       error
+	)
+	if opts.Recursive {
+		namespace, err = a.parseListKey(key)
+	} else {
+		var name string
+		namespace, name, err = a.parseKey(key)
+		opts.Predicate.Field = withName(opts.Predicate.Field, name)
+	}
+	if err != nil {
+		return err
+	}
+	if opts.ResourceVersion == "0" {
+		opts.ResourceVersion = ""
+	}
+	// The strategy lists the exact resource version when one is given, which also satisfies NotOlderThan
+	opts.ResourceVersionMatch = ""
+
+	list, err := a.strategy.List(ctx, namespace, opts)
+	if err != nil {
+		return toStorageError(key, err)
+	}
+	return setObject(listObj, list)
+}
+
+func (a *StorageAdapter) GuaranteedUpdate(ctx context.Context, key string, destination runtime.Object, ignoreNotFound bool,
+	preconditions *storage.Preconditions, tryUpdate storage.UpdateFunc, _ runtime.Object) error {
+	namespace, name, err := a.parseKey(key)
+	if err != nil {
+		return err
+	}
+
+	for {
+		exists := true
+		current, err := a.strategy.Get(ctx, namespace, name)
+		if apierrors.IsNotFound(err) {
+			if !ignoreNotFound {
+				return storage.NewKeyNotFoundError(key, 0)
+			}
+			exists = false
+			current = a.strategy.New()
+		} else if err != nil {
+			return toStorageError(key, err)
+		}
+
+		if err := preconditions.Check(key, current); err != nil {
+			return err
+		}
+
+		var res storage.ResponseMeta
+		if exists {
+			if res.ResourceVersion, err = strconv.ParseUint(current.GetResourceVersion(), 10, 64); err != nil {
+				return err
+			}
+		}
+
+		updated, _, err := tryUpdate(current.DeepCopyObject(), res)
+		if err != nil {
+			return err
+		}
+		obj, ok := updated.(types.Object)
+		if !ok {
+			return fmt.Errorf("unexpected object type %T", updated)
+		}
+
+		var result types.Object
+		if !exists {
+			obj.SetResourceVersion("")
+			result, err = a.strategy.Create(ctx, obj)
+		} else if equality.Semantic.DeepEqual(current, obj) {
+			result = current
+		} else {
+			obj.SetResourceVersion(current.GetResourceVersion())
+			result, err = a.strategy.Update(ctx, obj)
+		}
+		if apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+			continue
+		} else if err != nil {
+			return toStorageError(key, err)
+		}
+		return setObject(destination, result)
+	}
+}
+
+func (a *StorageAdapter) Count(key string) (int64, error) {
+	namespace, err := a.parseListKey(key)
+	if err != nil {
+		return 0, err
+	}
+	list, err := a.strategy.List(context.Background(), namespace, storage.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return int64(meta.LenList(list)), nil
+}
+
+func (a *StorageAdapter) ReadinessCheck() error {
+	return nil
+}
+
+func (a *StorageAdapter) RequestWatchProgress(context.Context) error {
+	// Watches send bookmarks on their own
+	return nil
+}
@@ -2,7 +2,9 @@ package strategy
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/obot-platform/kinm/pkg/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -14,11 +16,34 @@ type TableAdapter struct {
 	defaultTableConverter rest.TableConvertor
 }
 
+// NewTable returns an adapter that converts objects to tables with the strategy if it is a rest.TableConvertor, with
+// the columns declared by the strategy or its objects if either is a Columner, or with the default name and creation
+// time columns otherwise.
 func NewTable(strategy any) *TableAdapter {
-	return &TableAdapter{
+	result := &TableAdapter{
 		strategy:              strategy,
 		defaultTableConverter: rest.NewDefaultTableConvertor(schema.GroupResource{}),
 	}
+	if columns := columnsFor(strategy); len(columns) > 0 {
+		convertor, err := NewColumnTableConvertor(columns...)
+		if err != nil {
+			panic(fmt.Sprintf("invalid table columns: %v", err))
+		}
+		result.defaultTableConverter = convertor
+	}
+	return result
+}
+
+func columnsFor(strategy any) []Column {
+	if columner, ok := strategy.(Columner); ok && columner != nil {
+		return columner.Columns()
+	}
+	if newer, ok := strategy.(interface{ New() types.Object }); ok && newer != nil {
+		if columner, ok := newer.New().(Columner); ok {
+			return columner.Columns()
+		}
+	}
+	return nil
 }
 
 func (t *TableAdapter) ConvertToTable(ctx context.Context, object runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
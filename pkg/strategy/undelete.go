@@ -0,0 +1,61 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/obot-platform/kinm/pkg/types"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+var (
+	_ rest.Storage      = (*UndeleteAdapter)(nil)
+	_ rest.NamedCreater = (*UndeleteAdapter)(nil)
+)
+
+// Undeleter recreates deleted objects from their last stored revision. An empty uid keeps the previous UID.
+type Undeleter interface {
+	New() types.Object
+	Undelete(ctx context.Context, namespace, name string, uid ktypes.UID) (types.Object, error)
+}
+
+func NewUndelete(strategy Undeleter) *UndeleteAdapter {
+	return &UndeleteAdapter{
+		strategy: strategy,
+	}
+}
+
+// UndeleteAdapter serves an undelete subresource, which recreates the deleted object when it is created. The body is
+// an object of the same type; if it has a UID, the recreated object gets that UID.
+type UndeleteAdapter struct {
+	strategy Undeleter
+}
+
+func (a *UndeleteAdapter) New() runtime.Object {
+	return a.strategy.New()
+}
+
+func (a *UndeleteAdapter) Destroy() {
+}
+
+func (a *UndeleteAdapter) Create(ctx context.Context, name string, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions) (runtime.Object, error) {
+	if len(options.DryRun) != 0 && options.DryRun[0] == metav1.DryRunAll {
+		return nil, errors.NewBadRequest("dry run is not supported by undelete")
+	}
+	if createValidation != nil {
+		if err := createValidation(ctx, obj); err != nil {
+			return nil, err
+		}
+	}
+
+	var uid ktypes.UID
+	if o, ok := obj.(types.Object); ok {
+		uid = o.GetUID()
+	}
+	ns, _ := request.NamespaceFrom(ctx)
+	return a.strategy.Undelete(ctx, ns, name, uid)
+}
@@ -66,6 +66,7 @@ func (a *UpdateAdapter) AllowCreateOnUpdate() bool {
 }
 
 func (a *UpdateAdapter) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	a.resetFields(obj, old)
 	if a.PrepareForUpdater != nil {
 		a.PrepareForUpdater.PrepareForUpdate(ctx, obj, old)
 	} else if o, ok := a.strategy.(PrepareForUpdater); ok {
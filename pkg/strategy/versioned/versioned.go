@@ -0,0 +1,191 @@
+// Package versioned serves a kind in an API version other than the one it is stored in, converting the objects with
+// the conversion functions of the scheme.
+package versioned
+
+import (
+	"context"
+
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+var _ strategy.CompleteStrategy = (*Strategy)(nil)
+
+// Strategy serves the objects of a storage strategy in another version of the same kind. Objects are converted to the
+// storage version before they are written and to the served version when they are read or watched, so the database
+// only holds the storage version. The scheme of the storage strategy must have both versions and the conversion
+// functions between them.
+//
+// Field and label selectors are evaluated by the storage strategy on the stored objects, so they should only use
+// fields that have the same path in both versions, such as the metadata.
+type Strategy struct {
+	strategy strategy.CompleteStrategy
+	gvk      schema.GroupVersionKind
+}
+
+// NewStrategy returns a strategy serving the objects of storage in the version of obj.
+func NewStrategy(storage strategy.CompleteStrategy, obj types.Object) *Strategy {
+	return &Strategy{
+		strategy: storage,
+		gvk:      types.MustGetGVK(obj, storage.Scheme()),
+	}
+}
+
+func (s *Strategy) New() types.Object {
+	obj, err := s.strategy.Scheme().New(s.gvk)
+	if err != nil {
+		panic(err)
+	}
+	return obj.(types.Object)
+}
+
+func (s *Strategy) NewList() types.ObjectList {
+	obj, err := s.strategy.Scheme().New(s.gvk.GroupVersion().WithKind(s.gvk.Kind + "List"))
+	if err != nil {
+		panic(err)
+	}
+	return obj.(types.ObjectList)
+}
+
+func (s *Strategy) Scheme() *runtime.Scheme {
+	return s.strategy.Scheme()
+}
+
+func (s *Strategy) Destroy() {
+	s.strategy.Destroy()
+}
+
+func (s *Strategy) NamespaceScoped() bool {
+	return strategy.NewScoper(s.strategy).NamespaceScoped()
+}
+
+// toStorage converts obj from the served version to the storage version.
+func (s *Strategy) toStorage(obj types.Object) (types.Object, error) {
+	result := s.strategy.New()
+	if err := s.strategy.Scheme().Convert(obj, result, nil); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return result, nil
+}
+
+// toServed converts obj from the storage version to the served version.
+func (s *Strategy) toServed(obj types.Object, err error) (types.Object, error) {
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	result := s.New()
+	if err := s.strategy.Scheme().Convert(obj, result, nil); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *Strategy) Create(ctx context.Context, obj types.Object) (types.Object, error) {
+	stored, err := s.toStorage(obj)
+	if err != nil {
+		return nil, err
+	}
+	return s.toServed(s.strategy.Create(ctx, stored))
+}
+
+func (s *Strategy) Get(ctx context.Context, namespace, name string) (types.Object, error) {
+	return s.toServed(s.strategy.Get(ctx, namespace, name))
+}
+
+func (s *Strategy) Update(ctx context.Context, obj types.Object) (types.Object, error) {
+	stored, err := s.toStorage(obj)
+	if err != nil {
+		return nil, err
+	}
+	return s.toServed(s.strategy.Update(ctx, stored))
+}
+
+func (s *Strategy) UpdateStatus(ctx context.Context, obj types.Object) (types.Object, error) {
+	stored, err := s.toStorage(obj)
+	if err != nil {
+		return nil, err
+	}
+	return s.toServed(s.strategy.UpdateStatus(ctx, stored))
+}
+
+func (s *Strategy) Delete(ctx context.Context, obj types.Object) (types.Object, error) {
+	stored, err := s.toStorage(obj)
+	if err != nil {
+		return nil, err
+	}
+	return s.toServed(s.strategy.Delete(ctx, stored))
+}
+
+func (s *Strategy) List(ctx context.Context, namespace string, opts storage.ListOptions) (types.ObjectList, error) {
+	list, err := s.strategy.List(ctx, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []runtime.Object
+	err = meta.EachListItem(list, func(obj runtime.Object) error {
+		served, err := s.toServed(obj.(types.Object), nil)
+		if err != nil {
+			return err
+		}
+		items = append(items, served)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.NewList()
+	result.SetResourceVersion(list.GetResourceVersion())
+	result.SetContinue(list.GetContinue())
+	result.SetRemainingItemCount(list.GetRemainingItemCount())
+	return result, meta.SetList(result, items)
+}
+
+func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	w, err := s.strategy.Watch(ctx, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+		for event := range w {
+			switch event.Type {
+			case watch.Added, watch.Modified, watch.Deleted:
+				served, err := s.toServed(event.Object.(types.Object), nil)
+				if err != nil {
+					event = watch.Event{
+						Type:   watch.Error,
+						Object: &apierrors.NewInternalError(err).ErrStatus,
+					}
+				} else {
+					event.Object = served
+				}
+			case watch.Bookmark:
+				if obj, ok := event.Object.(types.Object); ok {
+					bookmark := s.New()
+					bookmark.SetResourceVersion(obj.GetResourceVersion())
+					bookmark.SetAnnotations(obj.GetAnnotations())
+					event.Object = bookmark
+				}
+			}
+			select {
+			case result <- event:
+			case <-ctx.Done():
+				// The watch of the storage strategy ends with ctx
+				for range w {
+				}
+				return
+			}
+		}
+	}()
+	return result, nil
+}
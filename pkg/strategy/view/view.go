@@ -0,0 +1,206 @@
+package view
+
+import (
+	"context"
+
+	"github.com/obot-platform/kinm/pkg/stores"
+	"github.com/obot-platform/kinm/pkg/strategy"
+	"github.com/obot-platform/kinm/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+var (
+	_ strategy.Getter  = (*Strategy)(nil)
+	_ strategy.Lister  = (*Strategy)(nil)
+	_ strategy.Watcher = (*Strategy)(nil)
+)
+
+// ReadStrategy is the subset of a strategy a view reads from.
+type ReadStrategy interface {
+	strategy.Getter
+	strategy.Lister
+	strategy.Watcher
+	Scheme() *runtime.Scheme
+}
+
+// TransformFunc converts an object of the underlying strategy into the object exposed by the view. The object passed
+// in is owned by the function and may be modified and returned. Returning a nil object hides it from the view.
+type TransformFunc func(ctx context.Context, obj types.Object) (types.Object, error)
+
+// Strategy is a read-only strategy that exposes the objects of another strategy after passing them through a
+// TransformFunc. It only implements the get, list, and watch verbs, so stores built from it never write to the
+// underlying table.
+type Strategy struct {
+	strategy  ReadStrategy
+	transform TransformFunc
+	obj       types.Object
+	list      types.ObjectList
+	gvk       schema.GroupVersionKind
+	resource  schema.GroupResource
+}
+
+// NewStrategy returns a view of s exposing objects of the type of obj, which must be registered in the scheme of s. A
+// nil obj exposes the type of s unchanged. Label and field selectors are evaluated against the underlying objects,
+// before they are transformed.
+func NewStrategy(s ReadStrategy, obj types.Object, transform TransformFunc) *Strategy {
+	if obj == nil {
+		obj = s.New()
+	}
+	gvk := types.MustGetGVK(obj, s.Scheme())
+	plural, _ := meta.UnsafeGuessKindToResource(gvk)
+	return &Strategy{
+		strategy:  s,
+		transform: transform,
+		obj:       obj,
+		list:      types.MustGetListType(obj, s.Scheme()),
+		gvk:       gvk,
+		resource:  plural.GroupResource(),
+	}
+}
+
+func (s *Strategy) New() types.Object {
+	return s.obj.DeepCopyObject().(types.Object)
+}
+
+func (s *Strategy) NewList() types.ObjectList {
+	return s.list.DeepCopyObject().(types.ObjectList)
+}
+
+func (s *Strategy) Scheme() *runtime.Scheme {
+	return s.strategy.Scheme()
+}
+
+func (s *Strategy) Destroy() {
+	if d, ok := s.strategy.(strategy.Destroyer); ok {
+		d.Destroy()
+	}
+}
+
+// NamespaceScoped returns the scope of the underlying strategy, since the objects of the view have the same names and
+// namespaces.
+func (s *Strategy) NamespaceScoped() bool {
+	return strategy.NewScoper(s.strategy).NamespaceScoped()
+}
+
+func (s *Strategy) toView(ctx context.Context, obj types.Object) (types.Object, error) {
+	result, err := s.transform(ctx, obj)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	result.GetObjectKind().SetGroupVersionKind(s.gvk)
+	return result, nil
+}
+
+func (s *Strategy) Get(ctx context.Context, namespace, name string) (types.Object, error) {
+	obj, err := s.strategy.Get(ctx, namespace, name)
+	if apierrors.IsNotFound(err) {
+		return nil, apierrors.NewNotFound(s.resource, name)
+	} else if err != nil {
+		return nil, err
+	}
+	result, err := s.toView(ctx, obj)
+	if err != nil {
+		return nil, err
+	} else if result == nil {
+		return nil, apierrors.NewNotFound(s.resource, name)
+	}
+	return result, nil
+}
+
+func (s *Strategy) List(ctx context.Context, namespace string, opts storage.ListOptions) (types.ObjectList, error) {
+	list, err := s.strategy.List(ctx, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []runtime.Object
+	err = meta.EachListItem(list, func(obj runtime.Object) error {
+		result, err := s.toView(ctx, obj.(types.Object))
+		if err != nil {
+			return err
+		}
+		if result != nil {
+			items = append(items, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.NewList()
+	if err := meta.SetList(result, items); err != nil {
+		return nil, err
+	}
+	result.SetContinue(list.GetContinue())
+	result.SetResourceVersion(list.GetResourceVersion())
+	return result, nil
+}
+
+// Watch transforms the events of the underlying watch. Events for objects hidden by the transform are dropped, so a
+// watcher is not told when an object it has seen becomes hidden.
+func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	w, err := s.strategy.Watch(ctx, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+
+		for event := range w {
+			switch event.Type {
+			case watch.Bookmark:
+				m, err := meta.Accessor(event.Object)
+				if err != nil {
+					continue
+				}
+				obj := s.New()
+				obj.SetResourceVersion(m.GetResourceVersion())
+				obj.GetObjectKind().SetGroupVersionKind(s.gvk)
+				event.Object = obj
+			case watch.Added, watch.Modified, watch.Deleted:
+				obj, err := s.toView(ctx, event.Object.(types.Object))
+				if err != nil {
+					event = watch.Event{
+						Type:   watch.Error,
+						Object: &apierrors.NewInternalError(err).ErrStatus,
+					}
+				} else if obj == nil {
+					continue
+				} else {
+					event.Object = obj
+				}
+			}
+
+			select {
+			case result <- event:
+			case <-ctx.Done():
+				// Drain the underlying watch so its sender is not blocked.
+				for range w {
+				}
+				return
+			}
+		}
+	}()
+
+	return result, nil
+}
+
+// NewStore returns a store serving only get, list, and watch for the view of s.
+func NewStore(s ReadStrategy, obj types.Object, transform TransformFunc) rest.Storage {
+	view := NewStrategy(s, obj, transform)
+	return stores.NewBuilder(view.Scheme(), view.New()).
+		WithGet(view).
+		WithList(view).
+		WithWatch(view).
+		WithDestroy(view).
+		Build()
+}
@@ -0,0 +1,168 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// TagName is the struct tag that marks fields for kinm, such as `kinm:"encrypt"` or `kinm:"encrypt,redact"`.
+const TagName = "kinm"
+
+// TaggedFields returns the dot separated JSON paths of the fields of the type of obj whose kinm tag has option. The
+// elements of slices and the values of maps are matched by a "*" segment, such as "spec.credentials.*.token".
+// Unstructured objects have no tags.
+func TaggedFields(obj any, option string) []string {
+	var paths []string
+	collectTaggedFields(reflect.TypeOf(obj), nil, option, map[reflect.Type]bool{}, &paths)
+	return paths
+}
+
+func collectTaggedFields(t reflect.Type, prefix []string, option string, visiting map[reflect.Type]bool, paths *[]string) {
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+		if t.Kind() != reflect.Pointer {
+			prefix = append(slices.Clip(prefix), "*")
+		}
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			// encoding/json inlines embedded structs
+			collectTaggedFields(field.Type, prefix, option, visiting, paths)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		path := append(slices.Clip(prefix), name)
+
+		if slices.Contains(strings.Split(field.Tag.Get(TagName), ","), option) {
+			*paths = append(*paths, strings.Join(path, "."))
+			continue
+		}
+		collectTaggedFields(field.Type, path, option, visiting, paths)
+	}
+}
+
+// RewriteFields calls fn with the JSON of the value at each of paths in the JSON object data, and replaces the value
+// with the JSON fn returns, or removes it if fn returns nil. Paths are dot separated, and a "*" segment matches every
+// element of an array or value of an object. The rest of data is copied unchanged, keeping the order of fields, so
+// that a value is only different if a field was rewritten.
+func RewriteFields(data []byte, paths []string, fn func(path string, value json.RawMessage) (json.RawMessage, error)) ([]byte, error) {
+	var split [][]string
+	for _, path := range paths {
+		split = append(split, strings.Split(path, "."))
+	}
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	result, err := rewriteValue(trimmed, split, paths, fn)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, data[len(trimmed):]...), nil
+}
+
+// rewriteValue rewrites the fields of value below which the remaining segments of paths are. The segments of all
+// paths are consumed in step, full being the original paths passed to fn.
+func rewriteValue(value json.RawMessage, paths [][]string, full []string, fn func(string, json.RawMessage) (json.RawMessage, error)) (json.RawMessage, error) {
+	value = bytes.TrimSpace(value)
+	if len(paths) == 0 || len(value) == 0 || (value[0] != '{' && value[0] != '[') {
+		return value, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(value))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteByte(value[0])
+	first := true
+	for dec.More() {
+		var key string
+		if value[0] == '{' {
+			token, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ = token.(string)
+		}
+		var item json.RawMessage
+		if err := dec.Decode(&item); err != nil {
+			return nil, err
+		}
+
+		// Paths ending here are rewritten, the others continue below the item
+		var (
+			below     [][]string
+			belowFull []string
+			remove    bool
+		)
+		for i, path := range paths {
+			if path[0] != "*" && (value[0] == '[' || path[0] != key) {
+				continue
+			}
+			if len(path) > 1 {
+				below = append(below, path[1:])
+				belowFull = append(belowFull, full[i])
+				continue
+			}
+			rewritten, err := fn(full[i], item)
+			if err != nil {
+				return nil, err
+			}
+			if rewritten == nil {
+				remove = true
+				break
+			}
+			item = rewritten
+		}
+		if remove && value[0] == '{' {
+			continue
+		} else if remove {
+			item = json.RawMessage("null")
+		}
+		item, err := rewriteValue(item, below, belowFull, fn)
+		if err != nil {
+			return nil, err
+		}
+
+		if !first {
+			out.WriteByte(',')
+		}
+		first = false
+		if value[0] == '{' {
+			encodedKey, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(encodedKey)
+			out.WriteByte(':')
+		}
+		out.Write(item)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if value[0] == '{' {
+		out.WriteByte('}')
+	} else {
+		out.WriteByte(']')
+	}
+	return out.Bytes(), nil
+}